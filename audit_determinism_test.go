@@ -0,0 +1,58 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+const (
+	TestAuditDeterminism_user     = "root"
+	TestAuditDeterminism_password = "password"
+	TestAuditDeterminism_host     = "localhost"
+	TestAuditDeterminism_port     = 3306
+	TestAuditDeterminism_charset  = "utf16"
+	TestAuditDeterminism_limit    = 70000 // Number of runes to extract, which keeps each run short
+)
+
+// TestAuditDeterminism extracts the same character set twice, generating a file each time, and fails if the files are
+// not identical. Any nondeterminism (such as map iteration order or consolidation that depends on timing) would
+// produce noisy diffs whenever a file is regenerated for GMS, so this should be run whenever the extraction or code
+// generation is changed. Both runs use the same server, so differences are caused by the extractor itself.
+func TestAuditDeterminism(t *testing.T) {
+	conn, err := utils.NewConnection(TestAuditDeterminism_user, TestAuditDeterminism_password, TestAuditDeterminism_host, TestAuditDeterminism_port)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	generate := func() string {
+		iter := utils.NewUTF8Iter()
+		iter.SetIteratorLimit(TestAuditDeterminism_limit)
+		tree := utils.NewCharacterSetEncodingTree()
+		CharacterSetToEncodingTree(t, conn, TestAuditDeterminism_charset, iter, tree)
+		rangeMap := EncodingTreeToRangeMap(t, tree)
+		iter.Reset()
+		caseMappings := CharacterSetCaseMappings(t, conn, TestAuditDeterminism_charset, rangeMap, iter, nil)
+		return utils.RangeMapToGoFileWithOptions(rangeMap, caseMappings, TestAuditDeterminism_charset, WriteArtifact_codegen)
+	}
+	first := generate()
+	second := generate()
+	if diff := utils.DiffGeneratedFiles(first, second, 20); diff != "" {
+		t.Fatalf("generated files differ between runs:\n%s", diff)
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+const (
+	// Extractions save their progress at this interval, so that an interrupted extraction may be resumed. Zero disables
+	// checkpoints.
+	Checkpoint_interval = 5 * time.Minute
+	// Resumes an interrupted extraction from its checkpoint, rather than starting from scratch. The checkpoint must
+	// belong to the same extraction, and is removed once the extraction completes.
+	Checkpoint_resume = false
+)
+
+// NewCheckpointer returns a Checkpointer for all tests that save checkpoints, using the options above. The checkpoint of
+// each extraction is named after the character set or collation.
+func NewCheckpointer(t *testing.T, name string) *utils.Checkpointer {
+	checkpointer, err := utils.NewCheckpointer("./"+name+".checkpoint.bin", Checkpoint_interval, Checkpoint_resume)
+	require.NoError(t, err)
+	return checkpointer
+}
@@ -0,0 +1,115 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/dolthub/collation-extractor/extractor"
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+// compare implements `compare`, which extracts the same character set or collation from two servers at once (such as
+// MySQL and Dolt) and reports every codepoint mapping, case conversion, and weight that differs between them. This is
+// equivalent to TestCompareTargets, and fails when any difference is found.
+func compare(ctx context.Context, kind string, args []string) error {
+	fs := flag.NewFlagSet("compare "+kind, flag.ContinueOnError)
+	var conn connectionFlags
+	var target connectionFlags
+	conn.register(fs)
+	target.registerTarget(fs)
+	report := fs.String("report", "", "the file that the differences are written to as JSON (not written when empty)")
+	maxDifferences := fs.Int("max-differences", 100, "the number of differences that are logged")
+	name, err := parseName(fs, args, kind)
+	if err != nil {
+		return err
+	}
+	target.timeout, target.queryTimeout = conn.timeout, conn.queryTimeout
+	target.retries, target.retryBackoff, target.prepare = conn.retries, conn.retryBackoff, conn.prepare
+
+	ctx, cancel := conn.context(ctx)
+	defer cancel()
+	pool, err := conn.connect(ctx, 1)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	targetPool, err := target.connect(ctx, 1)
+	if err != nil {
+		return err
+	}
+	defer targetPool.Close()
+	log.Printf("comparing `%s` between `%s` and `%s`", name, pool.Connection(0).Version(), targetPool.Connection(0).Version())
+
+	// Both servers are extracted at the same time, as each extraction spends most of its time waiting on its server
+	type result struct {
+		model *utils.Model
+		err   error
+	}
+	results := make(chan result, 1)
+	go func() {
+		model, err := extractModel(ctx, targetPool.Connection(0), kind, name)
+		results <- result{model, err}
+	}()
+	model, err := extractModel(ctx, pool.Connection(0), kind, name)
+	if err != nil {
+		cancel()
+		<-results
+		return err
+	}
+	targetResult := <-results
+	if targetResult.err != nil {
+		return fmt.Errorf("target server: %w", targetResult.err)
+	}
+
+	diff, err := utils.DiffModels(model, targetResult.model)
+	if err != nil {
+		return err
+	}
+	for i, str := range diff.Strings() {
+		if i == *maxDifferences {
+			log.Printf("...and %d more differences", diff.Len()-i)
+			break
+		}
+		log.Print(str)
+	}
+	if *report != "" {
+		contents, err := json.MarshalIndent(diff, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err = os.WriteFile(*report, append(contents, '\n'), 0644); err != nil {
+			return err
+		}
+	}
+	if diff.Len() > 0 {
+		return fmt.Errorf("found %d differences for `%s`", diff.Len(), name)
+	}
+	log.Printf("`%s` is identical on both servers", name)
+	return nil
+}
+
+// extractModel returns the Model of the given character set or collation.
+func extractModel(ctx context.Context, conn *utils.Connection, kind string, name string) (*utils.Model, error) {
+	if kind == utils.ManifestKindCharset {
+		return extractor.ExtractCharsetModel(ctx, conn, name)
+	}
+	return extractor.ExtractCollation(ctx, conn, name, nil)
+}
@@ -0,0 +1,148 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/dolthub/collation-extractor/extractor"
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+// compareVersions implements `compare versions`, which extracts the same character set or collation from several
+// server versions (each started in Docker from one of -images), and reports every codepoint that changed between each
+// version and the version before it. Unlike `compare`, differences are expected, so this only fails when an extraction
+// fails. This is equivalent to TestCompareVersions.
+func compareVersions(ctx context.Context, kind string, args []string) error {
+	fs := flag.NewFlagSet("compare versions "+kind, flag.ContinueOnError)
+	var conn connectionFlags
+	conn.register(fs)
+	images := fs.String("images", "", "a comma-separated list of the images to compare in order from the oldest, such as mysql:5.7,mysql:8.0,mysql:8.4")
+	jobs := fs.Int("jobs", 1, "the number of versions that are started and extracted in parallel")
+	report := fs.String("report", "", "the file that the changes are written to as JSON (not written when empty)")
+	maxDifferences := fs.Int("max-differences", 100, "the number of codepoints that are logged")
+	name, err := parseName(fs, args, kind)
+	if err != nil {
+		return err
+	}
+	if *images == "" {
+		return fmt.Errorf("-images is required")
+	}
+	if *jobs < 1 {
+		return fmt.Errorf("-jobs must be at least 1")
+	}
+	imageList := strings.Split(*images, ",")
+	if len(imageList) < 2 {
+		return fmt.Errorf("at least two images are required to compare versions")
+	}
+
+	ctx, cancel := conn.context(ctx)
+	defer cancel()
+	versions := make([]string, len(imageList))
+	models := make([]*utils.Model, len(imageList))
+	errs := make([]error, len(imageList))
+	// Each job takes the next image, and its container is removed before the job moves on
+	imageQueue := make(chan int, len(imageList))
+	for i := range imageList {
+		imageQueue <- i
+	}
+	close(imageQueue)
+	wg := sync.WaitGroup{}
+	for job := 0; job < *jobs; job++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range imageQueue {
+				if ctx.Err() != nil {
+					return
+				}
+				versions[i], models[i], errs[i] = extractVersion(ctx, conn, imageList[i], kind, name)
+				if errs[i] != nil {
+					cancel()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("`%s`: %w", imageList[i], err)
+		}
+	}
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+
+	comparison, err := utils.CompareVersions(name, kind, versions, models)
+	if err != nil {
+		return err
+	}
+	for _, version := range comparison.Missing {
+		log.Printf("`%s` does not offer `%s`", version, name)
+	}
+	for _, change := range comparison.Changes {
+		log.Printf("%d differences from `%s` to `%s`", change.Diff.Len(), change.From, change.To)
+	}
+	for i, history := range comparison.Codepoints {
+		if i == *maxDifferences {
+			log.Printf("...and %d more codepoints", len(comparison.Codepoints)-i)
+			break
+		}
+		for _, str := range history.Changes {
+			log.Print(str)
+		}
+	}
+	if *report != "" {
+		contents, err := json.MarshalIndent(comparison, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err = os.WriteFile(*report, append(contents, '\n'), 0644); err != nil {
+			return err
+		}
+	}
+	log.Printf("`%s` changed %d codepoints across %d versions", name, len(comparison.Codepoints), len(versions))
+	return nil
+}
+
+// extractVersion starts the given image and returns its server version along with the Model of the given character set
+// or collation, which is nil when the version does not offer it. The container is removed before returning.
+func extractVersion(ctx context.Context, conn connectionFlags, image string, kind string, name string) (string, *utils.Model, error) {
+	conn.docker = image
+	pool, err := conn.connect(ctx, 1)
+	if err != nil {
+		return "", nil, err
+	}
+	defer pool.Close()
+	version := pool.Connection(0).Version()
+	exists, err := extractor.Exists(ctx, pool.Connection(0), kind, name)
+	if err != nil || !exists {
+		return version, nil, err
+	}
+	log.Printf("extracting `%s` from `%s`", name, version)
+	model, err := extractModel(ctx, pool.Connection(0), kind, name)
+	if err != nil {
+		return "", nil, err
+	}
+	model.Provenance = utils.NewProvenance(version, 0)
+	return version, model, nil
+}
@@ -0,0 +1,653 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dolthub/collation-extractor/extractor"
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+// companionTestSeed selects the samples of every companion test, so that re-running an extraction writes the same test.
+const companionTestSeed = 1
+
+// extractCharset implements `extract charset`, which creates a Go file containing the data necessary to encode and
+// decode the character set. This is equivalent to TestExtractCharacterSet.
+func extractCharset(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("extract charset", flag.ContinueOnError)
+	var conn connectionFlags
+	var out outputFlags
+	conn.register(fs)
+	out.register(fs)
+	workers := fs.Int("workers", 1, "the number of connections that convert runes in parallel, which disables checkpoints when greater than 1")
+	testSamples := fs.Int("test-samples", 100, "the number of codepoints converted by the server for the companion test (zero to skip the test)")
+	var options extractor.CharacterSetOptions
+	registerCharacterSetOptions(fs, &options)
+	charset, err := parseName(fs, args, "character set")
+	if err != nil {
+		return err
+	}
+
+	checkpointer, err := out.checkpointer(charset)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := conn.context(ctx)
+	defer cancel()
+	pool, err := conn.connect(ctx, *workers)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	if _, err = writeCharset(ctx, pool, out, charset, options, *testSamples, checkpointer); err != nil {
+		return err
+	}
+	return out.writeStats(pool)
+}
+
+// registerCharacterSetOptions adds the flags of the given options to the flag set.
+func registerCharacterSetOptions(fs *flag.FlagSet, options *extractor.CharacterSetOptions) {
+	fs.BoolVar(&options.SkipUnassigned, "skip-unassigned", false, "only converts the runes that are assigned according to Go's Unicode tables, other than for the Unicode character sets")
+	fs.BoolVar(&options.VerifyUnassigned, "verify-unassigned", false, "also converts the runes that -skip-unassigned skipped, logging and keeping any that are encoded")
+}
+
+// writeCharset extracts the given character set, and writes the generated files. Returns the character set's RangeMap,
+// which is needed to extract its collations.
+func writeCharset(ctx context.Context, pool *utils.ConnectionPool, out outputFlags, charset string, options extractor.CharacterSetOptions, testSamples int, checkpointer *utils.Checkpointer) (*utils.RangeMap, error) {
+	if err := out.codegen.CheckName(charset); err != nil {
+		return nil, err
+	}
+	c := pool.Connection(0)
+	start := startExtraction(pool)
+	rangeMap, err := characterSetRangeMapWithOptions(ctx, pool, charset, options, checkpointer)
+	if err != nil {
+		return nil, err
+	}
+	if err = utils.CharacterSetQuirksFor(charset).Verify(rangeMap); err != nil {
+		return nil, err
+	}
+	log.Printf("`%s` is ASCII compatible: %t", charset, rangeMap.IsASCIICompatible())
+	metadata, err := extractor.CharacterSetMetadata(ctx, c, charset)
+	if err != nil {
+		return nil, err
+	}
+	if err = metadata.ApplyRangeMap(rangeMap); err != nil {
+		return nil, err
+	}
+	// The bytes of the binary character set are never converted, so it has neither lossy mappings nor case mappings
+	var lossyMappings []utils.LossyMapping
+	caseMappings := utils.NewCaseMappings()
+	if charset != extractor.BinaryCharacterSet {
+		// The runes are converted using the same queries as the RangeMap, so they are read from the query cache
+		if lossyMappings, err = extractor.CharacterSetLossyMappings(ctx, c, charset, rangeMap, utils.NewUTF8Iter(), log.Printf); err != nil {
+			return nil, err
+		}
+		for _, lossyMapping := range lossyMappings {
+			log.Printf("`%s` lossy mapping: %s", charset, lossyMapping.String())
+		}
+		if caseMappings, err = extractor.CharacterSetCaseMappings(ctx, c, charset, rangeMap, utils.NewUTF8Iter(), checkpointer); err != nil {
+			return nil, err
+		}
+	}
+	model := utils.NewCharacterSetModel(charset, rangeMap, caseMappings)
+	model.Provenance = utils.NewProvenance(c.Version(), 0)
+	out.codegen.Provenance = model.Provenance
+	modelPath := out.path(charset + ".model.json")
+	if err = model.Save(modelPath); err != nil {
+		return nil, err
+	}
+	if err = utils.SaveEncodingTree(out.path(charset+".tree.bin"), rangeMap.Tree()); err != nil {
+		return nil, err
+	}
+	if err = utils.RegisteredExtractionHooks().BeforeCodegen(model); err != nil {
+		return nil, err
+	}
+
+	path, err := out.writeArtifact(charset+".go", []byte(utils.RangeMapToGoFileWithOptions(rangeMap, caseMappings, charset, out.codegen)))
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("wrote `%s`", path)
+	if _, err = out.writeArtifact(charset+"_text_encoding.go", []byte(utils.TextEncodingToGoFile(charset, out.codegen))); err != nil {
+		return nil, err
+	}
+	if _, err = out.writeArtifact(charset+"_metadata.go", []byte(utils.CharacterSetMetadataToGoFile(metadata, out.codegen))); err != nil {
+		return nil, err
+	}
+	if _, err = out.writeArtifact(charset+"_provenance.go", []byte(utils.ProvenanceToGoFile(charset, model.Provenance, out.codegen))); err != nil {
+		return nil, err
+	}
+	if len(lossyMappings) > 0 {
+		if _, err = out.writeArtifact(charset+"_lossy.go", []byte(utils.LossyMappingsToGoFile(charset, lossyMappings, out.codegen))); err != nil {
+			return nil, err
+		}
+	}
+	if testSamples > 0 {
+		samples, err := extractor.CharacterSetSamples(ctx, c, charset, rangeMap, testSamples, companionTestSeed)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = out.writeArtifact(charset+"_test.go", []byte(utils.CharacterSetTestToGoFile(charset, samples, out.codegen))); err != nil {
+			return nil, err
+		}
+	}
+	start.finish(utils.NewCharacterSetStats(charset, rangeMap))
+	err = out.updateManifest(utils.ManifestEntry{
+		Name:  charset,
+		Kind:  utils.ManifestKindCharset,
+		File:  path,
+		Model: modelPath,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rangeMap, checkpointer.Remove()
+}
+
+// collationFlags are the flags that control the extraction of a collation.
+type collationFlags struct {
+	strategy           string
+	weightCacheSample  int
+	derivedAge         string
+	unicodeVersion     string
+	workers            int
+	base               string
+	codegen            string
+	mapChunkSize       int
+	dynamicRangeCutoff int
+	staticRangeCutoff  int
+	autoTuneCutoffs    bool
+	equivalenceClasses bool
+	caseFolding        bool
+	accentStripping    bool
+	ignorables         bool
+	implicitWeights    bool
+	testSamples        int
+	validateStrings    int
+}
+
+// register adds the collation flags to the given flag set.
+func (cf *collationFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&cf.strategy, "strategy", "", "the extraction strategy, selected based on the collation's name when empty")
+	fs.IntVar(&cf.weightCacheSample, "weight-cache-sample", 100, "verifies one of every N seeded weights against the server")
+	fs.StringVar(&cf.derivedAge, "derived-age", "", "the DerivedAge.txt file that the extracted runes are pinned to (every rune when empty)")
+	fs.StringVar(&cf.unicodeVersion, "unicode-version", "", "the Unicode version that the extracted runes are pinned to (the DerivedAge version when empty)")
+	fs.IntVar(&cf.workers, "workers", 1, "the number of connections that query runes in parallel, which disables checkpoints when greater than 1")
+	fs.StringVar(&cf.codegen, "codegen", "", fmt.Sprintf("the form of the generated weights, one of %v (map when empty)", utils.CollationCodegens()))
+	fs.IntVar(&cf.mapChunkSize, "map-chunk-size", 0, "the maximum number of entries within each literal of the weight map (a single literal when zero)")
+	fs.IntVar(&cf.dynamicRangeCutoff, "dynamic-range-cutoff", 0, "the minimum span of sequential weights that the weight function computes rather than listing within the weight map (100 when zero)")
+	fs.IntVar(&cf.staticRangeCutoff, "static-range-cutoff", 0, "the minimum span of runes sharing a weight that the weight function compares rather than listing within the weight map (100 when zero)")
+	fs.BoolVar(&cf.autoTuneCutoffs, "auto-tune-cutoffs", false, "picks the range cutoffs with the smallest weight map and range comparisons, overriding -dynamic-range-cutoff and -static-range-cutoff")
+	fs.BoolVar(&cf.equivalenceClasses, "equivalence-classes", false, "also writes the groups of runes that share a weight")
+	fs.BoolVar(&cf.caseFolding, "case-folding", false, "also writes the rune that each rune folds to when the collation ignores case, reusing the character set's model from the output directory when one exists")
+	fs.BoolVar(&cf.accentStripping, "accent-stripping", false, "also writes the base rune that each rune strips to when the collation ignores accents, using the accent-sensitive counterpart's model from the output directory (such as utf8mb4_0900_as_ci for utf8mb4_0900_ai_ci) to find the bases when one exists")
+	fs.BoolVar(&cf.ignorables, "ignorables", false, "detects the runes that the collation ignores entirely (such as the soft hyphen), which the generated comparison skips")
+	fs.BoolVar(&cf.implicitWeights, "implicit-weights", false, "computes the implicit weights of UCA 9.0.0 collations (such as those of unassigned codepoints) by formula, rather than listing them within the weight map")
+	fs.IntVar(&cf.testSamples, "test-samples", 100, "the number of rune pairs compared by the server for the companion test (zero to skip the test)")
+	fs.IntVar(&cf.validateStrings, "validate-strings", 0, "compares N random pairs of multi-rune strings against the server before writing the collation's files, failing on any mismatch such as those caused by contractions (disabled when zero)")
+	fs.StringVar(&cf.base, "base", "", "the output directory of a previous extraction, whose saved rune order only has the missing runes inserted")
+}
+
+// extractCollation implements `extract collation`, which creates a Go file containing the data necessary to sort and
+// compare strings using the collation. This is equivalent to TestExtractCollation.
+func extractCollation(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("extract collation", flag.ContinueOnError)
+	var conn connectionFlags
+	var out outputFlags
+	var cf collationFlags
+	conn.register(fs)
+	out.register(fs)
+	cf.register(fs)
+	weightCacheImport := fs.String("weight-cache-import", "", "seeds the extraction with the weight cache of a related collation")
+	collation, err := parseName(fs, args, "collation")
+	if err != nil {
+		return err
+	}
+	// All collations start with the character set followed by an underscore
+	charset := strings.Split(collation, "_")[0]
+	runeToWeight := make(map[rune][]byte)
+	if *weightCacheImport != "" {
+		if runeToWeight, err = utils.LoadWeightCache(*weightCacheImport); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := conn.context(ctx)
+	defer cancel()
+	pool, err := conn.connect(ctx, cf.workers)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	rangeMap, err := characterSetRangeMap(ctx, pool, charset, nil)
+	if err != nil {
+		return err
+	}
+	if err = writeCollation(ctx, pool, out, cf, collation, charset, rangeMap, runeToWeight); err != nil {
+		return err
+	}
+	return out.writeStats(pool)
+}
+
+// extractCollations implements `extract collations`, which creates a Go file for every collation of a character set.
+// The character set is only extracted once, and is shared by every collation.
+func extractCollations(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("extract collations", flag.ContinueOnError)
+	var conn connectionFlags
+	var out outputFlags
+	var cf collationFlags
+	conn.register(fs)
+	out.register(fs)
+	cf.register(fs)
+	charset := fs.String("charset", "", "the character set whose collations are extracted")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *charset == "" {
+		return fmt.Errorf("the character set name is required")
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	ctx, cancel := conn.context(ctx)
+	defer cancel()
+	pool, err := conn.connect(ctx, cf.workers)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	collations, err := extractor.CharacterSetCollations(ctx, pool.Connection(0), *charset)
+	if err != nil {
+		return err
+	}
+	log.Printf("extracting %d collations of `%s`: %s", len(collations), *charset, strings.Join(collations, ", "))
+	rangeMap, err := characterSetRangeMap(ctx, pool, *charset, nil)
+	if err != nil {
+		return err
+	}
+	for _, collation := range collations {
+		// Each collation is extracted independently, so their weights are not shared
+		if err = writeCollation(ctx, pool, out, cf, collation, *charset, rangeMap, make(map[rune][]byte)); err != nil {
+			return fmt.Errorf("`%s`: %w", collation, err)
+		}
+	}
+	return out.writeStats(pool)
+}
+
+// characterSetRangeMap extracts the RangeMap of the given character set, converting runes in parallel when the pool has
+// more than one connection. Parallel extraction does not use the checkpointer.
+func characterSetRangeMap(ctx context.Context, pool *utils.ConnectionPool, charset string, checkpointer *utils.Checkpointer) (*utils.RangeMap, error) {
+	return characterSetRangeMapWithOptions(ctx, pool, charset, extractor.CharacterSetOptions{}, checkpointer)
+}
+
+// characterSetRangeMapWithOptions is the same as characterSetRangeMap, but only converts the runes that the options
+// select.
+func characterSetRangeMapWithOptions(ctx context.Context, pool *utils.ConnectionPool, charset string, options extractor.CharacterSetOptions, checkpointer *utils.Checkpointer) (*utils.RangeMap, error) {
+	if pool.Size() > 1 {
+		return extractor.ParallelCharacterSetToRangeMapWithOptions(ctx, pool, charset, options, log.Printf)
+	}
+	return extractor.CharacterSetToRangeMapWithOptions(ctx, pool.Connection(0), charset, options, log.Printf, checkpointer)
+}
+
+// writeCollation extracts the given collation using the character set's RangeMap, and writes the generated files. The
+// weight map may be seeded, and is exported as the collation's weight cache.
+func writeCollation(ctx context.Context, pool *utils.ConnectionPool, out outputFlags, cf collationFlags, collation string, charset string, rangeMap *utils.RangeMap, runeToWeight map[rune][]byte) error {
+	c := pool.Connection(0)
+	start := startExtraction(pool)
+	profile := utils.SelectExtractionProfile(collation)
+	if cf.strategy != "" {
+		profile.Strategy = utils.ExtractionStrategy(cf.strategy)
+	}
+	// The codegen is validated before extracting, rather than once the extraction has completed
+	if _, err := utils.ParseCollationCodegen(cf.codegen); err != nil {
+		return err
+	}
+	if err := out.codegen.CheckName(collation); err != nil {
+		return err
+	}
+	// A collation from the other server flavor would have its files overwritten, so it's checked before extracting
+	if err := out.checkManifestFlavor(collation, c.Builder().Flavor()); err != nil {
+		return err
+	}
+	iter, pinnedVersion, err := utils.NewPinnedUTF8Iter(cf.derivedAge, cf.unicodeVersion)
+	if err != nil {
+		return err
+	}
+	// Only the STRCMP strategy saves checkpoints, as the other strategies are fast enough to restart
+	checkpointer, err := out.checkpointer(collation)
+	if err != nil {
+		return err
+	}
+
+	var runeComparator *utils.RuneComparator
+	if profile.Strategy == utils.ExtractionStrategyBinary && cf.base == "" {
+		runeComparator, err = extractor.CollationToRuneComparatorBinary(ctx, c, collation, charset, iter, rangeMap, log.Printf)
+		if errors.Is(err, extractor.ErrNotByteOrder) {
+			log.Printf("%v, falling back to `%s`", err, utils.ExtractionStrategyStrcmp)
+			profile = utils.ExtractionProfile{Collation: profile.Collation, Strategy: utils.ExtractionStrategyStrcmp}
+			iter.Reset()
+		} else if err != nil {
+			return err
+		}
+	}
+	if profile.Strategy == utils.ExtractionStrategyDelta && cf.base == "" {
+		base, err := tailoringBaseRuneComparator(out, profile)
+		if err != nil {
+			return err
+		}
+		if base == nil {
+			log.Printf("the base collation `%s` has not been extracted to the output directory, falling back to `%s`", profile.Base, utils.ExtractionStrategyStrcmp)
+			profile = utils.ExtractionProfile{Collation: profile.Collation, Strategy: utils.ExtractionStrategyStrcmp}
+		} else if runeComparator, err = extractor.CollationTailoringToRuneComparator(ctx, c, collation, charset, iter, rangeMap, base, runeToWeight, log.Printf); err != nil {
+			return err
+		}
+	}
+	switch {
+	case runeComparator != nil:
+	case cf.base != "":
+		if runeComparator, err = baseRuneComparator(cf.base, collation, runeToWeight); err != nil {
+			return err
+		}
+		runeComparator, err = extractor.CollationDeltaToRuneComparator(ctx, c, collation, charset, iter, rangeMap, runeComparator, runeToWeight, log.Printf)
+		if err != nil {
+			return err
+		}
+	case profile.Strategy == utils.ExtractionStrategyOrderBy:
+		runeComparator, err = extractor.CollationToRuneComparatorOrderBy(ctx, c, collation, charset, iter, rangeMap, runeToWeight, log.Printf)
+		if err != nil {
+			return err
+		}
+	default:
+		// STRCMP probing works for every collation, so it is the fallback for strategies that are not yet implemented
+		if profile.Strategy != utils.ExtractionStrategyStrcmp {
+			log.Printf("strategy `%s` is not implemented, falling back to `%s`", profile.Strategy, utils.ExtractionStrategyStrcmp)
+			profile = utils.ExtractionProfile{Collation: profile.Collation, Strategy: utils.ExtractionStrategyStrcmp}
+		}
+		seedSample := cf.weightCacheSample
+		if pool.Size() > 1 {
+			// The weights are retrieved in parallel beforehand, so every weight in the map may be trusted afterward
+			if err = extractor.ParallelCollationWeights(ctx, pool, collation, charset, iter, rangeMap, runeToWeight, seedSample, log.Printf); err != nil {
+				return err
+			}
+			iter.Reset()
+			seedSample = 0
+		}
+		runeComparator, err = extractor.CollationToRuneComparator(ctx, c, collation, charset, iter, rangeMap, runeToWeight, seedSample, log.Printf, checkpointer)
+		if err != nil {
+			return err
+		}
+	}
+	if err = utils.SaveWeightCache(out.path(collation+".weights.txt"), runeToWeight); err != nil {
+		return err
+	}
+	if err = runeComparator.Save(out.path(collation + ".order.bin")); err != nil {
+		return err
+	}
+	padSpace, err := extractor.CollationPadSpace(ctx, c, collation, charset)
+	if err != nil {
+		return err
+	}
+	metadata, err := extractor.CollationMetadata(ctx, c, collation)
+	if err != nil {
+		return err
+	}
+	model := utils.NewCollationModel(collation, runeComparator, padSpace)
+	model.Provenance = utils.NewProvenance(c.Version(), metadata.ID)
+	out.codegen.Provenance = model.Provenance
+	if cf.ignorables {
+		if model.Ignorables, err = extractor.CollationIgnorables(ctx, c, collation, charset, runeComparator, runeToWeight); err != nil {
+			return err
+		}
+		log.Printf("found %d ignorable runes", len(model.Ignorables))
+	}
+	modelPath := out.path(collation + ".model.json")
+	if err = model.Save(modelPath); err != nil {
+		return err
+	}
+	if err = utils.RegisteredExtractionHooks().BeforeCodegen(model); err != nil {
+		return err
+	}
+
+	// The implicit weights change the weights of the generated files, so every file is generated from the same
+	// RuneComparator, while the saved order and model keep the original weights
+	codegenComparator := runeComparator
+	goFileOptions := utils.RuneComparatorGoFileOptions{
+		MapChunkSize:       cf.mapChunkSize,
+		DynamicRangeCutoff: cf.dynamicRangeCutoff,
+		StaticRangeCutoff:  cf.staticRangeCutoff,
+		Ignorables:         model.Ignorables,
+	}
+	if cf.implicitWeights {
+		codegenComparator, goFileOptions.ImplicitWeights, err = extractor.CollationImplicitWeights(collation, runeComparator, runeToWeight)
+		if err != nil {
+			return err
+		}
+		log.Printf("found %d implicit weight regions", len(goFileOptions.ImplicitWeights))
+	}
+	if cf.autoTuneCutoffs {
+		tuneCollationCutoffs(&goFileOptions, codegenComparator)
+	}
+	if cf.validateStrings > 0 {
+		runeWeights, err := utils.ParseRuneComparatorGoFile(utils.RuneComparatorToGoFileWithOptions(codegenComparator, collation, padSpace, goFileOptions))
+		if err != nil {
+			return err
+		}
+		// The model has already been saved, so the files may still be written by `generate` once the mismatches are
+		// understood
+		if err = validateCollationStrings(ctx, c, collation, charset, runeWeights, cf.validateStrings, 0); err != nil {
+			return err
+		}
+	}
+	path, err := writeCollationFile(out, cf.codegen, goFileOptions, codegenComparator, collation, padSpace)
+	if err != nil {
+		return err
+	}
+	log.Printf("wrote `%s`", path)
+	if _, err = out.writeArtifact(collation+"_reverse.go", []byte(utils.RuneComparatorReverseToGoFile(codegenComparator, collation, out.codegen))); err != nil {
+		return err
+	}
+	// Dolt loads the same weights from a binary table, so that both projects are fed from a single extraction
+	if _, err = out.writeArtifact(collation+".dolt", utils.RuneComparatorToDoltFile(codegenComparator, collation)); err != nil {
+		return err
+	}
+	expansions, err := extractor.CollationExpansions(collation, runeToWeight)
+	if err != nil {
+		return err
+	}
+	if len(expansions) > 0 {
+		contents, err := utils.ExpansionsToGoFile(codegenComparator, collation, expansions, out.codegen)
+		if err != nil {
+			return err
+		}
+		if _, err = out.writeArtifact(collation+"_expansions.go", []byte(contents)); err != nil {
+			return err
+		}
+		log.Printf("found %d expansions", len(expansions))
+	}
+	if cf.equivalenceClasses {
+		if _, err = out.writeArtifact(collation+"_equivalence.go", []byte(utils.EquivalenceClassesToGoFile(codegenComparator, collation, out.codegen))); err != nil {
+			return err
+		}
+	}
+	if cf.caseFolding {
+		caseMappings, err := characterSetCaseMappings(ctx, c, out, charset, rangeMap)
+		if err != nil {
+			return err
+		}
+		folding := utils.CaseFolding(codegenComparator, caseMappings)
+		if _, err = out.writeArtifact(collation+"_fold.go", []byte(utils.CaseFoldingToGoFile(collation, folding, out.codegen))); err != nil {
+			return err
+		}
+		log.Printf("found %d case folds", len(folding))
+	}
+	if cf.accentStripping {
+		caseMappings, err := characterSetCaseMappings(ctx, c, out, charset, rangeMap)
+		if err != nil {
+			return err
+		}
+		sensitive, err := accentSensitiveComparator(out, collation)
+		if err != nil {
+			return err
+		}
+		stripping := utils.AccentStripping(codegenComparator, sensitive, caseMappings)
+		if _, err = out.writeArtifact(collation+"_strip.go", []byte(utils.AccentStrippingToGoFile(collation, stripping, out.codegen))); err != nil {
+			return err
+		}
+		log.Printf("found %d accent strips", len(stripping))
+	}
+	if cf.testSamples > 0 {
+		samples, err := extractor.CollationSamples(ctx, c, collation, charset, runeComparator, cf.testSamples, companionTestSeed)
+		if err != nil {
+			return err
+		}
+		if _, err = out.writeArtifact(collation+"_test.go", []byte(utils.RuneComparatorTestToGoFile(collation, samples, out.codegen))); err != nil {
+			return err
+		}
+	}
+	if _, err = out.writeArtifact(collation+"_metadata.go", []byte(utils.CollationMetadataToGoFile(metadata, out.codegen))); err != nil {
+		return err
+	}
+	if _, err = out.writeArtifact(collation+"_provenance.go", []byte(utils.ProvenanceToGoFile(collation, model.Provenance, out.codegen))); err != nil {
+		return err
+	}
+	start.finish(utils.NewCollationStats(collation, runeComparator))
+	err = out.updateManifest(utils.ManifestEntry{
+		Name:     collation,
+		Kind:     utils.ManifestKindCollation,
+		File:     path,
+		Strategy: profile.Strategy,
+		Base:     profile.Base,
+		Model:    modelPath,
+		Unicode:  pinnedVersion,
+		Metadata: &metadata,
+	})
+	if err != nil {
+		return err
+	}
+	return checkpointer.Remove()
+}
+
+// baseRuneComparator loads the saved rune order of the given collation from the output directory of a previous
+// extraction. The previous weight cache is added to the weight map (without replacing any weights), so that the new
+// runes are compared against the loaded runes using their weights whenever possible.
+func baseRuneComparator(dir string, collation string, runeToWeight map[rune][]byte) (*utils.RuneComparator, error) {
+	runeComparator, err := utils.LoadRuneComparator(filepath.Join(dir, collation+".order.bin"))
+	if err != nil {
+		return nil, err
+	}
+	weights, err := utils.LoadWeightCache(filepath.Join(dir, collation+".weights.txt"))
+	if errors.Is(err, os.ErrNotExist) {
+		return runeComparator, nil
+	} else if err != nil {
+		return nil, err
+	}
+	for r, weight := range weights {
+		if _, ok := runeToWeight[r]; !ok {
+			runeToWeight[r] = weight
+		}
+	}
+	return runeComparator, nil
+}
+
+// tailoringBaseRuneComparator loads the saved rune order of the base collation of the given delta profile from the output
+// directory. Returns nil when the profile has no base, or the base has not been extracted. The base's weight cache is not loaded, as the weights of
+// the base differ from the weights of the tailoring.
+func tailoringBaseRuneComparator(out outputFlags, profile utils.ExtractionProfile) (*utils.RuneComparator, error) {
+	if profile.Base == "" {
+		return nil, nil
+	}
+	base, err := utils.LoadRuneComparator(out.path(profile.Base + ".order.bin"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return base, err
+}
+
+// characterSetCaseMappings returns the case mappings of the given character set. The mappings are read from the
+// character set's model within the output directory when one exists, as extracting them queries every rune.
+func characterSetCaseMappings(ctx context.Context, c *utils.Connection, out outputFlags, charset string, rangeMap *utils.RangeMap) (utils.CaseMappings, error) {
+	model, err := utils.LoadModel(out.path(charset + ".model.json"))
+	if err == nil {
+		return model.CaseMappings(), nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return utils.CaseMappings{}, err
+	}
+	log.Printf("`%s` has not been extracted, so its case mappings are extracted", charset)
+	return extractor.CharacterSetCaseMappings(ctx, c, charset, rangeMap, utils.NewUTF8Iter(), nil)
+}
+
+// accentSensitiveComparator returns the RuneComparator of the accent-sensitive counterpart of the given collation, which
+// is read from the counterpart's model within the output directory. Returns nil when the collation has no counterpart or
+// the counterpart has not been extracted, in which case the bases are chosen by codepoint.
+func accentSensitiveComparator(out outputFlags, collation string) (*utils.RuneComparator, error) {
+	sensitive := utils.AccentSensitiveCollation(collation)
+	if sensitive == "" {
+		return nil, nil
+	}
+	model, err := utils.LoadModel(out.path(sensitive + ".model.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		log.Printf("`%s` has not been extracted, so each base rune is chosen by its codepoint", sensitive)
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return model.RuneComparator()
+}
+
+// tuneCollationCutoffs sets the range cutoffs of the given options to those chosen by utils.TuneCutoffs for the given
+// RuneComparator.
+func tuneCollationCutoffs(options *utils.RuneComparatorGoFileOptions, rc *utils.RuneComparator) {
+	best, _ := utils.TuneCutoffs(rc, nil)
+	options.DynamicRangeCutoff = best.DynamicRangeCutoff
+	options.StaticRangeCutoff = best.StaticRangeCutoff
+	log.Printf("tuned the range cutoffs to %s", best)
+}
+
+// writeCollationFile writes the Go file of the given collation using the named codegen, along with any table that the
+// file embeds. The options only apply to the map codegen, and take the codegen options of the output. Returns the path
+// of the Go file.
+func writeCollationFile(out outputFlags, codegenName string, options utils.RuneComparatorGoFileOptions, rc *utils.RuneComparator, collation string, padSpace bool) (string, error) {
+	codegen, err := utils.ParseCollationCodegen(codegenName)
+	if err != nil {
+		return "", err
+	}
+	options.Codegen = out.codegen
+	switch codegen {
+	case utils.CollationCodegenEmbed:
+		contents, table, err := utils.RuneComparatorToEmbeddedGoFile(rc, collation, padSpace, out.codegen)
+		if err != nil {
+			return "", err
+		}
+		if _, err = out.writeArtifact(utils.RuneComparatorEmbeddedTableName(collation), table); err != nil {
+			return "", err
+		}
+		return out.writeArtifact(collation+".go", []byte(contents))
+	case utils.CollationCodegenSortedSlice:
+		return out.writeArtifact(collation+".go", []byte(utils.RuneComparatorToSortedSliceGoFile(rc, collation, padSpace, out.codegen)))
+	default:
+		return out.writeArtifact(collation+".go", []byte(utils.RuneComparatorToGoFileWithOptions(rc, collation, padSpace, options)))
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dolthub/collation-extractor/extractor"
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+// extractAll implements `extract all`, which extracts every character set and collation that the server offers. Any
+// that are already within the output directory's manifest are skipped, so an interrupted run may be restarted with the
+// same flags. Character sets (along with their collations) are extracted in parallel when -jobs is greater than 1.
+func extractAll(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("extract all", flag.ContinueOnError)
+	var conn connectionFlags
+	var out outputFlags
+	var cf collationFlags
+	conn.register(fs)
+	out.register(fs)
+	cf.register(fs)
+	jobs := fs.Int("jobs", 1, "the number of character sets that are extracted in parallel, each using -workers connections")
+	charsetList := fs.String("charsets", "", "a comma-separated list of the character sets to extract (every character set when empty)")
+	failFast := fs.Bool("fail-fast", false, "stops at the first failed extraction, rather than extracting the rest and reporting every failure")
+	var options extractor.CharacterSetOptions
+	registerCharacterSetOptions(fs, &options)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	if *jobs < 1 {
+		return fmt.Errorf("-jobs must be at least 1")
+	}
+	if cf.base != "" {
+		return fmt.Errorf("-base is specific to a single collation, and cannot be used with `extract all`")
+	}
+
+	ctx, cancel := conn.context(ctx)
+	defer cancel()
+	pool, err := conn.connect(ctx, *jobs*cf.workers)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	charsets, err := extractor.CharacterSets(ctx, pool.Connection(0))
+	if err != nil {
+		return err
+	}
+	if *charsetList != "" {
+		charsets = strings.Split(*charsetList, ",")
+	}
+	manifest, err := utils.LoadManifest(out.path(out.manifest))
+	if err != nil {
+		return err
+	}
+	log.Printf("extracting %d character sets from `%s`", len(charsets), pool.Connection(0).Version())
+
+	// Each job takes the next character set, so that a job is never idle while character sets remain
+	charsetQueue := make(chan string, len(charsets))
+	for _, charset := range charsets {
+		charsetQueue <- charset
+	}
+	close(charsetQueue)
+	var failures []string
+	var failuresMu sync.Mutex
+	wg := sync.WaitGroup{}
+	for _, jobPool := range pool.Split(cf.workers) {
+		wg.Add(1)
+		go func(jobPool *utils.ConnectionPool) {
+			defer wg.Done()
+			for charset := range charsetQueue {
+				if ctx.Err() != nil {
+					return
+				}
+				charsetFailures := extractCharsetAndCollations(ctx, jobPool, out, cf, options, manifest, charset)
+				if len(charsetFailures) == 0 {
+					continue
+				}
+				failuresMu.Lock()
+				failures = append(failures, charsetFailures...)
+				failuresMu.Unlock()
+				if *failFast {
+					cancel()
+				}
+			}
+		}(jobPool)
+	}
+	wg.Wait()
+	// The statistics of the extractions that succeeded are still written when others have failed
+	if err = out.writeStats(pool); err != nil {
+		return err
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%d extractions failed:\n%s", len(failures), strings.Join(failures, "\n"))
+	}
+	return ctx.Err()
+}
+
+// extractCharsetAndCollations extracts the given character set followed by each of its collations, skipping those that
+// are within the manifest. Failures are logged and returned rather than stopping the remaining collations, as a single
+// collation may fail (such as from a server quirk) without affecting the others.
+func extractCharsetAndCollations(ctx context.Context, pool *utils.ConnectionPool, out outputFlags, cf collationFlags, options extractor.CharacterSetOptions, manifest *utils.Manifest, charset string) (failures []string) {
+	fail := func(name string, err error) {
+		log.Printf("`%s` failed: %v", name, err)
+		failures = append(failures, fmt.Sprintf("`%s`: %v", name, err))
+	}
+	rangeMap, err := existingRangeMap(manifest, charset)
+	if err != nil {
+		fail(charset, err)
+		return failures
+	}
+	if rangeMap == nil {
+		checkpointer, err := out.checkpointer(charset)
+		if err != nil {
+			fail(charset, err)
+			return failures
+		}
+		if rangeMap, err = writeCharset(ctx, pool, out, charset, options, cf.testSamples, checkpointer); err != nil {
+			fail(charset, err)
+			return failures
+		}
+	} else {
+		log.Printf("skipping `%s`, which is already in the manifest", charset)
+	}
+	collations, err := extractor.CharacterSetCollations(ctx, pool.Connection(0), charset)
+	if err != nil {
+		fail(charset, err)
+		return failures
+	}
+	// Tailorings are extracted as a delta from their base, so the bases are extracted first
+	sort.SliceStable(collations, func(i, j int) bool {
+		return utils.SelectExtractionProfile(collations[i]).Strategy != utils.ExtractionStrategyDelta &&
+			utils.SelectExtractionProfile(collations[j]).Strategy == utils.ExtractionStrategyDelta
+	})
+	for _, collation := range collations {
+		if ctx.Err() != nil {
+			break
+		}
+		if _, ok := manifest.Get(collation, utils.ManifestKindCollation); ok {
+			log.Printf("skipping `%s`, which is already in the manifest", collation)
+			continue
+		}
+		// Each collation is extracted independently, so their weights are not shared
+		if err = writeCollation(ctx, pool, out, cf, collation, charset, rangeMap, make(map[rune][]byte)); err != nil {
+			fail(collation, err)
+		}
+	}
+	return failures
+}
+
+// existingRangeMap returns the RangeMap of the given character set from the Model that its manifest entry references.
+// Returns nil if the character set is not within the manifest.
+func existingRangeMap(manifest *utils.Manifest, charset string) (*utils.RangeMap, error) {
+	entry, ok := manifest.Get(charset, utils.ManifestKindCharset)
+	if !ok {
+		return nil, nil
+	}
+	if entry.Model == "" {
+		return nil, fmt.Errorf("the manifest entry of `%s` does not reference a model", charset)
+	}
+	model, err := utils.LoadModel(entry.Model)
+	if err != nil {
+		return nil, err
+	}
+	return model.RangeMap()
+}
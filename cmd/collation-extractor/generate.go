@@ -0,0 +1,123 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+// generate implements `generate`, which creates the Go file of a model that was previously saved by an extraction. This
+// does not connect to a server, so changes to code generation may be applied to an existing extraction in seconds. This
+// is equivalent to TestGenerate.
+func generate(args []string) error {
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	var out outputFlags
+	out.register(fs)
+	consolidation := fs.String("consolidation", "", fmt.Sprintf("the strategy that merges the ranges of a character set, one of %v (the character set's default when empty)", utils.ConsolidationStrategies()))
+	codegen := fs.String("codegen", "", fmt.Sprintf("the form of the generated weights of a collation, one of %v (map when empty)", utils.CollationCodegens()))
+	mapChunkSize := fs.Int("map-chunk-size", 0, "the maximum number of entries within each literal of a collation's weight map (a single literal when zero)")
+	dynamicRangeCutoff := fs.Int("dynamic-range-cutoff", 0, "the minimum span of sequential weights that a collation's weight function computes rather than listing within the weight map (100 when zero)")
+	staticRangeCutoff := fs.Int("static-range-cutoff", 0, "the minimum span of runes sharing a weight that a collation's weight function compares rather than listing within the weight map (100 when zero)")
+	autoTuneCutoffs := fs.Bool("auto-tune-cutoffs", false, "picks the range cutoffs of a collation with the smallest weight map and range comparisons, overriding -dynamic-range-cutoff and -static-range-cutoff")
+	equivalenceClasses := fs.Bool("equivalence-classes", false, "also writes the groups of runes that share a weight of a collation")
+	modelPath, err := parseName(fs, args, "model")
+	if err != nil {
+		return err
+	}
+
+	model, err := utils.LoadModel(modelPath)
+	if err != nil {
+		return err
+	}
+	if err = out.codegen.CheckName(model.Name); err != nil {
+		return err
+	}
+	if err = utils.RegisteredExtractionHooks().BeforeCodegen(model); err != nil {
+		return err
+	}
+	// Models saved before the provenance was recorded generate files without it
+	out.codegen.Provenance = model.Provenance
+	var path string
+	var contents string
+	if model.Kind == utils.ManifestKindCollation &&
+		(*codegen != "" || *mapChunkSize > 0 || *dynamicRangeCutoff > 0 || *staticRangeCutoff > 0 || *autoTuneCutoffs) {
+		rc, err := model.RuneComparator()
+		if err != nil {
+			return err
+		}
+		options := utils.RuneComparatorGoFileOptions{
+			MapChunkSize:       *mapChunkSize,
+			DynamicRangeCutoff: *dynamicRangeCutoff,
+			StaticRangeCutoff:  *staticRangeCutoff,
+			Ignorables:         model.Ignorables,
+		}
+		if *autoTuneCutoffs {
+			tuneCollationCutoffs(&options, rc)
+		}
+		if path, err = writeCollationFile(out, *codegen, options, rc, model.Name, model.PadSpace); err != nil {
+			return err
+		}
+	} else if model.Kind == utils.ManifestKindCharset {
+		// An empty strategy uses the character set's default
+		var strategy utils.ConsolidationStrategy
+		if *consolidation != "" {
+			if strategy, err = utils.ParseConsolidationStrategy(*consolidation); err != nil {
+				return err
+			}
+		}
+		rangeMap, err := model.RangeMapWithConsolidation(strategy)
+		if err != nil {
+			return err
+		}
+		contents = utils.RangeMapToGoFileWithOptions(rangeMap, model.CaseMappings(), model.Name, out.codegen)
+	} else if contents, err = model.GoFileWithOptions(out.codegen); err != nil {
+		return err
+	}
+	if path == "" {
+		if path, err = out.writeArtifact(model.Name+".go", []byte(contents)); err != nil {
+			return err
+		}
+	}
+	if model.Kind == utils.ManifestKindCharset {
+		if _, err = out.writeArtifact(model.Name+"_text_encoding.go", []byte(utils.TextEncodingToGoFile(model.Name, out.codegen))); err != nil {
+			return err
+		}
+	}
+	if model.Kind == utils.ManifestKindCollation {
+		rc, err := model.RuneComparator()
+		if err != nil {
+			return err
+		}
+		if _, err = out.writeArtifact(model.Name+"_reverse.go", []byte(utils.RuneComparatorReverseToGoFile(rc, model.Name, out.codegen))); err != nil {
+			return err
+		}
+		if *equivalenceClasses {
+			if _, err = out.writeArtifact(model.Name+"_equivalence.go", []byte(utils.EquivalenceClassesToGoFile(rc, model.Name, out.codegen))); err != nil {
+				return err
+			}
+		}
+	}
+	if model.Provenance != nil {
+		if _, err = out.writeArtifact(model.Name+"_provenance.go", []byte(utils.ProvenanceToGoFile(model.Name, model.Provenance, out.codegen))); err != nil {
+			return err
+		}
+	}
+	log.Printf("generated `%s` (%s) from `%s`", path, model.Kind, modelPath)
+	return nil
+}
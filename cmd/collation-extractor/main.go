@@ -0,0 +1,419 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+const usage = `collation-extractor extracts character sets and collations from a MySQL server for go-mysql-server.
+
+Usage:
+  collation-extractor extract charset <name> [flags]
+  collation-extractor extract collation <name> [flags]
+  collation-extractor extract collations -charset <name> [flags]
+  collation-extractor extract all [flags]
+  collation-extractor run -config <file> [flags]
+  collation-extractor validate <charset> [flags]
+  collation-extractor validate collation <name> [flags]
+  collation-extractor verify <file> [flags]
+  collation-extractor generate <model> [flags]
+  collation-extractor compare charset <name> -target-port <port> [flags]
+  collation-extractor compare collation <name> -target-port <port> [flags]
+  collation-extractor compare versions charset|collation <name> -images <image,image,...> [flags]
+
+Run a command with -h to see its flags.
+`
+
+func main() {
+	// An interrupt cancels the extraction at its next query, so that the connections are closed cleanly
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := run(ctx, os.Args[1:]); err != nil {
+		// The flag package has already printed the usage
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		fmt.Fprintln(os.Stderr, err)
+		stop()
+		os.Exit(1)
+	}
+}
+
+// run executes the command represented by the given arguments.
+func run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("a command is required")
+	}
+	switch args[0] {
+	case "extract":
+		if len(args) < 2 {
+			return fmt.Errorf("extract requires one of `charset`, `collation`, `collations`, or `all`")
+		}
+		switch args[1] {
+		case "charset":
+			return extractCharset(ctx, args[2:])
+		case "collation":
+			return extractCollation(ctx, args[2:])
+		case "collations":
+			return extractCollations(ctx, args[2:])
+		case "all":
+			return extractAll(ctx, args[2:])
+		default:
+			return fmt.Errorf("unknown extraction `%s`, expected one of `charset`, `collation`, `collations`, or `all`", args[1])
+		}
+	case "run":
+		return runConfigFile(ctx, args[1:])
+	case "validate":
+		if len(args) > 1 && args[1] == utils.ManifestKindCollation {
+			return validateCollation(ctx, args[2:])
+		}
+		return validate(ctx, args[1:])
+	case "verify":
+		return verify(ctx, args[1:])
+	case "generate":
+		return generate(args[1:])
+	case "compare":
+		if len(args) < 2 {
+			return fmt.Errorf("compare requires one of `charset`, `collation`, or `versions`")
+		}
+		switch args[1] {
+		case utils.ManifestKindCharset, utils.ManifestKindCollation:
+			return compare(ctx, args[1], args[2:])
+		case "versions":
+			if len(args) < 3 || (args[2] != utils.ManifestKindCharset && args[2] != utils.ManifestKindCollation) {
+				return fmt.Errorf("compare versions requires one of `charset` or `collation`")
+			}
+			return compareVersions(ctx, args[2], args[3:])
+		default:
+			return fmt.Errorf("unknown comparison `%s`, expected one of `charset`, `collation`, or `versions`", args[1])
+		}
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(os.Stdout, usage)
+		return nil
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown command `%s`", args[0])
+	}
+}
+
+// connectionFlags are the flags for connecting to a server, which are shared by every command.
+type connectionFlags struct {
+	user     string
+	password string
+	host     string
+	port     int
+	socket   string
+	// tls only holds the TLS settings, as connect fills in the rest of the options from the other flags
+	tls utils.ConnectionOptions
+	dsn string
+	// docker is the image of a server that is started for the command, in place of connecting to an existing server
+	docker string
+	// record is the file that every query and response is recorded to, while replay is a recording that answers every
+	// query in place of a server
+	record string
+	replay string
+	// cacheDir, noCache, and clearCache control the QueryCache of the connection
+	cacheDir   string
+	noCache    bool
+	clearCache bool
+	// timeout limits the duration of the entire command, while queryTimeout limits each query
+	timeout      time.Duration
+	queryTimeout time.Duration
+	// retries and retryBackoff control how a query recovers from a dropped connection or other transient error
+	retries      int
+	retryBackoff time.Duration
+	// prepare sends the hot extraction queries as prepared statements
+	prepare bool
+}
+
+// register adds the connection flags to the given flag set.
+func (c *connectionFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.user, "user", "root", "the user to connect with")
+	fs.StringVar(&c.password, "password", "", "the password to connect with")
+	fs.StringVar(&c.host, "host", "localhost", "the host of the server")
+	fs.IntVar(&c.port, "port", 3306, "the port of the server")
+	fs.StringVar(&c.socket, "socket", "", "the unix socket of the server, which replaces the host and port")
+	fs.BoolVar(&c.tls.TLS, "tls", false, "encrypts the connection, verifying the server against the system's certificate authorities (implied by the other -tls flags)")
+	fs.StringVar(&c.tls.TLSCA, "tls-ca", "", "a PEM file of the certificate authorities that the server is verified against")
+	fs.StringVar(&c.tls.TLSCert, "tls-cert", "", "a PEM file of the client certificate, for servers that require one")
+	fs.StringVar(&c.tls.TLSKey, "tls-key", "", "a PEM file of the client certificate's key")
+	fs.BoolVar(&c.tls.TLSSkipVerify, "tls-skip-verify", false, "accepts any certificate from the server")
+	fs.StringVar(&c.tls.TLSServerName, "tls-server-name", "", "the name that the server's certificate is verified against (the host when empty)")
+	fs.StringVar(&c.dsn, "dsn", "", "a data source name such as user:password@tcp(host:3306)/?tls=true, which replaces the other connection flags")
+	fs.StringVar(&c.record, "record", "", "a file that every query and its response are recorded to, for rerunning the command offline with -replay")
+	fs.StringVar(&c.replay, "replay", "", "a file from -record that answers every query in place of a server, which replaces the other connection flags")
+	fs.StringVar(&c.docker, "docker", "", "an image such as mysql:8.0.31 or mariadb:10.11, which is started in Docker for the command and removed afterward")
+	fs.StringVar(&c.cacheDir, "cache", ".query-cache", "the directory that caches query results for each server version")
+	fs.BoolVar(&c.noCache, "no-cache", false, "queries the server for every result, without reading or writing the cache")
+	fs.BoolVar(&c.clearCache, "clear-cache", false, "removes the cached results of the server's version before connecting")
+	fs.DurationVar(&c.timeout, "timeout", 0, "cancels the command once it has run for this long (no limit when zero)")
+	fs.DurationVar(&c.queryTimeout, "query-timeout", 5*time.Minute, "fails any query that runs for this long (no limit when zero)")
+	fs.IntVar(&c.retries, "retries", utils.DefaultRetryPolicy().MaxConsecutiveFailures, "the number of consecutive transient failures that are retried on a new connection (never retried when zero)")
+	fs.DurationVar(&c.retryBackoff, "retry-backoff", utils.DefaultRetryPolicy().InitialBackoff, "the wait before the first retry, which doubles after each consecutive failure")
+	fs.BoolVar(&c.prepare, "prepare", true, "sends repeated queries as prepared statements, so that the server only parses each shape of query once")
+}
+
+// registerTarget adds the flags of a second server to the given flag set, which are the connection flags prefixed with
+// "target-". The remaining flags are shared with the first server, other than the cache, which is never used for the
+// second server, as a server such as Dolt may report the same version as the MySQL server that it emulates.
+func (c *connectionFlags) registerTarget(fs *flag.FlagSet) {
+	fs.StringVar(&c.user, "target-user", "root", "the user to connect to the target server with")
+	fs.StringVar(&c.password, "target-password", "", "the password to connect to the target server with")
+	fs.StringVar(&c.host, "target-host", "localhost", "the host of the target server")
+	fs.IntVar(&c.port, "target-port", 3307, "the port of the target server")
+	fs.StringVar(&c.dsn, "target-dsn", "", "a data source name for the target server, which replaces the other target flags")
+	fs.StringVar(&c.record, "target-record", "", "a file that every query of the target server is recorded to")
+	fs.StringVar(&c.replay, "target-replay", "", "a file from -target-record that answers every query in place of the target server")
+	fs.StringVar(&c.docker, "target-docker", "", "an image that is started in Docker as the target server, such as mariadb:10.11")
+	c.noCache = true
+}
+
+// context returns the given context with the command's timeout applied.
+func (c *connectionFlags) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.timeout > 0 {
+		return context.WithTimeout(ctx, c.timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// connect returns a new pool of the given number of connections using the flags. The first connection is used for all
+// work that is not parallelized. When a Docker image is given, its server is started and then removed once the pool is
+// closed. When a recording is replayed, no server is connected to at all.
+func (c *connectionFlags) connect(ctx context.Context, workers int) (*utils.ConnectionPool, error) {
+	if c.replay != "" {
+		pool, err := utils.NewReplayConnectionPool(c.replay, workers)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("replaying the queries of `%s` from `%s`", pool.Connection(0).Version(), c.replay)
+		return pool, nil
+	}
+	options := c.tls
+	options.User, options.Password, options.Host, options.Port = c.user, c.password, c.host, c.port
+	options.Socket, options.DSN = c.socket, c.dsn
+	var server *utils.DockerServer
+	if c.docker != "" {
+		log.Printf("starting `%s` in Docker", c.docker)
+		var err error
+		server, err = utils.StartDockerServer(ctx, utils.DockerServerOptions{Image: c.docker, Password: c.password, Logf: log.Printf})
+		if err != nil {
+			return nil, err
+		}
+		options = server.Options()
+		log.Printf("started `%s` as container %.12s on port %d", c.docker, server.ContainerID(), options.Port)
+	}
+	pool, err := utils.NewConnectionPoolWithOptions(options, workers)
+	if err != nil {
+		if server != nil {
+			server.Close()
+		}
+		return nil, err
+	}
+	if server != nil {
+		pool.AddCloser(server)
+	}
+	pool.SetQueryTimeout(c.queryTimeout)
+	policy := utils.DefaultRetryPolicy()
+	policy.MaxConsecutiveFailures, policy.InitialBackoff, policy.Logf = c.retries, c.retryBackoff, log.Printf
+	pool.SetRetryPolicy(policy)
+	pool.SetPreparedStatements(c.prepare)
+	if c.record != "" {
+		recording, err := pool.RecordQueries(c.record)
+		if err != nil {
+			pool.Close()
+			return nil, err
+		}
+		log.Printf("recording queries to `%s`, which already holds %d queries", c.record, recording.Len())
+	}
+	if c.noCache || c.cacheDir == "" {
+		return pool, nil
+	}
+	cache, err := pool.EnableQueryCache(c.cacheDir)
+	if err != nil {
+		pool.Close()
+		return nil, err
+	}
+	if c.clearCache {
+		if err = cache.Clear(); err != nil {
+			pool.Close()
+			return nil, err
+		}
+	}
+	log.Printf("using %d cached results for `%s`", cache.Len(), pool.Connection(0).Version())
+	return pool, nil
+}
+
+// manifestMu and indexMu serialize the updates of the manifest and the index, as `extract all` writes from multiple
+// goroutines. manifestMu is always acquired before indexMu, as updating the manifest also writes the registration.
+var manifestMu, indexMu sync.Mutex
+
+// outputFlags are the flags that control where and how generated files are written.
+type outputFlags struct {
+	dir          string
+	manifest     string
+	registration string
+	index        string
+	stats        string
+	gzip         bool
+	txtSuffix    bool
+	gmsRoot      string
+	// codegen is given to the generator of every Go file
+	codegen utils.CodegenOptions
+	// checkpointInterval and resume control the checkpoints that are saved within the output directory
+	checkpointInterval time.Duration
+	resume             bool
+}
+
+// register adds the output flags to the given flag set.
+func (o *outputFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.dir, "out", ".", "the directory that generated files are written to")
+	fs.StringVar(&o.manifest, "manifest", "manifest.json", "the manifest recording every extraction, relative to the output directory")
+	fs.StringVar(&o.registration, "registration", "registration.go", "the file registering every entry of the manifest by name, relative to the output directory (empty to disable)")
+	fs.StringVar(&o.index, "index", "artifacts.txt", "the index of every generated file, relative to the output directory (empty to disable)")
+	fs.StringVar(&o.stats, "stats", "stats.json", "the report of every extraction's statistics, relative to the output directory (empty to only log the report)")
+	fs.BoolVar(&o.gzip, "gzip", false, "compresses every generated file")
+	fs.BoolVar(&o.txtSuffix, "txt-suffix", true, "prevents generated Go files from being compiled when placed within a package")
+	fs.StringVar(&o.gmsRoot, "gms-root", "", "a go-mysql-server checkout whose encodings package receives the generated Go files directly, ignoring -gzip and -txt-suffix for them")
+	fs.StringVar(&o.codegen.PackageName, "package", "encodings", "the package of generated Go files")
+	fs.StringVar(&o.codegen.BuildTags, "build-tags", "", "the build constraint of generated Go files, such as !tinygo (empty to omit)")
+	fs.StringVar(&o.codegen.EncoderType, "encoder-type", "Encoder", "the type that a generated character set is declared as")
+	fs.BoolVar(&o.codegen.ExtendedRangeMap, "extended-range-map", false, "also writes the fields of a character set's RangeMap that GMS's RangeMap does not declare")
+	fs.IntVar(&o.codegen.Year, "year", 0, "the copyright year of the license header of generated Go files (the year of the extraction when zero)")
+	fs.Func("header-file", "a file whose text replaces the license header of generated Go files, without comment markers", func(path string) error {
+		contents, err := os.ReadFile(path)
+		o.codegen.Header = string(contents)
+		return err
+	})
+	fs.DurationVar(&o.checkpointInterval, "checkpoint-interval", 5*time.Minute, "how often progress is saved, so that an interrupted extraction may be resumed (zero to disable)")
+	fs.BoolVar(&o.resume, "resume", false, "resumes an interrupted extraction from its checkpoint")
+}
+
+// checkpointer returns the Checkpointer for the given extraction, creating the output directory if it does not exist.
+// The checkpoint should be removed once the extraction's files have been written.
+func (o *outputFlags) checkpointer(name string) (*utils.Checkpointer, error) {
+	if err := os.MkdirAll(o.dir, 0755); err != nil {
+		return nil, err
+	}
+	return utils.NewCheckpointer(o.path(name+".checkpoint.bin"), o.checkpointInterval, o.resume)
+}
+
+// path returns the path of the given file within the output directory.
+func (o *outputFlags) path(name string) string {
+	return filepath.Join(o.dir, name)
+}
+
+// writeArtifact writes a generated file to the output directory and adds it to the index. Returns the written path,
+// which may have additional suffixes.
+func (o *outputFlags) writeArtifact(name string, contents []byte) (string, error) {
+	if err := os.MkdirAll(o.dir, 0755); err != nil {
+		return "", err
+	}
+	artifact, err := utils.WriteArtifact(o.path(name), contents, utils.ArtifactOptions{
+		Gzip:      o.gzip,
+		TxtSuffix: o.txtSuffix,
+		GMSRoot:   o.gmsRoot,
+	})
+	if err != nil {
+		return "", err
+	}
+	if o.index != "" {
+		indexMu.Lock()
+		defer indexMu.Unlock()
+		if err = utils.UpdateArtifactIndex(o.path(o.index), artifact); err != nil {
+			return "", err
+		}
+	}
+	return artifact.Path, nil
+}
+
+// updateManifest adds the given entry to the manifest within the output directory, and regenerates the registration
+// file so that it covers every entry.
+func (o *outputFlags) updateManifest(entry utils.ManifestEntry) error {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+	manifest, err := utils.LoadManifest(o.path(o.manifest))
+	if err != nil {
+		return err
+	}
+	if err = manifest.CheckFlavor(entry); err != nil {
+		return err
+	}
+	manifest.Set(entry)
+	if err = manifest.Save(o.path(o.manifest)); err != nil {
+		return err
+	}
+	if o.registration == "" {
+		return nil
+	}
+	// The registration covers every entry, so it does not take the provenance of the entry that was just extracted.
+	// It keeps the year of the extraction though, so that its header does not change whenever the year does.
+	registrationOut := *o
+	if registrationOut.codegen.Year == 0 && o.codegen.Provenance != nil && !o.codegen.Provenance.ExtractedAt.IsZero() {
+		registrationOut.codegen.Year = o.codegen.Provenance.ExtractedAt.Year()
+	}
+	registrationOut.codegen.Provenance = nil
+	_, err = registrationOut.writeArtifact(o.registration, []byte(utils.RegistrationToGoFile(manifest, registrationOut.codegen)))
+	return err
+}
+
+// checkManifestFlavor returns an error when the manifest within the output directory contains the given collation from
+// a different server flavor.
+func (o *outputFlags) checkManifestFlavor(collation string, flavor utils.ServerFlavor) error {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+	manifest, err := utils.LoadManifest(o.path(o.manifest))
+	if err != nil {
+		return err
+	}
+	return manifest.CheckFlavor(utils.ManifestEntry{
+		Name:     collation,
+		Kind:     utils.ManifestKindCollation,
+		Metadata: &utils.CollationMetadata{Name: collation, Flavor: flavor},
+	})
+}
+
+// parseName parses the flags of a command that takes a single name as its argument. The name may appear before or
+// after the flags.
+func parseName(fs *flag.FlagSet, args []string, kind string) (string, error) {
+	var name string
+	if len(args) > 0 && len(args[0]) > 0 && args[0][0] != '-' {
+		name, args = args[0], args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return "", err
+	}
+	if name == "" && fs.NArg() == 1 {
+		name = fs.Arg(0)
+	} else if fs.NArg() > 0 {
+		return "", fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	if name == "" {
+		return "", fmt.Errorf("the %s name is required", kind)
+	}
+	return name, nil
+}
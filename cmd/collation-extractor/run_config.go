@@ -0,0 +1,77 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+// runConfigFile implements `run`, which runs every extraction within a config file in order. Each extraction runs the
+// same command (with the same flags) as it would from the command line, so a config only describes what to run.
+func runConfigFile(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	configPath := fs.String("config", "", "the YAML file listing the connection, flags, and extractions of the run")
+	dryRun := fs.Bool("dry-run", false, "prints the command of each extraction without running it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *configPath == "" {
+		return fmt.Errorf("-config is required")
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	config, err := utils.LoadRunConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	// A Docker server is started once for the whole run, rather than once for each extraction
+	if image := config.Connection["docker"]; image != "" && !*dryRun {
+		log.Printf("starting `%s` in Docker", image)
+		server, err := utils.StartDockerServer(ctx, utils.DockerServerOptions{Image: image, Password: config.Connection["password"], Logf: log.Printf})
+		if err != nil {
+			return err
+		}
+		defer server.Close()
+		options := server.Options()
+		log.Printf("started `%s` as container %.12s on port %d", image, server.ContainerID(), options.Port)
+		delete(config.Connection, "docker")
+		config.Connection["user"], config.Connection["password"] = options.User, options.Password
+		config.Connection["host"], config.Connection["port"] = options.Host, strconv.Itoa(options.Port)
+	}
+	commands, err := config.Commands(filepath.Dir(*configPath))
+	if err != nil {
+		return fmt.Errorf("`%s`: %w", *configPath, err)
+	}
+	for i, command := range commands {
+		if *dryRun {
+			fmt.Println("collation-extractor " + strings.Join(command, " "))
+			continue
+		}
+		log.Printf("running extraction %d of %d: %s", i+1, len(commands), strings.Join(command, " "))
+		if err = run(ctx, command); err != nil {
+			return fmt.Errorf("extraction %d of `%s`: %w", i+1, *configPath, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+// runStats collects the statistics of every extraction within the run, which are written once the run has finished.
+var runStats = &statsCollector{start: time.Now(), strcmp: make(map[string]int)}
+
+func init() {
+	utils.RegisterExtractionHooks(utils.ExtractionHooks{CollationStrcmp: runStats.countStrcmp})
+}
+
+// statsCollector gathers the ExtractionStats of each extraction. `extract all` extracts from multiple goroutines, so
+// every method may be called concurrently.
+type statsCollector struct {
+	mu     sync.Mutex
+	start  time.Time
+	report utils.ExtractionReport
+	// strcmp counts the STRCMP fallbacks of each collation, as reported by the CollationStrcmp hook
+	strcmp map[string]int
+	// queries counts the queries of every pool whose statistics were written, as `run` uses a pool for each command
+	queries int
+}
+
+// countStrcmp implements the CollationStrcmp hook.
+func (c *statsCollector) countStrcmp(_ utils.Queryable, collation string, _ rune, _ rune) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.strcmp[collation]++
+	return nil
+}
+
+// extractionStart is the state of a pool before an extraction, so that the queries and duration of the extraction
+// itself are known once it has finished. The pool must not be shared with another extraction that runs concurrently.
+type extractionStart struct {
+	pool    *utils.ConnectionPool
+	queries int
+	time    time.Time
+}
+
+// startExtraction returns the current state of the given pool.
+func startExtraction(pool *utils.ConnectionPool) extractionStart {
+	return extractionStart{pool: pool, queries: pool.QueryCount(), time: time.Now()}
+}
+
+// finish adds the given statistics to the run's report, along with the queries and duration since the start.
+func (s extractionStart) finish(stats utils.ExtractionStats) {
+	stats.Queries = s.pool.QueryCount() - s.queries
+	stats.SetDuration(time.Since(s.time))
+	runStats.mu.Lock()
+	defer runStats.mu.Unlock()
+	if stats.Kind == utils.ManifestKindCollation {
+		stats.StrcmpFallbacks = runStats.strcmp[stats.Name]
+	}
+	runStats.report.Add(stats)
+}
+
+// writeStats logs the statistics of every extraction within the run, and writes them to the output directory.
+func (o *outputFlags) writeStats(pool *utils.ConnectionPool) error {
+	runStats.mu.Lock()
+	runStats.queries += pool.QueryCount()
+	report := runStats.report
+	report.Queries = runStats.queries
+	runStats.mu.Unlock()
+	report.Version = pool.Connection(0).Version()
+	report.Seconds = time.Since(runStats.start).Seconds()
+	log.Printf("extraction statistics:\n%s", report.String())
+	if o.stats == "" {
+		return nil
+	}
+	if err := os.MkdirAll(o.dir, 0755); err != nil {
+		return err
+	}
+	return report.Save(o.path(o.stats))
+}
@@ -0,0 +1,158 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/dolthub/collation-extractor/extractor"
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+// validate implements `validate`, which converts random strings using a previously generated character set file and
+// compares the conversions against the server. This is equivalent to TestValidateRoundTrip. The file is also checked
+// for overlapping entries and codepoints that do not round trip before connecting.
+func validate(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	var conn connectionFlags
+	conn.register(fs)
+	file := fs.String("file", "", "the generated character set file (defaults to <charset>.go.txt)")
+	samples := fs.Int("samples", 10000, "the number of random strings to validate")
+	seed := fs.Int64("seed", 0, "the seed of the random strings")
+	reverseLength := fs.Int("reverse-length", 0, "also decodes every byte sequence of up to this length, reporting sequences that the file decodes differently (disabled when zero)")
+	charset, err := parseName(fs, args, "character set")
+	if err != nil {
+		return err
+	}
+	if *file == "" {
+		*file = charset + ".go.txt"
+	}
+
+	contents, err := utils.ReadArtifact(*file)
+	if err != nil {
+		return err
+	}
+	rangeMap, _, err := utils.ParseRangeMapGoFile(string(contents))
+	if err != nil {
+		return err
+	}
+	// The file is checked on its own before connecting, as it may have been edited since it was generated
+	report := rangeMap.Report()
+	log.Printf("%s: %s", *file, report.String())
+	for _, overlap := range report.Overlaps {
+		log.Print(overlap.String())
+	}
+	for _, failure := range report.RoundTripFailures {
+		log.Print(failure.String())
+	}
+	if err = report.Err(); err != nil {
+		return err
+	}
+	ctx, cancel := conn.context(ctx)
+	defer cancel()
+	pool, err := conn.connect(ctx, 1)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	c := pool.Connection(0)
+	mismatches, err := extractor.ValidateRoundTrip(ctx, c, charset, rangeMap, *samples, *seed)
+	if err != nil {
+		return err
+	}
+	for _, mismatch := range mismatches {
+		log.Print(mismatch.String())
+	}
+	if len(mismatches) > 0 {
+		return fmt.Errorf("%d of %d strings do not match the server", len(mismatches), *samples)
+	}
+	log.Printf("all %d strings match the server", *samples)
+	if *reverseLength <= 0 {
+		return nil
+	}
+	mappings, err := extractor.CharacterSetReverseMappings(ctx, c, charset, rangeMap, *reverseLength, log.Printf)
+	if err != nil {
+		return err
+	}
+	for _, mapping := range mappings {
+		log.Print(mapping.String())
+	}
+	if len(mappings) > 0 {
+		return fmt.Errorf("%d byte sequences are decoded differently by the server", len(mappings))
+	}
+	log.Printf("every byte sequence of up to %d bytes matches the server", *reverseLength)
+	return nil
+}
+
+// validateCollation implements `validate collation`, which compares random strings of multiple runes using a previously
+// generated collation file, and compares the results against the server. This is equivalent to
+// TestValidateCollationStrings.
+func validateCollation(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("validate collation", flag.ContinueOnError)
+	var conn connectionFlags
+	conn.register(fs)
+	file := fs.String("file", "", "the generated collation file (defaults to <collation>.go.txt)")
+	samples := fs.Int("samples", 10000, "the number of random pairs of strings to validate")
+	seed := fs.Int64("seed", 0, "the seed of the random strings")
+	collation, err := parseName(fs, args, "collation")
+	if err != nil {
+		return err
+	}
+	if *file == "" {
+		*file = collation + ".go.txt"
+	}
+
+	contents, err := utils.ReadArtifact(*file)
+	if err != nil {
+		return err
+	}
+	runeWeights, err := utils.ParseRuneComparatorGoFile(string(contents))
+	if err != nil {
+		return err
+	}
+	ctx, cancel := conn.context(ctx)
+	defer cancel()
+	pool, err := conn.connect(ctx, 1)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	c := pool.Connection(0)
+	metadata, err := extractor.CollationMetadata(ctx, c, collation)
+	if err != nil {
+		return err
+	}
+	return validateCollationStrings(ctx, c, collation, metadata.Charset, runeWeights, *samples, *seed)
+}
+
+// validateCollationStrings logs every pair of random strings that the given weights compare differently than the
+// server, returning an error when there is any.
+func validateCollationStrings(ctx context.Context, conn utils.Queryable, collation string, charset string, runeWeights *utils.RuneWeights, samples int, seed int64) error {
+	mismatches, err := extractor.ValidateCollationStrings(ctx, conn, collation, charset, runeWeights, samples, seed)
+	if err != nil {
+		return err
+	}
+	for _, mismatch := range mismatches {
+		log.Print(mismatch.String())
+	}
+	if len(mismatches) > 0 {
+		return fmt.Errorf("%d of %d pairs of strings are compared differently by the server", len(mismatches), samples)
+	}
+	log.Printf("all %d pairs of strings match the server", samples)
+	return nil
+}
@@ -0,0 +1,157 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dolthub/collation-extractor/extractor"
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+// verify implements `verify`, which checks a previously generated character set or collation against a live server,
+// reporting every codepoint whose mapping, case conversion, or relative weight has drifted. The file may be a generated
+// Go file or a saved model. Sampled runes are verified by default, which is equivalent to TestSpotCheck, while
+// -exhaustive extracts the server in full.
+func verify(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	var conn connectionFlags
+	conn.register(fs)
+	name := fs.String("name", "", "the character set or collation that the file was generated from (taken from the model or file name when empty)")
+	samples := fs.Int("samples", 2000, "the number of random runes that are verified")
+	seed := fs.Int64("seed", 0, "the seed of the random runes")
+	exhaustive := fs.Bool("exhaustive", false, "extracts every rune from the server rather than sampling, which takes as long as the original extraction")
+	report := fs.String("report", "", "the file that the differences are written to as JSON (not written when empty)")
+	maxDifferences := fs.Int("max-differences", 100, "the number of differences that are logged")
+	file, err := parseName(fs, args, "file")
+	if err != nil {
+		return err
+	}
+	// Cached results would hide any drift on a server that reports the same version as the one the file came from
+	conn.noCache = true
+
+	expected, err := loadVerifyModel(file, *name)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := conn.context(ctx)
+	defer cancel()
+	pool, err := conn.connect(ctx, 1)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	c := pool.Connection(0)
+	log.Printf("verifying the %s `%s` from `%s` against `%s`", expected.Kind, expected.Name, file, c.Version())
+
+	var diff utils.ModelDiff
+	if *exhaustive {
+		actual, err := extractModel(ctx, c, expected.Kind, expected.Name)
+		if err != nil {
+			return err
+		}
+		if diff, err = utils.DiffModels(expected, actual); err != nil {
+			return err
+		}
+	} else {
+		runes := extractor.SampleRunes(rand.New(rand.NewSource(*seed)), *samples)
+		if expected.Kind == utils.ManifestKindCharset {
+			rangeMap, err := expected.RangeMap()
+			if err != nil {
+				return err
+			}
+			diff, err = extractor.VerifyCharacterSet(ctx, c, expected.Name, rangeMap, expected.CaseMappings(), runes, log.Printf)
+			if err != nil {
+				return err
+			}
+		} else {
+			// All collations start with the character set followed by an underscore
+			charset := strings.Split(expected.Name, "_")[0]
+			diff, err = extractor.VerifyCollation(ctx, c, expected.Name, charset, expected.Weights, expected.PadSpace, runes, log.Printf)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for i, str := range diff.Strings() {
+		if i == *maxDifferences {
+			log.Printf("...and %d more differences", diff.Len()-i)
+			break
+		}
+		log.Print(str)
+	}
+	if *report != "" {
+		contents, err := json.MarshalIndent(diff, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err = os.WriteFile(*report, append(contents, '\n'), 0644); err != nil {
+			return err
+		}
+	}
+	if diff.Len() > 0 {
+		return fmt.Errorf("`%s` has drifted from the server with %d differences", file, diff.Len())
+	}
+	log.Printf("`%s` matches the server", file)
+	return nil
+}
+
+// loadVerifyModel returns the Model of the given file, which is either a saved model (whose name contains `.model.`) or
+// a generated Go file of a character set or collation. The name of a Go file is taken from the file name, without any
+// extensions, unless a name is given.
+func loadVerifyModel(path string, name string) (*utils.Model, error) {
+	if strings.Contains(filepath.Base(path), ".model.") {
+		model, err := utils.LoadModel(path)
+		if err != nil {
+			return nil, err
+		}
+		if name != "" && name != model.Name {
+			return nil, fmt.Errorf("the model at `%s` is for `%s` rather than `%s`", path, model.Name, name)
+		}
+		return model, nil
+	}
+	if name == "" {
+		name = filepath.Base(path)
+		if idx := strings.Index(name, "."); idx > 0 {
+			name = name[:idx]
+		}
+	}
+	contents, err := utils.ReadArtifact(path)
+	if err != nil {
+		return nil, err
+	}
+	if rangeMap, caseMappings, err := utils.ParseRangeMapGoFile(string(contents)); err == nil {
+		return utils.NewCharacterSetModel(name, rangeMap, caseMappings), nil
+	}
+	runeWeights, err := utils.ParseRuneComparatorGoFile(string(contents))
+	if err != nil {
+		return nil, fmt.Errorf("`%s` is neither a generated character set nor a generated collation: %w", path, err)
+	}
+	var runes []rune
+	iter := utils.NewUTF8Iter()
+	for r, ok := iter.Next(); ok; r, ok = iter.Next() {
+		runes = append(runes, r)
+	}
+	return utils.NewCollationModel(name, utils.NewRuneComparatorFromOrder(runeWeights.Order(runes)), runeWeights.PadSpace()), nil
+}
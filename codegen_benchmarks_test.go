@@ -0,0 +1,76 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+const (
+	// A model previously saved by TestExtractCharacterSet or TestExtractCollation
+	TestCodegenBenchmarks_model = "./utf8mb4_0900_ai_ci.model.json"
+	// A UTF-8 text file containing the corpus that collations are benchmarked over, which should approximate the text
+	// that a database would store
+	TestCodegenBenchmarks_corpus = "./corpus.txt"
+	// The benchtime of each compiled benchmark, such as `1s` or `1000x`
+	TestCodegenBenchmarks_benchtime = "1s"
+	// The minimum time that each variant of a character set is benchmarked for
+	TestCodegenBenchmarks_duration = time.Second
+	// The report is written as JSON, which is skipped when the report is empty
+	TestCodegenBenchmarks_report = "./utf8mb4_0900_ai_ci.benchmarks.json"
+)
+
+// TestCodegenBenchmarks benchmarks every form of the generated files of a model. Collations are generated in every
+// CollationCodegen, each of which is compiled and benchmarked over the corpus, while character sets are constructed with
+// every consolidation strategy, both with and without linear entries. This does not connect to a server, and is
+// intended to drive decisions about the layout of the generated files with data.
+func TestCodegenBenchmarks(t *testing.T) {
+	model, err := utils.LoadModel(TestCodegenBenchmarks_model)
+	require.NoError(t, err)
+	var report interface{ String() string }
+	switch model.Kind {
+	case utils.ManifestKindCollation:
+		corpus, err := os.ReadFile(TestCodegenBenchmarks_corpus)
+		require.NoError(t, err)
+		rc, err := model.RuneComparator()
+		require.NoError(t, err)
+		variants, err := utils.CollationCodegenVariants(rc, model.Name, model.PadSpace)
+		require.NoError(t, err)
+		report, err = utils.RunCodegenBenchmarks(context.Background(), variants, string(corpus), TestCodegenBenchmarks_benchtime)
+		require.NoError(t, err)
+	case utils.ManifestKindCharset:
+		tree, err := model.EncodingTree()
+		require.NoError(t, err)
+		options := utils.CharacterSetQuirksFor(model.Name).RangeMapOptions
+		report, err = utils.RunRangeMapBenchmarks(tree, model.Name, options, TestCodegenBenchmarks_duration)
+		require.NoError(t, err)
+	default:
+		t.Fatalf("model `%s` has the unknown kind `%s`", model.Name, model.Kind)
+	}
+	t.Logf("\n%s", report.String())
+	if TestCodegenBenchmarks_report != "" {
+		contents, err := json.MarshalIndent(report, "", "  ")
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(TestCodegenBenchmarks_report, append(contents, '\n'), 0644))
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/collation-extractor/extractor"
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+const (
+	TestCompareTargets_user     = "root"
+	TestCompareTargets_password = "password"
+	TestCompareTargets_host     = "localhost"
+	TestCompareTargets_port     = 3306
+	// The target is usually a Dolt (or other GMS) server, which is compared against the MySQL server above
+	TestCompareTargets_targetUser     = "root"
+	TestCompareTargets_targetPassword = ""
+	TestCompareTargets_targetHost     = "localhost"
+	TestCompareTargets_targetPort     = 3307
+	TestCompareTargets_name           = "utf16_unicode_ci" // Either a character set or a collation
+	TestCompareTargets_kind           = utils.ManifestKindCollation
+	// Every difference is written to the report as JSON, which is skipped when the report is empty
+	TestCompareTargets_report         = "./" + TestCompareTargets_name + ".diff.json"
+	TestCompareTargets_maxDifferences = 100
+)
+
+// TestCompareTargets extracts the same character set or collation from a MySQL server and a target server (such as
+// Dolt), and logs every encoding, case conversion, and weight that differs between them. The query cache is not used,
+// as it would return the same results for servers that report the same version. This fails if anything differs, and
+// runs both extractions in full, so it takes twice as long as TestExtractCharacterSet or TestExtractCollation.
+func TestCompareTargets(t *testing.T) {
+	conn, err := utils.NewConnection(TestCompareTargets_user, TestCompareTargets_password, TestCompareTargets_host, TestCompareTargets_port)
+	require.NoError(t, err)
+	defer conn.Close()
+	target, err := utils.NewConnection(TestCompareTargets_targetUser, TestCompareTargets_targetPassword, TestCompareTargets_targetHost, TestCompareTargets_targetPort)
+	require.NoError(t, err)
+	defer target.Close()
+
+	model := CompareTargetsModel(t, conn)
+	targetModel := CompareTargetsModel(t, target)
+	diff, err := utils.DiffModels(model, targetModel)
+	require.NoError(t, err)
+	for i, str := range diff.Strings() {
+		if i == TestCompareTargets_maxDifferences {
+			t.Logf("...and %d more differences", diff.Len()-i)
+			break
+		}
+		t.Log(str)
+	}
+	if TestCompareTargets_report != "" {
+		contents, err := json.MarshalIndent(diff, "", "  ")
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(TestCompareTargets_report, append(contents, '\n'), 0644))
+	}
+	require.Zero(t, diff.Len(), "%d differences found for `%s`", diff.Len(), TestCompareTargets_name)
+}
+
+// CompareTargetsModel is part of the implementation of TestCompareTargets, which extracts the Model from a single server.
+func CompareTargetsModel(t *testing.T, conn *utils.Connection) *utils.Model {
+	var model *utils.Model
+	var err error
+	if TestCompareTargets_kind == utils.ManifestKindCharset {
+		model, err = extractor.ExtractCharsetModel(NewContext(t, conn), conn, TestCompareTargets_name)
+	} else {
+		model, err = extractor.ExtractCollation(NewContext(t, conn), conn, TestCompareTargets_name, nil)
+	}
+	require.NoError(t, err)
+	return model
+}
@@ -0,0 +1,92 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/collation-extractor/extractor"
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+const (
+	TestCompareVersions_name = "utf8mb4_0900_ai_ci" // Either a character set or a collation
+	TestCompareVersions_kind = utils.ManifestKindCollation
+	// Every change is written to the report as JSON, which is skipped when the report is empty
+	TestCompareVersions_report        = "./" + TestCompareVersions_name + ".versions.json"
+	TestCompareVersions_maxCodepoints = 100
+)
+
+// TestCompareVersions_images are started in Docker in order, so they should be listed from the oldest version.
+var TestCompareVersions_images = []string{"mysql:5.7", "mysql:8.0", "mysql:8.4"}
+
+// TestCompareVersions extracts the same character set or collation from each of the images above, and logs every
+// codepoint whose encoding, case conversion, or weight changed between each version and the version before it. This
+// helps decide which version's behavior GMS should embed. Versions that do not offer the character set or collation are
+// skipped. Each image is extracted in full, so this takes as long as TestExtractCharacterSet or TestExtractCollation for
+// every image.
+func TestCompareVersions(t *testing.T) {
+	versions := make([]string, len(TestCompareVersions_images))
+	models := make([]*utils.Model, len(TestCompareVersions_images))
+	for i, image := range TestCompareVersions_images {
+		versions[i], models[i] = CompareVersionsModel(t, image)
+	}
+	comparison, err := utils.CompareVersions(TestCompareVersions_name, TestCompareVersions_kind, versions, models)
+	require.NoError(t, err)
+	for _, version := range comparison.Missing {
+		t.Logf("`%s` does not offer `%s`", version, TestCompareVersions_name)
+	}
+	for _, change := range comparison.Changes {
+		t.Logf("%d differences from `%s` to `%s`", change.Diff.Len(), change.From, change.To)
+	}
+	for i, history := range comparison.Codepoints {
+		if i == TestCompareVersions_maxCodepoints {
+			t.Logf("...and %d more codepoints", len(comparison.Codepoints)-i)
+			break
+		}
+		for _, str := range history.Changes {
+			t.Log(str)
+		}
+	}
+	if TestCompareVersions_report != "" {
+		contents, err := json.MarshalIndent(comparison, "", "  ")
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(TestCompareVersions_report, append(contents, '\n'), 0644))
+	}
+}
+
+// CompareVersionsModel is part of the implementation of TestCompareVersions, which starts a single image and returns its
+// server version along with its Model. The Model is nil when the version does not offer the character set or collation.
+func CompareVersionsModel(t *testing.T, image string) (string, *utils.Model) {
+	conn := NewDockerConnection(t, image)
+	ctx := NewContext(t, conn)
+	exists, err := extractor.Exists(ctx, conn, TestCompareVersions_kind, TestCompareVersions_name)
+	require.NoError(t, err)
+	if !exists {
+		return conn.Version(), nil
+	}
+	var model *utils.Model
+	if TestCompareVersions_kind == utils.ManifestKindCharset {
+		model, err = extractor.ExtractCharsetModel(ctx, conn, TestCompareVersions_name)
+	} else {
+		model, err = extractor.ExtractCollation(ctx, conn, TestCompareVersions_name, nil)
+	}
+	require.NoError(t, err)
+	return conn.Version(), model
+}
@@ -0,0 +1,52 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+const (
+	// Each query fails once it exceeds this duration, so that a server that stops responding fails the test rather than
+	// stalling it. Zero disables the limit.
+	Context_queryTimeout = 5 * time.Minute
+	// Transient errors (such as the server dropping the connection) are retried on a new connection this many times in a
+	// row before the test fails. Zero disables retries.
+	Context_retries = 8
+	// Queries that differ only in their data are sent as prepared statements, so that the server parses each shape once
+	Context_preparedStatements = true
+)
+
+// NewContext returns the context for all tests that extract from a server, which is cancelled at the test's deadline
+// (set using `go test -timeout`). The query timeout, retries, and prepared statements above are applied to the given
+// connection.
+func NewContext(t *testing.T, conn *utils.Connection) context.Context {
+	conn.SetQueryTimeout(Context_queryTimeout)
+	policy := utils.DefaultRetryPolicy()
+	policy.MaxConsecutiveFailures, policy.Logf = Context_retries, t.Logf
+	conn.SetRetryPolicy(policy)
+	conn.SetPreparedStatements(Context_preparedStatements)
+	deadline, ok := t.Deadline()
+	if !ok {
+		return context.Background()
+	}
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	t.Cleanup(cancel)
+	return ctx
+}
@@ -0,0 +1,78 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+const (
+	TestDeduplicateCollations_manifest = "./manifest.json"
+)
+
+// TestDeduplicateCollations finds the collations in the manifest that have identical weights, and rewrites the files
+// of all but one collation in each group to share the weights of the remaining collation. This should be run after
+// extracting many collations, as aliases and legacy duplicates would otherwise duplicate megabytes of generated data.
+// Only collations that were extracted with a model are considered.
+func TestDeduplicateCollations(t *testing.T) {
+	manifest, err := utils.LoadManifest(TestDeduplicateCollations_manifest)
+	require.NoError(t, err)
+	var models []*utils.Model
+	for _, entry := range manifest.Entries {
+		if entry.Kind != utils.ManifestKindCollation || entry.Model == "" {
+			continue
+		}
+		model, err := utils.LoadModel(entry.Model)
+		require.NoError(t, err)
+		models = append(models, model)
+	}
+	groups, err := utils.GroupCollationModels(models)
+	require.NoError(t, err)
+
+	savedBytes := 0
+	for _, group := range groups {
+		shared := group[0]
+		sharedEntry, _ := manifest.Get(shared.Name, utils.ManifestKindCollation)
+		// A collation that previously shared another collation's weights must contain its own weights again
+		if sharedEntry.Shares != "" || sharedEntry.Delta != "" {
+			contents, err := shared.GoFileWithOptions(ModelCodegen(shared))
+			require.NoError(t, err)
+			sharedEntry.File = WriteArtifact(t, ArtifactBasePath(sharedEntry.File), []byte(contents))
+			sharedEntry.Shares = ""
+			sharedEntry.Delta = ""
+			manifest.Set(sharedEntry)
+		}
+		for _, alias := range group[1:] {
+			aliasEntry, _ := manifest.Get(alias.Name, utils.ManifestKindCollation)
+			if info, err := os.Stat(aliasEntry.File); err == nil && aliasEntry.Shares == "" {
+				savedBytes += int(info.Size())
+			}
+			contents := utils.RuneComparatorAliasToGoFile(alias.Name, shared.Name, alias.PadSpace, WriteArtifact_codegen)
+			aliasEntry.File = WriteArtifact(t, ArtifactBasePath(aliasEntry.File), []byte(contents))
+			aliasEntry.Shares = shared.Name
+			aliasEntry.Delta = ""
+			aliasEntry.SharedWeights = ""
+			manifest.Set(aliasEntry)
+			t.Logf("`%s` shares the weights of `%s`", alias.Name, shared.Name)
+		}
+	}
+	require.NoError(t, manifest.Save(TestDeduplicateCollations_manifest))
+	t.Logf("%d collations were grouped into %d tables, saving roughly %d bytes", len(models), len(groups), savedBytes)
+}
@@ -0,0 +1,79 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+const (
+	TestDeltaCollations_manifest = "./manifest.json"
+)
+
+// TestDeltaCollations compares the collations in the manifest of each character set, and rewrites the file of each
+// collation that is similar enough to another (such as a tailoring of `utf8mb4_unicode_ci`) as a delta from the other
+// collation, which only lists the runes that sort differently. This should be run after TestDeduplicateCollations, as
+// collations that share another collation's weights are skipped. Only collations that were extracted with a model are
+// considered, and the base collations must have been generated without implicit weights.
+func TestDeltaCollations(t *testing.T) {
+	manifest, err := utils.LoadManifest(TestDeltaCollations_manifest)
+	require.NoError(t, err)
+	var models []*utils.Model
+	for _, entry := range manifest.Entries {
+		if entry.Kind != utils.ManifestKindCollation || entry.Model == "" || entry.Shares != "" {
+			continue
+		}
+		model, err := utils.LoadModel(entry.Model)
+		require.NoError(t, err)
+		models = append(models, model)
+	}
+	deltas, err := utils.FindCollationDeltas(models)
+	require.NoError(t, err)
+
+	savedBytes := 0
+	for _, model := range models {
+		entry, _ := manifest.Get(model.Name, utils.ManifestKindCollation)
+		delta, ok := deltas[strings.ToLower(model.Name)]
+		var contents string
+		if ok {
+			contents = utils.CollationDeltaToGoFile(delta, model.Name, model.PadSpace, ModelCodegen(model))
+			t.Logf("`%s` is a delta of %d runes from `%s`", model.Name, len(delta.Overrides), delta.Base)
+		} else if entry.Delta != "" {
+			// A collation that was previously a delta must contain its own weights again
+			contents, err = model.GoFileWithOptions(ModelCodegen(model))
+			require.NoError(t, err)
+		} else {
+			continue
+		}
+		if info, err := os.Stat(entry.File); err == nil {
+			savedBytes += int(info.Size()) - len(contents)
+		}
+		entry.File = WriteArtifact(t, ArtifactBasePath(entry.File), []byte(contents))
+		entry.Delta = ""
+		entry.SharedWeights = ""
+		if ok {
+			entry.Delta = delta.Base
+		}
+		manifest.Set(entry)
+	}
+	require.NoError(t, manifest.Save(TestDeltaCollations_manifest))
+	t.Logf("%d of %d collations were generated as deltas, saving roughly %d bytes", len(deltas), len(models), savedBytes)
+}
@@ -0,0 +1,50 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+const (
+	// Both trees are saved by TestExtractCharacterSet, such as from servers of different versions
+	TestDiffCharacterSets_left  = "./mysql-8.0/utf16.tree.bin"
+	TestDiffCharacterSets_right = "./mysql-8.4/utf16.tree.bin"
+	// Only the first differences are logged, as a changed range could otherwise log thousands of codepoints
+	TestDiffCharacterSets_maxDifferences = 100
+)
+
+// TestDiffCharacterSets compares two extractions of a character set, logging every codepoint that was added, removed,
+// or now decodes to a different rune. This does not connect to a server, and fails if any codepoint differs, so that
+// mapping drift between MySQL versions (or between MySQL and Dolt) is caught before a file is regenerated for GMS.
+func TestDiffCharacterSets(t *testing.T) {
+	left, err := utils.LoadEncodingTree(TestDiffCharacterSets_left)
+	require.NoError(t, err)
+	right, err := utils.LoadEncodingTree(TestDiffCharacterSets_right)
+	require.NoError(t, err)
+	diffs := left.Diff(right)
+	for i, diff := range diffs {
+		if i == TestDiffCharacterSets_maxDifferences {
+			t.Logf("...and %d more differences", len(diffs)-i)
+			break
+		}
+		t.Log(diff.String())
+	}
+	require.Empty(t, diffs, "%d codepoints differ between `%s` and `%s`", len(diffs), TestDiffCharacterSets_left, TestDiffCharacterSets_right)
+}
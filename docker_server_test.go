@@ -0,0 +1,61 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/collation-extractor/extractor"
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+const (
+	// Any official MySQL or MariaDB image (or one derived from them) may be used
+	TestDockerServer_image    = "mysql:8.0.31"
+	TestDockerServer_password = "password"
+)
+
+// TestDockerServer starts the image in Docker, and lists the character sets of its server. This checks that the image
+// is usable before running a long extraction against it. Other tests may connect to an image instead of a local server
+// by replacing utils.NewConnection with NewDockerConnection.
+func TestDockerServer(t *testing.T) {
+	conn := NewDockerConnection(t, TestDockerServer_image)
+	charsets, err := extractor.CharacterSets(NewContext(t, conn), conn)
+	require.NoError(t, err)
+	t.Logf("`%s` has %d character sets: %v", conn.Version(), len(charsets), charsets)
+}
+
+// NewDockerConnection starts the given image in Docker and returns a connection to its server. The connection is
+// closed and the container is removed once the test finishes.
+func NewDockerConnection(t *testing.T, image string) *utils.Connection {
+	server, err := utils.StartDockerServer(context.Background(), utils.DockerServerOptions{
+		Image:    image,
+		Password: TestDockerServer_password,
+		Logf:     t.Logf,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, server.Close())
+	})
+	conn, err := utils.NewConnectionWithOptions(server.Options())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		conn.Close()
+	})
+	return conn
+}
@@ -0,0 +1,80 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+const (
+	TestEstimate_user     = "root"
+	TestEstimate_password = "password"
+	TestEstimate_host     = "localhost"
+	TestEstimate_port     = 3306
+	// Comma-separated character sets and collations, which are distinguished by the presence of an underscore
+	TestEstimate_names           = "utf16,utf16_unicode_ci"
+	TestEstimate_manifest        = "./manifest.json"
+	TestEstimate_latencySamples  = 20
+	TestEstimate_pricePerMillion = 0.0 // The price of one million queries on a metered database, zero to skip pricing
+)
+
+// TestEstimate prints the estimated number of queries, duration, and cost of extracting each of the given character sets
+// and collations, without extracting anything. The duration is based on the measured latency of the server, so this
+// should be run against the same server that the extraction will use. Character sets that have been extracted before
+// (with a model recorded in the manifest) use their number of valid runes, while all runes are assumed to be valid
+// otherwise, which overestimates character sets that only cover a small portion of Unicode.
+func TestEstimate(t *testing.T) {
+	conn, err := utils.NewConnection(TestEstimate_user, TestEstimate_password, TestEstimate_host, TestEstimate_port)
+	require.NoError(t, err)
+	defer conn.Close()
+	latency, err := utils.MeasureLatency(conn, TestEstimate_latencySamples)
+	require.NoError(t, err)
+	manifest, err := utils.LoadManifest(TestEstimate_manifest)
+	require.NoError(t, err)
+
+	validRuneCount := func(charset string) int {
+		entry, ok := manifest.Get(charset, utils.ManifestKindCharset)
+		if !ok || entry.Model == "" {
+			return 0
+		}
+		model, err := utils.LoadModel(entry.Model)
+		require.NoError(t, err)
+		return len(model.Encodings)
+	}
+	runeCount := utils.NewUTF8Iter().Total()
+	var total time.Duration
+	t.Logf("measured latency: %s", latency)
+	for _, name := range strings.Split(TestEstimate_names, ",") {
+		var estimate utils.QueryEstimate
+		if charset, _, isCollation := strings.Cut(name, "_"); isCollation {
+			estimate = utils.EstimateCollationQueries(utils.SelectExtractionProfile(name), runeCount, validRuneCount(charset), 0, 0)
+		} else {
+			estimate = utils.EstimateCharacterSetQueries(name, runeCount, validRuneCount(name))
+		}
+		total += estimate.Duration(latency)
+		if TestEstimate_pricePerMillion > 0 {
+			t.Logf("%s, ~%s, ~$%.2f", estimate, estimate.Duration(latency).Round(time.Second), estimate.Cost(TestEstimate_pricePerMillion))
+		} else {
+			t.Logf("%s, ~%s", estimate, estimate.Duration(latency).Round(time.Second))
+		}
+	}
+	t.Logf("total: ~%s", total.Round(time.Second))
+}
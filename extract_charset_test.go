@@ -15,15 +15,11 @@
 package main
 
 import (
-	"encoding/hex"
-	"fmt"
-	"os"
 	"testing"
-	"unicode/utf8"
 
-	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/dolthub/collation-extractor/extractor"
 	"github.com/dolthub/collation-extractor/utils"
 )
 
@@ -33,7 +29,24 @@ const (
 	TestExtractCharacterSet_host     = "localhost"
 	TestExtractCharacterSet_port     = 3306
 	TestExtractCharacterSet_charset  = "utf16"
-	TestExtractCharacterSet_file     = "./" + TestExtractCharacterSet_charset + ".go.txt"
+	TestExtractCharacterSet_file     = "./" + TestExtractCharacterSet_charset + ".go"
+	TestExtractCharacterSet_manifest = "./manifest.json"
+	// Runes that encode to a codepoint which decodes to a different rune are written to their own file, which is skipped
+	// when the character set does not have any lossy mappings
+	TestExtractCharacterSet_lossyFile = "./" + TestExtractCharacterSet_charset + "_lossy.go"
+	// The extracted encodings in a compact binary format, which may be reloaded using utils.LoadEncodingTree
+	TestExtractCharacterSet_tree = "./" + TestExtractCharacterSet_charset + ".tree.bin"
+	// Implements golang.org/x/text/encoding.Encoding using the generated RangeMap, for programs outside of GMS
+	TestExtractCharacterSet_textEncodingFile = "./" + TestExtractCharacterSet_charset + "_text_encoding.go"
+	// The model allows the file to be regenerated by TestGenerate without connecting to a server
+	TestExtractCharacterSet_model = "./" + TestExtractCharacterSet_charset + ".model.json"
+	// A test for GMS that checks the generated RangeMap against codepoints converted by the server, which is skipped
+	// when there are no samples
+	TestExtractCharacterSet_testFile    = "./" + TestExtractCharacterSet_charset + "_test.go"
+	TestExtractCharacterSet_testSamples = 100
+	// Declares the server version, extraction time, and extractor revision as constants, which every other Go file also
+	// records within its header comment
+	TestExtractCharacterSet_provenanceFile = "./" + TestExtractCharacterSet_charset + "_provenance.go"
 )
 
 // TestExtractCharacterSet creates a Go file for embedding into GMS. It contains the data necessary to encode and decode
@@ -42,108 +55,83 @@ func TestExtractCharacterSet(t *testing.T) {
 	conn, err := utils.NewConnection(TestExtractCharacterSet_user, TestExtractCharacterSet_password, TestExtractCharacterSet_host, TestExtractCharacterSet_port)
 	require.NoError(t, err)
 	defer conn.Close()
-	rangeMap := CharacterSetToRangeMap(t, conn, TestExtractCharacterSet_charset)
-
-	// Grab the uppercase and lowercase conversions (case conversions may be asymmetric, so we have to test them individually)
-	iter := utils.NewUTF8Iter()
-	var toUpper [][2]rune
-	var toLower [][2]rune
-	for r, ok := iter.Next(); ok; r, ok = iter.Next() {
-		// Ensure that this rune is a valid character in the character set, as we only want to check valid runes
-		_, ok := rangeMap.Encode([]byte(string(r)))
-		if !ok {
-			continue
-		}
-
-		// First we'll do the uppercase conversion
-		rAsBytes := []byte(string(r))
-		sqlOutput, err := conn.Query(fmt.Sprintf(`SELECT CAST(CONVERT(UPPER(CONVERT(_utf8mb4 0x%s USING %s)) USING utf8mb4) AS BINARY);`,
-			hex.EncodeToString(rAsBytes), TestExtractCharacterSet_charset))
-		require.NoError(t, err)
-		// The output should be equivalent to a single rune
-		outputAsRune := []rune(string(sqlOutput))[0]
-		if assert.True(t, utf8.RuneCountInString(string(sqlOutput)) == 1 && utf8.ValidRune(outputAsRune)) && r != outputAsRune {
-			toUpper = append(toUpper, [2]rune{r, outputAsRune})
-		}
+	EnableQueryCache(t, conn)
+	checkpointer := NewCheckpointer(t, TestExtractCharacterSet_charset)
+	rangeMap := CharacterSetToRangeMap(t, conn, TestExtractCharacterSet_charset, checkpointer)
+	require.NoError(t, utils.CharacterSetQuirksFor(TestExtractCharacterSet_charset).Verify(rangeMap))
+	// The generated RangeMap skips the entry search for ASCII when this is true
+	t.Logf("ASCII compatible: %t", rangeMap.IsASCIICompatible())
+	report := rangeMap.Report()
+	t.Logf("RangeMap: %s", report.String())
+	require.NoError(t, report.Err())
+	// The runes are converted using the same queries as the RangeMap, so they are read from the query cache
+	lossyMappings, err := extractor.CharacterSetLossyMappings(NewContext(t, conn), conn, TestExtractCharacterSet_charset, rangeMap, utils.NewUTF8Iter(), t.Logf)
+	require.NoError(t, err)
+	for _, lossyMapping := range lossyMappings {
+		t.Logf("lossy mapping: %s", lossyMapping.String())
+	}
+	caseMappings := CharacterSetCaseMappings(t, conn, TestExtractCharacterSet_charset, rangeMap, utils.NewUTF8Iter(), checkpointer)
+	model := utils.NewCharacterSetModel(TestExtractCharacterSet_charset, rangeMap, caseMappings)
+	model.Provenance = utils.NewProvenance(conn.Version(), 0)
+	StampProvenance(t, model.Provenance)
+	require.NoError(t, model.Save(TestExtractCharacterSet_model))
+	require.NoError(t, utils.SaveEncodingTree(TestExtractCharacterSet_tree, rangeMap.Tree()))
+	require.NoError(t, utils.RegisteredExtractionHooks().BeforeCodegen(model))
 
-		// Afterward we do the lowercase conversion
-		sqlOutput, err = conn.Query(fmt.Sprintf(`SELECT CAST(CONVERT(LOWER(CONVERT(_utf8mb4 0x%s USING %s)) USING utf8mb4) AS BINARY);`,
-			hex.EncodeToString(rAsBytes), TestExtractCharacterSet_charset))
+	// Write the output to a file
+	path := WriteArtifact(t, TestExtractCharacterSet_file, []byte(utils.RangeMapToGoFileWithOptions(rangeMap, caseMappings, TestExtractCharacterSet_charset, WriteArtifact_codegen)))
+	WriteArtifact(t, TestExtractCharacterSet_textEncodingFile, []byte(utils.TextEncodingToGoFile(TestExtractCharacterSet_charset, WriteArtifact_codegen)))
+	WriteArtifact(t, TestExtractCharacterSet_provenanceFile, []byte(utils.ProvenanceToGoFile(TestExtractCharacterSet_charset, model.Provenance, WriteArtifact_codegen)))
+	if len(lossyMappings) > 0 {
+		WriteArtifact(t, TestExtractCharacterSet_lossyFile, []byte(utils.LossyMappingsToGoFile(TestExtractCharacterSet_charset, lossyMappings, WriteArtifact_codegen)))
+	}
+	if TestExtractCharacterSet_testSamples > 0 {
+		samples, err := extractor.CharacterSetSamples(NewContext(t, conn), conn, TestExtractCharacterSet_charset, rangeMap, TestExtractCharacterSet_testSamples, 1)
 		require.NoError(t, err)
-		outputAsRune = []rune(string(sqlOutput))[0]
-		if assert.True(t, utf8.RuneCountInString(string(sqlOutput)) == 1 && utf8.ValidRune(outputAsRune)) && r != outputAsRune {
-			toLower = append(toLower, [2]rune{r, outputAsRune})
-		}
+		WriteArtifact(t, TestExtractCharacterSet_testFile, []byte(utils.CharacterSetTestToGoFile(TestExtractCharacterSet_charset, samples, WriteArtifact_codegen)))
 	}
 
-	// Write the output to a file
-	file, err := os.OpenFile(TestExtractCharacterSet_file, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0644)
-	require.NoError(t, err)
-	defer file.Close()
-	_, err = file.WriteString(utils.RangeMapToGoFile(rangeMap, toUpper, toLower, TestExtractCharacterSet_charset))
+	// Record the character set in the manifest
+	manifest, err := utils.LoadManifest(TestExtractCharacterSet_manifest)
 	require.NoError(t, err)
-	err = file.Sync()
+	manifest.Set(utils.ManifestEntry{
+		Name:  TestExtractCharacterSet_charset,
+		Kind:  utils.ManifestKindCharset,
+		File:  path,
+		Model: TestExtractCharacterSet_model,
+	})
+	require.NoError(t, manifest.Save(TestExtractCharacterSet_manifest))
+	require.NoError(t, checkpointer.Remove())
+}
+
+// CharacterSetCaseMappings is part of the implementation of TestExtractCharacterSet, which returns the uppercase and
+// lowercase conversions for all runes from the iterator that are valid in the character set. The checkpointer may be nil.
+func CharacterSetCaseMappings(t *testing.T, conn *utils.Connection, charset string, rangeMap *utils.RangeMap, iter *utils.UTF8Iter, checkpointer *utils.Checkpointer) utils.CaseMappings {
+	caseMappings, err := extractor.CharacterSetCaseMappings(NewContext(t, conn), conn, charset, rangeMap, iter, checkpointer)
 	require.NoError(t, err)
+	return caseMappings
 }
 
 // CharacterSetToRangeMap is part of the implementation of TestExtractCharacterSet, which is used to construct a
 // RangeMap from a character set. This validates the RangeMap before returning, so no further validation is necessary.
-func CharacterSetToRangeMap(t *testing.T, conn *utils.Connection, charset string) *utils.RangeMap {
-	iter := utils.NewUTF8Iter()
-	charsetToGoString := utils.NewCharacterSetEncodingTree()
-	for r, ok := iter.Next(); ok; r, ok = iter.Next() {
-		// Converting a rune to a string will encode the rune (which is an int32) as a sequence of valid UTF8 bytes.
-		// We then convert it to a byte slice to pass to the hex encoder and encoding trees.
-		rAsBytes := []byte(string(r))
-		// We convert the string to a hexadecimal to ensure that Go's exact byte representation is being given to MySQL.
-		// This also allows us to bypass escape rules.
-		sqlOutput, err := conn.Query(fmt.Sprintf(`SELECT CAST(CONVERT(_utf8mb4 0x%s USING %s) AS BINARY);`,
-			hex.EncodeToString(rAsBytes), charset))
-		require.NoError(t, err)
-
-		// If we receive the '?' character then we check if we've already received it. As '?' is within the ASCII space,
-		// it should already have been added by the time this is encountered elsewhere. MySQL returns this character
-		// when it doesn't have a conversion to the target character set, so we do a brief check to verify that it's
-		// already in the tree (validating that this is the unknown and not a valid '?') and continue if so. Otherwise,
-		// we error, as this is a character set that doesn't follow the precedent set by other character sets.
-		if len(sqlOutput) == 1 && sqlOutput[0] == 63 && r != 63 {
-			child := charsetToGoString.Child(sqlOutput[0])
-			if child.Data() == nil {
-				t.Fatalf("rune `%s` returned `%d` which should have already been added", string(r), sqlOutput[0])
-			}
-			continue
-		}
-
-		// We add the output to the tree for converting from the character set to Go's encoding
-		toGoStr := charsetToGoString
-		for _, byteVal := range sqlOutput {
-			toGoStr = toGoStr.AddChild(byteVal)
-		}
-		require.True(t, toGoStr.SetData(rAsBytes))
-	}
-
-	// Add all codepoints to the constructor
-	charsetToGoIter := charsetToGoString.Iterator()
-	rangeMapConstructor := utils.NewRangeMapConstructor()
-	for inputEncoding, outputEncoding, ok := charsetToGoIter.Next(); ok; inputEncoding, outputEncoding, ok = charsetToGoIter.Next() {
-		rangeMapConstructor.AddValidEncoding(inputEncoding, outputEncoding)
-	}
-	rangeMap := rangeMapConstructor.Map()
+// The checkpointer may be nil.
+func CharacterSetToRangeMap(t *testing.T, conn *utils.Connection, charset string, checkpointer *utils.Checkpointer) *utils.RangeMap {
+	rangeMap, err := extractor.CharacterSetToRangeMap(NewContext(t, conn), conn, charset, t.Logf, checkpointer)
+	require.NoError(t, err)
+	return rangeMap
+}
 
-	// Verify that the range map returns the correct results for all valid inputs
-	charsetToGoIter = charsetToGoString.Iterator()
-	for inputEncoding, outputEncoding, ok := charsetToGoIter.Next(); ok; inputEncoding, outputEncoding, ok = charsetToGoIter.Next() {
-		generatedOutputEncoding, ok := rangeMap.Decode(inputEncoding)
-		if assert.True(t, ok) {
-			assert.Equal(t, outputEncoding, generatedOutputEncoding, "Decode\ninput: '%c', expected output: '%c', actual output: '%c'",
-				[]rune(string(inputEncoding))[0], []rune(string(outputEncoding))[0], []rune(string(generatedOutputEncoding))[0])
-		}
-		generatedInputEncoding, ok := rangeMap.Encode(outputEncoding)
-		if assert.True(t, ok) {
-			assert.Equal(t, inputEncoding, generatedInputEncoding, "Encode\ninput: '%c', expected output: '%c', actual output: '%c'",
-				[]rune(string(outputEncoding))[0], []rune(string(inputEncoding))[0], []rune(string(generatedInputEncoding))[0])
-		}
-	}
+// CharacterSetToEncodingTree is part of the implementation of TestExtractCharacterSet, which adds every rune from the
+// iterator that is valid in the character set to the given tree. The tree's input encoding is the character set's
+// encoding, while the data is Go's UTF8 encoding.
+func CharacterSetToEncodingTree(t *testing.T, conn *utils.Connection, charset string, iter *utils.UTF8Iter, charsetToGoString *utils.CharacterSetEncodingTree) {
+	require.NoError(t, extractor.CharacterSetToEncodingTree(NewContext(t, conn), conn, charset, iter, charsetToGoString, t.Logf, nil))
+}
 
+// EncodingTreeToRangeMap is part of the implementation of TestExtractCharacterSet, which constructs a RangeMap from the
+// given tree. This validates the RangeMap before returning, so no further validation is necessary.
+func EncodingTreeToRangeMap(t *testing.T, charsetToGoString *utils.CharacterSetEncodingTree) *utils.RangeMap {
+	rangeMap, err := utils.RangeMapFromTree(charsetToGoString)
+	require.NoError(t, err)
 	return rangeMap
 }
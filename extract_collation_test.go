@@ -15,15 +15,13 @@
 package main
 
 import (
-	"bytes"
-	"encoding/hex"
-	"fmt"
-	"os"
+	"errors"
 	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/dolthub/collation-extractor/extractor"
 	"github.com/dolthub/collation-extractor/utils"
 )
 
@@ -33,98 +31,208 @@ const (
 	TestExtractCollation_host      = "localhost"
 	TestExtractCollation_port      = 3306
 	TestExtractCollation_collation = "utf16_unicode_ci"
-	TestExtractCollation_file      = "./" + TestExtractCollation_collation + ".go.txt"
+	TestExtractCollation_file      = "./" + TestExtractCollation_collation + ".go"
+	TestExtractCollation_doltFile  = "./" + TestExtractCollation_collation + ".dolt"
+	// Runes that expand to multiple collation elements are written to their own file, which is skipped when the
+	// collation does not have any expansions
+	TestExtractCollation_expansionsFile = "./" + TestExtractCollation_collation + "_expansions.go"
+	TestExtractCollation_metadataFile   = "./" + TestExtractCollation_collation + "_metadata.go"
+	TestExtractCollation_provenanceFile = "./" + TestExtractCollation_collation + "_provenance.go"
+	TestExtractCollation_reverseFile    = "./" + TestExtractCollation_collation + "_reverse.go"
+	TestExtractCollation_manifest       = "./manifest.json"
+	// The model allows the file to be regenerated by TestGenerate without connecting to a server
+	TestExtractCollation_model = "./" + TestExtractCollation_collation + ".model.json"
+	// A test for GMS that checks the generated weights against rune pairs compared by the server, which is skipped when
+	// there are no samples
+	TestExtractCollation_testFile    = "./" + TestExtractCollation_collation + "_test.go"
+	TestExtractCollation_testSamples = 100
+	// The rune that each rune folds to when the collation ignores case, which requires the case mappings of the character
+	// set (extracted using the same queries as TestExtractCharacterSet, so they're read from the query cache)
+	TestExtractCollation_caseFolding     = false
+	TestExtractCollation_caseFoldingFile = "./" + TestExtractCollation_collation + "_fold.go"
+	// Detects the runes that the collation ignores entirely (such as the soft hyphen), which the generated comparison skips
+	TestExtractCollation_ignorables = false
+	// The base rune that each rune strips to when the collation ignores accents, whose bases are found using the model of
+	// the accent-sensitive counterpart (such as utf8mb4_0900_as_ci) when it has been extracted to the working directory
+	TestExtractCollation_accentStripping     = false
+	TestExtractCollation_accentStrippingFile = "./" + TestExtractCollation_collation + "_strip.go"
+	// The weight cache of a collation may seed the extraction of another collation of the same character set, as many
+	// collations share the weights of most runes. An empty import path does not seed the extraction. Every seeded
+	// weight is trusted other than the sampled ones, so this should only be used for collations known to be related.
+	TestExtractCollation_weightCacheImport = ""
+	TestExtractCollation_weightCacheExport = "./" + TestExtractCollation_collation + ".weights.txt"
+	TestExtractCollation_weightCacheSample = 100 // Verifies one of every N seeded weights against the server
+	// An empty strategy selects the strategy based on the collation's name
+	TestExtractCollation_strategy utils.ExtractionStrategy = ""
 )
 
 // TestExtractCollation creates a Go file for embedding into GMS. It contains the data necessary to sort and compare
-// strings based on the specified collation. May take up to 120 minutes depending on the complexity of the collation.
+// strings based on the specified collation. May take up to 120 minutes depending on the complexity of the collation when
+// using STRCMP, while the order_by strategy (which is selected for the UCA collations) sorts on the server in minutes.
+// The order_by strategy creates a database named collation_extractor, as temporary tables must belong to a database.
 func TestExtractCollation(t *testing.T) {
 	// All collations start with the character set followed by an underscore
 	charset := strings.Split(TestExtractCollation_collation, "_")[0]
+	profile := utils.SelectExtractionProfile(TestExtractCollation_collation)
+	if TestExtractCollation_strategy != "" {
+		profile.Strategy = TestExtractCollation_strategy
+	}
 
-	iter := utils.NewUTF8Iter()
 	conn, err := utils.NewConnection(TestExtractCollation_user, TestExtractCollation_password, TestExtractCollation_host, TestExtractCollation_port)
 	require.NoError(t, err)
 	defer conn.Close()
+	// A collation from the other server flavor would have its files overwritten, so it's checked before extracting
+	manifest, err := utils.LoadManifest(TestExtractCollation_manifest)
+	require.NoError(t, err)
+	require.NoError(t, manifest.CheckFlavor(utils.ManifestEntry{
+		Name:     TestExtractCollation_collation,
+		Kind:     utils.ManifestKindCollation,
+		Metadata: &utils.CollationMetadata{Name: TestExtractCollation_collation, Flavor: conn.Builder().Flavor()},
+	}))
+	EnableQueryCache(t, conn)
 	// The RangeMap allows us to check that a rune is valid in the character set, so that we may skip over invalid runes
-	rangeMap := CharacterSetToRangeMap(t, conn, charset)
+	rangeMap := CharacterSetToRangeMap(t, conn, charset, nil)
 
-	// This is a map that takes a rune as an input and return the weight, which is represented as a byte slice. MySQL
-	// encodes weights as binary strings, and they cannot be converted to unsigned integers due to their length (which
-	// can be over the 8 byte limit of a 64-bit integer).
 	runeToWeight := make(map[rune][]byte)
-	runeComparator := utils.NewRuneComparator()
-	// The comparator returns the relative sorting order of any two given runes
-	runeComparator.SetComparator(func(l rune, r rune) int {
-		// If we have the weights for both of the runes then we may use those for comparison
-		lWeight, lOk := runeToWeight[l]
-		rWeight, rOk := runeToWeight[r]
-		if lOk && rOk {
-			return bytes.Compare(lWeight, rWeight)
-		}
-
-		// Without the weights, we can resort to using MySQL's STRCMP to get a comparison. Check the "for" loop below
-		// for details on our byte slices and hex encoding usage here.
-		lAsBytes := []byte(string(l))
-		rAsBytes := []byte(string(r))
-		sqlOutput, err := conn.Query(fmt.Sprintf(
-			"SELECT STRCMP(CONVERT(_utf8mb4 0x%s USING %s) COLLATE %s, CONVERT(_utf8mb4 0x%s USING %s) COLLATE %s);",
-			hex.EncodeToString(lAsBytes), charset, TestExtractCollation_collation,
-			hex.EncodeToString(rAsBytes), charset, TestExtractCollation_collation))
+	if TestExtractCollation_weightCacheImport != "" {
+		runeToWeight, err = utils.LoadWeightCache(TestExtractCollation_weightCacheImport)
 		require.NoError(t, err)
-		switch string(sqlOutput) {
-		case "1":
-			return 1
-		case "-1":
-			return -1
-		case "0":
-			// If they're comparably equivalent and one has a weight, we can assign the other the same weight to
-			// potentially save time on future comparisons
-			if lOk && !rOk {
-				runeToWeight[r] = lWeight
-			} else if !lOk && rOk {
-				runeToWeight[l] = rWeight
-			}
-			return 0
-		default:
-			t.Fatalf("unknown output `%s` for comparing '%s' (%d) and '%s' (%d)", string(sqlOutput), string(l), l, string(r), r)
-			return 0 // Won't actually be reached due to the above call, needed to compile
-		}
-	})
+	}
 
-	for r, ok := iter.Next(); ok; r, ok = iter.Next() {
-		// Ensure that this rune is a valid character in the character set, as we only want to process valid runes
-		_, ok := rangeMap.Encode([]byte(string(r)))
-		if !ok {
-			continue
+	// Character sets are always extracted in full, while the runes of a collation may be pinned to a Unicode version
+	iter, unicodeVersion := NewPinnedUTF8Iter(t)
+	// Only the STRCMP strategy saves checkpoints, as the other strategies are fast enough to restart
+	checkpointer := NewCheckpointer(t, TestExtractCollation_collation)
+	var runeComparator *utils.RuneComparator
+	// Binary collations are read from the RangeMap without any comparisons, unless the server does not order them by
+	// their bytes
+	if profile.Strategy == utils.ExtractionStrategyBinary {
+		runeComparator, err = extractor.CollationToRuneComparatorBinary(NewContext(t, conn), conn, TestExtractCollation_collation, charset, iter, rangeMap, t.Logf)
+		if errors.Is(err, extractor.ErrNotByteOrder) {
+			t.Logf("%v, falling back to `%s`", err, utils.ExtractionStrategyStrcmp)
+			profile = utils.ExtractionProfile{Collation: profile.Collation, Strategy: utils.ExtractionStrategyStrcmp}
+			iter.Reset()
+		} else {
+			require.NoError(t, err)
 		}
-
-		// Converting a rune to a string will encode the rune (which is an int32) as a sequence of valid UTF8 bytes.
-		// We then convert it to a byte slice to pass to the hex encoder.
-		rAsBytes := []byte(string(r))
-		// We convert the string to a hexadecimal to ensure that Go's exact byte representation is being given to MySQL.
-		// This also allows us to bypass escape rules.
-		sqlOutput, err := conn.Query(fmt.Sprintf(
-			"SELECT HEX(WEIGHT_STRING(CONVERT(_utf8mb4 0x%s USING %s) COLLATE %s));",
-			hex.EncodeToString(rAsBytes), charset, TestExtractCollation_collation))
+	}
+	// Tailorings are extracted as a delta from the order of their base, which is read from the model that was saved
+	// when the base was extracted into the working directory
+	var base *utils.RuneComparator
+	if profile.Strategy == utils.ExtractionStrategyDelta && profile.Base != "" {
+		if baseModel, err := utils.LoadModel("./" + profile.Base + ".model.json"); err == nil {
+			base, err = baseModel.RuneComparator()
+			require.NoError(t, err)
+		}
+	}
+	switch {
+	case runeComparator != nil:
+	case profile.Strategy == utils.ExtractionStrategyOrderBy:
+		runeComparator, err = extractor.CollationToRuneComparatorOrderBy(NewContext(t, conn), conn, TestExtractCollation_collation, charset, iter, rangeMap, runeToWeight, t.Logf)
 		require.NoError(t, err)
-		// The output is the sorting weight of the character. Lower weights sort before higher weights. The weight
-		// is encoded as a binary string. WEIGHT_STRING is explicitly defined as not guaranteeing a stable output
-		// between versions, but it will always return the proper relative weights if a weight is returned. For an
-		// unknown reason, some characters do not return a weight, but still have a sort order, and such cases are
-		// handled during comparisons.
-		if len(sqlOutput) > 0 {
-			runeToWeight[r] = sqlOutput
+	case base != nil:
+		runeComparator, err = extractor.CollationTailoringToRuneComparator(NewContext(t, conn), conn, TestExtractCollation_collation, charset, iter, rangeMap, base, runeToWeight, t.Logf)
+		require.NoError(t, err)
+	default:
+		// STRCMP probing works for every collation, so it is the fallback when a delta's base has not been extracted
+		if profile.Strategy != utils.ExtractionStrategyStrcmp {
+			t.Logf("strategy `%s` is not available, falling back to `%s`", profile.Strategy, utils.ExtractionStrategyStrcmp)
+			profile = utils.ExtractionProfile{Collation: profile.Collation, Strategy: utils.ExtractionStrategyStrcmp}
 		}
-		runeComparator.Insert(r)
+		runeComparator = CollationToRuneComparator(t, conn, TestExtractCollation_collation, charset, iter, rangeMap, runeToWeight, checkpointer)
+	}
+	require.NoError(t, utils.SaveWeightCache(TestExtractCollation_weightCacheExport, runeToWeight))
+	padSpace := CollationPadSpace(t, conn, TestExtractCollation_collation, charset)
+	metadata, err := extractor.CollationMetadata(NewContext(t, conn), conn, TestExtractCollation_collation)
+	require.NoError(t, err)
+	model := utils.NewCollationModel(TestExtractCollation_collation, runeComparator, padSpace)
+	model.Provenance = utils.NewProvenance(conn.Version(), metadata.ID)
+	StampProvenance(t, model.Provenance)
+	if TestExtractCollation_ignorables {
+		model.Ignorables, err = extractor.CollationIgnorables(NewContext(t, conn), conn, TestExtractCollation_collation, charset, runeComparator, runeToWeight)
+		require.NoError(t, err)
+		t.Logf("found %d ignorable runes", len(model.Ignorables))
 	}
+	require.NoError(t, model.Save(TestExtractCollation_model))
+	require.NoError(t, utils.RegisteredExtractionHooks().BeforeCodegen(model))
 
 	// Write the output to a file
-	file, err := os.OpenFile(TestExtractCollation_file, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0644)
+	options := utils.RuneComparatorGoFileOptions{Ignorables: model.Ignorables}
+	path := WriteArtifact(t, TestExtractCollation_file, []byte(utils.RuneComparatorToGoFileWithOptions(runeComparator, TestExtractCollation_collation, padSpace, options)))
+	WriteArtifact(t, TestExtractCollation_reverseFile, []byte(utils.RuneComparatorReverseToGoFile(runeComparator, TestExtractCollation_collation, WriteArtifact_codegen)))
+	// Dolt loads the same weights from a binary table, so that both projects are fed from a single extraction
+	WriteArtifact(t, TestExtractCollation_doltFile, utils.RuneComparatorToDoltFile(runeComparator, TestExtractCollation_collation))
+	expansions, err := extractor.CollationExpansions(TestExtractCollation_collation, runeToWeight)
 	require.NoError(t, err)
-	defer file.Close()
-	_, err = file.WriteString(utils.RuneComparatorToGoFile(runeComparator, TestExtractCollation_collation))
+	t.Logf("found %d expansions", len(expansions))
+	if len(expansions) > 0 {
+		contents, err := utils.ExpansionsToGoFile(runeComparator, TestExtractCollation_collation, expansions, WriteArtifact_codegen)
+		require.NoError(t, err)
+		WriteArtifact(t, TestExtractCollation_expansionsFile, []byte(contents))
+	}
+	if TestExtractCollation_caseFolding {
+		caseMappings := CharacterSetCaseMappings(t, conn, charset, rangeMap, utils.NewUTF8Iter(), nil)
+		WriteArtifact(t, TestExtractCollation_caseFoldingFile, []byte(utils.CaseFoldingToGoFile(TestExtractCollation_collation, utils.CaseFolding(runeComparator, caseMappings), WriteArtifact_codegen)))
+	}
+	if TestExtractCollation_accentStripping {
+		caseMappings := CharacterSetCaseMappings(t, conn, charset, rangeMap, utils.NewUTF8Iter(), nil)
+		var sensitive *utils.RuneComparator
+		if name := utils.AccentSensitiveCollation(TestExtractCollation_collation); name != "" {
+			if sensitiveModel, err := utils.LoadModel("./" + name + ".model.json"); err == nil {
+				sensitive, err = sensitiveModel.RuneComparator()
+				require.NoError(t, err)
+			}
+		}
+		stripping := utils.AccentStripping(runeComparator, sensitive, caseMappings)
+		WriteArtifact(t, TestExtractCollation_accentStrippingFile, []byte(utils.AccentStrippingToGoFile(TestExtractCollation_collation, stripping, WriteArtifact_codegen)))
+	}
+	if TestExtractCollation_testSamples > 0 {
+		samples, err := extractor.CollationSamples(NewContext(t, conn), conn, TestExtractCollation_collation, charset, runeComparator, TestExtractCollation_testSamples, 1)
+		require.NoError(t, err)
+		WriteArtifact(t, TestExtractCollation_testFile, []byte(utils.RuneComparatorTestToGoFile(TestExtractCollation_collation, samples, WriteArtifact_codegen)))
+	}
+	WriteArtifact(t, TestExtractCollation_metadataFile, []byte(utils.CollationMetadataToGoFile(metadata, WriteArtifact_codegen)))
+	WriteArtifact(t, TestExtractCollation_provenanceFile, []byte(utils.ProvenanceToGoFile(TestExtractCollation_collation, model.Provenance, WriteArtifact_codegen)))
+
+	// Record how the collation was extracted
+	manifest, err = utils.LoadManifest(TestExtractCollation_manifest)
+	require.NoError(t, err)
+	manifest.Set(utils.ManifestEntry{
+		Name:     TestExtractCollation_collation,
+		Kind:     utils.ManifestKindCollation,
+		File:     path,
+		Strategy: profile.Strategy,
+		Base:     profile.Base,
+		Model:    TestExtractCollation_model,
+		Unicode:  unicodeVersion,
+		Metadata: &metadata,
+	})
+	require.NoError(t, manifest.Save(TestExtractCollation_manifest))
+	require.NoError(t, checkpointer.Remove())
+}
+
+// CollationToRuneComparator is part of the implementation of TestExtractCollation, which inserts every rune that is
+// valid in the character set into a RuneComparator. Runes are compared using their weights when they're available, and
+// using STRCMP otherwise. Only the runes returned by the given iterator are inserted.
+//
+// The given map takes a rune as an input and returns the weight, which is represented as a byte slice. MySQL encodes
+// weights as binary strings, and they cannot be converted to unsigned integers due to their length (which can be over
+// the 8 byte limit of a 64-bit integer). The map may be seeded with the weights of a related collation, in which case
+// the weights of the seeded runes are not queried (other than a sample for verification). All weights that are found
+// during extraction are added to the map. The checkpointer may be nil.
+func CollationToRuneComparator(t *testing.T, conn *utils.Connection, collation string, charset string, iter *utils.UTF8Iter, rangeMap *utils.RangeMap, runeToWeight map[rune][]byte, checkpointer *utils.Checkpointer) *utils.RuneComparator {
+	runeComparator, err := extractor.CollationToRuneComparator(NewContext(t, conn), conn, collation, charset, iter, rangeMap, runeToWeight,
+		TestExtractCollation_weightCacheSample, t.Logf, checkpointer)
 	require.NoError(t, err)
-	err = file.Sync()
+	return runeComparator
+}
+
+// CollationPadSpace is part of the implementation of TestExtractCollation, which returns whether the collation is PAD
+// SPACE (trailing spaces are insignificant) rather than NO PAD (trailing spaces are significant). This is determined by
+// comparing strings that differ only in their trailing spaces, and is checked against the collation's reported pad
+// attribute on servers that report it.
+func CollationPadSpace(t *testing.T, conn *utils.Connection, collation string, charset string) bool {
+	padSpace, err := extractor.CollationPadSpace(NewContext(t, conn), conn, collation, charset)
 	require.NoError(t, err)
+	return padSpace
 }
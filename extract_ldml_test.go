@@ -0,0 +1,87 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+const (
+	// A character set definition file from MySQL's share/charsets directory, such as latin1.xml or Index.xml
+	TestExtractLDML_definitions = "/usr/share/mysql/charsets/latin1.xml"
+	TestExtractLDML_collation   = "latin1_swedish_ci"
+	// Collations defined by tailoring rules are applied to the model of their base collation, which was previously
+	// saved by TestExtractCollation. This is unused for collations defined by a map of sort weights.
+	TestExtractLDML_baseModel = "./utf8mb4_unicode_ci.model.json"
+	TestExtractLDML_file      = "./" + TestExtractLDML_collation + "_ldml.go"
+	// A file previously generated by TestExtractCollation for the same collation, which the definition is compared
+	// against. An empty path skips the comparison.
+	TestExtractLDML_compare = ""
+)
+
+// TestExtractLDML creates a Go file for embedding into GMS from the collation definition files that MySQL ships,
+// rather than from a live server. Simple collations assign a sort weight to each byte of their character set, while
+// tailored collations apply LDML rules on top of a base collation. The result may be compared against a file that was
+// extracted from a server, which verifies that the definition matches the server's behavior.
+func TestExtractLDML(t *testing.T) {
+	file, err := utils.LoadLDMLFile(TestExtractLDML_definitions)
+	require.NoError(t, err)
+	charset, collation, ok := file.Collation(TestExtractLDML_collation)
+	require.True(t, ok, "`%s` does not define `%s`", TestExtractLDML_definitions, TestExtractLDML_collation)
+
+	var runeComparator *utils.RuneComparator
+	// All simple collations are PAD SPACE, while tailored collations inherit the padding of their base collation
+	padSpace := true
+	if collation.Map != "" {
+		runeComparator, err = charset.SimpleRuneComparator(collation)
+		require.NoError(t, err)
+	} else {
+		model, err := utils.LoadModel(TestExtractLDML_baseModel)
+		require.NoError(t, err)
+		base, err := model.RuneComparator()
+		require.NoError(t, err)
+		runeComparator, err = utils.ApplyLDMLRules(base, collation.Rules)
+		require.NoError(t, err)
+		padSpace = model.PadSpace
+	}
+	contents := utils.RuneComparatorToGoFileWithOptions(runeComparator, TestExtractLDML_collation, padSpace, utils.RuneComparatorGoFileOptions{Codegen: WriteArtifact_codegen})
+
+	if TestExtractLDML_compare != "" {
+		extractedContents, err := utils.ReadArtifact(TestExtractLDML_compare)
+		require.NoError(t, err)
+		extracted, err := utils.ParseRuneComparatorGoFile(string(extractedContents))
+		require.NoError(t, err)
+		defined, err := utils.ParseRuneComparatorGoFile(contents)
+		require.NoError(t, err)
+		assert.Equal(t, extracted.PadSpace(), defined.PadSpace(), "the padding does not match")
+		mismatches := 0
+		for r := rune(0); r <= utf8.MaxRune && mismatches < 100; r++ {
+			if extracted.Weight(r) != defined.Weight(r) {
+				mismatches++
+				assert.Fail(t, "weights do not match", "rune `%s` (%d) has the weight %d on the server, but %d in the definition",
+					string(r), r, extracted.Weight(r), defined.Weight(r))
+			}
+		}
+	}
+
+	// Write the output to a file
+	WriteArtifact(t, TestExtractLDML_file, []byte(contents))
+}
@@ -0,0 +1,94 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/collation-extractor/extractor"
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+const (
+	TestExtractSupplementaryPlanes_user     = "root"
+	TestExtractSupplementaryPlanes_password = "password"
+	TestExtractSupplementaryPlanes_host     = "localhost"
+	TestExtractSupplementaryPlanes_port     = 3306
+	TestExtractSupplementaryPlanes_charset  = "utf16"
+	TestExtractSupplementaryPlanes_existing = "./" + TestExtractSupplementaryPlanes_charset + ".go.txt"
+	TestExtractSupplementaryPlanes_file     = "./" + TestExtractSupplementaryPlanes_charset + "_supplementary.go"
+)
+
+// TestExtractSupplementaryPlanes extracts only the supplementary planes (U+10000 and above) of a character set, and
+// merges them into a file that was previously created by TestExtractCharacterSet. New Unicode versions mostly add
+// characters to the supplementary planes, so this skips re-extracting the Basic Multilingual Plane. Any supplementary
+// codepoint from the existing file must have the same encoding in the new extraction, otherwise this fails, as it
+// means that the BMP may have changed as well (and a full extraction should be done instead).
+func TestExtractSupplementaryPlanes(t *testing.T) {
+	existingFile, err := utils.ReadArtifact(TestExtractSupplementaryPlanes_existing)
+	require.NoError(t, err)
+	existingRangeMap, existingCaseMappings, err := utils.ParseRangeMapGoFile(string(existingFile))
+	require.NoError(t, err)
+
+	conn, err := utils.NewConnection(TestExtractSupplementaryPlanes_user, TestExtractSupplementaryPlanes_password, TestExtractSupplementaryPlanes_host, TestExtractSupplementaryPlanes_port)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// The extraction relies on the replacement being present to detect unmappable runes, so we copy it from the
+	// existing tree. Most character sets replace with '?', while others use 0x1A or a full-width question mark.
+	replacement, _, err := extractor.CharacterSetReplacement(NewContext(t, conn), conn, TestExtractSupplementaryPlanes_charset)
+	require.NoError(t, err)
+	tree := existingRangeMap.Tree()
+	supplementaryTree := utils.NewCharacterSetEncodingTree()
+	replacementNode := tree
+	for _, val := range replacement {
+		replacementNode = replacementNode.Child(val)
+	}
+	if replacementData := replacementNode.Data(); replacementData != nil {
+		supplementaryNode := supplementaryTree
+		for _, val := range replacement {
+			supplementaryNode = supplementaryNode.AddChild(val)
+		}
+		supplementaryNode.SetData(replacementData)
+	}
+	CharacterSetToEncodingTree(t, conn, TestExtractSupplementaryPlanes_charset, utils.NewSupplementaryUTF8Iter(), supplementaryTree)
+	require.NoError(t, tree.Merge(supplementaryTree))
+	rangeMap := EncodingTreeToRangeMap(t, tree)
+
+	// Case conversions from the BMP are kept, while the supplementary conversions are replaced by the new extraction
+	caseMappings := filterBasicMultilingualPlane(existingCaseMappings)
+	caseMappings.Merge(CharacterSetCaseMappings(t, conn, TestExtractSupplementaryPlanes_charset, rangeMap, utils.NewSupplementaryUTF8Iter(), nil))
+
+	// Write the output to a file
+	WriteArtifact(t, TestExtractSupplementaryPlanes_file, []byte(utils.RangeMapToGoFileWithOptions(rangeMap, caseMappings, TestExtractSupplementaryPlanes_charset, WriteArtifact_codegen)))
+}
+
+// filterBasicMultilingualPlane returns only the conversions whose source rune is within the Basic Multilingual Plane.
+func filterBasicMultilingualPlane(caseMappings utils.CaseMappings) utils.CaseMappings {
+	filtered := utils.NewCaseMappings()
+	for r, upper := range caseMappings.ToUpper {
+		if r < utils.SupplementaryPlaneStart {
+			filtered.ToUpper[r] = upper
+		}
+	}
+	for r, lower := range caseMappings.ToLower {
+		if r < utils.SupplementaryPlaneStart {
+			filtered.ToLower[r] = lower
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,98 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/collation-extractor/extractor"
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+const (
+	TestExtractWeightString_user      = "root"
+	TestExtractWeightString_password  = "password"
+	TestExtractWeightString_host      = "localhost"
+	TestExtractWeightString_port      = 3306
+	TestExtractWeightString_collation = "utf16_unicode_ci"
+	TestExtractWeightString_samples   = 10000
+	TestExtractWeightString_file      = "./" + TestExtractWeightString_collation + "_weight_string.go"
+	// Contractions are found by probing every sequence of these runes, up to the given length. Empty runes skip the
+	// probing, which is fine for collations that are known to not have any contractions.
+	TestExtractWeightString_contractionRunes  = utils.DefaultContractionRunes
+	TestExtractWeightString_contractionLength = utils.DefaultContractionLength
+)
+
+// TestExtractWeightString creates a Go file for embedding into GMS. It contains the data necessary to implement the
+// WEIGHT_STRING function for the specified collation, along with a SortKey function that computes it, which differs from the relative weights created by
+// TestExtractCollation, as those weights do not match the server's output. The padding behavior is determined from the
+// server, and the data is verified by comparing the server's output for random strings against the computed output.
+// Contractions that contain runes other than the probed runes will fail verification, as they are not detected.
+func TestExtractWeightString(t *testing.T) {
+	charset := strings.Split(TestExtractWeightString_collation, "_")[0]
+	conn, err := utils.NewConnection(TestExtractWeightString_user, TestExtractWeightString_password, TestExtractWeightString_host, TestExtractWeightString_port)
+	require.NoError(t, err)
+	defer conn.Close()
+	EnableQueryCache(t, conn)
+	rangeMap := CharacterSetToRangeMap(t, conn, charset, nil)
+	ctx := NewContext(t, conn)
+	ws, err := extractor.CollationToWeightString(ctx, conn, TestExtractWeightString_collation, charset, utils.NewUTF8Iter(), rangeMap, t.Logf)
+	require.NoError(t, err)
+	candidates := utils.ContractionCandidates([]rune(TestExtractWeightString_contractionRunes), TestExtractWeightString_contractionLength)
+	require.NoError(t, extractor.CollationContractions(ctx, conn, TestExtractWeightString_collation, charset, ws, candidates, t.Logf))
+	t.Logf("found %d contractions", len(ws.Contractions))
+	validRunes := make([]rune, 0, len(ws.Weights))
+	for r := range ws.Weights {
+		validRunes = append(validRunes, r)
+	}
+	sort.Slice(validRunes, func(i, j int) bool {
+		return validRunes[i] < validRunes[j]
+	})
+
+	// Verify the computed weight strings against random strings, which may be truncated or padded
+	random := rand.New(rand.NewSource(0))
+	for i := 0; i < TestExtractWeightString_samples; i++ {
+		runes := make([]rune, random.Intn(8)+1)
+		for j := range runes {
+			runes[j] = validRunes[random.Intn(len(validRunes))]
+		}
+		charLength := random.Intn(10)
+		expected, err := extractor.CollationWeightString(ctx, conn, TestExtractWeightString_collation, charset, string(runes), charLength)
+		require.NoError(t, err)
+		actual, ok := ws.Compute(string(runes), charLength)
+		if assert.True(t, ok) {
+			assert.True(t, bytes.Equal(expected, actual), "runes: %v, char length: %d\nexpected: %X\nactual:   %X",
+				runes, charLength, expected, actual)
+		}
+	}
+
+	// No character should contribute more bytes than the SORTLEN, unless the SORTLEN is zero
+	sortlen, err := extractor.CollationSortLength(ctx, conn, TestExtractWeightString_collation)
+	require.NoError(t, err)
+	t.Logf("SORTLEN: %d, max weight length: %d", sortlen, ws.MaxWeightLength())
+	if sortlen > 0 {
+		assert.LessOrEqual(t, ws.MaxWeightLength(), sortlen)
+	}
+
+	// Write the output to a file
+	WriteArtifact(t, TestExtractWeightString_file, []byte(utils.WeightStringToGoFile(ws, TestExtractWeightString_collation, WriteArtifact_codegen)))
+}
@@ -0,0 +1,160 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extractor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+// BinaryCharacterSet is the pseudo character set of byte strings, whose bytes are never converted.
+const BinaryCharacterSet = "binary"
+
+// binaryVerificationSeed is the seed of the runes that BinaryCharacterSetToRangeMap verifies, so that repeated
+// extractions issue the same queries.
+const binaryVerificationSeed = 2022
+
+// binaryVerificationRunes is the number of runes that BinaryCharacterSetToRangeMap verifies.
+const binaryVerificationRunes = 4096
+
+// ErrNotByteOrder is returned by CollationToRuneComparatorBinary when the server does not sort the runes of a collation
+// by the bytes of their encodings, in which case the collation must be extracted using another strategy.
+var ErrNotByteOrder = errors.New("collation does not sort by byte order")
+
+// BinaryCharacterSetToRangeMap returns the RangeMap of the `binary` character set without converting every rune. The
+// server does not convert the bytes of a rune when casting to `binary`, so the RangeMap is the identity mapping of UTF8
+// (see utils.IdentityRangeMap), and only a sample of runes are verified against the server.
+func BinaryCharacterSetToRangeMap(ctx context.Context, conn utils.Queryable, logf Logf) (*utils.RangeMap, error) {
+	hooks := utils.RegisteredExtractionHooks()
+	if err := hooks.BeforeCharacterSet(conn, BinaryCharacterSet); err != nil {
+		return nil, err
+	}
+	rangeMap, err := utils.IdentityRangeMap()
+	if err != nil {
+		return nil, err
+	}
+	runes := uniqueRunes(SampleRunes(rand.New(rand.NewSource(binaryVerificationSeed)), binaryVerificationRunes))
+	encodings, err := serverEncodings(ctx, conn, BinaryCharacterSet, runes)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range runes {
+		if expected := []byte(string(r)); !bytes.Equal(encodings[r], expected) {
+			return nil, fmt.Errorf("`%s` encoded U+%04X as %X rather than its UTF8 encoding %X",
+				BinaryCharacterSet, r, encodings[r], expected)
+		}
+	}
+	logf("%s: verified %d runes against the identity mapping", BinaryCharacterSet, len(runes))
+	if err = hooks.AfterCharacterSet(conn, BinaryCharacterSet, rangeMap); err != nil {
+		return nil, err
+	}
+	return rangeMap, nil
+}
+
+// CollationToRuneComparatorBinary constructs a RuneComparator from a collation that sorts by the bytes of each rune's
+// encoding, such as `binary` and the `_bin` collations (see utils.ExtractionStrategyBinary). The runes are ordered
+// using the RangeMap rather than the server, and then adjacent runes are compared using STRCMP: every pair whose
+// codepoints descend (as that is where a server comparing codepoints rather than bytes would disagree), along with
+// utils.BinaryVerificationPairs pairs spread evenly across the order. Returns an error wrapping ErrNotByteOrder when
+// the server disagrees with any of the compared runes.
+func CollationToRuneComparatorBinary(ctx context.Context, conn utils.Queryable, collation string, charset string, iter *utils.UTF8Iter, rangeMap *utils.RangeMap, logf Logf) (*utils.RuneComparator, error) {
+	qb := conn.Builder()
+	hooks := utils.RegisteredExtractionHooks()
+	type encodedRune struct {
+		r        rune
+		encoding []byte
+	}
+	var runes []encodedRune
+	for r, ok := iter.Next(); ok; r, ok = iter.Next() {
+		// Ensure that this rune is a valid character in the character set, as we only want to process valid runes
+		encoding, ok := rangeMap.Encode([]byte(string(r)))
+		if !ok {
+			continue
+		}
+		runes = append(runes, encodedRune{r: r, encoding: encoding})
+	}
+	// Runes with the same encoding keep their sequential order, as the RuneComparator expects them in that order
+	sort.SliceStable(runes, func(i, j int) bool {
+		return bytes.Compare(runes[i].encoding, runes[j].encoding) < 0
+	})
+	var order [][]rune
+	for i, er := range runes {
+		if err := hooks.CollationRune(conn, collation, er.r, nil); err != nil {
+			return nil, err
+		}
+		if i > 0 && bytes.Equal(runes[i-1].encoding, er.encoding) {
+			order[len(order)-1] = append(order[len(order)-1], er.r)
+		} else {
+			order = append(order, []rune{er.r})
+		}
+	}
+	if len(order) < 2 {
+		return utils.NewRuneComparatorFromOrder(order), nil
+	}
+
+	// Each verified pair is the first rune of a row along with the first rune of the next row
+	pairs := utils.BinaryVerificationPairs
+	if pairs > len(order)-1 {
+		pairs = len(order) - 1
+	}
+	verified := make(map[int]struct{})
+	for i := 0; i < pairs; i++ {
+		verified[i*(len(order)-1)/pairs] = struct{}{}
+	}
+	verified[len(order)-2] = struct{}{}
+	for row := 0; row < len(order)-1; row++ {
+		if order[row][0] > order[row+1][0] {
+			verified[row] = struct{}{}
+		}
+	}
+	rows := make([]int, 0, len(verified))
+	for row := range verified {
+		rows = append(rows, row)
+	}
+	sort.Ints(rows)
+	for start := 0; start < len(rows); start += utils.CharacterSetBatchSize {
+		batch := rows[start:]
+		if len(batch) > utils.CharacterSetBatchSize {
+			batch = batch[:utils.CharacterSetBatchSize]
+		}
+		exprs := make([]string, len(batch))
+		for i, row := range batch {
+			exprs[i] = qb.Call("STRCMP", qb.InCollation([]byte(string(order[row][0])), charset, collation),
+				qb.InCollation([]byte(string(order[row+1][0])), charset, collation))
+		}
+		sqlOutputs, err := conn.QueryValuesContext(ctx, qb.Select(exprs...))
+		if err != nil {
+			return nil, err
+		}
+		if len(sqlOutputs) != len(batch) {
+			return nil, fmt.Errorf("compared %d runes, but %d values were returned", len(batch), len(sqlOutputs))
+		}
+		for i, row := range batch {
+			if string(sqlOutputs[i]) != "-1" {
+				l, r := order[row][0], order[row+1][0]
+				return nil, fmt.Errorf("%w: `%s` returned %s for STRCMP of '%s' (%d) and '%s' (%d)",
+					ErrNotByteOrder, collation, string(sqlOutputs[i]), string(l), l, string(r), r)
+			}
+		}
+	}
+	logf("%s: verified the byte order of %d runes using %d pairs", collation, len(runes), len(rows))
+	return utils.NewRuneComparatorFromOrder(order), nil
+}
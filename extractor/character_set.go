@@ -0,0 +1,473 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package extractor contains the extraction logic that is shared by the test drivers in the root directory, the command
+// line tool, and any other tool that embeds the extractor. Functions return errors rather than failing a test, so that
+// they may be called from anywhere. ExtractCharset, ExtractCollation, and the other Extract functions are the simplest
+// entry points, while the remaining functions expose each stage of the extraction.
+package extractor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+// Logf receives progress reports and other informational messages, such as testing.T's Logf or log.Printf.
+type Logf func(format string, args ...interface{})
+
+// CharacterSetOptions control which runes are converted while extracting a character set.
+type CharacterSetOptions struct {
+	// SkipUnassigned only converts the runes that are assigned according to the unicode package's tables, which is
+	// ignored for the character sets that encode every rune (see CharacterSetQuirks.EncodesUnassigned).
+	SkipUnassigned bool
+	// VerifyUnassigned also converts every rune that SkipUnassigned skipped once the others have been extracted, which
+	// takes as long as not skipping them. Any that are encoded are added to the RangeMap and logged, so that the result
+	// is the same as a full scan, and so that the character set may be known to be safe to skip.
+	VerifyUnassigned bool
+}
+
+// characterSetIter returns the iterator over the runes of the given character set that the options select.
+func characterSetIter(charset string, options CharacterSetOptions, logf Logf) *utils.UTF8Iter {
+	quirks := utils.CharacterSetQuirksFor(charset)
+	iter := utils.NewUTF8Iter()
+	if quirks.Planes != nil {
+		iter.SetPlanes(quirks.Planes...)
+	}
+	if options.SkipUnassigned {
+		if quirks.EncodesUnassigned {
+			logf("%s: encodes unassigned runes, so they are not skipped", charset)
+		} else {
+			iter.SkipUnassigned()
+		}
+	}
+	return iter
+}
+
+// verifyUnassigned converts every rune that characterSetIter skipped as unassigned, adding those that are encoded to
+// the tree. Does nothing unless the options both skip and verify the unassigned runes.
+func verifyUnassigned(ctx context.Context, conn utils.Queryable, charset string, options CharacterSetOptions, tree *utils.CharacterSetEncodingTree, logf Logf) error {
+	quirks := utils.CharacterSetQuirksFor(charset)
+	if !options.SkipUnassigned || !options.VerifyUnassigned || quirks.EncodesUnassigned {
+		return nil
+	}
+	iter := utils.NewUTF8Iter()
+	if quirks.Planes != nil {
+		iter.SetPlanes(quirks.Planes...)
+	}
+	iter.SkipAssigned()
+	// The encoded runes are found using a separate tree, as only the new encodings are reported
+	unassigned := utils.NewCharacterSetEncodingTree()
+	if err := unassigned.Merge(tree); err != nil {
+		return err
+	}
+	if err := CharacterSetToEncodingTree(ctx, conn, charset, iter, unassigned, logf, nil); err != nil {
+		return err
+	}
+	rangeMap, err := utils.RangeMapFromTreeWithOptions(unassigned, quirks.RangeMapOptions)
+	if err != nil {
+		return err
+	}
+	var encoded []rune
+	iter.Reset()
+	for r, ok := iter.Next(); ok; r, ok = iter.Next() {
+		if _, ok = rangeMap.Encode([]byte(string(r))); ok {
+			encoded = append(encoded, r)
+		}
+	}
+	if len(encoded) == 0 {
+		logf("%s: verified that every unassigned rune is unmappable", charset)
+		return nil
+	}
+	logf("%s: %d unassigned runes are encoded (starting with U+%04X), so unassigned runes must not be skipped", charset, len(encoded), encoded[0])
+	return tree.Merge(unassigned)
+}
+
+// CharacterSetToRangeMap constructs a RangeMap from a character set, iterating over every rune. This validates the
+// RangeMap before returning, so no further validation is necessary. The extraction resumes from the checkpointer's
+// Checkpoint when one exists, and is skipped entirely when the Checkpoint was saved during a later stage.
+func CharacterSetToRangeMap(ctx context.Context, conn utils.Queryable, charset string, logf Logf, checkpointer *utils.Checkpointer) (*utils.RangeMap, error) {
+	return CharacterSetToRangeMapWithOptions(ctx, conn, charset, CharacterSetOptions{}, logf, checkpointer)
+}
+
+// CharacterSetToRangeMapWithOptions is the same as CharacterSetToRangeMap, but only converts the runes that the options
+// select. The unassigned runes are verified after resuming from a Checkpoint, but are not themselves checkpointed. The
+// `binary` character set is not converted rune by rune (see BinaryCharacterSetToRangeMap).
+func CharacterSetToRangeMapWithOptions(ctx context.Context, conn utils.Queryable, charset string, options CharacterSetOptions, logf Logf, checkpointer *utils.Checkpointer) (*utils.RangeMap, error) {
+	if charset == BinaryCharacterSet {
+		return BinaryCharacterSetToRangeMap(ctx, conn, logf)
+	}
+	hooks := utils.RegisteredExtractionHooks()
+	if err := hooks.BeforeCharacterSet(conn, charset); err != nil {
+		return nil, err
+	}
+	checkpoint, err := checkpointer.Resume(charset, utils.CheckpointStageEncodings)
+	if err != nil {
+		return nil, err
+	}
+	iter := characterSetIter(charset, options, logf)
+	charsetToGoString := utils.NewCharacterSetEncodingTree()
+	if checkpoint != nil {
+		if charsetToGoString, err = checkpoint.EncodingTree(); err != nil {
+			return nil, err
+		}
+		iter.SetStart(checkpoint.LastRune + 1)
+		logf("%s: resuming the encodings from U+%04X", charset, checkpoint.LastRune+1)
+	}
+	if checkpoint == nil || checkpoint.Stage == utils.CheckpointStageEncodings {
+		if err = CharacterSetToEncodingTree(ctx, conn, charset, iter, charsetToGoString, logf, checkpointer); err != nil {
+			return nil, err
+		}
+		if err = verifyUnassigned(ctx, conn, charset, options, charsetToGoString, logf); err != nil {
+			return nil, err
+		}
+	}
+	rangeMap, err := utils.RangeMapFromTreeWithOptions(charsetToGoString, utils.CharacterSetQuirksFor(charset).RangeMapOptions)
+	if err != nil {
+		return nil, err
+	}
+	if err = hooks.AfterCharacterSet(conn, charset, rangeMap); err != nil {
+		return nil, err
+	}
+	return rangeMap, nil
+}
+
+// CharacterSets returns the name of every character set that the server offers, as reported by SHOW CHARACTER SET.
+// The names are sorted.
+func CharacterSets(ctx context.Context, conn utils.Queryable) ([]string, error) {
+	values, err := conn.QueryColumnContext(ctx, "SHOW CHARACTER SET;", "Charset")
+	if err != nil {
+		return nil, err
+	}
+	charsets := make([]string, len(values))
+	for i, value := range values {
+		charsets[i] = string(value)
+	}
+	sort.Strings(charsets)
+	return charsets, nil
+}
+
+// CharacterSetCollations returns the name of every collation of the given character set, sorted by name.
+func CharacterSetCollations(ctx context.Context, conn utils.Queryable, charset string) ([]string, error) {
+	qb := conn.Builder()
+	values, err := conn.QueryColumnContext(ctx, fmt.Sprintf("SHOW COLLATION WHERE Charset = %s;", qb.String(charset)), "Collation")
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("character set `%s` does not have any collations", charset)
+	}
+	collations := make([]string, len(values))
+	for i, value := range values {
+		collations[i] = string(value)
+	}
+	sort.Strings(collations)
+	return collations, nil
+}
+
+// CharacterSetMetadata returns the properties of the given character set that are reported by SHOW CHARACTER SET, which
+// are read from information_schema.CHARACTER_SETS so that the columns may be selected by name. The minimum length is
+// not reported by the server, and is set by CharacterSetMetadata.ApplyRangeMap.
+func CharacterSetMetadata(ctx context.Context, conn utils.Queryable, charset string) (utils.CharacterSetMetadata, error) {
+	qb := conn.Builder()
+	values, err := conn.QueryValuesContext(ctx, fmt.Sprintf("SELECT DEFAULT_COLLATE_NAME, DESCRIPTION, MAXLEN "+
+		"FROM information_schema.CHARACTER_SETS WHERE CHARACTER_SET_NAME = %s;", qb.String(charset)))
+	if err != nil {
+		return utils.CharacterSetMetadata{}, err
+	}
+	metadata := utils.CharacterSetMetadata{
+		Name:             charset,
+		DefaultCollation: string(values[0]),
+		Description:      string(values[1]),
+		Flavor:           qb.Flavor(),
+	}
+	if metadata.MaxLength, err = strconv.Atoi(string(values[2])); err != nil {
+		return utils.CharacterSetMetadata{}, fmt.Errorf("character set `%s` returned an invalid MAXLEN `%s`", charset, string(values[2]))
+	}
+	return metadata, nil
+}
+
+// CharacterSetToEncodingTree adds every rune from the iterator that is valid in the character set to the given tree.
+// The tree's input encoding is the character set's encoding, while the data is Go's UTF8 encoding. Runes are converted
+// in batches, with each rune as a separate column of a single query, so that the number of round trips is a fraction of
+// the number of runes. The tree is saved to the checkpointer after each batch when a Checkpoint is due.
+func CharacterSetToEncodingTree(ctx context.Context, conn utils.Queryable, charset string, iter *utils.UTF8Iter, charsetToGoString *utils.CharacterSetEncodingTree, logf Logf, checkpointer *utils.Checkpointer) error {
+	qb := conn.Builder()
+	quirks, err := characterSetQuirks(ctx, conn, charset, logf)
+	if err != nil {
+		return err
+	}
+	hooks := utils.RegisteredExtractionHooks()
+	progress := utils.NewProgress(charset, iter.Total(), logf)
+	batch := make([]rune, 0, utils.CharacterSetBatchSize)
+	exprs := make([]string, 0, utils.CharacterSetBatchSize)
+	// Converts every rune in the batch, then adds each rune to the tree in sequential order
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		exprs = exprs[:0]
+		for _, r := range batch {
+			// Converting a rune to a string will encode the rune (which is an int32) as a sequence of valid UTF8 bytes.
+			// We convert the bytes to a hexadecimal to ensure that Go's exact byte representation is being given to
+			// MySQL. This also allows us to bypass escape rules.
+			exprs = append(exprs, qb.AsBinary(qb.InCharset([]byte(string(r)), charset)))
+		}
+		sqlOutputs, err := conn.QueryValuesContext(ctx, qb.Select(exprs...))
+		if err != nil {
+			return err
+		}
+		if len(sqlOutputs) != len(batch) {
+			return fmt.Errorf("converted %d runes, but %d values were returned", len(batch), len(sqlOutputs))
+		}
+		for i, r := range batch {
+			if err = addToEncodingTree(ctx, conn, charset, quirks, hooks, r, sqlOutputs[i], charsetToGoString); err != nil {
+				return err
+			}
+		}
+		if checkpointer.Due() {
+			if err = checkpointer.Save(utils.NewEncodingsCheckpoint(charset, batch[len(batch)-1], charsetToGoString)); err != nil {
+				return err
+			}
+		}
+		batch = batch[:0]
+		return nil
+	}
+	for r, ok := iter.Next(); ok; r, ok = iter.Next() {
+		progress.Step(r)
+		batch = append(batch, r)
+		if len(batch) == utils.CharacterSetBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return flush()
+}
+
+// replacementProbes are runes within the supplementary private use planes, which do not have a conversion to any
+// character set other than the Unicode character sets.
+var replacementProbes = []rune{0xF0000, 0x10FFFD}
+
+// CharacterSetReplacement returns the encoding that the server returns for runes without a conversion to the character
+// set, along with the rune that the encoding decodes to. This is '?' for most character sets, yet some use another
+// substitution character, such as 0x1A or a full-width question mark. When the probed runes convert to different
+// encodings, the character set contains every rune, so '?' is returned.
+func CharacterSetReplacement(ctx context.Context, conn utils.Queryable, charset string) ([]byte, rune, error) {
+	qb := conn.Builder()
+	exprs := make([]string, len(replacementProbes))
+	for i, r := range replacementProbes {
+		exprs[i] = qb.AsBinary(qb.InCharset([]byte(string(r)), charset))
+	}
+	sqlOutputs, err := conn.QueryValuesContext(ctx, qb.Select(exprs...))
+	if err != nil {
+		return nil, 0, err
+	}
+	replacement := sqlOutputs[0]
+	for _, sqlOutput := range sqlOutputs[1:] {
+		if len(replacement) == 0 || !bytes.Equal(replacement, sqlOutput) {
+			return []byte("?"), '?', nil
+		}
+	}
+	decoded, err := conn.QueryContext(ctx, qb.Select(qb.AsBinary(qb.Convert(qb.Convert(qb.Literal("binary", replacement), charset), "utf8mb4"))))
+	if err != nil {
+		return nil, 0, err
+	}
+	replacementRune, size := utf8.DecodeRune(decoded)
+	if size == 0 || size != len(decoded) || replacementRune == utf8.RuneError {
+		return nil, 0, fmt.Errorf("the replacement 0x%X of `%s` decodes to 0x%X, which is not a single rune", replacement, charset, decoded)
+	}
+	return replacement, replacementRune, nil
+}
+
+// characterSetQuirks returns the quirks of the character set using its discovered replacement.
+func characterSetQuirks(ctx context.Context, conn utils.Queryable, charset string, logf Logf) (utils.CharacterSetQuirks, error) {
+	replacement, replacementRune, err := CharacterSetReplacement(ctx, conn, charset)
+	if err != nil {
+		return utils.CharacterSetQuirks{}, err
+	}
+	if replacementRune != '?' {
+		logf("%s: runes without a conversion are replaced by 0x%X (U+%04X)", charset, replacement, replacementRune)
+	}
+	return utils.CharacterSetQuirksWithReplacement(charset, replacement, replacementRune), nil
+}
+
+// addToEncodingTree adds the given rune to the tree using the server's output, unless the output means that the rune
+// does not exist in the character set. As the detection depends on the runes that have already been added, runes must
+// be added in sequential order. When multiple runes encode to the same codepoint, the tree keeps the rune that the
+// server decodes the codepoint to, and the other runes are found by CharacterSetLossyMappings.
+func addToEncodingTree(ctx context.Context, conn utils.Queryable, charset string, quirks utils.CharacterSetQuirks, hooks utils.ExtractionHooks, r rune, sqlOutput []byte, charsetToGoString *utils.CharacterSetEncodingTree) error {
+	if err := hooks.CharacterSetRune(conn, charset, r, sqlOutput); err != nil {
+		return err
+	}
+	// MySQL returns a replacement when the rune doesn't have a conversion to the target character set, which is
+	// detected differently depending on the character set
+	unmappable, err := quirks.Unmappable(r, sqlOutput, charsetToGoString)
+	if err != nil {
+		return err
+	}
+	if unmappable {
+		return nil
+	}
+
+	// We add the output to the tree for converting from the character set to Go's encoding
+	toGoStr := charsetToGoString
+	for _, byteVal := range sqlOutput {
+		toGoStr = toGoStr.AddChild(byteVal)
+	}
+	if toGoStr.SetData([]byte(string(r))) {
+		return nil
+	}
+	if toGoStr.Data() == nil {
+		return fmt.Errorf("rune `%s` (%d) encoded to 0x%X, which conflicts with a previously extracted encoding", string(r), r, sqlOutput)
+	}
+	// Another rune has the same encoding, so the encoding is lossy for one of the runes. The server's decoding of the
+	// codepoint determines which rune is preferred.
+	qb := conn.Builder()
+	decoded, err := conn.QueryContext(ctx, qb.Select(qb.AsBinary(qb.Convert(qb.Convert(qb.Literal("binary", sqlOutput), charset), "utf8mb4"))))
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(decoded, []byte(string(r))) {
+		toGoStr.ReplaceData(decoded)
+	}
+	return nil
+}
+
+// CharacterSetLossyMappings returns every rune from the iterator that the server encodes to a codepoint of the
+// character set, yet the codepoint decodes to a different (preferred) rune. Such runes are not contained in the
+// RangeMap, as it may only map each codepoint to a single rune. The runes are converted using the same queries as
+// CharacterSetToEncodingTree, so the results are read from the QueryCache when it is enabled.
+func CharacterSetLossyMappings(ctx context.Context, conn utils.Queryable, charset string, rangeMap *utils.RangeMap, iter *utils.UTF8Iter, logf Logf) ([]utils.LossyMapping, error) {
+	qb := conn.Builder()
+	quirks, err := characterSetQuirks(ctx, conn, charset, logf)
+	if err != nil {
+		return nil, err
+	}
+	tree := rangeMap.Tree()
+	progress := utils.NewProgress(charset, iter.Total(), logf)
+	var mappings []utils.LossyMapping
+	batch := make([]rune, 0, utils.CharacterSetBatchSize)
+	exprs := make([]string, 0, utils.CharacterSetBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		exprs = exprs[:0]
+		for _, r := range batch {
+			exprs = append(exprs, qb.AsBinary(qb.InCharset([]byte(string(r)), charset)))
+		}
+		sqlOutputs, err := conn.QueryValuesContext(ctx, qb.Select(exprs...))
+		if err != nil {
+			return err
+		}
+		if len(sqlOutputs) != len(batch) {
+			return fmt.Errorf("converted %d runes, but %d values were returned", len(batch), len(sqlOutputs))
+		}
+		for i, r := range batch {
+			if encoded, ok := rangeMap.Encode([]byte(string(r))); ok && bytes.Equal(encoded, sqlOutputs[i]) {
+				continue
+			}
+			unmappable, err := quirks.Unmappable(r, sqlOutputs[i], tree)
+			if err != nil {
+				return err
+			}
+			if unmappable {
+				continue
+			}
+			decoded, ok := rangeMap.Decode(sqlOutputs[i])
+			if !ok {
+				return fmt.Errorf("rune `%s` (%d) encoded to 0x%X, which is not a codepoint of the RangeMap", string(r), r, sqlOutputs[i])
+			}
+			preferred, _ := utf8.DecodeRune(decoded)
+			mappings = append(mappings, utils.LossyMapping{
+				Rune:      r,
+				Encoding:  sqlOutputs[i],
+				Preferred: preferred,
+			})
+		}
+		batch = batch[:0]
+		return nil
+	}
+	for r, ok := iter.Next(); ok; r, ok = iter.Next() {
+		progress.Step(r)
+		batch = append(batch, r)
+		if len(batch) == utils.CharacterSetBatchSize {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return mappings, nil
+}
+
+// CharacterSetCaseMappings returns the uppercase and lowercase conversions for all runes from the iterator that are
+// valid in the character set. A conversion may produce any number of runes, which are returned as strings. The
+// conversions resume from the checkpointer's Checkpoint when one exists, and are periodically saved to the checkpointer.
+func CharacterSetCaseMappings(ctx context.Context, conn utils.Queryable, charset string, rangeMap *utils.RangeMap, iter *utils.UTF8Iter, checkpointer *utils.Checkpointer) (utils.CaseMappings, error) {
+	qb := conn.Builder()
+	checkpoint, err := checkpointer.Resume(charset, utils.CheckpointStageCaseConversions)
+	if err != nil {
+		return utils.CaseMappings{}, err
+	}
+	caseMappings := utils.NewCaseMappings()
+	if checkpoint != nil {
+		caseMappings.Merge(checkpoint.CaseMappings)
+		iter.SetStart(checkpoint.LastRune + 1)
+	}
+	// Returns the string that the case conversion function returns for the given rune
+	convert := func(function string, r rune) (string, error) {
+		sqlOutput, err := conn.QueryContext(ctx, qb.Select(qb.AsBinary(qb.Convert(qb.Call(function, qb.InCharset([]byte(string(r)), charset)), "utf8mb4"))))
+		if err != nil {
+			return "", err
+		}
+		// The output is usually a single rune, yet some conversions (such as 'ß' to "SS") produce multiple runes
+		if len(sqlOutput) == 0 || !utf8.Valid(sqlOutput) {
+			return "", fmt.Errorf("%s of rune `%s` (%d) returned 0x%X, which is not valid UTF8", function, string(r), r, sqlOutput)
+		}
+		return string(sqlOutput), nil
+	}
+	// Grab the uppercase and lowercase conversions (case conversions may be asymmetric, so we have to test them individually)
+	for r, ok := iter.Next(); ok; r, ok = iter.Next() {
+		// Ensure that this rune is a valid character in the character set, as we only want to check valid runes
+		_, ok := rangeMap.Encode([]byte(string(r)))
+		if !ok {
+			continue
+		}
+
+		upper, err := convert("UPPER", r)
+		if err != nil {
+			return utils.CaseMappings{}, err
+		}
+		lower, err := convert("LOWER", r)
+		if err != nil {
+			return utils.CaseMappings{}, err
+		}
+		caseMappings.Add(r, upper, lower)
+		if checkpointer.Due() {
+			if err = checkpointer.Save(utils.NewCaseConversionsCheckpoint(charset, r, rangeMap, caseMappings)); err != nil {
+				return utils.CaseMappings{}, err
+			}
+		}
+	}
+	return caseMappings, nil
+}
@@ -0,0 +1,359 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extractor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+// CollationToRuneComparator inserts every rune from the iterator that is valid in the character set into a
+// RuneComparator. The weight of every rune is retrieved first, so that all weighted runes may be sorted client-side
+// without any comparisons against the server. STRCMP is only used to place the remaining runes without a weight, which
+// are a small fraction of most collations.
+//
+// The given map takes a rune as an input and returns the weight, which is represented as a byte slice. MySQL encodes
+// weights as binary strings, and they cannot be converted to unsigned integers due to their length (which can be over
+// the 8 byte limit of a 64-bit integer). The map may be seeded with the weights of a related collation, in which case
+// the weights of the seeded runes are not queried, other than one of every seedSample runes, which is verified against
+// the server. A seedSample of zero trusts every seeded weight. All weights that are found during extraction are added to
+// the map. The insertion resumes from the checkpointer's Checkpoint when one exists, and is periodically saved to the
+// checkpointer.
+func CollationToRuneComparator(ctx context.Context, conn utils.Queryable, collation string, charset string, iter *utils.UTF8Iter, rangeMap *utils.RangeMap, runeToWeight map[rune][]byte, seedSample int, logf Logf, checkpointer *utils.Checkpointer) (*utils.RuneComparator, error) {
+	hooks := utils.RegisteredExtractionHooks()
+	seededRunes := 0
+	var weighted []rune
+	var weightless []rune
+	runeComparator := utils.NewRuneComparator()
+	checkpoint, err := checkpointer.Resume(collation, utils.CheckpointStageWeights)
+	if err != nil {
+		return nil, err
+	}
+	if checkpoint != nil {
+		for r, weight := range checkpoint.RuneToWeight {
+			runeToWeight[r] = weight
+		}
+		seededRunes = checkpoint.SeededRunes
+		weightless = checkpoint.Weightless
+		switch checkpoint.Stage {
+		case utils.CheckpointStageWeights:
+			for _, row := range checkpoint.Weights {
+				weighted = append(weighted, row...)
+			}
+			iter.SetStart(checkpoint.LastRune + 1)
+			logf("%s: resuming the weights from U+%04X", collation, checkpoint.LastRune+1)
+		case utils.CheckpointStageWeightless:
+			runeComparator = checkpoint.RuneComparator()
+			logf("%s: resuming with %d weightless runes remaining", collation, len(weightless))
+		default:
+			return nil, fmt.Errorf("`%s` cannot resume from the `%s` stage", collation, checkpoint.Stage)
+		}
+	}
+
+	if checkpoint == nil || checkpoint.Stage == utils.CheckpointStageWeights {
+		progress := utils.NewProgress(collation, iter.Total(), logf)
+		for r, ok := iter.Next(); ok; r, ok = iter.Next() {
+			progress.Step(r)
+			// Ensure that this rune is a valid character in the character set, as we only want to process valid runes
+			_, ok := rangeMap.Encode([]byte(string(r)))
+			if !ok {
+				continue
+			}
+			weight, err := collationRuneWeight(ctx, conn, collation, charset, r, runeToWeight, seedSample, &seededRunes)
+			if err != nil {
+				return nil, err
+			}
+			if err = hooks.CollationRune(conn, collation, r, weight); err != nil {
+				return nil, err
+			}
+			if len(weight) > 0 {
+				weighted = append(weighted, r)
+			} else {
+				weightless = append(weightless, r)
+			}
+			if checkpointer.Due() {
+				partial := utils.NewRuneComparator()
+				partial.InsertWeighted(weighted, runeToWeight)
+				if err = checkpointer.Save(utils.NewWeightsCheckpoint(collation, r, partial, runeToWeight, weightless, seededRunes)); err != nil {
+					return nil, err
+				}
+			}
+		}
+		runeComparator.InsertWeighted(weighted, runeToWeight)
+		logf("%s: sorted %d weighted runes, comparing %d weightless runes", collation, len(weighted), len(weightless))
+	}
+
+	// The comparator cannot return an error, so the first error is recorded and returned once the insertion completes
+	var comparatorErr error
+	runeComparator.SetComparator(strcmpComparator(ctx, conn, collation, charset, runeToWeight, &comparatorErr))
+
+	// The runes without a weight are given in sequential order, so they're inserted in that order. The RuneComparator
+	// may be inconsistent after a comparison has failed, so a Checkpoint is never saved afterward.
+	for i, r := range weightless {
+		runeComparator.Insert(r)
+		if comparatorErr != nil {
+			return nil, comparatorErr
+		}
+		if checkpointer.Due() {
+			if err = checkpointer.Save(utils.NewWeightlessCheckpoint(collation, runeComparator, runeToWeight, weightless[i+1:], seededRunes)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return runeComparator, nil
+}
+
+// CollationDeltaToRuneComparator inserts every rune from the iterator that is valid in the character set, yet is not
+// already contained within the given base RuneComparator, into the base. This allows a previously extracted collation
+// to be extended with the codepoints that a newer server added, without extracting every rune again. The weights of the
+// new runes are added to the map, which should contain the weights of the base's runes (such as from the collation's
+// weight cache), as base runes without a weight are compared using STRCMP.
+func CollationDeltaToRuneComparator(ctx context.Context, conn utils.Queryable, collation string, charset string, iter *utils.UTF8Iter, rangeMap *utils.RangeMap, base *utils.RuneComparator, runeToWeight map[rune][]byte, logf Logf) (*utils.RuneComparator, error) {
+	hooks := utils.RegisteredExtractionHooks()
+	var comparatorErr error
+	base.SetComparator(strcmpComparator(ctx, conn, collation, charset, runeToWeight, &comparatorErr))
+	existing := base.Runes()
+	inserted := 0
+	seededRunes := 0
+	progress := utils.NewProgress(collation, iter.Total(), logf)
+	for r, ok := iter.Next(); ok; r, ok = iter.Next() {
+		progress.Step(r)
+		if _, ok := existing[r]; ok {
+			continue
+		}
+		if _, ok := rangeMap.Encode([]byte(string(r))); !ok {
+			continue
+		}
+		weight, err := collationRuneWeight(ctx, conn, collation, charset, r, runeToWeight, 0, &seededRunes)
+		if err != nil {
+			return nil, err
+		}
+		if err = hooks.CollationRune(conn, collation, r, weight); err != nil {
+			return nil, err
+		}
+		base.Insert(r)
+		if comparatorErr != nil {
+			return nil, comparatorErr
+		}
+		inserted++
+	}
+	logf("%s: inserted %d runes that were missing from the base", collation, inserted)
+	return base, nil
+}
+
+// CollationTailoringToRuneComparator returns the RuneComparator of a collation that orders most runes the same as the
+// given base RuneComparator, such as a language tailoring (`utf8mb4_sv_0900_ai_ci`) of the collation that the base was
+// extracted from (`utf8mb4_0900_ai_ci`). The runes that the collation orders differently are removed from the base (see
+// RuneComparator.RemoveMisordered), and are then inserted again along with the runes that are missing from the base
+// (see CollationDeltaToRuneComparator), so most runes are only compared once. The base is modified. The weight map
+// must not contain the weights of the base's collation, as they differ from the weights of this collation.
+func CollationTailoringToRuneComparator(ctx context.Context, conn utils.Queryable, collation string, charset string, iter *utils.UTF8Iter, rangeMap *utils.RangeMap, base *utils.RuneComparator, runeToWeight map[rune][]byte, logf Logf) (*utils.RuneComparator, error) {
+	var comparatorErr error
+	base.SetComparator(strcmpComparator(ctx, conn, collation, charset, runeToWeight, &comparatorErr))
+	removed := base.RemoveMisordered()
+	if comparatorErr != nil {
+		return nil, comparatorErr
+	}
+	logf("%s: removed %d runes that are ordered differently than the base", collation, len(removed))
+	return CollationDeltaToRuneComparator(ctx, conn, collation, charset, iter, rangeMap, base, runeToWeight, logf)
+}
+
+// strcmpComparator returns a comparator of the relative sorting order of any two given runes. Runes are compared using
+// their weights when both are in the map, and using STRCMP otherwise. The comparator cannot return an error, so the
+// first error is written to the given error, and every later comparison returns 0.
+func strcmpComparator(ctx context.Context, conn utils.Queryable, collation string, charset string, runeToWeight map[rune][]byte, comparatorErr *error) func(l rune, r rune) int {
+	qb := conn.Builder()
+	hooks := utils.RegisteredExtractionHooks()
+	return func(l rune, r rune) int {
+		// If we have the weights for both of the runes then we may use those for comparison
+		lWeight, lOk := runeToWeight[l]
+		rWeight, rOk := runeToWeight[r]
+		if lOk && rOk {
+			return bytes.Compare(lWeight, rWeight)
+		}
+		if *comparatorErr != nil {
+			return 0
+		}
+
+		// Without the weights, we can resort to using MySQL's STRCMP to get a comparison. Check collationRuneWeight
+		// for details on our byte slices and hex encoding usage here.
+		if err := hooks.CollationStrcmp(conn, collation, l, r); err != nil {
+			*comparatorErr = err
+			return 0
+		}
+		lAsBytes := []byte(string(l))
+		rAsBytes := []byte(string(r))
+		sqlOutput, err := conn.QueryContext(ctx, qb.Select(qb.Call("STRCMP",
+			qb.InCollation(lAsBytes, charset, collation), qb.InCollation(rAsBytes, charset, collation))))
+		if err != nil {
+			*comparatorErr = err
+			return 0
+		}
+		switch string(sqlOutput) {
+		case "1":
+			return 1
+		case "-1":
+			return -1
+		case "0":
+			// If they're comparably equivalent and one has a weight, we can assign the other the same weight to
+			// potentially save time on future comparisons
+			if lOk && !rOk {
+				runeToWeight[r] = lWeight
+			} else if !lOk && rOk {
+				runeToWeight[l] = rWeight
+			}
+			return 0
+		default:
+			*comparatorErr = fmt.Errorf("unknown output `%s` for comparing '%s' (%d) and '%s' (%d)", string(sqlOutput), string(l), l, string(r), r)
+			return 0
+		}
+	}
+}
+
+// collationRuneWeight returns the weight of the given rune, which is empty when the server does not return a weight.
+// Seeded weights are only verified against the server for one of every seedSample seeded runes, and are otherwise
+// trusted. Weights that are found are added to the map.
+func collationRuneWeight(ctx context.Context, conn utils.Queryable, collation string, charset string, r rune, runeToWeight map[rune][]byte, seedSample int, seededRunes *int) ([]byte, error) {
+	qb := conn.Builder()
+	seededWeight, seeded := runeToWeight[r]
+	if seeded {
+		*seededRunes++
+		if seedSample <= 0 || *seededRunes%seedSample != 0 {
+			return seededWeight, nil
+		}
+	}
+
+	// Converting a rune to a string will encode the rune (which is an int32) as a sequence of valid UTF8 bytes.
+	// We then convert it to a byte slice to pass to the hex encoder.
+	rAsBytes := []byte(string(r))
+	// We convert the string to a hexadecimal to ensure that Go's exact byte representation is being given to MySQL.
+	// This also allows us to bypass escape rules.
+	sqlOutput, err := conn.QueryContext(ctx, qb.Select(qb.Call("HEX", qb.WeightString(qb.InCollation(rAsBytes, charset, collation), 0))))
+	if err != nil {
+		return nil, err
+	}
+	// The output is the sorting weight of the character. Lower weights sort before higher weights. The weight
+	// is encoded as a binary string. WEIGHT_STRING is explicitly defined as not guaranteeing a stable output
+	// between versions, but it will always return the proper relative weights if a weight is returned. For an
+	// unknown reason, some characters do not return a weight, but still have a sort order, and such cases are
+	// handled during comparisons.
+	if seeded && !bytes.Equal(seededWeight, sqlOutput) {
+		return nil, fmt.Errorf("seeded weight for rune %d does not match the server", r)
+	}
+	if len(sqlOutput) > 0 {
+		runeToWeight[r] = sqlOutput
+	}
+	return sqlOutput, nil
+}
+
+// CollationPadSpace returns whether the collation is PAD SPACE (trailing spaces are insignificant) rather than NO PAD
+// (trailing spaces are significant). This is determined by comparing strings that differ only in their trailing spaces,
+// and is checked against the collation's reported pad attribute on servers that report it.
+func CollationPadSpace(ctx context.Context, conn utils.Queryable, collation string, charset string) (bool, error) {
+	qb := conn.Builder()
+	strcmp := func(l string, r string) (string, error) {
+		sqlOutput, err := conn.QueryContext(ctx, qb.Select(qb.Call("STRCMP",
+			qb.InCollation([]byte(l), charset, collation), qb.InCollation([]byte(r), charset, collation))))
+		return string(sqlOutput), err
+	}
+	cmp, err := strcmp("a", "a ")
+	if err != nil {
+		return false, err
+	}
+	padSpace := cmp == "0"
+	// Every comparison that differs only in trailing spaces must agree with the first
+	for _, pair := range [][2]string{{"a ", "a"}, {"a", "a   "}, {"", " "}, {"a b", "a b "}} {
+		cmp, err = strcmp(pair[0], pair[1])
+		if err != nil {
+			return false, err
+		}
+		if padSpace && cmp != "0" {
+			return false, fmt.Errorf("`%s` is PAD SPACE, yet `%s` and `%s` differ", collation, pair[0], pair[1])
+		} else if !padSpace && cmp == "0" {
+			return false, fmt.Errorf("`%s` is NO PAD, yet `%s` and `%s` are equal", collation, pair[0], pair[1])
+		}
+	}
+	// MariaDB does not report the pad attribute, as its NO PAD collations are distinguished by their names instead
+	if qb.IsMariaDB() && collation != "binary" && padSpace == utils.IsNoPadCollationName(collation) {
+		return false, fmt.Errorf("`%s` has a name that does not match its padding (PAD SPACE: %t)", collation, padSpace)
+	}
+	// MySQL 8.0 added the pad attribute to information_schema
+	if !qb.IsMariaDB() && qb.AtLeast(8, 0, 0) {
+		sqlOutput, err := conn.QueryContext(ctx, fmt.Sprintf("SELECT PAD_ATTRIBUTE FROM information_schema.COLLATIONS WHERE COLLATION_NAME = %s;",
+			qb.String(collation)))
+		if err != nil {
+			return false, err
+		}
+		if padSpace != (string(sqlOutput) == "PAD SPACE") {
+			return false, fmt.Errorf("`%s` has the pad attribute `%s`", collation, string(sqlOutput))
+		}
+	}
+	return padSpace, nil
+}
+
+// CollationExists returns whether the server offers the given collation. Older versions lack many of the collations
+// of newer versions (such as the UCA 9.0.0 collations before MySQL 8.0), so this is checked before extracting from
+// several versions.
+func CollationExists(ctx context.Context, conn utils.Queryable, collation string) (bool, error) {
+	qb := conn.Builder()
+	query := fmt.Sprintf("SELECT COUNT(*) FROM information_schema.COLLATIONS WHERE COLLATION_NAME = %s;", qb.String(collation))
+	if qb.IsMariaDB() && qb.AtLeast(10, 10, 0) {
+		query = fmt.Sprintf("SELECT COUNT(*) FROM information_schema.COLLATION_CHARACTER_SET_APPLICABILITY "+
+			"WHERE FULL_COLLATION_NAME = %s;", qb.String(collation))
+	}
+	sqlOutput, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return false, err
+	}
+	return string(sqlOutput) != "0", nil
+}
+
+// CollationMetadata returns the properties of the given collation that are reported by SHOW COLLATION, which are read
+// from information_schema.COLLATIONS so that the columns may be selected by name. MariaDB 10.10 lists the collations
+// that are shared by multiple character sets (such as `uca1400_ai_ci`) under their short name, so the character set,
+// ID, and default of each full name are read from information_schema.COLLATION_CHARACTER_SET_APPLICABILITY instead.
+func CollationMetadata(ctx context.Context, conn utils.Queryable, collation string) (utils.CollationMetadata, error) {
+	qb := conn.Builder()
+	query := fmt.Sprintf("SELECT CHARACTER_SET_NAME, ID, IS_DEFAULT, IS_COMPILED, SORTLEN "+
+		"FROM information_schema.COLLATIONS WHERE COLLATION_NAME = %s;", qb.String(collation))
+	if qb.IsMariaDB() && qb.AtLeast(10, 10, 0) {
+		query = fmt.Sprintf("SELECT a.CHARACTER_SET_NAME, a.ID, a.IS_DEFAULT, c.IS_COMPILED, c.SORTLEN "+
+			"FROM information_schema.COLLATION_CHARACTER_SET_APPLICABILITY a "+
+			"JOIN information_schema.COLLATIONS c ON a.COLLATION_NAME = c.COLLATION_NAME WHERE a.FULL_COLLATION_NAME = %s;",
+			qb.String(collation))
+	}
+	values, err := conn.QueryValuesContext(ctx, query)
+	if err != nil {
+		return utils.CollationMetadata{}, err
+	}
+	metadata := utils.CollationMetadata{
+		Name:       collation,
+		Charset:    string(values[0]),
+		IsDefault:  string(values[2]) == "Yes",
+		IsCompiled: string(values[3]) == "Yes",
+		Flavor:     qb.Flavor(),
+	}
+	if metadata.ID, err = strconv.Atoi(string(values[1])); err != nil {
+		return utils.CollationMetadata{}, fmt.Errorf("collation `%s` returned an invalid ID `%s`", collation, string(values[1]))
+	}
+	if metadata.SortLength, err = strconv.Atoi(string(values[4])); err != nil {
+		return utils.CollationMetadata{}, fmt.Errorf("collation `%s` returned an invalid SORTLEN `%s`", collation, string(values[4]))
+	}
+	return metadata, nil
+}
@@ -0,0 +1,158 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extractor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+const (
+	// orderByDatabase is the database that contains the temporary table of ExtractionStrategyOrderBy. The connection
+	// does not select a database, and a temporary table must belong to one.
+	orderByDatabase = "collation_extractor"
+	// orderByTable is the temporary table of ExtractionStrategyOrderBy.
+	orderByTable = orderByDatabase + ".runes"
+)
+
+// orderByRow is a single row returned by the sorting query of ExtractionStrategyOrderBy.
+type orderByRow struct {
+	r      rune
+	weight []byte
+}
+
+// CollationToRuneComparatorOrderBy creates the same RuneComparator as CollationToRuneComparator, but sorts the runes on
+// the server rather than inserting them one at a time. Every rune from the iterator that is valid in the character set
+// is inserted into a temporary table, which is then sorted using a single query. Adjacent runes are equal when their
+// weights are equal, while STRCMP is only used when either rune does not have a weight. This replaces the O(n log n)
+// STRCMP queries with O(n / batch size) queries. All weights are added to the given map.
+func CollationToRuneComparatorOrderBy(ctx context.Context, conn utils.Queryable, collation string, charset string, iter *utils.UTF8Iter, rangeMap *utils.RangeMap, runeToWeight map[rune][]byte, logf Logf) (_ *utils.RuneComparator, err error) {
+	qb := conn.Builder()
+	hooks := utils.RegisteredExtractionHooks()
+	// Temporary tables are dropped when the session ends, but they're dropped here so that the connection may be reused
+	if err = conn.ExecContext(ctx, fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s;", qb.Identifier(orderByDatabase))); err != nil {
+		return nil, err
+	}
+	if err = conn.ExecContext(ctx, fmt.Sprintf("DROP TEMPORARY TABLE IF EXISTS %s;", qb.Identifier(orderByTable))); err != nil {
+		return nil, err
+	}
+	if err = conn.ExecContext(ctx, fmt.Sprintf("CREATE TEMPORARY TABLE %s (r INT PRIMARY KEY, str VARCHAR(1) CHARACTER SET %s COLLATE %s NOT NULL);",
+		qb.Identifier(orderByTable), qb.Identifier(charset), qb.Identifier(collation))); err != nil {
+		return nil, err
+	}
+	defer func() {
+		// The table is dropped even when the extraction was cancelled, so the cleanup does not use the context
+		nerr := conn.ExecContext(context.Background(), fmt.Sprintf("DROP TEMPORARY TABLE IF EXISTS %s;", qb.Identifier(orderByTable)))
+		if err == nil {
+			err = nerr
+		}
+	}()
+
+	// Insert every valid rune in batches
+	progress := utils.NewProgress(collation, iter.Total(), logf)
+	batch := make([][]string, 0, utils.OrderByBatchSize)
+	insertedRunes := 0
+	for r, ok := iter.Next(); ok; r, ok = iter.Next() {
+		progress.Step(r)
+		// Ensure that this rune is a valid character in the character set, as we only want to process valid runes
+		if _, ok := rangeMap.Encode([]byte(string(r))); !ok {
+			continue
+		}
+		batch = append(batch, []string{strconv.Itoa(int(r)), qb.InCharset([]byte(string(r)), charset)})
+		if len(batch) == utils.OrderByBatchSize {
+			if err = conn.ExecContext(ctx, qb.Insert(orderByTable, batch)); err != nil {
+				return nil, err
+			}
+			insertedRunes += len(batch)
+			batch = batch[:0]
+		}
+	}
+	if len(batch) > 0 {
+		if err = conn.ExecContext(ctx, qb.Insert(orderByTable, batch)); err != nil {
+			return nil, err
+		}
+		insertedRunes += len(batch)
+	}
+
+	// The rows are read before any other queries are issued, as the connection is busy until every row has been read.
+	// Equal runes are ordered by their codepoint, as the RuneComparator expects them in sequential order.
+	rows := make([]orderByRow, 0, insertedRunes)
+	err = conn.QueryRowsContext(ctx, fmt.Sprintf("SELECT r, HEX(WEIGHT_STRING(str)) FROM %s ORDER BY str, r;", qb.Identifier(orderByTable)),
+		func(values [][]byte) error {
+			r, err := strconv.Atoi(string(values[0]))
+			if err != nil {
+				return err
+			}
+			rows = append(rows, orderByRow{r: rune(r), weight: append([]byte(nil), values[1]...)})
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) != insertedRunes {
+		return nil, fmt.Errorf("inserted %d runes, but %d were returned", insertedRunes, len(rows))
+	}
+
+	var order [][]rune
+	for i, row := range rows {
+		if len(row.weight) > 0 {
+			runeToWeight[row.r] = row.weight
+		}
+		if err = hooks.CollationRune(conn, collation, row.r, runeToWeight[row.r]); err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			order = append(order, []rune{row.r})
+			continue
+		}
+		equal, err := orderByEqual(ctx, conn, collation, charset, rows[i-1], row)
+		if err != nil {
+			return nil, err
+		}
+		if equal {
+			order[len(order)-1] = append(order[len(order)-1], row.r)
+		} else {
+			order = append(order, []rune{row.r})
+		}
+	}
+	return utils.NewRuneComparatorFromOrder(order), nil
+}
+
+// orderByEqual returns whether the given adjacent rows of the sorting query are equal. Some runes do not return a
+// weight but still have a sort order (as described in CollationToRuneComparator), so STRCMP is used for those.
+func orderByEqual(ctx context.Context, conn utils.Queryable, collation string, charset string, l orderByRow, r orderByRow) (bool, error) {
+	if len(l.weight) > 0 && len(r.weight) > 0 {
+		return bytes.Equal(l.weight, r.weight), nil
+	}
+	qb := conn.Builder()
+	sqlOutput, err := conn.QueryContext(ctx, qb.Select(qb.Call("STRCMP",
+		qb.InCollation([]byte(string(l.r)), charset, collation), qb.InCollation([]byte(string(r.r)), charset, collation))))
+	if err != nil {
+		return false, err
+	}
+	switch string(sqlOutput) {
+	case "0":
+		return true, nil
+	case "-1":
+		return false, nil
+	default:
+		return false, fmt.Errorf("'%s' (%d) sorted before '%s' (%d), yet STRCMP returned `%s`",
+			string(l.r), l.r, string(r.r), r.r, string(sqlOutput))
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extractor
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+// CharacterSetSamples converts random codepoints of a character set to utf8mb4 and back again on the server, returning
+// the server's conversions for a companion test of the generated RangeMap. The codepoints are deterministic for a given
+// seed.
+func CharacterSetSamples(ctx context.Context, conn utils.Queryable, charset string, rangeMap *utils.RangeMap, samples int, seed int64) ([]utils.CharacterSetSample, error) {
+	qb := conn.Builder()
+	var codepoints [][]byte
+	iter := rangeMap.Tree().Iterator()
+	for inputEncoding, _, ok := iter.Next(); ok; inputEncoding, _, ok = iter.Next() {
+		codepoints = append(codepoints, inputEncoding)
+	}
+	if len(codepoints) == 0 {
+		return nil, fmt.Errorf("`%s` does not contain any codepoints", charset)
+	}
+
+	random := rand.New(rand.NewSource(seed))
+	characterSetSamples := make([]utils.CharacterSetSample, 0, samples)
+	for i := 0; i < samples; i++ {
+		codepoint := codepoints[random.Intn(len(codepoints))]
+		// The binary introducer allows the bytes to be interpreted as the character set without conversion
+		asCharset := qb.Convert(qb.Literal("binary", codepoint), charset)
+		decoded, err := conn.QueryContext(ctx, qb.Select(qb.AsBinary(qb.Convert(asCharset, "utf8mb4"))))
+		if err != nil {
+			return nil, err
+		}
+		reencoded, err := conn.QueryContext(ctx, qb.Select(qb.AsBinary(qb.Convert(qb.Convert(asCharset, "utf8mb4"), charset))))
+		if err != nil {
+			return nil, err
+		}
+		characterSetSamples = append(characterSetSamples, utils.CharacterSetSample{
+			Encoded:   codepoint,
+			Decoded:   decoded,
+			Reencoded: reencoded,
+		})
+	}
+	return characterSetSamples, nil
+}
+
+// CollationSamples compares random pairs of runes from the RuneComparator using STRCMP, returning the server's
+// comparisons for a companion test of the generated weights. Every other pair is taken from a group of runes that share
+// a weight (when the collation has any), as random pairs are rarely equal. The pairs are deterministic for a given seed.
+func CollationSamples(ctx context.Context, conn utils.Queryable, collation string, charset string, rc *utils.RuneComparator, samples int, seed int64) ([]utils.CollationSample, error) {
+	qb := conn.Builder()
+	runes := make([]rune, 0, rc.Len())
+	for r := range rc.Runes() {
+		runes = append(runes, r)
+	}
+	if len(runes) == 0 {
+		return nil, fmt.Errorf("`%s` does not contain any runes", collation)
+	}
+	// The runes are sorted so that the same seed always selects the same pairs
+	sort.Slice(runes, func(i, j int) bool {
+		return runes[i] < runes[j]
+	})
+	classes := rc.EquivalenceClasses()
+
+	random := rand.New(rand.NewSource(seed))
+	collationSamples := make([]utils.CollationSample, 0, samples)
+	for i := 0; i < samples; i++ {
+		l, r := runes[random.Intn(len(runes))], runes[random.Intn(len(runes))]
+		if i%2 == 1 && len(classes) > 0 {
+			class := classes[random.Intn(len(classes))]
+			l, r = class[random.Intn(len(class))], class[random.Intn(len(class))]
+		}
+		sqlOutput, err := conn.QueryContext(ctx, qb.Select(qb.Call("STRCMP",
+			qb.InCollation([]byte(string(l)), charset, collation), qb.InCollation([]byte(string(r)), charset, collation))))
+		if err != nil {
+			return nil, err
+		}
+		sample := utils.CollationSample{Left: l, Right: r}
+		switch string(sqlOutput) {
+		case "-1":
+			sample.Comparison = -1
+		case "0":
+			sample.Comparison = 0
+		case "1":
+			sample.Comparison = 1
+		default:
+			return nil, fmt.Errorf("unknown output `%s` for comparing '%s' (%d) and '%s' (%d)", string(sqlOutput), string(l), l, string(r), r)
+		}
+		collationSamples = append(collationSamples, sample)
+	}
+	return collationSamples, nil
+}
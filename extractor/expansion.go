@@ -0,0 +1,47 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extractor
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+// CollationExpansions returns the runes of the collation that expand to multiple collation elements, using the weights
+// that were gathered while extracting the collation (which are the hexadecimal WEIGHT_STRING output of each rune). This
+// does not query the server, as the weight of every rune is already known. Runes without a weight are skipped.
+func CollationExpansions(collation string, runeToWeight map[rune][]byte) (map[rune][]rune, error) {
+	weights := make(map[rune][]byte, len(runeToWeight))
+	for r, hexWeight := range runeToWeight {
+		weight, err := hex.DecodeString(string(hexWeight))
+		if err != nil {
+			return nil, fmt.Errorf("rune %d has the invalid weight `%s`", r, string(hexWeight))
+		}
+		weights[r] = weight
+	}
+	// Only the UCA 9.0.0 collations contain multiple levels, which are separated by two zero bytes
+	levels := 1
+	if aWeight, ok := weights['a']; ok && strings.Contains(collation, "_0900_") {
+		for i := 0; i+1 < len(aWeight); i += 2 {
+			if aWeight[i] == 0 && aWeight[i+1] == 0 {
+				levels++
+			}
+		}
+	}
+	return utils.FindExpansions(weights, levels), nil
+}
@@ -0,0 +1,134 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extractor
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+// The functions within this file are the entry points for embedding the extractor within other tools. They use the
+// defaults that the command line tool uses, do not save checkpoints, and discard progress reports. Every query uses the
+// context, so a cancelled extraction stops at its next query.
+
+// discardLogf is a Logf that discards every message.
+func discardLogf(string, ...interface{}) {}
+
+// Exists returns whether the server offers the given character set or collation, where the kind is either
+// utils.ManifestKindCharset or utils.ManifestKindCollation.
+func Exists(ctx context.Context, conn utils.Queryable, kind string, name string) (bool, error) {
+	if kind == utils.ManifestKindCollation {
+		return CollationExists(ctx, conn, name)
+	}
+	charsets, err := CharacterSets(ctx, conn)
+	if err != nil {
+		return false, err
+	}
+	for _, charset := range charsets {
+		if charset == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ExtractCharset returns the RangeMap of the given character set.
+func ExtractCharset(ctx context.Context, conn utils.Queryable, name string) (*utils.RangeMap, error) {
+	return CharacterSetToRangeMap(ctx, conn, name, discardLogf, nil)
+}
+
+// ExtractCaseMappings returns the uppercase and lowercase conversions of every rune within the given character set's
+// RangeMap.
+func ExtractCaseMappings(ctx context.Context, conn utils.Queryable, charset string, rangeMap *utils.RangeMap) (utils.CaseMappings, error) {
+	return CharacterSetCaseMappings(ctx, conn, charset, rangeMap, utils.NewUTF8Iter(), nil)
+}
+
+// ExtractCharsetModel returns the Model of the given character set, which contains both its encodings and its case
+// conversions.
+func ExtractCharsetModel(ctx context.Context, conn utils.Queryable, name string) (*utils.Model, error) {
+	rangeMap, err := ExtractCharset(ctx, conn, name)
+	if err != nil {
+		return nil, err
+	}
+	caseMappings, err := ExtractCaseMappings(ctx, conn, name, rangeMap)
+	if err != nil {
+		return nil, err
+	}
+	return utils.NewCharacterSetModel(name, rangeMap, caseMappings), nil
+}
+
+// ExtractCollation returns the Model of the given collation, using the strategy of its ExtractionProfile. The RangeMap
+// of the collation's character set is extracted when it is nil, so a RangeMap should be given when extracting multiple
+// collations of the same character set. Language tailorings are extracted using STRCMP, as no order of their base is
+// given, so CollationTailoringToRuneComparator should be called directly when the base has already been extracted.
+func ExtractCollation(ctx context.Context, conn utils.Queryable, name string, rangeMap *utils.RangeMap) (*utils.Model, error) {
+	// All collations start with the character set followed by an underscore
+	charset := strings.Split(name, "_")[0]
+	var err error
+	if rangeMap == nil {
+		if rangeMap, err = ExtractCharset(ctx, conn, charset); err != nil {
+			return nil, err
+		}
+	}
+	runeToWeight := make(map[rune][]byte)
+	var runeComparator *utils.RuneComparator
+	strategy := utils.SelectExtractionProfile(name).Strategy
+	if strategy == utils.ExtractionStrategyBinary {
+		// Collations named as binary that do not sort by byte order are extracted using STRCMP instead
+		runeComparator, err = CollationToRuneComparatorBinary(ctx, conn, name, charset, utils.NewUTF8Iter(), rangeMap, discardLogf)
+		if errors.Is(err, ErrNotByteOrder) {
+			strategy = utils.ExtractionStrategyStrcmp
+		}
+	}
+	switch strategy {
+	case utils.ExtractionStrategyBinary:
+	case utils.ExtractionStrategyOrderBy:
+		runeComparator, err = CollationToRuneComparatorOrderBy(ctx, conn, name, charset, utils.NewUTF8Iter(), rangeMap, runeToWeight, discardLogf)
+	default:
+		runeComparator, err = CollationToRuneComparator(ctx, conn, name, charset, utils.NewUTF8Iter(), rangeMap, runeToWeight, 0, discardLogf, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	padSpace, err := CollationPadSpace(ctx, conn, name, charset)
+	if err != nil {
+		return nil, err
+	}
+	return utils.NewCollationModel(name, runeComparator, padSpace), nil
+}
+
+// ExtractWeightString returns the WeightString of the given collation, including any contractions of the default
+// contraction runes. The RangeMap of the collation's character set is extracted when it is nil.
+func ExtractWeightString(ctx context.Context, conn utils.Queryable, collation string, rangeMap *utils.RangeMap) (*utils.WeightString, error) {
+	charset := strings.Split(collation, "_")[0]
+	var err error
+	if rangeMap == nil {
+		if rangeMap, err = ExtractCharset(ctx, conn, charset); err != nil {
+			return nil, err
+		}
+	}
+	ws, err := CollationToWeightString(ctx, conn, collation, charset, utils.NewUTF8Iter(), rangeMap, discardLogf)
+	if err != nil {
+		return nil, err
+	}
+	candidates := utils.ContractionCandidates([]rune(utils.DefaultContractionRunes), utils.DefaultContractionLength)
+	if err = CollationContractions(ctx, conn, collation, charset, ws, candidates, discardLogf); err != nil {
+		return nil, err
+	}
+	return ws, nil
+}
@@ -0,0 +1,415 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extractor
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+// newFakeServer returns a FakeServer with the `fake8` character set, which is ASCII along with a few Latin runes in the
+// upper half, where both `é` and `ê` encode to 0xE9 (so that the encoding is lossy for `ê`). The `fake8_general_ci`
+// collation compares case-insensitively and ignores the accents of the Latin runes.
+func newFakeServer(t *testing.T) *utils.FakeServer {
+	server, err := utils.NewFakeServer("8.0.31")
+	require.NoError(t, err)
+	encodings := make(map[rune][]byte)
+	for r := rune(0); r < 128; r++ {
+		encodings[r] = []byte{byte(r)}
+	}
+	encodings['À'] = []byte{0xC0}
+	encodings['à'] = []byte{0xE0}
+	encodings['é'] = []byte{0xE9}
+	encodings['ê'] = []byte{0xE9}
+	server.AddCharset(utils.FakeCharset{Name: "fake8", Encodings: encodings})
+	server.AddCollation(utils.FakeCollation{Name: "fake8_general_ci", Charset: "fake8", ID: 1000, PadSpace: true, IsDefault: true,
+		Weight: func(r rune) uint16 {
+			switch r {
+			case 'À', 'à':
+				return 'A'
+			case 'é', 'ê':
+				return 'E'
+			}
+			return uint16(unicode.ToUpper(r))
+		}})
+	return server
+}
+
+// fakeIter returns an iterator over the runes that the fake character set may contain, so that tests do not convert
+// every rune.
+func fakeIter() *utils.UTF8Iter {
+	iter := utils.NewUTF8Iter()
+	iter.SetRanges([][2]rune{{0, 0x2FF}})
+	return iter
+}
+
+func TestFakeCharacterSetToRangeMap(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeServer(t)
+	tree := utils.NewCharacterSetEncodingTree()
+	require.NoError(t, CharacterSetToEncodingTree(ctx, server, "fake8", fakeIter(), tree, discardLogf, nil))
+	rangeMap, err := utils.RangeMapFromTree(tree)
+	require.NoError(t, err)
+	assert.True(t, rangeMap.IsASCIICompatible())
+
+	for r, expected := range map[rune][]byte{'a': {'a'}, '?': {'?'}, 'À': {0xC0}, 'à': {0xE0}, 'é': {0xE9}} {
+		encoded, ok := rangeMap.Encode([]byte(string(r)))
+		assert.True(t, ok, "rune `%s`", string(r))
+		assert.Equal(t, expected, encoded, "rune `%s`", string(r))
+		decoded, ok := rangeMap.Decode(expected)
+		assert.True(t, ok, "rune `%s`", string(r))
+		assert.Equal(t, string(r), string(decoded))
+	}
+	// Runes that the server replaces with '?' are not within the RangeMap, along with the lossy rune
+	for _, r := range []rune{'ß', 'ê', 0x2FF} {
+		_, ok := rangeMap.Encode([]byte(string(r)))
+		assert.False(t, ok, "rune `%s`", string(r))
+	}
+	lossyMappings, err := CharacterSetLossyMappings(ctx, server, "fake8", rangeMap, fakeIter(), discardLogf)
+	require.NoError(t, err)
+	require.Len(t, lossyMappings, 1)
+	assert.Equal(t, 'ê', lossyMappings[0].Rune)
+
+	metadata, err := CharacterSetMetadata(ctx, server, "fake8")
+	require.NoError(t, err)
+	require.NoError(t, metadata.ApplyRangeMap(rangeMap))
+	assert.Equal(t, "fake8_general_ci", metadata.DefaultCollation)
+	assert.Equal(t, 1, metadata.MinLength)
+	assert.Equal(t, 1, metadata.MaxLength)
+
+	// The generated file is parsed back into the same mappings
+	caseMappings, err := CharacterSetCaseMappings(ctx, server, "fake8", rangeMap, fakeIter(), nil)
+	require.NoError(t, err)
+	parsed, parsedCaseMappings, err := utils.ParseRangeMapGoFile(utils.RangeMapToGoFile(rangeMap, caseMappings, "fake8"))
+	require.NoError(t, err)
+	iter := fakeIter()
+	for r, ok := iter.Next(); ok; r, ok = iter.Next() {
+		expected, expectedOk := rangeMap.Encode([]byte(string(r)))
+		actual, actualOk := parsed.Encode([]byte(string(r)))
+		require.Equal(t, expectedOk, actualOk, "rune `%s`", string(r))
+		require.Equal(t, expected, actual, "rune `%s`", string(r))
+	}
+	assert.Equal(t, "À", parsedCaseMappings.ToUpper['à'])
+}
+
+// valuesCounter counts the QueryValuesContext queries that are made through it.
+type valuesCounter struct {
+	utils.Queryable
+	count int
+}
+
+// QueryValuesContext implements the interface utils.Queryable.
+func (counter *valuesCounter) QueryValuesContext(ctx context.Context, query string) ([][]byte, error) {
+	counter.count++
+	return counter.Queryable.QueryValuesContext(ctx, query)
+}
+
+func TestFakeCharacterSetBatches(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeServer(t)
+	conn := &valuesCounter{Queryable: utils.NewFakeConnection(server)}
+	qb := conn.Builder()
+	// Two full batches followed by a partial batch, where every batch after the first contains unmappable runes
+	const runeCount = 2*utils.CharacterSetBatchSize + 37
+	iter := utils.NewUTF8Iter()
+	iter.SetRanges([][2]rune{{0, runeCount - 1}})
+	// The quirks are probed before any batch is converted, so their queries are not counted as batches
+	quirks, err := characterSetQuirks(ctx, conn, "fake8", discardLogf)
+	require.NoError(t, err)
+	probes := conn.count
+	batched := utils.NewCharacterSetEncodingTree()
+	require.NoError(t, CharacterSetToEncodingTree(ctx, conn, "fake8", iter, batched, discardLogf, nil))
+	assert.Equal(t, 2*probes+3, conn.count)
+
+	// Each rune is converted with its own statement, and added to the tree in the same order
+	hooks := utils.RegisteredExtractionHooks()
+	single := utils.NewCharacterSetEncodingTree()
+	unmappable := 0
+	for r := rune(0); r < runeCount; r++ {
+		sqlOutput, err := conn.QueryContext(ctx, qb.Select(qb.AsBinary(qb.InCharset([]byte(string(r)), "fake8"))))
+		require.NoError(t, err)
+		if r != '?' && string(sqlOutput) == "?" {
+			unmappable++
+		}
+		require.NoError(t, addToEncodingTree(ctx, conn, "fake8", quirks, hooks, r, sqlOutput, single))
+	}
+	assert.Greater(t, unmappable, utils.CharacterSetBatchSize)
+	assert.Empty(t, batched.Diff(single))
+
+	// The values of a batch are returned in the order of their expressions, including the unmappable runes
+	runes := []rune{'a', 'ß', 'é', 0x2FF, 'ê', 'Z'}
+	exprs := make([]string, len(runes))
+	for i, r := range runes {
+		exprs[i] = qb.AsBinary(qb.InCharset([]byte(string(r)), "fake8"))
+	}
+	values, err := conn.QueryValuesContext(ctx, qb.Select(exprs...))
+	require.NoError(t, err)
+	require.Len(t, values, len(runes))
+	for i, expr := range exprs {
+		value, err := conn.QueryContext(ctx, qb.Select(expr))
+		require.NoError(t, err)
+		assert.Equal(t, value, values[i], "rune `%s`", string(runes[i]))
+	}
+	assert.Equal(t, [][]byte{{'a'}, {'?'}, {0xE9}, {'?'}, {0xE9}, {'Z'}}, values)
+}
+
+func TestFakeCollationToRuneComparator(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeServer(t)
+	tree := utils.NewCharacterSetEncodingTree()
+	require.NoError(t, CharacterSetToEncodingTree(ctx, server, "fake8", fakeIter(), tree, discardLogf, nil))
+	rangeMap, err := utils.RangeMapFromTree(tree)
+	require.NoError(t, err)
+
+	runeComparator, err := CollationToRuneComparator(ctx, server, "fake8_general_ci", "fake8", fakeIter(), rangeMap,
+		make(map[rune][]byte), 0, discardLogf, nil)
+	require.NoError(t, err)
+	padSpace, err := CollationPadSpace(ctx, server, "fake8_general_ci", "fake8")
+	require.NoError(t, err)
+	assert.True(t, padSpace)
+
+	runeWeights, err := utils.ParseRuneComparatorGoFile(utils.RuneComparatorToGoFile(runeComparator, "fake8_general_ci", padSpace))
+	require.NoError(t, err)
+	assert.Equal(t, 0, runeWeights.Compare("a", "A"))
+	assert.Equal(t, 0, runeWeights.Compare("à", "A"))
+	assert.Equal(t, 0, runeWeights.Compare("é", "e"))
+	assert.Equal(t, -1, runeWeights.Compare("a", "b"))
+	assert.Equal(t, 1, runeWeights.Compare("é", "d"))
+	assert.Equal(t, 0, runeWeights.Compare("a", "a  "))
+
+	metadata, err := CollationMetadata(ctx, server, "fake8_general_ci")
+	require.NoError(t, err)
+	assert.Equal(t, "fake8", metadata.Charset)
+	assert.Equal(t, 1000, metadata.ID)
+	assert.True(t, metadata.IsDefault)
+	exists, err := Exists(ctx, server, utils.ManifestKindCollation, "fake8_bin")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	collations, err := CharacterSetCollations(ctx, server, "fake8")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fake8_general_ci"}, collations)
+}
+
+// weightStringCounter counts the WEIGHT_STRING queries that are made through it.
+type weightStringCounter struct {
+	*utils.FakeServer
+	count int
+}
+
+// QueryContext implements the interface utils.Queryable.
+func (counter *weightStringCounter) QueryContext(ctx context.Context, query string) ([]byte, error) {
+	if strings.Contains(query, "WEIGHT_STRING") {
+		counter.count++
+	}
+	return counter.FakeServer.QueryContext(ctx, query)
+}
+
+func TestFakeCollationTailoring(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeServer(t)
+	// The tailoring sorts `À` and `à` after every other rune, and separates `é` from `e` and `ê`
+	server.AddCollation(utils.FakeCollation{Name: "fake8_swedish_ci", Charset: "fake8", ID: 1001, PadSpace: true,
+		Weight: func(r rune) uint16 {
+			switch r {
+			case 'À', 'à':
+				return 0x100
+			case 'é':
+				return 0x101
+			case 'ê':
+				return 'E'
+			}
+			return uint16(unicode.ToUpper(r))
+		}})
+	rangeMap, err := CharacterSetToRangeMap(ctx, server, "fake8", discardLogf, nil)
+	require.NoError(t, err)
+	base, err := CollationToRuneComparator(ctx, server, "fake8_general_ci", "fake8", fakeIter(), rangeMap,
+		make(map[rune][]byte), 0, discardLogf, nil)
+	require.NoError(t, err)
+
+	// Only the runes that the tailoring moved (and their neighbors) have their weights retrieved
+	counter := &weightStringCounter{FakeServer: server}
+	tailored, err := CollationTailoringToRuneComparator(ctx, counter, "fake8_swedish_ci", "fake8", fakeIter(), rangeMap,
+		base, make(map[rune][]byte), discardLogf)
+	require.NoError(t, err)
+	tailoredCount := counter.count
+	counter.count = 0
+	expected, err := CollationToRuneComparator(ctx, counter, "fake8_swedish_ci", "fake8", fakeIter(), rangeMap,
+		make(map[rune][]byte), 0, discardLogf, nil)
+	require.NoError(t, err)
+	assert.Equal(t, utils.NewCollationModel("fake8_swedish_ci", expected, true).Weights,
+		utils.NewCollationModel("fake8_swedish_ci", tailored, true).Weights)
+	assert.NotZero(t, tailoredCount)
+	assert.Less(t, tailoredCount*4, counter.count)
+}
+
+func TestFakeCollationIgnorables(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeServer(t)
+	server.AddCollation(utils.FakeCollation{Name: "fake8_ignorable_ci", Charset: "fake8", ID: 1001,
+		Weight: func(r rune) uint16 {
+			return uint16(unicode.ToUpper(r))
+		},
+		Ignorable: func(r rune) bool {
+			return r == 0x7F || r == 0x1F
+		}})
+	tree := utils.NewCharacterSetEncodingTree()
+	require.NoError(t, CharacterSetToEncodingTree(ctx, server, "fake8", fakeIter(), tree, discardLogf, nil))
+	rangeMap, err := utils.RangeMapFromTree(tree)
+	require.NoError(t, err)
+
+	runeToWeight := make(map[rune][]byte)
+	runeComparator, err := CollationToRuneComparator(ctx, server, "fake8_ignorable_ci", "fake8", fakeIter(), rangeMap,
+		runeToWeight, 0, discardLogf, nil)
+	require.NoError(t, err)
+	ignorables, err := CollationIgnorables(ctx, server, "fake8_ignorable_ci", "fake8", runeComparator, runeToWeight)
+	require.NoError(t, err)
+	assert.Equal(t, []rune{0x1F, 0x7F}, ignorables)
+
+	// The ignorable runes sort before every other rune, so they have the weight of zero
+	contents := utils.RuneComparatorToGoFileWithOptions(runeComparator, "fake8_ignorable_ci", false,
+		utils.RuneComparatorGoFileOptions{Ignorables: ignorables})
+	runeWeights, err := utils.ParseRuneComparatorGoFile(contents)
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), runeWeights.Weight(0x7F))
+	assert.True(t, runeWeights.IsIgnorable(0x1F))
+	assert.False(t, runeWeights.IsIgnorable('a'))
+	assert.Equal(t, 0, runeWeights.Compare("a\x7Fb", "AB"))
+	assert.Equal(t, 0, runeWeights.Compare("\x1F", ""))
+	assert.Equal(t, -1, runeWeights.Compare("a\x7F", "ab"))
+	assert.Contains(t, contents, "lRunes := fake8_ignorable_ci_withoutIgnorables(l)")
+}
+
+func TestFakeValidateCollationStrings(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeServer(t)
+	tree := utils.NewCharacterSetEncodingTree()
+	require.NoError(t, CharacterSetToEncodingTree(ctx, server, "fake8", fakeIter(), tree, discardLogf, nil))
+	rangeMap, err := utils.RangeMapFromTree(tree)
+	require.NoError(t, err)
+	runeComparator, err := CollationToRuneComparator(ctx, server, "fake8_general_ci", "fake8", fakeIter(), rangeMap,
+		make(map[rune][]byte), 0, discardLogf, nil)
+	require.NoError(t, err)
+	runeWeights, err := utils.ParseRuneComparatorGoFile(utils.RuneComparatorToGoFile(runeComparator, "fake8_general_ci", true))
+	require.NoError(t, err)
+
+	mismatches, err := ValidateCollationStrings(ctx, server, "fake8_general_ci", "fake8", runeWeights, 500, 1)
+	require.NoError(t, err)
+	assert.Empty(t, mismatches)
+
+	// A collation that distinguishes case compares many of the strings differently
+	server.AddCollation(utils.FakeCollation{Name: "fake8_sensitive_cs", Charset: "fake8", ID: 1002, PadSpace: true,
+		Weight: func(r rune) uint16 {
+			return uint16(r)
+		}})
+	mismatches, err = ValidateCollationStrings(ctx, server, "fake8_sensitive_cs", "fake8", runeWeights, 500, 1)
+	require.NoError(t, err)
+	require.NotEmpty(t, mismatches)
+	assert.Contains(t, mismatches[0].String(), "(server ")
+}
+
+func TestFakeCharacterSetSkipUnassigned(t *testing.T) {
+	ctx := context.Background()
+	server, err := utils.NewFakeServer("8.0.31")
+	require.NoError(t, err)
+	// The character set is named after one that only encodes the Basic Multilingual Plane, so that only the runes of
+	// that plane are converted. U+0378 is unassigned, and is encoded solely to be found by the verification.
+	encodings := make(map[rune][]byte)
+	for r := rune(0); r < 128; r++ {
+		encodings[r] = []byte{byte(r)}
+	}
+	encodings['é'] = []byte{0xE9}
+	encodings[0x0378] = []byte{0xF0}
+	server.AddCharset(utils.FakeCharset{Name: "latin1", Encodings: encodings})
+
+	rangeMap, err := CharacterSetToRangeMapWithOptions(ctx, server, "latin1", CharacterSetOptions{SkipUnassigned: true}, discardLogf, nil)
+	require.NoError(t, err)
+	_, ok := rangeMap.Encode([]byte("é"))
+	assert.True(t, ok)
+	_, ok = rangeMap.Encode([]byte(string(rune(0x0378))))
+	assert.False(t, ok)
+
+	// Verifying converts the skipped runes, which finds the unassigned rune
+	rangeMap, err = CharacterSetToRangeMapWithOptions(ctx, server, "latin1", CharacterSetOptions{SkipUnassigned: true, VerifyUnassigned: true}, discardLogf, nil)
+	require.NoError(t, err)
+	encoded, ok := rangeMap.Encode([]byte(string(rune(0x0378))))
+	assert.True(t, ok)
+	assert.Equal(t, []byte{0xF0}, encoded)
+	full, err := CharacterSetToRangeMap(ctx, server, "latin1", discardLogf, nil)
+	require.NoError(t, err)
+	assert.Empty(t, full.Tree().Diff(rangeMap.Tree()))
+}
+
+func TestFakeBinaryCollation(t *testing.T) {
+	ctx := context.Background()
+	server, err := utils.NewFakeServer("8.0.31")
+	require.NoError(t, err)
+	// `Ω` encodes before `é` despite its greater codepoint, so that the byte order differs from the codepoint order
+	encodings := make(map[rune][]byte)
+	for r := rune(0); r < 128; r++ {
+		encodings[r] = []byte{byte(r)}
+	}
+	encodings['Ω'] = []byte{0x80}
+	encodings['é'] = []byte{0xE9}
+	server.AddCharset(utils.FakeCharset{Name: "rev8", Encodings: encodings})
+	server.AddCollation(utils.FakeCollation{Name: "rev8_bin", Charset: "rev8", ID: 1001, PadSpace: true, IsDefault: true,
+		Weight: func(r rune) uint16 { return uint16(encodings[r][0]) }})
+	server.AddCollation(utils.FakeCollation{Name: "rev8_codepoint_bin", Charset: "rev8", ID: 1002, PadSpace: true,
+		Weight: func(r rune) uint16 { return uint16(r) }})
+	rangeMap, err := CharacterSetToRangeMap(ctx, server, "rev8", discardLogf, nil)
+	require.NoError(t, err)
+	iter := utils.NewUTF8Iter()
+	iter.SetRanges([][2]rune{{0, 0x3FF}})
+
+	runeComparator, err := CollationToRuneComparatorBinary(ctx, server, "rev8_bin", "rev8", iter, rangeMap, discardLogf)
+	require.NoError(t, err)
+	iter.Reset()
+	expected, err := CollationToRuneComparator(ctx, server, "rev8_bin", "rev8", iter, rangeMap, make(map[rune][]byte), 0, discardLogf, nil)
+	require.NoError(t, err)
+	assert.Equal(t, utils.NewCollationModel("rev8_bin", expected, true).Weights, utils.NewCollationModel("rev8_bin", runeComparator, true).Weights)
+
+	// The descending codepoints are always verified, so a collation that sorts by codepoint is rejected
+	iter.Reset()
+	_, err = CollationToRuneComparatorBinary(ctx, server, "rev8_codepoint_bin", "rev8", iter, rangeMap, discardLogf)
+	assert.ErrorIs(t, err, ErrNotByteOrder)
+	model, err := ExtractCollation(ctx, server, "rev8_codepoint_bin", rangeMap)
+	require.NoError(t, err)
+	order := model.Weights
+	assert.Equal(t, []rune{'é'}, order[len(order)-2])
+	assert.Equal(t, []rune{'Ω'}, order[len(order)-1])
+}
+
+func TestFakeBinaryCharacterSet(t *testing.T) {
+	ctx := context.Background()
+	server, err := utils.NewFakeServer("8.0.31")
+	require.NoError(t, err)
+	rangeMap, err := CharacterSetToRangeMap(ctx, server, BinaryCharacterSet, discardLogf, nil)
+	require.NoError(t, err)
+	for _, r := range []rune{0, 'a', 'é', 0xFFFF, 0x10000, utf8.MaxRune} {
+		decoded, ok := rangeMap.Decode([]byte(string(r)))
+		assert.True(t, ok, "rune `%s`", string(r))
+		assert.Equal(t, string(r), string(decoded))
+	}
+	metadata := utils.CharacterSetMetadata{Name: BinaryCharacterSet, MaxLength: 1}
+	require.NoError(t, metadata.ApplyRangeMap(rangeMap))
+	assert.Equal(t, 1, metadata.MinLength)
+}
@@ -0,0 +1,147 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extractor
+
+import (
+	"context"
+	"flag"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+// updateGolden rewrites the golden files from the current output rather than comparing against them, which is run as
+// `go test ./extractor -run TestGolden -update-golden` after an intended change to the generated files.
+var updateGolden = flag.Bool("update-golden", false, "rewrites the golden files of TestGolden")
+
+// goldenHeader is the header of every generated file, which does not change with the year like the default header.
+const goldenHeader = "Code generated by TestGolden. DO NOT EDIT."
+
+// newGoldenServer returns a FakeServer with the `gold16` character set, which is ASCII along with the Greek letters as
+// two bytes (0xA1 followed by the offset of the letter from U+0391, or 0xA2 for the lowercase letters), where `σ`
+// encodes to the same bytes as the final sigma (so that the encoding is lossy for `σ`). The `gold16_general_ci` collation compares case-insensitively, with the final
+// sigma equal to `Σ`, and sorts the Greek letters before the ASCII letters.
+func newGoldenServer(t *testing.T) *utils.FakeServer {
+	server, err := utils.NewFakeServer("8.0.31")
+	require.NoError(t, err)
+	encodings := make(map[rune][]byte)
+	for r := rune(0); r < 128; r++ {
+		encodings[r] = []byte{byte(r)}
+	}
+	for r := rune('Α'); r <= 'Ω'; r++ {
+		if r == 0x03A2 {
+			continue
+		}
+		encodings[r] = []byte{0xA1, byte(r - 'Α' + 0x41)}
+		encodings[r+0x20] = []byte{0xA2, byte(r - 'Α' + 0x41)}
+	}
+	encodings['ς'] = encodings['σ']
+	server.AddCharset(utils.FakeCharset{Name: "gold16", Encodings: encodings})
+	server.AddCollation(utils.FakeCollation{Name: "gold16_general_ci", Charset: "gold16", ID: 1001, PadSpace: true, IsDefault: true,
+		Weight: func(r rune) uint16 {
+			if r == 'ς' {
+				return uint16('Σ' - 0x300)
+			}
+			if unicode.Is(unicode.Greek, r) {
+				return uint16(unicode.ToUpper(r) - 0x300)
+			}
+			return uint16(unicode.ToUpper(r))
+		}})
+	return server
+}
+
+// goldenIter returns an iterator over the runes that the golden character set may contain.
+func goldenIter() *utils.UTF8Iter {
+	iter := utils.NewUTF8Iter()
+	iter.SetRanges([][2]rune{{0, 0x3FF}})
+	return iter
+}
+
+// TestGolden runs the extraction and code generation of the golden character set and collation, and compares every
+// generated file against the files within testdata/golden, so that changes to the RangeMap constructor or the code
+// generation may be checked without extracting from a server. Differences should either be fixed or, when intended,
+// accepted by rerunning the test with -update-golden and reviewing the changed files.
+func TestGolden(t *testing.T) {
+	ctx := context.Background()
+	server := newGoldenServer(t)
+	tree := utils.NewCharacterSetEncodingTree()
+	require.NoError(t, CharacterSetToEncodingTree(ctx, server, "gold16", goldenIter(), tree, discardLogf, nil))
+	rangeMap, err := utils.RangeMapFromTree(tree)
+	require.NoError(t, err)
+	caseMappings, err := CharacterSetCaseMappings(ctx, server, "gold16", rangeMap, goldenIter(), nil)
+	require.NoError(t, err)
+	lossyMappings, err := CharacterSetLossyMappings(ctx, server, "gold16", rangeMap, goldenIter(), discardLogf)
+	require.NoError(t, err)
+	rc, err := CollationToRuneComparator(ctx, server, "gold16_general_ci", "gold16", goldenIter(), rangeMap,
+		make(map[rune][]byte), 0, discardLogf, nil)
+	require.NoError(t, err)
+	padSpace, err := CollationPadSpace(ctx, server, "gold16_general_ci", "gold16")
+	require.NoError(t, err)
+
+	options := utils.CodegenOptions{Header: goldenHeader}
+	files := map[string]string{
+		"gold16.go":                                utils.RangeMapToGoFileWithOptions(rangeMap, caseMappings, "gold16", options),
+		"gold16_lossy.go":                          utils.LossyMappingsToGoFile("gold16", lossyMappings, options),
+		"gold16_general_ci.go":                     utils.RuneComparatorToGoFileWithOptions(rc, "gold16_general_ci", padSpace, utils.RuneComparatorGoFileOptions{Codegen: options}),
+		"gold16_general_ci_sorted_slice.go":        utils.RuneComparatorToSortedSliceGoFile(rc, "gold16_general_ci", padSpace, options),
+		"gold16_general_ci_reverse.go":             utils.RuneComparatorReverseToGoFile(rc, "gold16_general_ci", options),
+		"gold16_general_ci_equivalence_classes.go": utils.EquivalenceClassesToGoFile(rc, "gold16_general_ci", options),
+	}
+	for name, contents := range files {
+		actual := []byte(contents)
+		path := filepath.Join("testdata", "golden", name+".golden")
+		if *updateGolden {
+			require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+			require.NoError(t, os.WriteFile(path, actual, 0644))
+			continue
+		}
+		expected, err := os.ReadFile(path)
+		require.NoError(t, err, "missing golden file, which may be created with -update-golden")
+		assert.Equal(t, string(expected), string(actual), "`%s` differs from its golden file", name)
+	}
+}
+
+// TestGoldenCompiles type-checks the golden files of the character set against the declarations of GMS's encodings
+// package within testdata/gms, so that the generated files do not reference any field or method that only the RangeMap
+// of this repository declares.
+func TestGoldenCompiles(t *testing.T) {
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, path := range []string{
+		filepath.Join("testdata", "gms", "encodings.go.txt"),
+		filepath.Join("testdata", "golden", "gold16.go.golden"),
+		filepath.Join("testdata", "golden", "gold16_lossy.go.golden"),
+	} {
+		contents, err := os.ReadFile(path)
+		require.NoError(t, err)
+		file, err := parser.ParseFile(fset, path, contents, 0)
+		require.NoError(t, err)
+		files = append(files, file)
+	}
+	config := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	_, err := config.Check("encodings", fset, files, nil)
+	require.NoError(t, err)
+}
@@ -0,0 +1,59 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extractor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+// CollationIgnorables returns the runes of the RuneComparator that the collation ignores entirely, such as the soft
+// hyphen and the zero-width joiner within the UCA collations. A rune is ignorable when WEIGHT_STRING returns no weight
+// for it, and inserting it between two runes does not change the comparison against the two runes alone. The map
+// contains the weights that were found while extracting the collation, so only the runes without a weight are queried.
+// The ignorable runes are returned in sequential order.
+func CollationIgnorables(ctx context.Context, conn utils.Queryable, collation string, charset string, rc *utils.RuneComparator, runeToWeight map[rune][]byte) ([]rune, error) {
+	qb := conn.Builder()
+	var candidates []rune
+	for r := range rc.Runes() {
+		if _, ok := runeToWeight[r]; !ok {
+			candidates = append(candidates, r)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i] < candidates[j]
+	})
+
+	var ignorables []rune
+	for _, r := range candidates {
+		withRune := []byte("a" + string(r) + "b")
+		sqlOutput, err := conn.QueryContext(ctx, qb.Select(qb.Call("STRCMP",
+			qb.InCollation(withRune, charset, collation), qb.InCollation([]byte("ab"), charset, collation))))
+		if err != nil {
+			return nil, err
+		}
+		switch string(sqlOutput) {
+		case "0":
+			ignorables = append(ignorables, r)
+		case "1", "-1":
+		default:
+			return nil, fmt.Errorf("unknown output `%s` for comparing 'a%sb' (%d) and 'ab'", string(sqlOutput), string(r), r)
+		}
+	}
+	return ignorables, nil
+}
@@ -0,0 +1,44 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extractor
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+// CollationImplicitWeights returns the regions of the collation whose weights may be computed by formula, along with
+// the RuneComparator that the collation's files should be generated from (see utils.FindImplicitWeightRegions). This
+// uses the weights that were gathered while extracting the collation (which are the hexadecimal WEIGHT_STRING output
+// of each rune), and does not query the server. Only the UCA 9.0.0 collations have implicit weights, so every other
+// collation returns the RuneComparator unchanged.
+func CollationImplicitWeights(collation string, rc *utils.RuneComparator, runeToWeight map[rune][]byte) (*utils.RuneComparator, []utils.ImplicitWeightRegion, error) {
+	if !strings.Contains(collation, "_0900_") {
+		return rc, nil, nil
+	}
+	weights := make(map[rune][]byte, len(runeToWeight))
+	for r, hexWeight := range runeToWeight {
+		weight, err := hex.DecodeString(string(hexWeight))
+		if err != nil {
+			return nil, nil, fmt.Errorf("rune %d has the invalid weight `%s`", r, string(hexWeight))
+		}
+		weights[r] = weight
+	}
+	rc, regions := utils.FindImplicitWeightRegions(rc, weights)
+	return rc, regions, nil
+}
@@ -0,0 +1,297 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extractor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+// parallelChunks runs the given query function on every chunk of runes, using each connection of the pool within its
+// own goroutine. Each function returns one value per rune of its chunk. The values are returned in the order of the
+// chunks regardless of the order in which they complete, so that merging them is deterministic. Progress is reported
+// for every rune of a chunk once the chunk completes. The work stops once the context is done.
+func parallelChunks(ctx context.Context, pool *utils.ConnectionPool, chunks [][]rune, progress *utils.Progress, query func(conn utils.Queryable, chunk []rune) ([][]byte, error)) ([][][]byte, error) {
+	results := make([][][]byte, len(chunks))
+	indexes := make(chan int)
+	completed := make(chan int)
+	errs := make(chan error, pool.Size())
+	done := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	for i := 0; i < pool.Size(); i++ {
+		wg.Add(1)
+		go func(conn utils.Queryable) {
+			defer wg.Done()
+			for idx := range indexes {
+				values, err := query(conn, chunks[idx])
+				if err == nil && len(values) != len(chunks[idx]) {
+					err = fmt.Errorf("queried %d runes, but %d values were returned", len(chunks[idx]), len(values))
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+				results[idx] = values
+				select {
+				case completed <- idx:
+				case <-done:
+					return
+				}
+			}
+		}(pool.Connection(i))
+	}
+	// The chunks are distributed until every chunk has been given out, or until a worker fails
+	go func() {
+		defer close(indexes)
+		for idx := range chunks {
+			select {
+			case indexes <- idx:
+			case <-done:
+				return
+			}
+		}
+	}()
+	// Progress is reported from this goroutine, as Progress may only be used by a single goroutine
+	var err error
+	for remaining := len(chunks); remaining > 0 && err == nil; {
+		select {
+		case idx := <-completed:
+			for _, r := range chunks[idx] {
+				progress.Step(r)
+			}
+			remaining--
+		case err = <-errs:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+	close(done)
+	wg.Wait()
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// parallelShards splits the iterator into a shard for each connection of the pool, so that each connection owns a
+// contiguous block of runes within its own goroutine. The query function is run on each batch of a shard's runes, and
+// returns one value per rune of its batch. Once every shard has completed, merge is called with every rune and its
+// value in sequential order, as the shards are in order. Progress is reported for every rune of a batch once the batch
+// completes. The work stops once the context is done.
+func parallelShards(ctx context.Context, pool *utils.ConnectionPool, iter *utils.UTF8Iter, batchSize int, progress *utils.Progress, query func(conn utils.Queryable, batch []rune) ([][]byte, error), merge func(r rune, value []byte) error) error {
+	shards := iter.Split(pool.Size())
+	runes := make([][]rune, len(shards))
+	values := make([][][]byte, len(shards))
+	// Each completed batch is sent for its progress, while a nil batch marks that a shard has completed
+	completed := make(chan []rune)
+	errs := make(chan error, len(shards))
+	done := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	for i, shard := range shards {
+		wg.Add(1)
+		go func(shardIdx int, shard *utils.UTF8Iter, conn utils.Queryable) {
+			defer wg.Done()
+			send := func(batch []rune) bool {
+				select {
+				case completed <- batch:
+					return true
+				case <-done:
+					return false
+				}
+			}
+			batch := make([]rune, 0, batchSize)
+			flush := func() bool {
+				if len(batch) == 0 {
+					return true
+				}
+				batchValues, err := query(conn, batch)
+				if err == nil && len(batchValues) != len(batch) {
+					err = fmt.Errorf("queried %d runes, but %d values were returned", len(batch), len(batchValues))
+				}
+				if err != nil {
+					errs <- err
+					return false
+				}
+				runes[shardIdx] = append(runes[shardIdx], batch...)
+				values[shardIdx] = append(values[shardIdx], batchValues...)
+				if !send(batch) {
+					return false
+				}
+				batch = make([]rune, 0, batchSize)
+				return true
+			}
+			for r, ok := shard.Next(); ok; r, ok = shard.Next() {
+				batch = append(batch, r)
+				if len(batch) == batchSize && !flush() {
+					return
+				}
+			}
+			if flush() {
+				send(nil)
+			}
+		}(i, shard, pool.Connection(i))
+	}
+	// Progress is reported from this goroutine, as Progress may only be used by a single goroutine
+	var err error
+	for remaining := len(shards); remaining > 0 && err == nil; {
+		select {
+		case batch := <-completed:
+			if batch == nil {
+				remaining--
+			}
+			for _, r := range batch {
+				progress.Step(r)
+			}
+		case err = <-errs:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+	close(done)
+	wg.Wait()
+	if err != nil {
+		return err
+	}
+	for shardIdx := range shards {
+		for i, r := range runes[shardIdx] {
+			if err = merge(r, values[shardIdx][i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// chunkRunes splits the runes into chunks of the given size.
+func chunkRunes(runes []rune, size int) [][]rune {
+	var chunks [][]rune
+	for len(runes) > size {
+		chunks = append(chunks, runes[:size])
+		runes = runes[size:]
+	}
+	if len(runes) > 0 {
+		chunks = append(chunks, runes)
+	}
+	return chunks
+}
+
+// ParallelCharacterSetToRangeMap constructs the same RangeMap as CharacterSetToRangeMap, but converts the runes using
+// every connection of the pool in parallel. The outputs are added to the tree in sequential order once every rune has
+// been converted, as the detection of unmappable runes depends on the runes that have already been added. Each
+// connection owns a shard of the runes (see UTF8Iter.Split). Checkpoints are not supported, as the shards complete in
+// any order.
+func ParallelCharacterSetToRangeMap(ctx context.Context, pool *utils.ConnectionPool, charset string, logf Logf) (*utils.RangeMap, error) {
+	return ParallelCharacterSetToRangeMapWithOptions(ctx, pool, charset, CharacterSetOptions{}, logf)
+}
+
+// ParallelCharacterSetToRangeMapWithOptions is the same as ParallelCharacterSetToRangeMap, but only converts the runes
+// that the options select. The unassigned runes are verified using only the first connection, as is the `binary`
+// character set (see BinaryCharacterSetToRangeMap).
+func ParallelCharacterSetToRangeMapWithOptions(ctx context.Context, pool *utils.ConnectionPool, charset string, options CharacterSetOptions, logf Logf) (*utils.RangeMap, error) {
+	conn := pool.Connection(0)
+	if charset == BinaryCharacterSet {
+		return BinaryCharacterSetToRangeMap(ctx, conn, logf)
+	}
+	hooks := utils.RegisteredExtractionHooks()
+	if err := hooks.BeforeCharacterSet(conn, charset); err != nil {
+		return nil, err
+	}
+	// The quirks are discovered before the workers start, as they use the first connection
+	quirks, err := characterSetQuirks(ctx, conn, charset, logf)
+	if err != nil {
+		return nil, err
+	}
+	iter := characterSetIter(charset, options, logf)
+	charsetToGoString := utils.NewCharacterSetEncodingTree()
+	err = parallelShards(ctx, pool, iter, utils.CharacterSetBatchSize, utils.NewProgress(charset, iter.Total(), logf),
+		func(conn utils.Queryable, batch []rune) ([][]byte, error) {
+			qb := conn.Builder()
+			exprs := make([]string, len(batch))
+			for i, r := range batch {
+				exprs[i] = qb.AsBinary(qb.InCharset([]byte(string(r)), charset))
+			}
+			return conn.QueryValuesContext(ctx, qb.Select(exprs...))
+		},
+		func(r rune, value []byte) error {
+			return addToEncodingTree(ctx, conn, charset, quirks, hooks, r, value, charsetToGoString)
+		})
+	if err != nil {
+		return nil, err
+	}
+	if err = verifyUnassigned(ctx, conn, charset, options, charsetToGoString, logf); err != nil {
+		return nil, err
+	}
+	rangeMap, err := utils.RangeMapFromTreeWithOptions(charsetToGoString, quirks.RangeMapOptions)
+	if err != nil {
+		return nil, err
+	}
+	if err = hooks.AfterCharacterSet(conn, charset, rangeMap); err != nil {
+		return nil, err
+	}
+	return rangeMap, nil
+}
+
+// ParallelCollationWeights retrieves the weight of every rune from the iterator that is valid in the character set,
+// using every connection of the pool in parallel, and adds them to the given map. This is the bulk of the queries of
+// CollationToRuneComparator, which may then be given the map with a seed sample of zero, so that only runes without a
+// weight are queried. Runes that are already within the map are treated as seeded, and one of every seedSample seeded
+// runes is verified against the server.
+func ParallelCollationWeights(ctx context.Context, pool *utils.ConnectionPool, collation string, charset string, iter *utils.UTF8Iter, rangeMap *utils.RangeMap, runeToWeight map[rune][]byte, seedSample int, logf Logf) error {
+	// The runes are determined sequentially, so that the sampled runes match CollationToRuneComparator
+	var runes []rune
+	seededRunes := 0
+	for r, ok := iter.Next(); ok; r, ok = iter.Next() {
+		if _, ok := rangeMap.Encode([]byte(string(r))); !ok {
+			continue
+		}
+		if _, seeded := runeToWeight[r]; seeded {
+			seededRunes++
+			if seedSample <= 0 || seededRunes%seedSample != 0 {
+				continue
+			}
+		}
+		runes = append(runes, r)
+	}
+	chunks := chunkRunes(runes, utils.CharacterSetBatchSize)
+	results, err := parallelChunks(ctx, pool, chunks, utils.NewProgress(collation, len(runes), logf),
+		func(conn utils.Queryable, chunk []rune) ([][]byte, error) {
+			qb := conn.Builder()
+			exprs := make([]string, len(chunk))
+			for i, r := range chunk {
+				exprs[i] = qb.Call("HEX", qb.WeightString(qb.InCollation([]byte(string(r)), charset, collation), 0))
+			}
+			return conn.QueryValuesContext(ctx, qb.Select(exprs...))
+		})
+	if err != nil {
+		return err
+	}
+	for chunkIdx, chunk := range chunks {
+		for i, r := range chunk {
+			weight := results[chunkIdx][i]
+			if seededWeight, seeded := runeToWeight[r]; seeded && !bytes.Equal(seededWeight, weight) {
+				return fmt.Errorf("seeded weight for rune %d does not match the server", r)
+			}
+			// Some runes do not return a weight, which are handled by STRCMP during comparisons
+			if len(weight) > 0 {
+				runeToWeight[r] = weight
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extractor
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unicode"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+// newFakeLatin1Server returns a FakeServer with a `latin1` character set and `latin1_general_ci` collation, which are
+// named after the real character set so that only the Basic Multilingual Plane is converted. Every rune below U+0100
+// encodes to its own codepoint.
+func newFakeLatin1Server(t *testing.T) *utils.FakeServer {
+	server, err := utils.NewFakeServer("8.0.31")
+	require.NoError(t, err)
+	encodings := make(map[rune][]byte)
+	for r := rune(0); r < 0x100; r++ {
+		encodings[r] = []byte{byte(r)}
+	}
+	server.AddCharset(utils.FakeCharset{Name: "latin1", Encodings: encodings})
+	server.AddCollation(utils.FakeCollation{Name: "latin1_general_ci", Charset: "latin1", ID: 48, PadSpace: true,
+		Weight: func(r rune) uint16 { return uint16(unicode.ToUpper(r)) }})
+	return server
+}
+
+// runWithDeadline fails the test when the given function does not return within a few seconds, so that a deadlock
+// fails rather than hanging the test binary.
+func runWithDeadline(t *testing.T, f func() error) error {
+	result := make(chan error, 1)
+	go func() {
+		result <- f()
+	}()
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(10 * time.Second):
+		t.Fatal("the workers did not return")
+		return nil
+	}
+}
+
+func TestParallelCharacterSetToRangeMap(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeLatin1Server(t)
+	pool, err := utils.NewFakeConnectionPool(server, 3)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	serial, err := CharacterSetToRangeMap(ctx, server, "latin1", discardLogf, nil)
+	require.NoError(t, err)
+	parallel, err := ParallelCharacterSetToRangeMap(ctx, pool, "latin1", discardLogf)
+	require.NoError(t, err)
+	assert.Empty(t, serial.Tree().Diff(parallel.Tree()))
+	_, ok := parallel.Encode([]byte("é"))
+	assert.True(t, ok)
+	_, ok = parallel.Encode([]byte("Ā"))
+	assert.False(t, ok)
+}
+
+func TestParallelCollationWeights(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeLatin1Server(t)
+	pool, err := utils.NewFakeConnectionPool(server, 3)
+	require.NoError(t, err)
+	defer pool.Close()
+	rangeMap, err := CharacterSetToRangeMap(ctx, server, "latin1", discardLogf, nil)
+	require.NoError(t, err)
+
+	serialWeights := make(map[rune][]byte)
+	serial, err := CollationToRuneComparator(ctx, server, "latin1_general_ci", "latin1", fakeIter(), rangeMap, serialWeights, 0, discardLogf, nil)
+	require.NoError(t, err)
+	parallelWeights := make(map[rune][]byte)
+	require.NoError(t, ParallelCollationWeights(ctx, pool, "latin1_general_ci", "latin1", fakeIter(), rangeMap, parallelWeights, 0, discardLogf))
+	assert.Equal(t, serialWeights, parallelWeights)
+	// The space has no weight, as the collation removes trailing spaces
+	assert.Equal(t, 255, len(parallelWeights))
+	assert.NotContains(t, parallelWeights, ' ')
+
+	// The weights are given to the serial extraction, which then has nothing left to query
+	parallel, err := CollationToRuneComparator(ctx, server, "latin1_general_ci", "latin1", fakeIter(), rangeMap, parallelWeights, 0, discardLogf, nil)
+	require.NoError(t, err)
+	assert.Equal(t, utils.RuneComparatorToGoFile(serial, "latin1_general_ci", true), utils.RuneComparatorToGoFile(parallel, "latin1_general_ci", true))
+}
+
+func TestParallelWorkerError(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeLatin1Server(t)
+	pool, err := utils.NewFakeConnectionPool(server, 3)
+	require.NoError(t, err)
+	defer pool.Close()
+	errWorker := errors.New("worker failed")
+
+	runes := make([]rune, 1000)
+	for i := range runes {
+		runes[i] = rune(i)
+	}
+	err = runWithDeadline(t, func() error {
+		_, err := parallelChunks(ctx, pool, chunkRunes(runes, 10), utils.NewProgress("chunks", len(runes), discardLogf),
+			func(conn utils.Queryable, chunk []rune) ([][]byte, error) {
+				if chunk[0] == 500 {
+					return nil, errWorker
+				}
+				return make([][]byte, len(chunk)), nil
+			})
+		return err
+	})
+	assert.ErrorIs(t, err, errWorker)
+
+	iter := fakeIter()
+	err = runWithDeadline(t, func() error {
+		return parallelShards(ctx, pool, iter, 10, utils.NewProgress("shards", iter.Total(), discardLogf),
+			func(conn utils.Queryable, batch []rune) ([][]byte, error) {
+				if batch[0] >= 0x200 {
+					return nil, errWorker
+				}
+				return make([][]byte, len(batch)), nil
+			},
+			func(r rune, value []byte) error {
+				t.Fatal("no rune is merged once a worker fails")
+				return nil
+			})
+	})
+	assert.ErrorIs(t, err, errWorker)
+
+	// A worker that returns the wrong number of values is also an error
+	err = runWithDeadline(t, func() error {
+		_, err := parallelChunks(ctx, pool, chunkRunes(runes, 10), utils.NewProgress("chunks", len(runes), discardLogf),
+			func(conn utils.Queryable, chunk []rune) ([][]byte, error) {
+				return make([][]byte, 1), nil
+			})
+		return err
+	})
+	assert.Error(t, err)
+}
+
+func TestParallelCancellation(t *testing.T) {
+	server := newFakeLatin1Server(t)
+	pool, err := utils.NewFakeConnectionPool(server, 3)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	// The context is cancelled by the first query, so only the queries that were already running may complete
+	runes := make([]rune, 1000)
+	for i := range runes {
+		runes[i] = rune(i)
+	}
+	chunks := chunkRunes(runes, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	var queries int32
+	err = runWithDeadline(t, func() error {
+		_, err := parallelChunks(ctx, pool, chunks, utils.NewProgress("chunks", len(runes), discardLogf),
+			func(conn utils.Queryable, chunk []rune) ([][]byte, error) {
+				atomic.AddInt32(&queries, 1)
+				cancel()
+				time.Sleep(time.Millisecond)
+				return make([][]byte, len(chunk)), nil
+			})
+		return err
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, int(atomic.LoadInt32(&queries)), len(chunks))
+
+	// The connections of the pool also refuse to query once the context is done, which stops the shards
+	iter := fakeIter()
+	err = runWithDeadline(t, func() error {
+		return parallelShards(ctx, pool, iter, 10, utils.NewProgress("shards", iter.Total(), discardLogf),
+			func(conn utils.Queryable, batch []rune) ([][]byte, error) {
+				qb := conn.Builder()
+				return conn.QueryValuesContext(ctx, qb.Select(qb.AsBinary(qb.InCharset([]byte(string(batch[0])), "latin1"))))
+			},
+			func(r rune, value []byte) error {
+				return nil
+			})
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	_, err = ParallelCharacterSetToRangeMap(ctx, pool, "latin1", discardLogf)
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,106 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extractor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+// ReverseMapping is a byte sequence of a character set that the server decodes to a rune, yet the RangeMap does not
+// decode the same way. Such sequences are unreachable when the RangeMap is built by encoding every rune, as the server
+// either does not encode the rune at all, or encodes it to a different sequence.
+type ReverseMapping struct {
+	// Encoding is the byte sequence in the character set's encoding.
+	Encoding []byte
+	// Rune is the server's decoding of the byte sequence.
+	Rune rune
+	// Forward is the server's encoding of the rune, as recorded by the RangeMap. This is nil when the rune does not have
+	// an encoding, meaning that the codepoint only exists in the reverse direction.
+	Forward []byte
+}
+
+// Asymmetric returns whether the rune has an encoding, which differs from the sequence that decodes to it.
+func (m ReverseMapping) Asymmetric() bool {
+	return m.Forward != nil
+}
+
+// String returns a description of the mapping.
+func (m ReverseMapping) String() string {
+	if m.Asymmetric() {
+		return fmt.Sprintf("0x%X decodes to `%s` (U+%04X), which encodes to 0x%X", m.Encoding, string(m.Rune), m.Rune, m.Forward)
+	}
+	return fmt.Sprintf("0x%X decodes to `%s` (U+%04X), which does not have an encoding", m.Encoding, string(m.Rune), m.Rune)
+}
+
+// CharacterSetReverseMappings decodes byte sequences of the character set using the server, and returns every sequence
+// that decodes to a single rune that the RangeMap does not decode the same way. Every single byte is probed, and each
+// sequence that the server cannot decode on its own (which may be the start of a longer codepoint) is extended by every
+// possible byte, up to the given maximum length. Each extension of a sequence is decoded in a single query. The number of
+// queries grows with the number of incomplete sequences at each length, so a maximum length of 4 is only practical for
+// character sets with few multi-byte lead bytes.
+func CharacterSetReverseMappings(ctx context.Context, conn utils.Queryable, charset string, rangeMap *utils.RangeMap, maxLength int, logf Logf) ([]ReverseMapping, error) {
+	qb := conn.Builder()
+	// The server decodes invalid sequences to '?', so it only represents a codepoint when it's the encoding of '?'
+	questionMark, _ := rangeMap.Encode([]byte("?"))
+	var mappings []ReverseMapping
+	prefixes := [][]byte{{}}
+	for length := 1; length <= maxLength && len(prefixes) > 0; length++ {
+		logf("%s: probing %d sequences of length %d", charset, len(prefixes)*256, length)
+		var incomplete [][]byte
+		exprs := make([]string, 256)
+		for _, prefix := range prefixes {
+			for b := 0; b < 256; b++ {
+				sequence := append(append([]byte{}, prefix...), byte(b))
+				// The binary introducer allows the bytes to be interpreted as the character set without conversion
+				exprs[b] = qb.AsBinary(qb.Convert(qb.Convert(qb.Literal("binary", sequence), charset), "utf8mb4"))
+			}
+			sqlOutputs, err := conn.QueryValuesContext(ctx, qb.Select(exprs...))
+			if err != nil {
+				return nil, err
+			}
+			if len(sqlOutputs) != len(exprs) {
+				return nil, fmt.Errorf("decoded %d sequences, but %d values were returned", len(exprs), len(sqlOutputs))
+			}
+			for b, sqlOutput := range sqlOutputs {
+				sequence := append(append([]byte{}, prefix...), byte(b))
+				if len(sqlOutput) == 0 || (string(sqlOutput) == "?" && !bytes.Equal(sequence, questionMark)) {
+					incomplete = append(incomplete, sequence)
+					continue
+				}
+				// Sequences that decode to multiple runes contain a complete codepoint followed by other bytes
+				r, _ := utf8.DecodeRune(sqlOutput)
+				if utf8.RuneCount(sqlOutput) != 1 || r == utf8.RuneError {
+					continue
+				}
+				if decoded, ok := rangeMap.Decode(sequence); ok && bytes.Equal(decoded, sqlOutput) {
+					continue
+				}
+				forward, _ := rangeMap.Encode(sqlOutput)
+				mappings = append(mappings, ReverseMapping{
+					Encoding: sequence,
+					Rune:     r,
+					Forward:  forward,
+				})
+			}
+		}
+		prefixes = incomplete
+	}
+	return mappings, nil
+}
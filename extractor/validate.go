@@ -0,0 +1,200 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extractor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+// RoundTripMismatch is a string whose conversions, as computed by a RangeMap, differ from the server's conversions.
+type RoundTripMismatch struct {
+	// Original is the string in the character set's encoding.
+	Original []byte
+	// Decoded is the RangeMap's conversion to utf8mb4, while ServerDecoded is the server's conversion.
+	Decoded       []byte
+	ServerDecoded []byte
+	// Encoded is the RangeMap's conversion of Decoded back to the character set, while ServerEncoded is the server's.
+	Encoded       []byte
+	ServerEncoded []byte
+}
+
+// String returns a description of the mismatch.
+func (m RoundTripMismatch) String() string {
+	return fmt.Sprintf("0x%X decoded to 0x%X (server 0x%X) and encoded to 0x%X (server 0x%X)",
+		m.Original, m.Decoded, m.ServerDecoded, m.Encoded, m.ServerEncoded)
+}
+
+// ValidateRoundTrip converts random strings from a character set to utf8mb4 and back again using the given RangeMap,
+// and compares each step against the server performing the same conversions. The random strings are deterministic for
+// a given seed. Returns every string whose conversions differ, or whose round trip is asymmetric.
+func ValidateRoundTrip(ctx context.Context, conn utils.Queryable, charset string, rangeMap *utils.RangeMap, samples int, seed int64) ([]RoundTripMismatch, error) {
+	qb := conn.Builder()
+	// We gather every codepoint of the character set, as the strings are built in the character set's encoding
+	var codepoints [][]byte
+	iter := rangeMap.Tree().Iterator()
+	for inputEncoding, _, ok := iter.Next(); ok; inputEncoding, _, ok = iter.Next() {
+		codepoints = append(codepoints, inputEncoding)
+	}
+	if len(codepoints) == 0 {
+		return nil, fmt.Errorf("`%s` does not contain any codepoints", charset)
+	}
+
+	var mismatches []RoundTripMismatch
+	random := rand.New(rand.NewSource(seed))
+	for i := 0; i < samples; i++ {
+		// We decode and encode each codepoint individually, as the strings are not segmented by the RangeMap
+		var original, decoded, encoded []byte
+		for j := random.Intn(8) + 1; j > 0; j-- {
+			codepoint := codepoints[random.Intn(len(codepoints))]
+			original = append(original, codepoint...)
+			decodedCodepoint, ok := rangeMap.Decode(codepoint)
+			if !ok {
+				return nil, fmt.Errorf("codepoint 0x%X cannot be decoded", codepoint)
+			}
+			decoded = append(decoded, decodedCodepoint...)
+			encodedCodepoint, ok := rangeMap.Encode(decodedCodepoint)
+			if !ok {
+				return nil, fmt.Errorf("codepoint 0x%X cannot be encoded after decoding to 0x%X", codepoint, decodedCodepoint)
+			}
+			encoded = append(encoded, encodedCodepoint...)
+		}
+
+		// The binary introducer allows the bytes to be interpreted as the character set without conversion
+		asCharset := qb.Convert(qb.Literal("binary", original), charset)
+		sqlDecoded, err := conn.QueryContext(ctx, qb.Select(qb.AsBinary(qb.Convert(asCharset, "utf8mb4"))))
+		if err != nil {
+			return nil, err
+		}
+		sqlEncoded, err := conn.QueryContext(ctx, qb.Select(qb.AsBinary(qb.Convert(qb.Convert(asCharset, "utf8mb4"), charset))))
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(sqlDecoded, decoded) || !bytes.Equal(sqlEncoded, encoded) || !bytes.Equal(original, encoded) {
+			mismatches = append(mismatches, RoundTripMismatch{
+				Original:      original,
+				Decoded:       decoded,
+				ServerDecoded: sqlDecoded,
+				Encoded:       encoded,
+				ServerEncoded: sqlEncoded,
+			})
+		}
+	}
+	return mismatches, nil
+}
+
+// CollationMismatch is a pair of strings whose comparison, as computed by a generated weight function, differs from the
+// server's comparison.
+type CollationMismatch struct {
+	Left  string
+	Right string
+	// Expected is the comparison of the generated weight function, while Server is the server's comparison.
+	Expected int
+	Server   int
+}
+
+// String returns a description of the mismatch.
+func (m CollationMismatch) String() string {
+	return fmt.Sprintf("%q (%U) compared to %q (%U) is %d (server %d)",
+		m.Left, []rune(m.Left), m.Right, []rune(m.Right), m.Expected, m.Server)
+}
+
+// ValidateCollationStrings compares random strings of multiple runes using the given weights (such as those parsed from
+// a generated file), and compares each result against the server comparing the same strings with STRCMP. Every rune is
+// ordered correctly on its own after extraction, yet strings may still be compared differently when the collation has
+// contractions (such as "ch" in traditional Spanish) or expansions (such as "ß" equaling "ss"), which a weight per rune
+// cannot represent. The strings are built from the runes that have a weight, where half of the runes are drawn from
+// utils.DefaultContractionRunes (as known contractions are made of Latin letters), and half of the pairs differ by only a
+// single rune, so that the comparison is decided after the first rune. The random strings are deterministic for a given
+// seed. Returns every pair of strings whose comparisons differ.
+func ValidateCollationStrings(ctx context.Context, conn utils.Queryable, collation string, charset string, rw *utils.RuneWeights, samples int, seed int64) ([]CollationMismatch, error) {
+	qb := conn.Builder()
+	var runes []rune
+	iter := utils.NewUTF8Iter()
+	for r, ok := iter.Next(); ok; r, ok = iter.Next() {
+		if rw.Weight(r) != 2147483647 {
+			runes = append(runes, r)
+		}
+	}
+	var contractionRunes []rune
+	for _, r := range utils.DefaultContractionRunes {
+		if rw.Weight(r) != 2147483647 {
+			contractionRunes = append(contractionRunes, r)
+		}
+	}
+	if len(runes) == 0 {
+		return nil, fmt.Errorf("`%s` does not contain any weighted runes", collation)
+	}
+
+	random := rand.New(rand.NewSource(seed))
+	randomRune := func() rune {
+		if len(contractionRunes) > 0 && random.Intn(2) == 0 {
+			return contractionRunes[random.Intn(len(contractionRunes))]
+		}
+		return runes[random.Intn(len(runes))]
+	}
+	randomString := func() []rune {
+		str := make([]rune, random.Intn(7)+2)
+		for i := range str {
+			str[i] = randomRune()
+		}
+		return str
+	}
+	var mismatches []CollationMismatch
+	for i := 0; i < samples; i++ {
+		left := randomString()
+		var right []rune
+		if random.Intn(2) == 0 {
+			right = randomString()
+		} else {
+			// The right string replaces, inserts, or removes a single rune of the left string
+			right = append([]rune(nil), left...)
+			pos := random.Intn(len(right))
+			switch random.Intn(3) {
+			case 0:
+				right[pos] = randomRune()
+			case 1:
+				right = append(right[:pos], append([]rune{randomRune()}, right[pos:]...)...)
+			default:
+				right = append(right[:pos], right[pos+1:]...)
+			}
+		}
+
+		sqlOutput, err := conn.QueryContext(ctx, qb.Select(qb.Call("STRCMP",
+			qb.InCollation([]byte(string(left)), charset, collation), qb.InCollation([]byte(string(right)), charset, collation))))
+		if err != nil {
+			return nil, err
+		}
+		var server int
+		switch string(sqlOutput) {
+		case "1":
+			server = 1
+		case "-1":
+			server = -1
+		case "0":
+			server = 0
+		default:
+			return nil, fmt.Errorf("unknown output `%s` for comparing %q and %q", string(sqlOutput), string(left), string(right))
+		}
+		if expected := rw.Compare(string(left), string(right)); expected != server {
+			mismatches = append(mismatches, CollationMismatch{Left: string(left), Right: string(right), Expected: expected, Server: server})
+		}
+	}
+	return mismatches, nil
+}
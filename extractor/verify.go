@@ -0,0 +1,282 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extractor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+// RecentUnicodeBlocks are blocks that were added in recent Unicode versions (13.0 and later). These are the most likely
+// to differ after a MySQL upgrade, so SampleRunes draws half of its runes from these blocks.
+var RecentUnicodeBlocks = [][2]rune{
+	{0x10570, 0x105BF}, // Vithkuqi
+	{0x10E80, 0x10EBF}, // Yezidi
+	{0x10F70, 0x10FAF}, // Old Uyghur
+	{0x10FB0, 0x10FDF}, // Chorasmian
+	{0x11900, 0x1195F}, // Dives Akuru
+	{0x11F00, 0x11F5F}, // Kawi
+	{0x12F90, 0x12FFF}, // Cypro-Minoan
+	{0x16A70, 0x16ACF}, // Tangsa
+	{0x18B00, 0x18CFF}, // Khitan Small Script
+	{0x1E290, 0x1E2BF}, // Toto
+	{0x1E4D0, 0x1E4FF}, // Nag Mundari
+	{0x1FA70, 0x1FAFF}, // Symbols and Pictographs Extended-A
+	{0x1FB00, 0x1FBFF}, // Symbols for Legacy Computing
+	{0x30000, 0x3134F}, // CJK Unified Ideographs Extension G
+	{0x31350, 0x323AF}, // CJK Unified Ideographs Extension H
+}
+
+// SampleRunes returns the given number of random valid runes. Half of all runes are drawn from RecentUnicodeBlocks,
+// while the other half are drawn from the entire range of valid runes. Runes may be returned more than once.
+func SampleRunes(random *rand.Rand, count int) []rune {
+	samples := make([]rune, 0, count)
+	for len(samples) < count {
+		var r rune
+		if random.Intn(2) == 0 {
+			block := RecentUnicodeBlocks[random.Intn(len(RecentUnicodeBlocks))]
+			r = block[0] + rune(random.Intn(int(block[1]-block[0])+1))
+		} else {
+			r = rune(random.Intn(utf8.MaxRune + 1))
+		}
+		if utf8.ValidRune(r) {
+			samples = append(samples, r)
+		}
+	}
+	return samples
+}
+
+// VerifyCharacterSet re-queries the server for the given runes, and returns every difference between the server and the
+// expected RangeMap and CaseMappings (such as those parsed from a previously generated file), where the expected side is
+// the left side. Each rune is encoded by the server, and every encoding that either side produced is then decoded by both
+// sides, so that a rune sharing its encoding with the rune that the server prefers is not reported.
+func VerifyCharacterSet(ctx context.Context, conn utils.Queryable, charset string, rangeMap *utils.RangeMap, caseMappings utils.CaseMappings, runes []rune, logf Logf) (utils.ModelDiff, error) {
+	runes = uniqueRunes(runes)
+	encodings, err := serverEncodings(ctx, conn, charset, runes)
+	if err != nil {
+		return utils.ModelDiff{}, err
+	}
+	var allEncodings [][]byte
+	seen := make(map[string]struct{})
+	addEncoding := func(encoding []byte) {
+		if _, ok := seen[string(encoding)]; encoding != nil && !ok {
+			seen[string(encoding)] = struct{}{}
+			allEncodings = append(allEncodings, encoding)
+		}
+	}
+	for _, r := range runes {
+		addEncoding(encodings[r])
+		if encoded, ok := rangeMap.Encode([]byte(string(r))); ok {
+			addEncoding(encoded)
+		}
+	}
+	decodings, err := serverDecodings(ctx, conn, charset, allEncodings, encodings['?'])
+	if err != nil {
+		return utils.ModelDiff{}, err
+	}
+	diff := utils.ModelDiff{}
+	for _, encoding := range allEncodings {
+		left, ok := rangeMap.Decode(encoding)
+		if !ok {
+			left = nil
+		}
+		if right := decodings[string(encoding)]; !bytes.Equal(left, right) {
+			diff.Encodings = append(diff.Encodings, utils.EncodingTreeDifference{Encoding: encoding, Left: left, Right: right})
+		}
+	}
+	sort.Slice(diff.Encodings, func(i, j int) bool {
+		return bytes.Compare(diff.Encodings[i].Encoding, diff.Encodings[j].Encoding) < 0
+	})
+
+	iter := utils.NewUTF8Iter()
+	iter.SetRanges(runeRanges(runes))
+	serverCaseMappings, err := CharacterSetCaseMappings(ctx, conn, charset, rangeMap, iter, nil)
+	if err != nil {
+		return utils.ModelDiff{}, err
+	}
+	expected := utils.NewCaseMappings()
+	for _, r := range runes {
+		if _, ok := rangeMap.Encode([]byte(string(r))); !ok {
+			continue
+		}
+		upper, ok := caseMappings.ToUpper[r]
+		if !ok {
+			upper = string(r)
+		}
+		lower, ok := caseMappings.ToLower[r]
+		if !ok {
+			lower = string(r)
+		}
+		expected.Add(r, upper, lower)
+	}
+	diff.ToUpper, diff.ToLower = expected.Diff(serverCaseMappings)
+	logf("%s: verified %d runes and %d encodings", charset, len(runes), len(allEncodings))
+	return diff, nil
+}
+
+// VerifyCollation re-queries the server for the given runes, and returns every difference between the server and the
+// expected ordering and padding (such as those parsed from a previously generated file), where the expected side is the
+// left side. Only the positions of the given runes relative to each other are compared, and runes that the server
+// cannot encode in the character set are expected to be missing from the ordering.
+func VerifyCollation(ctx context.Context, conn utils.Queryable, collation string, charset string, order [][]rune, padSpace bool, runes []rune, logf Logf) (utils.ModelDiff, error) {
+	runes = uniqueRunes(runes)
+	encodings, err := serverEncodings(ctx, conn, charset, runes)
+	if err != nil {
+		return utils.ModelDiff{}, err
+	}
+	// The collation only needs to know which runes are valid, so a rune that shares its encoding with another is skipped
+	tree := utils.NewCharacterSetEncodingTree()
+	for _, r := range runes {
+		if encodings[r] == nil {
+			continue
+		}
+		node := tree
+		for _, val := range encodings[r] {
+			node = node.AddChild(val)
+		}
+		node.SetData([]byte(string(r)))
+	}
+	rangeMap, err := utils.RangeMapFromTreeWithOptions(tree, utils.CharacterSetQuirksFor(charset).RangeMapOptions)
+	if err != nil {
+		return utils.ModelDiff{}, err
+	}
+	iter := utils.NewUTF8Iter()
+	iter.SetRanges(runeRanges(runes))
+	rc, err := CollationToRuneComparator(ctx, conn, collation, charset, iter, rangeMap, make(map[rune][]byte), 0, logf, nil)
+	if err != nil {
+		return utils.ModelDiff{}, err
+	}
+	serverPadSpace, err := CollationPadSpace(ctx, conn, collation, charset)
+	if err != nil {
+		return utils.ModelDiff{}, err
+	}
+
+	// The expected ordering is limited to the same runes, so that the previous row of each rune matches the server's
+	included := make(map[rune]struct{}, len(runes))
+	for _, r := range runes {
+		included[r] = struct{}{}
+	}
+	var expectedOrder [][]rune
+	for _, row := range order {
+		var filtered []rune
+		for _, r := range row {
+			if _, ok := included[r]; ok {
+				filtered = append(filtered, r)
+			}
+		}
+		if len(filtered) > 0 {
+			expectedOrder = append(expectedOrder, filtered)
+		}
+	}
+	expected := utils.NewCollationModel(collation, utils.NewRuneComparatorFromOrder(expectedOrder), padSpace)
+	return utils.DiffModels(expected, utils.NewCollationModel(collation, rc, serverPadSpace))
+}
+
+// serverEncodings returns the server's encoding of each rune within the character set. Runes that the server cannot
+// encode (which it converts to '?') are nil.
+func serverEncodings(ctx context.Context, conn utils.Queryable, charset string, runes []rune) (map[rune][]byte, error) {
+	qb := conn.Builder()
+	// The encoding of '?' is always retrieved, so that it may be distinguished from a rune that cannot be encoded
+	runes = append([]rune{'?'}, runes...)
+	encodings := make(map[rune][]byte, len(runes))
+	for start := 0; start < len(runes); start += utils.CharacterSetBatchSize {
+		batch := runes[start:]
+		if len(batch) > utils.CharacterSetBatchSize {
+			batch = batch[:utils.CharacterSetBatchSize]
+		}
+		exprs := make([]string, len(batch))
+		for i, r := range batch {
+			exprs[i] = qb.AsBinary(qb.InCharset([]byte(string(r)), charset))
+		}
+		sqlOutputs, err := conn.QueryValuesContext(ctx, qb.Select(exprs...))
+		if err != nil {
+			return nil, err
+		}
+		if len(sqlOutputs) != len(batch) {
+			return nil, fmt.Errorf("converted %d runes, but %d values were returned", len(batch), len(sqlOutputs))
+		}
+		for i, r := range batch {
+			encodings[r] = sqlOutputs[i]
+		}
+	}
+	for r, encoding := range encodings {
+		if r != '?' && bytes.Equal(encoding, encodings['?']) {
+			encodings[r] = nil
+		}
+	}
+	return encodings, nil
+}
+
+// serverDecodings returns the UTF8 encoding of each of the given encodings of the character set, as decoded by the
+// server. Encodings that the server cannot decode (which it converts to '?') are nil.
+func serverDecodings(ctx context.Context, conn utils.Queryable, charset string, encodings [][]byte, questionMark []byte) (map[string][]byte, error) {
+	qb := conn.Builder()
+	decodings := make(map[string][]byte, len(encodings))
+	for start := 0; start < len(encodings); start += utils.CharacterSetBatchSize {
+		batch := encodings[start:]
+		if len(batch) > utils.CharacterSetBatchSize {
+			batch = batch[:utils.CharacterSetBatchSize]
+		}
+		exprs := make([]string, len(batch))
+		for i, encoding := range batch {
+			exprs[i] = qb.AsBinary(qb.Convert(qb.Literal(charset, encoding), "utf8mb4"))
+		}
+		sqlOutputs, err := conn.QueryValuesContext(ctx, qb.Select(exprs...))
+		if err != nil {
+			return nil, err
+		}
+		if len(sqlOutputs) != len(batch) {
+			return nil, fmt.Errorf("converted %d encodings, but %d values were returned", len(batch), len(sqlOutputs))
+		}
+		for i, encoding := range batch {
+			if string(sqlOutputs[i]) == "?" && !bytes.Equal(encoding, questionMark) {
+				decodings[string(encoding)] = nil
+			} else {
+				decodings[string(encoding)] = sqlOutputs[i]
+			}
+		}
+	}
+	return decodings, nil
+}
+
+// uniqueRunes returns the given runes sorted, with duplicates removed.
+func uniqueRunes(runes []rune) []rune {
+	sorted := append([]rune{}, runes...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	unique := sorted[:0]
+	for i, r := range sorted {
+		if i == 0 || r != sorted[i-1] {
+			unique = append(unique, r)
+		}
+	}
+	return unique
+}
+
+// runeRanges returns a range for each of the given sorted runes, which restricts a UTF8Iter to only those runes.
+func runeRanges(runes []rune) [][2]rune {
+	ranges := make([][2]rune, len(runes))
+	for i, r := range runes {
+		ranges[i] = [2]rune{r, r}
+	}
+	return ranges
+}
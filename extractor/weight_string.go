@@ -0,0 +1,135 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extractor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+// CollationWeightString returns the server's WEIGHT_STRING output for the given string within the collation. When the
+// character length is greater than zero, the string is cast to a CHAR of that length before computing the weight.
+func CollationWeightString(ctx context.Context, conn utils.Queryable, collation string, charset string, str string, charLength int) ([]byte, error) {
+	qb := conn.Builder()
+	return conn.QueryContext(ctx, qb.Select(qb.WeightString(qb.InCollation([]byte(str), charset, collation), charLength)))
+}
+
+// CollationToWeightString returns the WeightString of the given collation, containing the weight of every rune from the
+// iterator that is valid in the character set. The number of levels and the padding behavior are determined from the
+// server. Collations with contractions or expansions cannot be represented, as the weights are retrieved per rune.
+func CollationToWeightString(ctx context.Context, conn utils.Queryable, collation string, charset string, iter *utils.UTF8Iter, rangeMap *utils.RangeMap, logf Logf) (*utils.WeightString, error) {
+	// Only the UCA 9.0.0 collations contain multiple levels, which are separated by two zero bytes
+	levels := 1
+	if strings.Contains(collation, "_0900_") {
+		aWeight, err := CollationWeightString(ctx, conn, collation, charset, "a", 0)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i+1 < len(aWeight); i += 2 {
+			if aWeight[i] == 0 && aWeight[i+1] == 0 {
+				levels++
+			}
+		}
+	}
+	// A padded string will have a longer weight if the collation pads strings when casting to a longer CHAR
+	unpadded, err := CollationWeightString(ctx, conn, collation, charset, "a", 0)
+	if err != nil {
+		return nil, err
+	}
+	padded, err := CollationWeightString(ctx, conn, collation, charset, "a", 3)
+	if err != nil {
+		return nil, err
+	}
+	ws := utils.NewWeightString(levels, len(padded) > len(unpadded))
+
+	progress := utils.NewProgress(collation, iter.Total(), logf)
+	for r, ok := iter.Next(); ok; r, ok = iter.Next() {
+		progress.Step(r)
+		// Ensure that this rune is a valid character in the character set, as we only want to process valid runes
+		if _, ok := rangeMap.Encode([]byte(string(r))); !ok {
+			continue
+		}
+		if ws.Weights[r], err = CollationWeightString(ctx, conn, collation, charset, string(r), 0); err != nil {
+			return nil, err
+		}
+	}
+	return ws, nil
+}
+
+// CollationContractions probes the collation with each candidate sequence, adding every sequence whose WEIGHT_STRING
+// differs from the weights of its runes to the WeightString's contractions. The WeightString must already contain the
+// weight of every rune, and candidates containing a rune without a weight are skipped. Candidates should be ordered by
+// length, so that a longer sequence is not reported because it contains a shorter contraction. Candidates are probed in
+// batches, with each candidate as a separate column of a single query.
+func CollationContractions(ctx context.Context, conn utils.Queryable, collation string, charset string, ws *utils.WeightString, candidates [][]rune, logf Logf) error {
+	qb := conn.Builder()
+	progress := utils.NewProgress(collation+" contractions", len(candidates), logf)
+	batch := make([][]rune, 0, utils.CharacterSetBatchSize)
+	exprs := make([]string, 0, utils.CharacterSetBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		exprs = exprs[:0]
+		for _, candidate := range batch {
+			exprs = append(exprs, qb.WeightString(qb.InCollation([]byte(string(candidate)), charset, collation), 0))
+		}
+		sqlOutputs, err := conn.QueryValuesContext(ctx, qb.Select(exprs...))
+		if err != nil {
+			return err
+		}
+		if len(sqlOutputs) != len(batch) {
+			return fmt.Errorf("probed %d contractions, but %d values were returned", len(batch), len(sqlOutputs))
+		}
+		for i, candidate := range batch {
+			// The expected weight includes any shorter contractions that were found in earlier batches
+			expected, _ := ws.Compute(string(candidate), 0)
+			if !bytes.Equal(expected, sqlOutputs[i]) {
+				ws.Contractions[string(candidate)] = sqlOutputs[i]
+				logf("%s: found the contraction `%s`", collation, string(candidate))
+			}
+		}
+		batch = batch[:0]
+		return nil
+	}
+	for _, candidate := range candidates {
+		progress.Step(candidate[0])
+		if _, ok := ws.Compute(string(candidate), 0); !ok {
+			continue
+		}
+		// A batch only contains candidates of the same length, as the shorter contractions must be known first
+		if len(batch) == utils.CharacterSetBatchSize || (len(batch) > 0 && len(batch[0]) != len(candidate)) {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		batch = append(batch, candidate)
+	}
+	return flush()
+}
+
+// CollationSortLength returns the SORTLEN of the given collation. MySQL reports a SORTLEN of zero for collations that do
+// not use a fixed length per character.
+func CollationSortLength(ctx context.Context, conn utils.Queryable, collation string) (int, error) {
+	metadata, err := CollationMetadata(ctx, conn, collation)
+	if err != nil {
+		return 0, err
+	}
+	return metadata.SortLength, nil
+}
@@ -0,0 +1,45 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+const (
+	TestGenerate_model = "./utf16.model.json"
+	TestGenerate_file  = "./utf16.go"
+)
+
+// TestGenerate creates a Go file from a model that was previously saved by TestExtractCharacterSet or
+// TestExtractCollation. This does not connect to a server, so changes to code generation may be applied to an existing
+// extraction in seconds.
+func TestGenerate(t *testing.T) {
+	model, err := utils.LoadModel(TestGenerate_model)
+	require.NoError(t, err)
+	require.NoError(t, utils.RegisteredExtractionHooks().BeforeCodegen(model))
+	// Models saved before the provenance was recorded generate files without it
+	if model.Provenance != nil {
+		StampProvenance(t, model.Provenance)
+	}
+	contents, err := model.GoFileWithOptions(WriteArtifact_codegen)
+	require.NoError(t, err)
+	WriteArtifact(t, TestGenerate_file, []byte(contents))
+	t.Logf("generated `%s` (%s) from `%s`", model.Name, model.Kind, TestGenerate_model)
+}
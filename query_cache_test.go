@@ -0,0 +1,46 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+const (
+	// The directory that caches query results for each server version, so that rerunning an extraction replays the
+	// results rather than querying the server. An empty directory disables the cache.
+	QueryCache_dir = ""
+	// Removes the cached results of the server's version before extracting, such as when the server's data may have
+	// changed without changing its version.
+	QueryCache_clear = false
+)
+
+// EnableQueryCache enables the QueryCache on the given connection for all tests that cache their queries, using the
+// options above. The cache is written when the connection is closed.
+func EnableQueryCache(t *testing.T, conn *utils.Connection) {
+	if QueryCache_dir == "" {
+		return
+	}
+	cache, err := conn.EnableQueryCache(QueryCache_dir)
+	require.NoError(t, err)
+	if QueryCache_clear {
+		require.NoError(t, cache.Clear())
+	}
+	t.Logf("using %d cached results for `%s`", cache.Len(), conn.Version())
+}
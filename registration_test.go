@@ -0,0 +1,38 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+const (
+	TestGenerateRegistration_manifest = "./manifest.json"
+	TestGenerateRegistration_file     = "./registration.go"
+)
+
+// TestGenerateRegistration creates a Go file that registers every character set and collation within the manifest by
+// name, so that a batch of extracted files may be copied into GMS without wiring each one by hand. This does not connect
+// to a server.
+func TestGenerateRegistration(t *testing.T) {
+	manifest, err := utils.LoadManifest(TestGenerateRegistration_manifest)
+	require.NoError(t, err)
+	WriteArtifact(t, TestGenerateRegistration_file, []byte(utils.RegistrationToGoFile(manifest, WriteArtifact_codegen)))
+	t.Logf("registered %d entries from `%s`", len(manifest.Entries), TestGenerateRegistration_manifest)
+}
@@ -0,0 +1,86 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+const (
+	TestShareWeightTables_manifest = "./manifest.json"
+	// TestShareWeightTables_output is the file that the shared tables are written to.
+	TestShareWeightTables_output = "./shared_weights.go"
+	// TestShareWeightTables_blockSize is the number of runes within each block that is compared across collations.
+	TestShareWeightTables_blockSize = utils.SharedWeightBlockSize
+)
+
+// TestShareWeightTables finds the blocks of weights that are identical across the collations in the manifest, writes
+// them to a single file, and rewrites the file of each collation to reference the shared blocks rather than listing them
+// within its own weight map. This should be run after TestDeduplicateCollations and TestDeltaCollations, as collations
+// that share another collation's weights (or are a delta from them) are skipped, and both regenerate files without any
+// references. Only collations that were extracted with a model are considered.
+func TestShareWeightTables(t *testing.T) {
+	manifest, err := utils.LoadManifest(TestShareWeightTables_manifest)
+	require.NoError(t, err)
+	var models []*utils.Model
+	for _, entry := range manifest.Entries {
+		if entry.Kind != utils.ManifestKindCollation || entry.Model == "" || entry.Shares != "" || entry.Delta != "" {
+			continue
+		}
+		model, err := utils.LoadModel(entry.Model)
+		require.NoError(t, err)
+		models = append(models, model)
+	}
+	shared, err := utils.FindSharedWeightTables(models, TestShareWeightTables_blockSize)
+	require.NoError(t, err)
+	sharedFile := WriteArtifact(t, TestShareWeightTables_output, []byte(utils.SharedWeightsToGoFile(shared, WriteArtifact_codegen)))
+
+	savedBytes := 0
+	for _, model := range models {
+		entry, _ := manifest.Get(model.Name, utils.ManifestKindCollation)
+		refs := shared.References[strings.ToLower(entry.Name)]
+		// A collation that no longer references any tables must contain its own weights again
+		if len(refs) == 0 && entry.SharedWeights == "" {
+			continue
+		}
+		rc, err := model.RuneComparator()
+		require.NoError(t, err)
+		contents := utils.RuneComparatorToGoFileWithOptions(rc, model.Name, model.PadSpace, utils.RuneComparatorGoFileOptions{
+			SharedWeights: refs,
+			Codegen:       ModelCodegen(model),
+		})
+		if info, err := os.Stat(entry.File); err == nil {
+			savedBytes += int(info.Size()) - len(contents)
+		}
+		entry.File = WriteArtifact(t, ArtifactBasePath(entry.File), []byte(contents))
+		entry.SharedWeights = ""
+		if len(refs) > 0 {
+			entry.SharedWeights = sharedFile
+			t.Logf("`%s` references %d shared tables", entry.Name, len(refs))
+		}
+		manifest.Set(entry)
+	}
+	if info, err := os.Stat(sharedFile); err == nil {
+		savedBytes -= int(info.Size())
+	}
+	require.NoError(t, manifest.Save(TestShareWeightTables_manifest))
+	t.Logf("%d collations share %d tables, saving roughly %d bytes", len(models), len(shared.Tables), savedBytes)
+}
@@ -0,0 +1,159 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/collation-extractor/extractor"
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+const (
+	TestSpotCheck_user     = "root"
+	TestSpotCheck_password = "password"
+	TestSpotCheck_host     = "localhost"
+	TestSpotCheck_port     = 3306
+	TestSpotCheck_name     = "utf16_unicode_ci" // Either a character set or a collation, depending on the file
+	TestSpotCheck_file     = "./" + TestSpotCheck_name + ".go.txt"
+	TestSpotCheck_samples  = 2000
+)
+
+// TestSpotCheck samples runes and random strings against a file that was previously generated by
+// TestExtractCharacterSet or TestExtractCollation, and reports whether the live server still agrees with the file. This
+// is intended to quickly answer whether a new MySQL version requires the file to be regenerated, and takes only a few
+// minutes rather than hours. Passing does not guarantee that the file is identical to a full extraction.
+func TestSpotCheck(t *testing.T) {
+	contents, err := utils.ReadArtifact(TestSpotCheck_file)
+	require.NoError(t, err)
+	conn, err := utils.NewConnection(TestSpotCheck_user, TestSpotCheck_password, TestSpotCheck_host, TestSpotCheck_port)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	random := rand.New(rand.NewSource(0))
+	samples := extractor.SampleRunes(random, TestSpotCheck_samples)
+
+	if rangeMap, _, err := utils.ParseRangeMapGoFile(string(contents)); err == nil {
+		spotCheckCharacterSet(t, conn, rangeMap, random, samples)
+		return
+	}
+	runeWeights, err := utils.ParseRuneComparatorGoFile(string(contents))
+	require.NoError(t, err)
+	spotCheckCollation(t, conn, runeWeights, random, samples)
+}
+
+// spotCheckCharacterSet checks that the server encodes the sampled runes (and strings built from them) the same as the
+// RangeMap.
+func spotCheckCharacterSet(t *testing.T, conn *utils.Connection, rangeMap *utils.RangeMap, random *rand.Rand, samples []rune) {
+	qb := conn.Builder()
+	convert := func(str string) []byte {
+		sqlOutput, err := conn.Query(qb.Select(qb.AsBinary(qb.InCharset([]byte(str), TestSpotCheck_name))))
+		require.NoError(t, err)
+		return sqlOutput
+	}
+
+	failures := 0
+	var validRunes []rune
+	for _, r := range samples {
+		sqlOutput := convert(string(r))
+		encoded, ok := rangeMap.Encode([]byte(string(r)))
+		// The server returns '?' for runes that do not exist in the character set
+		if len(sqlOutput) == 1 && sqlOutput[0] == 63 && r != 63 {
+			if !assert.False(t, ok, "rune %d is unmappable on the server but encodes to %v", r, encoded) {
+				failures++
+			}
+			continue
+		}
+		if !assert.True(t, ok && bytes.Equal(sqlOutput, encoded), "rune %d: server: %v, file: %v", r, sqlOutput, encoded) {
+			failures++
+			continue
+		}
+		validRunes = append(validRunes, r)
+	}
+	if len(validRunes) > 0 {
+		for i := 0; i < len(samples)/10; i++ {
+			str, expected := strings.Builder{}, []byte{}
+			for j := random.Intn(7) + 2; j > 0; j-- {
+				r := validRunes[random.Intn(len(validRunes))]
+				encoded, _ := rangeMap.Encode([]byte(string(r)))
+				str.WriteRune(r)
+				expected = append(expected, encoded...)
+			}
+			if sqlOutput := convert(str.String()); !assert.Equal(t, expected, sqlOutput, "string: %q", str.String()) {
+				failures++
+			}
+		}
+	}
+	t.Logf("spot check of `%s` finished with %d failures", TestSpotCheck_name, failures)
+}
+
+// spotCheckCollation checks that the server sorts pairs of the sampled runes (and strings built from them) the same as
+// the generated weights.
+func spotCheckCollation(t *testing.T, conn *utils.Connection, runeWeights *utils.RuneWeights, random *rand.Rand, samples []rune) {
+	qb := conn.Builder()
+	charset := strings.Split(TestSpotCheck_name, "_")[0]
+	var validRunes []rune
+	for _, r := range samples {
+		sqlOutput, err := conn.Query(qb.Select(qb.AsBinary(qb.InCharset([]byte(string(r)), charset))))
+		require.NoError(t, err)
+		if len(sqlOutput) == 1 && sqlOutput[0] == 63 && r != 63 {
+			continue
+		}
+		validRunes = append(validRunes, r)
+	}
+	if len(validRunes) < 2 {
+		t.Fatalf("not enough valid runes were sampled for `%s`", TestSpotCheck_name)
+	}
+
+	failures := 0
+	compare := func(l []rune, r []rune) {
+		sqlOutput, err := conn.Query(qb.Select(qb.Call("STRCMP",
+			qb.InCollation([]byte(string(l)), charset, TestSpotCheck_name),
+			qb.InCollation([]byte(string(r)), charset, TestSpotCheck_name))))
+		require.NoError(t, err)
+		expected := strconv.Itoa(runeWeights.Compare(string(l), string(r)))
+		if !assert.Equal(t, expected, string(sqlOutput), "left: %v, right: %v", l, r) {
+			failures++
+		}
+	}
+	for i := 1; i < len(validRunes); i++ {
+		compare([]rune{validRunes[i-1]}, []rune{validRunes[i]})
+	}
+	randomString := func() []rune {
+		runes := make([]rune, random.Intn(4)+1)
+		for i := range runes {
+			runes[i] = validRunes[random.Intn(len(validRunes))]
+		}
+		return runes
+	}
+	for i := 0; i < len(samples)/10; i++ {
+		compare(randomString(), randomString())
+	}
+	// Trailing spaces are only significant for NO PAD collations, so we compare strings against padded variants
+	for i := 0; i < len(samples)/100; i++ {
+		str := randomString()
+		padded := append(append([]rune{}, str...), []rune(strings.Repeat(" ", random.Intn(3)+1))...)
+		compare(str, padded)
+		compare(padded, append(str, validRunes[random.Intn(len(validRunes))]))
+	}
+	t.Logf("spot check of `%s` finished with %d failures", TestSpotCheck_name, failures)
+}
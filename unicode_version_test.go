@@ -0,0 +1,42 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+const (
+	// The DerivedAge.txt file from the Unicode Character Database (https://www.unicode.org/Public/UCD/latest/ucd/). An
+	// empty path iterates over every rune, which is not pinned to any Unicode version.
+	UnicodeVersion_derivedAge = ""
+	// The Unicode version that collation extraction is pinned to, such as "9.0". An empty version uses the version of
+	// the DerivedAge file.
+	UnicodeVersion_version = ""
+)
+
+// NewPinnedUTF8Iter returns a UTF8Iter for all tests that should only iterate over the runes of a specific Unicode
+// version, using the options above. Unassigned runes are not iterated over, so that the same runes are extracted
+// regardless of the machine or Go toolchain. Also returns the pinned version, which is empty when the iterator is not
+// pinned.
+func NewPinnedUTF8Iter(t *testing.T) (*utils.UTF8Iter, string) {
+	iter, version, err := utils.NewPinnedUTF8Iter(UnicodeVersion_derivedAge, UnicodeVersion_version)
+	require.NoError(t, err)
+	return iter, version
+}
@@ -0,0 +1,161 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// AccentSensitiveCollation returns the accent-sensitive counterpart of the given accent-insensitive collation (such as
+// `utf8mb4_0900_as_ci` for `utf8mb4_0900_ai_ci`), whose weights distinguish a rune from its accented forms. Returns an
+// empty string when the collation is not accent-insensitive.
+func AccentSensitiveCollation(collation string) string {
+	lowerName := strings.ToLower(collation)
+	if !strings.Contains(lowerName, "_ai_") && !strings.HasSuffix(lowerName, "_ai") {
+		return ""
+	}
+	parts := strings.Split(lowerName, "_")
+	for i, part := range parts {
+		if part == "ai" {
+			parts[i] = "as"
+		}
+	}
+	return strings.Join(parts, "_")
+}
+
+// AccentStripping returns the accent stripping of an accent-insensitive collation, which maps each rune to the base rune
+// that the collation considers equal to it (such as 'é' to 'e'). The base of each group of equal runes is the rune that
+// sorts first within the given accent-sensitive collation, as unaccented runes sort before their accented forms, while
+// the case of each rune is kept by choosing the base with the same case (using the case mappings), while runes without
+// case (such as 'ª') strip to a lowercase base when there is one. When the sensitive collation is nil, the base is the
+// rune with the lowest codepoint instead, which is only correct for scripts that encode their unaccented runes first
+// (such as Latin, but not Greek). Runes that strip to themselves are omitted, so an accent-sensitive collation returns
+// an empty stripping.
+func AccentStripping(rc *RuneComparator, sensitive *RuneComparator, caseMappings CaseMappings) map[rune]rune {
+	sensitiveWeights := make(map[rune]int)
+	if sensitive != nil {
+		for weight, row := range sensitive.rows() {
+			for _, r := range row {
+				sensitiveWeights[r] = weight
+			}
+		}
+	}
+	// caseOf returns whether the rune is lowercase (1), uppercase (2), or neither (0)
+	caseOf := func(r rune) int {
+		switch {
+		case singleRuneConversion(caseMappings.ToUpper, r) != r:
+			return 1
+		case singleRuneConversion(caseMappings.ToLower, r) != r:
+			return 2
+		default:
+			return 0
+		}
+	}
+
+	stripping := make(map[rune]rune)
+	for _, row := range rc.rows() {
+		if len(row) < 2 {
+			continue
+		}
+		bases := append([]rune(nil), row...)
+		if sensitive != nil {
+			lowestWeight := -1
+			for _, r := range row {
+				if weight, ok := sensitiveWeights[r]; ok && (lowestWeight == -1 || weight < lowestWeight) {
+					lowestWeight = weight
+				}
+			}
+			if lowestWeight != -1 {
+				bases = nil
+				for _, r := range row {
+					if weight, ok := sensitiveWeights[r]; ok && weight == lowestWeight {
+						bases = append(bases, r)
+					}
+				}
+			}
+		}
+		sort.Slice(bases, func(i, j int) bool {
+			return bases[i] < bases[j]
+		})
+		fallback := bases[0]
+		for _, candidate := range bases {
+			if caseOf(candidate) == 1 {
+				fallback = candidate
+				break
+			}
+		}
+		for _, r := range row {
+			base := fallback
+			for _, candidate := range bases {
+				if caseOf(r) != 0 && caseOf(candidate) == caseOf(r) {
+					base = candidate
+					break
+				}
+			}
+			if base != r {
+				stripping[r] = base
+			}
+		}
+	}
+	return stripping
+}
+
+// AccentStrippingToGoFile returns the given accent stripping as a Go file for inclusion in an application, alongside the
+// file that RuneComparatorToGoFile generated for the same collation. Stripping both sides of an accent-insensitive
+// LIKE (or a prefix search) allows the comparison to match runes directly rather than computing the weight of each rune.
+func AccentStrippingToGoFile(name string, stripping map[rune]rune, options CodegenOptions) string {
+	titleName, lowerName := options.names(name)
+	runes := make([]rune, 0, len(stripping))
+	for r := range stripping {
+		runes = append(runes, r)
+	}
+	sort.Slice(runes, func(i, j int) bool {
+		return runes[i] < runes[j]
+	})
+
+	sb := strings.Builder{}
+	sb.WriteString(options.fileHeader())
+	sb.WriteString(fmt.Sprintf(`
+
+import (
+	"strings"
+)
+
+// %[1]s_StripAccent returns the base rune of the given rune for the %[3]s collation. Two runes that
+// differ only in their accents have the same base rune, which keeps the case of the given rune.
+func %[1]s_StripAccent(r rune) rune {
+	if base, ok := %[2]s_accentTable[r]; ok {
+		return base
+	}
+	return r
+}
+
+// %[1]s_StripAccents returns the given string with every rune stripped using %[1]s_StripAccent.
+func %[1]s_StripAccents(str string) string {
+	return strings.Map(%[1]s_StripAccent, str)
+}
+
+// %[2]s_accentTable maps each rune that is not its own base to its base rune for the %[3]s
+// collation.
+var %[2]s_accentTable = map[rune]rune{
+`, titleName, lowerName, "`"+lowerName+"`"))
+	for _, r := range runes {
+		sb.WriteString(fmt.Sprintf("\t%d: %d, // %q -> %q\n", r, stripping[r], string(r), string(stripping[r])))
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
@@ -0,0 +1,54 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccentStripping(t *testing.T) {
+	caseMappings := NewCaseMappings()
+	for _, r := range "aAéÉeEάΆαΑ" {
+		caseMappings.Add(r, strings.ToUpper(string(r)), strings.ToLower(string(r)))
+	}
+
+	// The insensitive collation places every accent and case of a letter in the same row, while the sensitive one only
+	// places both cases together
+	insensitive := NewRuneComparatorFromOrder([][]rune{{'A', 'a', 'ª'}, {'E', 'e', 'É', 'é'}, {'Α', 'α', 'Ά', 'ά'}})
+	sensitive := NewRuneComparatorFromOrder([][]rune{{'A', 'a'}, {'ª'}, {'E', 'e'}, {'É', 'é'}, {'Α', 'α'}, {'Ά', 'ά'}})
+	assert.Equal(t, map[rune]rune{'ª': 'a', 'É': 'E', 'é': 'e', 'Ά': 'Α', 'ά': 'α'}, AccentStripping(insensitive, sensitive, caseMappings))
+	// Without the sensitive collation, the Greek runes strip to the accented runes that are encoded first
+	assert.Equal(t, map[rune]rune{'ª': 'a', 'É': 'E', 'é': 'e', 'Α': 'Ά', 'α': 'ά'}, AccentStripping(insensitive, nil, caseMappings))
+	assert.Empty(t, AccentStripping(sensitive, nil, caseMappings))
+
+	assert.Equal(t, "utf8mb4_0900_as_ci", AccentSensitiveCollation("utf8mb4_0900_ai_ci"))
+	assert.Equal(t, "utf8mb4_ja_0900_as_cs", AccentSensitiveCollation("utf8mb4_ja_0900_ai_cs"))
+	assert.Equal(t, "", AccentSensitiveCollation("utf8mb4_0900_as_ci"))
+	assert.Equal(t, "", AccentSensitiveCollation("latin1_general_ci"))
+
+	contents := AccentStrippingToGoFile("test_ai_ci", AccentStripping(insensitive, sensitive, caseMappings), CodegenOptions{})
+	file, err := parser.ParseFile(token.NewFileSet(), "", contents, 0)
+	require.NoError(t, err)
+	for _, name := range []string{"Test_ai_ci_StripAccent", "Test_ai_ci_StripAccents", "test_ai_ci_accentTable"} {
+		assert.NotNil(t, file.Scope.Lookup(name), name)
+	}
+	assert.Contains(t, contents, "\t233: 101, // \"é\" -> \"e\"\n")
+}
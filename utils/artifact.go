@@ -0,0 +1,157 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ArtifactOptions controls how generated artifacts are written.
+type ArtifactOptions struct {
+	// Gzip compresses each artifact, appending ".gz" to its path. Generated files are highly repetitive, so they
+	// compress well enough to attach to pull requests and issues.
+	Gzip bool
+	// TxtSuffix appends ".txt" to the path of Go files, so that they are not compiled when placed within a package.
+	TxtSuffix bool
+	// GMSRoot is the root directory of a go-mysql-server checkout. When set, Go files (and the tables that they embed)
+	// are written to the encodings package of the checkout rather than to their given directory, and are neither
+	// compressed nor suffixed, so that they compile in place. All other artifacts are unaffected.
+	GMSRoot string
+}
+
+// GMSEncodingsDir is the directory of the encodings package within a go-mysql-server checkout, which contains every
+// character set and collation.
+var GMSEncodingsDir = filepath.Join("sql", "encodings")
+
+// Artifact is a file that was written by WriteArtifact.
+type Artifact struct {
+	Path string
+	// Hash is the SHA-256 hash of the written file, which is the compressed file when compression is enabled.
+	Hash string
+}
+
+// WriteArtifact writes the given contents to the given path, modified by the given options. Go files are formatted
+// using FormatGoFile, so a Go file that does not parse returns an error rather than being written. Returns the written
+// artifact, whose path may differ from the given path.
+func WriteArtifact(path string, contents []byte, options ArtifactOptions) (Artifact, error) {
+	if strings.HasSuffix(path, ".go") {
+		formatted, err := FormatGoFile(contents)
+		if err != nil {
+			return Artifact{}, fmt.Errorf("`%s`: %w", path, err)
+		}
+		contents = formatted
+	}
+	if options.GMSRoot != "" && (strings.HasSuffix(path, ".go") || strings.HasSuffix(path, runeComparatorEmbeddedTableSuffix)) {
+		dir := filepath.Join(options.GMSRoot, GMSEncodingsDir)
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return Artifact{}, fmt.Errorf("`%s` is not a go-mysql-server checkout, as `%s` is not a directory", options.GMSRoot, dir)
+		}
+		path = filepath.Join(dir, filepath.Base(path))
+		options.Gzip = false
+		options.TxtSuffix = false
+	}
+	if options.TxtSuffix && strings.HasSuffix(path, ".go") {
+		path += ".txt"
+	}
+	if options.Gzip {
+		path += ".gz"
+		buf := bytes.Buffer{}
+		writer := gzip.NewWriter(&buf)
+		if _, err := writer.Write(contents); err != nil {
+			return Artifact{}, err
+		}
+		if err := writer.Close(); err != nil {
+			return Artifact{}, err
+		}
+		contents = buf.Bytes()
+	}
+	if err := os.WriteFile(path, contents, 0644); err != nil {
+		return Artifact{}, err
+	}
+	hash := sha256.Sum256(contents)
+	return Artifact{Path: path, Hash: hex.EncodeToString(hash[:])}, nil
+}
+
+// ReadArtifact reads the artifact at the given path, decompressing it if the path ends with ".gz". If the path does not
+// exist, then the compressed path is read instead, so that readers need not know how an artifact was written.
+func ReadArtifact(path string) ([]byte, error) {
+	contents, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !strings.HasSuffix(path, ".gz") {
+		path += ".gz"
+		contents, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, ".gz") {
+		return contents, nil
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(contents))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// UpdateArtifactIndex adds the given artifacts to the index at the given path, creating the index if it does not exist.
+// The index lists one artifact per line in the format of sha256sum, so that the artifacts of a run may be verified using
+// `sha256sum -c`. Artifacts replace existing lines with the same path, and lines are sorted by path.
+func UpdateArtifactIndex(indexPath string, artifacts ...Artifact) error {
+	hashes := make(map[string]string)
+	file, err := os.Open(indexPath)
+	if err == nil {
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			hash, path, ok := strings.Cut(scanner.Text(), "  ")
+			if !ok {
+				_ = file.Close()
+				return fmt.Errorf("malformed line in the artifact index `%s`: %s", indexPath, scanner.Text())
+			}
+			hashes[path] = hash
+		}
+		_ = file.Close()
+		if err = scanner.Err(); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for _, artifact := range artifacts {
+		hashes[artifact.Path] = artifact.Hash
+	}
+	paths := make([]string, 0, len(hashes))
+	for path := range hashes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	sb := strings.Builder{}
+	for _, path := range paths {
+		sb.WriteString(fmt.Sprintf("%s  %s\n", hashes[path], path))
+	}
+	return os.WriteFile(indexPath, []byte(sb.String()), 0644)
+}
@@ -0,0 +1,67 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteArtifactGoFile(t *testing.T) {
+	dir := t.TempDir()
+	rc := NewRuneComparatorFromOrder([][]rune{{'a'}, {'b', 'B'}, {'c'}})
+	contents := RuneComparatorToGoFile(rc, "test_collation", false)
+	artifact, err := WriteArtifact(filepath.Join(dir, "test_collation.go"), []byte(contents), ArtifactOptions{})
+	require.NoError(t, err)
+	written, err := os.ReadFile(artifact.Path)
+	require.NoError(t, err)
+	formatted, err := FormatGoFile(written)
+	require.NoError(t, err)
+	assert.Equal(t, string(formatted), string(written))
+
+	_, err = WriteArtifact(filepath.Join(dir, "malformed.go"), []byte("package encodings\n\nfunc {\n"), ArtifactOptions{})
+	assert.Error(t, err)
+	_, err = os.Stat(filepath.Join(dir, "malformed.go"))
+	assert.True(t, os.IsNotExist(err))
+
+	// Files that are not Go files are written as given
+	_, err = WriteArtifact(filepath.Join(dir, "weights.txt"), []byte("func {"), ArtifactOptions{})
+	assert.NoError(t, err)
+}
+
+func TestWriteArtifactGMSRoot(t *testing.T) {
+	dir := t.TempDir()
+	gmsRoot := filepath.Join(dir, "go-mysql-server")
+	options := ArtifactOptions{Gzip: true, TxtSuffix: true, GMSRoot: gmsRoot}
+	contents := []byte("package encodings\n")
+	_, err := WriteArtifact(filepath.Join(dir, "utf16.go"), contents, options)
+	assert.Error(t, err)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(gmsRoot, GMSEncodingsDir), 0755))
+	artifact, err := WriteArtifact(filepath.Join(dir, "utf16.go"), contents, options)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(gmsRoot, GMSEncodingsDir, "utf16.go"), artifact.Path)
+	artifact, err = WriteArtifact(filepath.Join(dir, RuneComparatorEmbeddedTableName("utf16_bin")), []byte{1, 2, 3}, options)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(gmsRoot, GMSEncodingsDir, "utf16_bin_weights.bin"), artifact.Path)
+	// Everything else is still written to its own directory
+	artifact, err = WriteArtifact(filepath.Join(dir, "utf16.model.json"), []byte("{}"), options)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "utf16.model.json.gz"), artifact.Path)
+}
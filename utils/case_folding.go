@@ -0,0 +1,118 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// CaseFolding returns the case folding of a collation, which maps each rune to the rune that it folds to. A rune folds
+// to its lowercase conversion when the collation considers both equal, which is how the collation treats case when
+// comparing strings. Runes without a lowercase conversion (such as 'ſ') fold to the lowercase conversion of their
+// uppercase conversion instead. Runes that fold to themselves are omitted, so a case-sensitive collation returns an
+// empty folding. Only single rune conversions are considered, as a fold must preserve the number of runes.
+func CaseFolding(rc *RuneComparator, caseMappings CaseMappings) map[rune]rune {
+	weights := make(map[rune]int)
+	for weight, row := range rc.rows() {
+		for _, r := range row {
+			weights[r] = weight
+		}
+	}
+	folding := make(map[rune]rune)
+	for r, weight := range weights {
+		lower := singleRuneConversion(caseMappings.ToLower, r)
+		lowerOfUpper := singleRuneConversion(caseMappings.ToLower, singleRuneConversion(caseMappings.ToUpper, r))
+		for _, candidate := range []rune{lower, lowerOfUpper} {
+			if candidateWeight, ok := weights[candidate]; ok && candidate != r && candidateWeight == weight {
+				folding[r] = candidate
+				break
+			}
+		}
+	}
+	// A rune may fold to a rune that folds further, so each fold is resolved to the end of its chain
+	for r, folded := range folding {
+		for steps := 0; steps < len(folding); steps++ {
+			next, ok := folding[folded]
+			if !ok || next == r {
+				break
+			}
+			folded = next
+		}
+		folding[r] = folded
+	}
+	return folding
+}
+
+// singleRuneConversion returns the conversion of the given rune when it converts to a single rune, and otherwise returns
+// the rune itself.
+func singleRuneConversion(conversions map[rune]string, r rune) rune {
+	converted, ok := conversions[r]
+	if !ok {
+		return r
+	}
+	if convertedRune, size := utf8.DecodeRuneInString(converted); size == len(converted) {
+		return convertedRune
+	}
+	return r
+}
+
+// CaseFoldingToGoFile returns the given case folding as a Go file for inclusion in an application, alongside the file
+// that RuneComparatorToGoFile generated for the same collation. Folding both sides of a case-insensitive comparison
+// (such as = or LIKE) allows the comparison to skip converting each rune to uppercase and then lowercase.
+func CaseFoldingToGoFile(name string, folding map[rune]rune, options CodegenOptions) string {
+	titleName, lowerName := options.names(name)
+	runes := make([]rune, 0, len(folding))
+	for r := range folding {
+		runes = append(runes, r)
+	}
+	sort.Slice(runes, func(i, j int) bool {
+		return runes[i] < runes[j]
+	})
+
+	sb := strings.Builder{}
+	sb.WriteString(options.fileHeader())
+	sb.WriteString(fmt.Sprintf(`
+
+import (
+	"strings"
+)
+
+// %[1]s_Fold returns the rune that the given rune folds to for the %[3]s collation. Two runes that differ
+// only in their case fold to the same rune.
+func %[1]s_Fold(r rune) rune {
+	if folded, ok := %[2]s_foldTable[r]; ok {
+		return folded
+	}
+	return r
+}
+
+// %[1]s_FoldString returns the given string with every rune folded using %[1]s_Fold.
+func %[1]s_FoldString(str string) string {
+	return strings.Map(%[1]s_Fold, str)
+}
+
+// %[2]s_foldTable maps each rune that does not fold to itself to the rune that it folds to for the
+// %[3]s collation.
+var %[2]s_foldTable = map[rune]rune{
+`, titleName, lowerName, "`"+lowerName+"`"))
+	for _, r := range runes {
+		sb.WriteString(fmt.Sprintf("\t%d: %d, // %q -> %q\n", r, folding[r], string(r), string(folding[r])))
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
@@ -0,0 +1,48 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaseFolding(t *testing.T) {
+	caseMappings := NewCaseMappings()
+	for _, r := range "aAbBsS" {
+		caseMappings.Add(r, strings.ToUpper(string(r)), strings.ToLower(string(r)))
+	}
+	caseMappings.Add('ſ', "S", "ſ")
+	caseMappings.Add('ß', "SS", "ß")
+
+	// The insensitive collation places each case of a letter in the same row, while the sensitive one does not
+	insensitive := NewRuneComparatorFromOrder([][]rune{{'A', 'a'}, {'B', 'b'}, {'S', 's', 'ſ'}, {'ß'}})
+	assert.Equal(t, map[rune]rune{'A': 'a', 'B': 'b', 'S': 's', 'ſ': 's'}, CaseFolding(insensitive, caseMappings))
+	sensitive := NewRuneComparatorFromOrder([][]rune{{'a'}, {'A'}, {'b'}, {'B'}, {'s'}, {'S'}, {'ſ'}, {'ß'}})
+	assert.Empty(t, CaseFolding(sensitive, caseMappings))
+
+	contents := CaseFoldingToGoFile("test_ci", CaseFolding(insensitive, caseMappings), CodegenOptions{})
+	file, err := parser.ParseFile(token.NewFileSet(), "", contents, 0)
+	require.NoError(t, err)
+	for _, name := range []string{"Test_ci_Fold", "Test_ci_FoldString", "test_ci_foldTable"} {
+		assert.NotNil(t, file.Scope.Lookup(name), name)
+	}
+	assert.Contains(t, contents, "\t383: 115, // \"ſ\" -> \"s\"\n")
+}
@@ -0,0 +1,124 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"sort"
+	"unicode/utf8"
+)
+
+// CaseMappings are the uppercase and lowercase conversions of a character set, mapping each rune to the string that it
+// converts to. Most conversions produce a single rune, yet some produce several (such as 'ß' to "SS" under full case
+// mapping), which is why the conversions are strings. Runes that convert to themselves are omitted.
+type CaseMappings struct {
+	ToUpper map[rune]string
+	ToLower map[rune]string
+}
+
+// NewCaseMappings returns empty CaseMappings.
+func NewCaseMappings() CaseMappings {
+	return CaseMappings{
+		ToUpper: make(map[rune]string),
+		ToLower: make(map[rune]string),
+	}
+}
+
+// caseMappingsFromConversions returns CaseMappings containing the given single rune conversions.
+func caseMappingsFromConversions(toUpper [][2]rune, toLower [][2]rune) CaseMappings {
+	cm := NewCaseMappings()
+	for _, conversion := range toUpper {
+		cm.ToUpper[conversion[0]] = string(conversion[1])
+	}
+	for _, conversion := range toLower {
+		cm.ToLower[conversion[0]] = string(conversion[1])
+	}
+	return cm
+}
+
+// Add records the uppercase and lowercase conversions of the given rune, skipping each conversion that returns the rune
+// itself.
+func (cm CaseMappings) Add(r rune, upper string, lower string) {
+	if upper != string(r) {
+		cm.ToUpper[r] = upper
+	}
+	if lower != string(r) {
+		cm.ToLower[r] = lower
+	}
+}
+
+// Merge adds every conversion from the given CaseMappings, replacing any conversion of the same rune.
+func (cm CaseMappings) Merge(other CaseMappings) {
+	for r, upper := range other.ToUpper {
+		cm.ToUpper[r] = upper
+	}
+	for r, lower := range other.ToLower {
+		cm.ToLower[r] = lower
+	}
+}
+
+// Conversions returns the conversions that produce a single rune, sorted by the rune that is converted (rather than the
+// rune that it converts to). These are the conversions that a RangeMap holds.
+func (cm CaseMappings) Conversions() (toUpper [][2]rune, toLower [][2]rune) {
+	return singleRuneConversions(cm.ToUpper), singleRuneConversions(cm.ToLower)
+}
+
+// Diff returns every rune whose conversion differs between the calling CaseMappings (the left side) and the given
+// CaseMappings, sorted by rune.
+func (cm CaseMappings) Diff(other CaseMappings) (toUpper []CaseMappingDifference, toLower []CaseMappingDifference) {
+	return diffCaseMappings(cm.ToUpper, other.ToUpper), diffCaseMappings(cm.ToLower, other.ToLower)
+}
+
+// MultiRune returns the conversions that do not produce exactly one rune, which a RangeMap cannot hold.
+func (cm CaseMappings) MultiRune() CaseMappings {
+	multiRune := NewCaseMappings()
+	for r, upper := range cm.ToUpper {
+		if utf8.RuneCountInString(upper) != 1 {
+			multiRune.ToUpper[r] = upper
+		}
+	}
+	for r, lower := range cm.ToLower {
+		if utf8.RuneCountInString(lower) != 1 {
+			multiRune.ToLower[r] = lower
+		}
+	}
+	return multiRune
+}
+
+// singleRuneConversions returns the entries of the given map that convert to a single rune, sorted by their source rune
+// (the key of the map).
+func singleRuneConversions(conversions map[rune]string) [][2]rune {
+	var pairs [][2]rune
+	for r, converted := range conversions {
+		if convertedRune, size := utf8.DecodeRuneInString(converted); size > 0 && size == len(converted) {
+			pairs = append(pairs, [2]rune{r, convertedRune})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i][0] < pairs[j][0]
+	})
+	return pairs
+}
+
+// sortedCaseMappingRunes returns the runes of the given map in ascending order.
+func sortedCaseMappingRunes(conversions map[rune]string) []rune {
+	runes := make([]rune, 0, len(conversions))
+	for r := range conversions {
+		runes = append(runes, r)
+	}
+	sort.Slice(runes, func(i, j int) bool {
+		return runes[i] < runes[j]
+	})
+	return runes
+}
@@ -0,0 +1,56 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaseMappings(t *testing.T) {
+	caseMappings := NewCaseMappings()
+	caseMappings.Add('a', "A", "a")
+	caseMappings.Add('A', "A", "a")
+	caseMappings.Add('ß', "SS", "ß")
+	caseMappings.Add('İ', "İ", "i̇")
+	toUpper, toLower := caseMappings.Conversions()
+	assert.Equal(t, [][2]rune{{'a', 'A'}}, toUpper)
+	assert.Equal(t, [][2]rune{{'A', 'a'}}, toLower)
+	multiRune := caseMappings.MultiRune()
+	assert.Equal(t, map[rune]string{'ß': "SS"}, multiRune.ToUpper)
+	assert.Equal(t, map[rune]string{'İ': "i̇"}, multiRune.ToLower)
+
+	// The multi-rune conversions are declared next to the RangeMap, and are read back by the parser
+	rangeMap, err := RangeMapFromTree(consolidationTestTrees()["euc"])
+	require.NoError(t, err)
+	contents := RangeMapToGoFile(rangeMap, caseMappings, "euc")
+	assert.Contains(t, contents, "var euc_MultiRuneToUpper = map[rune]string{\n\t223: \"SS\",\n}")
+	_, parsedCaseMappings, err := ParseRangeMapGoFile(contents)
+	require.NoError(t, err)
+	assert.Equal(t, caseMappings, parsedCaseMappings)
+	assert.NotContains(t, RangeMapToGoFile(rangeMap, caseMappingsFromConversions(toUpper, toLower), "euc"), "MultiRune")
+
+	// Models keep both forms of conversions
+	for _, name := range []string{"euc.model.json", "euc.model.bin"} {
+		path := filepath.Join(t.TempDir(), name)
+		require.NoError(t, NewCharacterSetModel("euc", rangeMap, caseMappings).Save(path))
+		model, err := LoadModel(path)
+		require.NoError(t, err)
+		assert.Equal(t, caseMappings, model.CaseMappings(), name)
+	}
+}
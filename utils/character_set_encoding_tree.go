@@ -15,6 +15,8 @@
 package utils
 
 import (
+	"bytes"
+	"fmt"
 	"sort"
 )
 
@@ -22,12 +24,26 @@ import (
 // decoded by processing bytes until data is found (or not further trees were returned, indicating an invalid byte
 // sequence).
 type CharacterSetEncodingTree struct {
-	data  []byte
-	nodes map[byte]*CharacterSetEncodingTree
+	data []byte
+	// nodes is nil for leaves, which are the majority of the nodes
+	nodes *encodingTreeNodes
 	min   byte
 	max   byte
 }
 
+// encodingTreeNodes are the subtrees of a CharacterSetEncodingTree. Large character sets have over a million nodes, so
+// a map per node has a large overhead, while leaves have no subtrees at all. Subtrees are therefore stored as a sorted
+// sparse array, which becomes an array indexed by the value once at least half of the values between the minimum and
+// maximum are present. Both representations are kept in ascending order.
+type encodingTreeNodes struct {
+	// vals contains the value of each subtree in ascending order, and is nil when the subtrees are dense.
+	vals []byte
+	// trees contains the subtree of each value within vals. When the subtrees are dense, this contains the subtree of
+	// every value from the tree's minimum to its maximum, where missing values are nil.
+	trees []*CharacterSetEncodingTree
+	count int
+}
+
 // CharacterSetEncodingContinuation is used to control exactly when the tree continues its search. This allows for
 // proper code generation.
 type CharacterSetEncodingContinuation struct {
@@ -37,25 +53,55 @@ type CharacterSetEncodingContinuation struct {
 }
 
 // CharacterSetEncodingIterator iterates through a CharacterSetEncodingTree, returning all valid encodings. The
-// encodings are ordered from shortest to longest (byte slice length), and also in ascending order.
+// encodings are ordered from shortest to longest (byte slice length), and also in ascending order, unless the iterator
+// was created using options that state otherwise.
 type CharacterSetEncodingIterator struct {
 	trees    []*CharacterSetEncodingTree
 	progress []int
 	depth    int
+	// minDepth and maxDepth bound the depth, where minDepth is inclusive and maxDepth is exclusive
+	minDepth     int
+	maxDepth     int
+	longestFirst bool
+	start        []byte
+	end          []byte
+}
+
+// EncodingIteratorOptions restrict the encodings that a CharacterSetEncodingIterator returns, along with their order.
+// The zero value returns every encoding. Encodings outside of the bounds are skipped without visiting their subtrees,
+// so a small region of a large tree may be iterated quickly, such as to re-extract the region or to split the tree
+// into chunks.
+type EncodingIteratorOptions struct {
+	// MinLength is the length of the shortest encoding to return. Zero returns encodings of every length.
+	MinLength int
+	// MaxLength is the length of the longest encoding to return. Zero returns encodings of every length.
+	MaxLength int
+	// Start is the lowest encoding to return, compared lexicographically. Nil starts from the lowest encoding.
+	Start []byte
+	// End is the encoding that follows the last encoding to return, compared lexicographically. Nil continues through
+	// the highest encoding.
+	End []byte
+	// LongestFirst returns the encodings from longest to shortest, while each length is still in ascending order.
+	LongestFirst bool
 }
 
 // NewCharacterSetEncodingTree returns a new CharacterSetEncodingTree.
 func NewCharacterSetEncodingTree() *CharacterSetEncodingTree {
-	return &CharacterSetEncodingTree{
-		data:  nil,
-		nodes: make(map[byte]*CharacterSetEncodingTree),
-	}
+	return &CharacterSetEncodingTree{}
 }
 
 // AddChild adds the given value to the tree, returning the newly created subtree (or, if the subtree already existed,
 // the existing subtree).
 func (cset *CharacterSetEncodingTree) AddChild(val byte) *CharacterSetEncodingTree {
-	if len(cset.nodes) == 0 {
+	if subtree := cset.Child(val); subtree != nil {
+		return subtree
+	}
+	child := &CharacterSetEncodingTree{}
+	if cset.nodes == nil {
+		cset.nodes = &encodingTreeNodes{}
+	}
+	cset.nodes.add(val, child, cset.min, cset.max)
+	if cset.nodes.count == 1 {
 		cset.min = val
 		cset.max = val
 	} else if val < cset.min {
@@ -63,20 +109,23 @@ func (cset *CharacterSetEncodingTree) AddChild(val byte) *CharacterSetEncodingTr
 	} else if val > cset.max {
 		cset.max = val
 	}
-	if subtree, ok := cset.nodes[val]; ok {
-		return subtree
-	}
-	child := &CharacterSetEncodingTree{
-		data:  nil,
-		nodes: make(map[byte]*CharacterSetEncodingTree),
-	}
-	cset.nodes[val] = child
+	cset.nodes.compact(cset.min, cset.max)
 	return child
 }
 
 // SetData sets this tree's data to the given data. Returns false if this tree has subtrees, or data was set previously.
 func (cset *CharacterSetEncodingTree) SetData(data []byte) bool {
-	if len(cset.nodes) > 0 || cset.data != nil {
+	if cset.nodes != nil || cset.data != nil {
+		return false
+	}
+	cset.data = data
+	return true
+}
+
+// ReplaceData replaces this tree's data with the given data. Returns false if this tree has subtrees, or data was not
+// set previously.
+func (cset *CharacterSetEncodingTree) ReplaceData(data []byte) bool {
+	if cset.nodes != nil || cset.data == nil {
 		return false
 	}
 	cset.data = data
@@ -85,10 +134,10 @@ func (cset *CharacterSetEncodingTree) SetData(data []byte) bool {
 
 // Child returns the subtree belonging to the given value. If the value has no subtree, then nil is returned.
 func (cset *CharacterSetEncodingTree) Child(val byte) *CharacterSetEncodingTree {
-	if cset == nil || cset.nodes == nil {
+	if cset == nil || cset.nodes == nil || val < cset.min || val > cset.max {
 		return nil
 	}
-	return cset.nodes[val]
+	return cset.nodes.get(val, cset.min)
 }
 
 // Data returns the data contained in this tree. Data will only be present if there are no subtrees, and also if data
@@ -100,19 +149,76 @@ func (cset *CharacterSetEncodingTree) Data() []byte {
 	return cset.data
 }
 
+// Merge adds all encodings from the given tree to the calling tree. Encodings that are present in both trees must have
+// the same data, otherwise an error is returned. An error is also returned if an encoding from one tree is a prefix of
+// an encoding from the other tree, as the resulting tree would not be able to decode both encodings.
+func (cset *CharacterSetEncodingTree) Merge(other *CharacterSetEncodingTree) error {
+	iter := other.Iterator()
+	for inputEncoding, outputEncoding, ok := iter.Next(); ok; inputEncoding, outputEncoding, ok = iter.Next() {
+		tree := cset
+		for _, byteVal := range inputEncoding {
+			if tree.data != nil {
+				return fmt.Errorf("encoding %v conflicts with an existing shorter encoding", inputEncoding)
+			}
+			tree = tree.AddChild(byteVal)
+		}
+		if !tree.SetData(outputEncoding) {
+			if tree.data == nil {
+				return fmt.Errorf("encoding %v conflicts with an existing longer encoding", inputEncoding)
+			}
+			if !bytes.Equal(tree.data, outputEncoding) {
+				return fmt.Errorf("encoding %v has the data %v which conflicts with the existing data %v",
+					inputEncoding, outputEncoding, tree.data)
+			}
+		}
+	}
+	return nil
+}
+
 // Iterator returns a CharacterSetEncodingIterator that will iterate over this CharacterSetEncodingTree, returning all
 // valid encodings. The encodings are ordered from shortest to longest (byte slice length), and also in ascending order.
 func (cset *CharacterSetEncodingTree) Iterator() *CharacterSetEncodingIterator {
+	return cset.IteratorWithOptions(EncodingIteratorOptions{})
+}
+
+// IteratorWithOptions is the same as Iterator, except that only the encodings within the bounds of the options are
+// returned, in the order given by the options.
+func (cset *CharacterSetEncodingTree) IteratorWithOptions(options EncodingIteratorOptions) *CharacterSetEncodingIterator {
 	csei := &CharacterSetEncodingIterator{
-		trees:    make([]*CharacterSetEncodingTree, 1, 4),
-		progress: make([]int, 1, 4),
-		depth:    0,
+		trees:        make([]*CharacterSetEncodingTree, 1, 4),
+		progress:     make([]int, 1, 4),
+		maxDepth:     cset.maxDepth(),
+		longestFirst: options.LongestFirst,
+		start:        options.Start,
+		end:          options.End,
+	}
+	if options.MinLength > 1 {
+		csei.minDepth = options.MinLength - 1
+	}
+	if options.MaxLength > 0 && options.MaxLength < csei.maxDepth {
+		csei.maxDepth = options.MaxLength
+	}
+	csei.depth = csei.minDepth
+	if csei.longestFirst {
+		csei.depth = csei.maxDepth - 1
 	}
 	csei.trees[0] = cset
 	csei.progress[0] = int(cset.min)
 	return csei
 }
 
+// maxDepth returns the length of the longest encoding in the tree. Most character sets have a maximum length of 4, but
+// some (such as the filename character set) have longer encodings.
+func (cset *CharacterSetEncodingTree) maxDepth() int {
+	maxDepth := 0
+	cset.nodes.each(cset.min, func(_ byte, subtree *CharacterSetEncodingTree) {
+		if depth := subtree.maxDepth() + 1; depth > maxDepth {
+			maxDepth = depth
+		}
+	})
+	return maxDepth
+}
+
 // DFS iterates through the tree using depth-first-search, while passing each subtree's information to the given
 // function. The root tree (i.e. the one that this function is being called on) has a depth of 0, and also has a value
 // of 0 (as there is no value associated with the root). This iterates through the subtrees sorted by their value
@@ -130,7 +236,7 @@ func (cset *CharacterSetEncodingTree) DFS(
 		depth:     0,
 		inputFunc: inputFunc,
 	}
-	return inputFunc(continuation, 0, cset.data != nil && len(cset.nodes) == 0, 0, cset.data)
+	return inputFunc(continuation, 0, cset.data != nil && cset.nodes == nil, 0, cset.data)
 }
 
 // dfs is the inner function of DFS that actually handles the recursive logic.
@@ -140,12 +246,10 @@ func (cset *CharacterSetEncodingTree) dfs(currentDepth int,
 		val  byte
 		tree *CharacterSetEncodingTree
 	}
-	sortedSubtrees := make([]subtree, 0, len(cset.nodes))
-	for val, tree := range cset.nodes {
+	// The subtrees are copied, as the function may modify the tree
+	var sortedSubtrees []subtree
+	cset.nodes.each(cset.min, func(val byte, tree *CharacterSetEncodingTree) {
 		sortedSubtrees = append(sortedSubtrees, subtree{val, tree})
-	}
-	sort.Slice(sortedSubtrees, func(i, j int) bool {
-		return sortedSubtrees[i].val < sortedSubtrees[j].val
 	})
 	for _, st := range sortedSubtrees {
 		continuation := CharacterSetEncodingContinuation{
@@ -153,7 +257,7 @@ func (cset *CharacterSetEncodingTree) dfs(currentDepth int,
 			depth:     currentDepth + 1,
 			inputFunc: inputFunc,
 		}
-		err := inputFunc(continuation, currentDepth+1, st.tree.data != nil && len(st.tree.nodes) == 0, st.val, st.tree.data)
+		err := inputFunc(continuation, currentDepth+1, st.tree.data != nil && st.tree.nodes == nil, st.val, st.tree.data)
 		if err != nil {
 			return err
 		}
@@ -171,7 +275,7 @@ func (cont *CharacterSetEncodingContinuation) Continue() error {
 // Returns false if there are no more encodings to iterate through.
 func (csei *CharacterSetEncodingIterator) Next() (inputEncoding []byte, outputEncoding []byte, ok bool) {
 	// Iteration works in a few steps:
-	// 1) Check the depth. If it is beyond the maximum possible encoding length (currently 4), then we return.
+	// 1) Check the depth. If it is beyond the longest encoding in the tree, then we return.
 	// 2) Check if the progress on the current level is beyond the max valid encoding.
 	//    a) If we are not at level zero, then we decrement our level is increment that level's progress.
 	//    b) If we are at level zero, we increment the depth requirement and reset our progress.
@@ -180,8 +284,8 @@ func (csei *CharacterSetEncodingIterator) Next() (inputEncoding []byte, outputEn
 	//       the progress for the next loop). Otherwise, we just increment our progress.
 	//    b) If our level is less than the depth, then we add a new level with the found subtree.
 	for true {
-		// Largest encoding is 4 bytes deep, so we can immediately return if we've gone beyond that
-		if csei.depth >= 4 {
+		// We can immediately return once we've gone beyond the longest (or shortest) encoding
+		if csei.depth >= csei.maxDepth || csei.depth < csei.minDepth {
 			return nil, nil, false
 		}
 		depth := csei.depth
@@ -191,9 +295,13 @@ func (csei *CharacterSetEncodingIterator) Next() (inputEncoding []byte, outputEn
 
 		// Here we check if the progress is beyond the max
 		if progress > int(tree.max) {
-			// Level 0 is as low as we can go, so we increment the depth instead
+			// Level 0 is as low as we can go, so we move to the next depth instead
 			if level == 0 {
-				csei.depth++
+				if csei.longestFirst {
+					csei.depth--
+				} else {
+					csei.depth++
+				}
 				csei.progress[level] = int(tree.min)
 				continue
 			} else {
@@ -204,14 +312,14 @@ func (csei *CharacterSetEncodingIterator) Next() (inputEncoding []byte, outputEn
 			}
 		}
 
-		subtree, ok := tree.nodes[byte(progress)]
-		if !ok {
+		subtree := tree.Child(byte(progress))
+		if subtree == nil || csei.outsideWindow(level, level == depth) {
 			csei.progress[level]++
 			continue
 		}
 		if level == depth {
 			// Since the level matches the depth, we're checking for data (which will be leafs)
-			if subtree.data != nil && len(subtree.nodes) == 0 {
+			if subtree.data != nil && subtree.nodes == nil {
 				inputEncoding = make([]byte, len(csei.progress))
 				for i := 0; i < len(inputEncoding); i++ {
 					inputEncoding[i] = byte(csei.progress[i])
@@ -226,7 +334,7 @@ func (csei *CharacterSetEncodingIterator) Next() (inputEncoding []byte, outputEn
 			}
 		} else {
 			// Level is less than the depth
-			if len(subtree.nodes) == 0 {
+			if subtree.nodes == nil {
 				// Our current subtree on this level has no subtrees of its own, so we increment our progress
 				csei.progress[level]++
 				continue
@@ -239,3 +347,130 @@ func (csei *CharacterSetEncodingIterator) Next() (inputEncoding []byte, outputEn
 	}
 	return nil, nil, false
 }
+
+// outsideWindow returns whether every encoding that begins with the progress up to the given level is outside of the
+// start and end of the iterator. The final level is the entire encoding, which is outside of the window when it's a
+// prefix of the start.
+func (csei *CharacterSetEncodingIterator) outsideWindow(level int, final bool) bool {
+	if csei.start != nil {
+		equal := true
+		for i := 0; i <= level && i < len(csei.start); i++ {
+			if progress := byte(csei.progress[i]); progress != csei.start[i] {
+				if progress < csei.start[i] {
+					return true
+				}
+				equal = false
+				break
+			}
+		}
+		if equal && final && level+1 < len(csei.start) {
+			return true
+		}
+	}
+	if csei.end != nil {
+		for i := 0; i <= level; i++ {
+			// Every encoding that begins with the end is after the end
+			if i == len(csei.end) {
+				return true
+			}
+			if progress := byte(csei.progress[i]); progress != csei.end[i] {
+				return progress > csei.end[i]
+			}
+		}
+		// The encoding equals the end, which is exclusive
+		if final && level+1 == len(csei.end) {
+			return true
+		}
+	}
+	return false
+}
+
+// get returns the subtree of the given value, or nil if there is none. The minimum is the tree's minimum value.
+func (nodes *encodingTreeNodes) get(val byte, min byte) *CharacterSetEncodingTree {
+	if nodes.vals == nil {
+		return nodes.trees[val-min]
+	}
+	idx := sort.Search(len(nodes.vals), func(i int) bool {
+		return nodes.vals[i] >= val
+	})
+	if idx < len(nodes.vals) && nodes.vals[idx] == val {
+		return nodes.trees[idx]
+	}
+	return nil
+}
+
+// add adds a subtree for a value that does not have one. The minimum and maximum are the tree's values before the
+// subtree is added.
+func (nodes *encodingTreeNodes) add(val byte, tree *CharacterSetEncodingTree, min byte, max byte) {
+	nodes.count++
+	if nodes.count == 1 {
+		nodes.vals = []byte{val}
+		nodes.trees = []*CharacterSetEncodingTree{tree}
+		return
+	}
+	if nodes.vals == nil {
+		// Dense subtrees are extended to cover the new value
+		if val < min {
+			trees := make([]*CharacterSetEncodingTree, int(max-val)+1)
+			copy(trees[min-val:], nodes.trees)
+			nodes.trees = trees
+			min = val
+		} else if val > max {
+			nodes.trees = append(nodes.trees, make([]*CharacterSetEncodingTree, val-max)...)
+		}
+		nodes.trees[val-min] = tree
+		return
+	}
+	idx := sort.Search(len(nodes.vals), func(i int) bool {
+		return nodes.vals[i] >= val
+	})
+	nodes.vals = append(nodes.vals, 0)
+	copy(nodes.vals[idx+1:], nodes.vals[idx:])
+	nodes.vals[idx] = val
+	nodes.trees = append(nodes.trees, nil)
+	copy(nodes.trees[idx+1:], nodes.trees[idx:])
+	nodes.trees[idx] = tree
+}
+
+// compact switches between the sparse and dense representations, using whichever is smaller for the current subtrees.
+// The subtrees are dense when at least half of the values from the minimum to the maximum are present.
+func (nodes *encodingTreeNodes) compact(min byte, max byte) {
+	dense := int(max-min)+1 <= 2*nodes.count
+	if dense == (nodes.vals == nil) {
+		return
+	}
+	if dense {
+		trees := make([]*CharacterSetEncodingTree, int(max-min)+1)
+		for i, val := range nodes.vals {
+			trees[val-min] = nodes.trees[i]
+		}
+		nodes.vals = nil
+		nodes.trees = trees
+	} else {
+		vals := make([]byte, 0, nodes.count)
+		trees := make([]*CharacterSetEncodingTree, 0, nodes.count)
+		for i, tree := range nodes.trees {
+			if tree != nil {
+				vals = append(vals, min+byte(i))
+				trees = append(trees, tree)
+			}
+		}
+		nodes.vals = vals
+		nodes.trees = trees
+	}
+}
+
+// each calls the given function for every subtree in ascending order of their values. The minimum is the tree's
+// minimum value. Does nothing when the nodes are nil.
+func (nodes *encodingTreeNodes) each(min byte, f func(val byte, tree *CharacterSetEncodingTree)) {
+	if nodes == nil {
+		return
+	}
+	for i, tree := range nodes.trees {
+		if nodes.vals != nil {
+			f(nodes.vals[i], tree)
+		} else if tree != nil {
+			f(min+byte(i), tree)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// EncodingTreeDifference is a codepoint that differs between two trees. Left is nil when the codepoint is only in the
+// other tree, and Right is nil when the codepoint is only in the calling tree.
+type EncodingTreeDifference struct {
+	Encoding []byte
+	Left     []byte
+	Right    []byte
+}
+
+// String returns the difference as a human-readable string.
+func (diff EncodingTreeDifference) String() string {
+	switch {
+	case diff.Left == nil:
+		return fmt.Sprintf("0x%X was added, decoding to 0x%X", diff.Encoding, diff.Right)
+	case diff.Right == nil:
+		return fmt.Sprintf("0x%X was removed, which decoded to 0x%X", diff.Encoding, diff.Left)
+	default:
+		return fmt.Sprintf("0x%X decoded to 0x%X, and now decodes to 0x%X", diff.Encoding, diff.Left, diff.Right)
+	}
+}
+
+// Diff returns every codepoint that is only in one of the trees, along with every codepoint whose data differs between
+// the trees. The calling tree is the left side, so this may be used to compare extractions from different server
+// versions (or between MySQL and Dolt) to detect mappings that have drifted. The differences are in the same order as
+// the Iterator.
+func (cset *CharacterSetEncodingTree) Diff(other *CharacterSetEncodingTree) []EncodingTreeDifference {
+	var diffs []EncodingTreeDifference
+	leftIter := cset.Iterator()
+	rightIter := other.Iterator()
+	leftEncoding, leftData, leftOk := leftIter.Next()
+	rightEncoding, rightData, rightOk := rightIter.Next()
+	for leftOk || rightOk {
+		// The iterators return the encodings sorted by their length and then by their value
+		comparison := 0
+		if !leftOk {
+			comparison = 1
+		} else if !rightOk {
+			comparison = -1
+		} else if len(leftEncoding) != len(rightEncoding) {
+			comparison = len(leftEncoding) - len(rightEncoding)
+		} else {
+			comparison = bytes.Compare(leftEncoding, rightEncoding)
+		}
+		switch {
+		case comparison < 0:
+			diffs = append(diffs, EncodingTreeDifference{Encoding: leftEncoding, Left: leftData})
+			leftEncoding, leftData, leftOk = leftIter.Next()
+		case comparison > 0:
+			diffs = append(diffs, EncodingTreeDifference{Encoding: rightEncoding, Right: rightData})
+			rightEncoding, rightData, rightOk = rightIter.Next()
+		default:
+			if !bytes.Equal(leftData, rightData) {
+				diffs = append(diffs, EncodingTreeDifference{Encoding: leftEncoding, Left: leftData, Right: rightData})
+			}
+			leftEncoding, leftData, leftOk = leftIter.Next()
+			rightEncoding, rightData, rightOk = rightIter.Next()
+		}
+	}
+	return diffs
+}
@@ -0,0 +1,56 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodingTreeDiff(t *testing.T) {
+	left := consolidationTestTrees()["euc"]
+	right := consolidationTestTrees()["euc"]
+	assert.Empty(t, left.Diff(right))
+
+	// A longer encoding is added, an ASCII codepoint is removed, and a codepoint now decodes to another rune
+	right.AddChild(0x8E).AddChild(0xA1).SetData([]byte("ｱ"))
+	removed := NewCharacterSetEncodingTree()
+	iter := right.Iterator()
+	for inputEncoding, outputEncoding, ok := iter.Next(); ok; inputEncoding, outputEncoding, ok = iter.Next() {
+		if inputEncoding[0] == 'A' {
+			continue
+		}
+		if inputEncoding[0] == 0xA1 && inputEncoding[1] == 0xA9 {
+			outputEncoding = []byte("〉")
+		}
+		node := removed
+		for _, val := range inputEncoding {
+			node = node.AddChild(val)
+		}
+		node.SetData(outputEncoding)
+	}
+	diffs := left.Diff(removed)
+	require.Len(t, diffs, 3)
+	assert.Equal(t, EncodingTreeDifference{Encoding: []byte{'A'}, Left: []byte{'A'}}, diffs[0])
+	assert.Equal(t, EncodingTreeDifference{Encoding: []byte{0x8E, 0xA1}, Right: []byte("ｱ")}, diffs[1])
+	assert.Equal(t, EncodingTreeDifference{Encoding: []byte{0xA1, 0xA9}, Left: []byte("〈"), Right: []byte("〉")}, diffs[2])
+	// Swapping the trees swaps the sides of every difference
+	swapped := removed.Diff(left)
+	require.Len(t, swapped, 3)
+	assert.Equal(t, diffs[2].Left, swapped[2].Right)
+	assert.Nil(t, swapped[0].Left)
+}
@@ -0,0 +1,152 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// encodingTreeMagic identifies a serialized CharacterSetEncodingTree, which is followed by the version of the format.
+var encodingTreeMagic = []byte("CSET")
+
+// encodingTreeVersion is the version of the serialization format, which is incremented whenever the format changes.
+const encodingTreeVersion = 1
+
+// Serialize writes the tree to the given writer using a compact binary format, which Deserialize reads. Each node is
+// written in depth-first order as its data (a length followed by the bytes, with a length of zero for nodes without
+// data) followed by the number of children, and then each child's byte value and node in ascending order. Encodings
+// share the bytes of their common prefixes, so this is far smaller than a Model for large character sets.
+func (cset *CharacterSetEncodingTree) Serialize(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if _, err := bw.Write(encodingTreeMagic); err != nil {
+		return err
+	}
+	if err := bw.WriteByte(encodingTreeVersion); err != nil {
+		return err
+	}
+	if err := cset.serializeNode(bw, make([]byte, binary.MaxVarintLen64)); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// serializeNode writes the calling node and all of its children. The buffer is used to write each length.
+func (cset *CharacterSetEncodingTree) serializeNode(bw *bufio.Writer, buf []byte) error {
+	if _, err := bw.Write(buf[:binary.PutUvarint(buf, uint64(len(cset.data)))]); err != nil {
+		return err
+	}
+	if _, err := bw.Write(cset.data); err != nil {
+		return err
+	}
+	count := 0
+	if cset.nodes != nil {
+		count = cset.nodes.count
+	}
+	if _, err := bw.Write(buf[:binary.PutUvarint(buf, uint64(count))]); err != nil {
+		return err
+	}
+	var err error
+	cset.nodes.each(cset.min, func(val byte, subtree *CharacterSetEncodingTree) {
+		if err == nil {
+			if err = bw.WriteByte(val); err == nil {
+				err = subtree.serializeNode(bw, buf)
+			}
+		}
+	})
+	return err
+}
+
+// DeserializeCharacterSetEncodingTree reads a tree that was written by Serialize.
+func DeserializeCharacterSetEncodingTree(r io.Reader) (*CharacterSetEncodingTree, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(encodingTreeMagic)+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("unable to read the encoding tree header: %w", err)
+	}
+	if !bytes.Equal(header[:len(encodingTreeMagic)], encodingTreeMagic) {
+		return nil, fmt.Errorf("the data is not a serialized encoding tree")
+	}
+	if version := header[len(encodingTreeMagic)]; version != encodingTreeVersion {
+		return nil, fmt.Errorf("the encoding tree has version %d, while this tool only reads version %d", version, encodingTreeVersion)
+	}
+	tree := NewCharacterSetEncodingTree()
+	if err := tree.deserializeNode(br); err != nil {
+		return nil, fmt.Errorf("unable to read the encoding tree: %w", err)
+	}
+	return tree, nil
+}
+
+// deserializeNode reads the data and children of the calling node.
+func (cset *CharacterSetEncodingTree) deserializeNode(br *bufio.Reader) error {
+	dataLength, err := binary.ReadUvarint(br)
+	if err != nil {
+		return err
+	}
+	if dataLength > 0 {
+		data := make([]byte, dataLength)
+		if _, err = io.ReadFull(br, data); err != nil {
+			return err
+		}
+		cset.SetData(data)
+	}
+	childCount, err := binary.ReadUvarint(br)
+	if err != nil {
+		return err
+	}
+	if childCount > 256 {
+		return fmt.Errorf("a node has %d children, which is more than the number of byte values", childCount)
+	}
+	if childCount > 0 && cset.data != nil {
+		return fmt.Errorf("a node has both data and children")
+	}
+	for i := uint64(0); i < childCount; i++ {
+		val, err := br.ReadByte()
+		if err != nil {
+			return err
+		}
+		if err = cset.AddChild(val).deserializeNode(br); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveEncodingTree serializes the tree to the file at the given path, replacing the file if it exists.
+func SaveEncodingTree(path string, tree *CharacterSetEncodingTree) error {
+	buf := bytes.Buffer{}
+	if err := tree.Serialize(&buf); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// LoadEncodingTree deserializes the tree from the file at the given path.
+func LoadEncodingTree(path string) (*CharacterSetEncodingTree, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	tree, err := DeserializeCharacterSetEncodingTree(file)
+	if err != nil {
+		return nil, fmt.Errorf("`%s`: %w", path, err)
+	}
+	return tree, nil
+}
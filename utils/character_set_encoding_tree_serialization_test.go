@@ -0,0 +1,51 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodingTreeSerialization(t *testing.T) {
+	tree := consolidationTestTrees()["euc"]
+	path := filepath.Join(t.TempDir(), "euc.tree")
+	require.NoError(t, SaveEncodingTree(path, tree))
+	loaded, err := LoadEncodingTree(path)
+	require.NoError(t, err)
+	assert.Equal(t, encodingTreeEntries(tree), encodingTreeEntries(loaded))
+
+	// The RangeMap of the loaded tree matches the original
+	rangeMap, err := RangeMapFromTree(tree)
+	require.NoError(t, err)
+	loadedRangeMap, err := RangeMapFromTree(loaded)
+	require.NoError(t, err)
+	assert.Equal(t, RangeMapToGoFile(rangeMap, CaseMappings{}, "euc"), RangeMapToGoFile(loadedRangeMap, CaseMappings{}, "euc"))
+
+	buf := bytes.Buffer{}
+	require.NoError(t, tree.Serialize(&buf))
+	serialized := buf.Bytes()
+	_, err = DeserializeCharacterSetEncodingTree(bytes.NewReader(serialized[:len(serialized)-1]))
+	assert.Error(t, err)
+	serialized[len(encodingTreeMagic)] = encodingTreeVersion + 1
+	_, err = DeserializeCharacterSetEncodingTree(bytes.NewReader(serialized))
+	assert.Error(t, err)
+	_, err = DeserializeCharacterSetEncodingTree(bytes.NewReader([]byte("{}")))
+	assert.Error(t, err)
+}
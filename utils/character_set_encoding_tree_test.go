@@ -0,0 +1,106 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodingTreeNodes(t *testing.T) {
+	// Values are added in a random order, so the subtrees switch between the sparse and dense representations
+	r := rand.New(rand.NewSource(0))
+	for _, count := range []int{1, 3, 40, 200, 256} {
+		tree := NewCharacterSetEncodingTree()
+		expected := make(map[byte]*CharacterSetEncodingTree)
+		for _, idx := range r.Perm(256)[:count] {
+			child := tree.AddChild(byte(idx))
+			require.True(t, child.SetData([]byte{byte(idx)}))
+			expected[byte(idx)] = child
+			assert.Same(t, child, tree.AddChild(byte(idx)))
+		}
+		for val := 0; val < 256; val++ {
+			if child, ok := expected[byte(val)]; ok {
+				assert.Same(t, child, tree.Child(byte(val)))
+			} else {
+				assert.Nil(t, tree.Child(byte(val)))
+			}
+		}
+		// The iterator returns the values in ascending order
+		previous := -1
+		iter := tree.Iterator()
+		for inputEncoding, outputEncoding, ok := iter.Next(); ok; inputEncoding, outputEncoding, ok = iter.Next() {
+			assert.Equal(t, inputEncoding, outputEncoding)
+			assert.Greater(t, int(inputEncoding[0]), previous)
+			previous = int(inputEncoding[0])
+			delete(expected, inputEncoding[0])
+		}
+		assert.Empty(t, expected)
+	}
+}
+
+func TestEncodingTreeIteratorOptions(t *testing.T) {
+	tree := consolidationTestTrees()["euc"]
+	var all [][]byte
+	iter := tree.Iterator()
+	for inputEncoding, _, ok := iter.Next(); ok; inputEncoding, _, ok = iter.Next() {
+		all = append(all, inputEncoding)
+	}
+	for _, options := range []EncodingIteratorOptions{
+		{},
+		{MinLength: 2},
+		{MaxLength: 1},
+		{LongestFirst: true},
+		{Start: []byte{0xB0}, End: []byte{0xB2, 0x50}},
+		{Start: []byte{0xB0, 0xC0}, End: []byte{0xB0, 0xC5}},
+		{Start: []byte{'a'}, End: []byte{0xA1}, LongestFirst: true},
+		{Start: []byte{0xA1, 0xA1, 0xA1}},
+		{End: []byte{0xA1, 0xA2}, MinLength: 2},
+	} {
+		// The expected encodings are every encoding filtered by the options
+		var expected [][]byte
+		for _, length := range []int{1, 2} {
+			if options.LongestFirst {
+				length = 3 - length
+			}
+			for _, encoding := range all {
+				if len(encoding) != length || len(encoding) < options.MinLength ||
+					(options.MaxLength > 0 && len(encoding) > options.MaxLength) ||
+					(options.Start != nil && bytes.Compare(encoding, options.Start) < 0) ||
+					(options.End != nil && bytes.Compare(encoding, options.End) >= 0) {
+					continue
+				}
+				expected = append(expected, encoding)
+			}
+		}
+		var actual [][]byte
+		iter = tree.IteratorWithOptions(options)
+		for inputEncoding, _, ok := iter.Next(); ok; inputEncoding, _, ok = iter.Next() {
+			actual = append(actual, inputEncoding)
+		}
+		assert.Equal(t, expected, actual, "%+v", options)
+	}
+}
+
+func BenchmarkEncodingTree(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		consolidationTestTrees()
+	}
+}
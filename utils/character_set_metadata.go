@@ -0,0 +1,79 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+)
+
+// CharacterSetMetadata contains the properties of a character set that GMS declares alongside its encoding, as reported
+// by SHOW CHARACTER SET.
+type CharacterSetMetadata struct {
+	Name string `json:"name"`
+	// DefaultCollation is the collation that the character set uses when none is given.
+	DefaultCollation string `json:"default_collation"`
+	Description      string `json:"description"`
+	// MinLength is the length of the shortest codepoint, in bytes. The server does not report the minimum length, so
+	// it is read from the RangeMap of the character set.
+	MinLength int `json:"min_length"`
+	// MaxLength is the MAXLEN of the character set, which is the length of its longest codepoint in bytes.
+	MaxLength int `json:"max_length"`
+	// Flavor is the server that the character set was extracted from.
+	Flavor ServerFlavor `json:"flavor,omitempty"`
+}
+
+// ApplyRangeMap sets the minimum length from the codepoints of the given RangeMap. Returns an error when the longest
+// codepoint of the RangeMap does not have the length that the server reported, as either the server misreported its
+// MAXLEN or the extraction missed (or invented) codepoints. The MAXLEN of the `binary` character set counts bytes rather
+// than the runes of its identity RangeMap (see IdentityRangeMap), so it is not compared.
+func (m *CharacterSetMetadata) ApplyRangeMap(rm *RangeMap) error {
+	if m.Name == "binary" {
+		m.MinLength = 1
+		return nil
+	}
+	minLength, maxLength := rm.CodepointLengths()
+	if maxLength != m.MaxLength {
+		return fmt.Errorf("the longest codepoint of `%s` has %d bytes, however the server reports a MAXLEN of %d",
+			m.Name, maxLength, m.MaxLength)
+	}
+	m.MinLength = minLength
+	return nil
+}
+
+// CharacterSetMetadataToGoFile returns the given metadata as a Go file of constants for inclusion in an application,
+// alongside the file that RangeMapToGoFile generated for the same character set.
+func CharacterSetMetadataToGoFile(metadata CharacterSetMetadata, options CodegenOptions) string {
+	titleName, lowerName := options.names(metadata.Name)
+	flavor := metadata.Flavor
+	if flavor == "" {
+		flavor = ServerFlavorMySQL
+	}
+	return fmt.Sprintf(`%s
+
+const (
+	// %[2]s_DefaultCollation is the default collation of the %[3]s character set.
+	%[2]s_DefaultCollation = %[4]q
+	// %[2]s_Description is the description of the %[3]s character set.
+	%[2]s_Description = %[5]q
+	// %[2]s_MinLength is the length of the shortest codepoint of the %[3]s character set, in bytes.
+	%[2]s_MinLength = %[6]d
+	// %[2]s_MaxLength is the length of the longest codepoint of the %[3]s character set, in bytes.
+	%[2]s_MaxLength = %[7]d
+	// %[2]s_Flavor is the server that the %[3]s character set was extracted from.
+	%[2]s_Flavor = %[8]q
+)
+`, options.fileHeader(), titleName, "`"+lowerName+"`", metadata.DefaultCollation, metadata.Description,
+		metadata.MinLength, metadata.MaxLength, string(flavor))
+}
@@ -0,0 +1,42 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCharacterSetMetadata(t *testing.T) {
+	rangeMap, err := RangeMapFromTree(consolidationTestTrees()["euc"])
+	require.NoError(t, err)
+	minLength, maxLength := rangeMap.CodepointLengths()
+	assert.Equal(t, 1, minLength)
+	assert.Equal(t, 2, maxLength)
+
+	metadata := CharacterSetMetadata{Name: "euc", DefaultCollation: "euc_general_ci", Description: "EUC", MaxLength: 3}
+	assert.Error(t, metadata.ApplyRangeMap(rangeMap))
+	metadata.MaxLength = 2
+	require.NoError(t, metadata.ApplyRangeMap(rangeMap))
+	assert.Equal(t, 1, metadata.MinLength)
+
+	contents := CharacterSetMetadataToGoFile(metadata, CodegenOptions{})
+	assert.Contains(t, contents, "\tEuc_DefaultCollation = \"euc_general_ci\"\n")
+	assert.Contains(t, contents, "\tEuc_MinLength = 1\n")
+	assert.Contains(t, contents, "\tEuc_MaxLength = 2\n")
+	assert.Contains(t, contents, "\tEuc_Flavor = \"mysql\"\n")
+}
@@ -0,0 +1,254 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// CharacterSetQuirks contains the hooks that the extraction calls for behavior that differs between character sets.
+// Most character sets follow the same conventions, which are implemented by DefaultCharacterSetQuirks, while a few
+// legacy and internal character sets need their own handling.
+type CharacterSetQuirks struct {
+	// Unmappable returns whether the server's output for the given rune means that the rune does not exist in the
+	// character set. The tree contains every rune that has been extracted so far. Returns an error if the output is
+	// ambiguous, as the extraction would otherwise silently produce an incorrect mapping.
+	Unmappable func(r rune, output []byte, tree *CharacterSetEncodingTree) (bool, error)
+	// KnownEncodings are encodings that are known to break the assumptions of the extraction. They're verified against
+	// the extracted RangeMap, so that a regression in the handling of the character set is caught immediately.
+	KnownEncodings map[rune][]byte
+	// RangeMapOptions are given to RangeMapFromTreeWithOptions, so that the encodings of character sets with an unusual
+	// structure consolidate into a small number of entries.
+	RangeMapOptions RangeMapOptions
+	// FixedWidth is the length of every encoding of a fixed-width character set, which is verified against the
+	// extracted RangeMap. Zero for variable-width character sets.
+	FixedWidth int
+	// Planes are the Unicode planes that the character set is able to encode, so that the extraction skips the runes of
+	// every other plane. Nil when any plane may be encoded.
+	Planes []int
+	// EncodesUnassigned is true for the character sets that encode every rune, whether or not it has been assigned, so
+	// that unassigned runes are never skipped.
+	EncodesUnassigned bool
+}
+
+// basicMultilingualPlaneCharacterSets are the character sets that only encode runes within the Basic Multilingual Plane,
+// which is every single-byte character set along with the legacy multi-byte character sets. Skipping the supplementary
+// planes avoids converting over a million runes that are known to be unmappable.
+var basicMultilingualPlaneCharacterSets = map[string]struct{}{
+	"armscii8": {},
+	"ascii":    {},
+	"big5":     {},
+	"cp1250":   {},
+	"cp1251":   {},
+	"cp1256":   {},
+	"cp1257":   {},
+	"cp850":    {},
+	"cp852":    {},
+	"cp866":    {},
+	"cp932":    {},
+	"dec8":     {},
+	"eucjpms":  {},
+	"euckr":    {},
+	"gb2312":   {},
+	"gbk":      {},
+	"geostd8":  {},
+	"greek":    {},
+	"hebrew":   {},
+	"hp8":      {},
+	"keybcs2":  {},
+	"koi8r":    {},
+	"koi8u":    {},
+	"latin1":   {},
+	"latin2":   {},
+	"latin5":   {},
+	"latin7":   {},
+	"macce":    {},
+	"macroman": {},
+	"sjis":     {},
+	"swe7":     {},
+	"tis620":   {},
+	"ucs2":     {},
+	"ujis":     {},
+	"utf8":     {},
+	"utf8mb3":  {},
+}
+
+// DefaultCharacterSetQuirks returns the conventional behavior. MySQL returns '?' for runes that do not have a conversion
+// to the character set. As '?' is within the ASCII space, it should already have been added by the time a rune without
+// a conversion is encountered, so we verify that it's in the tree (validating that this is the unknown and not a valid
+// '?'). Otherwise, we error, as this is a character set that doesn't follow the precedent set by other character sets.
+func DefaultCharacterSetQuirks() CharacterSetQuirks {
+	return CharacterSetQuirks{
+		Unmappable: replacementUnmappable([]byte("?"), '?'),
+	}
+}
+
+// replacementUnmappable returns an Unmappable that treats the given replacement as a rune without a conversion, unless
+// the rune is the replacement's own rune. Runes are extracted in order, so the replacement must already be in the tree
+// once a later rune returns it.
+func replacementUnmappable(replacement []byte, replacementRune rune) func(r rune, output []byte, tree *CharacterSetEncodingTree) (bool, error) {
+	return func(r rune, output []byte, tree *CharacterSetEncodingTree) (bool, error) {
+		if !bytes.Equal(output, replacement) || r == replacementRune {
+			return false, nil
+		}
+		if r > replacementRune {
+			node := tree
+			for _, val := range replacement {
+				node = node.Child(val)
+			}
+			if node.Data() == nil {
+				return false, fmt.Errorf("rune `%s` returned 0x%X which should have already been added", string(r), output)
+			}
+		}
+		return true, nil
+	}
+}
+
+// CharacterSetQuirksFor returns the quirks of the given character set, which returns '?' for runes without a
+// conversion.
+func CharacterSetQuirksFor(charset string) CharacterSetQuirks {
+	return CharacterSetQuirksWithReplacement(charset, []byte("?"), '?')
+}
+
+// CharacterSetQuirksWithReplacement returns the quirks of the given character set, which returns the given replacement
+// (the encoding of replacementRune) for runes without a conversion. Some character sets use a substitution character
+// other than '?', such as 0x1A or a full-width question mark, which is discovered by CharacterSetReplacement in the
+// extractor. Character sets with their own detection (such as filename) ignore the replacement.
+func CharacterSetQuirksWithReplacement(charset string, replacement []byte, replacementRune rune) CharacterSetQuirks {
+	quirks := CharacterSetQuirks{
+		Unmappable: replacementUnmappable(replacement, replacementRune),
+	}
+	if _, ok := basicMultilingualPlaneCharacterSets[charset]; ok {
+		quirks.Planes = []int{0}
+	}
+	switch charset {
+	case "utf8mb4", "utf16", "utf16le", "utf32", "gb18030":
+		quirks.EncodesUnassigned = true
+	}
+	switch charset {
+	case "filename":
+		// The filename character set encodes table names for the file system. Every rune that is not a letter or digit
+		// is escaped as '@' followed by four hexadecimal digits (or a two character code for some letters), including
+		// '?' itself, which is encoded as "@003f". A lone '?' is therefore never a valid encoding, and always means
+		// that the rune has no conversion. The escape character '@' is also never valid on its own. The escapes are
+		// 5 bytes long, which is longer than any other character set's encodings.
+		quirks.Unmappable = func(r rune, output []byte, tree *CharacterSetEncodingTree) (bool, error) {
+			if len(output) != 1 || output[0] != '?' {
+				return false, nil
+			}
+			if r == '?' {
+				return false, fmt.Errorf("rune `?` returned `?`, which is expected to be escaped")
+			}
+			return true, nil
+		}
+		quirks.KnownEncodings = map[rune][]byte{
+			'a': []byte("a"),
+			'.': []byte("@002e"),
+			'?': []byte("@003f"),
+			'@': []byte("@0040"),
+		}
+	case "swe7":
+		// The Swedish 7-bit character set replaces the ASCII symbols @[\]^`{|}~ with accented letters, so the replacement
+		// '?' is valid, but the bytes 0x00-0x7F do not all map to themselves. This includes 0x5C, so the byte that is
+		// normally the escape character decodes to 'Ö'.
+		quirks.KnownEncodings = map[rune][]byte{
+			'?': {0x3F},
+			'É': {0x40},
+			'Ä': {0x5B},
+			'Ö': {0x5C},
+			'Å': {0x5D},
+			'Ü': {0x5E},
+			'é': {0x60},
+			'ä': {0x7B},
+			'ö': {0x7C},
+			'å': {0x7D},
+			'ü': {0x7E},
+		}
+	case "utf16le":
+		// The little-endian variant of utf16 puts the least significant byte of each 16-bit word first, including each
+		// word of a surrogate pair, so the words are reversed for consolidation
+		quirks.RangeMapOptions.InputWordSize = 2
+		quirks.KnownEncodings = map[rune][]byte{
+			'a': {0x61, 0x00},
+			'€': {0xAC, 0x20},
+			'😀': {0x3D, 0xD8, 0x00, 0xDE},
+		}
+	case "utf32":
+		// Every rune is a big-endian 32-bit word, so every encoding is 4 bytes long
+		quirks.FixedWidth = 4
+		quirks.KnownEncodings = map[rune][]byte{
+			'a': {0x00, 0x00, 0x00, 0x61},
+			'€': {0x00, 0x00, 0x20, 0xAC},
+			'😀': {0x00, 0x01, 0xF6, 0x00},
+		}
+	case "gb18030":
+		// The four-byte codepoints are numbered sequentially, and long runs of them map to consecutive runes (such as
+		// the entire supplementary planes, starting at 0x90308130). The numbering has a base of 10 or 126 at each byte,
+		// which never aligns with the base 64 of UTF8, so the runs are represented as linear entries.
+		quirks.RangeMapOptions.LinearRunLength = 128
+		quirks.KnownEncodings = map[rune][]byte{
+			'a':      {0x61},
+			'€':      {0xA2, 0xE3},
+			'\u0080': {0x81, 0x30, 0x81, 0x30},
+			'😀':      {0x94, 0x39, 0xFC, 0x36},
+		}
+	case "ucs2":
+		// The fixed-width predecessor of utf16, which only contains the Basic Multilingual Plane
+		quirks.FixedWidth = 2
+	case "dec8":
+		// The DEC multinational character set leaves some of the upper bytes unassigned, which MySQL decodes to U+0000.
+		// Those bytes are never returned when encoding, so they are never added to the tree, and the conventional
+		// behavior applies.
+		quirks.KnownEncodings = map[rune][]byte{
+			'é': {0xE9},
+		}
+	}
+	return quirks
+}
+
+// Verify checks that the given RangeMap, which was extracted for the character set, contains the known encodings. This
+// also checks the byte order and width of the encodings for the character sets that declare them.
+func (quirks CharacterSetQuirks) Verify(rangeMap *RangeMap) error {
+	if rangeMap.inputWordSize != quirks.RangeMapOptions.InputWordSize {
+		return fmt.Errorf("the RangeMap has an input word size of %d rather than %d", rangeMap.inputWordSize, quirks.RangeMapOptions.InputWordSize)
+	}
+	if quirks.FixedWidth > 0 {
+		for length, entries := range rangeMap.inputEntries {
+			if len(entries) > 0 && length+1 != quirks.FixedWidth {
+				return fmt.Errorf("the RangeMap contains encodings of length %d, which should all be %d", length+1, quirks.FixedWidth)
+			}
+		}
+	}
+	runes := make([]rune, 0, len(quirks.KnownEncodings))
+	for r := range quirks.KnownEncodings {
+		runes = append(runes, r)
+	}
+	sort.Slice(runes, func(i, j int) bool {
+		return runes[i] < runes[j]
+	})
+	for _, r := range runes {
+		expected := quirks.KnownEncodings[r]
+		encoded, ok := rangeMap.Encode([]byte(string(r)))
+		if !ok {
+			return fmt.Errorf("rune `%s` was expected to encode to %v, but it is not in the character set", string(r), expected)
+		}
+		if !bytes.Equal(encoded, expected) {
+			return fmt.Errorf("rune `%s` was expected to encode to %v, but it encoded to %v", string(r), expected, encoded)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// quirksTestRangeMap returns a RangeMap from the given encodings, which map a character set's encoding to a rune.
+func quirksTestRangeMap(t *testing.T, encodings map[string]rune) *RangeMap {
+	tree := NewCharacterSetEncodingTree()
+	for encoding, r := range encodings {
+		node := tree
+		for _, val := range []byte(encoding) {
+			node = node.AddChild(val)
+		}
+		require.True(t, node.SetData([]byte(string(r))))
+	}
+	rangeMap, err := RangeMapFromTree(tree)
+	require.NoError(t, err)
+	return rangeMap
+}
+
+// quirksTestASCII returns the encodings of ASCII, with the given replacements.
+func quirksTestASCII(replacements map[byte]rune) map[string]rune {
+	encodings := make(map[string]rune)
+	for b := 0; b < 0x80; b++ {
+		if r, ok := replacements[byte(b)]; ok {
+			encodings[string([]byte{byte(b)})] = r
+		} else {
+			encodings[string([]byte{byte(b)})] = rune(b)
+		}
+	}
+	return encodings
+}
+
+func TestDefaultCharacterSetQuirks(t *testing.T) {
+	quirks := CharacterSetQuirksFor("latin1")
+	tree := NewCharacterSetEncodingTree()
+
+	// The replacement is ambiguous until '?' has been extracted
+	_, err := quirks.Unmappable('€', []byte("?"), tree)
+	assert.Error(t, err)
+	unmappable, err := quirks.Unmappable('?', []byte("?"), tree)
+	require.NoError(t, err)
+	assert.False(t, unmappable)
+	tree.AddChild('?').SetData([]byte("?"))
+	unmappable, err = quirks.Unmappable('€', []byte("?"), tree)
+	require.NoError(t, err)
+	assert.True(t, unmappable)
+	unmappable, err = quirks.Unmappable('a', []byte("a"), tree)
+	require.NoError(t, err)
+	assert.False(t, unmappable)
+
+	assert.NoError(t, quirks.Verify(quirksTestRangeMap(t, quirksTestASCII(nil))))
+}
+
+func TestSwe7CharacterSetQuirks(t *testing.T) {
+	quirks := CharacterSetQuirksFor("swe7")
+	swe7 := quirksTestASCII(map[byte]rune{
+		0x40: 'É', 0x5B: 'Ä', 0x5C: 'Ö', 0x5D: 'Å', 0x5E: 'Ü', 0x60: 'é', 0x7B: 'ä', 0x7C: 'ö', 0x7D: 'å', 0x7E: 'ü',
+	})
+	rangeMap := quirksTestRangeMap(t, swe7)
+	assert.NoError(t, quirks.Verify(rangeMap))
+	assert.False(t, rangeMap.IsASCIICompatible())
+	// The escape character is not within the character set
+	_, ok := rangeMap.Encode([]byte(`\`))
+	assert.False(t, ok)
+	// An extraction that treated swe7 as ASCII would be caught
+	assert.Error(t, quirks.Verify(quirksTestRangeMap(t, quirksTestASCII(nil))))
+
+	// The replacement is a valid rune, so swe7 follows the conventional replacement detection
+	tree := NewCharacterSetEncodingTree()
+	tree.AddChild('?').SetData([]byte("?"))
+	unmappable, err := quirks.Unmappable('\\', []byte("?"), tree)
+	require.NoError(t, err)
+	assert.True(t, unmappable)
+}
+
+func TestDec8CharacterSetQuirks(t *testing.T) {
+	quirks := CharacterSetQuirksFor("dec8")
+	encodings := quirksTestASCII(nil)
+	encodings["\xE9"] = 'é'
+	assert.NoError(t, quirks.Verify(quirksTestRangeMap(t, encodings)))
+	delete(encodings, "\xE9")
+	assert.Error(t, quirks.Verify(quirksTestRangeMap(t, encodings)))
+}
+
+func TestFilenameCharacterSetQuirks(t *testing.T) {
+	quirks := CharacterSetQuirksFor("filename")
+	filename := map[string]rune{
+		"a":     'a',
+		"z":     'z',
+		"0":     '0',
+		"@002e": '.',
+		"@003f": '?',
+		"@0040": '@',
+		"@0G":   'À',
+	}
+	rangeMap := quirksTestRangeMap(t, filename)
+	assert.NoError(t, quirks.Verify(rangeMap))
+	// The escape character is never valid on its own
+	_, ok := rangeMap.Decode([]byte("@"))
+	assert.False(t, ok)
+
+	// A lone '?' always means that the rune has no conversion, even though '?' was never added to the tree
+	tree := NewCharacterSetEncodingTree()
+	unmappable, err := quirks.Unmappable('😀', []byte("?"), tree)
+	require.NoError(t, err)
+	assert.True(t, unmappable)
+	unmappable, err = quirks.Unmappable('?', []byte("@003f"), tree)
+	require.NoError(t, err)
+	assert.False(t, unmappable)
+	_, err = quirks.Unmappable('?', []byte("?"), tree)
+	assert.Error(t, err)
+	// The conventional detection would fail on the first rune without a conversion
+	_, err = DefaultCharacterSetQuirks().Unmappable('😀', []byte("?"), tree)
+	assert.Error(t, err)
+}
+
+func TestCharacterSetQuirksWithReplacement(t *testing.T) {
+	// A control character replacement is extracted before any rune that returns it
+	quirks := CharacterSetQuirksWithReplacement("latin1", []byte{0x1A}, 0x1A)
+	tree := NewCharacterSetEncodingTree()
+	tree.AddChild(0x1A).SetData([]byte{0x1A})
+	unmappable, err := quirks.Unmappable('€', []byte{0x1A}, tree)
+	require.NoError(t, err)
+	assert.True(t, unmappable)
+	unmappable, err = quirks.Unmappable(0x1A, []byte{0x1A}, tree)
+	require.NoError(t, err)
+	assert.False(t, unmappable)
+	// A lone '?' is a valid rune when it is not the replacement
+	unmappable, err = quirks.Unmappable('?', []byte("?"), tree)
+	require.NoError(t, err)
+	assert.False(t, unmappable)
+
+	// A full-width question mark is extracted after many runes that return it, which are unmappable regardless
+	quirks = CharacterSetQuirksWithReplacement("sjis", []byte{0x81, 0x48}, '？')
+	tree = NewCharacterSetEncodingTree()
+	unmappable, err = quirks.Unmappable('€', []byte{0x81, 0x48}, tree)
+	require.NoError(t, err)
+	assert.True(t, unmappable)
+	unmappable, err = quirks.Unmappable('？', []byte{0x81, 0x48}, tree)
+	require.NoError(t, err)
+	assert.False(t, unmappable)
+	_, err = quirks.Unmappable('😀', []byte{0x81, 0x48}, tree)
+	assert.Error(t, err)
+	tree.AddChild(0x81).AddChild(0x48).SetData([]byte("？"))
+	unmappable, err = quirks.Unmappable('😀', []byte{0x81, 0x48}, tree)
+	require.NoError(t, err)
+	assert.True(t, unmappable)
+}
+
+func TestUtf16leCharacterSetQuirks(t *testing.T) {
+	quirks := CharacterSetQuirksFor("utf16le")
+	tree := NewCharacterSetEncodingTree()
+	for _, r := range []rune{'a', '€', '😀'} {
+		node := tree
+		for _, val := range quirks.KnownEncodings[r] {
+			node = node.AddChild(val)
+		}
+		require.True(t, node.SetData([]byte(string(r))))
+	}
+	for r := rune(0); r < 0x3000; r++ {
+		node := tree.AddChild(byte(r)).AddChild(byte(r >> 8))
+		node.SetData([]byte(string(r)))
+	}
+	rangeMap, err := RangeMapFromTreeWithOptions(tree, quirks.RangeMapOptions)
+	require.NoError(t, err)
+	assert.NoError(t, quirks.Verify(rangeMap))
+	// Each word is consolidated in big-endian order, so the Basic Multilingual Plane only needs a few entries
+	assert.Less(t, len(rangeMap.inputEntries[1]), 10)
+	decoded, ok := rangeMap.Decode([]byte{0x3D, 0xD8, 0x00, 0xDE})
+	require.True(t, ok)
+	assert.Equal(t, "😀", string(decoded))
+	encoded, ok := rangeMap.Encode([]byte("€"))
+	require.True(t, ok)
+	assert.Equal(t, []byte{0xAC, 0x20}, encoded)
+
+	// Parsing the generated file retains the word size
+	parsed, _, err := ParseRangeMapGoFile(RangeMapToGoFile(rangeMap, CaseMappings{}, "utf16le"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, parsed.inputWordSize)
+	// A RangeMap built without reversing the words is caught
+	rangeMap, err = RangeMapFromTree(tree)
+	require.NoError(t, err)
+	assert.Error(t, quirks.Verify(rangeMap))
+}
+
+func TestUtf32CharacterSetQuirks(t *testing.T) {
+	quirks := CharacterSetQuirksFor("utf32")
+	encodings := make(map[string]rune)
+	for r, encoding := range quirks.KnownEncodings {
+		encodings[string(encoding)] = r
+	}
+	assert.NoError(t, quirks.Verify(quirksTestRangeMap(t, encodings)))
+	// Every encoding must be 4 bytes long
+	encodings["\x62"] = 'b'
+	assert.Error(t, quirks.Verify(quirksTestRangeMap(t, encodings)))
+}
+
+func TestCharacterSetQuirksPlanes(t *testing.T) {
+	// Legacy character sets only encode the Basic Multilingual Plane, while the Unicode character sets encode every plane
+	for _, charset := range []string{"latin1", "sjis", "gbk", "ucs2", "utf8mb3"} {
+		assert.Equal(t, []int{0}, CharacterSetQuirksFor(charset).Planes, charset)
+	}
+	for _, charset := range []string{"utf8mb4", "utf16", "utf16le", "utf32", "gb18030", "binary"} {
+		assert.Nil(t, CharacterSetQuirksFor(charset).Planes, charset)
+	}
+}
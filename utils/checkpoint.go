@@ -0,0 +1,222 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// CheckpointStage is the stage of an extraction that a Checkpoint was saved during.
+type CheckpointStage string
+
+const (
+	// CheckpointStageEncodings is saved while converting runes to a character set.
+	CheckpointStageEncodings CheckpointStage = "encodings"
+	// CheckpointStageCaseConversions is saved while retrieving the case conversions of a character set, which occurs
+	// after all encodings have been extracted.
+	CheckpointStageCaseConversions CheckpointStage = "case_conversions"
+	// CheckpointStageWeights is saved while retrieving the weights of a collation's runes.
+	CheckpointStageWeights CheckpointStage = "weights"
+	// CheckpointStageWeightless is saved while inserting the runes without a weight into the RuneComparator of a
+	// collation, which occurs after all weighted runes have been inserted.
+	CheckpointStageWeightless CheckpointStage = "weightless"
+)
+
+// characterSetCheckpointStages is the order that the stages of a character set's extraction occur in. Collations have
+// their own stages, which are not part of this order.
+var characterSetCheckpointStages = map[CheckpointStage]int{
+	CheckpointStageEncodings:       0,
+	CheckpointStageCaseConversions: 1,
+}
+
+// Checkpoint is the partial state of an extraction, which allows an interrupted extraction (such as from a dropped
+// connection) to resume from the last processed rune rather than starting from scratch.
+type Checkpoint struct {
+	Name  string
+	Stage CheckpointStage
+	// LastRune is the last rune that was processed, so an extraction resumes from the following rune.
+	LastRune rune
+	// Encodings contains the encodings that have been extracted, in the same format as Model.
+	Encodings    [][2][]byte
+	CaseMappings CaseMappings
+	// Weights contains the ordering of a partial RuneComparator, in the same format as Model.
+	Weights      [][]rune
+	RuneToWeight map[rune][]byte
+	SeededRunes  int
+	// Weightless contains the runes without a weight, which are inserted into the RuneComparator after all weighted
+	// runes. During the weightless stage, this only contains the runes that have yet to be inserted.
+	Weightless []rune
+}
+
+// NewEncodingsCheckpoint returns a Checkpoint for the given partially extracted tree.
+func NewEncodingsCheckpoint(charset string, lastRune rune, tree *CharacterSetEncodingTree) *Checkpoint {
+	return &Checkpoint{
+		Name:      charset,
+		Stage:     CheckpointStageEncodings,
+		LastRune:  lastRune,
+		Encodings: encodingTreeEntries(tree),
+	}
+}
+
+// NewCaseConversionsCheckpoint returns a Checkpoint for the given partially extracted case conversions.
+func NewCaseConversionsCheckpoint(charset string, lastRune rune, rangeMap *RangeMap, caseMappings CaseMappings) *Checkpoint {
+	return &Checkpoint{
+		Name:         charset,
+		Stage:        CheckpointStageCaseConversions,
+		LastRune:     lastRune,
+		Encodings:    encodingTreeEntries(rangeMap.Tree()),
+		CaseMappings: caseMappings,
+	}
+}
+
+// NewWeightsCheckpoint returns a Checkpoint for the given partially retrieved weights, where the RuneComparator
+// contains the weighted runes, and the runes that were found to have no weight are given separately.
+func NewWeightsCheckpoint(collation string, lastRune rune, rc *RuneComparator, runeToWeight map[rune][]byte, weightless []rune, seededRunes int) *Checkpoint {
+	return &Checkpoint{
+		Name:         collation,
+		Stage:        CheckpointStageWeights,
+		LastRune:     lastRune,
+		Weights:      rc.rows(),
+		RuneToWeight: runeToWeight,
+		SeededRunes:  seededRunes,
+		Weightless:   weightless,
+	}
+}
+
+// NewWeightlessCheckpoint returns a Checkpoint for the given partially extracted RuneComparator, where the given runes
+// have yet to be inserted.
+func NewWeightlessCheckpoint(collation string, rc *RuneComparator, runeToWeight map[rune][]byte, remaining []rune, seededRunes int) *Checkpoint {
+	return &Checkpoint{
+		Name:         collation,
+		Stage:        CheckpointStageWeightless,
+		Weights:      rc.rows(),
+		RuneToWeight: runeToWeight,
+		SeededRunes:  seededRunes,
+		Weightless:   remaining,
+	}
+}
+
+// EncodingTree returns the tree of the Checkpoint's encodings.
+func (cp *Checkpoint) EncodingTree() (*CharacterSetEncodingTree, error) {
+	tree, err := encodingTreeFromEntries(cp.Encodings)
+	if err != nil {
+		return nil, fmt.Errorf("checkpoint `%s` %w", cp.Name, err)
+	}
+	return tree, nil
+}
+
+// RuneComparator returns the RuneComparator of the Checkpoint's weights. The comparator is not set.
+func (cp *Checkpoint) RuneComparator() *RuneComparator {
+	return NewRuneComparatorFromOrder(cp.Weights)
+}
+
+// Checkpointer periodically saves a Checkpoint to a file, and loads it when resuming an extraction. All methods may be
+// called on a nil Checkpointer, which never saves and never resumes.
+type Checkpointer struct {
+	path     string
+	interval time.Duration
+	lastSave time.Time
+	// checkpoint is the Checkpoint that was loaded for resumption, which is nil when not resuming.
+	checkpoint *Checkpoint
+}
+
+// NewCheckpointer returns a new Checkpointer, which saves to the given path once the interval has elapsed since the
+// last save. When resuming, the Checkpoint at the path is loaded (if it exists).
+func NewCheckpointer(path string, interval time.Duration, resume bool) (*Checkpointer, error) {
+	c := &Checkpointer{
+		path:     path,
+		interval: interval,
+		lastSave: time.Now(),
+	}
+	if !resume {
+		return c, nil
+	}
+	contents, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	} else if err != nil {
+		return nil, err
+	}
+	c.checkpoint = &Checkpoint{}
+	if err = gob.NewDecoder(bytes.NewReader(contents)).Decode(c.checkpoint); err != nil {
+		return nil, fmt.Errorf("unable to decode the checkpoint at `%s`: %w", path, err)
+	}
+	return c, nil
+}
+
+// Resume returns the loaded Checkpoint when it belongs to the given stage of the named extraction, or to a later stage
+// (in which case the given stage has completed). Returns nil when there is nothing to resume.
+func (c *Checkpointer) Resume(name string, stage CheckpointStage) (*Checkpoint, error) {
+	if c == nil || c.checkpoint == nil {
+		return nil, nil
+	}
+	if c.checkpoint.Name != name {
+		return nil, fmt.Errorf("the checkpoint at `%s` belongs to `%s` rather than `%s`", c.path, c.checkpoint.Name, name)
+	}
+	checkpointOrder, checkpointIsCharset := characterSetCheckpointStages[c.checkpoint.Stage]
+	stageOrder, stageIsCharset := characterSetCheckpointStages[stage]
+	if checkpointIsCharset != stageIsCharset {
+		return nil, fmt.Errorf("the checkpoint at `%s` was saved during the `%s` stage, which is not part of the same extraction as `%s`",
+			c.path, c.checkpoint.Stage, stage)
+	}
+	// An earlier stage is resumed before the given stage is reached, so there's nothing to resume for this stage
+	if checkpointOrder < stageOrder {
+		return nil, nil
+	}
+	return c.checkpoint, nil
+}
+
+// Due returns whether the interval has elapsed since the last save. Always false when the interval is not positive.
+func (c *Checkpointer) Due() bool {
+	return c != nil && c.interval > 0 && time.Since(c.lastSave) >= c.interval
+}
+
+// Save writes the given Checkpoint. The file is replaced atomically, so an interruption while saving does not corrupt
+// the previous Checkpoint.
+func (c *Checkpointer) Save(cp *Checkpoint) error {
+	if c == nil {
+		return nil
+	}
+	buf := bytes.Buffer{}
+	if err := gob.NewEncoder(&buf).Encode(cp); err != nil {
+		return err
+	}
+	if err := os.WriteFile(c.path+".tmp", buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(c.path+".tmp", c.path); err != nil {
+		return err
+	}
+	c.lastSave = time.Now()
+	return nil
+}
+
+// Remove deletes the saved Checkpoint, which should be called once the extraction has completed.
+func (c *Checkpointer) Remove() error {
+	if c == nil {
+		return nil
+	}
+	c.checkpoint = nil
+	if err := os.Remove(c.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
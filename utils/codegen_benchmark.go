@@ -0,0 +1,341 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CodegenBenchmarkVariant is a set of generated files for a single collation, which is compiled and benchmarked by
+// RunCodegenBenchmarks. Each variant of a collation exposes the same weight and comparison functions.
+type CodegenBenchmarkVariant struct {
+	Name string
+	// Collation is the name of the collation, which determines the identifiers of the generated functions.
+	Collation string
+	// Files maps the name of each file to its contents, which are written to the same directory.
+	Files map[string][]byte
+}
+
+// CodegenBenchmarkResult is the result of benchmarking a single variant.
+type CodegenBenchmarkResult struct {
+	Variant string `json:"variant"`
+	// SourceBytes is the size of the generated files.
+	SourceBytes int `json:"source_bytes"`
+	// BinaryBytes is the size of the compiled test binary, which includes the testing package and is therefore only
+	// meaningful relative to the other variants.
+	BinaryBytes int64 `json:"binary_bytes"`
+	// CompileTime is the time that was taken to compile the test binary, which includes the generated files.
+	CompileTime time.Duration `json:"compile_time"`
+	// WeightNanos is the average time of a single call to the weight function across the corpus.
+	WeightNanos float64 `json:"weight_nanos"`
+	// CompareNanos is the average time of comparing two lines of the corpus.
+	CompareNanos float64 `json:"compare_nanos"`
+	// AllocsPerOp is the number of allocations of a pass over the corpus with the weight function, which should be zero
+	// once the weights have been loaded.
+	AllocsPerOp int64 `json:"allocs_per_op"`
+}
+
+// CodegenBenchmarkReport contains the results of every variant of RunCodegenBenchmarks.
+type CodegenBenchmarkReport struct {
+	Collation string                   `json:"collation"`
+	Runes     int                      `json:"runes"`
+	Results   []CodegenBenchmarkResult `json:"results"`
+}
+
+// CollationCodegenVariants returns a variant for every CollationCodegen of the given RuneComparator.
+func CollationCodegenVariants(rc *RuneComparator, name string, padSpace bool) ([]CodegenBenchmarkVariant, error) {
+	var variants []CodegenBenchmarkVariant
+	for _, codegen := range CollationCodegens() {
+		variant := CodegenBenchmarkVariant{Name: string(codegen), Collation: name, Files: make(map[string][]byte)}
+		switch codegen {
+		case CollationCodegenMap:
+			variant.Files[strings.ToLower(name)+".go"] = []byte(RuneComparatorToGoFile(rc, name, padSpace))
+		case CollationCodegenSortedSlice:
+			variant.Files[strings.ToLower(name)+".go"] = []byte(RuneComparatorToSortedSliceGoFile(rc, name, padSpace, CodegenOptions{}))
+		case CollationCodegenEmbed:
+			contents, table, err := RuneComparatorToEmbeddedGoFile(rc, name, padSpace, CodegenOptions{})
+			if err != nil {
+				return nil, err
+			}
+			variant.Files[strings.ToLower(name)+".go"] = []byte(contents)
+			variant.Files[RuneComparatorEmbeddedTableName(name)] = table
+		default:
+			return nil, fmt.Errorf("unknown collation codegen `%s`", codegen)
+		}
+		variants = append(variants, variant)
+	}
+	return variants, nil
+}
+
+// codegenBenchmarkFile is the test file that is compiled alongside the generated files of a variant. The corpus is
+// split into lines, where consecutive lines are compared.
+const codegenBenchmarkFile = `package encodings
+
+import (
+	"strings"
+	"testing"
+)
+
+var benchmarkCorpus = %q
+
+var benchmarkSink int32
+
+func BenchmarkWeight(b *testing.B) {
+	runes := []rune(benchmarkCorpus)
+	// The first call loads the weights of the variants that load them lazily
+	%[2]s_RuneWeight(' ')
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, r := range runes {
+			benchmarkSink += %[2]s_RuneWeight(r)
+		}
+	}
+}
+
+func BenchmarkCompare(b *testing.B) {
+	lines := strings.Split(benchmarkCorpus, "\n")
+	%[2]s_RuneWeight(' ')
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := 1; j < len(lines); j++ {
+			benchmarkSink += int32(%[2]s_Compare(lines[j-1], lines[j]))
+		}
+	}
+}
+`
+
+// codegenBenchmarkLine matches a line of benchmark output, such as `BenchmarkWeight-8  100  1234 ns/op  0 B/op  0
+// allocs/op`.
+var codegenBenchmarkLine = regexp.MustCompile(`^Benchmark(\w+?)(?:-\d+)?\s+\d+\s+([\d.]+) ns/op(?:\s+\d+ B/op\s+(\d+) allocs/op)?`)
+
+// RunCodegenBenchmarks compiles each variant within a temporary module using the `go` command, and benchmarks its
+// weight and comparison functions over the given corpus, which should approximate the text that a database would
+// store. The benchtime is given to `-test.benchtime` (such as `1s` or `100x`). This measures the generated code as it
+// would be compiled into GMS, rather than a runtime representation of it (see WeightLookup).
+func RunCodegenBenchmarks(ctx context.Context, variants []CodegenBenchmarkVariant, corpus string, benchtime string) (*CodegenBenchmarkReport, error) {
+	if len(variants) == 0 {
+		return nil, fmt.Errorf("no variants were given")
+	}
+	goCommand, err := exec.LookPath("go")
+	if err != nil {
+		return nil, fmt.Errorf("the go command is required to compile the generated files: %w", err)
+	}
+	report := &CodegenBenchmarkReport{Collation: strings.ToLower(variants[0].Collation), Runes: len([]rune(corpus))}
+	for _, variant := range variants {
+		result, err := runCodegenBenchmark(ctx, goCommand, variant, corpus, benchtime)
+		if err != nil {
+			return nil, fmt.Errorf("variant `%s`: %w", variant.Name, err)
+		}
+		report.Results = append(report.Results, result)
+	}
+	return report, nil
+}
+
+// runCodegenBenchmark compiles and benchmarks a single variant.
+func runCodegenBenchmark(ctx context.Context, goCommand string, variant CodegenBenchmarkVariant, corpus string, benchtime string) (CodegenBenchmarkResult, error) {
+	result := CodegenBenchmarkResult{Variant: variant.Name}
+	dir, err := os.MkdirTemp("", "codegen-benchmark-")
+	if err != nil {
+		return result, err
+	}
+	defer os.RemoveAll(dir)
+	titleName, _ := CodegenOptions{}.names(variant.Collation)
+	files := map[string][]byte{
+		"go.mod":            []byte("module codegenbenchmark\n\ngo 1.18\n"),
+		"benchmark_test.go": []byte(fmt.Sprintf(codegenBenchmarkFile, corpus, titleName)),
+	}
+	for name, contents := range variant.Files {
+		files[name] = contents
+		if strings.HasSuffix(name, ".go") {
+			result.SourceBytes += len(contents)
+		}
+	}
+	for name, contents := range files {
+		if err = os.WriteFile(filepath.Join(dir, name), contents, 0644); err != nil {
+			return result, err
+		}
+	}
+
+	binary := filepath.Join(dir, "benchmark.test")
+	start := time.Now()
+	build := exec.CommandContext(ctx, goCommand, "test", "-c", "-o", binary, ".")
+	build.Dir = dir
+	if output, err := build.CombinedOutput(); err != nil {
+		return result, fmt.Errorf("unable to compile the generated files: %w\n%s", err, output)
+	}
+	result.CompileTime = time.Since(start)
+	info, err := os.Stat(binary)
+	if err != nil {
+		return result, err
+	}
+	result.BinaryBytes = info.Size()
+
+	run := exec.CommandContext(ctx, binary, "-test.run", "XXX", "-test.bench", ".", "-test.benchtime", benchtime)
+	run.Dir = dir
+	output, err := run.CombinedOutput()
+	if err != nil {
+		return result, fmt.Errorf("the benchmarks failed: %w\n%s", err, output)
+	}
+	runes := len([]rune(corpus))
+	lines := len(strings.Split(corpus, "\n")) - 1
+	for _, line := range strings.Split(string(output), "\n") {
+		match := codegenBenchmarkLine.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+		nanos, err := strconv.ParseFloat(match[2], 64)
+		if err != nil {
+			return result, err
+		}
+		switch match[1] {
+		case "Weight":
+			if runes > 0 {
+				result.WeightNanos = nanos / float64(runes)
+			}
+			if match[3] != "" {
+				if result.AllocsPerOp, err = strconv.ParseInt(match[3], 10, 64); err != nil {
+					return result, err
+				}
+			}
+		case "Compare":
+			if lines > 0 {
+				result.CompareNanos = nanos / float64(lines)
+			}
+		}
+	}
+	return result, nil
+}
+
+// String returns the report as a table, with the variants sorted by the time of the weight function.
+func (report *CodegenBenchmarkReport) String() string {
+	results := append([]CodegenBenchmarkResult(nil), report.Results...)
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].WeightNanos < results[j].WeightNanos
+	})
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("`%s` over %d runes\n", report.Collation, report.Runes))
+	sb.WriteString(fmt.Sprintf("%-14s %14s %14s %12s %12s %12s %8s\n",
+		"variant", "source bytes", "binary bytes", "compile", "weight ns", "compare ns", "allocs"))
+	for _, result := range results {
+		sb.WriteString(fmt.Sprintf("%-14s %14d %14d %12s %12.2f %12.2f %8d\n", result.Variant, result.SourceBytes,
+			result.BinaryBytes, result.CompileTime.Round(time.Millisecond), result.WeightNanos, result.CompareNanos, result.AllocsPerOp))
+	}
+	return sb.String()
+}
+
+// RangeMapBenchmarkResult is the result of benchmarking a single variant of a RangeMap.
+type RangeMapBenchmarkResult struct {
+	Variant string `json:"variant"`
+	// Entries is the number of entries within the RangeMap, including linear entries.
+	Entries int `json:"entries"`
+	// SourceBytes is the size of the generated file.
+	SourceBytes int `json:"source_bytes"`
+	// DecodeNanos and EncodeNanos are the average time of decoding and encoding a single codepoint.
+	DecodeNanos float64 `json:"decode_nanos"`
+	EncodeNanos float64 `json:"encode_nanos"`
+}
+
+// RangeMapBenchmarkReport contains the results of every variant of RunRangeMapBenchmarks.
+type RangeMapBenchmarkReport struct {
+	Codepoints int                       `json:"codepoints"`
+	Results    []RangeMapBenchmarkResult `json:"results"`
+}
+
+// rangeMapBenchmarkLinearRunLength is the run length of the variants with linear entries.
+const rangeMapBenchmarkLinearRunLength = 16
+
+// RunRangeMapBenchmarks constructs a RangeMap from the tree using every ConsolidationStrategy, both with and without
+// linear entries (replacing those of the given options, which should be the character set's CharacterSetQuirks), and
+// times decoding and encoding every codepoint within the tree for at least the given duration. A
+// generated RangeMap is data for the same search that the RangeMap performs, so the variants are benchmarked in-process
+// rather than compiled.
+func RunRangeMapBenchmarks(tree *CharacterSetEncodingTree, name string, options RangeMapOptions, duration time.Duration) (*RangeMapBenchmarkReport, error) {
+	entries := encodingTreeEntries(tree)
+	report := &RangeMapBenchmarkReport{Codepoints: len(entries)}
+	if len(entries) == 0 {
+		return report, nil
+	}
+	for _, strategy := range ConsolidationStrategies() {
+		for _, linearRunLength := range []int{0, rangeMapBenchmarkLinearRunLength} {
+			options.Consolidation = strategy
+			options.LinearRunLength = linearRunLength
+			rangeMap, err := RangeMapFromTreeWithOptions(tree, options)
+			if err != nil {
+				return nil, fmt.Errorf("variant `%s`: %w", strategy, err)
+			}
+			result := RangeMapBenchmarkResult{
+				Variant:     string(strategy),
+				Entries:     len(rangeMap.linearEntries),
+				SourceBytes: len(RangeMapToGoFile(rangeMap, CaseMappings{}, name)),
+			}
+			if linearRunLength > 0 {
+				result.Variant += "+linear"
+			}
+			for _, entryLength := range rangeMap.inputEntries {
+				result.Entries += len(entryLength)
+			}
+			result.DecodeNanos = timeCodepoints(duration, len(entries), func() {
+				for _, entry := range entries {
+					rangeMap.Decode(entry[0])
+				}
+			})
+			result.EncodeNanos = timeCodepoints(duration, len(entries), func() {
+				for _, entry := range entries {
+					rangeMap.Encode(entry[1])
+				}
+			})
+			report.Results = append(report.Results, result)
+		}
+	}
+	return report, nil
+}
+
+// timeCodepoints repeatedly calls the pass until the duration has elapsed, returning the average time of each of the
+// codepoints within a pass.
+func timeCodepoints(duration time.Duration, codepoints int, pass func()) float64 {
+	passes := 0
+	start := time.Now()
+	for passes == 0 || time.Since(start) < duration {
+		pass()
+		passes++
+	}
+	return float64(time.Since(start).Nanoseconds()) / float64(passes*codepoints)
+}
+
+// String returns the report as a table, with the variants sorted by the time of decoding.
+func (report *RangeMapBenchmarkReport) String() string {
+	results := append([]RangeMapBenchmarkResult(nil), report.Results...)
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].DecodeNanos < results[j].DecodeNanos
+	})
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("%d codepoints\n", report.Codepoints))
+	sb.WriteString(fmt.Sprintf("%-18s %10s %14s %12s %12s\n", "variant", "entries", "source bytes", "decode ns", "encode ns"))
+	for _, result := range results {
+		sb.WriteString(fmt.Sprintf("%-18s %10d %14d %12.2f %12.2f\n", result.Variant, result.Entries, result.SourceBytes,
+			result.DecodeNanos, result.EncodeNanos))
+	}
+	return sb.String()
+}
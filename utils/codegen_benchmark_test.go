@@ -0,0 +1,73 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunCodegenBenchmarks(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles every variant")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("the go command is not available")
+	}
+	rc := NewRuneComparator()
+	rc.SetComparator(func(l rune, r rune) int {
+		if l%3 < r%3 || (l%3 == r%3 && l < r) {
+			return -1
+		} else if l == r {
+			return 0
+		}
+		return 1
+	})
+	for r := rune(0); r < 0x400; r++ {
+		rc.Insert(r)
+	}
+	variants, err := CollationCodegenVariants(rc, "utf8mb4_bench_ci", true)
+	require.NoError(t, err)
+	require.Len(t, variants, len(CollationCodegens()))
+
+	report, err := RunCodegenBenchmarks(context.Background(), variants, weightLookupCorpus, "10x")
+	require.NoError(t, err)
+	assert.Equal(t, "utf8mb4_bench_ci", report.Collation)
+	require.Len(t, report.Results, len(variants))
+	for _, result := range report.Results {
+		assert.Positive(t, result.SourceBytes, result.Variant)
+		assert.Positive(t, result.BinaryBytes, result.Variant)
+		assert.Positive(t, result.WeightNanos, result.Variant)
+		assert.Positive(t, result.CompareNanos, result.Variant)
+	}
+	assert.Contains(t, report.String(), "sorted_slice")
+}
+
+func TestRunRangeMapBenchmarks(t *testing.T) {
+	report, err := RunRangeMapBenchmarks(consolidationTestTrees()["euc"], "euc", RangeMapOptions{}, time.Millisecond)
+	require.NoError(t, err)
+	require.Len(t, report.Results, 2*len(ConsolidationStrategies()))
+	for _, result := range report.Results {
+		assert.Positive(t, result.Entries, result.Variant)
+		assert.Positive(t, result.DecodeNanos, result.Variant)
+		assert.Positive(t, result.EncodeNanos, result.Variant)
+	}
+	assert.Contains(t, report.String(), "linear+linear")
+}
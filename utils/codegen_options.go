@@ -0,0 +1,166 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+// CodegenOptions controls the parts of generated Go files that depend on the package that they are placed within. The
+// zero value generates files for the `encodings` package of GMS.
+type CodegenOptions struct {
+	// PackageName is the package clause of each file. Defaults to `encodings`.
+	PackageName string
+	// Header is the comment at the top of each file, without the comment markers. Defaults to the Apache license of
+	// Dolthub for the year (see Year).
+	Header string
+	// Year is the copyright year of the default header. Defaults to the year of the provenance's extraction when set,
+	// so that regenerating a saved model produces identical files, and otherwise to defaultCodegenYear.
+	Year int
+	// BuildTags is a build constraint expression (such as `!tinygo`) that is written as a `//go:build` line. No
+	// constraint is written when empty.
+	BuildTags string
+	// Prefix replaces the name of the character set or collation within the identifiers of a file, such that a prefix
+	// of `Custom` declares `Custom_RuneWeight` rather than `Utf16_unicode_ci_RuneWeight`. Every file of a character set
+	// or collation must be generated with the same prefix, as the files refer to one another's identifiers. Defaults to
+	// the name.
+	Prefix string
+	// EncoderType is the type that a character set's RangeMap is declared as. Defaults to `Encoder`.
+	EncoderType string
+	// ExtendedRangeMap writes the fields of a character set's RangeMap that only the RangeMap of this repository
+	// declares, which are the upper bounds that binary search its entries and whether the character set is ASCII
+	// compatible. GMS's RangeMap does not declare them, so they are omitted by default, and ParseRangeMapGoFile derives
+	// them from the entries either way.
+	ExtendedRangeMap bool
+	// Provenance is appended to the header comment of each file when set, so that every file records the server that
+	// its data came from.
+	Provenance *Provenance
+}
+
+// defaultCodegenYear is the copyright year of the default header when neither the year nor the provenance is set. It is
+// fixed rather than the current year, so that the same input generates the same file in every year.
+const defaultCodegenYear = 2022
+
+// defaultCodegenHeader is the header of every file when CodegenOptions.Header is empty.
+const defaultCodegenHeader = `Copyright %d Dolthub, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.`
+
+// ApplyToGoFile replaces the header, build constraint, and package clause of the given generated Go file with the ones
+// from the options. This applies the options to files that were generated without them, such as by RangeMapToGoFile.
+func (o CodegenOptions) ApplyToGoFile(contents []byte) ([]byte, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), "", contents, parser.PackageClauseOnly)
+	if err != nil {
+		return nil, fmt.Errorf("generated Go file is malformed: %w", err)
+	}
+	// The file set only contains this file, whose base is 1
+	packageEnd := int(file.Name.End()) - 1
+	return append([]byte(o.fileHeader()), contents[packageEnd:]...), nil
+}
+
+// fileHeader returns everything that precedes the declarations of a file, ending with the package clause.
+func (o CodegenOptions) fileHeader() string {
+	header := o.Header
+	if header == "" {
+		header = fmt.Sprintf(defaultCodegenHeader, o.year())
+	}
+	packageName := o.PackageName
+	if packageName == "" {
+		packageName = "encodings"
+	}
+	sb := strings.Builder{}
+	// Headers read from files may have Windows line endings or trailing whitespace, which would differ between checkouts
+	lines := strings.Split(strings.TrimRight(strings.ReplaceAll(header, "\r\n", "\n"), " \t\n"), "\n")
+	if o.Provenance != nil {
+		lines = append(append(lines, ""), o.Provenance.commentLines()...)
+	}
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t")
+		if line == "" {
+			sb.WriteString("//\n")
+		} else {
+			sb.WriteString("// " + line + "\n")
+		}
+	}
+	sb.WriteString("\n")
+	if o.BuildTags != "" {
+		sb.WriteString("//go:build " + o.BuildTags + "\n\n")
+	}
+	sb.WriteString("package " + packageName)
+	return sb.String()
+}
+
+// year returns the copyright year of the default header.
+func (o CodegenOptions) year() int {
+	switch {
+	case o.Year != 0:
+		return o.Year
+	case o.Provenance != nil && !o.Provenance.ExtractedAt.IsZero():
+		return o.Provenance.ExtractedAt.Year()
+	default:
+		return defaultCodegenYear
+	}
+}
+
+// CheckName returns an error when the identifiers of a file generated for the given character set or collation name
+// (or for the prefix when set) would not be valid Go identifiers, such as when the name is empty. The names come from
+// the user, so they are checked before anything is extracted or generated.
+func (o CodegenOptions) CheckName(name string) error {
+	if name == "" {
+		return fmt.Errorf("the name of a generated file may not be empty")
+	}
+	titleName, lowerName := o.names(name)
+	for _, identifier := range []string{titleName + "_", lowerName + "_"} {
+		if !token.IsIdentifier(identifier) {
+			return fmt.Errorf("`%s` cannot begin the identifiers of a generated file", identifier[:len(identifier)-1])
+		}
+	}
+	return nil
+}
+
+// names returns the title-cased and lower-cased forms of the given name (or of the prefix when set), which begin the
+// identifiers of a file. An empty name returns empty forms, which CheckName reports.
+func (o CodegenOptions) names(name string) (titleName string, lowerName string) {
+	if o.Prefix != "" {
+		name = o.Prefix
+	}
+	lowerName = strings.ToLower(name)
+	nameRunes := []rune(lowerName)
+	if len(nameRunes) > 0 {
+		nameRunes[0] = []rune(strings.ToUpper(string(nameRunes[0])))[0]
+	}
+	return string(nameRunes), lowerName
+}
+
+// encoderType returns the type that a character set's RangeMap is declared as.
+func (o CodegenOptions) encoderType() string {
+	if o.EncoderType == "" {
+		return "Encoder"
+	}
+	return o.EncoderType
+}
@@ -0,0 +1,254 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodegenOptions(t *testing.T) {
+	options := CodegenOptions{
+		PackageName: "charsets",
+		Header:      "Generated for testing.\n\nDo not edit.",
+		BuildTags:   "!tinygo",
+		Prefix:      "Custom",
+		EncoderType: "Charset",
+	}
+	rangeMap, err := RangeMapFromTree(consolidationTestTrees()["euc"])
+	require.NoError(t, err)
+	contents := RangeMapToGoFileWithOptions(rangeMap, CaseMappings{}, "euc", options)
+	assert.True(t, strings.HasPrefix(contents, "// Generated for testing.\n//\n// Do not edit.\n\n//go:build !tinygo\n\npackage charsets\n"))
+	file, err := parser.ParseFile(token.NewFileSet(), "", contents, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "charsets", file.Name.Name)
+	declaration := file.Scope.Lookup("Custom")
+	require.NotNil(t, declaration)
+	assert.Equal(t, "Charset", declaration.Decl.(*ast.ValueSpec).Type.(*ast.Ident).Name)
+	assert.NotNil(t, file.Scope.Lookup("Custom_DecodeString"))
+	// The lookup accessor uses the encoder type, while the name is still that of the character set
+	assert.Contains(t, contents, "func Custom_Lookup(name string) (Charset, bool) {")
+	assert.Contains(t, contents, "const Custom_Name = \"euc\"")
+
+	rc := NewRuneComparatorFromOrder([][]rune{{'a'}, {'b', 'B'}, {'c'}})
+	contents = RuneComparatorToGoFileWithOptions(rc, "test_collation", false, RuneComparatorGoFileOptions{Codegen: options})
+	file, err = parser.ParseFile(token.NewFileSet(), "", contents, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "charsets", file.Name.Name)
+	for _, name := range []string{"Custom_RuneWeight", "custom_Weights"} {
+		assert.NotNil(t, file.Scope.Lookup(name), name)
+	}
+	// The documentation still refers to the collation rather than the prefix
+	assert.Contains(t, contents, "`test_collation` collation")
+
+	// The registration refers to the identifiers of the prefix, while its tables are still keyed by name
+	manifest := &Manifest{Entries: []ManifestEntry{
+		{Name: "euc", Kind: ManifestKindCharset},
+		{Name: "test_collation", Kind: ManifestKindCollation},
+	}}
+	contents = RegistrationToGoFile(manifest, options)
+	file, err = parser.ParseFile(token.NewFileSet(), "", contents, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "charsets", file.Name.Name)
+	assert.Contains(t, contents, "var ExtractedCharacterSets = map[string]Charset{\n\t\"euc\": Custom,\n}")
+	assert.Contains(t, contents, "\t\"test_collation\": {\n")
+	for _, name := range []string{"Custom_RuneWeight", "Custom_Compare", "Custom_PadSpace"} {
+		assert.Contains(t, contents, name+",")
+	}
+	assert.NotContains(t, contents, "Euc")
+	assert.NotContains(t, contents, "Test_collation")
+
+	// Files generated without the options only have their header and package replaced
+	applied, err := options.ApplyToGoFile([]byte(RuneComparatorToGoFile(rc, "test_collation", false)))
+	require.NoError(t, err)
+	file, err = parser.ParseFile(token.NewFileSet(), "", applied, parser.ParseComments)
+	require.NoError(t, err)
+	assert.Equal(t, "charsets", file.Name.Name)
+	assert.True(t, strings.HasPrefix(string(applied), "// Generated for testing."))
+	assert.NotContains(t, string(applied), "Dolthub")
+	assert.NotNil(t, file.Scope.Lookup("Test_collation_RuneWeight"))
+	_, err = options.ApplyToGoFile([]byte("func {"))
+	assert.Error(t, err)
+
+	// The zero value matches the files of generators without options
+	assert.Equal(t, RuneComparatorToGoFile(rc, "test_collation", true), RuneComparatorToGoFileWithOptions(rc, "test_collation", true, RuneComparatorGoFileOptions{}))
+	applied, err = CodegenOptions{}.ApplyToGoFile([]byte(RangeMapToGoFile(rangeMap, CaseMappings{}, "euc")))
+	require.NoError(t, err)
+	assert.Equal(t, RangeMapToGoFile(rangeMap, CaseMappings{}, "euc"), string(applied))
+}
+
+func TestCodegenOptionsDeterministic(t *testing.T) {
+	// The year is fixed by the option, and then by the provenance
+	assert.Contains(t, CodegenOptions{Year: 2022}.fileHeader(), "// Copyright 2022 Dolthub, Inc.\n")
+	provenance := &Provenance{ServerVersion: "8.0.31", ExtractedAt: time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)}
+	assert.Contains(t, CodegenOptions{Provenance: provenance}.fileHeader(), "// Copyright 2021 Dolthub, Inc.\n")
+	assert.Contains(t, CodegenOptions{Year: 2022, Provenance: provenance}.fileHeader(), "// Copyright 2022 Dolthub, Inc.\n")
+
+	// Line endings and trailing whitespace of the header do not change the file
+	assert.Equal(t, CodegenOptions{Header: "Generated for testing.\n\nDo not edit.\n"}.fileHeader(),
+		CodegenOptions{Header: "Generated for testing.  \r\n\t\r\nDo not edit.\r\n\r\n"}.fileHeader())
+
+	// The weight map is listed by rune rather than by weight
+	rc := NewRuneComparatorFromOrder([][]rune{{'z'}, {'b', 'y'}, {'a'}, {'c'}})
+	contents := RuneComparatorToGoFileWithOptions(rc, "test_collation", false, RuneComparatorGoFileOptions{Codegen: CodegenOptions{Year: 2022}})
+	assert.Contains(t, contents, "\t97: 2,\n\t98: 1,\n\t99: 3,\n\t121: 1,\n\t122: 0,\n")
+	assert.Equal(t, contents, RuneComparatorToGoFileWithOptions(rc, "test_collation", false, RuneComparatorGoFileOptions{Codegen: CodegenOptions{Year: 2022}}))
+
+	// The registration does not depend on the order of the manifest's entries
+	manifest := &Manifest{Entries: []ManifestEntry{
+		{Name: "utf8mb4_0900_bin", Kind: ManifestKindCollation},
+		{Name: "latin1", Kind: ManifestKindCharset},
+		{Name: "latin1_general_ci", Kind: ManifestKindCollation},
+	}}
+	reversed := &Manifest{Entries: []ManifestEntry{manifest.Entries[2], manifest.Entries[1], manifest.Entries[0]}}
+	assert.Equal(t, RegistrationToGoFile(manifest, CodegenOptions{}), RegistrationToGoFile(reversed, CodegenOptions{}))
+	assert.Equal(t, "utf8mb4_0900_bin", manifest.Entries[0].Name)
+}
+
+func TestCodegenOptionsExtendedRangeMap(t *testing.T) {
+	rangeMap, err := RangeMapFromTree(consolidationTestTrees()["euc"])
+	require.NoError(t, err)
+	require.True(t, rangeMap.IsASCIICompatible())
+	// GMS's RangeMap does not declare the extended fields, so they are only written when requested
+	contents := RangeMapToGoFile(rangeMap, CaseMappings{}, "euc")
+	for _, field := range []string{"inputUpperBounds", "outputUpperBounds", "asciiCompatible"} {
+		assert.NotContains(t, contents, field)
+	}
+	extended := RangeMapToGoFileWithOptions(rangeMap, CaseMappings{}, "euc", CodegenOptions{ExtendedRangeMap: true})
+	assert.Contains(t, extended, "\tinputUpperBounds: [][]byte{\n")
+	assert.Contains(t, extended, "\toutputUpperBounds: [][]byte{\n")
+	assert.Contains(t, extended, "\tasciiCompatible: true,\n")
+	// Both files parse to a RangeMap that is indexed for binary search and takes the ASCII fast path
+	for _, file := range []string{contents, extended} {
+		parsed, _, err := ParseRangeMapGoFile(file)
+		require.NoError(t, err)
+		assert.True(t, parsed.asciiCompatible)
+		assert.Equal(t, rangeMap.inputUpperBounds, parsed.inputUpperBounds)
+		assert.Equal(t, rangeMap.outputUpperBounds, parsed.outputUpperBounds)
+		decoded, ok := parsed.Decode([]byte{'A'})
+		assert.True(t, ok)
+		assert.Equal(t, []byte{'A'}, decoded)
+	}
+}
+
+func TestCodegenOptionsCheckName(t *testing.T) {
+	assert.NoError(t, CodegenOptions{}.CheckName("utf8mb4_0900_ai_ci"))
+	assert.NoError(t, CodegenOptions{Prefix: "Custom"}.CheckName("utf8mb4_0900_ai_ci"))
+	assert.Error(t, CodegenOptions{}.CheckName(""))
+	assert.Error(t, CodegenOptions{}.CheckName("0900_ai_ci"))
+	assert.Error(t, CodegenOptions{Prefix: "custom-prefix"}.CheckName("latin1"))
+	// An empty name is reported rather than panicking within any generator
+	titleName, lowerName := CodegenOptions{}.names("")
+	assert.Empty(t, titleName)
+	assert.Empty(t, lowerName)
+}
+
+func TestCodegenOptionsEveryGenerator(t *testing.T) {
+	rc := NewRuneComparatorFromOrder([][]rune{{'a', 'A'}, {'b', 'B'}, {'c'}})
+	rangeMap, err := RangeMapFromTree(consolidationTestTrees()["euc"])
+	require.NoError(t, err)
+	ws := NewWeightString(1, true)
+	ws.Weights['a'] = []byte{0x10, 0x61}
+	manifest := &Manifest{Entries: []ManifestEntry{
+		{Name: "latin1", Kind: ManifestKindCharset},
+		{Name: "latin1_general_ci", Kind: ManifestKindCollation},
+	}}
+	generators := map[string]func(options CodegenOptions) string{
+		"RangeMapToGoFileWithOptions": func(options CodegenOptions) string {
+			return RangeMapToGoFileWithOptions(rangeMap, CaseMappings{}, "euc", options)
+		},
+		"RuneComparatorToGoFileWithOptions": func(options CodegenOptions) string {
+			return RuneComparatorToGoFileWithOptions(rc, "test_ci", true, RuneComparatorGoFileOptions{Codegen: options})
+		},
+		"WeightStringToGoFile": func(options CodegenOptions) string {
+			return WeightStringToGoFile(ws, "test_ci", options)
+		},
+		"TextEncodingToGoFile": func(options CodegenOptions) string {
+			return TextEncodingToGoFile("euc", options)
+		},
+		"RuneComparatorToSortedSliceGoFile": func(options CodegenOptions) string {
+			return RuneComparatorToSortedSliceGoFile(rc, "test_ci", true, options)
+		},
+		"ExpansionsToGoFile": func(options CodegenOptions) string {
+			contents, err := ExpansionsToGoFile(rc, "test_ci", map[rune][]rune{'c': {'a', 'b'}}, options)
+			require.NoError(t, err)
+			return contents
+		},
+		"RuneComparatorToEmbeddedGoFile": func(options CodegenOptions) string {
+			contents, _, err := RuneComparatorToEmbeddedGoFile(rc, "test_ci", true, options)
+			require.NoError(t, err)
+			return contents
+		},
+		"RuneComparatorReverseToGoFile": func(options CodegenOptions) string {
+			return RuneComparatorReverseToGoFile(rc, "test_ci", options)
+		},
+		"EquivalenceClassesToGoFile": func(options CodegenOptions) string {
+			return EquivalenceClassesToGoFile(rc, "test_ci", options)
+		},
+		"CollationMetadataToGoFile": func(options CodegenOptions) string {
+			return CollationMetadataToGoFile(CollationMetadata{Name: "test_ci", Charset: "euc", ID: 1000}, options)
+		},
+		"RuneComparatorAliasToGoFile": func(options CodegenOptions) string {
+			return RuneComparatorAliasToGoFile("test_alias_ci", "test_ci", false, options)
+		},
+		"LossyMappingsToGoFile": func(options CodegenOptions) string {
+			return LossyMappingsToGoFile("euc", []LossyMapping{{Rune: 0xFF5E, Encoding: []byte{0xA1, 0xC1}, Preferred: 0x301C}}, options)
+		},
+		"CharacterSetMetadataToGoFile": func(options CodegenOptions) string {
+			return CharacterSetMetadataToGoFile(CharacterSetMetadata{Name: "euc", DefaultCollation: "euc_ci", MaxLength: 2}, options)
+		},
+		"RegistrationToGoFile": func(options CodegenOptions) string {
+			return RegistrationToGoFile(manifest, options)
+		},
+		"CharacterSetTestToGoFile": func(options CodegenOptions) string {
+			return CharacterSetTestToGoFile("euc", []CharacterSetSample{{Encoded: []byte{0x41}, Decoded: []byte{0x41}, Reencoded: []byte{0x41}}}, options)
+		},
+		"RuneComparatorTestToGoFile": func(options CodegenOptions) string {
+			return RuneComparatorTestToGoFile("test_ci", []CollationSample{{Left: 'a', Right: 'b', Comparison: -1}}, options)
+		},
+		"CaseFoldingToGoFile": func(options CodegenOptions) string {
+			return CaseFoldingToGoFile("test_ci", map[rune]rune{'A': 'a'}, options)
+		},
+		"AccentStrippingToGoFile": func(options CodegenOptions) string {
+			return AccentStrippingToGoFile("test_ai_ci", map[rune]rune{'\u00E1': 'a'}, options)
+		},
+		"ProvenanceToGoFile": func(options CodegenOptions) string {
+			return ProvenanceToGoFile("test_ci", &Provenance{ServerVersion: "8.0.31"}, options)
+		},
+	}
+	// The year of the header is the only part of a file that could change between runs, so a year that is not the
+	// current year shows that no generator writes the current year
+	options := CodegenOptions{PackageName: "charsets", Year: 1999}
+	currentYear := fmt.Sprintf("Copyright %d ", time.Now().Year())
+	for name, generator := range generators {
+		contents := generator(options)
+		assert.Equal(t, contents, generator(options), name)
+		assert.True(t, strings.HasPrefix(contents, "// Copyright 1999 Dolthub, Inc.\n"), name)
+		assert.NotContains(t, contents, currentYear, name)
+		file, err := parser.ParseFile(token.NewFileSet(), "", contents, parser.PackageClauseOnly)
+		require.NoError(t, err, name)
+		assert.Equal(t, "charsets", file.Name.Name, name)
+		// Without a year or provenance, the header uses a fixed year rather than the current year
+		assert.True(t, strings.HasPrefix(generator(CodegenOptions{}), "// Copyright 2022 Dolthub, Inc.\n"), name)
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+)
+
+// CollationCodegen is the form that the weights of a generated collation file take. Each form exposes the same weight
+// and comparison functions, so they may be swapped without changing any callers.
+type CollationCodegen string
+
+const (
+	// CollationCodegenMap writes the weights as range comparisons and a map literal within the Go file. This is the
+	// default.
+	CollationCodegenMap CollationCodegen = "map"
+	// CollationCodegenEmbed writes the weights as a compressed binary table alongside a small Go file, which embeds the
+	// table using go:embed and decodes it on first use. This avoids compiling large map literals, which slows builds
+	// and grows binaries.
+	CollationCodegenEmbed CollationCodegen = "embed"
+	// CollationCodegenSortedSlice writes every range of runes to a slice sorted by the lower bound of each range, which
+	// is binary searched. This avoids building a map when the program starts.
+	CollationCodegenSortedSlice CollationCodegen = "sorted_slice"
+)
+
+// CollationCodegens returns every CollationCodegen.
+func CollationCodegens() []CollationCodegen {
+	return []CollationCodegen{CollationCodegenMap, CollationCodegenEmbed, CollationCodegenSortedSlice}
+}
+
+// ParseCollationCodegen returns the CollationCodegen with the given name. An empty name returns the default.
+func ParseCollationCodegen(name string) (CollationCodegen, error) {
+	if name == "" {
+		return CollationCodegenMap, nil
+	}
+	for _, codegen := range CollationCodegens() {
+		if string(codegen) == name {
+			return codegen, nil
+		}
+	}
+	return "", fmt.Errorf("unknown collation codegen `%s`, expected one of %v", name, CollationCodegens())
+}
@@ -0,0 +1,36 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCollationCodegen(t *testing.T) {
+	codegen, err := ParseCollationCodegen("")
+	require.NoError(t, err)
+	assert.Equal(t, CollationCodegenMap, codegen)
+	codegen, err = ParseCollationCodegen("embed")
+	require.NoError(t, err)
+	assert.Equal(t, CollationCodegenEmbed, codegen)
+	codegen, err = ParseCollationCodegen("sorted_slice")
+	require.NoError(t, err)
+	assert.Equal(t, CollationCodegenSortedSlice, codegen)
+	_, err = ParseCollationCodegen("switch")
+	assert.Error(t, err)
+}
@@ -0,0 +1,323 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CollationDelta contains the weights of a collation as a delta from the weights of a base collation, such as a
+// tailoring (`utf8mb4_hungarian_ci`) from the collation that it tailors (`utf8mb4_unicode_ci`). The weight of each rune
+// is the base collation's weight multiplied by the scale, unless the rune is within the overrides. Scaling leaves gaps
+// between the base weights, so that the runes that the collation moves may be given weights between them.
+type CollationDelta struct {
+	// Base is the name of the base collation.
+	Base string
+	// Scale is the multiplier of the base collation's weights.
+	Scale int32
+	// Overrides are the weights of the runes whose weight is not the base collation's weight multiplied by the scale.
+	Overrides map[rune]int32
+}
+
+// collationDeltaSamples is the number of rows that FindCollationDeltas compares between two collations before
+// computing the delta, as most pairs of collations are too dissimilar for a delta to be worthwhile.
+const collationDeltaSamples = 1024
+
+// collationDeltaSimilarity is the fraction of sampled rows that must have the same order within both collations for
+// FindCollationDeltas to compute the delta.
+const collationDeltaSimilarity = 0.9
+
+// collationDeltaRatio is the minimum ratio between the number of entries within the collation's own weight map and the
+// number of overrides for FindCollationDeltas to use the delta.
+const collationDeltaRatio = 4
+
+// NewCollationDelta returns the delta of the given collation Model from the given base collation Model. The overrides
+// are kept to a minimum by finding the longest sequence of the collation's rows whose base weights ascend (weighted by
+// the number of runes), as those rows may keep their scaled base weights. Runes that the collation does not have a
+// weight for, but the base collation does, are overridden with the maximum weight. The base collation's generated
+// weights must be the rows of its Model, so it may not be generated with implicit weights or as a delta itself.
+func NewCollationDelta(model *Model, base *Model) (*CollationDelta, error) {
+	for _, m := range []*Model{model, base} {
+		if m.Kind != ManifestKindCollation {
+			return nil, fmt.Errorf("model `%s` is a %s rather than a %s", m.Name, m.Kind, ManifestKindCollation)
+		}
+	}
+	baseWeights := make(map[rune]int, len(base.Weights))
+	for weight, row := range base.Weights {
+		for _, r := range row {
+			baseWeights[r] = weight
+		}
+	}
+
+	// Each candidate keeps the runes of a row that share a base weight, and chains onto the best candidate of an earlier
+	// row with a smaller base weight
+	type candidate struct {
+		row    int
+		weight int
+		total  int
+		parent int
+	}
+	var candidates []candidate
+	// The tree holds the index of the best candidate whose base weight is at most the index
+	tree := make([]int, len(base.Weights)+1)
+	for i := range tree {
+		tree[i] = -1
+	}
+	better := func(l int, r int) int {
+		if l == -1 || (r != -1 && candidates[r].total > candidates[l].total) {
+			return r
+		}
+		return l
+	}
+	query := func(weight int) int {
+		best := -1
+		for i := weight; i > 0; i -= i & -i {
+			best = better(best, tree[i])
+		}
+		return best
+	}
+	update := func(weight int, idx int) {
+		for i := weight + 1; i < len(tree); i += i & -i {
+			tree[i] = better(tree[i], idx)
+		}
+	}
+	best := -1
+	for rowIdx, row := range model.Weights {
+		counts := make(map[int]int)
+		for _, r := range row {
+			if weight, ok := baseWeights[r]; ok {
+				counts[weight]++
+			}
+		}
+		rowStart := len(candidates)
+		for weight, count := range counts {
+			parent := query(weight)
+			total := count
+			if parent != -1 {
+				total += candidates[parent].total
+			}
+			candidates = append(candidates, candidate{row: rowIdx, weight: weight, total: total, parent: parent})
+		}
+		// The candidates of a row may not chain onto each other, so they're added once the row has been queried
+		for idx := rowStart; idx < len(candidates); idx++ {
+			update(candidates[idx].weight, idx)
+			best = better(best, idx)
+		}
+	}
+	kept := make(map[int]int)
+	for idx := best; idx != -1; idx = candidates[idx].parent {
+		kept[candidates[idx].row] = candidates[idx].weight
+	}
+	keptRows := make([]int, 0, len(kept))
+	for row := range kept {
+		keptRows = append(keptRows, row)
+	}
+	sort.Ints(keptRows)
+
+	// The scale must leave room for every row between two kept rows
+	scale := int64(1)
+	for i := 1; i < len(keptRows); i++ {
+		needed := int64(keptRows[i] - keptRows[i-1])
+		gap := int64(kept[keptRows[i]] - kept[keptRows[i-1]])
+		if s := (needed + gap - 1) / gap; s > scale {
+			scale = s
+		}
+	}
+	rowWeights := make([]int64, len(model.Weights))
+	for i := range rowWeights {
+		if weight, ok := kept[i]; ok {
+			rowWeights[i] = int64(weight) * scale
+		} else if i > 0 {
+			rowWeights[i] = rowWeights[i-1] + 1
+		} else if len(keptRows) > 0 {
+			// The rows before the first kept row count down to it
+			rowWeights[i] = int64(kept[keptRows[0]])*scale - int64(keptRows[0])
+		}
+	}
+	maxWeight := int64(len(base.Weights)) * scale
+	if len(rowWeights) > 0 && rowWeights[len(rowWeights)-1] > maxWeight {
+		maxWeight = rowWeights[len(rowWeights)-1]
+	}
+	if maxWeight >= 2147483647 {
+		return nil, fmt.Errorf("the weights of `%s` cannot be scaled from `%s` by %d", model.Name, base.Name, scale)
+	}
+
+	delta := &CollationDelta{Base: strings.ToLower(base.Name), Scale: int32(scale), Overrides: make(map[rune]int32)}
+	inModel := make(map[rune]struct{})
+	for rowIdx, row := range model.Weights {
+		keptWeight, isKept := kept[rowIdx]
+		for _, r := range row {
+			inModel[r] = struct{}{}
+			if baseWeight, ok := baseWeights[r]; !ok || !isKept || baseWeight != keptWeight {
+				delta.Overrides[r] = int32(rowWeights[rowIdx])
+			}
+		}
+	}
+	for r := range baseWeights {
+		if _, ok := inModel[r]; !ok {
+			delta.Overrides[r] = 2147483647
+		}
+	}
+	return delta, nil
+}
+
+// Weight returns the weight of the given rune, given the rune's weight from the base collation. This matches the
+// generated weight function.
+func (d *CollationDelta) Weight(r rune, baseWeight int32) int32 {
+	if weight, ok := d.Overrides[r]; ok {
+		return weight
+	}
+	if baseWeight == 2147483647 {
+		return baseWeight
+	}
+	return baseWeight * d.Scale
+}
+
+// FindCollationDeltas returns the delta of each collation whose weights are best generated as a delta from another of
+// the given collations, keyed by the lower-cased name of the collation. Only collations of the same character set are
+// compared, and only pairs that order most of a sample of runes the same way have their delta computed. A delta is only
+// used when its overrides are a fraction of the collation's own weight map. The deltas with the fewest overrides are
+// chosen first, and a collation is never both a delta and the base of a delta, so the deltas do not form chains.
+func FindCollationDeltas(models []*Model) (map[string]*CollationDelta, error) {
+	type pair struct {
+		model *Model
+		delta *CollationDelta
+	}
+	var pairs []pair
+	for _, model := range models {
+		if model.Kind != ManifestKindCollation {
+			return nil, fmt.Errorf("model `%s` is a %s rather than a %s", model.Name, model.Kind, ManifestKindCollation)
+		}
+		rc, err := model.RuneComparator()
+		if err != nil {
+			return nil, err
+		}
+		mapSize := len(rc.mapWeights())
+		for _, base := range models {
+			if base == model || collationCharacterSet(base.Name) != collationCharacterSet(model.Name) ||
+				!collationsAreSimilar(model, base) {
+				continue
+			}
+			delta, err := NewCollationDelta(model, base)
+			if err != nil {
+				// A delta that cannot be scaled is not worthwhile
+				continue
+			}
+			if len(delta.Overrides)*collationDeltaRatio <= mapSize {
+				pairs = append(pairs, pair{model: model, delta: delta})
+			}
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return len(pairs[i].delta.Overrides) < len(pairs[j].delta.Overrides)
+	})
+	deltas := make(map[string]*CollationDelta)
+	bases := make(map[string]struct{})
+	for _, p := range pairs {
+		name := strings.ToLower(p.model.Name)
+		_, isDelta := deltas[name]
+		_, isBase := bases[name]
+		_, baseIsDelta := deltas[p.delta.Base]
+		if isDelta || isBase || baseIsDelta {
+			continue
+		}
+		deltas[name] = p.delta
+		bases[p.delta.Base] = struct{}{}
+	}
+	return deltas, nil
+}
+
+// CollationDeltaToGoFile returns the given CollationDelta as a Go file, whose weight function calls the weight function
+// of the base collation, so the file of the base collation must be copied alongside it.
+func CollationDeltaToGoFile(delta *CollationDelta, name string, padSpace bool, options CodegenOptions) string {
+	titleName, lowerName := options.names(name)
+	baseTitleName, _ := CodegenOptions{}.names(delta.Base)
+
+	fileSb := strings.Builder{}
+	fileSb.WriteString(options.fileHeader())
+	fileSb.WriteString(fmt.Sprintf(`
+
+// %s_RuneWeight returns the weight of a given rune based on its relational sort order from
+// the %s collation. The weights are derived from the %s collation, with the runes that sort
+// differently listed within %s_Overrides.
+func %s_RuneWeight(r rune) int32 {
+	weight, ok := %s_Overrides[r]
+	if ok {
+		return weight
+	}
+	weight = %s_RuneWeight(r)
+	if weight == 2147483647 {
+		return weight
+	}
+	return weight * %d
+}
+
+`, titleName, "`"+strings.ToLower(name)+"`", "`"+delta.Base+"`", lowerName, titleName, lowerName, baseTitleName, delta.Scale))
+	fileSb.WriteString(runeComparatorCompareFunc(titleName, lowerName, padSpace, false))
+	fileSb.WriteString(fmt.Sprintf(`
+// %s_Overrides contain a map from rune to weight for the runes whose weight within the %s
+// collation does not match the scaled weight of the %s collation.
+var %s_Overrides = map[rune]int32{
+`, lowerName, "`"+strings.ToLower(name)+"`", "`"+delta.Base+"`", lowerName))
+	runes := make([]rune, 0, len(delta.Overrides))
+	for r := range delta.Overrides {
+		runes = append(runes, r)
+	}
+	sort.Slice(runes, func(i, j int) bool {
+		return runes[i] < runes[j]
+	})
+	for _, r := range runes {
+		fileSb.WriteString(fmt.Sprintf("\t%d: %d,\n", r, delta.Overrides[r]))
+	}
+	fileSb.WriteString("}\n")
+	return fileSb.String()
+}
+
+// collationsAreSimilar returns whether most of a sample of the model's rows have the same order within the base.
+func collationsAreSimilar(model *Model, base *Model) bool {
+	baseWeights := make(map[rune]int, len(base.Weights))
+	for weight, row := range base.Weights {
+		for _, r := range row {
+			baseWeights[r] = weight
+		}
+	}
+	step := len(model.Weights) / collationDeltaSamples
+	if step == 0 {
+		step = 1
+	}
+	agreements, comparisons := 0, 0
+	previous := -1
+	for i := 0; i < len(model.Weights); i += step {
+		weight, ok := baseWeights[model.Weights[i][0]]
+		if !ok {
+			weight = -1
+		}
+		if previous != -1 {
+			comparisons++
+			if weight > previous {
+				agreements++
+			}
+		}
+		previous = weight
+	}
+	return comparisons > 0 && float64(agreements) >= float64(comparisons)*collationDeltaSimilarity
+}
+
+// collationCharacterSet returns the character set at the start of a collation's name.
+func collationCharacterSet(name string) string {
+	return strings.Split(strings.ToLower(name), "_")[0]
+}
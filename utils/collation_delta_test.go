@@ -0,0 +1,112 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// latinOrder returns the runes from U+0100 to U+017F in descending order, so that none of them may be written as a
+// range.
+func latinOrder() [][]rune {
+	var order [][]rune
+	for r := rune(0x017F); r >= 0x0100; r-- {
+		order = append(order, []rune{r})
+	}
+	return order
+}
+
+// orderFromWeights groups the given runes by the given weight function, in the same way as RuneWeights.Order.
+func orderFromWeights(runes []rune, weight func(r rune) int32) [][]rune {
+	rc := NewRuneComparator()
+	rc.SetComparator(func(l rune, r rune) int {
+		lWeight, rWeight := weight(l), weight(r)
+		if lWeight < rWeight {
+			return -1
+		} else if lWeight > rWeight {
+			return 1
+		}
+		return 0
+	})
+	for _, r := range runes {
+		if weight(r) != 2147483647 {
+			rc.Insert(r)
+		}
+	}
+	return rc.rows()
+}
+
+func TestNewCollationDelta(t *testing.T) {
+	base := NewCollationModel("utf8mb4_unicode_ci", NewRuneComparatorFromOrder(append([][]rune{{'a', 'A'}, {'c'}, {'d'}, {'z'}}, latinOrder()...)), true)
+	// The tailoring moves `z` between `c` and `d` alongside a rune that the base does not have, separates `a` from `A`,
+	// and does not have a weight for U+0100
+	tailoredOrder := [][]rune{{'a'}, {'A'}, {'c'}, {'z'}, {0x10FFFD}, {'d'}}
+	tailoredOrder = append(tailoredOrder, latinOrder()[:len(latinOrder())-1]...)
+	tailored := NewCollationModel("utf8mb4_hungarian_ci", NewRuneComparatorFromOrder(tailoredOrder), true)
+
+	delta, err := NewCollationDelta(tailored, base)
+	require.NoError(t, err)
+	assert.Equal(t, "utf8mb4_unicode_ci", delta.Base)
+	assert.Equal(t, int32(3), delta.Scale)
+	assert.Len(t, delta.Overrides, 4)
+	assert.Equal(t, int32(2147483647), delta.Overrides[0x0100])
+
+	baseRC, err := base.RuneComparator()
+	require.NoError(t, err)
+	baseWeights := make(map[rune]int32)
+	for weight, row := range baseRC.rows() {
+		for _, r := range row {
+			baseWeights[r] = int32(weight)
+		}
+	}
+	runes := []rune{'a', 'A', 'c', 'd', 'z', 0x10FFFD}
+	for r := rune(0x0100); r < 0x0180; r++ {
+		runes = append(runes, r)
+	}
+	assert.Equal(t, tailoredOrder, orderFromWeights(runes, func(r rune) int32 {
+		baseWeight, ok := baseWeights[r]
+		if !ok {
+			baseWeight = 2147483647
+		}
+		return delta.Weight(r, baseWeight)
+	}))
+
+	contents := CollationDeltaToGoFile(delta, tailored.Name, true, CodegenOptions{})
+	assert.Contains(t, contents, "weight = Utf8mb4_unicode_ci_RuneWeight(r)")
+	assert.Contains(t, contents, "return weight * 3")
+	_, err = ParseRuneComparatorGoFile(contents)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "utf8mb4_unicode_ci")
+}
+
+func TestFindCollationDeltas(t *testing.T) {
+	base := NewCollationModel("utf8mb4_unicode_ci", NewRuneComparatorFromOrder(append([][]rune{{'a'}, {'b'}}, latinOrder()...)), true)
+	tailored := NewCollationModel("utf8mb4_hungarian_ci", NewRuneComparatorFromOrder(append([][]rune{{'b'}, {'a'}}, latinOrder()...)), true)
+	// Collations of another character set are never compared
+	other := NewCollationModel("utf16_unicode_ci", NewRuneComparatorFromOrder(append([][]rune{{'a'}, {'b'}}, latinOrder()...)), true)
+
+	deltas, err := FindCollationDeltas([]*Model{base, tailored, other})
+	require.NoError(t, err)
+	// Either collation could be a delta of the other, but never both
+	require.Len(t, deltas, 1)
+	for name, delta := range deltas {
+		assert.Contains(t, []string{"utf8mb4_unicode_ci", "utf8mb4_hungarian_ci"}, name)
+		assert.NotEqual(t, name, delta.Base)
+		assert.Len(t, delta.Overrides, 1)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// GroupCollationModels groups the given collation Models by their weights, such that every Model within a group has
+// identical weights. Aliases and legacy duplicates commonly have identical weights, and a single generated table may be
+// shared by all of them. Each group is sorted by name, and the groups are sorted by the name of their first Model, which
+// is the Model whose table should be shared. Padding is not considered, as it does not affect the weights.
+func GroupCollationModels(models []*Model) ([][]*Model, error) {
+	groupsByFingerprint := make(map[[sha256.Size]byte][]*Model)
+	for _, model := range models {
+		if model.Kind != ManifestKindCollation {
+			return nil, fmt.Errorf("model `%s` is a %s rather than a %s", model.Name, model.Kind, ManifestKindCollation)
+		}
+		fingerprint := model.weightsFingerprint()
+		groupsByFingerprint[fingerprint] = append(groupsByFingerprint[fingerprint], model)
+	}
+	groups := make([][]*Model, 0, len(groupsByFingerprint))
+	for _, group := range groupsByFingerprint {
+		sort.Slice(group, func(i, j int) bool {
+			return group[i].Name < group[j].Name
+		})
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i][0].Name < groups[j][0].Name
+	})
+	return groups, nil
+}
+
+// weightsFingerprint returns a hash of the Model's weights. Two Models have the same fingerprint only when every rune
+// has the same weight in both.
+func (m *Model) weightsFingerprint() [sha256.Size]byte {
+	hash := sha256.New()
+	for _, row := range m.Weights {
+		_ = binary.Write(hash, binary.LittleEndian, row)
+		// An invalid rune separates each weight, so that the boundaries between weights are part of the hash
+		_ = binary.Write(hash, binary.LittleEndian, int32(-1))
+	}
+	var fingerprint [sha256.Size]byte
+	copy(fingerprint[:], hash.Sum(nil))
+	return fingerprint
+}
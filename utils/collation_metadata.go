@@ -0,0 +1,79 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CollationMetadata contains the properties of a collation that GMS registers alongside its weights, as reported by
+// SHOW COLLATION.
+type CollationMetadata struct {
+	Name    string `json:"name"`
+	Charset string `json:"charset"`
+	// ID is the numeric ID of the collation, which is sent over the wire protocol.
+	ID int `json:"id"`
+	// IsDefault is whether the collation is the default collation of its character set.
+	IsDefault bool `json:"is_default"`
+	// IsCompiled is whether the collation is compiled into the server, rather than loaded from a definition file.
+	IsCompiled bool `json:"is_compiled"`
+	// SortLength is the SORTLEN of the collation, which is zero for collations that do not use a fixed length per
+	// character.
+	SortLength int `json:"sort_length"`
+	// Flavor is the server that the collation was extracted from. Empty for metadata recorded before the flavor was
+	// tracked, which was always MySQL.
+	Flavor ServerFlavor `json:"flavor,omitempty"`
+}
+
+// IsNoPadCollationName returns whether the name of the given MariaDB collation marks it as NO PAD. MariaDB does not
+// report the pad attribute, and instead names each NO PAD variant with a `nopad` part (such as `utf8mb4_nopad_bin` and
+// `utf8mb4_unicode_520_nopad_ci`).
+func IsNoPadCollationName(collation string) bool {
+	for _, part := range strings.Split(strings.ToLower(collation), "_") {
+		if part == "nopad" {
+			return true
+		}
+	}
+	return false
+}
+
+// CollationMetadataToGoFile returns the given metadata as a Go file of constants for inclusion in an application,
+// alongside the file that RuneComparatorToGoFile generated for the same collation.
+func CollationMetadataToGoFile(metadata CollationMetadata, options CodegenOptions) string {
+	titleName, lowerName := options.names(metadata.Name)
+	flavor := metadata.Flavor
+	if flavor == "" {
+		flavor = ServerFlavorMySQL
+	}
+	return fmt.Sprintf(`%s
+
+const (
+	// %[2]s_ID is the ID of the %[3]s collation.
+	%[2]s_ID = %[4]d
+	// %[2]s_CharacterSet is the character set of the %[3]s collation.
+	%[2]s_CharacterSet = %[5]q
+	// %[2]s_IsDefault is whether %[3]s is the default collation of its character set.
+	%[2]s_IsDefault = %[6]t
+	// %[2]s_IsCompiled is whether %[3]s is compiled into the server.
+	%[2]s_IsCompiled = %[7]t
+	// %[2]s_SortLength is the SORTLEN of the %[3]s collation.
+	%[2]s_SortLength = %[8]d
+	// %[2]s_Flavor is the server that the %[3]s collation was extracted from.
+	%[2]s_Flavor = %[9]q
+)
+`, options.fileHeader(), titleName, "`"+lowerName+"`", metadata.ID, metadata.Charset, metadata.IsDefault,
+		metadata.IsCompiled, metadata.SortLength, string(flavor))
+}
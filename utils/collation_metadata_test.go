@@ -0,0 +1,61 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMariaDBNoPadCollations(t *testing.T) {
+	assert.True(t, IsNoPadCollationName("utf8mb4_nopad_bin"))
+	assert.True(t, IsNoPadCollationName("utf8mb4_unicode_520_nopad_ci"))
+	assert.True(t, IsNoPadCollationName("latin1_swedish_nopad_ci"))
+	assert.False(t, IsNoPadCollationName("utf8mb4_unicode_520_ci"))
+	assert.False(t, IsNoPadCollationName("utf8mb4_0900_ai_ci"))
+
+	// The NO PAD variants are extracted the same way as the collations that they're named after
+	assert.Equal(t, ExtractionStrategyBinary, SelectExtractionProfile("utf8mb4_nopad_bin").Strategy)
+	assert.Equal(t, ExtractionStrategyOrderBy, SelectExtractionProfile("utf8mb4_unicode_520_nopad_ci").Strategy)
+	assert.Equal(t, ExtractionStrategyStrcmp, SelectExtractionProfile("latin1_swedish_nopad_ci").Strategy)
+	assert.Equal(t, ExtractionProfile{
+		Collation: "utf8mb3_german2_nopad_ci",
+		Strategy:  ExtractionStrategyDelta,
+		Base:      "utf8mb3_unicode_ci",
+	}, SelectExtractionProfile("utf8mb3_german2_nopad_ci"))
+
+	contents := CollationMetadataToGoFile(CollationMetadata{Name: "utf8mb4_nopad_bin", Charset: "utf8mb4", ID: 1070, Flavor: ServerFlavorMariaDB}, CodegenOptions{})
+	assert.Contains(t, contents, "\tUtf8mb4_nopad_bin_Flavor = \"mariadb\"\n")
+	contents = CollationMetadataToGoFile(CollationMetadata{Name: "utf8mb4_bin", Charset: "utf8mb4", ID: 46}, CodegenOptions{})
+	assert.Contains(t, contents, "\tUtf8mb4_bin_Flavor = \"mysql\"\n")
+}
+
+func TestManifestCheckFlavor(t *testing.T) {
+	manifest := &Manifest{}
+	mysql := ManifestEntry{Name: "utf8mb4_bin", Kind: ManifestKindCollation, Metadata: &CollationMetadata{Name: "utf8mb4_bin", Flavor: ServerFlavorMySQL}}
+	mariadb := ManifestEntry{Name: "utf8mb4_bin", Kind: ManifestKindCollation, Metadata: &CollationMetadata{Name: "utf8mb4_bin", Flavor: ServerFlavorMariaDB}}
+	assert.NoError(t, manifest.CheckFlavor(mariadb))
+	manifest.Set(mysql)
+	assert.NoError(t, manifest.CheckFlavor(mysql))
+	assert.Error(t, manifest.CheckFlavor(mariadb))
+	// Entries without metadata were extracted from MySQL
+	manifest.Set(ManifestEntry{Name: "utf8mb4_bin", Kind: ManifestKindCollation})
+	assert.NoError(t, manifest.CheckFlavor(mysql))
+	assert.Error(t, manifest.CheckFlavor(mariadb))
+	// Collations of other names, and character sets, may be from either flavor
+	assert.NoError(t, manifest.CheckFlavor(ManifestEntry{Name: "utf8mb4_nopad_bin", Kind: ManifestKindCollation, Metadata: mariadb.Metadata}))
+	assert.NoError(t, manifest.CheckFlavor(ManifestEntry{Name: "utf8mb4_bin", Kind: ManifestKindCharset}))
+}
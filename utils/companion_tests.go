@@ -0,0 +1,124 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CharacterSetSample is a codepoint of a character set along with the server's conversions of it, which a companion
+// test compares against the generated RangeMap.
+type CharacterSetSample struct {
+	// Encoded is the codepoint in the character set's encoding.
+	Encoded []byte
+	// Decoded is the server's conversion of the codepoint to UTF8.
+	Decoded []byte
+	// Reencoded is the server's conversion of Decoded back to the character set, which only differs from Encoded for
+	// lossy codepoints.
+	Reencoded []byte
+}
+
+// CollationSample is a pair of runes along with the server's comparison of them, which a companion test compares
+// against the generated weights.
+type CollationSample struct {
+	Left  rune
+	Right rune
+	// Comparison is -1 when the left rune sorts first, 1 when the right rune sorts first, and 0 when they're equal.
+	Comparison int
+}
+
+// CharacterSetTestToGoFile returns a test file for the Go file that RangeMapToGoFile generated for the same character
+// set. The test asserts that the generated RangeMap converts each sample the same way as the server did during the
+// extraction.
+func CharacterSetTestToGoFile(name string, samples []CharacterSetSample, options CodegenOptions) string {
+	titleName, lowerName := options.names(name)
+	sb := strings.Builder{}
+	sb.WriteString(options.fileHeader())
+	sb.WriteString(fmt.Sprintf(`
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Test%[1]s_Samples verifies the %[2]s character set against conversions that were sampled from the server
+// during the extraction.
+func Test%[1]s_Samples(t *testing.T) {
+	for _, sample := range []struct {
+		encoded   []byte
+		decoded   []byte
+		reencoded []byte
+	}{
+`, titleName, "`"+lowerName+"`"))
+	for _, sample := range samples {
+		sb.WriteString(fmt.Sprintf("\t\t{%s, %s, %s},\n", bytesToGoLiteral(sample.Encoded), bytesToGoLiteral(sample.Decoded), bytesToGoLiteral(sample.Reencoded)))
+	}
+	sb.WriteString(fmt.Sprintf(`	} {
+		if decoded, ok := %[1]s.Decode(sample.encoded); !ok || !bytes.Equal(decoded, sample.decoded) {
+			t.Errorf("0x%%X decoded to 0x%%X rather than 0x%%X", sample.encoded, decoded, sample.decoded)
+		}
+		if reencoded, ok := %[1]s.Encode(sample.decoded); !ok || !bytes.Equal(reencoded, sample.reencoded) {
+			t.Errorf("0x%%X encoded to 0x%%X rather than 0x%%X", sample.decoded, reencoded, sample.reencoded)
+		}
+	}
+}
+`, titleName))
+	return sb.String()
+}
+
+// RuneComparatorTestToGoFile returns a test file for the Go file that RuneComparatorToGoFile generated for the same
+// collation. The test asserts that the generated comparison function orders each sample the same way as the server did
+// during the extraction.
+func RuneComparatorTestToGoFile(name string, samples []CollationSample, options CodegenOptions) string {
+	titleName, lowerName := options.names(name)
+	sb := strings.Builder{}
+	sb.WriteString(options.fileHeader())
+	sb.WriteString(fmt.Sprintf(`
+
+import (
+	"testing"
+)
+
+// Test%[1]s_Samples verifies the %[2]s collation against comparisons that were sampled from the server
+// during the extraction.
+func Test%[1]s_Samples(t *testing.T) {
+	for _, sample := range []struct {
+		left       rune
+		right      rune
+		comparison int
+	}{
+`, titleName, "`"+lowerName+"`"))
+	for _, sample := range samples {
+		sb.WriteString(fmt.Sprintf("\t\t{%d, %d, %d},\n", sample.Left, sample.Right, sample.Comparison))
+	}
+	sb.WriteString(fmt.Sprintf(`	} {
+		if comparison := %[1]s_Compare(string(sample.left), string(sample.right)); comparison != sample.comparison {
+			t.Errorf("comparing %%q and %%q returned %%d rather than %%d", sample.left, sample.right, comparison, sample.comparison)
+		}
+	}
+}
+`, titleName))
+	return sb.String()
+}
+
+// bytesToGoLiteral returns the given bytes as a Go byte slice literal.
+func bytesToGoLiteral(data []byte) string {
+	values := make([]string, len(data))
+	for i, b := range data {
+		values[i] = fmt.Sprintf("0x%02X", b)
+	}
+	return "[]byte{" + strings.Join(values, ", ") + "}"
+}
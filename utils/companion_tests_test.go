@@ -0,0 +1,44 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompanionTestsToGoFile(t *testing.T) {
+	contents := CharacterSetTestToGoFile("euc", []CharacterSetSample{
+		{Encoded: []byte{0xA1, 0xA9}, Decoded: []byte("〈"), Reencoded: []byte{0xA1, 0xA9}},
+	}, CodegenOptions{})
+	file, err := parser.ParseFile(token.NewFileSet(), "", contents, 0)
+	require.NoError(t, err)
+	assert.NotNil(t, file.Scope.Lookup("TestEuc_Samples"))
+	assert.Contains(t, contents, "{[]byte{0xA1, 0xA9}, []byte{0xE3, 0x80, 0x88}, []byte{0xA1, 0xA9}},")
+
+	contents = RuneComparatorTestToGoFile("utf16_unicode_ci", []CollationSample{
+		{Left: 'a', Right: 'B', Comparison: -1},
+		{Left: 'b', Right: 'B', Comparison: 0},
+	}, CodegenOptions{})
+	file, err = parser.ParseFile(token.NewFileSet(), "", contents, 0)
+	require.NoError(t, err)
+	assert.NotNil(t, file.Scope.Lookup("TestUtf16_unicode_ci_Samples"))
+	assert.Contains(t, contents, "Utf16_unicode_ci_Compare(string(sample.left), string(sample.right))")
+	assert.Contains(t, contents, "{98, 66, 0},")
+}
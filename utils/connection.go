@@ -15,7 +15,10 @@
 package utils
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
+	"time"
 
 	"github.com/gocraft/dbr/v2"
 
@@ -25,32 +28,220 @@ import (
 // Connection represents a MySQL or Dolt connection.
 type Connection struct {
 	conn *dbr.Connection
+	// dsn is kept so that the connection may be replaced after a transient error
+	dsn     string
+	builder *QueryBuilder
+	version string
+	// cache is used by Query and QueryValues when it is set
+	cache *QueryCache
+	// queryTimeout limits the duration of each query when it is greater than zero
+	queryTimeout time.Duration
+	// retryPolicy controls the recovery from transient errors, with failures counting the consecutive failures and
+	// broken marking that the connection must be replaced before the next query
+	retryPolicy RetryPolicy
+	failures    int
+	broken      bool
+	// prepared sends queries as prepared statements, which are kept in statements by the text of each statement
+	prepared   bool
+	statements map[string]*sql.Stmt
+	// recording receives every query and its response when set, while replay answers every query in place of a server
+	recording *QueryRecording
+	replay    queryReplayer
+	// queries counts every query that was sent to the server, including each retry
+	queries int
 }
 
 // NewConnection returns a new Connection.
 func NewConnection(user string, password string, host string, port int) (*Connection, error) {
-	conn, err := dbr.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s:%d)/", user, password, host, port), nil)
+	return NewConnectionWithOptions(ConnectionOptions{User: user, Password: password, Host: host, Port: port})
+}
+
+// NewConnectionWithOptions returns a new Connection using the given options, which allow for TLS, unix sockets, and
+// data source names.
+func NewConnectionWithOptions(options ConnectionOptions) (*Connection, error) {
+	dsn, err := options.dsn()
 	if err != nil {
 		return nil, err
 	}
-	_, err = conn.Exec(`SET CHARACTER SET "utf8mb4";`)
+	conn, version, err := openConnection(dsn)
 	if err != nil {
 		return nil, err
 	}
-	_, err = conn.Exec(`SET collation_connection = "utf8mb4_0900_bin";`)
+	builder, err := NewQueryBuilder(version)
 	if err != nil {
+		conn.Close()
 		return nil, err
 	}
+	return &Connection{conn: conn, dsn: dsn, builder: builder, version: version}, nil
+}
+
+// openConnection opens a connection to the given data source name, applying the settings that every Connection starts
+// with. Returns the full version of the server.
+func openConnection(dsn string) (_ *dbr.Connection, _ string, err error) {
+	conn, err := dbr.Open("mysql", dsn, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() {
+		if err != nil {
+			conn.Close()
+		}
+	}()
+	// Session variables and temporary tables only apply to a single connection, so the pool is limited to one
+	conn.SetMaxOpenConns(1)
+	conn.SetMaxIdleConns(1)
+	_, err = conn.Exec(`SET CHARACTER SET "utf8mb4";`)
+	if err != nil {
+		return nil, "", err
+	}
+	var version string
+	if err = conn.QueryRow(`SELECT VERSION();`).Scan(&version); err != nil {
+		return nil, "", err
+	}
+	builder, err := NewQueryBuilder(version)
+	if err != nil {
+		return nil, "", err
+	}
+	_, err = conn.Exec(fmt.Sprintf(`SET collation_connection = "%s";`, builder.BinaryCollation()))
+	if err != nil {
+		return nil, "", err
+	}
 	_, err = conn.Exec(`SET character_set_results = binary;`)
+	if err != nil {
+		return nil, "", err
+	}
+	return conn, version, nil
+}
+
+// queryReplayer answers the queries of a Connection in place of a server, such as a QueryRecording or a FakeServer. The
+// kind is one of the kinds of query within a recording.
+type queryReplayer interface {
+	replay(kind string, query string) (recordedResult, error)
+}
+
+// NewReplayConnection returns a Connection that answers every query from the given QueryRecording rather than from a
+// server, so that a recorded extraction may be rerun offline. Queries that were not recorded return
+// ErrQueryNotRecorded.
+func NewReplayConnection(recording *QueryRecording) (*Connection, error) {
+	builder, err := NewQueryBuilder(recording.Version())
+	if err != nil {
+		return nil, err
+	}
+	return &Connection{builder: builder, version: recording.Version(), replay: recording}, nil
+}
+
+// Builder returns the QueryBuilder for the connected server's version.
+func (conn *Connection) Builder() *QueryBuilder {
+	return conn.builder
+}
+
+// Version returns the full version of the connected server, including any suffixes.
+func (conn *Connection) Version() string {
+	return conn.version
+}
+
+// EnableQueryCache opens the QueryCache of the connected server's version within the given directory. Query and
+// QueryValues return the cached results of queries that have been seen before, and add the results of new queries to
+// the cache. All other queries are not cached, as they may depend on the state of the session.
+func (conn *Connection) EnableQueryCache(dir string) (*QueryCache, error) {
+	cache, err := OpenQueryCache(dir, conn.version)
+	if err != nil {
+		return nil, err
+	}
+	conn.cache = cache
+	return cache, nil
+}
+
+// RecordQueries adds every following query and its response to the QueryRecording at the given path, which is created
+// when it does not exist. The recording is written to disk periodically and when the connection is closed.
+func (conn *Connection) RecordQueries(path string) (*QueryRecording, error) {
+	recording, err := OpenQueryRecording(path, conn.version)
 	if err != nil {
 		return nil, err
 	}
-	return &Connection{conn}, nil
+	conn.recording = recording
+	return recording, nil
+}
+
+// useQueryRecording sets the QueryRecording of the connection, which may be shared with other connections to the same
+// server.
+func (conn *Connection) useQueryRecording(recording *QueryRecording) {
+	conn.recording = recording
+}
+
+// SetQueryTimeout limits the duration of every following query, so that a server that stops responding fails the query
+// rather than stalling the extraction. A timeout of zero removes the limit.
+func (conn *Connection) SetQueryTimeout(timeout time.Duration) {
+	conn.queryTimeout = timeout
+}
+
+// queryContext returns the context of a single query, which applies the query timeout to the given context. The
+// context is checked before the query is issued, so that cancellation is observed even when the results are cached.
+func (conn *Connection) queryContext(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	if conn.queryTimeout > 0 {
+		ctx, cancel := context.WithTimeout(ctx, conn.queryTimeout)
+		return ctx, cancel, nil
+	}
+	return ctx, func() {}, nil
+}
+
+// useQueryCache sets the QueryCache of the connection, which may be shared with other connections to the same server.
+func (conn *Connection) useQueryCache(cache *QueryCache) {
+	conn.cache = cache
 }
 
 // Query is used to retrieve the value of a query that returns a single row and a single value.
-func (conn *Connection) Query(query string) (_ []byte, err error) {
-	results, err := conn.conn.Query(query)
+func (conn *Connection) Query(query string) ([]byte, error) {
+	return conn.QueryContext(context.Background(), query)
+}
+
+// QueryContext is the same as Query, but the query is cancelled when the context is done.
+func (conn *Connection) QueryContext(ctx context.Context, query string) (out []byte, err error) {
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	if conn.replay != nil {
+		result, err := conn.replay.replay(recordedValue, query)
+		if err != nil || result.Null {
+			return nil, err
+		}
+		return result.Rows[0][0], nil
+	}
+	if conn.cache != nil {
+		if results, ok := conn.cache.Get(query); ok && len(results) == 1 {
+			return results[0], conn.recordValue(query, results[0])
+		}
+	}
+	err = conn.retry(ctx, alwaysReplay, func(ctx context.Context) error {
+		out, err = conn.query(ctx, query)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = conn.recordValue(query, out); err != nil {
+		return nil, err
+	}
+	if conn.cache == nil {
+		return out, nil
+	}
+	return out, conn.cache.Put(query, [][]byte{out})
+}
+
+// recordValue adds the value of the given query to the QueryRecording when queries are being recorded.
+func (conn *Connection) recordValue(query string, value []byte) error {
+	if conn.recording == nil {
+		return nil
+	}
+	return conn.recording.record(recordedValue, query, [][][]byte{{value}}, value == nil)
+}
+
+// query implements QueryContext without the cache.
+func (conn *Connection) query(ctx context.Context, query string) (_ []byte, err error) {
+	results, err := conn.rows(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -79,7 +270,245 @@ func (conn *Connection) Query(query string) (_ []byte, err error) {
 	return out, nil
 }
 
-// Close should be called when the connection is no longer needed.
+// QueryValues is used to retrieve every value of a query that returns a single row, which allows multiple expressions to
+// be computed using a single query.
+func (conn *Connection) QueryValues(query string) ([][]byte, error) {
+	return conn.QueryValuesContext(context.Background(), query)
+}
+
+// QueryValuesContext is the same as QueryValues, but the query is cancelled when the context is done.
+func (conn *Connection) QueryValuesContext(ctx context.Context, query string) ([][]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if conn.cache != nil {
+		if results, ok := conn.cache.Get(query); ok {
+			// Replays read the values as rows, as they are not cached
+			if conn.recording != nil {
+				return results, conn.recording.record(recordedRows, query, [][][]byte{results}, false)
+			}
+			return results, nil
+		}
+	}
+	var values [][]byte
+	rowCount := 0
+	err := conn.QueryRowsContext(ctx, query, func(row [][]byte) error {
+		rowCount++
+		values = make([][]byte, len(row))
+		for i, value := range row {
+			values[i] = append([]byte{}, value...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rowCount != 1 {
+		return nil, fmt.Errorf("the following query returned %d rows instead of 1: %s", rowCount, query)
+	}
+	if conn.cache != nil {
+		return values, conn.cache.Put(query, values)
+	}
+	return values, nil
+}
+
+// QueryRows is used to retrieve every row that a query returns. The callback is called with the values of each row,
+// which are only valid until the callback returns. Other queries must not be issued from within the callback, as the
+// connection is busy until every row has been read. A query that fails after the callback has been called is not
+// replayed, as the callback would see the same rows again.
+func (conn *Connection) QueryRows(query string, callback func(values [][]byte) error) error {
+	return conn.QueryRowsContext(context.Background(), query, callback)
+}
+
+// QueryRowsContext is the same as QueryRows, but the query is cancelled when the context is done.
+func (conn *Connection) QueryRowsContext(ctx context.Context, query string, callback func(values [][]byte) error) error {
+	if conn.replay != nil {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		result, err := conn.replay.replay(recordedRows, query)
+		if err != nil {
+			return err
+		}
+		for _, row := range result.Rows {
+			if err = callback(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	called := false
+	var rows [][][]byte
+	err := conn.retry(ctx, func() bool { return !called }, func(ctx context.Context) error {
+		return conn.queryRows(ctx, query, func(values [][]byte) error {
+			called = true
+			// The values are reused for every row, so each row is copied
+			if conn.recording != nil {
+				rows = append(rows, append([][]byte{}, values...))
+			}
+			return callback(values)
+		})
+	})
+	if err != nil || conn.recording == nil {
+		return err
+	}
+	return conn.recording.record(recordedRows, query, rows, false)
+}
+
+// queryRows implements QueryRowsContext for a single attempt.
+func (conn *Connection) queryRows(ctx context.Context, query string, callback func(values [][]byte) error) (err error) {
+	results, err := conn.rows(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		nerr := results.Close()
+		if err == nil {
+			err = nerr
+		}
+	}()
+	colNames, err := results.Columns()
+	if err != nil {
+		return err
+	}
+	values := make([][]byte, len(colNames))
+	row := make([]interface{}, len(colNames))
+	for i := range row {
+		row[i] = &values[i]
+	}
+	for results.Next() {
+		if err = results.Scan(row...); err != nil {
+			return err
+		}
+		if err = callback(values); err != nil {
+			return err
+		}
+	}
+	return results.Err()
+}
+
+// Exec is used to execute a query that does not return any rows, such as creating a table.
+func (conn *Connection) Exec(query string) error {
+	return conn.ExecContext(context.Background(), query)
+}
+
+// ExecContext is the same as Exec, but the query is cancelled when the context is done. Statements are never replayed,
+// as they may not be idempotent and may depend on session state (such as temporary tables) that a new connection lacks.
+func (conn *Connection) ExecContext(ctx context.Context, query string) error {
+	if conn.replay != nil {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		_, err := conn.replay.replay(recordedExec, query)
+		return err
+	}
+	err := conn.retry(ctx, nil, func(ctx context.Context) error {
+		_, err := conn.conn.ExecContext(ctx, query)
+		return err
+	})
+	if err != nil || conn.recording == nil {
+		return err
+	}
+	return conn.recording.record(recordedExec, query, nil, false)
+}
+
+// QueryColumn is used to retrieve the values of the given column from every row that a query returns. This allows the
+// output of statements with a fixed set of columns (such as SHOW COLLATION) to be read.
+func (conn *Connection) QueryColumn(query string, column string) ([][]byte, error) {
+	return conn.QueryColumnContext(context.Background(), query, column)
+}
+
+// QueryColumnContext is the same as QueryColumn, but the query is cancelled when the context is done.
+func (conn *Connection) QueryColumnContext(ctx context.Context, query string, column string) (values [][]byte, err error) {
+	// The column is part of the recorded query, as each column of the same query has its own values
+	recordedQuery := column + "\x00" + query
+	if conn.replay != nil {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		result, err := conn.replay.replay(recordedColumn, recordedQuery)
+		if err != nil {
+			return nil, err
+		}
+		return result.Rows[0], nil
+	}
+	err = conn.retry(ctx, alwaysReplay, func(ctx context.Context) error {
+		values, err = conn.queryColumn(ctx, query, column)
+		return err
+	})
+	if err != nil || conn.recording == nil {
+		return values, err
+	}
+	return values, conn.recording.record(recordedColumn, recordedQuery, [][][]byte{values}, false)
+}
+
+// queryColumn implements QueryColumnContext for a single attempt.
+func (conn *Connection) queryColumn(ctx context.Context, query string, column string) (_ [][]byte, err error) {
+	results, err := conn.rows(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		nerr := results.Close()
+		if err == nil {
+			err = nerr
+		}
+	}()
+	colNames, err := results.Columns()
+	if err != nil {
+		return nil, err
+	}
+	colIdx := -1
+	for i, colName := range colNames {
+		if colName == column {
+			colIdx = i
+		}
+	}
+	if colIdx == -1 {
+		return nil, fmt.Errorf("the following query does not return the column `%s`: %s", column, query)
+	}
+	var values [][]byte
+	for results.Next() {
+		row := make([]interface{}, len(colNames))
+		for i := range row {
+			row[i] = new([]byte)
+		}
+		if err = results.Scan(row...); err != nil {
+			return nil, err
+		}
+		values = append(values, *row[colIdx].(*[]byte))
+	}
+	if err = results.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// QueryCount returns the number of queries that were sent to the server, including each retry. Queries that were
+// answered by the QueryCache or a replayed QueryRecording are not counted.
+func (conn *Connection) QueryCount() int {
+	return conn.queries
+}
+
+// Close should be called when the connection is no longer needed. This also writes the QueryCache and QueryRecording
+// to disk.
 func (conn *Connection) Close() error {
-	return conn.conn.Close()
+	conn.closeStatements()
+	var firstErr error
+	if conn.cache != nil {
+		firstErr = conn.cache.Flush()
+	}
+	if conn.recording != nil {
+		if err := conn.recording.Flush(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	// Replay connections are not connected to a server
+	if conn.conn == nil {
+		return firstErr
+	}
+	if err := conn.conn.Close(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	return firstErr
 }
@@ -0,0 +1,129 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// ConnectionOptions are the settings of a Connection beyond a user, password, host, and port, such as those needed by
+// managed cloud servers that require encrypted connections.
+type ConnectionOptions struct {
+	User     string
+	Password string
+	Host     string
+	Port     int
+	// Socket is the path of a unix socket, which replaces the host and port when set.
+	Socket string
+	// TLS encrypts the connection, verifying the server's certificate against the system's roots (or the CA when
+	// given). TLS is implied by any of the other TLS settings.
+	TLS bool
+	// TLSCA is a PEM file of the certificate authorities that the server's certificate is verified against.
+	TLSCA string
+	// TLSCert and TLSKey are PEM files of a client certificate and its key, for servers that require one.
+	TLSCert string
+	TLSKey  string
+	// TLSSkipVerify accepts any certificate from the server, which should only be used for testing.
+	TLSSkipVerify bool
+	// TLSServerName is the name that the server's certificate is verified against. Defaults to the host.
+	TLSServerName string
+	// DSN is a data source name in the format of github.com/go-sql-driver/mysql, which is used as-is in place of every
+	// other setting, other than the TLS settings when DSN does not specify its own.
+	DSN string
+}
+
+// tlsEnabled returns whether any of the TLS settings are set.
+func (o ConnectionOptions) tlsEnabled() bool {
+	return o.TLS || o.TLSCA != "" || o.TLSCert != "" || o.TLSKey != "" || o.TLSSkipVerify || o.TLSServerName != ""
+}
+
+// dsn returns the data source name of the options, registering the TLS configuration with the driver when needed.
+func (o ConnectionOptions) dsn() (string, error) {
+	var config *mysql.Config
+	if o.DSN != "" {
+		var err error
+		if config, err = mysql.ParseDSN(o.DSN); err != nil {
+			return "", fmt.Errorf("invalid DSN: %w", err)
+		}
+		if config.TLSConfig != "" || !o.tlsEnabled() {
+			return o.DSN, nil
+		}
+	} else {
+		config = mysql.NewConfig()
+		config.User = o.User
+		config.Passwd = o.Password
+		if o.Socket != "" {
+			config.Net = "unix"
+			config.Addr = o.Socket
+		} else {
+			config.Net = "tcp"
+			config.Addr = net.JoinHostPort(o.Host, strconv.Itoa(o.Port))
+		}
+	}
+	if o.tlsEnabled() {
+		name, err := o.registerTLSConfig(config.Addr)
+		if err != nil {
+			return "", err
+		}
+		config.TLSConfig = name
+	}
+	return config.FormatDSN(), nil
+}
+
+// registerTLSConfig registers the TLS settings with the driver, returning the name that a DSN references them by. The
+// name is derived from the settings, so that identical settings are only registered once.
+func (o ConnectionOptions) registerTLSConfig(addr string) (string, error) {
+	serverName := o.TLSServerName
+	if serverName == "" {
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			serverName = host
+		}
+	}
+	tlsConfig := &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: o.TLSSkipVerify,
+	}
+	if o.TLSCA != "" {
+		pem, err := os.ReadFile(o.TLSCA)
+		if err != nil {
+			return "", err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return "", fmt.Errorf("no certificates were found within `%s`", o.TLSCA)
+		}
+	}
+	if o.TLSCert != "" || o.TLSKey != "" {
+		cert, err := tls.LoadX509KeyPair(o.TLSCert, o.TLSKey)
+		if err != nil {
+			return "", err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%s\x00%t\x00%s", o.TLSCA, o.TLSCert, o.TLSKey, o.TLSSkipVerify, serverName)))
+	name := fmt.Sprintf("collation-extractor-%x", hash[:8])
+	if err := mysql.RegisterTLSConfig(name, tlsConfig); err != nil {
+		return "", err
+	}
+	return name, nil
+}
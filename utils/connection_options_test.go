@@ -0,0 +1,66 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectionOptionsDSN(t *testing.T) {
+	dsn, err := ConnectionOptions{User: "root", Password: "p@ss:word", Host: "localhost", Port: 3306}.dsn()
+	require.NoError(t, err)
+	config, err := mysql.ParseDSN(dsn)
+	require.NoError(t, err)
+	assert.Equal(t, "root", config.User)
+	assert.Equal(t, "p@ss:word", config.Passwd)
+	assert.Equal(t, "tcp", config.Net)
+	assert.Equal(t, "localhost:3306", config.Addr)
+	assert.Empty(t, config.TLSConfig)
+
+	// IPv6 hosts are bracketed, and sockets replace the host and port
+	dsn, err = ConnectionOptions{User: "root", Host: "::1", Port: 3306}.dsn()
+	require.NoError(t, err)
+	assert.Contains(t, dsn, "tcp([::1]:3306)")
+	dsn, err = ConnectionOptions{User: "root", Socket: "/tmp/mysql.sock", Host: "localhost", Port: 3306}.dsn()
+	require.NoError(t, err)
+	assert.Contains(t, dsn, "unix(/tmp/mysql.sock)")
+
+	// Any TLS setting registers a configuration, which the DSN references by name
+	dsn, err = ConnectionOptions{User: "root", Host: "db.example.com", Port: 3306, TLSSkipVerify: true}.dsn()
+	require.NoError(t, err)
+	config, err = mysql.ParseDSN(dsn)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(config.TLSConfig, "collation-extractor-"))
+	_, err = ConnectionOptions{User: "root", Host: "db.example.com", Port: 3306, TLSCA: "/nonexistent/ca.pem"}.dsn()
+	assert.Error(t, err)
+
+	// A DSN is passed through unless TLS is requested and the DSN does not configure it
+	dsn, err = ConnectionOptions{DSN: "user:password@tcp(db.example.com:3306)/?tls=skip-verify", TLS: true}.dsn()
+	require.NoError(t, err)
+	assert.Equal(t, "user:password@tcp(db.example.com:3306)/?tls=skip-verify", dsn)
+	dsn, err = ConnectionOptions{DSN: "user:password@tcp(db.example.com:3306)/", TLSSkipVerify: true}.dsn()
+	require.NoError(t, err)
+	config, err = mysql.ParseDSN(dsn)
+	require.NoError(t, err)
+	assert.Equal(t, "db.example.com:3306", config.Addr)
+	assert.NotEmpty(t, config.TLSConfig)
+	_, err = ConnectionOptions{DSN: "not a dsn"}.dsn()
+	assert.Error(t, err)
+}
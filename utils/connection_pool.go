@@ -0,0 +1,187 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// ConnectionPool contains multiple connections to the same server. A Connection may only be used by a single goroutine
+// at a time, so a pool allows queries to be issued in parallel by giving each goroutine its own Connection.
+type ConnectionPool struct {
+	conns []*Connection
+	// closers are closed after the connections, such as the DockerServer that the pool is connected to
+	closers []io.Closer
+}
+
+// NewConnectionPool returns a new ConnectionPool containing the given number of connections.
+func NewConnectionPool(user string, password string, host string, port int, size int) (*ConnectionPool, error) {
+	return NewConnectionPoolWithOptions(ConnectionOptions{User: user, Password: password, Host: host, Port: port}, size)
+}
+
+// NewConnectionPoolWithOptions returns a new ConnectionPool containing the given number of connections, which are each
+// created using the given options.
+func NewConnectionPoolWithOptions(options ConnectionOptions, size int) (*ConnectionPool, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("a connection pool requires at least one connection, but %d were requested", size)
+	}
+	pool := &ConnectionPool{}
+	for i := 0; i < size; i++ {
+		conn, err := NewConnectionWithOptions(options)
+		if err != nil {
+			pool.Close()
+			return nil, err
+		}
+		pool.conns = append(pool.conns, conn)
+	}
+	return pool, nil
+}
+
+// NewReplayConnectionPool returns a new ConnectionPool containing the given number of connections, which each answer
+// every query from the QueryRecording at the given path rather than from a server.
+func NewReplayConnectionPool(path string, size int) (*ConnectionPool, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("a connection pool requires at least one connection, but %d were requested", size)
+	}
+	recording, err := LoadQueryRecording(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := &ConnectionPool{}
+	for i := 0; i < size; i++ {
+		conn, err := NewReplayConnection(recording)
+		if err != nil {
+			return nil, err
+		}
+		pool.conns = append(pool.conns, conn)
+	}
+	return pool, nil
+}
+
+// NewFakeConnectionPool returns a new ConnectionPool containing the given number of connections, which each answer
+// every query from the given FakeServer (see NewFakeConnection).
+func NewFakeConnectionPool(server *FakeServer, size int) (*ConnectionPool, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("a connection pool requires at least one connection, but %d were requested", size)
+	}
+	pool := &ConnectionPool{}
+	for i := 0; i < size; i++ {
+		pool.conns = append(pool.conns, NewFakeConnection(server))
+	}
+	return pool, nil
+}
+
+// Size returns the number of connections within the pool.
+func (pool *ConnectionPool) Size() int {
+	return len(pool.conns)
+}
+
+// Connection returns the connection at the given index. The first connection may be used for any sequential work that
+// occurs alongside the parallel work.
+func (pool *ConnectionPool) Connection(idx int) *Connection {
+	return pool.conns[idx]
+}
+
+// Split divides the pool's connections into pools of the given size, so that independent extractions may run in
+// parallel with each of them using its own pool. The connections remain owned by this pool, so only this pool should be
+// closed. Any remaining connections that do not fill a pool are not returned.
+func (pool *ConnectionPool) Split(size int) []*ConnectionPool {
+	var pools []*ConnectionPool
+	for i := 0; size > 0 && i+size <= len(pool.conns); i += size {
+		pools = append(pools, &ConnectionPool{conns: pool.conns[i : i+size]})
+	}
+	return pools
+}
+
+// SetQueryTimeout sets the query timeout of every connection within the pool.
+func (pool *ConnectionPool) SetQueryTimeout(timeout time.Duration) {
+	for _, conn := range pool.conns {
+		conn.SetQueryTimeout(timeout)
+	}
+}
+
+// SetRetryPolicy sets the retry policy of every connection within the pool. Each connection counts its own consecutive
+// failures.
+func (pool *ConnectionPool) SetRetryPolicy(policy RetryPolicy) {
+	for _, conn := range pool.conns {
+		conn.SetRetryPolicy(policy)
+	}
+}
+
+// SetPreparedStatements sets whether every connection within the pool sends queries as prepared statements.
+func (pool *ConnectionPool) SetPreparedStatements(enabled bool) {
+	for _, conn := range pool.conns {
+		conn.SetPreparedStatements(enabled)
+	}
+}
+
+// QueryCount returns the number of queries that every connection within the pool sent to the server.
+func (pool *ConnectionPool) QueryCount() int {
+	count := 0
+	for _, conn := range pool.conns {
+		count += conn.QueryCount()
+	}
+	return count
+}
+
+// EnableQueryCache opens the QueryCache of the server's version within the given directory, which is shared by every
+// connection within the pool.
+func (pool *ConnectionPool) EnableQueryCache(dir string) (*QueryCache, error) {
+	cache, err := pool.conns[0].EnableQueryCache(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, conn := range pool.conns[1:] {
+		conn.useQueryCache(cache)
+	}
+	return cache, nil
+}
+
+// AddCloser adds a closer that is closed along with the pool, after every connection has been closed. This allows the
+// pool to own the resources that it depends on, such as the DockerServer that it is connected to.
+func (pool *ConnectionPool) AddCloser(closer io.Closer) {
+	pool.closers = append(pool.closers, closer)
+}
+
+// RecordQueries adds every following query of every connection within the pool to the QueryRecording at the given path,
+// which is shared by every connection.
+func (pool *ConnectionPool) RecordQueries(path string) (*QueryRecording, error) {
+	recording, err := pool.conns[0].RecordQueries(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, conn := range pool.conns[1:] {
+		conn.useQueryRecording(recording)
+	}
+	return recording, nil
+}
+
+// Close closes every connection within the pool, followed by every closer, returning the first error.
+func (pool *ConnectionPool) Close() error {
+	var firstErr error
+	for _, conn := range pool.conns {
+		if err := conn.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for _, closer := range pool.closers {
+		if err := closer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,67 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectionPool(t *testing.T) {
+	server, err := NewFakeServer("8.0.31")
+	require.NoError(t, err)
+	_, err = NewFakeConnectionPool(server, 0)
+	assert.Error(t, err)
+	pool, err := NewFakeConnectionPool(server, 5)
+	require.NoError(t, err)
+	defer pool.Close()
+	assert.Equal(t, 5, pool.Size())
+
+	// Every connection answers from the same server
+	ctx := context.Background()
+	qb := pool.Connection(0).Builder()
+	for i := 0; i < pool.Size(); i++ {
+		conn := pool.Connection(i)
+		value, err := conn.QueryContext(ctx, qb.Select(qb.AsBinary(qb.InCharset([]byte("a"), "ascii"))))
+		require.NoError(t, err)
+		assert.Equal(t, []byte("a"), value)
+		values, err := conn.QueryValuesContext(ctx, qb.Select(qb.Call("HEX", qb.WeightString(qb.InCollation([]byte("a"), "ascii", "ascii_general_ci"), 0)), qb.AsBinary(qb.InCharset([]byte("b"), "ascii"))))
+		require.NoError(t, err)
+		assert.Equal(t, [][]byte{[]byte("0041"), []byte("b")}, values)
+		charsets, err := conn.QueryColumnContext(ctx, "SHOW CHARACTER SET;", "Charset")
+		require.NoError(t, err)
+		assert.Contains(t, charsets, []byte("ascii"))
+		assert.Error(t, conn.ExecContext(ctx, "CREATE TABLE t (c int);"))
+	}
+	// The connections refuse to query once the context is done
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = pool.Connection(1).QueryValuesContext(cancelled, qb.Select(qb.AsBinary(qb.InCharset([]byte("a"), "ascii"))))
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// Split pools share the connections of the pool, and drop any that do not fill a pool
+	pools := pool.Split(2)
+	require.Len(t, pools, 2)
+	assert.Equal(t, 2, pools[0].Size())
+	assert.Same(t, pool.Connection(0), pools[0].Connection(0))
+	assert.Same(t, pool.Connection(3), pools[1].Connection(1))
+	assert.Len(t, pool.Split(6), 0)
+	assert.Len(t, pool.Split(0), 0)
+	// Queries answered by the fake server are not sent to a server
+	assert.Equal(t, 0, pool.QueryCount())
+}
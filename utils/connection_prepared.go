@@ -0,0 +1,71 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// maxPreparedStatements is the number of prepared statements that each Connection keeps. The server limits the number
+// of prepared statements across every connection (max_prepared_stmt_count), so every statement is closed once the limit
+// is reached. The hot extraction queries only have a handful of shapes, so the limit is rarely reached.
+const maxPreparedStatements = 256
+
+// SetPreparedStatements sets whether the following queries are sent as prepared statements, which are parsed by the
+// server once for each shape of query rather than once for every query. Only queries that select converted literals
+// (see QueryBuilder.Parameterize) are prepared, while all other queries are sent as text. The QueryCache is unaffected,
+// as its results remain keyed by the text of each query.
+func (conn *Connection) SetPreparedStatements(enabled bool) {
+	conn.prepared = enabled
+	if !enabled {
+		conn.closeStatements()
+	}
+}
+
+// rows issues the given query, using a prepared statement when they are enabled and the query may be parameterized.
+func (conn *Connection) rows(ctx context.Context, query string) (*sql.Rows, error) {
+	if !conn.prepared || !strings.HasPrefix(query, "SELECT ") {
+		return conn.conn.QueryContext(ctx, query)
+	}
+	statement, params := conn.builder.Parameterize(query)
+	if len(params) == 0 {
+		return conn.conn.QueryContext(ctx, query)
+	}
+	stmt, ok := conn.statements[statement]
+	if !ok {
+		if len(conn.statements) >= maxPreparedStatements {
+			conn.closeStatements()
+		}
+		var err error
+		if stmt, err = conn.conn.PrepareContext(ctx, statement); err != nil {
+			return nil, err
+		}
+		if conn.statements == nil {
+			conn.statements = make(map[string]*sql.Stmt)
+		}
+		conn.statements[statement] = stmt
+	}
+	return stmt.QueryContext(ctx, params...)
+}
+
+// closeStatements closes every prepared statement, which must be done before the connection is closed or replaced.
+func (conn *Connection) closeStatements() {
+	for _, stmt := range conn.statements {
+		_ = stmt.Close()
+	}
+	conn.statements = nil
+}
@@ -0,0 +1,177 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// RetryPolicy controls how a Connection recovers from transient errors, such as the server dropping the connection
+// partway through an extraction that runs for hours. A failed query is replayed on a new connection after waiting for
+// the backoff, which doubles after each consecutive failure.
+type RetryPolicy struct {
+	// MaxConsecutiveFailures is the number of failures in a row (across all queries) that are retried before the error
+	// is returned. A successful query resets the count. Zero disables retries.
+	MaxConsecutiveFailures int
+	// InitialBackoff is the wait before the first retry, and MaxBackoff caps the wait of every later retry.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Logf is called for every retry when it is set.
+	Logf func(format string, args ...interface{})
+}
+
+// DefaultRetryPolicy returns the RetryPolicy that the command line tool uses by default, which rides out a server
+// restart of up to a few minutes.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxConsecutiveFailures: 8,
+		InitialBackoff:         time.Second,
+		MaxBackoff:             time.Minute,
+	}
+}
+
+// backoff returns the wait before the retry that follows the given number of consecutive failures, which starts at 1.
+func (policy RetryPolicy) backoff(failures int) time.Duration {
+	wait := policy.InitialBackoff
+	for i := 1; i < failures && wait < policy.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if policy.MaxBackoff > 0 && wait > policy.MaxBackoff {
+		wait = policy.MaxBackoff
+	}
+	return wait
+}
+
+// IsTransientError returns whether the error may succeed when the query is replayed on a new connection, such as a
+// dropped connection, a network error, or a query that exceeded its timeout. Errors in the query itself (such as a
+// syntax error) are never transient.
+func IsTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) || errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case 1040, // ER_CON_COUNT_ERROR: too many connections
+			1053, // ER_SERVER_SHUTDOWN: the server is shutting down
+			1205, // ER_LOCK_WAIT_TIMEOUT
+			1213, // ER_LOCK_DEADLOCK
+			1927: // ER_CONNECTION_KILLED (MariaDB)
+			return true
+		}
+	}
+	return false
+}
+
+// SetRetryPolicy sets how the following queries recover from transient errors. The zero RetryPolicy disables retries.
+func (conn *Connection) SetRetryPolicy(policy RetryPolicy) {
+	conn.retryPolicy = policy
+}
+
+// retry calls the given function until it succeeds, returns an error that is not transient, or exceeds the maximum
+// number of consecutive failures. Each call receives a context with the query timeout applied. The connection is
+// replaced before the function is called again, so the function must be safe to replay, which is true of any query
+// that only reads. When canReplay returns false (or is nil), a transient error is returned immediately, but the
+// connection is still replaced before the next query.
+func (conn *Connection) retry(ctx context.Context, canReplay func() bool, fn func(ctx context.Context) error) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := conn.reconnectIfBroken()
+		if err == nil {
+			var queryCtx context.Context
+			var cancel context.CancelFunc
+			if queryCtx, cancel, err = conn.queryContext(ctx); err != nil {
+				return err
+			}
+			conn.queries++
+			err = fn(queryCtx)
+			cancel()
+			if err == nil {
+				conn.failures = 0
+				return nil
+			}
+			// The caller's own context being done is never transient, while the query timeout expiring may be
+			if ctx.Err() != nil || !IsTransientError(err) {
+				return err
+			}
+			conn.broken = true
+		} else if !IsTransientError(err) {
+			// Such as the credentials being rejected, or the server's version changing
+			return err
+		}
+		if canReplay == nil || !canReplay() || conn.retryPolicy.MaxConsecutiveFailures <= 0 {
+			return err
+		}
+		conn.failures++
+		if conn.failures > conn.retryPolicy.MaxConsecutiveFailures {
+			return fmt.Errorf("giving up after %d consecutive failures: %w", conn.failures, err)
+		}
+		wait := conn.retryPolicy.backoff(conn.failures)
+		if conn.retryPolicy.Logf != nil {
+			conn.retryPolicy.Logf("retrying in %s after failure %d of %d: %s", wait, conn.failures, conn.retryPolicy.MaxConsecutiveFailures, err.Error())
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// alwaysReplay is given to retry for queries that are always safe to replay.
+func alwaysReplay() bool {
+	return true
+}
+
+// reconnectIfBroken replaces the connection after a transient error. Session state (such as temporary tables) does not
+// survive the new connection, yet the settings that every Connection starts with are applied again.
+func (conn *Connection) reconnectIfBroken() error {
+	if !conn.broken {
+		return nil
+	}
+	newConn, version, err := openConnection(conn.dsn)
+	if err != nil {
+		return err
+	}
+	if version != conn.version {
+		newConn.Close()
+		return fmt.Errorf("the server was `%s` but is now `%s` after reconnecting", conn.version, version)
+	}
+	conn.closeStatements()
+	conn.conn.Close()
+	conn.conn = newConn
+	conn.broken = false
+	return nil
+}
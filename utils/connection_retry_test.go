@@ -0,0 +1,84 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxConsecutiveFailures: 10, InitialBackoff: time.Second, MaxBackoff: 10 * time.Second}
+	assert.Equal(t, time.Second, policy.backoff(1))
+	assert.Equal(t, 2*time.Second, policy.backoff(2))
+	assert.Equal(t, 8*time.Second, policy.backoff(4))
+	assert.Equal(t, 10*time.Second, policy.backoff(5))
+	assert.Equal(t, 10*time.Second, policy.backoff(100))
+}
+
+func TestIsTransientError(t *testing.T) {
+	assert.False(t, IsTransientError(nil))
+	assert.True(t, IsTransientError(driver.ErrBadConn))
+	assert.True(t, IsTransientError(fmt.Errorf("wrapped: %w", mysql.ErrInvalidConn)))
+	assert.True(t, IsTransientError(context.DeadlineExceeded))
+	assert.True(t, IsTransientError(&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}))
+	assert.True(t, IsTransientError(&mysql.MySQLError{Number: 1053, Message: "Server shutdown in progress"}))
+	assert.False(t, IsTransientError(&mysql.MySQLError{Number: 1064, Message: "You have an error in your SQL syntax"}))
+	assert.False(t, IsTransientError(context.Canceled))
+	assert.False(t, IsTransientError(errors.New("no rows returned from query")))
+}
+
+func TestConnectionRetry(t *testing.T) {
+	conn := &Connection{retryPolicy: RetryPolicy{MaxConsecutiveFailures: 3, InitialBackoff: time.Millisecond}}
+
+	// Errors that are not transient are returned without replaying the query or replacing the connection
+	calls := 0
+	err := conn.retry(context.Background(), alwaysReplay, func(ctx context.Context) error {
+		calls++
+		return errors.New("syntax error")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+	assert.False(t, conn.broken)
+
+	// Statements that may not be replayed return transient errors immediately, while the connection is still replaced
+	// before the next query
+	calls = 0
+	err = conn.retry(context.Background(), nil, func(ctx context.Context) error {
+		calls++
+		return driver.ErrBadConn
+	})
+	require.ErrorIs(t, err, driver.ErrBadConn)
+	assert.Equal(t, 1, calls)
+	assert.True(t, conn.broken)
+	assert.Equal(t, 0, conn.failures)
+
+	// The caller's context is checked before the connection is replaced
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = conn.retry(ctx, alwaysReplay, func(ctx context.Context) error {
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+}
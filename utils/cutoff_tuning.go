@@ -0,0 +1,171 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"sort"
+)
+
+// CutoffTuning is the estimated cost of generating a collation with a pair of range cutoffs (see
+// RuneComparatorGoFileOptions).
+type CutoffTuning struct {
+	DynamicRangeCutoff int `json:"dynamic_range_cutoff"`
+	StaticRangeCutoff  int `json:"static_range_cutoff"`
+	// MapEntries is the number of entries within the weight map.
+	MapEntries int `json:"map_entries"`
+	// Ranges is the number of range comparisons within the weight function.
+	Ranges int `json:"ranges"`
+	// TableBytes is the approximate number of bytes that the weight map and range comparisons occupy.
+	TableBytes int `json:"table_bytes"`
+	// AverageComparisons is the average number of range comparisons that a lookup checks after the weight map.
+	AverageComparisons float64 `json:"average_comparisons"`
+}
+
+// cutoffTuningCandidates are the cutoffs that TuneCutoffs considers for both the dynamic and static ranges, which
+// include the defaults.
+var cutoffTuningCandidates = []int{8, 16, 32, 64, 100, 128, 256, 512, 1024, 4096}
+
+const (
+	// cutoffTuningComparisonBudget is the average number of range comparisons that a lookup may check, which is roughly
+	// the time of the map lookup that every lookup makes before checking the ranges.
+	cutoffTuningComparisonBudget = 8
+	// cutoffTuningMapEntryBytes is the approximate size of a map entry, being the key, the value, and the overhead.
+	cutoffTuningMapEntryBytes = 4 + 4 + 8
+	// cutoffTuningRangeBytes is the approximate size of the compiled code of a range comparison.
+	cutoffTuningRangeBytes = 24
+)
+
+// String returns the tuning as a single line.
+func (tuning CutoffTuning) String() string {
+	return fmt.Sprintf("dynamic cutoff %d, static cutoff %d: %d map entries, %d ranges, %d bytes, %.2f comparisons per lookup",
+		tuning.DynamicRangeCutoff, tuning.StaticRangeCutoff, tuning.MapEntries, tuning.Ranges, tuning.TableBytes,
+		tuning.AverageComparisons)
+}
+
+// TuneCutoffs estimates the cost of generating the RuneComparator with every pair of candidate cutoffs, and returns the
+// pair with the smallest table whose lookups are simulated to check no more range comparisons on average than a budget
+// of 8 (or than the default cutoffs, when they exceed the budget), along with every candidate sorted by size. The
+// simulated lookups are the given runes (such as the runes of a corpus), where each occurrence is a separate lookup, or
+// every rune with a weight once when empty. The weight map is checked first by every lookup, so a lookup of a map entry
+// checks no range comparisons, while a lookup of a range checks every comparison before its own. Runes without a weight
+// check every comparison.
+func TuneCutoffs(rc *RuneComparator, lookups []rune) (CutoffTuning, []CutoffTuning) {
+	var counts map[rune]int
+	if len(lookups) > 0 {
+		counts = make(map[rune]int)
+		for _, r := range lookups {
+			counts[r]++
+		}
+	}
+	// hits returns the number of lookups within the given bounds, removing them from the counts so that the remaining
+	// counts are the lookups without a weight
+	remaining := make(map[rune]int, len(counts))
+	hits := func(lower rune, upper rune) int {
+		if counts == nil {
+			return int(upper-lower) + 1
+		}
+		total := 0
+		for r := lower; r <= upper; r++ {
+			if count, ok := remaining[r]; ok {
+				total += count
+				delete(remaining, r)
+			}
+		}
+		return total
+	}
+
+	var candidates []CutoffTuning
+	for _, dynamicCutoff := range cutoffTuningCandidates {
+		for r, count := range counts {
+			remaining[r] = count
+		}
+		dynamicWeightRanges, staticWeightRanges := rc.weightRangesWithCutoff(dynamicCutoff)
+		dynamicHits := make([]int, len(dynamicWeightRanges))
+		for i, dynamic := range dynamicWeightRanges {
+			dynamicHits[i] = hits(dynamic.Lower, dynamic.Upper)
+		}
+		staticHits := make([]int, len(staticWeightRanges))
+		for i, static := range staticWeightRanges {
+			staticHits[i] = hits(static.Lower, static.Upper)
+		}
+		misses, total := 0, 0
+		for _, count := range remaining {
+			misses += count
+		}
+		if counts == nil {
+			total = rc.runeCount()
+		} else {
+			total = len(lookups)
+		}
+
+		for _, staticCutoff := range cutoffTuningCandidates {
+			tuning := CutoffTuning{DynamicRangeCutoff: dynamicCutoff, StaticRangeCutoff: staticCutoff}
+			comparisons := 0
+			for i := range dynamicWeightRanges {
+				tuning.Ranges++
+				comparisons += dynamicHits[i] * tuning.Ranges
+			}
+			for i, static := range staticWeightRanges {
+				if static.Upper-static.Lower >= rune(staticCutoff) {
+					tuning.Ranges++
+					comparisons += staticHits[i] * tuning.Ranges
+				} else {
+					tuning.MapEntries += int(static.Count())
+				}
+			}
+			comparisons += misses * tuning.Ranges
+			tuning.TableBytes = tuning.MapEntries*cutoffTuningMapEntryBytes + tuning.Ranges*cutoffTuningRangeBytes
+			if total > 0 {
+				tuning.AverageComparisons = float64(comparisons) / float64(total)
+			}
+			candidates = append(candidates, tuning)
+		}
+	}
+
+	var defaultTuning CutoffTuning
+	for _, candidate := range candidates {
+		if candidate.DynamicRangeCutoff == dynamicWeightRangeCutoff && candidate.StaticRangeCutoff == staticWeightRangeCutoff {
+			defaultTuning = candidate
+		}
+	}
+	budget := float64(cutoffTuningComparisonBudget)
+	if defaultTuning.AverageComparisons > budget {
+		budget = defaultTuning.AverageComparisons
+	}
+	best := defaultTuning
+	for _, candidate := range candidates {
+		if candidate.AverageComparisons > budget {
+			continue
+		}
+		if candidate.TableBytes < best.TableBytes ||
+			(candidate.TableBytes == best.TableBytes && candidate.AverageComparisons < best.AverageComparisons) {
+			best = candidate
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].TableBytes < candidates[j].TableBytes
+	})
+	return best, candidates
+}
+
+// runeCount returns the number of runes within the RuneComparator.
+func (rc *RuneComparator) runeCount() int {
+	count := 0
+	for _, row := range rc.rows() {
+		count += len(row)
+	}
+	return count
+}
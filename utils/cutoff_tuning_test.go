@@ -0,0 +1,94 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cutoffTestComparator returns a RuneComparator whose runes below 0x400 share a weight in groups of 64, followed by a
+// long run of sequential weights, and a few hundred runes in reverse order.
+func cutoffTestComparator() *RuneComparator {
+	rc := NewRuneComparator()
+	rc.SetComparator(func(l rune, r rune) int {
+		weight := func(r rune) rune {
+			switch {
+			case r < 0x400:
+				return r / 64
+			case r < 0x2000:
+				return r
+			default:
+				return 0x8000 - r
+			}
+		}
+		if lWeight, rWeight := weight(l), weight(r); lWeight < rWeight {
+			return -1
+		} else if lWeight > rWeight {
+			return 1
+		}
+		return 0
+	})
+	for r := rune(0); r < 0x2100; r++ {
+		rc.Insert(r)
+	}
+	return rc
+}
+
+func TestRuneComparatorCutoffs(t *testing.T) {
+	rc := cutoffTestComparator()
+	defaultContents := RuneComparatorToGoFile(rc, "utf8mb4_cutoff_ci", false)
+	tunedContents := RuneComparatorToGoFileWithOptions(rc, "utf8mb4_cutoff_ci", false, RuneComparatorGoFileOptions{
+		DynamicRangeCutoff: 0x2000,
+		StaticRangeCutoff:  32,
+	})
+	// The groups become range comparisons, while the sequential run becomes map entries
+	assert.Equal(t, 1, strings.Count(defaultContents, "else if r >= "))
+	assert.Equal(t, 16, strings.Count(tunedContents, "else if r >= "))
+	assert.NotContains(t, tunedContents, "return r+")
+
+	for _, contents := range []string{defaultContents, tunedContents} {
+		runeWeights, err := ParseRuneComparatorGoFile(contents)
+		require.NoError(t, err)
+		for r := rune(1); r < 0x2100; r++ {
+			require.Equal(t, rc.comparator(r-1, r) < 0, runeWeights.Weight(r-1) < runeWeights.Weight(r), "rune %d", r)
+		}
+	}
+}
+
+func TestTuneCutoffs(t *testing.T) {
+	rc := cutoffTestComparator()
+	best, candidates := TuneCutoffs(rc, nil)
+	require.Len(t, candidates, len(cutoffTuningCandidates)*len(cutoffTuningCandidates))
+	var defaultTuning CutoffTuning
+	for _, candidate := range candidates {
+		if candidate.DynamicRangeCutoff == 100 && candidate.StaticRangeCutoff == 100 {
+			defaultTuning = candidate
+		}
+	}
+	assert.Equal(t, 1, defaultTuning.Ranges)
+	assert.LessOrEqual(t, best.AverageComparisons, float64(cutoffTuningComparisonBudget))
+	assert.Less(t, best.TableBytes, defaultTuning.TableBytes)
+	assert.Contains(t, best.String(), "comparisons per lookup")
+
+	// Lookups of runes without a weight check every range comparison
+	_, candidates = TuneCutoffs(rc, []rune{0x10000, 0x10001})
+	for _, candidate := range candidates {
+		assert.Equal(t, float64(candidate.Ranges), candidate.AverageComparisons)
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DiffGeneratedFiles compares two generated files line by line, returning a description of every line that differs.
+// Returns an empty string if both files are identical. At most maxDifferences lines are described, as a difference in
+// the ordering of a large table would otherwise produce an enormous description.
+func DiffGeneratedFiles(left string, right string, maxDifferences int) string {
+	if left == right {
+		return ""
+	}
+	leftLines := strings.Split(left, "\n")
+	rightLines := strings.Split(right, "\n")
+	sb := strings.Builder{}
+	differences := 0
+	for i := 0; i < len(leftLines) || i < len(rightLines); i++ {
+		leftLine, rightLine := "<missing>", "<missing>"
+		if i < len(leftLines) {
+			leftLine = leftLines[i]
+		}
+		if i < len(rightLines) {
+			rightLine = rightLines[i]
+		}
+		if leftLine == rightLine {
+			continue
+		}
+		differences++
+		if differences <= maxDifferences {
+			sb.WriteString(fmt.Sprintf("line %d:\n\t- %s\n\t+ %s\n", i+1, leftLine, rightLine))
+		}
+	}
+	if differences > maxDifferences {
+		sb.WriteString(fmt.Sprintf("...and %d more differing lines\n", differences-maxDifferences))
+	}
+	return sb.String()
+}
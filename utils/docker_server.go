@@ -0,0 +1,179 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DockerServerOptions are the settings of a server that is run within Docker.
+type DockerServerOptions struct {
+	// Image is the image of the server, such as `mysql:8.0.31` or `mariadb:10.11`. The official MySQL and MariaDB images
+	// (along with images derived from them) are supported.
+	Image string
+	// Password is the password of the root user. Defaults to "password".
+	Password string
+	// Port is the port on the host that the server is published to. Defaults to a free port chosen by Docker.
+	Port int
+	// ReadyTimeout is how long the server may take to accept connections after starting. Defaults to three minutes, as
+	// the first start of an image initializes its data directory.
+	ReadyTimeout time.Duration
+	// Command is the Docker command, which may be replaced with a compatible command such as podman. Defaults to "docker".
+	Command string
+	// Logf is called while waiting for the server when it is set.
+	Logf func(format string, args ...interface{})
+}
+
+// DockerServer is a MySQL or MariaDB server that is running within a Docker container, so that an extraction may target
+// a specific version without a hand-configured local server. The container is removed when the server is closed.
+type DockerServer struct {
+	options     DockerServerOptions
+	containerID string
+	host        string
+	port        int
+}
+
+// StartDockerServer starts a container of the given image and waits until the server accepts connections. The caller
+// must close the returned server, which removes the container.
+func StartDockerServer(ctx context.Context, options DockerServerOptions) (_ *DockerServer, err error) {
+	if options.Image == "" {
+		return nil, fmt.Errorf("a Docker image is required, such as `mysql:8.0.31`")
+	}
+	if options.Password == "" {
+		options.Password = "password"
+	}
+	if options.ReadyTimeout <= 0 {
+		options.ReadyTimeout = 3 * time.Minute
+	}
+	if options.Command == "" {
+		options.Command = "docker"
+	}
+	output, err := dockerCommand(ctx, options.Command, dockerRunArgs(options)...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to start `%s`: %w", options.Image, err)
+	}
+	server := &DockerServer{options: options, containerID: strings.TrimSpace(output)}
+	defer func() {
+		if err != nil {
+			server.Close()
+		}
+	}()
+	output, err = dockerCommand(ctx, options.Command, "port", server.containerID, "3306/tcp")
+	if err != nil {
+		return nil, err
+	}
+	if server.host, server.port, err = parseDockerPort(output); err != nil {
+		return nil, err
+	}
+	if err = server.waitUntilReady(ctx); err != nil {
+		// The server's log generally explains why it never became ready
+		logs, _ := dockerCommand(context.Background(), options.Command, "logs", "--tail", "20", server.containerID)
+		return nil, fmt.Errorf("`%s` did not accept connections: %w\n%s", options.Image, err, logs)
+	}
+	return server, nil
+}
+
+// Options returns the ConnectionOptions that connect to the server as the root user.
+func (server *DockerServer) Options() ConnectionOptions {
+	return ConnectionOptions{User: "root", Password: server.options.Password, Host: server.host, Port: server.port}
+}
+
+// ContainerID returns the ID of the server's container.
+func (server *DockerServer) ContainerID() string {
+	return server.containerID
+}
+
+// Close stops the server and removes its container, along with the container's volumes. This does not use a context,
+// so that the container is removed even after the extraction was cancelled.
+func (server *DockerServer) Close() error {
+	_, err := dockerCommand(context.Background(), server.options.Command, "rm", "--force", "--volumes", server.containerID)
+	return err
+}
+
+// waitUntilReady connects to the server until it succeeds or the timeout is reached. The images run a temporary server
+// without networking while they initialize, so the first successful connection is made to the actual server.
+func (server *DockerServer) waitUntilReady(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, server.options.ReadyTimeout)
+	defer cancel()
+	for attempt := 1; ; attempt++ {
+		conn, err := NewConnectionWithOptions(server.Options())
+		if err == nil {
+			return conn.Close()
+		}
+		if server.options.Logf != nil && attempt%10 == 0 {
+			server.options.Logf("waiting for `%s` to accept connections: %s", server.options.Image, err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w (last error: %s)", ctx.Err(), err.Error())
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// dockerRunArgs returns the arguments that start a detached container of the server. The port is only published to the
+// loopback interface, as the root password is not secret.
+func dockerRunArgs(options DockerServerOptions) []string {
+	port := ""
+	if options.Port > 0 {
+		port = strconv.Itoa(options.Port)
+	}
+	return []string{"run", "--detach", "--rm",
+		// The MariaDB images also read the MYSQL_ prefixed variables
+		"--env", "MYSQL_ROOT_PASSWORD=" + options.Password,
+		"--env", "MYSQL_ROOT_HOST=%",
+		"--publish", "127.0.0.1:" + port + ":3306",
+		options.Image,
+	}
+}
+
+// parseDockerPort returns the host and port from the output of `docker port`, which has a line for each address that the
+// port is published to.
+func parseDockerPort(output string) (string, int, error) {
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		host, portStr, err := net.SplitHostPort(strings.TrimSpace(line))
+		if err != nil {
+			continue
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			continue
+		}
+		if host == "0.0.0.0" || host == "::" || host == "" {
+			host = "127.0.0.1"
+		}
+		return host, port, nil
+	}
+	return "", 0, fmt.Errorf("unable to find the published port within `%s`", strings.TrimSpace(output))
+}
+
+// dockerCommand runs the Docker command with the given arguments, returning its output. The error contains the
+// command's error output.
+func dockerCommand(ctx context.Context, command string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("`%s %s` failed: %w: %s", command, args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,44 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDockerRunArgs(t *testing.T) {
+	assert.Equal(t, []string{"run", "--detach", "--rm", "--env", "MYSQL_ROOT_PASSWORD=password", "--env", "MYSQL_ROOT_HOST=%",
+		"--publish", "127.0.0.1::3306", "mysql:8.0.31"}, dockerRunArgs(DockerServerOptions{Image: "mysql:8.0.31", Password: "password"}))
+	assert.Contains(t, dockerRunArgs(DockerServerOptions{Image: "mariadb:10.11", Port: 3310}), "127.0.0.1:3310:3306")
+}
+
+func TestParseDockerPort(t *testing.T) {
+	host, port, err := parseDockerPort("127.0.0.1:49153\n")
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", host)
+	assert.Equal(t, 49153, port)
+
+	// Older versions of Docker report the wildcard address, which is reached through the loopback interface
+	host, port, err = parseDockerPort("0.0.0.0:32768\n[::]:32768\n")
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", host)
+	assert.Equal(t, 32768, port)
+
+	_, _, err = parseDockerPort("Error: No public port '3306/tcp' published")
+	assert.Error(t, err)
+}
@@ -0,0 +1,130 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// doltCollationMagic identifies a file created by RuneComparatorToDoltFile.
+var doltCollationMagic = [4]byte{'D', 'C', 'W', 'T'}
+
+// doltCollationVersion is the version of the format written by RuneComparatorToDoltFile.
+const doltCollationVersion uint16 = 1
+
+// RuneComparatorToDoltFile returns the given RuneComparator as a binary weight table, which Dolt loads to order
+// collation-aware index keys without depending on generated Go source. All values are little-endian:
+//
+//	magic           [4]byte  "DCWT"
+//	version         uint16
+//	name length     uint16
+//	name            [name length]byte
+//	offset count    uint32
+//	offset ranges   [offset count]{lower int32, upper int32, offset int32}
+//	weight count    uint32
+//	weight ranges   [weight count]{lower int32, upper int32, weight int32}
+//
+// Both range lists are sorted by their lower bound and do not overlap, so they may be binary searched. A rune within an
+// offset range has the weight `rune + offset`, while a rune within a weight range has the range's weight. Runes that
+// are not within any range have the maximum weight.
+func RuneComparatorToDoltFile(rc *RuneComparator, name string) []byte {
+	dynamicWeightRanges, staticWeightRanges := rc.weightRanges()
+	sort.Slice(dynamicWeightRanges, func(i, j int) bool {
+		return dynamicWeightRanges[i].Lower < dynamicWeightRanges[j].Lower
+	})
+	sort.Slice(staticWeightRanges, func(i, j int) bool {
+		return staticWeightRanges[i].Lower < staticWeightRanges[j].Lower
+	})
+
+	buf := bytes.Buffer{}
+	buf.Write(doltCollationMagic[:])
+	_ = binary.Write(&buf, binary.LittleEndian, doltCollationVersion)
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(len(name)))
+	buf.WriteString(name)
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(len(dynamicWeightRanges)))
+	for _, dynamic := range dynamicWeightRanges {
+		_ = binary.Write(&buf, binary.LittleEndian, [3]int32{dynamic.Lower, dynamic.Upper, int32(dynamic.Offset)})
+	}
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(len(staticWeightRanges)))
+	for _, static := range staticWeightRanges {
+		_ = binary.Write(&buf, binary.LittleEndian, [3]int32{static.Lower, static.Upper, int32(static.Weight)})
+	}
+	return buf.Bytes()
+}
+
+// ParseDoltFile parses a file that was previously created by RuneComparatorToDoltFile, returning the name along with
+// the weights.
+func ParseDoltFile(data []byte) (string, *RuneWeights, error) {
+	reader := bytes.NewReader(data)
+	var header struct {
+		Magic      [4]byte
+		Version    uint16
+		NameLength uint16
+	}
+	if err := binary.Read(reader, binary.LittleEndian, &header); err != nil {
+		return "", nil, err
+	}
+	if header.Magic != doltCollationMagic {
+		return "", nil, fmt.Errorf("data is not a Dolt weight table")
+	}
+	if header.Version != doltCollationVersion {
+		return "", nil, fmt.Errorf("unsupported Dolt weight table version: %d", header.Version)
+	}
+	name := make([]byte, header.NameLength)
+	if _, err := reader.Read(name); err != nil {
+		return "", nil, err
+	}
+	readRanges := func() ([][3]int32, error) {
+		var count uint32
+		if err := binary.Read(reader, binary.LittleEndian, &count); err != nil {
+			return nil, err
+		}
+		if int64(count)*12 > int64(reader.Len()) {
+			return nil, fmt.Errorf("Dolt weight table is truncated")
+		}
+		ranges := make([][3]int32, count)
+		if err := binary.Read(reader, binary.LittleEndian, ranges); err != nil {
+			return nil, err
+		}
+		return ranges, nil
+	}
+	rw := &RuneWeights{weights: make(map[rune]int32)}
+	offsetRanges, err := readRanges()
+	if err != nil {
+		return "", nil, err
+	}
+	for _, offsetRange := range offsetRanges {
+		rw.dynamicRanges = append(rw.dynamicRanges, dynamicWeightRange{
+			Offset: int(offsetRange[2]),
+			Lower:  offsetRange[0],
+			Upper:  offsetRange[1],
+		})
+	}
+	weightRanges, err := readRanges()
+	if err != nil {
+		return "", nil, err
+	}
+	for _, weightRange := range weightRanges {
+		rw.staticRanges = append(rw.staticRanges, staticWeightRange{
+			Weight: int(weightRange[2]),
+			Lower:  weightRange[0],
+			Upper:  weightRange[1],
+		})
+	}
+	return string(name), rw, nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// EncodingBuffer collects codepoints in any order, and gives them to a RangeMapConstructor in the order that it
+// requires. This should be used for any codepoint source other than CharacterSetEncodingIterator, such as codepoints
+// that are read from a file or collected by multiple workers.
+type EncodingBuffer struct {
+	encodings []bufferedEncoding
+}
+
+// bufferedEncoding is a single codepoint that has been added to an EncodingBuffer.
+type bufferedEncoding struct {
+	input  []byte
+	output []byte
+}
+
+// NewEncodingBuffer returns a new EncodingBuffer.
+func NewEncodingBuffer() *EncodingBuffer {
+	return &EncodingBuffer{}
+}
+
+// Add adds the given codepoints to the buffer. It is assumed that these two codepoints are equivalent in their
+// respective encodings. The slices are copied, so they may be reused by the caller.
+func (eb *EncodingBuffer) Add(inputCodepoint []byte, outputCodepoint []byte) {
+	eb.encodings = append(eb.encodings, bufferedEncoding{
+		input:  append([]byte(nil), inputCodepoint...),
+		output: append([]byte(nil), outputCodepoint...),
+	})
+}
+
+// AddTo sorts the buffered codepoints by their length and then by their value (ascending), and adds them to the given
+// constructor. Codepoints that were added multiple times are only given once. Returns an error if the same input
+// codepoint was added with different output codepoints.
+func (eb *EncodingBuffer) AddTo(rc *RangeMapConstructor) error {
+	sort.SliceStable(eb.encodings, func(i, j int) bool {
+		if len(eb.encodings[i].input) != len(eb.encodings[j].input) {
+			return len(eb.encodings[i].input) < len(eb.encodings[j].input)
+		}
+		return bytes.Compare(eb.encodings[i].input, eb.encodings[j].input) < 0
+	})
+	for i, encoding := range eb.encodings {
+		if i > 0 && bytes.Equal(eb.encodings[i-1].input, encoding.input) {
+			if !bytes.Equal(eb.encodings[i-1].output, encoding.output) {
+				return fmt.Errorf("codepoint %v was added with the outputs %v and %v",
+					encoding.input, eb.encodings[i-1].output, encoding.output)
+			}
+			continue
+		}
+		rc.AddValidEncoding(encoding.input, encoding.output)
+	}
+	return rc.OrderingError()
+}
@@ -0,0 +1,112 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodingBuffer(t *testing.T) {
+	tree := consolidationTestTrees()["euc"]
+	expected, err := RangeMapFromTree(tree)
+	require.NoError(t, err)
+	var inputs, outputs [][]byte
+	iter := tree.Iterator()
+	for input, output, ok := iter.Next(); ok; input, output, ok = iter.Next() {
+		inputs = append(inputs, append([]byte(nil), input...))
+		outputs = append(outputs, append([]byte(nil), output...))
+	}
+
+	// A descending feed, a shuffled feed, and a feed with repeated codepoints all construct the same RangeMap as the
+	// ascending feed of the tree
+	descending := make([]int, len(inputs))
+	for i := range descending {
+		descending[i] = len(inputs) - 1 - i
+	}
+	shuffled := rand.New(rand.NewSource(1)).Perm(len(inputs))
+	repeated := append(append([]int(nil), shuffled...), shuffled[:len(shuffled)/2]...)
+	for name, order := range map[string][]int{"descending": descending, "shuffled": shuffled, "repeated": repeated} {
+		buffer := NewEncodingBuffer()
+		for _, i := range order {
+			buffer.Add(inputs[i], outputs[i])
+		}
+		constructor := NewRangeMapConstructor()
+		require.NoError(t, buffer.AddTo(constructor), name)
+		rangeMap := constructor.Map()
+		assert.Empty(t, expected.Tree().Diff(rangeMap.Tree()), name)
+		assert.Equal(t, RangeMapToGoFile(expected, CaseMappings{}, "euc"), RangeMapToGoFile(rangeMap, CaseMappings{}, "euc"), name)
+	}
+
+	// Without the buffer, the shuffled feed is reported by the constructor
+	constructor := NewRangeMapConstructor()
+	for _, i := range shuffled {
+		constructor.AddValidEncoding(inputs[i], outputs[i])
+	}
+	assert.Error(t, constructor.OrderingError())
+
+	// The buffer copies the codepoints, so the caller may reuse its slices
+	buffer := NewEncodingBuffer()
+	input, output := []byte{'a'}, []byte{'a'}
+	buffer.Add(input, output)
+	input[0], output[0] = 'b', 'b'
+	buffer.Add(input, output)
+	constructor = NewRangeMapConstructor()
+	require.NoError(t, buffer.AddTo(constructor))
+	decoded, ok := constructor.Map().Decode([]byte{'a'})
+	assert.True(t, ok)
+	assert.Equal(t, []byte{'a'}, decoded)
+
+	// The same input codepoint cannot have different outputs
+	buffer = NewEncodingBuffer()
+	buffer.Add([]byte{0xA1, 0xA1}, []byte("〈"))
+	buffer.Add([]byte{'a'}, []byte{'a'})
+	buffer.Add([]byte{0xA1, 0xA1}, []byte("〉"))
+	assert.Error(t, buffer.AddTo(NewRangeMapConstructor()))
+}
+
+func TestRangeMapConstructorOrdering(t *testing.T) {
+	add := func(codepoints ...[]byte) error {
+		constructor := NewRangeMapConstructor()
+		for _, codepoint := range codepoints {
+			constructor.AddValidEncoding(codepoint, codepoint)
+		}
+		return constructor.OrderingError()
+	}
+	// Either direction is allowed within each length, and the lengths may be given in any order
+	assert.NoError(t, add([]byte{1}, []byte{2}, []byte{3}))
+	assert.NoError(t, add([]byte{3}, []byte{2}, []byte{1}))
+	assert.NoError(t, add([]byte{1, 1}, []byte{1, 2}, []byte{3}, []byte{2}))
+	assert.NoError(t, add([]byte{1}, []byte{2}, []byte{2, 2}, []byte{2, 1}))
+
+	// A change of direction within a length
+	assert.Error(t, add([]byte{1}, []byte{3}, []byte{2}))
+	// A repeated codepoint
+	assert.Error(t, add([]byte{1}, []byte{2}, []byte{2}))
+	// A length that was already finished
+	assert.Error(t, add([]byte{1}, []byte{1, 1}, []byte{2}))
+
+	// The first error is kept, even once the codepoints are ordered again
+	constructor := NewRangeMapConstructor()
+	for _, codepoint := range [][]byte{{1}, {3}, {2}, {1, 1}, {1, 2}} {
+		constructor.AddValidEncoding(codepoint, codepoint)
+	}
+	err := constructor.OrderingError()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "[2]")
+}
@@ -0,0 +1,79 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EquivalenceClasses returns every group of runes that share a weight, which are the runes that compare as equal to
+// one another (such as the case and accent variants of a letter in an insensitive collation). Runes that do not share
+// their weight with any other rune are not included. The groups are returned in weight order, and the runes of each
+// group are in sequential order.
+func (rc *RuneComparator) EquivalenceClasses() [][]rune {
+	var classes [][]rune
+	for _, row := range rc.rows() {
+		if len(row) > 1 {
+			classes = append(classes, append([]rune{}, row...))
+		}
+	}
+	return classes
+}
+
+// EquivalenceClassesToGoFile returns the equivalence classes of the given RuneComparator as a Go file for inclusion in
+// an application, alongside the file that RuneComparatorToGoFile generated for the same RuneComparator. This allows
+// case and accent insensitive pattern matching (such as LIKE) to match every rune that is equal to a pattern's rune.
+func EquivalenceClassesToGoFile(rc *RuneComparator, name string, options CodegenOptions) string {
+	titleName, lowerName := options.names(name)
+
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf(`%[1]s
+
+// %[2]s_EquivalenceClass returns every rune that compares as equal to the given rune for the %[4]s
+// collation, including the given rune, in sequential order. Returns nil when no other rune is equal to the given rune.
+// The returned slice must not be modified.
+func %[2]s_EquivalenceClass(r rune) []rune {
+	if idx, ok := %[3]s_equivalenceClassIndex[r]; ok {
+		return %[3]s_EquivalenceClasses[idx]
+	}
+	return nil
+}
+
+// %[3]s_equivalenceClassIndex maps each rune within %[3]s_EquivalenceClasses to the index of its class.
+var %[3]s_equivalenceClassIndex = func() map[rune]int {
+	index := make(map[rune]int)
+	for idx, class := range %[3]s_EquivalenceClasses {
+		for _, r := range class {
+			index[r] = idx
+		}
+	}
+	return index
+}()
+
+// %[3]s_EquivalenceClasses contains every group of runes that share a weight for the %[4]s collation,
+// in weight order.
+var %[3]s_EquivalenceClasses = [][]rune{
+`, options.fileHeader(), titleName, lowerName, "`"+lowerName+"`"))
+	for _, class := range rc.EquivalenceClasses() {
+		runes := make([]string, len(class))
+		for i, r := range class {
+			runes[i] = fmt.Sprint(r)
+		}
+		sb.WriteString(fmt.Sprintf("\t{%s}, // %q\n", strings.Join(runes, ", "), string(class)))
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
@@ -0,0 +1,40 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEquivalenceClasses(t *testing.T) {
+	rc := NewRuneComparatorFromOrder([][]rune{{'0'}, {'A', 'a', 'À', 'à'}, {'B', 'b'}, {'c'}})
+	classes := rc.EquivalenceClasses()
+	assert.Equal(t, [][]rune{{'A', 'a', 'À', 'à'}, {'B', 'b'}}, classes)
+	// The classes are copies, so modifying them does not modify the RuneComparator
+	classes[0][0] = 'Z'
+	assert.Equal(t, 'A', rc.rows()[1][0])
+
+	contents := EquivalenceClassesToGoFile(rc, "test_ai_ci", CodegenOptions{})
+	_, err := parser.ParseFile(token.NewFileSet(), "", contents, 0)
+	require.NoError(t, err)
+	assert.True(t, strings.Contains(contents, "func Test_ai_ci_EquivalenceClass(r rune) []rune {"))
+	assert.True(t, strings.Contains(contents, "\t{66, 98}, // \"Bb\"\n"))
+}
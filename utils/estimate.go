@@ -0,0 +1,175 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// QueryEstimate is the estimated number of queries that an extraction will issue. Extractions are dominated by the
+// round trip of each query, so the number of queries determines both the duration and the cost of an extraction when
+// the server is a metered cloud database.
+type QueryEstimate struct {
+	Name     string
+	Strategy ExtractionStrategy
+	Queries  int
+	// Exact is false when the estimate is a lower bound, as some queries depend on the server's responses.
+	Exact bool
+}
+
+// EstimateCharacterSetQueries returns the estimated number of queries to extract a character set. A CONVERT is issued
+// for every batch of runes, while the case conversions issue an UPPER and LOWER for every valid rune. When the number of valid
+// runes is unknown (such as when the character set has never been extracted), all runes are assumed to be valid.
+func EstimateCharacterSetQueries(charset string, runeCount int, validRuneCount int) QueryEstimate {
+	if validRuneCount <= 0 {
+		validRuneCount = runeCount
+	}
+	return QueryEstimate{
+		Name:    charset,
+		Queries: characterSetMappingQueries(runeCount) + 2*validRuneCount,
+		Exact:   true,
+	}
+}
+
+// EstimateCollationQueries returns the estimated number of queries to extract a collation using the profile's strategy.
+// The character set's RangeMap is extracted first, which issues a CONVERT for every batch of runes. Seeded weights are only
+// queried for one of every sample runes. STRCMP is only issued for runes without a weight, which cannot be known
+// beforehand, so the estimate is a lower bound.
+func EstimateCollationQueries(profile ExtractionProfile, runeCount int, validRuneCount int, seededRuneCount int, seedSample int) QueryEstimate {
+	if validRuneCount <= 0 {
+		validRuneCount = runeCount
+	}
+	estimate := QueryEstimate{
+		Name:     profile.Collation,
+		Strategy: profile.Strategy,
+	}
+	switch profile.Strategy {
+	case ExtractionStrategyOrderBy:
+		// Seeded weights are not used, as every rune is sorted on the server. The runes are inserted in batches into a
+		// temporary table (which is created and dropped, along with its database), and sorted using a single query.
+		// STRCMP is only issued for adjacent runes without a weight.
+		estimate.Queries = characterSetMappingQueries(runeCount) + (validRuneCount+OrderByBatchSize-1)/OrderByBatchSize + 5 + 6
+	case ExtractionStrategyBinary:
+		// The runes are sorted by their encodings without any queries, and adjacent runes are then compared in batches.
+		// The pairs whose codepoints descend are also compared, which cannot be known beforehand.
+		pairs := BinaryVerificationPairs
+		if pairs > validRuneCount-1 {
+			pairs = validRuneCount - 1
+		}
+		estimate.Queries = characterSetMappingQueries(runeCount) + characterSetMappingQueries(pairs) + 6
+	default:
+		// Strategies that are not yet implemented fall back to STRCMP, so they issue the same queries
+		estimate.Strategy = ExtractionStrategyStrcmp
+		weightQueries := validRuneCount - seededRuneCount
+		if seedSample > 0 {
+			weightQueries += seededRuneCount / seedSample
+		}
+		// The padding is determined by comparing a handful of strings
+		estimate.Queries = characterSetMappingQueries(runeCount) + weightQueries + 6
+	}
+	return estimate
+}
+
+// characterSetMappingQueries returns the number of queries to map the given number of runes to a character set.
+func characterSetMappingQueries(runeCount int) int {
+	return (runeCount + CharacterSetBatchSize - 1) / CharacterSetBatchSize
+}
+
+// Duration returns the estimated duration of the extraction given the latency of a single query.
+func (qe QueryEstimate) Duration(latency time.Duration) time.Duration {
+	return time.Duration(qe.Queries) * latency
+}
+
+// Cost returns the estimated cost of the extraction given the price of one million queries.
+func (qe QueryEstimate) Cost(pricePerMillion float64) float64 {
+	return float64(qe.Queries) * pricePerMillion / 1000000
+}
+
+// String returns the estimate as a line that may be printed alongside other estimates.
+func (qe QueryEstimate) String() string {
+	bound := "="
+	if !qe.Exact {
+		bound = ">="
+	}
+	strategy := string(qe.Strategy)
+	if strategy == "" {
+		strategy = "-"
+	}
+	return fmt.Sprintf("%-32s %-10s %s %d queries", qe.Name, strategy, bound, qe.Queries)
+}
+
+// MeasureLatency returns the median round trip of a trivial query, which approximates the latency of every query that
+// an extraction issues.
+func MeasureLatency(conn *Connection, samples int) (time.Duration, error) {
+	if samples <= 0 {
+		return 0, fmt.Errorf("at least one sample is required to measure latency")
+	}
+	durations := make([]time.Duration, samples)
+	for i := range durations {
+		start := time.Now()
+		if _, err := conn.Query("SELECT 1;"); err != nil {
+			return 0, err
+		}
+		durations[i] = time.Since(start)
+	}
+	sort.Slice(durations, func(i, j int) bool {
+		return durations[i] < durations[j]
+	})
+	return durations[samples/2], nil
+}
+
+// Progress reports the progress of an extraction after each block of runes, along with the estimated time remaining.
+// Extractions iterate over runes sequentially, so the time remaining is extrapolated from the runes processed so far.
+type Progress struct {
+	name      string
+	total     int
+	processed int
+	blockSize int
+	start     time.Time
+	logf      func(format string, args ...interface{})
+}
+
+// ProgressBlockSize is the number of runes in each block that Progress reports.
+const ProgressBlockSize = 0x4000
+
+// NewProgress returns a new Progress for an extraction over the given total number of runes. Each report is given to
+// the log function (such as testing.T's Logf).
+func NewProgress(name string, total int, logf func(format string, args ...interface{})) *Progress {
+	return &Progress{
+		name:      name,
+		total:     total,
+		blockSize: ProgressBlockSize,
+		start:     time.Now(),
+		logf:      logf,
+	}
+}
+
+// Step records that the given rune was processed, reporting the progress when a block has been completed.
+func (p *Progress) Step(r rune) {
+	p.processed++
+	if p.processed%p.blockSize != 0 && p.processed != p.total {
+		return
+	}
+	elapsed := time.Since(p.start)
+	remaining := time.Duration(0)
+	if p.processed < p.total {
+		remaining = time.Duration(float64(elapsed) / float64(p.processed) * float64(p.total-p.processed))
+	}
+	p.logf("%s: processed through U+%04X (%d/%d runes, %.1f%%), elapsed %s, remaining ~%s", p.name, r,
+		p.processed, p.total, 100*float64(p.processed)/float64(p.total),
+		elapsed.Round(time.Second), remaining.Round(time.Second))
+}
@@ -0,0 +1,120 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CollationElementLength returns the number of bytes of a single collation element within the primary level of the
+// given weights, which is the most common length of a single rune's primary weight. Runes with an empty primary weight
+// are ignored, as they do not have any elements.
+func CollationElementLength(weights map[rune][]byte, levels int) int {
+	counts := make(map[int]int)
+	for _, weight := range weights {
+		if length := len(SplitWeightLevels(weight, levels)[0]); length > 0 {
+			counts[length]++
+		}
+	}
+	elementLength := 0
+	for length, count := range counts {
+		if count > counts[elementLength] || (count == counts[elementLength] && length < elementLength) {
+			elementLength = length
+		}
+	}
+	return elementLength
+}
+
+// FindExpansions returns every rune whose primary weight contains multiple collation elements (such as 'ß', which
+// expands to "ss" in many collations), mapped to the runes that each element belongs to. The weights are the
+// WEIGHT_STRING output of each rune. An element belongs to the lowest rune whose primary weight is exactly that
+// element, and runes with an element that does not belong to any rune are not returned, as they cannot be decomposed.
+func FindExpansions(weights map[rune][]byte, levels int) map[rune][]rune {
+	elementLength := CollationElementLength(weights, levels)
+	expansions := make(map[rune][]rune)
+	if elementLength == 0 {
+		return expansions
+	}
+	primaries := make(map[rune][]byte, len(weights))
+	elementRunes := make(map[string]rune)
+	for r, weight := range weights {
+		primary := SplitWeightLevels(weight, levels)[0]
+		primaries[r] = primary
+		if len(primary) == elementLength {
+			if existing, ok := elementRunes[string(primary)]; !ok || r < existing {
+				elementRunes[string(primary)] = r
+			}
+		}
+	}
+	for r, primary := range primaries {
+		if len(primary) <= elementLength || len(primary)%elementLength != 0 {
+			continue
+		}
+		decomposition := make([]rune, 0, len(primary)/elementLength)
+		for i := 0; i < len(primary); i += elementLength {
+			elementRune, ok := elementRunes[string(primary[i:i+elementLength])]
+			if !ok {
+				decomposition = nil
+				break
+			}
+			decomposition = append(decomposition, elementRune)
+		}
+		if decomposition != nil {
+			expansions[r] = decomposition
+		}
+	}
+	return expansions
+}
+
+// ExpansionsToGoFile returns the given expansions as a Go file for inclusion in an application, alongside the file
+// that RuneComparatorToGoFile generated for the same RuneComparator. Each expansion is written as the weights of the
+// runes that it expands to, so that an expanded rune may be compared as multiple elements rather than a single weight.
+func ExpansionsToGoFile(rc *RuneComparator, name string, expansions map[rune][]rune, options CodegenOptions) (string, error) {
+	_, lowerName := options.names(name)
+	runeWeights := make(map[rune]int32)
+	for weight, runes := range rc.rows() {
+		for _, r := range runes {
+			runeWeights[r] = int32(weight)
+		}
+	}
+	sortedRunes := make([]rune, 0, len(expansions))
+	for r := range expansions {
+		sortedRunes = append(sortedRunes, r)
+	}
+	sortRunes(sortedRunes)
+
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf(`%s
+
+// %s_Expansions contains the runes that expand to multiple collation elements for the %s
+// collation, mapped to the weight of each element. The weights match the weights of the runes that each element
+// belongs to, so an expanded rune compares equal to the sequence of those runes.
+var %s_Expansions = map[rune][]int32{
+`, options.fileHeader(), lowerName, "`"+lowerName+"`", lowerName))
+	for _, r := range sortedRunes {
+		elementWeights := make([]string, len(expansions[r]))
+		for i, elementRune := range expansions[r] {
+			weight, ok := runeWeights[elementRune]
+			if !ok {
+				return "", fmt.Errorf("rune %d expands to rune %d, which does not have a weight", r, elementRune)
+			}
+			elementWeights[i] = fmt.Sprint(weight)
+		}
+		sb.WriteString(fmt.Sprintf("\t%d: {%s}, // %s\n", r, strings.Join(elementWeights, ", "), fmt.Sprintf("%q -> %q", string(r), string(expansions[r]))))
+	}
+	sb.WriteString("}\n")
+	return sb.String(), nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+// ExtractionHooks contains functions that are called at each stage of an extraction, which allows custom probes (such as
+// collecting the output of additional server functions) to run alongside an extraction without modifying it. Any field
+// may be nil. Returning an error fails the extraction.
+type ExtractionHooks struct {
+	// BeforeCharacterSet is called before the encodings of a character set are extracted.
+	BeforeCharacterSet func(conn Queryable, charset string) error
+	// AfterCharacterSet is called with the extracted encodings of a character set.
+	AfterCharacterSet func(conn Queryable, charset string, rangeMap *RangeMap) error
+	// CharacterSetRune is called for every rune that is converted to a character set, along with the server's output.
+	// This is called before the output is checked for validity, so the output may be the character set's replacement.
+	CharacterSetRune func(conn Queryable, charset string, r rune, output []byte) error
+	// CollationRune is called for every rune that is inserted while extracting a collation, along with its weight. The
+	// weight is nil for runes that the server does not return a weight for.
+	CollationRune func(conn Queryable, collation string, r rune, weight []byte) error
+	// CollationStrcmp is called for every pair of runes that is compared using STRCMP while extracting a collation, as
+	// at least one of them lacks a weight.
+	CollationStrcmp func(conn Queryable, collation string, l rune, r rune) error
+	// BeforeCodegen is called with the model that a file is about to be generated from. The model must not be modified.
+	BeforeCodegen func(model *Model) error
+}
+
+// registeredExtractionHooks contains every set of hooks that has been registered, in the order of registration.
+var registeredExtractionHooks []ExtractionHooks
+
+// RegisterExtractionHooks adds the given hooks to every extraction. This is intended to be called from an init function
+// within a separate file, so that probes may be added without changing the extraction itself.
+func RegisterExtractionHooks(hooks ExtractionHooks) {
+	registeredExtractionHooks = append(registeredExtractionHooks, hooks)
+}
+
+// RegisteredExtractionHooks returns hooks that call every registered hook in the order of registration, stopping at
+// the first error. Every field of the returned hooks is set, so they may be called without checking for nil.
+func RegisteredExtractionHooks() ExtractionHooks {
+	registered := registeredExtractionHooks
+	return ExtractionHooks{
+		BeforeCharacterSet: func(conn Queryable, charset string) error {
+			for _, hooks := range registered {
+				if hooks.BeforeCharacterSet != nil {
+					if err := hooks.BeforeCharacterSet(conn, charset); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+		AfterCharacterSet: func(conn Queryable, charset string, rangeMap *RangeMap) error {
+			for _, hooks := range registered {
+				if hooks.AfterCharacterSet != nil {
+					if err := hooks.AfterCharacterSet(conn, charset, rangeMap); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+		CharacterSetRune: func(conn Queryable, charset string, r rune, output []byte) error {
+			for _, hooks := range registered {
+				if hooks.CharacterSetRune != nil {
+					if err := hooks.CharacterSetRune(conn, charset, r, output); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+		CollationRune: func(conn Queryable, collation string, r rune, weight []byte) error {
+			for _, hooks := range registered {
+				if hooks.CollationRune != nil {
+					if err := hooks.CollationRune(conn, collation, r, weight); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+		CollationStrcmp: func(conn Queryable, collation string, l rune, r rune) error {
+			for _, hooks := range registered {
+				if hooks.CollationStrcmp != nil {
+					if err := hooks.CollationStrcmp(conn, collation, l, r); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+		BeforeCodegen: func(model *Model) error {
+			for _, hooks := range registered {
+				if hooks.BeforeCodegen != nil {
+					if err := hooks.BeforeCodegen(model); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+	}
+}
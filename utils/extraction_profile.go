@@ -0,0 +1,136 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"strings"
+)
+
+// ExtractionStrategy is the approach that is used to extract the weights of a collation.
+type ExtractionStrategy string
+
+const (
+	// ExtractionStrategyStrcmp inserts every rune into a RuneComparator, using STRCMP when weights are not available.
+	// This works for every collation, and is therefore the fallback for all other strategies.
+	ExtractionStrategyStrcmp ExtractionStrategy = "strcmp"
+	// ExtractionStrategyOrderBy sorts all runes on the server using a single query. This is intended for the UCA
+	// collations, which contain the most runes.
+	ExtractionStrategyOrderBy ExtractionStrategy = "order_by"
+	// ExtractionStrategyBinary copies the byte order of the character set, which is how all _bin collations sort.
+	ExtractionStrategyBinary ExtractionStrategy = "binary"
+	// ExtractionStrategyDelta extracts only the runes that differ from a base collation, which is intended for
+	// language tailorings of the UCA collations.
+	ExtractionStrategyDelta ExtractionStrategy = "delta"
+)
+
+const (
+	// OrderByBatchSize is the number of runes that ExtractionStrategyOrderBy inserts with each statement.
+	OrderByBatchSize = 1000
+	// CharacterSetBatchSize is the number of runes that are converted to a character set with each statement. Each
+	// rune is a separate column of the same row, and servers limit the number of columns to a few thousand.
+	CharacterSetBatchSize = 256
+	// BinaryVerificationPairs is the number of adjacent runes that ExtractionStrategyBinary compares using the server,
+	// which are spread evenly across the byte order.
+	BinaryVerificationPairs = 1024
+)
+
+// ExtractionProfile is the strategy to use for extracting a specific collation.
+type ExtractionProfile struct {
+	Collation string
+	Strategy  ExtractionStrategy
+	// Base is the collation that a delta is computed against. Only used by ExtractionStrategyDelta.
+	Base string
+}
+
+// unicodeCharacterSets are the character sets that have collations based on the Unicode Collation Algorithm.
+var unicodeCharacterSets = map[string]struct{}{
+	"ucs2":    {},
+	"utf16":   {},
+	"utf16le": {},
+	"utf32":   {},
+	"utf8":    {},
+	"utf8mb3": {},
+	"utf8mb4": {},
+}
+
+// unicodeTailorings are the language tailorings of the `_unicode_ci` and `_unicode_520_ci` collations.
+var unicodeTailorings = map[string]struct{}{
+	"croatian":   {},
+	"czech":      {},
+	"danish":     {},
+	"esperanto":  {},
+	"estonian":   {},
+	"german2":    {},
+	"hungarian":  {},
+	"icelandic":  {},
+	"latvian":    {},
+	"lithuanian": {},
+	"persian":    {},
+	"polish":     {},
+	"roman":      {},
+	"romanian":   {},
+	"sinhala":    {},
+	"slovak":     {},
+	"slovenian":  {},
+	"spanish":    {},
+	"spanish2":   {},
+	"swedish":    {},
+	"turkish":    {},
+	"vietnamese": {},
+}
+
+// SelectExtractionProfile returns the recommended ExtractionProfile for the given collation, based on its name.
+func SelectExtractionProfile(collation string) ExtractionProfile {
+	collation = strings.ToLower(collation)
+	// The NO PAD variants of MariaDB have the same weights as the collations that they're named after
+	var parts []string
+	for _, part := range strings.Split(collation, "_") {
+		if part != "nopad" {
+			parts = append(parts, part)
+		}
+	}
+	charset := parts[0]
+	if collation == "binary" || parts[len(parts)-1] == "bin" {
+		return ExtractionProfile{Collation: collation, Strategy: ExtractionStrategyBinary}
+	}
+	if _, ok := unicodeCharacterSets[charset]; !ok || len(parts) < 3 {
+		return ExtractionProfile{Collation: collation, Strategy: ExtractionStrategyStrcmp}
+	}
+	// UCA 9.0.0 collations are named `charset_0900_*` or `charset_language_0900_*` for tailorings
+	for i := 1; i < len(parts); i++ {
+		if parts[i] == "0900" {
+			if i == 1 {
+				return ExtractionProfile{Collation: collation, Strategy: ExtractionStrategyOrderBy}
+			}
+			return ExtractionProfile{
+				Collation: collation,
+				Strategy:  ExtractionStrategyDelta,
+				Base:      charset + "_" + strings.Join(parts[i:], "_"),
+			}
+		}
+	}
+	// Older UCA collations are named `charset_unicode_*` or `charset_language_*` for tailorings
+	if parts[1] == "unicode" {
+		return ExtractionProfile{Collation: collation, Strategy: ExtractionStrategyOrderBy}
+	}
+	if _, ok := unicodeTailorings[parts[1]]; ok {
+		base := charset + "_unicode_ci"
+		if len(parts) > 3 && parts[2] == "520" {
+			base = charset + "_unicode_520_ci"
+		}
+		return ExtractionProfile{Collation: collation, Strategy: ExtractionStrategyDelta, Base: base}
+	}
+	return ExtractionProfile{Collation: collation, Strategy: ExtractionStrategyStrcmp}
+}
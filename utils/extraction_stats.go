@@ -0,0 +1,156 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// ExtractionStats contains the statistics of a single extraction, which are useful for sanity checking a new character
+// set or collation, and for noticing when a change to the extractor alters what it extracts.
+type ExtractionStats struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	// Codepoints is the number of encoded codepoints that the character set decodes. Only set for character sets.
+	Codepoints int `json:"codepoints"`
+	// UnmappableRunes is the number of runes that the character set is unable to encode. Only set for character sets.
+	UnmappableRunes int `json:"unmappable_runes"`
+	// InputEntries and OutputEntries are the number of RangeMap entries for each encoding length, where the first
+	// element is the number of single-byte entries. Only set for character sets.
+	InputEntries  []int `json:"input_entries,omitempty"`
+	OutputEntries []int `json:"output_entries,omitempty"`
+	// Runes is the number of runes that the collation sorts, and WeightClasses is the number of distinct weights among
+	// them. Only set for collations.
+	Runes         int `json:"runes"`
+	WeightClasses int `json:"weight_classes"`
+	// StrcmpFallbacks is the number of rune pairs that were compared using STRCMP, as a weight was missing.
+	StrcmpFallbacks int `json:"strcmp_fallbacks"`
+	// Queries is the number of queries that were sent to the server, which excludes those answered by the QueryCache.
+	Queries int `json:"queries"`
+	// Seconds is the duration of the extraction.
+	Seconds float64 `json:"seconds"`
+}
+
+// ExtractionReport contains the statistics of every extraction within a single run.
+type ExtractionReport struct {
+	// Version is the server's VERSION().
+	Version     string            `json:"version"`
+	Extractions []ExtractionStats `json:"extractions"`
+	// Queries and Seconds are the totals of the run, which include any work outside of the extractions themselves.
+	Queries int     `json:"queries"`
+	Seconds float64 `json:"seconds"`
+}
+
+// NewCharacterSetStats returns the statistics of the given character set's RangeMap. Every rune is encoded and every
+// codepoint is decoded, so this takes about as long as RangeMap.Report.
+func NewCharacterSetStats(name string, rangeMap *RangeMap) ExtractionStats {
+	report := rangeMap.Report()
+	stats := ExtractionStats{
+		Name:          name,
+		Kind:          ManifestKindCharset,
+		Codepoints:    report.Codepoints,
+		InputEntries:  report.InputEntries,
+		OutputEntries: report.OutputEntries,
+	}
+	iter := NewUTF8Iter()
+	for r, ok := iter.Next(); ok; r, ok = iter.Next() {
+		if _, ok = rangeMap.Encode([]byte(string(r))); !ok {
+			stats.UnmappableRunes++
+		}
+	}
+	return stats
+}
+
+// NewCollationStats returns the statistics of the given collation's RuneComparator.
+func NewCollationStats(name string, rc *RuneComparator) ExtractionStats {
+	stats := ExtractionStats{
+		Name:          name,
+		Kind:          ManifestKindCollation,
+		WeightClasses: rc.Len(),
+	}
+	for _, row := range rc.rows() {
+		stats.Runes += len(row)
+	}
+	return stats
+}
+
+// SetDuration sets Seconds from the given duration.
+func (stats *ExtractionStats) SetDuration(duration time.Duration) {
+	stats.Seconds = duration.Seconds()
+}
+
+// String returns the statistics as a single human-readable line.
+func (stats ExtractionStats) String() string {
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("%s `%s`: ", stats.Kind, stats.Name))
+	if stats.Kind == ManifestKindCharset {
+		sb.WriteString(fmt.Sprintf("%d codepoints, %d unmappable runes", stats.Codepoints, stats.UnmappableRunes))
+		for length, count := range stats.InputEntries {
+			if count > 0 {
+				sb.WriteString(fmt.Sprintf(", %d input entries of length %d", count, length+1))
+			}
+		}
+		for length, count := range stats.OutputEntries {
+			if count > 0 {
+				sb.WriteString(fmt.Sprintf(", %d output entries of length %d", count, length+1))
+			}
+		}
+	} else {
+		sb.WriteString(fmt.Sprintf("%d runes, %d weight classes, %d STRCMP fallbacks", stats.Runes, stats.WeightClasses, stats.StrcmpFallbacks))
+	}
+	sb.WriteString(fmt.Sprintf(", %d queries in %s", stats.Queries, secondsToDuration(stats.Seconds)))
+	return sb.String()
+}
+
+// Add adds the statistics of an extraction to the report, replacing any earlier statistics of the same extraction.
+func (report *ExtractionReport) Add(stats ExtractionStats) {
+	for i, existing := range report.Extractions {
+		if existing.Name == stats.Name && existing.Kind == stats.Kind {
+			report.Extractions[i] = stats
+			return
+		}
+	}
+	report.Extractions = append(report.Extractions, stats)
+}
+
+// String returns the report as human-readable text, with a line for each extraction followed by the totals.
+func (report ExtractionReport) String() string {
+	sb := strings.Builder{}
+	for _, stats := range report.Extractions {
+		sb.WriteString(stats.String())
+		sb.WriteRune('\n')
+	}
+	sb.WriteString(fmt.Sprintf("%d extractions from `%s`: %d queries in %s", len(report.Extractions), report.Version,
+		report.Queries, secondsToDuration(report.Seconds)))
+	return sb.String()
+}
+
+// Save writes the report to the given path as JSON.
+func (report ExtractionReport) Save(path string) error {
+	contents, err := json.MarshalIndent(report, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(contents, '\n'), 0644)
+}
+
+// secondsToDuration returns the given number of seconds as a duration, rounded for display.
+func secondsToDuration(seconds float64) time.Duration {
+	return (time.Duration(seconds * float64(time.Second))).Round(time.Millisecond)
+}
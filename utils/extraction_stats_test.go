@@ -0,0 +1,61 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractionStats(t *testing.T) {
+	rangeMap, err := RangeMapFromTree(consolidationTestTrees()["euc"])
+	require.NoError(t, err)
+	charsetStats := NewCharacterSetStats("euc", rangeMap)
+	assert.Equal(t, ManifestKindCharset, charsetStats.Kind)
+	assert.Equal(t, 128+94*(94-14), charsetStats.Codepoints)
+	assert.Equal(t, NewUTF8Iter().Total()-charsetStats.Codepoints, charsetStats.UnmappableRunes)
+	assert.Equal(t, rangeMap.Report().InputEntries, charsetStats.InputEntries)
+
+	collationStats := NewCollationStats("euc_general_ci", NewRuneComparatorFromOrder([][]rune{{'A', 'a'}, {'B', 'b'}, {'c'}}))
+	assert.Equal(t, 5, collationStats.Runes)
+	assert.Equal(t, 3, collationStats.WeightClasses)
+	collationStats.StrcmpFallbacks = 2
+	collationStats.Queries = 7
+	collationStats.SetDuration(1500 * time.Millisecond)
+	assert.Equal(t, "collation `euc_general_ci`: 5 runes, 3 weight classes, 2 STRCMP fallbacks, 7 queries in 1.5s", collationStats.String())
+
+	// Adding the same extraction again replaces the earlier statistics
+	report := ExtractionReport{Version: "8.0.31"}
+	report.Add(charsetStats)
+	report.Add(ExtractionStats{Name: "euc_general_ci", Kind: ManifestKindCollation})
+	report.Add(collationStats)
+	require.Len(t, report.Extractions, 2)
+	assert.Equal(t, collationStats, report.Extractions[1])
+	assert.Contains(t, report.String(), "2 extractions from `8.0.31`")
+
+	path := filepath.Join(t.TempDir(), "stats.json")
+	require.NoError(t, report.Save(path))
+	contents, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var loaded ExtractionReport
+	require.NoError(t, json.Unmarshal(contents, &loaded))
+	assert.Equal(t, report, loaded)
+}
@@ -0,0 +1,626 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// FakeServer is an in-memory Queryable that serves canned character sets and collations, so that the extractor may be
+// tested without a server. It evaluates the expressions that a QueryBuilder produces (such as CONVERT, CAST, COLLATE,
+// STRCMP, WEIGHT_STRING, HEX, UPPER, and LOWER), along with the metadata queries that the extractor issues. Statements
+// (and therefore the ORDER BY extraction strategy) are not supported. The utf8mb4 and binary character sets are always
+// present.
+type FakeServer struct {
+	version    string
+	builder    *QueryBuilder
+	charsets   map[string]*FakeCharset
+	collations map[string]*FakeCollation
+}
+
+var _ Queryable = (*FakeServer)(nil)
+
+// FakeCharset is a character set of a FakeServer.
+type FakeCharset struct {
+	Name string
+	// Encodings maps every rune of the character set to its encoding. Runes without an encoding are converted to '?'.
+	// When several runes share an encoding, the encoding decodes to the smallest of them.
+	Encodings map[rune][]byte
+	// decodings is the reverse of the encodings, which is built when the character set is added.
+	decodings map[string]rune
+}
+
+// FakeCollation is a collation of a FakeServer.
+type FakeCollation struct {
+	Name    string
+	Charset string
+	ID      int
+	// Weight returns the weight of a rune, where runes with equal weights are equal. Strings are compared by the weights
+	// of their runes.
+	Weight func(r rune) uint16
+	// Ignorable returns whether a rune is ignored entirely, such that it has no weight and never changes a comparison.
+	// No rune is ignorable when nil.
+	Ignorable func(r rune) bool
+	PadSpace  bool
+	// IsDefault is whether this is the default collation of its character set.
+	IsDefault bool
+}
+
+// fakeValue is the result of evaluating an expression. A collation is only set by COLLATE.
+type fakeValue struct {
+	data      []byte
+	charset   string
+	collation string
+}
+
+// NewFakeServer returns a FakeServer that reports the given version. Besides utf8mb4 and binary, the server has the
+// ascii character set with the ascii_general_ci (case-insensitive) and ascii_bin collations.
+func NewFakeServer(version string) (*FakeServer, error) {
+	builder, err := NewQueryBuilder(version)
+	if err != nil {
+		return nil, err
+	}
+	server := &FakeServer{
+		version:    version,
+		builder:    builder,
+		charsets:   make(map[string]*FakeCharset),
+		collations: make(map[string]*FakeCollation),
+	}
+	ascii := make(map[rune][]byte)
+	for r := rune(0); r < 128; r++ {
+		ascii[r] = []byte{byte(r)}
+	}
+	server.AddCharset(FakeCharset{Name: "ascii", Encodings: ascii})
+	server.AddCollation(FakeCollation{Name: "ascii_general_ci", Charset: "ascii", ID: 11, PadSpace: true, IsDefault: true,
+		Weight: func(r rune) uint16 { return uint16(unicode.ToUpper(r)) }})
+	server.AddCollation(FakeCollation{Name: "ascii_bin", Charset: "ascii", ID: 65, PadSpace: true,
+		Weight: func(r rune) uint16 { return uint16(r) }})
+	return server, nil
+}
+
+// AddCharset adds the given character set, replacing any character set of the same name.
+func (server *FakeServer) AddCharset(charset FakeCharset) {
+	charset.decodings = make(map[string]rune, len(charset.Encodings))
+	for r, encoding := range charset.Encodings {
+		if existing, ok := charset.decodings[string(encoding)]; !ok || r < existing {
+			charset.decodings[string(encoding)] = r
+		}
+	}
+	server.charsets[charset.Name] = &charset
+}
+
+// AddCollation adds the given collation, replacing any collation of the same name.
+func (server *FakeServer) AddCollation(collation FakeCollation) {
+	server.collations[collation.Name] = &collation
+}
+
+// Builder implements the interface Queryable.
+func (server *FakeServer) Builder() *QueryBuilder {
+	return server.builder
+}
+
+// Version implements the interface Queryable.
+func (server *FakeServer) Version() string {
+	return server.version
+}
+
+// QueryContext implements the interface Queryable.
+func (server *FakeServer) QueryContext(ctx context.Context, query string) ([]byte, error) {
+	values, err := server.QueryValuesContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 1 {
+		return nil, fmt.Errorf("the following query returned %d columns instead of 1: %s", len(values), query)
+	}
+	return values[0], nil
+}
+
+// QueryValuesContext implements the interface Queryable.
+func (server *FakeServer) QueryValuesContext(ctx context.Context, query string) ([][]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(query, "SELECT ") {
+		return nil, fmt.Errorf("the fake server only supports SELECT: %s", query)
+	}
+	if values, ok, err := server.informationSchema(query); ok {
+		return values, err
+	}
+	p := &fakeParser{server: server, query: query, pos: len("SELECT ")}
+	var values [][]byte
+	for {
+		value, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value.data)
+		if p.consume(", ") {
+			continue
+		}
+		p.consume(";")
+		if p.pos != len(p.query) {
+			return nil, fmt.Errorf("unexpected `%s` at position %d: %s", p.query[p.pos:], p.pos, query)
+		}
+		return values, nil
+	}
+}
+
+// QueryRowsContext implements the interface Queryable. Every supported query returns a single row.
+func (server *FakeServer) QueryRowsContext(ctx context.Context, query string, callback func(values [][]byte) error) error {
+	values, err := server.QueryValuesContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	return callback(values)
+}
+
+// QueryColumnContext implements the interface Queryable for SHOW CHARACTER SET and SHOW COLLATION.
+func (server *FakeServer) QueryColumnContext(ctx context.Context, query string, column string) ([][]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var names []string
+	switch {
+	case query == "SHOW CHARACTER SET;" && column == "Charset":
+		for name := range server.charsets {
+			names = append(names, name)
+		}
+	case strings.HasPrefix(query, "SHOW COLLATION WHERE Charset = ") && column == "Collation":
+		p := &fakeParser{server: server, query: query, pos: len("SHOW COLLATION WHERE Charset = ")}
+		charset, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		for _, collation := range server.collations {
+			if collation.Charset == string(charset.data) {
+				names = append(names, collation.Name)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("the fake server does not support the column `%s` of: %s", column, query)
+	}
+	sort.Strings(names)
+	values := make([][]byte, len(names))
+	for i, name := range names {
+		values[i] = []byte(name)
+	}
+	return values, nil
+}
+
+// ExecContext implements the interface Queryable. Statements are not supported.
+func (server *FakeServer) ExecContext(ctx context.Context, query string) error {
+	return fmt.Errorf("the fake server does not support statements: %s", query)
+}
+
+// NewFakeConnection returns a Connection that answers every query from the given FakeServer, so that code taking a
+// Connection (such as a ConnectionPool) may be tested without a server. The FakeServer may be shared by multiple
+// connections, as it is not modified by queries.
+func NewFakeConnection(server *FakeServer) *Connection {
+	return &Connection{builder: server.builder, version: server.version, replay: server}
+}
+
+// replay answers the queries of a Connection from NewFakeConnection, using the kinds of query within a recording.
+func (server *FakeServer) replay(kind string, query string) (recordedResult, error) {
+	ctx := context.Background()
+	switch kind {
+	case recordedValue:
+		value, err := server.QueryContext(ctx, query)
+		if err != nil {
+			return recordedResult{}, err
+		}
+		return recordedResult{Rows: [][][]byte{{value}}, Null: value == nil}, nil
+	case recordedRows:
+		values, err := server.QueryValuesContext(ctx, query)
+		if err != nil {
+			return recordedResult{}, err
+		}
+		return recordedResult{Rows: [][][]byte{values}}, nil
+	case recordedColumn:
+		// The column is prepended to the query, as it is for a recording
+		column, query, _ := strings.Cut(query, "\x00")
+		values, err := server.QueryColumnContext(ctx, query, column)
+		if err != nil {
+			return recordedResult{}, err
+		}
+		return recordedResult{Rows: [][][]byte{values}}, nil
+	default:
+		return recordedResult{}, server.ExecContext(ctx, query)
+	}
+}
+
+// informationSchema answers the queries of information_schema.COLLATIONS and information_schema.CHARACTER_SETS,
+// returning false for any other query.
+func (server *FakeServer) informationSchema(query string) ([][]byte, bool, error) {
+	if values, ok, err := server.informationSchemaCharacterSets(query); ok {
+		return values, true, err
+	}
+	const where = " FROM information_schema.COLLATIONS WHERE COLLATION_NAME = "
+	idx := strings.Index(query, where)
+	if idx == -1 {
+		return nil, false, nil
+	}
+	p := &fakeParser{server: server, query: query, pos: idx + len(where)}
+	name, err := p.expr()
+	if err != nil {
+		return nil, true, err
+	}
+	collation, ok := server.collations[string(name.data)]
+	columns := query[len("SELECT "):idx]
+	if columns == "COUNT(*)" {
+		if ok {
+			return [][]byte{[]byte("1")}, true, nil
+		}
+		return [][]byte{[]byte("0")}, true, nil
+	}
+	if !ok {
+		return nil, true, fmt.Errorf("the following query returned 0 rows instead of 1: %s", query)
+	}
+	yesNo := func(b bool) []byte {
+		if b {
+			return []byte("Yes")
+		}
+		return []byte("")
+	}
+	switch columns {
+	case "PAD_ATTRIBUTE":
+		if collation.PadSpace {
+			return [][]byte{[]byte("PAD SPACE")}, true, nil
+		}
+		return [][]byte{[]byte("NO PAD")}, true, nil
+	case "CHARACTER_SET_NAME, ID, IS_DEFAULT, IS_COMPILED, SORTLEN":
+		return [][]byte{[]byte(collation.Charset), []byte(strconv.Itoa(collation.ID)), yesNo(collation.IsDefault), yesNo(true), []byte("1")}, true, nil
+	default:
+		return nil, true, fmt.Errorf("the fake server does not support the columns `%s`", columns)
+	}
+}
+
+// informationSchemaCharacterSets answers the queries of information_schema.CHARACTER_SETS, returning false for any
+// other query. The description of a character set is its name, and its MAXLEN is the length of its longest encoding.
+func (server *FakeServer) informationSchemaCharacterSets(query string) ([][]byte, bool, error) {
+	const where = " FROM information_schema.CHARACTER_SETS WHERE CHARACTER_SET_NAME = "
+	idx := strings.Index(query, where)
+	if idx == -1 {
+		return nil, false, nil
+	}
+	p := &fakeParser{server: server, query: query, pos: idx + len(where)}
+	name, err := p.expr()
+	if err != nil {
+		return nil, true, err
+	}
+	charset, ok := server.charsets[string(name.data)]
+	if !ok {
+		return nil, true, fmt.Errorf("the following query returned 0 rows instead of 1: %s", query)
+	}
+	if columns := query[len("SELECT "):idx]; columns != "DEFAULT_COLLATE_NAME, DESCRIPTION, MAXLEN" {
+		return nil, true, fmt.Errorf("the fake server does not support the columns `%s`", columns)
+	}
+	defaultCollation := ""
+	for _, collation := range server.collations {
+		if collation.Charset == charset.Name && collation.IsDefault {
+			defaultCollation = collation.Name
+		}
+	}
+	maxLength := 0
+	for _, encoding := range charset.Encodings {
+		if len(encoding) > maxLength {
+			maxLength = len(encoding)
+		}
+	}
+	return [][]byte{[]byte(defaultCollation), []byte(charset.Name), []byte(strconv.Itoa(maxLength))}, true, nil
+}
+
+// encode returns the given runes encoded in the given character set, converting runes without an encoding to '?'.
+func (server *FakeServer) encode(runes []rune, charset string) ([]byte, error) {
+	switch charset {
+	case "utf8mb4", "binary":
+		return []byte(string(runes)), nil
+	}
+	cs, ok := server.charsets[charset]
+	if !ok {
+		return nil, fmt.Errorf("unknown character set `%s`", charset)
+	}
+	var data []byte
+	for _, r := range runes {
+		encoding, ok := cs.Encodings[r]
+		if !ok {
+			encoding = cs.Encodings['?']
+		}
+		data = append(data, encoding...)
+	}
+	return data, nil
+}
+
+// decode returns the runes of the given data within the given character set, decoding invalid data to '?'.
+func (server *FakeServer) decode(data []byte, charset string) ([]rune, error) {
+	switch charset {
+	case "utf8mb4", "binary":
+		var runes []rune
+		for len(data) > 0 {
+			r, size := utf8.DecodeRune(data)
+			if r == utf8.RuneError && size <= 1 {
+				r = '?'
+			}
+			runes = append(runes, r)
+			data = data[size:]
+		}
+		return runes, nil
+	}
+	cs, ok := server.charsets[charset]
+	if !ok {
+		return nil, fmt.Errorf("unknown character set `%s`", charset)
+	}
+	var runes []rune
+	for len(data) > 0 {
+		// Encodings are at most 4 bytes, and the longest matching encoding is used
+		size := 4
+		if size > len(data) {
+			size = len(data)
+		}
+		for ; size > 0; size-- {
+			if r, ok := cs.decodings[string(data[:size])]; ok {
+				runes = append(runes, r)
+				break
+			}
+		}
+		if size == 0 {
+			runes = append(runes, '?')
+			size = 1
+		}
+		data = data[size:]
+	}
+	return runes, nil
+}
+
+// weights returns the weights of the given value using its collation, with the trailing spaces removed for PAD SPACE
+// collations.
+func (server *FakeServer) weights(value fakeValue) ([]uint16, error) {
+	collation, ok := server.collations[value.collation]
+	if !ok {
+		return nil, fmt.Errorf("the fake server requires a known collation for `%s`", value.collation)
+	}
+	runes, err := server.decode(value.data, value.charset)
+	if err != nil {
+		return nil, err
+	}
+	if collation.PadSpace {
+		for len(runes) > 0 && runes[len(runes)-1] == ' ' {
+			runes = runes[:len(runes)-1]
+		}
+	}
+	weights := make([]uint16, 0, len(runes))
+	for _, r := range runes {
+		if collation.Ignorable == nil || !collation.Ignorable(r) {
+			weights = append(weights, collation.Weight(r))
+		}
+	}
+	return weights, nil
+}
+
+// fakeParser evaluates the expressions of a single query.
+type fakeParser struct {
+	server *FakeServer
+	query  string
+	pos    int
+}
+
+// consume advances past the given string when it is next, returning whether it was.
+func (p *fakeParser) consume(str string) bool {
+	if strings.HasPrefix(p.query[p.pos:], str) {
+		p.pos += len(str)
+		return true
+	}
+	return false
+}
+
+// expect advances past the given string, which must be next.
+func (p *fakeParser) expect(str string) error {
+	if !p.consume(str) {
+		return fmt.Errorf("expected `%s` at position %d: %s", str, p.pos, p.query)
+	}
+	return nil
+}
+
+// name returns the name (or number) that is next.
+func (p *fakeParser) name() string {
+	start := p.pos
+	for p.pos < len(p.query) {
+		c := p.query[p.pos]
+		if !(c == '_' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')) {
+			break
+		}
+		p.pos++
+	}
+	return p.query[start:p.pos]
+}
+
+// expr evaluates the expression that is next, along with any COLLATE clause that follows it.
+func (p *fakeParser) expr() (fakeValue, error) {
+	value, err := p.primary()
+	if err != nil {
+		return fakeValue{}, err
+	}
+	if p.consume(" COLLATE ") {
+		value.collation = p.name()
+		if collation, ok := p.server.collations[value.collation]; !ok || collation.Charset != value.charset {
+			return fakeValue{}, fmt.Errorf("collation `%s` is not valid for character set `%s`", value.collation, value.charset)
+		}
+	}
+	return value, nil
+}
+
+// primary evaluates the expression that is next, excluding any COLLATE clause.
+func (p *fakeParser) primary() (fakeValue, error) {
+	if p.consume("_") {
+		charset := p.name()
+		if p.consume(" X''") {
+			return fakeValue{data: []byte{}, charset: charset}, nil
+		}
+		if err := p.expect(" 0x"); err != nil {
+			return fakeValue{}, err
+		}
+		data, err := hex.DecodeString(p.name())
+		if err != nil {
+			return fakeValue{}, err
+		}
+		return fakeValue{data: data, charset: charset}, nil
+	}
+	function := p.name()
+	if function == "" {
+		return fakeValue{}, fmt.Errorf("expected an expression at position %d: %s", p.pos, p.query)
+	}
+	if !p.consume("(") {
+		if _, err := strconv.Atoi(function); err != nil {
+			return fakeValue{}, fmt.Errorf("unknown column `%s`: %s", function, p.query)
+		}
+		return fakeValue{data: []byte(function), charset: "utf8mb4"}, nil
+	}
+	arg, err := p.expr()
+	if err != nil {
+		return fakeValue{}, err
+	}
+	switch function {
+	case "CONVERT":
+		if err = p.expect(" USING "); err != nil {
+			return fakeValue{}, err
+		}
+		charset := p.name()
+		if err = p.expect(")"); err != nil {
+			return fakeValue{}, err
+		}
+		// Binary strings are relabeled rather than converted
+		if arg.charset == "binary" {
+			return fakeValue{data: arg.data, charset: charset}, nil
+		}
+		runes, err := p.server.decode(arg.data, arg.charset)
+		if err != nil {
+			return fakeValue{}, err
+		}
+		data, err := p.server.encode(runes, charset)
+		return fakeValue{data: data, charset: charset}, err
+	case "CAST":
+		if err = p.expect(" AS BINARY)"); err != nil {
+			return fakeValue{}, err
+		}
+		return fakeValue{data: arg.data, charset: "binary"}, nil
+	case "WEIGHT_STRING":
+		if p.consume(" AS CHAR(") {
+			length, err := strconv.Atoi(p.name())
+			if err != nil {
+				return fakeValue{}, err
+			}
+			if err = p.expect(")"); err != nil {
+				return fakeValue{}, err
+			}
+			runes, err := p.server.decode(arg.data, arg.charset)
+			if err != nil {
+				return fakeValue{}, err
+			}
+			if len(runes) > length {
+				runes = runes[:length]
+			}
+			weights, err := p.server.weights(fakeValue{data: []byte(string(runes)), charset: "utf8mb4", collation: arg.collation})
+			if err != nil {
+				return fakeValue{}, err
+			}
+			// PAD SPACE collations pad the weight to the length of the CHAR
+			if p.server.collations[arg.collation].PadSpace {
+				for len(weights) < length {
+					weights = append(weights, p.server.collations[arg.collation].Weight(' '))
+				}
+			}
+			if err = p.expect(")"); err != nil {
+				return fakeValue{}, err
+			}
+			return fakeValue{data: fakeWeightString(weights), charset: "binary"}, nil
+		}
+		if err = p.expect(")"); err != nil {
+			return fakeValue{}, err
+		}
+		weights, err := p.server.weights(arg)
+		return fakeValue{data: fakeWeightString(weights), charset: "binary"}, err
+	}
+	args := []fakeValue{arg}
+	for p.consume(", ") {
+		if arg, err = p.expr(); err != nil {
+			return fakeValue{}, err
+		}
+		args = append(args, arg)
+	}
+	if err = p.expect(")"); err != nil {
+		return fakeValue{}, err
+	}
+	switch {
+	case function == "HEX" && len(args) == 1:
+		return fakeValue{data: []byte(strings.ToUpper(hex.EncodeToString(args[0].data))), charset: "utf8mb4"}, nil
+	case (function == "UPPER" || function == "LOWER") && len(args) == 1:
+		runes, err := p.server.decode(args[0].data, args[0].charset)
+		if err != nil {
+			return fakeValue{}, err
+		}
+		converted := []rune(strings.ToUpper(string(runes)))
+		if function == "LOWER" {
+			converted = []rune(strings.ToLower(string(runes)))
+		}
+		data, err := p.server.encode(converted, args[0].charset)
+		return fakeValue{data: data, charset: args[0].charset}, err
+	case function == "STRCMP" && len(args) == 2:
+		if args[0].collation == "" {
+			args[0].collation = args[1].collation
+		}
+		if args[1].collation == "" {
+			args[1].collation = args[0].collation
+		}
+		left, err := p.server.weights(args[0])
+		if err != nil {
+			return fakeValue{}, err
+		}
+		right, err := p.server.weights(args[1])
+		if err != nil {
+			return fakeValue{}, err
+		}
+		// PAD SPACE collations compare the shorter string as though it were padded with spaces, so that runes sorting
+		// before the space (such as tabs) also sort before a trailing space
+		if collation := p.server.collations[args[0].collation]; collation.PadSpace {
+			for len(left) < len(right) {
+				left = append(left, collation.Weight(' '))
+			}
+			for len(right) < len(left) {
+				right = append(right, collation.Weight(' '))
+			}
+		}
+		return fakeValue{data: []byte(strconv.Itoa(bytes.Compare(fakeWeightString(left), fakeWeightString(right)))), charset: "utf8mb4"}, nil
+	default:
+		return fakeValue{}, fmt.Errorf("the fake server does not support the function `%s` with %d arguments", function, len(args))
+	}
+}
+
+// fakeWeightString returns the weights as big-endian bytes, which compare in the same order as the weights.
+func fakeWeightString(weights []uint16) []byte {
+	data := make([]byte, 2*len(weights))
+	for i, weight := range weights {
+		binary.BigEndian.PutUint16(data[2*i:], weight)
+	}
+	return data
+}
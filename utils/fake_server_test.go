@@ -0,0 +1,56 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeServer(t *testing.T) {
+	ctx := context.Background()
+	server, err := NewFakeServer("8.0.31")
+	require.NoError(t, err)
+	qb := server.Builder()
+
+	values, err := server.QueryValuesContext(ctx, qb.Select(
+		qb.AsBinary(qb.InCharset([]byte("a"), "ascii")),
+		qb.AsBinary(qb.InCharset([]byte("€"), "ascii")),
+		qb.AsBinary(qb.Convert(qb.Convert(qb.Literal("binary", []byte{'z'}), "ascii"), "utf8mb4")),
+		qb.Call("HEX", qb.WeightString(qb.InCollation([]byte("a"), "ascii", "ascii_general_ci"), 3)),
+		qb.Call("STRCMP", qb.InCollation([]byte("a"), "ascii", "ascii_general_ci"), qb.InCollation([]byte("A "), "ascii", "ascii_general_ci")),
+		qb.Call("STRCMP", qb.InCollation([]byte("a"), "ascii", "ascii_bin"), qb.InCollation([]byte("B"), "ascii", "ascii_bin")),
+		qb.Call("UPPER", qb.InCharset([]byte("abc"), "ascii")),
+	))
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("a"), []byte("?"), []byte("z"), []byte("004100200020"), []byte("0"), []byte("1"), []byte("ABC")}, values)
+
+	charsets, err := server.QueryColumnContext(ctx, "SHOW CHARACTER SET;", "Charset")
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("ascii")}, charsets)
+	padAttribute, err := server.QueryContext(ctx, "SELECT PAD_ATTRIBUTE FROM information_schema.COLLATIONS WHERE COLLATION_NAME = "+qb.String("ascii_bin")+";")
+	require.NoError(t, err)
+	assert.Equal(t, "PAD SPACE", string(padAttribute))
+
+	// Anything that the fake server cannot evaluate is an error rather than a guess
+	_, err = server.QueryContext(ctx, qb.Select(qb.Call("SOUNDEX", qb.InCharset([]byte("a"), "ascii"))))
+	assert.Error(t, err)
+	_, err = server.QueryContext(ctx, qb.Select(qb.InCharset([]byte("a"), "unknown")))
+	assert.Error(t, err)
+	assert.Error(t, server.ExecContext(ctx, "DROP TEMPORARY TABLE IF EXISTS `t`;"))
+}
@@ -0,0 +1,30 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"go/format"
+)
+
+// FormatGoFile parses the given generated Go file and returns it formatted by gofmt. Returns an error when the file
+// does not parse, so that malformed output is caught when it is generated rather than when it is added to GMS.
+func FormatGoFile(contents []byte) ([]byte, error) {
+	formatted, err := format.Source(contents)
+	if err != nil {
+		return nil, fmt.Errorf("generated Go file is malformed: %w", err)
+	}
+	return formatted, nil
+}
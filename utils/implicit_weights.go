@@ -0,0 +1,119 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"encoding/binary"
+)
+
+// ImplicitWeightRegion is a range of runes whose generated weights are computed by the formula `rune + Offset`, rather
+// than being listed within the weight map. The UCA 9.0.0 collations compute the weights of the CJK ideographs and the
+// unassigned codepoints from the codepoint itself (known as implicit weights), so these runes sort by their codepoint
+// and may be generated as a handful of regions. A region may contain runes that have other weights (such as assigned
+// runes among the unassigned ones), which the generated function checks before any region.
+type ImplicitWeightRegion struct {
+	Lower  rune
+	Upper  rune
+	Offset int
+}
+
+// implicitWeightRegionCutoff is the minimum number of runes within an ImplicitWeightRegion, as smaller regions are
+// written the same way as any other weights.
+const implicitWeightRegionCutoff = staticWeightRangeCutoff
+
+// ImplicitWeightBase returns the base of the implicit weight that the given weight contains, which is 0xFB40 for the
+// core Han ideographs, 0xFB80 for the other Han ideographs, and 0xFBC0 for unassigned codepoints. The primary level of
+// an implicit weight consists of two elements, `base + (r >> 15)` followed by `(r & 0x7FFF) | 0x8000`. Returns false
+// when the weight's primary level is not the implicit weight of the given rune.
+func ImplicitWeightBase(r rune, weight []byte) (uint16, bool) {
+	if len(weight) < 4 || (len(weight) > 4 && (len(weight) < 6 || weight[4] != 0 || weight[5] != 0)) {
+		return 0, false
+	}
+	if binary.BigEndian.Uint16(weight[2:4]) != uint16(r&0x7FFF)|0x8000 {
+		return 0, false
+	}
+	base := binary.BigEndian.Uint16(weight[0:2]) - uint16(r>>15)
+	switch base {
+	case 0xFB40, 0xFB80, 0xFBC0:
+		return base, true
+	default:
+		return 0, false
+	}
+}
+
+// FindImplicitWeightRegions returns a copy of the RuneComparator in which the runes with implicit weights (see
+// ImplicitWeightBase) have the weight `rune + Offset` of their ImplicitWeightRegion, along with the regions. A region is
+// a run of adjacent rows, each holding a single rune with the same implicit weight base, whose runes ascend. Empty rows
+// are inserted for the codepoints that a region skips, which keeps the order while allowing the weights of the region
+// to be computed. The weights are the WEIGHT_STRING output of each rune. The RuneComparator is returned unchanged when
+// there are no regions. As the weights of the copy differ from the original, every file of a collation should be
+// generated from the same RuneComparator.
+func FindImplicitWeightRegions(rc *RuneComparator, weights map[rune][]byte) (*RuneComparator, []ImplicitWeightRegion) {
+	rows := rc.rows()
+	// Each run is the index of its first row along with the index following its last row
+	var runs [][2]int
+	runStart := -1
+	var runBase uint16
+	for i := 0; i <= len(rows); i++ {
+		var base uint16
+		ok := i < len(rows) && len(rows[i]) == 1
+		if ok {
+			base, ok = ImplicitWeightBase(rows[i][0], weights[rows[i][0]])
+		}
+		if runStart != -1 && ok && base == runBase && rows[i][0] > rows[i-1][0] {
+			continue
+		}
+		if runStart != -1 && i-runStart >= implicitWeightRegionCutoff {
+			runs = append(runs, [2]int{runStart, i})
+		}
+		runStart = -1
+		if ok {
+			runStart = i
+			runBase = base
+		}
+	}
+	if len(runs) == 0 {
+		return rc, nil
+	}
+
+	newRows := make([][]rune, 0, len(rows))
+	regions := make([]ImplicitWeightRegion, 0, len(runs))
+	for i := 0; i < len(rows); i++ {
+		if len(regions) == len(runs) || i != runs[len(regions)][0] {
+			newRows = append(newRows, rows[i])
+			continue
+		}
+		run := runs[len(regions)]
+		region := ImplicitWeightRegion{
+			Lower:  rows[run[0]][0],
+			Upper:  rows[run[1]-1][0],
+			Offset: len(newRows) - int(rows[run[0]][0]),
+		}
+		for ; i < run[1]; i++ {
+			for len(newRows)-region.Offset < int(rows[i][0]) {
+				newRows = append(newRows, nil)
+			}
+			newRows = append(newRows, rows[i])
+		}
+		i--
+		regions = append(regions, region)
+	}
+	return NewRuneComparatorFromOrder(newRows), regions
+}
+
+// contains returns whether the given rune has the weight of the region.
+func (region ImplicitWeightRegion) contains(r rune, weight int) bool {
+	return r >= region.Lower && r <= region.Upper && weight == int(r)+region.Offset
+}
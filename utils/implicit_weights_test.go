@@ -0,0 +1,96 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// implicitWeight returns the primary level of the implicit weight of the given rune.
+func implicitWeight(r rune, base uint16) []byte {
+	weight := make([]byte, 4)
+	binary.BigEndian.PutUint16(weight, base+uint16(r>>15))
+	binary.BigEndian.PutUint16(weight[2:], uint16(r&0x7FFF)|0x8000)
+	return weight
+}
+
+func TestImplicitWeightBase(t *testing.T) {
+	base, ok := ImplicitWeightBase(0x4E00, implicitWeight(0x4E00, 0xFB40))
+	assert.True(t, ok)
+	assert.Equal(t, uint16(0xFB40), base)
+	base, ok = ImplicitWeightBase(0x10FFFD, append(implicitWeight(0x10FFFD, 0xFBC0), 0, 0, 0, 0x20))
+	assert.True(t, ok)
+	assert.Equal(t, uint16(0xFBC0), base)
+	_, ok = ImplicitWeightBase(0x4E01, implicitWeight(0x4E00, 0xFB40))
+	assert.False(t, ok)
+	_, ok = ImplicitWeightBase(0x4E00, append(implicitWeight(0x4E00, 0xFB40), 0x12, 0x34))
+	assert.False(t, ok)
+	_, ok = ImplicitWeightBase('a', []byte{0x1C, 0x47})
+	assert.False(t, ok)
+}
+
+func TestFindImplicitWeightRegions(t *testing.T) {
+	// `丐` (U+4E10) has a table weight that sorts among the Latin runes, so it's skipped by the ideographs, while the
+	// replacement character sorts after every implicit weight
+	weights := map[rune][]byte{'a': {0x1C, 0x47}, 0x4E10: {0x1C, 0x48}, 'b': {0x1C, 0x60}, 0xFFFD: {0xFF, 0xFD}}
+	order := [][]rune{{'a'}, {0x4E10}, {'b'}}
+	for r := rune(0x4E00); r < 0x4F00; r++ {
+		if r != 0x4E10 {
+			weights[r] = implicitWeight(r, 0xFB40)
+			order = append(order, []rune{r})
+		}
+	}
+	for r := rune(0x0378); r < 0x0380; r++ {
+		weights[r] = implicitWeight(r, 0xFBC0)
+		order = append(order, []rune{r})
+	}
+	for r := rune(0xE0000); r < 0xE0100; r++ {
+		weights[r] = implicitWeight(r, 0xFBC0)
+		order = append(order, []rune{r})
+	}
+	order = append(order, []rune{0xFFFD})
+	rc := NewRuneComparatorFromOrder(order)
+
+	implicitRC, regions := FindImplicitWeightRegions(rc, weights)
+	// The unassigned runes sort by codepoint across every plane, so they form a single region
+	require.Equal(t, []ImplicitWeightRegion{
+		{Lower: 0x4E00, Upper: 0x4EFF, Offset: 3 - 0x4E00},
+		{Lower: 0x0378, Upper: 0xE00FF, Offset: 3 + 0x100 - 0x0378},
+	}, regions)
+	assert.Equal(t, 3+0x100+(0xE00FF-0x0378+1)+1, implicitRC.Len())
+
+	contents := RuneComparatorToGoFileWithOptions(implicitRC, "utf8mb4_0900_ai_ci", true, RuneComparatorGoFileOptions{ImplicitWeights: regions})
+	assert.Equal(t, 2, strings.Count(contents, "// Implicit weights"))
+	runeWeights, err := ParseRuneComparatorGoFile(contents)
+	require.NoError(t, err)
+	var runes []rune
+	for _, row := range order {
+		runes = append(runes, row...)
+	}
+	assert.Equal(t, order, runeWeights.Order(runes))
+	assert.Equal(t, int32(1), runeWeights.Weight(0x4E10))
+	assert.Equal(t, -1, runeWeights.Compare("b", "一"))
+	assert.Equal(t, -1, runeWeights.Compare("\U000E00FF", "�"))
+
+	// Weights without any implicit weights are returned unchanged
+	unchanged, regions := FindImplicitWeightRegions(rc, map[rune][]byte{})
+	assert.Same(t, rc, unchanged)
+	assert.Empty(t, regions)
+}
@@ -0,0 +1,277 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// LDMLFile is a character set definition file from MySQL's share/charsets directory, which is either Index.xml or the
+// definition of a single simple character set (such as latin1.xml). Simple collations are defined by a map of sort
+// weights, while collations that tailor the Unicode Collation Algorithm are defined by LDML rules.
+type LDMLFile struct {
+	Charsets []LDMLCharset `xml:"charset"`
+}
+
+// LDMLCharset is a single character set within an LDMLFile.
+type LDMLCharset struct {
+	Name string `xml:"name,attr"`
+	// ToUnicode contains the codepoint of each of the 256 bytes as hexadecimal values separated by whitespace. This is
+	// only defined for simple (single byte) character sets.
+	ToUnicode  string          `xml:"unicode>map"`
+	Collations []LDMLCollation `xml:"collation"`
+}
+
+// LDMLCollation is a single collation within an LDMLCharset.
+type LDMLCollation struct {
+	Name string `xml:"name,attr"`
+	ID   int    `xml:"id,attr"`
+	// Map contains the sort weight of each of the 256 bytes as hexadecimal values separated by whitespace. This is only
+	// defined for the collations of simple character sets.
+	Map   string    `xml:"map"`
+	Rules LDMLRules `xml:"rules"`
+}
+
+// LDMLRules are the tailoring rules of a collation, in the order that they're defined.
+type LDMLRules struct {
+	Rules []LDMLRule `xml:",any"`
+}
+
+// LDMLRule is a single tailoring rule. The rule's element name is its kind: "reset" sets the rune that the following
+// rules are relative to, "p", "s", and "t" sort a rune after the previous rune with a primary, secondary, or tertiary
+// difference, and "i" makes a rune identical to the previous rune. The "pc", "sc", "tc", and "ic" kinds apply the rule
+// to each rune of the text in turn.
+type LDMLRule struct {
+	XMLName xml.Name
+	Before  string `xml:"before,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// LoadLDMLFile reads the character set definition file at the given path.
+func LoadLDMLFile(path string) (*LDMLFile, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := ParseLDMLFile(contents)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse `%s`: %w", path, err)
+	}
+	return file, nil
+}
+
+// ParseLDMLFile parses the contents of a character set definition file.
+func ParseLDMLFile(contents []byte) (*LDMLFile, error) {
+	file := &LDMLFile{}
+	if err := xml.Unmarshal(contents, file); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// Collation returns the given collation along with its character set.
+func (file *LDMLFile) Collation(name string) (LDMLCharset, LDMLCollation, bool) {
+	for _, charset := range file.Charsets {
+		for _, collation := range charset.Collations {
+			if collation.Name == name {
+				return charset, collation, true
+			}
+		}
+	}
+	return LDMLCharset{}, LDMLCollation{}, false
+}
+
+// SimpleRuneComparator returns the RuneComparator of a simple collation, which assigns a sort weight to each byte of
+// the character set. Bytes that share a sort weight are equal, and bytes without a codepoint (other than the first
+// byte, which is always U+0000) are skipped.
+func (charset LDMLCharset) SimpleRuneComparator(collation LDMLCollation) (*RuneComparator, error) {
+	codepoints, err := parseLDMLHexValues(charset.ToUnicode)
+	if err != nil {
+		return nil, fmt.Errorf("character set `%s` has an invalid unicode map: %w", charset.Name, err)
+	}
+	weights, err := parseLDMLHexValues(collation.Map)
+	if err != nil {
+		return nil, fmt.Errorf("collation `%s` has an invalid map: %w", collation.Name, err)
+	}
+	if len(codepoints) != 256 || len(weights) != 256 {
+		return nil, fmt.Errorf("collation `%s` must map 256 bytes, but found %d codepoints and %d weights",
+			collation.Name, len(codepoints), len(weights))
+	}
+	runesByWeight := make(map[int][]rune)
+	seen := make(map[rune]bool)
+	for b := 0; b < 256; b++ {
+		r := rune(codepoints[b])
+		if (r == 0 && b != 0) || seen[r] {
+			continue
+		}
+		seen[r] = true
+		runesByWeight[weights[b]] = append(runesByWeight[weights[b]], r)
+	}
+	sortedWeights := make([]int, 0, len(runesByWeight))
+	for weight := range runesByWeight {
+		sortedWeights = append(sortedWeights, weight)
+	}
+	sort.Ints(sortedWeights)
+	order := make([][]rune, len(sortedWeights))
+	for i, weight := range sortedWeights {
+		order[i] = runesByWeight[weight]
+		sortRunes(order[i])
+	}
+	return NewRuneComparatorFromOrder(order), nil
+}
+
+// ApplyLDMLRules returns a copy of the base RuneComparator with the given tailoring rules applied. A RuneComparator
+// only represents a single level of comparison, so secondary, tertiary, and identical differences are all treated as
+// equal to the previous rune, which matches the primary comparisons of case and accent insensitive collations. Rules
+// that reset before a rune, and rules that contain contractions or expansions, are not supported.
+func ApplyLDMLRules(base *RuneComparator, rules LDMLRules) (*RuneComparator, error) {
+	order := make([][]rune, base.Len())
+	index := make(map[rune]int)
+	for i, runes := range base.rows() {
+		order[i] = append([]rune{}, runes...)
+		for _, r := range runes {
+			index[r] = i
+		}
+	}
+	// Moves the rune into the group at the given index, or into a new group at that index when newGroup is true, and
+	// updates the index of every rune after it
+	move := func(r rune, to int, newGroup bool) {
+		if from, ok := index[r]; ok {
+			group := order[from]
+			for i, groupRune := range group {
+				if groupRune == r {
+					order[from] = append(group[:i:i], group[i+1:]...)
+					break
+				}
+			}
+			if len(order[from]) == 0 {
+				order = append(order[:from], order[from+1:]...)
+				if to > from {
+					to--
+				}
+			}
+		}
+		if newGroup {
+			order = append(order, nil)
+			copy(order[to+1:], order[to:])
+			order[to] = []rune{r}
+		} else {
+			order[to] = append(order[to], r)
+			sortRunes(order[to])
+		}
+		for i := range order {
+			for _, groupRune := range order[i] {
+				index[groupRune] = i
+			}
+		}
+	}
+
+	anchor := rune(-1)
+	for _, rule := range rules.Rules {
+		runes, err := decodeLDMLText(rule.Text)
+		if err != nil {
+			return nil, err
+		}
+		kind := rule.XMLName.Local
+		if rule.Before != "" {
+			return nil, fmt.Errorf("rule `%s` resets before `%s`, which is not supported", kind, rule.Text)
+		}
+		// The multiple rune kinds are equivalent to a rule for each rune
+		if len(kind) == 2 && strings.HasSuffix(kind, "c") {
+			kind = kind[:1]
+		} else if len(runes) != 1 {
+			return nil, fmt.Errorf("rule `%s` contains `%s`, but contractions and expansions are not supported", kind, rule.Text)
+		}
+		for _, r := range runes {
+			if kind == "reset" {
+				if _, ok := index[r]; !ok {
+					return nil, fmt.Errorf("reset rune `%s` (%d) is not in the base collation", string(r), r)
+				}
+				anchor = r
+				continue
+			}
+			if anchor == -1 {
+				return nil, fmt.Errorf("rule `%s` for `%s` does not follow a reset", kind, string(r))
+			}
+			switch kind {
+			case "p":
+				move(r, index[anchor]+1, true)
+			case "s", "t", "i":
+				move(r, index[anchor], false)
+			default:
+				return nil, fmt.Errorf("unknown rule `%s`", kind)
+			}
+			anchor = r
+		}
+	}
+	return NewRuneComparatorFromOrder(order), nil
+}
+
+// parseLDMLHexValues parses hexadecimal values that are separated by whitespace.
+func parseLDMLHexValues(text string) ([]int, error) {
+	fields := strings.Fields(text)
+	values := make([]int, len(fields))
+	for i, field := range fields {
+		value, err := strconv.ParseUint(field, 16, 32)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = int(value)
+	}
+	return values, nil
+}
+
+// decodeLDMLText returns the runes of a rule's text, which may contain literal runes along with the \uXXXX and
+// \UXXXXXXXX escapes. Whitespace is ignored, as literal whitespace must be escaped.
+func decodeLDMLText(text string) ([]rune, error) {
+	var runes []rune
+	for i := 0; i < len(text); {
+		if text[i] == '\\' && i+1 < len(text) && (text[i+1] == 'u' || text[i+1] == 'U') {
+			length := 4
+			if text[i+1] == 'U' {
+				length = 8
+			}
+			if i+2+length > len(text) {
+				return nil, fmt.Errorf("text `%s` contains a truncated escape", text)
+			}
+			value, err := strconv.ParseUint(text[i+2:i+2+length], 16, 32)
+			if err != nil || !utf8.ValidRune(rune(value)) {
+				return nil, fmt.Errorf("text `%s` contains an invalid escape", text)
+			}
+			runes = append(runes, rune(value))
+			i += 2 + length
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if r != ' ' && r != '\t' && r != '\n' && r != '\r' {
+			runes = append(runes, r)
+		}
+		i += size
+	}
+	return runes, nil
+}
+
+// sortRunes sorts the given runes in sequential order.
+func sortRunes(runes []rune) {
+	sort.Slice(runes, func(i, j int) bool {
+		return runes[i] < runes[j]
+	})
+}
@@ -0,0 +1,66 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// LossyMapping is a rune that encodes to a codepoint of a character set, yet the codepoint decodes to a different rune.
+// This occurs when multiple runes encode to the same codepoint, where the server prefers one of them when decoding.
+type LossyMapping struct {
+	Rune     rune
+	Encoding []byte
+	// Preferred is the rune that the encoding decodes to.
+	Preferred rune
+}
+
+// String returns a description of the mapping.
+func (lm LossyMapping) String() string {
+	return fmt.Sprintf("`%s` (U+%04X) encodes to 0x%X, which decodes to `%s` (U+%04X)",
+		string(lm.Rune), lm.Rune, lm.Encoding, string(lm.Preferred), lm.Preferred)
+}
+
+// LossyMappingsToGoFile returns the given lossy mappings as a Go file for inclusion in an application, alongside the file
+// that RangeMapToGoFile generated for the same character set. The RangeMap only contains the preferred rune of each
+// codepoint, so an encoder may use the generated map for runes that the RangeMap does not contain, matching the server's
+// best-fit encoding.
+func LossyMappingsToGoFile(name string, mappings []LossyMapping, options CodegenOptions) string {
+	_, lowerName := options.names(name)
+	sortedMappings := make([]LossyMapping, len(mappings))
+	copy(sortedMappings, mappings)
+	sort.Slice(sortedMappings, func(i, j int) bool {
+		return sortedMappings[i].Rune < sortedMappings[j].Rune
+	})
+
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf(`%s
+
+// %s_LossyEncodings contains the runes that the %s character set encodes to a codepoint which decodes
+// to a different (preferred) rune. Decoding always returns the preferred rune, so encoding these runes is lossy.
+var %s_LossyEncodings = map[rune][]byte{
+`, options.fileHeader(), lowerName, "`"+lowerName+"`", lowerName))
+	for _, lm := range sortedMappings {
+		encoding := make([]string, len(lm.Encoding))
+		for i, b := range lm.Encoding {
+			encoding[i] = fmt.Sprintf("0x%02X", b)
+		}
+		sb.WriteString(fmt.Sprintf("\t%d: {%s}, // %q -> %q\n", lm.Rune, strings.Join(encoding, ", "), string(lm.Rune), string(lm.Preferred)))
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
@@ -0,0 +1,139 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+)
+
+// Manifest records every artifact that has been extracted into an output directory, along with how each artifact was
+// extracted.
+type Manifest struct {
+	Entries []ManifestEntry `json:"entries"`
+}
+
+// ManifestEntry is a single artifact within a Manifest.
+type ManifestEntry struct {
+	Name     string             `json:"name"`
+	Kind     string             `json:"kind"`
+	File     string             `json:"file"`
+	Strategy ExtractionStrategy `json:"strategy,omitempty"`
+	Base     string             `json:"base,omitempty"`
+	// Model is the file containing the Model that the artifact was generated from.
+	Model string `json:"model,omitempty"`
+	// Shares is the name of the collation whose generated weights are used by this collation, as their weights are
+	// identical.
+	Shares string `json:"shares,omitempty"`
+	// SharedWeights is the file containing the SharedWeightTables that this collation's generated weights reference.
+	SharedWeights string `json:"shared_weights,omitempty"`
+	// Delta is the name of the collation that this collation's generated weights are a delta from.
+	Delta string `json:"delta,omitempty"`
+	// Unicode is the Unicode version that the artifact's runes were pinned to. Empty when every rune was iterated over.
+	Unicode string `json:"unicode,omitempty"`
+	// Metadata contains the properties of a collation as reported by the server. Nil for character sets.
+	Metadata *CollationMetadata `json:"metadata,omitempty"`
+}
+
+const (
+	// ManifestKindCharset is the kind of a ManifestEntry for a character set.
+	ManifestKindCharset = "charset"
+	// ManifestKindCollation is the kind of a ManifestEntry for a collation.
+	ManifestKindCollation = "collation"
+)
+
+// LoadManifest reads the Manifest at the given path. Returns an empty Manifest if the file does not exist.
+func LoadManifest(path string) (*Manifest, error) {
+	contents, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Manifest{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	manifest := &Manifest{}
+	if err = json.Unmarshal(contents, manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// Set adds the given entry, replacing any existing entry with the same name and kind.
+func (m *Manifest) Set(entry ManifestEntry) {
+	for i, existing := range m.Entries {
+		if existing.Name == entry.Name && existing.Kind == entry.Kind {
+			m.Entries[i] = entry
+			return
+		}
+	}
+	m.Entries = append(m.Entries, entry)
+}
+
+// CheckFlavor returns an error when the manifest contains a collation of the same name as the given entry that was
+// extracted from a different server flavor. MariaDB collations may share the name of a MySQL collation while differing
+// in their weights, and as the files and identifiers of a collation are named after the collation alone, both cannot
+// be placed within the same output directory. Entries without metadata are assumed to be from MySQL.
+func (m *Manifest) CheckFlavor(entry ManifestEntry) error {
+	existing, ok := m.Get(entry.Name, entry.Kind)
+	if !ok || entry.Kind != ManifestKindCollation {
+		return nil
+	}
+	if existingFlavor, flavor := existing.flavor(), entry.flavor(); existingFlavor != flavor {
+		return fmt.Errorf("`%s` was already extracted from %s, so the %s collation must be extracted into a separate output directory",
+			entry.Name, existingFlavor, flavor)
+	}
+	return nil
+}
+
+// Get returns the entry with the given name and kind. Returns false if the entry does not exist.
+func (m *Manifest) Get(name string, kind string) (ManifestEntry, bool) {
+	for _, entry := range m.Entries {
+		if entry.Name == name && entry.Kind == kind {
+			return entry, true
+		}
+	}
+	return ManifestEntry{}, false
+}
+
+// flavor returns the server flavor that the entry was extracted from.
+func (entry ManifestEntry) flavor() ServerFlavor {
+	if entry.Metadata == nil || entry.Metadata.Flavor == "" {
+		return ServerFlavorMySQL
+	}
+	return entry.Metadata.Flavor
+}
+
+// Save writes the Manifest to the given path. Entries are sorted so that the file is stable between runs.
+func (m *Manifest) Save(path string) error {
+	sortManifestEntries(m.Entries)
+	contents, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(contents, '\n'), 0644)
+}
+
+// sortManifestEntries sorts the given entries by their kind, and then by their name.
+func sortManifestEntries(entries []ManifestEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Kind != entries[j].Kind {
+			return entries[i].Kind < entries[j].Kind
+		}
+		return entries[i].Name < entries[j].Name
+	})
+}
@@ -0,0 +1,304 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+)
+
+// ModelVersion is the version of the Model format. It is incremented whenever a change to the Model would cause an
+// older Model to be read incorrectly, and Models with a newer version are rejected.
+const ModelVersion = 1
+
+// Model is the intermediate result of an extraction, containing everything that was retrieved from the server before
+// any code generation decisions were made. Files may be generated from a saved Model without connecting to a server,
+// which allows code generation to be changed and files re-emitted in seconds rather than hours. Models saved with a
+// ".json" extension are written as a JSON document, which may be read by other tools, while all other Models use gob.
+type Model struct {
+	// Version is the ModelVersion that the Model was created with.
+	Version int
+	Name    string
+	// Kind is either ManifestKindCharset or ManifestKindCollation, which determines the fields that are set.
+	Kind string
+	// Encodings maps each of the character set's encodings to its UTF8 encoding.
+	Encodings [][2][]byte
+	// ToUpper and ToLower contain the case conversions that produce a single rune, while MultiRuneToUpper and
+	// MultiRuneToLower contain every other case conversion. Use CaseMappings to retrieve all of them together.
+	ToUpper          [][2]rune
+	ToLower          [][2]rune
+	MultiRuneToUpper map[rune]string
+	MultiRuneToLower map[rune]string
+	// Weights contains the ordering of a RuneComparator, where the index of each rune slice is its weight.
+	Weights  [][]rune
+	PadSpace bool
+	// Ignorables contains the runes that a collation ignores entirely, which are also within the Weights. Nil for Models
+	// whose ignorable runes were not detected.
+	Ignorables []rune
+	// Provenance records the server that the Model was extracted from. Nil for Models saved before it was recorded.
+	Provenance *Provenance
+}
+
+// NewCharacterSetModel returns a Model for the given character set.
+func NewCharacterSetModel(name string, rangeMap *RangeMap, caseMappings CaseMappings) *Model {
+	toUpper, toLower := caseMappings.Conversions()
+	multiRune := caseMappings.MultiRune()
+	model := &Model{
+		Version:          ModelVersion,
+		Name:             name,
+		Kind:             ManifestKindCharset,
+		ToUpper:          toUpper,
+		ToLower:          toLower,
+		MultiRuneToUpper: multiRune.ToUpper,
+		MultiRuneToLower: multiRune.ToLower,
+	}
+	model.Encodings = encodingTreeEntries(rangeMap.Tree())
+	return model
+}
+
+// CaseMappings returns every case conversion of a character set's Model.
+func (m *Model) CaseMappings() CaseMappings {
+	caseMappings := caseMappingsFromConversions(m.ToUpper, m.ToLower)
+	caseMappings.Merge(CaseMappings{ToUpper: m.MultiRuneToUpper, ToLower: m.MultiRuneToLower})
+	return caseMappings
+}
+
+// NewCollationModel returns a Model for the given collation.
+func NewCollationModel(name string, rc *RuneComparator, padSpace bool) *Model {
+	return &Model{
+		Version:  ModelVersion,
+		Name:     name,
+		Kind:     ManifestKindCollation,
+		Weights:  rc.rows(),
+		PadSpace: padSpace,
+	}
+}
+
+// modelJSON is the JSON document of a Model. Encodings are written as hexadecimal strings alongside the rune that they
+// decode to, so that the document may be read without knowledge of the encoding tree.
+type modelJSON struct {
+	Version   int                 `json:"version"`
+	Name      string              `json:"name"`
+	Kind      string              `json:"kind"`
+	Encodings []modelJSONEncoding `json:"encodings,omitempty"`
+	ToUpper   [][2]rune           `json:"to_upper,omitempty"`
+	ToLower   [][2]rune           `json:"to_lower,omitempty"`
+	// The multi-rune conversions are keyed by the decimal rune, as JSON object keys must be strings
+	MultiRuneToUpper map[rune]string `json:"multi_rune_to_upper,omitempty"`
+	MultiRuneToLower map[rune]string `json:"multi_rune_to_lower,omitempty"`
+	Weights          [][]rune        `json:"weights,omitempty"`
+	PadSpace         bool            `json:"pad_space,omitempty"`
+	Ignorables       []rune          `json:"ignorables,omitempty"`
+	Provenance       *Provenance     `json:"provenance,omitempty"`
+}
+
+// modelJSONEncoding is a single encoding of a character set within a modelJSON.
+type modelJSONEncoding struct {
+	Encoding string `json:"encoding"`
+	Rune     rune   `json:"rune"`
+}
+
+// LoadModel reads the Model at the given path, which is decoded as JSON when the path has a ".json" extension. Models
+// from before versioning was added are read as version 1.
+func LoadModel(path string) (*Model, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	model := &Model{}
+	if filepath.Ext(path) == ".json" {
+		model, err = modelFromJSON(contents)
+	} else {
+		err = gob.NewDecoder(bytes.NewReader(contents)).Decode(model)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode the model at `%s`: %w", path, err)
+	}
+	if model.Version == 0 {
+		model.Version = 1
+	}
+	if model.Version > ModelVersion {
+		return nil, fmt.Errorf("the model at `%s` has version %d, which is newer than the supported version %d",
+			path, model.Version, ModelVersion)
+	}
+	return model, nil
+}
+
+// Save writes the Model to the given path, which is encoded as JSON when the path has a ".json" extension.
+func (m *Model) Save(path string) error {
+	if filepath.Ext(path) == ".json" {
+		contents, err := m.JSON()
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(path, contents, 0644)
+	}
+	buf := bytes.Buffer{}
+	if err := gob.NewEncoder(&buf).Encode(m); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// JSON returns the Model as an indented JSON document.
+func (m *Model) JSON() ([]byte, error) {
+	doc := modelJSON{
+		Version:          m.Version,
+		Name:             m.Name,
+		Kind:             m.Kind,
+		ToUpper:          m.ToUpper,
+		ToLower:          m.ToLower,
+		MultiRuneToUpper: m.MultiRuneToUpper,
+		MultiRuneToLower: m.MultiRuneToLower,
+		Weights:          m.Weights,
+		PadSpace:         m.PadSpace,
+		Ignorables:       m.Ignorables,
+		Provenance:       m.Provenance,
+	}
+	for _, encoding := range m.Encodings {
+		r, size := utf8.DecodeRune(encoding[1])
+		if r == utf8.RuneError || size != len(encoding[1]) {
+			return nil, fmt.Errorf("model `%s` maps %X to %X, which is not a single rune", m.Name, encoding[0], encoding[1])
+		}
+		doc.Encodings = append(doc.Encodings, modelJSONEncoding{Encoding: hex.EncodeToString(encoding[0]), Rune: r})
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// modelFromJSON returns the Model of the given JSON document, which was returned by JSON.
+func modelFromJSON(contents []byte) (*Model, error) {
+	doc := modelJSON{}
+	if err := json.Unmarshal(contents, &doc); err != nil {
+		return nil, err
+	}
+	model := &Model{
+		Version:          doc.Version,
+		Name:             doc.Name,
+		Kind:             doc.Kind,
+		ToUpper:          doc.ToUpper,
+		ToLower:          doc.ToLower,
+		MultiRuneToUpper: doc.MultiRuneToUpper,
+		MultiRuneToLower: doc.MultiRuneToLower,
+		Weights:          doc.Weights,
+		PadSpace:         doc.PadSpace,
+		Ignorables:       doc.Ignorables,
+		Provenance:       doc.Provenance,
+	}
+	for _, encoding := range doc.Encodings {
+		encoded, err := hex.DecodeString(encoding.Encoding)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encoding `%s`: %w", encoding.Encoding, err)
+		}
+		model.Encodings = append(model.Encodings, [2][]byte{encoded, []byte(string(encoding.Rune))})
+	}
+	return model, nil
+}
+
+// RangeMap returns the RangeMap of a character set's Model.
+func (m *Model) RangeMap() (*RangeMap, error) {
+	return m.RangeMapWithConsolidation("")
+}
+
+// RangeMapWithConsolidation returns the RangeMap of a character set's Model, whose ranges are merged using the given
+// strategy rather than the character set's default.
+func (m *Model) RangeMapWithConsolidation(strategy ConsolidationStrategy) (*RangeMap, error) {
+	tree, err := m.EncodingTree()
+	if err != nil {
+		return nil, err
+	}
+	options := CharacterSetQuirksFor(m.Name).RangeMapOptions
+	if strategy != "" {
+		options.Consolidation = strategy
+	}
+	return RangeMapFromTreeWithOptions(tree, options)
+}
+
+// EncodingTree returns the encodings of a character set's Model as a tree.
+func (m *Model) EncodingTree() (*CharacterSetEncodingTree, error) {
+	if m.Kind != ManifestKindCharset {
+		return nil, fmt.Errorf("model `%s` is a %s rather than a %s", m.Name, m.Kind, ManifestKindCharset)
+	}
+	tree, err := encodingTreeFromEntries(m.Encodings)
+	if err != nil {
+		return nil, fmt.Errorf("model `%s` %w", m.Name, err)
+	}
+	return tree, nil
+}
+
+// RuneComparator returns the RuneComparator of a collation's Model.
+func (m *Model) RuneComparator() (*RuneComparator, error) {
+	if m.Kind != ManifestKindCollation {
+		return nil, fmt.Errorf("model `%s` is a %s rather than a %s", m.Name, m.Kind, ManifestKindCollation)
+	}
+	return NewRuneComparatorFromOrder(m.Weights), nil
+}
+
+// GoFile returns the Go file for the Model, which matches the file that the extraction generated. The header records
+// the Model's provenance, so the file is identical every time that the same Model is generated.
+func (m *Model) GoFile() (string, error) {
+	return m.GoFileWithOptions(CodegenOptions{Provenance: m.Provenance})
+}
+
+// GoFileWithOptions returns the same file as GoFile, modified by the given options.
+func (m *Model) GoFileWithOptions(options CodegenOptions) (string, error) {
+	switch m.Kind {
+	case ManifestKindCharset:
+		rangeMap, err := m.RangeMap()
+		if err != nil {
+			return "", err
+		}
+		return RangeMapToGoFileWithOptions(rangeMap, m.CaseMappings(), m.Name, options), nil
+	case ManifestKindCollation:
+		rc, err := m.RuneComparator()
+		if err != nil {
+			return "", err
+		}
+		return RuneComparatorToGoFileWithOptions(rc, m.Name, m.PadSpace, RuneComparatorGoFileOptions{Codegen: options, Ignorables: m.Ignorables}), nil
+	default:
+		return "", fmt.Errorf("model `%s` has the unknown kind `%s`", m.Name, m.Kind)
+	}
+}
+
+// encodingTreeEntries returns every encoding within the tree, where the first encoding of each entry is the character
+// set's encoding and the second is the UTF8 encoding.
+func encodingTreeEntries(tree *CharacterSetEncodingTree) [][2][]byte {
+	var entries [][2][]byte
+	iter := tree.Iterator()
+	for inputEncoding, outputEncoding, ok := iter.Next(); ok; inputEncoding, outputEncoding, ok = iter.Next() {
+		entries = append(entries, [2][]byte{inputEncoding, outputEncoding})
+	}
+	return entries
+}
+
+// encodingTreeFromEntries returns the tree containing the given entries, which were returned by encodingTreeEntries.
+func encodingTreeFromEntries(entries [][2][]byte) (*CharacterSetEncodingTree, error) {
+	tree := NewCharacterSetEncodingTree()
+	for _, encoding := range entries {
+		node := tree
+		for _, val := range encoding[0] {
+			node = node.AddChild(val)
+		}
+		if !node.SetData(encoding[1]) {
+			return nil, fmt.Errorf("contains conflicting encodings for %v", encoding[0])
+		}
+	}
+	return tree, nil
+}
@@ -0,0 +1,225 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ModelDiff contains every difference between two Models of the same kind, such as the same character set extracted
+// from MySQL and from Dolt. The first Model is the left side.
+type ModelDiff struct {
+	Encodings []EncodingTreeDifference `json:"encodings,omitempty"`
+	ToUpper   []CaseMappingDifference  `json:"to_upper,omitempty"`
+	ToLower   []CaseMappingDifference  `json:"to_lower,omitempty"`
+	Weights   []WeightDifference       `json:"weights,omitempty"`
+	// PadSpace is set when only one of the collations is PAD SPACE.
+	PadSpace bool `json:"pad_space,omitempty"`
+}
+
+// CaseMappingDifference is a rune whose case conversion differs between two Models. A rune without a conversion
+// converts to itself.
+type CaseMappingDifference struct {
+	Rune  rune   `json:"rune"`
+	Left  string `json:"left"`
+	Right string `json:"right"`
+}
+
+// String returns the difference as a human-readable string.
+func (diff CaseMappingDifference) String() string {
+	return fmt.Sprintf("`%s` (U+%04X) converted to %q, and now converts to %q", string(diff.Rune), diff.Rune, diff.Left, diff.Right)
+}
+
+// WeightDifference is a rune whose position differs between the orderings of two collations. The position of a rune is
+// the group of runes that it is equal to (its own row) along with the row that sorts immediately before it, so that a
+// single misplaced rune only reports the runes around it rather than every rune that sorts after it. Only runes that
+// are in both orderings are compared, and a nil class means that the rune is missing from that side.
+type WeightDifference struct {
+	Rune          rune   `json:"rune"`
+	LeftClass     []rune `json:"left_class"`
+	RightClass    []rune `json:"right_class"`
+	LeftPrevious  []rune `json:"left_previous"`
+	RightPrevious []rune `json:"right_previous"`
+}
+
+// String returns the difference as a human-readable string.
+func (diff WeightDifference) String() string {
+	switch {
+	case diff.LeftClass == nil:
+		return fmt.Sprintf("`%s` (U+%04X) was added", string(diff.Rune), diff.Rune)
+	case diff.RightClass == nil:
+		return fmt.Sprintf("`%s` (U+%04X) was removed", string(diff.Rune), diff.Rune)
+	default:
+		return fmt.Sprintf("`%s` (U+%04X) was equal to %q after %q, and is now equal to %q after %q", string(diff.Rune), diff.Rune,
+			string(diff.LeftClass), string(diff.LeftPrevious), string(diff.RightClass), string(diff.RightPrevious))
+	}
+}
+
+// Len returns the number of differences.
+func (diff ModelDiff) Len() int {
+	length := len(diff.Encodings) + len(diff.ToUpper) + len(diff.ToLower) + len(diff.Weights)
+	if diff.PadSpace {
+		length++
+	}
+	return length
+}
+
+// Strings returns every difference as a human-readable string.
+func (diff ModelDiff) Strings() []string {
+	var strs []string
+	for _, encodingDiff := range diff.Encodings {
+		strs = append(strs, "encoding: "+encodingDiff.String())
+	}
+	for _, caseDiff := range diff.ToUpper {
+		strs = append(strs, "uppercase: "+caseDiff.String())
+	}
+	for _, caseDiff := range diff.ToLower {
+		strs = append(strs, "lowercase: "+caseDiff.String())
+	}
+	for _, weightDiff := range diff.Weights {
+		strs = append(strs, "weight: "+weightDiff.String())
+	}
+	if diff.PadSpace {
+		strs = append(strs, "padding: only one of the collations is PAD SPACE")
+	}
+	return strs
+}
+
+// DiffModels returns every difference between the two Models, which must be of the same kind.
+func DiffModels(left *Model, right *Model) (ModelDiff, error) {
+	if left.Kind != right.Kind {
+		return ModelDiff{}, fmt.Errorf("model `%s` is a %s while model `%s` is a %s", left.Name, left.Kind, right.Name, right.Kind)
+	}
+	diff := ModelDiff{PadSpace: left.PadSpace != right.PadSpace}
+	if left.Kind == ManifestKindCharset {
+		leftTree, err := encodingTreeFromEntries(left.Encodings)
+		if err != nil {
+			return ModelDiff{}, fmt.Errorf("model `%s` %w", left.Name, err)
+		}
+		rightTree, err := encodingTreeFromEntries(right.Encodings)
+		if err != nil {
+			return ModelDiff{}, fmt.Errorf("model `%s` %w", right.Name, err)
+		}
+		diff.Encodings = leftTree.Diff(rightTree)
+		diff.ToUpper, diff.ToLower = left.CaseMappings().Diff(right.CaseMappings())
+	}
+	diff.Weights = diffWeights(left.Weights, right.Weights)
+	return diff, nil
+}
+
+// diffCaseMappings returns every rune whose conversion differs between the two maps, sorted by rune.
+func diffCaseMappings(left map[rune]string, right map[rune]string) []CaseMappingDifference {
+	runes := make(map[rune]struct{})
+	for r := range left {
+		runes[r] = struct{}{}
+	}
+	for r := range right {
+		runes[r] = struct{}{}
+	}
+	var diffs []CaseMappingDifference
+	for r := range runes {
+		leftConversion, ok := left[r]
+		if !ok {
+			leftConversion = string(r)
+		}
+		rightConversion, ok := right[r]
+		if !ok {
+			rightConversion = string(r)
+		}
+		if leftConversion != rightConversion {
+			diffs = append(diffs, CaseMappingDifference{Rune: r, Left: leftConversion, Right: rightConversion})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].Rune < diffs[j].Rune
+	})
+	return diffs
+}
+
+// diffWeights returns every rune whose position differs between the two orderings, along with every rune that is only
+// in one of them, sorted by rune.
+func diffWeights(left [][]rune, right [][]rune) []WeightDifference {
+	leftRunes := make(map[rune]struct{})
+	for _, row := range left {
+		for _, r := range row {
+			leftRunes[r] = struct{}{}
+		}
+	}
+	rightRunes := make(map[rune]struct{})
+	for _, row := range right {
+		for _, r := range row {
+			rightRunes[r] = struct{}{}
+		}
+	}
+	var diffs []WeightDifference
+	for r := range leftRunes {
+		if _, ok := rightRunes[r]; !ok {
+			diffs = append(diffs, WeightDifference{Rune: r, LeftClass: []rune{r}})
+		}
+	}
+	for r := range rightRunes {
+		if _, ok := leftRunes[r]; !ok {
+			diffs = append(diffs, WeightDifference{Rune: r, RightClass: []rune{r}})
+		}
+	}
+
+	// The positions are only compared using the runes that both orderings share
+	leftPositions := weightPositions(left, rightRunes)
+	rightPositions := weightPositions(right, leftRunes)
+	for r, leftPosition := range leftPositions {
+		rightPosition := rightPositions[r]
+		if string(leftPosition[0]) != string(rightPosition[0]) || string(leftPosition[1]) != string(rightPosition[1]) {
+			diffs = append(diffs, WeightDifference{
+				Rune:          r,
+				LeftClass:     leftPosition[0],
+				RightClass:    rightPosition[0],
+				LeftPrevious:  leftPosition[1],
+				RightPrevious: rightPosition[1],
+			})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].Rune < diffs[j].Rune
+	})
+	return diffs
+}
+
+// weightPositions returns the row of each rune along with the row before it, only including the runes in the given
+// set. Rows that do not contain any of the runes are skipped.
+func weightPositions(rows [][]rune, included map[rune]struct{}) map[rune][2][]rune {
+	positions := make(map[rune][2][]rune)
+	previous := []rune{}
+	for _, row := range rows {
+		var filtered []rune
+		for _, r := range row {
+			if _, ok := included[r]; ok {
+				filtered = append(filtered, r)
+			}
+		}
+		if len(filtered) == 0 {
+			continue
+		}
+		// Rows from a RuneComparator are sorted, yet rows from other sources may not be
+		sort.Slice(filtered, func(i, j int) bool {
+			return filtered[i] < filtered[j]
+		})
+		for _, r := range filtered {
+			positions[r] = [2][]rune{filtered, previous}
+		}
+		previous = filtered
+	}
+	return positions
+}
@@ -0,0 +1,59 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffModels(t *testing.T) {
+	// A single moved rune only reports the runes around its old and new positions
+	left := NewCollationModel("test_ci", NewRuneComparatorFromOrder([][]rune{{'a', 'A'}, {'b'}, {'c'}, {'d'}, {'e'}}), true)
+	right := NewCollationModel("test_ci", NewRuneComparatorFromOrder([][]rune{{'a'}, {'A'}, {'c'}, {'d'}, {'b'}, {'e'}, {'f'}}), false)
+	diff, err := DiffModels(left, right)
+	require.NoError(t, err)
+	var runes []rune
+	for _, weightDiff := range diff.Weights {
+		runes = append(runes, weightDiff.Rune)
+	}
+	assert.Equal(t, []rune{'A', 'a', 'b', 'c', 'e', 'f'}, runes)
+	assert.True(t, diff.PadSpace)
+	assert.Equal(t, 7, diff.Len())
+	assert.Len(t, diff.Strings(), 7)
+
+	diff, err = DiffModels(left, left)
+	require.NoError(t, err)
+	assert.Equal(t, 0, diff.Len())
+
+	// Character sets compare their encodings and case mappings
+	rangeMap, err := RangeMapFromTree(consolidationTestTrees()["euc"])
+	require.NoError(t, err)
+	leftCaseMappings := NewCaseMappings()
+	leftCaseMappings.Add('ß', "SS", "ß")
+	rightCaseMappings := NewCaseMappings()
+	rightCaseMappings.Add('ß', "ß", "ß")
+	rightCaseMappings.Add('a', "A", "a")
+	diff, err = DiffModels(NewCharacterSetModel("euc", rangeMap, leftCaseMappings), NewCharacterSetModel("euc", rangeMap, rightCaseMappings))
+	require.NoError(t, err)
+	assert.Empty(t, diff.Encodings)
+	assert.Equal(t, []CaseMappingDifference{{Rune: 'a', Left: "a", Right: "A"}, {Rune: 'ß', Left: "SS", Right: "ß"}}, diff.ToUpper)
+	assert.Empty(t, diff.ToLower)
+
+	_, err = DiffModels(left, NewCharacterSetModel("euc", rangeMap, leftCaseMappings))
+	assert.Error(t, err)
+}
@@ -0,0 +1,118 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// Provenance records where the data of a generated file came from, so that every file embedded into GMS may be traced
+// back to the exact server (and extractor) that produced it.
+type Provenance struct {
+	// ServerVersion is the output of VERSION() on the server that the data was extracted from.
+	ServerVersion string `json:"server_version"`
+	// ExtractedAt is when the extraction finished, in UTC.
+	ExtractedAt time.Time `json:"extracted_at"`
+	// CollationID is the ID of the extracted collation, which is zero for character sets.
+	CollationID int `json:"collation_id,omitempty"`
+	// ExtractorRevision is the git revision of the extractor, which is suffixed with "-dirty" when the extractor had
+	// uncommitted changes.
+	ExtractorRevision string `json:"extractor_revision"`
+}
+
+// NewProvenance returns the Provenance of an extraction from the given server version that finished now. The
+// collation ID should be zero for character sets.
+func NewProvenance(serverVersion string, collationID int) *Provenance {
+	return &Provenance{
+		ServerVersion:     serverVersion,
+		ExtractedAt:       time.Now().UTC().Truncate(time.Second),
+		CollationID:       collationID,
+		ExtractorRevision: ExtractorRevision(),
+	}
+}
+
+// ExtractorRevision returns the git revision that the extractor was built from. Binaries built with `go build` have the
+// revision embedded, while `go run` and `go test` do not, so the revision is read from the working tree in that case.
+// Returns "unknown" when neither is available.
+func ExtractorRevision() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		var revision string
+		modified := false
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				revision = setting.Value
+			case "vcs.modified":
+				modified = setting.Value == "true"
+			}
+		}
+		if revision != "" {
+			if modified {
+				revision += "-dirty"
+			}
+			return revision
+		}
+	}
+	output, err := exec.Command("git", "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "unknown"
+	}
+	revision := strings.TrimSpace(string(output))
+	if status, err := exec.Command("git", "status", "--porcelain").Output(); err == nil && len(status) > 0 {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+// commentLines returns the Provenance as the lines of a comment, without the comment markers.
+func (p *Provenance) commentLines() []string {
+	lines := []string{
+		"Server version: " + p.ServerVersion,
+		"Extracted at: " + p.ExtractedAt.Format(time.RFC3339),
+	}
+	if p.CollationID != 0 {
+		lines = append(lines, fmt.Sprintf("Collation ID: %d", p.CollationID))
+	}
+	return append(lines, "Extractor revision: "+p.ExtractorRevision)
+}
+
+// ProvenanceToGoFile returns a Go file declaring the Provenance of the given character set or collation as constants.
+// Every other file of the character set or collation only records the Provenance within its header comment, so this is
+// the only file that declares the constants.
+func ProvenanceToGoFile(name string, provenance *Provenance, options CodegenOptions) string {
+	titleName, lowerName := options.names(name)
+	collationID := ""
+	if provenance.CollationID != 0 {
+		collationID = fmt.Sprintf("\n\t// %[1]s_CollationID is the ID of the `%[2]s` collation on the server.\n\t%[1]s_CollationID = %[3]d",
+			titleName, lowerName, provenance.CollationID)
+	}
+	options.Provenance = provenance
+	return fmt.Sprintf(`%[1]s
+
+const (
+	// %[2]s_ServerVersion is the version of the server that `+"`%[3]s`"+` was extracted from.
+	%[2]s_ServerVersion = %[4]q
+	// %[2]s_ExtractedAt is when `+"`%[3]s`"+` was extracted, in RFC 3339 format.
+	%[2]s_ExtractedAt = %[5]q%[6]s
+	// %[2]s_ExtractorRevision is the git revision of the extractor that `+"`%[3]s`"+` was extracted with.
+	%[2]s_ExtractorRevision = %[7]q
+)
+`, options.fileHeader(), titleName, lowerName, provenance.ServerVersion, provenance.ExtractedAt.Format(time.RFC3339),
+		collationID, provenance.ExtractorRevision)
+}
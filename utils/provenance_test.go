@@ -0,0 +1,59 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvenance(t *testing.T) {
+	provenance := &Provenance{
+		ServerVersion:     "8.0.31",
+		ExtractedAt:       time.Date(2022, 11, 1, 12, 30, 0, 0, time.UTC),
+		CollationID:       101,
+		ExtractorRevision: "0123456789abcdef",
+	}
+	options := CodegenOptions{Provenance: provenance}
+	header := options.fileHeader()
+	assert.Contains(t, header, "//\n// Server version: 8.0.31\n// Extracted at: 2022-11-01T12:30:00Z\n// Collation ID: 101\n// Extractor revision: 0123456789abcdef\n\npackage encodings")
+
+	contents := ProvenanceToGoFile("utf16_unicode_ci", provenance, CodegenOptions{})
+	assert.True(t, strings.HasPrefix(contents, header))
+	file, err := parser.ParseFile(token.NewFileSet(), "", contents, 0)
+	require.NoError(t, err)
+	for _, name := range []string{"Utf16_unicode_ci_ServerVersion", "Utf16_unicode_ci_ExtractedAt", "Utf16_unicode_ci_CollationID", "Utf16_unicode_ci_ExtractorRevision"} {
+		assert.NotNil(t, file.Scope.Lookup(name), name)
+	}
+	// Character sets do not have an ID
+	provenance.CollationID = 0
+	assert.NotContains(t, ProvenanceToGoFile("utf16", provenance, CodegenOptions{}), "CollationID")
+
+	// The provenance is saved with the model, so that files regenerated from the model retain it
+	path := filepath.Join(t.TempDir(), "test_ci.model.json")
+	model := NewCollationModel("test_ci", NewRuneComparatorFromOrder([][]rune{{'a'}}), false)
+	model.Provenance = provenance
+	require.NoError(t, model.Save(path))
+	loaded, err := LoadModel(path)
+	require.NoError(t, err)
+	assert.Equal(t, provenance, loaded.Provenance)
+}
@@ -0,0 +1,225 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ServerFlavor is the family of server that a QueryBuilder targets, as MariaDB diverged from MySQL after 5.5 and ships
+// collations (such as the NO PAD variants) that MySQL lacks.
+type ServerFlavor string
+
+const (
+	// ServerFlavorMySQL is MySQL, along with servers that report a MySQL version (such as Dolt).
+	ServerFlavorMySQL ServerFlavor = "mysql"
+	// ServerFlavorMariaDB is MariaDB.
+	ServerFlavorMariaDB ServerFlavor = "mariadb"
+)
+
+// QueryBuilder constructs the queries that are sent to the server. All string data is sent as a hexadecimal literal
+// with a character set introducer, which ensures that Go's exact byte representation is given to the server while
+// bypassing escape rules. Names (such as character sets and collations) are validated rather than escaped, as they are
+// always given by the caller rather than by the extracted data. Builders are specific to a server version, as some
+// syntax differs between versions.
+type QueryBuilder struct {
+	major   int
+	minor   int
+	patch   int
+	mariaDB bool
+}
+
+// queryBuilderName matches the names that may be given to a QueryBuilder.
+var queryBuilderName = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// queryBuilderConvertedLiteral matches a hexadecimal literal that is immediately converted to another character set,
+// capturing the literal's character set and data.
+var queryBuilderConvertedLiteral = regexp.MustCompile(`CONVERT\(_([A-Za-z0-9_]+) (?:0x((?:[0-9a-f]{2})+)|X'') USING `)
+
+// queryBuilderVersion matches the leading numeric portion of a version string.
+var queryBuilderVersion = regexp.MustCompile(`^(\d+)\.(\d+)\.(\d+)`)
+
+// NewQueryBuilder returns a new QueryBuilder for the given server version, which is the output of VERSION().
+func NewQueryBuilder(version string) (*QueryBuilder, error) {
+	qb := &QueryBuilder{}
+	// MariaDB reports a version of 5.5.5 before its own version for compatibility with older replication clients
+	if strings.Contains(version, "MariaDB") {
+		qb.mariaDB = true
+		version = strings.TrimPrefix(version, "5.5.5-")
+	}
+	matches := queryBuilderVersion.FindStringSubmatch(version)
+	if matches == nil {
+		return nil, fmt.Errorf("unable to parse server version `%s`", version)
+	}
+	qb.major, _ = strconv.Atoi(matches[1])
+	qb.minor, _ = strconv.Atoi(matches[2])
+	qb.patch, _ = strconv.Atoi(matches[3])
+	return qb, nil
+}
+
+// Version returns the server version, excluding any suffixes.
+func (qb *QueryBuilder) Version() string {
+	return fmt.Sprintf("%d.%d.%d", qb.major, qb.minor, qb.patch)
+}
+
+// IsMariaDB returns whether the server is MariaDB rather than MySQL.
+func (qb *QueryBuilder) IsMariaDB() bool {
+	return qb.mariaDB
+}
+
+// Flavor returns the ServerFlavor of the server.
+func (qb *QueryBuilder) Flavor() ServerFlavor {
+	if qb.mariaDB {
+		return ServerFlavorMariaDB
+	}
+	return ServerFlavorMySQL
+}
+
+// AtLeast returns whether the server version is equal to or greater than the given version.
+func (qb *QueryBuilder) AtLeast(major int, minor int, patch int) bool {
+	if qb.major != major {
+		return qb.major > major
+	}
+	if qb.minor != minor {
+		return qb.minor > minor
+	}
+	return qb.patch >= patch
+}
+
+// BinaryCollation returns the binary collation of utf8mb4 for the server. MySQL 8.0 introduced utf8mb4_0900_bin, which
+// compares by codepoint rather than by bytes, while earlier versions (and MariaDB) only have utf8mb4_bin. Both sort
+// utf8mb4 in codepoint order, but utf8mb4_0900_bin is NO PAD.
+func (qb *QueryBuilder) BinaryCollation() string {
+	if !qb.mariaDB && qb.AtLeast(8, 0, 0) {
+		return "utf8mb4_0900_bin"
+	}
+	return "utf8mb4_bin"
+}
+
+// Select returns a query that selects the given expressions.
+func (qb *QueryBuilder) Select(exprs ...string) string {
+	return fmt.Sprintf("SELECT %s;", strings.Join(exprs, ", "))
+}
+
+// Literal returns the given data as a hexadecimal literal with the given character set's introducer. The data is not
+// converted, so it must already be encoded in the character set. The binary character set may be used to create a
+// binary string.
+func (qb *QueryBuilder) Literal(charset string, data []byte) string {
+	// An empty hexadecimal literal is only valid using the X'' syntax
+	if len(data) == 0 {
+		return fmt.Sprintf("_%s X''", qb.name(charset))
+	}
+	return fmt.Sprintf("_%s 0x%s", qb.name(charset), hex.EncodeToString(data))
+}
+
+// InCharset returns the given UTF8 data converted to the given character set.
+func (qb *QueryBuilder) InCharset(data []byte, charset string) string {
+	return qb.Convert(qb.Literal("utf8mb4", data), charset)
+}
+
+// InCollation returns the given UTF8 data converted to the given character set, using the given collation.
+func (qb *QueryBuilder) InCollation(data []byte, charset string, collation string) string {
+	return qb.Collate(qb.InCharset(data, charset), collation)
+}
+
+// Convert returns the given expression converted to the given character set.
+func (qb *QueryBuilder) Convert(expr string, charset string) string {
+	return fmt.Sprintf("CONVERT(%s USING %s)", expr, qb.name(charset))
+}
+
+// Collate returns the given expression using the given collation.
+func (qb *QueryBuilder) Collate(expr string, collation string) string {
+	return fmt.Sprintf("%s COLLATE %s", expr, qb.name(collation))
+}
+
+// AsBinary returns the given expression cast to a binary string, so that the server returns its bytes as-is.
+func (qb *QueryBuilder) AsBinary(expr string) string {
+	return fmt.Sprintf("CAST(%s AS BINARY)", expr)
+}
+
+// Call returns a call to the given function using the given arguments.
+func (qb *QueryBuilder) Call(function string, args ...string) string {
+	return fmt.Sprintf("%s(%s)", qb.name(function), strings.Join(args, ", "))
+}
+
+// WeightString returns a call to WEIGHT_STRING for the given expression. When the character length is greater than
+// zero, the expression is cast to a CHAR of that length, which truncates or pads the expression.
+func (qb *QueryBuilder) WeightString(expr string, charLength int) string {
+	if charLength > 0 {
+		return fmt.Sprintf("WEIGHT_STRING(%s AS CHAR(%d))", expr, charLength)
+	}
+	return fmt.Sprintf("WEIGHT_STRING(%s)", expr)
+}
+
+// Identifier returns the given name as a quoted identifier, such as a database, table, or column. A qualified name may
+// be given by separating the names with a period.
+func (qb *QueryBuilder) Identifier(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = "`" + qb.name(part) + "`"
+	}
+	return strings.Join(parts, ".")
+}
+
+// Insert returns a query that inserts the given rows of expressions into the given table.
+func (qb *QueryBuilder) Insert(table string, rows [][]string) string {
+	values := make([]string, len(rows))
+	for i, row := range rows {
+		values[i] = "(" + strings.Join(row, ", ") + ")"
+	}
+	return fmt.Sprintf("INSERT INTO %s VALUES %s;", qb.Identifier(table), strings.Join(values, ", "))
+}
+
+// String returns the given string as a utf8mb4 literal, which is used when a query requires a literal name (such as
+// when filtering information_schema).
+func (qb *QueryBuilder) String(str string) string {
+	return qb.Literal("utf8mb4", []byte(str))
+}
+
+// name validates that the given name may be used within a query. Names are given by the caller (and are generally
+// constants), so an invalid name is a programming error.
+func (qb *QueryBuilder) name(name string) string {
+	if !queryBuilderName.MatchString(name) {
+		panic(fmt.Sprintf("invalid name for a query: `%s`", name))
+	}
+	return name
+}
+
+// Parameterize returns the given query as a prepared statement, with the data of every converted literal (such as from
+// InCharset or InCollation) replaced by a parameter, along with the data of each parameter. The statement differs only
+// in the literals' data for every rune, so it only needs to be parsed once by the server. The parameters are bound as
+// binary strings and then relabeled with the literal's character set, which gives the server the same bytes as the
+// literal. Other literals are left as-is, as a converted parameter differs from a literal in its coercibility. Returns
+// no parameters when the query contains no converted literals.
+func (qb *QueryBuilder) Parameterize(query string) (string, []interface{}) {
+	var params []interface{}
+	statement := queryBuilderConvertedLiteral.ReplaceAllStringFunc(query, func(match string) string {
+		submatches := queryBuilderConvertedLiteral.FindStringSubmatch(match)
+		data, _ := hex.DecodeString(submatches[2])
+		if data == nil {
+			data = []byte{}
+		}
+		params = append(params, data)
+		return fmt.Sprintf("CONVERT(CONVERT(CAST(? AS BINARY) USING %s) USING ", submatches[1])
+	})
+	if len(params) == 0 {
+		return query, nil
+	}
+	return strings.TrimSuffix(statement, ";"), params
+}
@@ -0,0 +1,122 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryBuilderVersions(t *testing.T) {
+	tests := []struct {
+		version         string
+		expectedVersion string
+		mariaDB         bool
+		binaryCollation string
+	}{
+		{"5.7.40-log", "5.7.40", false, "utf8mb4_bin"},
+		{"8.0.0-dmr", "8.0.0", false, "utf8mb4_0900_bin"},
+		{"8.0.31", "8.0.31", false, "utf8mb4_0900_bin"},
+		{"8.0.31-0ubuntu0.22.04.1", "8.0.31", false, "utf8mb4_0900_bin"},
+		{"8.1.0-commercial", "8.1.0", false, "utf8mb4_0900_bin"},
+		{"10.6.12-MariaDB", "10.6.12", true, "utf8mb4_bin"},
+		{"5.5.5-10.11.2-MariaDB-1:10.11.2+maria~ubu2204", "10.11.2", true, "utf8mb4_bin"},
+	}
+	for _, test := range tests {
+		t.Run(test.version, func(t *testing.T) {
+			qb, err := NewQueryBuilder(test.version)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedVersion, qb.Version())
+			assert.Equal(t, test.mariaDB, qb.IsMariaDB())
+			assert.Equal(t, test.mariaDB, qb.Flavor() == ServerFlavorMariaDB)
+			assert.Equal(t, test.binaryCollation, qb.BinaryCollation())
+			// Collation clauses use the version's binary collation
+			assert.Equal(t, "SELECT STRCMP(CONVERT(_utf8mb4 0x61 USING utf8mb4) COLLATE "+test.binaryCollation+
+				", CONVERT(_utf8mb4 0x62 USING utf8mb4) COLLATE "+test.binaryCollation+");",
+				qb.Select(qb.Call("STRCMP",
+					qb.InCollation([]byte("a"), "utf8mb4", qb.BinaryCollation()),
+					qb.InCollation([]byte("b"), "utf8mb4", qb.BinaryCollation()))))
+		})
+	}
+
+	_, err := NewQueryBuilder("unknown")
+	assert.Error(t, err)
+}
+
+func TestQueryBuilderAtLeast(t *testing.T) {
+	qb, err := NewQueryBuilder("8.0.31")
+	require.NoError(t, err)
+	assert.True(t, qb.AtLeast(8, 0, 31))
+	assert.True(t, qb.AtLeast(8, 0, 30))
+	assert.True(t, qb.AtLeast(5, 7, 40))
+	assert.False(t, qb.AtLeast(8, 0, 32))
+	assert.False(t, qb.AtLeast(8, 1, 0))
+	assert.False(t, qb.AtLeast(9, 0, 0))
+}
+
+func TestQueryBuilderExpressions(t *testing.T) {
+	qb, err := NewQueryBuilder("8.0.31")
+	require.NoError(t, err)
+
+	// Data is always sent as a hexadecimal literal, which bypasses the escape rules for quotes and backslashes
+	assert.Equal(t, "_utf8mb4 0x27275c", qb.Literal("utf8mb4", []byte(`''\`)))
+	assert.Equal(t, "_utf8mb4 X''", qb.Literal("utf8mb4", nil))
+	assert.Equal(t, "_binary 0x00ff", qb.Literal("binary", []byte{0, 255}))
+	assert.Equal(t, "CONVERT(_utf8mb4 0xe282ac USING latin1)", qb.InCharset([]byte("€"), "latin1"))
+	assert.Equal(t, "CONVERT(_utf8mb4 0x61 USING utf16) COLLATE utf16_unicode_ci",
+		qb.InCollation([]byte("a"), "utf16", "utf16_unicode_ci"))
+	assert.Equal(t, "SELECT CAST(CONVERT(UPPER(CONVERT(_utf8mb4 0x61 USING utf16)) USING utf8mb4) AS BINARY);",
+		qb.Select(qb.AsBinary(qb.Convert(qb.Call("UPPER", qb.InCharset([]byte("a"), "utf16")), "utf8mb4"))))
+	assert.Equal(t, "SELECT WEIGHT_STRING(CONVERT(_utf8mb4 0x61 USING utf16) COLLATE utf16_bin);",
+		qb.Select(qb.WeightString(qb.InCollation([]byte("a"), "utf16", "utf16_bin"), 0)))
+	assert.Equal(t, "SELECT WEIGHT_STRING(CONVERT(_utf8mb4 0x61 USING utf16) COLLATE utf16_bin AS CHAR(3));",
+		qb.Select(qb.WeightString(qb.InCollation([]byte("a"), "utf16", "utf16_bin"), 3)))
+	assert.Equal(t, "SELECT 1, 2;", qb.Select("1", "2"))
+	assert.Equal(t, "`collation_extractor`.`runes`", qb.Identifier("collation_extractor.runes"))
+	assert.Equal(t, "INSERT INTO `runes` VALUES (1, _utf8mb4 0x61), (2, _utf8mb4 0x62);",
+		qb.Insert("runes", [][]string{{"1", qb.Literal("utf8mb4", []byte("a"))}, {"2", qb.Literal("utf8mb4", []byte("b"))}}))
+
+	// Names are validated rather than escaped
+	assert.Panics(t, func() { qb.InCharset([]byte("a"), "utf8mb4) USING latin1") })
+	assert.Panics(t, func() { qb.Collate("1", "`utf8mb4_bin`") })
+	assert.Panics(t, func() { qb.Literal("", []byte("a")) })
+	assert.Panics(t, func() { qb.Identifier("runes`; DROP TABLE runes") })
+}
+
+func TestQueryBuilderParameterize(t *testing.T) {
+	qb, err := NewQueryBuilder("8.0.31")
+	require.NoError(t, err)
+
+	// Every shape of the same query results in the same statement, with the data moved into the parameters
+	for _, str := range []string{"a", "€", `''\`, ""} {
+		query := qb.Select(qb.Call("STRCMP", qb.InCollation([]byte(str), "utf16", "utf16_unicode_ci"),
+			qb.InCollation([]byte("b"), "utf16", "utf16_unicode_ci")))
+		statement, params := qb.Parameterize(query)
+		assert.Equal(t, "SELECT STRCMP(CONVERT(CONVERT(CAST(? AS BINARY) USING utf8mb4) USING utf16) COLLATE utf16_unicode_ci, "+
+			"CONVERT(CONVERT(CAST(? AS BINARY) USING utf8mb4) USING utf16) COLLATE utf16_unicode_ci)", statement)
+		assert.Equal(t, []interface{}{[]byte(str), []byte("b")}, params)
+	}
+	statement, params := qb.Parameterize(qb.Select(qb.AsBinary(qb.Convert(qb.Literal("binary", []byte{0, 255}), "utf16"))))
+	assert.Equal(t, "SELECT CAST(CONVERT(CONVERT(CAST(? AS BINARY) USING binary) USING utf16) AS BINARY)", statement)
+	assert.Equal(t, []interface{}{[]byte{0, 255}}, params)
+
+	// Literals that are not converted keep their coercibility, so they are not parameterized
+	query := "SELECT COLLATION_NAME FROM information_schema.COLLATIONS WHERE COLLATION_NAME = " + qb.String("utf16_bin") + ";"
+	statement, params = qb.Parameterize(query)
+	assert.Equal(t, query, statement)
+	assert.Nil(t, params)
+}
@@ -0,0 +1,133 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+// queryCacheFlushInterval is the number of new results after which a QueryCache is written to disk, so that results
+// are not lost when an extraction is interrupted.
+const queryCacheFlushInterval = 100000
+
+// queryCacheFileName matches the characters of a version that may not be used within a file name.
+var queryCacheFileName = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// QueryCache is a persistent cache of query results, which allows an extraction to be rerun (or multiple collations of
+// the same character set to be extracted) without querying the server for results that have already been seen. Results
+// are keyed by the query, and each server version has its own file, so that results are never shared between versions.
+// A QueryCache may be shared by multiple connections to the same server.
+type QueryCache struct {
+	mu      sync.Mutex
+	path    string
+	results map[string][][]byte
+	// unflushed is the number of results that have been added since the cache was last written.
+	unflushed int
+}
+
+// OpenQueryCache returns the QueryCache of the given server version (the output of VERSION()) within the given
+// directory. The directory is created if it does not exist.
+func OpenQueryCache(dir string, version string) (*QueryCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	qc := &QueryCache{
+		path:    filepath.Join(dir, queryCacheFileName.ReplaceAllString(version, "_")+".cache.bin"),
+		results: make(map[string][][]byte),
+	}
+	contents, err := os.ReadFile(qc.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return qc, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err = gob.NewDecoder(bytes.NewReader(contents)).Decode(&qc.results); err != nil {
+		return nil, fmt.Errorf("unable to decode the query cache at `%s`: %w", qc.path, err)
+	}
+	return qc, nil
+}
+
+// Get returns the cached results of the given query.
+func (qc *QueryCache) Get(query string) ([][]byte, bool) {
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+	results, ok := qc.results[query]
+	return results, ok
+}
+
+// Put adds the results of the given query, writing the cache to disk once enough results have been added.
+func (qc *QueryCache) Put(query string, results [][]byte) error {
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+	qc.results[query] = results
+	qc.unflushed++
+	if qc.unflushed >= queryCacheFlushInterval {
+		return qc.flush()
+	}
+	return nil
+}
+
+// Len returns the number of cached queries.
+func (qc *QueryCache) Len() int {
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+	return len(qc.results)
+}
+
+// Clear removes every cached result of the server version, including those that have been written to disk.
+func (qc *QueryCache) Clear() error {
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+	qc.results = make(map[string][][]byte)
+	qc.unflushed = 0
+	if err := os.Remove(qc.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Flush writes the cache to disk. The file is replaced atomically, so an interruption does not corrupt the cache.
+func (qc *QueryCache) Flush() error {
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+	return qc.flush()
+}
+
+// flush implements Flush. The lock must be held by the caller.
+func (qc *QueryCache) flush() error {
+	if qc.unflushed == 0 {
+		return nil
+	}
+	buf := bytes.Buffer{}
+	if err := gob.NewEncoder(&buf).Encode(qc.results); err != nil {
+		return err
+	}
+	if err := os.WriteFile(qc.path+".tmp", buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(qc.path+".tmp", qc.path); err != nil {
+		return err
+	}
+	qc.unflushed = 0
+	return nil
+}
@@ -0,0 +1,163 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// ErrQueryNotRecorded is returned by a replay Connection for a query that is not within its QueryRecording.
+var ErrQueryNotRecorded = errors.New("query was not recorded")
+
+// QueryRecording holds every query that a Connection issued along with the server's response, so that an extraction
+// may be replayed without a server by a Connection from NewReplayConnection. Unlike a QueryCache, a recording holds
+// every kind of query (including statements and queries that return multiple rows), and a replay never falls back to a
+// server. A QueryRecording may be shared by multiple connections to the same server.
+type QueryRecording struct {
+	mu      sync.Mutex
+	path    string
+	version string
+	results map[string]recordedResult
+	// unflushed is the number of results that have been added since the recording was last written.
+	unflushed int
+}
+
+// recordedResult is the response to a single query. Null is only used by single values, as the value of a NULL and an
+// empty string are otherwise indistinguishable once written.
+type recordedResult struct {
+	Rows [][][]byte
+	Null bool
+}
+
+// queryRecordingFile is the contents of a recording's file.
+type queryRecordingFile struct {
+	Version string
+	Results map[string]recordedResult
+}
+
+// The kinds of query within a recording, which are part of each key, as the same query may be issued as different kinds.
+const (
+	recordedValue  = "value"
+	recordedRows   = "rows"
+	recordedColumn = "column"
+	recordedExec   = "exec"
+)
+
+// OpenQueryRecording returns the QueryRecording at the given path for the given server version (the output of
+// VERSION()), creating it when it does not exist. An existing recording is added to, which allows an interrupted
+// extraction to be resumed, so it must have been recorded from the same version.
+func OpenQueryRecording(path string, version string) (*QueryRecording, error) {
+	recording, err := LoadQueryRecording(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &QueryRecording{path: path, version: version, results: make(map[string]recordedResult)}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if recording.version != version {
+		return nil, fmt.Errorf("the recording at `%s` is of `%s` rather than `%s`", path, recording.version, version)
+	}
+	return recording, nil
+}
+
+// LoadQueryRecording returns the existing QueryRecording at the given path.
+func LoadQueryRecording(path string) (*QueryRecording, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file queryRecordingFile
+	if err = gob.NewDecoder(bytes.NewReader(contents)).Decode(&file); err != nil {
+		return nil, fmt.Errorf("unable to decode the query recording at `%s`: %w", path, err)
+	}
+	if file.Results == nil {
+		file.Results = make(map[string]recordedResult)
+	}
+	return &QueryRecording{path: path, version: file.Version, results: file.Results}, nil
+}
+
+// Version returns the version of the server that the queries were recorded from.
+func (recording *QueryRecording) Version() string {
+	return recording.version
+}
+
+// Len returns the number of recorded queries.
+func (recording *QueryRecording) Len() int {
+	recording.mu.Lock()
+	defer recording.mu.Unlock()
+	return len(recording.results)
+}
+
+// Flush writes the recording to disk. The file is replaced atomically, so an interruption does not corrupt the recording.
+func (recording *QueryRecording) Flush() error {
+	recording.mu.Lock()
+	defer recording.mu.Unlock()
+	return recording.flush()
+}
+
+// flush implements Flush. The lock must be held by the caller.
+func (recording *QueryRecording) flush() error {
+	if recording.unflushed == 0 {
+		return nil
+	}
+	buf := bytes.Buffer{}
+	if err := gob.NewEncoder(&buf).Encode(queryRecordingFile{Version: recording.version, Results: recording.results}); err != nil {
+		return err
+	}
+	if err := os.WriteFile(recording.path+".tmp", buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(recording.path+".tmp", recording.path); err != nil {
+		return err
+	}
+	recording.unflushed = 0
+	return nil
+}
+
+// record adds the response to the given query, writing the recording to disk once enough responses have been added. The
+// rows are copied.
+func (recording *QueryRecording) record(kind string, query string, rows [][][]byte, null bool) error {
+	copied := make([][][]byte, len(rows))
+	for i, row := range rows {
+		copied[i] = make([][]byte, len(row))
+		for j, value := range row {
+			copied[i][j] = append([]byte{}, value...)
+		}
+	}
+	recording.mu.Lock()
+	defer recording.mu.Unlock()
+	recording.results[kind+"\x00"+query] = recordedResult{Rows: copied, Null: null}
+	recording.unflushed++
+	if recording.unflushed >= queryCacheFlushInterval {
+		return recording.flush()
+	}
+	return nil
+}
+
+// replay returns the recorded response to the given query.
+func (recording *QueryRecording) replay(kind string, query string) (recordedResult, error) {
+	recording.mu.Lock()
+	defer recording.mu.Unlock()
+	result, ok := recording.results[kind+"\x00"+query]
+	if !ok {
+		return recordedResult{}, fmt.Errorf("%w: %s", ErrQueryNotRecorded, query)
+	}
+	return result, nil
+}
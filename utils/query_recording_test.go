@@ -0,0 +1,70 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryRecordingReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recording.bin")
+	recording, err := OpenQueryRecording(path, "8.0.31")
+	require.NoError(t, err)
+	qb, err := NewQueryBuilder("8.0.31")
+	require.NoError(t, err)
+	encode := qb.Select(qb.AsBinary(qb.InCharset([]byte("a"), "utf16")))
+	require.NoError(t, recording.record(recordedValue, encode, [][][]byte{{{0, 'a'}}}, false))
+	require.NoError(t, recording.record(recordedValue, "SELECT NULL;", [][][]byte{{nil}}, true))
+	require.NoError(t, recording.record(recordedRows, "SELECT 1, 2;", [][][]byte{{[]byte("1"), []byte("2")}}, false))
+	require.NoError(t, recording.record(recordedColumn, "Charset\x00SHOW CHARACTER SET;", [][][]byte{{[]byte("latin1"), []byte("utf16")}}, false))
+	require.NoError(t, recording.record(recordedExec, "DROP TEMPORARY TABLE IF EXISTS `t`;", nil, false))
+	require.NoError(t, recording.Flush())
+
+	// Recordings may only be added to from the same version
+	_, err = OpenQueryRecording(path, "8.4.0")
+	assert.Error(t, err)
+
+	loaded, err := LoadQueryRecording(path)
+	require.NoError(t, err)
+	assert.Equal(t, 5, loaded.Len())
+	conn, err := NewReplayConnection(loaded)
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, "8.0.31", conn.Version())
+
+	value, err := conn.Query(encode)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0, 'a'}, value)
+	value, err = conn.Query("SELECT NULL;")
+	require.NoError(t, err)
+	assert.Nil(t, value)
+	values, err := conn.QueryValues("SELECT 1, 2;")
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("1"), []byte("2")}, values)
+	column, err := conn.QueryColumn("SHOW CHARACTER SET;", "Charset")
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("latin1"), []byte("utf16")}, column)
+	require.NoError(t, conn.Exec("DROP TEMPORARY TABLE IF EXISTS `t`;"))
+
+	// Queries are never sent to a server, even when they were only recorded as a different kind
+	_, err = conn.Query("SELECT 1, 2;")
+	assert.ErrorIs(t, err, ErrQueryNotRecorded)
+	_, err = conn.QueryColumn("SHOW CHARACTER SET;", "Description")
+	assert.ErrorIs(t, err, ErrQueryNotRecorded)
+}
@@ -0,0 +1,41 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+)
+
+// Queryable is the set of queries that an extraction issues, which is implemented by Connection and by FakeServer. The
+// extractor accepts a Queryable rather than a Connection, so that its tree construction, RangeMap building, and codegen
+// paths may be tested without a server.
+type Queryable interface {
+	// Builder returns the QueryBuilder for the server's version.
+	Builder() *QueryBuilder
+	// Version returns the full version of the server, including any suffixes.
+	Version() string
+	// QueryContext returns the value of a query that returns a single row and a single value.
+	QueryContext(ctx context.Context, query string) ([]byte, error)
+	// QueryValuesContext returns every value of a query that returns a single row.
+	QueryValuesContext(ctx context.Context, query string) ([][]byte, error)
+	// QueryRowsContext calls the callback with the values of every row that a query returns.
+	QueryRowsContext(ctx context.Context, query string, callback func(values [][]byte) error) error
+	// QueryColumnContext returns the values of the given column from every row that a query returns.
+	QueryColumnContext(ctx context.Context, query string, column string) ([][]byte, error)
+	// ExecContext executes a query that does not return any rows.
+	ExecContext(ctx context.Context, query string) error
+}
+
+var _ Queryable = (*Connection)(nil)
@@ -18,7 +18,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
-	"time"
+	"unicode/utf8"
 )
 
 // RangeMap is used to transcode from one encoding to another. During its construction from a RangeMapConstructor, one
@@ -28,6 +28,21 @@ import (
 type RangeMap struct {
 	inputEntries  [][]rangeMapEntry
 	outputEntries [][]rangeMapEntry
+	// inputUpperBounds and outputUpperBounds contain the running maximum of the upper bound of each entry's first byte,
+	// which are used to binary search the entries (see searchEntries).
+	inputUpperBounds  [][]byte
+	outputUpperBounds [][]byte
+	// asciiCompatible is whether the bytes 0x00-0x7F map to themselves, allowing ASCII to skip the entry search.
+	asciiCompatible bool
+	// inputWordSize is the size of each little-endian word of the input encoding, such as 2 for utf16le. The bytes of
+	// each word are reversed before the input entries are searched, as the entries only consolidate when the most
+	// significant byte comes first. Zero means that the input is not reversed.
+	inputWordSize int
+	// linearEntries are searched after the input and output entries, and map runs of consecutive codepoints to runs of
+	// consecutive runes. The output encoding is UTF8 for every linear entry.
+	linearEntries []linearRangeMapEntry
+	// replacement is the rune that DecodeModeReplace substitutes for invalid byte sequences. Zero means '?'.
+	replacement rune
 }
 
 // rangeMapEntry is an entry within a RangeMap, which represents a range of valid inputs along with the possible
@@ -39,24 +54,64 @@ type rangeMapEntry struct {
 	outputMults []int
 }
 
+// TranscodeErrorKind is the reason that a RangeMap was unable to transcode data.
+type TranscodeErrorKind uint8
+
+const (
+	// TranscodeErrorInvalid represents a byte sequence that is not valid for the source encoding.
+	TranscodeErrorInvalid TranscodeErrorKind = iota
+	// TranscodeErrorUnmappable represents a valid byte sequence that does not have an equivalent in the target encoding.
+	TranscodeErrorUnmappable
+)
+
+// TranscodeError is returned when a RangeMap is unable to transcode data. This distinguishes between invalid data and
+// valid data that cannot be mapped, which MySQL reports using different error messages.
+type TranscodeError struct {
+	Kind TranscodeErrorKind
+	// Position is the index of the first byte that caused the error.
+	Position int
+	Data     []byte
+}
+
+var _ error = (*TranscodeError)(nil)
+
+// Error implements the error interface.
+func (err *TranscodeError) Error() string {
+	switch err.Kind {
+	case TranscodeErrorInvalid:
+		return fmt.Sprintf("invalid byte sequence at position %d: 0x%X", err.Position, err.Data)
+	case TranscodeErrorUnmappable:
+		return fmt.Sprintf("unmappable codepoint at position %d: 0x%X", err.Position, err.Data)
+	default:
+		return fmt.Sprintf("unknown transcode error at position %d: 0x%X", err.Position, err.Data)
+	}
+}
+
 // Decode converts from the input encoding to the output encoding for the given data.
 func (rm *RangeMap) Decode(data []byte) ([]byte, bool) {
+	if rm.asciiCompatible && len(data) == 1 && data[0] < 0x80 {
+		return []byte{data[0]}, true
+	}
 	if len(data) > len(rm.inputEntries) {
 		return nil, false
 	}
-	for _, entry := range rm.inputEntries[len(data)-1] {
-		if entry.inputRange.contains(data) {
-			outputData := make([]byte, len(entry.outputRange))
-			increase := 0
-			for i := len(entry.inputRange) - 1; i >= 0; i-- {
-				increase += int(data[i]-entry.inputRange[i][0]) * entry.inputMults[i]
-			}
-			for i := 0; i < len(outputData); i++ {
-				diff := increase / entry.outputMults[i]
-				outputData[i] = entry.outputRange[i][0] + byte(diff)
-				increase -= diff * entry.outputMults[i]
-			}
-			return outputData, true
+	data = reverseWords(data, rm.inputWordSize)
+	if entry, ok := searchEntries(rm.inputEntries[len(data)-1], rm.inputUpperBounds[len(data)-1], data, inputBounds); ok {
+		outputData := make([]byte, len(entry.outputRange))
+		increase := 0
+		for i := len(entry.inputRange) - 1; i >= 0; i-- {
+			increase += int(data[i]-entry.inputRange[i][0]) * entry.inputMults[i]
+		}
+		for i := 0; i < len(outputData); i++ {
+			diff := increase / entry.outputMults[i]
+			outputData[i] = entry.outputRange[i][0] + byte(diff)
+			increase -= diff * entry.outputMults[i]
+		}
+		return outputData, true
+	}
+	for _, entry := range rm.linearEntries {
+		if r, ok := entry.decode(data); ok {
+			return []byte(string(r)), true
 		}
 	}
 	return nil, false
@@ -64,58 +119,259 @@ func (rm *RangeMap) Decode(data []byte) ([]byte, bool) {
 
 // Encode converts from the output encoding to the input encoding for the given data.
 func (rm *RangeMap) Encode(data []byte) ([]byte, bool) {
+	if rm.asciiCompatible && len(data) == 1 && data[0] < 0x80 {
+		return []byte{data[0]}, true
+	}
 	if len(data) > len(rm.outputEntries) {
 		return nil, false
 	}
-	for _, entry := range rm.outputEntries[len(data)-1] {
-		if entry.outputRange.contains(data) {
-			inputData := make([]byte, len(entry.inputRange))
-			increase := 0
-			for i := len(entry.outputRange) - 1; i >= 0; i-- {
-				increase += int(data[i]-entry.outputRange[i][0]) * entry.outputMults[i]
-			}
-			for i := 0; i < len(inputData); i++ {
-				diff := increase / entry.inputMults[i]
-				inputData[i] = entry.inputRange[i][0] + byte(diff)
-				increase -= diff * entry.inputMults[i]
+	if entry, ok := searchEntries(rm.outputEntries[len(data)-1], rm.outputUpperBounds[len(data)-1], data, outputBounds); ok {
+		inputData := make([]byte, len(entry.inputRange))
+		increase := 0
+		for i := len(entry.outputRange) - 1; i >= 0; i-- {
+			increase += int(data[i]-entry.outputRange[i][0]) * entry.outputMults[i]
+		}
+		for i := 0; i < len(inputData); i++ {
+			diff := increase / entry.inputMults[i]
+			inputData[i] = entry.inputRange[i][0] + byte(diff)
+			increase -= diff * entry.inputMults[i]
+		}
+		return reverseWords(inputData, rm.inputWordSize), true
+	}
+	if len(rm.linearEntries) > 0 {
+		if r, size := utf8.DecodeRune(data); r != utf8.RuneError && size == len(data) {
+			for _, entry := range rm.linearEntries {
+				if inputData, ok := entry.encode(r); ok {
+					return reverseWords(inputData, rm.inputWordSize), true
+				}
 			}
-			return inputData, true
 		}
 	}
 	return nil, false
 }
 
-// RangeMapToGoFile returns the given RangeMap as a Go file for inclusion in an application.
-func RangeMapToGoFile(rm *RangeMap, toUpper [][2]rune, toLower [][2]rune, name string) string {
-	titleName := name
-	lowerName := strings.ToLower(name)
-	{
-		nameRunes := []rune(lowerName)
-		nameRunes[0] = []rune(strings.ToUpper(string(nameRunes[0])))[0]
-		titleName = string(nameRunes)
+// IsASCIICompatible returns whether every byte from 0x00 to 0x7F maps to itself in both directions, and also whether
+// every multi-byte encoding starts with a byte outside of that range. When both are true, a byte below 0x80 at the start
+// of a codepoint is always a single ASCII character, so it may be transcoded without searching the entries. This is true
+// for character sets such as utf8mb4 and latin1, but false for sets such as utf16. This checks the entries directly, so
+// that the result does not depend on the current value of the flag.
+func (rm *RangeMap) IsASCIICompatible() bool {
+	checkEntries := func(entries [][]rangeMapEntry, bounds func(entry rangeMapEntry) rangeBounds) bool {
+		if len(entries) == 0 {
+			return false
+		}
+		for _, entryLength := range entries[1:] {
+			for _, entry := range entryLength {
+				if bounds(entry)[0][0] < 0x80 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	for _, entry := range rm.linearEntries {
+		if entry.inputRange[0][0] < 0x80 {
+			return false
+		}
+	}
+	if !checkEntries(rm.inputEntries, func(entry rangeMapEntry) rangeBounds { return entry.inputRange }) ||
+		!checkEntries(rm.outputEntries, func(entry rangeMapEntry) rangeBounds { return entry.outputRange }) {
+		return false
+	}
+	asciiCompatible := rm.asciiCompatible
+	rm.asciiCompatible = false
+	defer func() {
+		rm.asciiCompatible = asciiCompatible
+	}()
+	for b := 0; b < 0x80; b++ {
+		if decoded, ok := rm.Decode([]byte{byte(b)}); !ok || len(decoded) != 1 || decoded[0] != byte(b) {
+			return false
+		}
+		if encoded, ok := rm.Encode([]byte{byte(b)}); !ok || len(encoded) != 1 || encoded[0] != byte(b) {
+			return false
+		}
+	}
+	return true
+}
+
+// CodepointLengths returns the lengths of the shortest and longest codepoints of the input encoding, in bytes.
+func (rm *RangeMap) CodepointLengths() (minLength int, maxLength int) {
+	for i, entryLength := range rm.inputEntries {
+		if len(entryLength) == 0 {
+			continue
+		}
+		if minLength == 0 {
+			minLength = i + 1
+		}
+		maxLength = i + 1
 	}
+	for _, entry := range rm.linearEntries {
+		if minLength == 0 || len(entry.inputRange) < minLength {
+			minLength = len(entry.inputRange)
+		}
+		if len(entry.inputRange) > maxLength {
+			maxLength = len(entry.inputRange)
+		}
+	}
+	return minLength, maxLength
+}
+
+// DecodeWithError is the same as Decode, except that a TranscodeError is returned when the data cannot be decoded. A
+// byte sequence is considered valid (and therefore unmappable) when every byte falls within a range that is valid for
+// its position, even though no single entry contains the entire sequence.
+func (rm *RangeMap) DecodeWithError(data []byte) ([]byte, error) {
+	if len(data) > 0 {
+		if outputData, ok := rm.Decode(data); ok {
+			return outputData, nil
+		}
+	}
+	// The linear entries are searched as though they were input entries, as only their bounds are needed
+	entries := rm.inputEntries
+	if len(rm.linearEntries) > 0 {
+		entries = make([][]rangeMapEntry, len(rm.inputEntries))
+		copy(entries, rm.inputEntries)
+		for _, entry := range rm.linearEntries {
+			length := len(entry.inputRange) - 1
+			entries[length] = append(append([]rangeMapEntry(nil), entries[length]...), rangeMapEntry{inputRange: entry.inputRange})
+		}
+	}
+	return nil, rm.transcodeError(entries, reverseWords(data, rm.inputWordSize), func(entry rangeMapEntry) rangeBounds { return entry.inputRange })
+}
+
+// EncodeWithError is the same as Encode, except that a TranscodeError is returned when the data cannot be encoded. The
+// output encoding is always UTF8 for extracted character sets, so any valid UTF8 codepoint is considered unmappable.
+func (rm *RangeMap) EncodeWithError(data []byte) ([]byte, error) {
+	if len(data) > 0 {
+		if inputData, ok := rm.Encode(data); ok {
+			return inputData, nil
+		}
+	}
+	if r, size := utf8.DecodeRune(data); r == utf8.RuneError && size <= 1 {
+		return nil, &TranscodeError{Kind: TranscodeErrorInvalid, Position: 0, Data: data}
+	} else if size != len(data) {
+		return nil, &TranscodeError{Kind: TranscodeErrorInvalid, Position: size, Data: data}
+	}
+	return nil, &TranscodeError{Kind: TranscodeErrorUnmappable, Position: 0, Data: data}
+}
+
+// transcodeError determines the kind and position of the error for data that could not be transcoded. The position is
+// the length of the longest prefix that is contained within any entry of the same length.
+func (rm *RangeMap) transcodeError(entries [][]rangeMapEntry, data []byte, bounds func(entry rangeMapEntry) rangeBounds) *TranscodeError {
+	if len(data) == 0 || len(data) > len(entries) {
+		return &TranscodeError{Kind: TranscodeErrorInvalid, Position: 0, Data: data}
+	}
+	position := 0
+	validPositions := make([]bool, len(data))
+	for _, entry := range entries[len(data)-1] {
+		entryBounds := bounds(entry)
+		if prefix := entryBounds.prefixLength(data); prefix > position {
+			position = prefix
+		}
+		for i := range data {
+			if entryBounds.boundsContains(entryBounds[i], [2]byte{data[i], data[i]}) {
+				validPositions[i] = true
+			}
+		}
+	}
+	for _, valid := range validPositions {
+		if !valid {
+			return &TranscodeError{Kind: TranscodeErrorInvalid, Position: position, Data: data}
+		}
+	}
+	return &TranscodeError{Kind: TranscodeErrorUnmappable, Position: position, Data: data}
+}
+
+// Tree returns a CharacterSetEncodingTree containing every input encoding that this RangeMap is able to decode, with
+// the output encodings as the data. This allows a previously constructed RangeMap to be merged with new encodings.
+func (rm *RangeMap) Tree() *CharacterSetEncodingTree {
+	tree := NewCharacterSetEncodingTree()
+	for _, entryLength := range rm.inputEntries {
+		for _, entry := range entryLength {
+			rm.addEntryToTree(tree, entry, make([]byte, len(entry.inputRange)), 0)
+		}
+	}
+	for _, entry := range rm.linearEntries {
+		for idx := entry.first; idx <= entry.last; idx++ {
+			encoding := reverseWords(entry.input(idx), rm.inputWordSize)
+			subtree := tree
+			for _, byteVal := range encoding {
+				subtree = subtree.AddChild(byteVal)
+			}
+			subtree.SetData([]byte(string(entry.firstRune + rune(idx-entry.first))))
+		}
+	}
+	return tree
+}
+
+// addEntryToTree adds every input encoding covered by the entry to the tree. The input slice is filled in from the
+// given index onward, as each byte position is iterated over recursively.
+func (rm *RangeMap) addEntryToTree(tree *CharacterSetEncodingTree, entry rangeMapEntry, input []byte, idx int) {
+	if idx == len(input) {
+		// The entries are searched using the reversed words, so the encoding is reversed back before decoding
+		encoding := reverseWords(input, rm.inputWordSize)
+		output, ok := rm.Decode(encoding)
+		if !ok {
+			return
+		}
+		subtree := tree
+		for _, byteVal := range encoding {
+			subtree = subtree.AddChild(byteVal)
+		}
+		subtree.SetData(output)
+		return
+	}
+	for val := int(entry.inputRange[idx][0]); val <= int(entry.inputRange[idx][1]); val++ {
+		input[idx] = byte(val)
+		rm.addEntryToTree(tree, entry, input, idx+1)
+	}
+}
+
+// reverseWords returns the data with the bytes of each word of the given size reversed, which converts between little-
+// endian and big-endian words. Trailing bytes that do not form a whole word are left as-is. The data is returned
+// unchanged when the size is less than 2.
+func reverseWords(data []byte, size int) []byte {
+	if size < 2 {
+		return data
+	}
+	reversed := append([]byte(nil), data...)
+	for start := 0; start+size <= len(reversed); start += size {
+		for i, j := start, start+size-1; i < j; i, j = i+1, j-1 {
+			reversed[i], reversed[j] = reversed[j], reversed[i]
+		}
+	}
+	return reversed
+}
+
+// RangeMapToGoFile returns the given RangeMap as a Go file for inclusion in an application. The RangeMap holds the case
+// conversions that produce a single rune, while the conversions that produce any other number of runes are declared in
+// separate maps. The RangeMap is declared as the title-cased name of the character set (such as `Latin1`), matching the
+// identifiers of RuneComparatorToGoFile, along with the name that the server uses for the character set (such as
+// `Latin1_Name`) and a function that returns the RangeMap when given that name (such as `Latin1_Lookup`), so that
+// applications may find the character set by name without the file from RegistrationToGoFile.
+func RangeMapToGoFile(rm *RangeMap, caseMappings CaseMappings, name string) string {
+	return RangeMapToGoFileWithOptions(rm, caseMappings, name, CodegenOptions{})
+}
+
+// RangeMapToGoFileWithOptions returns the same file as RangeMapToGoFile, modified by the given options.
+func RangeMapToGoFileWithOptions(rm *RangeMap, caseMappings CaseMappings, name string, options CodegenOptions) string {
+	titleName, lowerName := options.names(name)
+	toUpper, toLower := caseMappings.Conversions()
 
 	sb := strings.Builder{}
-	sb.WriteString(fmt.Sprintf(`// Copyright %d Dolthub, Inc.
-//
-// Licensed under the Apache License, Version 2.0 (the "License");
-// you may not use this file except in compliance with the License.
-// You may obtain a copy of the License at
-//
-//     http://www.apache.org/licenses/LICENSE-2.0
-//
-// Unless required by applicable law or agreed to in writing, software
-// distributed under the License is distributed on an "AS IS" BASIS,
-// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
-// See the License for the specific language governing permissions and
-// limitations under the License.
+	sb.WriteString(options.fileHeader())
+	sb.WriteString(fmt.Sprintf(`
 
-package encodings
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
 
 // %s represents the %s character set encoding.
-var %s Encoder = &RangeMap{
+var %s %s = &RangeMap{
 	inputEntries: [][]rangeMapEntry{
-`, time.Now().Year(), titleName, "`"+lowerName+"`", titleName))
+`, titleName, "`"+strings.ToLower(name)+"`", titleName, options.encoderType()))
 	for _, entryLength := range rm.inputEntries {
 		if len(entryLength) == 0 {
 			sb.WriteString("\t\tnil,\n")
@@ -141,8 +397,32 @@ var %s Encoder = &RangeMap{
 		}
 		sb.WriteString("\t\t},\n")
 	}
-	sb.WriteString(`	},
-	toUpper: map[rune]rune{
+	sb.WriteString("\t},\n")
+	// The upper bounds allow the entries of each length to be binary searched, as the entries are sorted by their lower
+	// bounds, which only the RangeMap of this repository does
+	if options.ExtendedRangeMap {
+		sb.WriteString(fmt.Sprintf(`	inputUpperBounds: %s,
+	outputUpperBounds: %s,
+	asciiCompatible: %t,
+`, upperBoundsToGoFile(rm.inputUpperBounds), upperBoundsToGoFile(rm.outputUpperBounds), rm.asciiCompatible))
+	}
+	// Only little-endian character sets reverse their input, so the field is omitted for every other character set
+	if rm.inputWordSize > 0 {
+		sb.WriteString(fmt.Sprintf("\tinputWordSize: %d,\n", rm.inputWordSize))
+	}
+	// Only character sets with long arithmetic runs (such as gb18030) have linear entries
+	if len(rm.linearEntries) > 0 {
+		sb.WriteString("\tlinearEntries: []linearRangeMapEntry{\n")
+		for _, entry := range rm.linearEntries {
+			sb.WriteString(entry.goString())
+		}
+		sb.WriteString("\t},\n")
+	}
+	// Only character sets that substitute a rune other than '?' for invalid byte sequences declare their replacement
+	if rm.replacement != 0 {
+		sb.WriteString(fmt.Sprintf("\treplacement: %d,\n", rm.replacement))
+	}
+	sb.WriteString(`	toUpper: map[rune]rune{
 `)
 	for _, runes := range toUpper {
 		sb.WriteString(fmt.Sprintf("\t\t%d: %d,\n", runes[0], runes[1]))
@@ -156,6 +436,43 @@ var %s Encoder = &RangeMap{
 	sb.WriteString(`	},
 }
 `)
+	// The name is always that of the character set, even when the identifiers use a prefix
+	sb.WriteString(fmt.Sprintf(`
+// %[1]s_Name is the name of the %[2]s character set, as used by the server.
+const %[1]s_Name = %[3]q
+
+// %[1]s_Lookup returns %[1]s when the given name is %[1]s_Name, ignoring case, and false otherwise.
+func %[1]s_Lookup(name string) (%[4]s, bool) {
+	if strings.EqualFold(name, %[1]s_Name) {
+		return %[1]s, true
+	}
+	return nil, false
+}
+`, titleName, "`"+strings.ToLower(name)+"`", strings.ToLower(name), options.encoderType()))
+	sb.WriteString(rm.stringHelpersToGoFile(titleName, lowerName))
+	// Only character sets with conversions that do not produce exactly one rune declare the multi-rune maps
+	multiRune := caseMappings.MultiRune()
+	sb.WriteString(multiRuneCaseMappingToGoFile(lowerName, strings.ToLower(name), "uppercase", "ToUpper", multiRune.ToUpper))
+	sb.WriteString(multiRuneCaseMappingToGoFile(lowerName, strings.ToLower(name), "lowercase", "ToLower", multiRune.ToLower))
+	return sb.String()
+}
+
+// multiRuneCaseMappingToGoFile returns the declaration of the given multi-rune conversions for the file that
+// RangeMapToGoFile generates, which is empty when there are no conversions.
+func multiRuneCaseMappingToGoFile(lowerName string, charset string, description string, suffix string, conversions map[rune]string) string {
+	if len(conversions) == 0 {
+		return ""
+	}
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf(`
+// %[1]s_MultiRune%[2]s contains the %[3]s conversions of the %[4]s character set that do not produce exactly
+// one rune, which the RangeMap's %[5]s cannot represent.
+var %[1]s_MultiRune%[2]s = map[rune]string{
+`, lowerName, suffix, description, "`"+charset+"`", strings.ToLower(suffix[:1])+suffix[1:]))
+	for _, r := range sortedCaseMappingRunes(conversions) {
+		sb.WriteString(fmt.Sprintf("\t%d: %q,\n", r, conversions[r]))
+	}
+	sb.WriteString("}\n")
 	return sb.String()
 }
 
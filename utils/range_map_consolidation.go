@@ -0,0 +1,176 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+)
+
+// ConsolidationStrategy is the approach that a RangeMapConstructor uses to merge its ranges. Strategies trade the number
+// of entries in the generated RangeMap (which determines how long a lookup takes) against the time that construction
+// takes, which matters most for large character sets.
+type ConsolidationStrategy string
+
+const (
+	// ConsolidationStrategyLinear merges each range into the previous range in a single pass, and then continues to
+	// merge the previous range into the ranges before it for as long as possible. Each merge removes a range, so this
+	// takes linear time, and produces the same entries as ConsolidationStrategyMultiPass. This is the default.
+	ConsolidationStrategyLinear ConsolidationStrategy = "linear"
+	// ConsolidationStrategyMultiPass repeatedly merges adjacent ranges until no further merges are possible. This
+	// re-scans every range on each pass, so its time grows with the number of passes, and it is only kept as a reference
+	// for ConsolidationStrategyLinear.
+	ConsolidationStrategyMultiPass ConsolidationStrategy = "multi_pass"
+	// ConsolidationStrategyGreedy merges each range into the previous range in a single pass, which is the fastest yet
+	// misses merges that only become possible after other ranges have been merged.
+	ConsolidationStrategyGreedy ConsolidationStrategy = "greedy"
+	// ConsolidationStrategyPlanes splits the ranges by their length and first byte, and consolidates each plane using
+	// ConsolidationStrategyLinear. Each pass only covers a single plane, which is far faster for character sets with
+	// many planes, yet ranges are never merged across planes.
+	ConsolidationStrategyPlanes ConsolidationStrategy = "planes"
+)
+
+// ConsolidationStrategies returns every ConsolidationStrategy.
+func ConsolidationStrategies() []ConsolidationStrategy {
+	return []ConsolidationStrategy{ConsolidationStrategyLinear, ConsolidationStrategyMultiPass, ConsolidationStrategyGreedy, ConsolidationStrategyPlanes}
+}
+
+// ParseConsolidationStrategy returns the ConsolidationStrategy with the given name. An empty name returns the default.
+func ParseConsolidationStrategy(name string) (ConsolidationStrategy, error) {
+	if name == "" {
+		return ConsolidationStrategyLinear, nil
+	}
+	for _, strategy := range ConsolidationStrategies() {
+		if string(strategy) == name {
+			return strategy, nil
+		}
+	}
+	return "", fmt.Errorf("unknown consolidation strategy `%s`, expected one of %v", name, ConsolidationStrategies())
+}
+
+// consolidate merges the given ranges using the strategy, returning the merged ranges. The input and output ranges at
+// each index belong to the same range set.
+func (strategy ConsolidationStrategy) consolidate(inputRanges []rangeBounds, outputRanges []rangeBounds) ([]rangeBounds, []rangeBounds) {
+	switch strategy {
+	case ConsolidationStrategyGreedy:
+		inputRanges, outputRanges, _ = consolidatePass(inputRanges, outputRanges)
+		return inputRanges, outputRanges
+	case ConsolidationStrategyPlanes:
+		var newInputRanges []rangeBounds
+		var newOutputRanges []rangeBounds
+		for start := 0; start < len(inputRanges); {
+			end := start + 1
+			for end < len(inputRanges) && len(inputRanges[end]) == len(inputRanges[start]) && inputRanges[end][0] == inputRanges[start][0] {
+				end++
+			}
+			planeInputRanges, planeOutputRanges := consolidateLinear(inputRanges[start:end], outputRanges[start:end])
+			newInputRanges = append(newInputRanges, planeInputRanges...)
+			newOutputRanges = append(newOutputRanges, planeOutputRanges...)
+			start = end
+		}
+		return newInputRanges, newOutputRanges
+	case ConsolidationStrategyMultiPass:
+		return consolidateMultiPass(inputRanges, outputRanges)
+	default:
+		return consolidateLinear(inputRanges, outputRanges)
+	}
+}
+
+// consolidateLinear merges the ranges using a stack. Each range is merged into the range set at the top of the stack
+// while both have at most a single difference (see consolidatePass), which is the merge that a single pass finds. Once
+// a range cannot be merged, the top range set is complete, and it is merged into the range set below it for as long
+// as possible, which are the merges that ConsolidationStrategyMultiPass finds on its later passes. A range set is only
+// merged downward once it is complete, as merging a partial range set may allow a merge that the complete range set
+// does not. Every merge removes a range set, so the number of comparisons is linear in the number of ranges.
+func consolidateLinear(inputRanges []rangeBounds, outputRanges []rangeBounds) ([]rangeBounds, []rangeBounds) {
+	newInputRanges := make([]rangeBounds, 0, len(inputRanges))
+	newOutputRanges := make([]rangeBounds, 0, len(outputRanges))
+	collapse := func() {
+		for len(newInputRanges) > 1 {
+			lastInputRange := newInputRanges[len(newInputRanges)-2]
+			lastOutputRange := newOutputRanges[len(newOutputRanges)-2]
+			topInputRange := newInputRanges[len(newInputRanges)-1]
+			topOutputRange := newOutputRanges[len(newOutputRanges)-1]
+			if lastInputRange.differences(topInputRange) > 1 || lastOutputRange.differences(topOutputRange) > 1 {
+				return
+			}
+			lastInputRange.merge(topInputRange)
+			lastOutputRange.merge(topOutputRange)
+			newInputRanges = newInputRanges[:len(newInputRanges)-1]
+			newOutputRanges = newOutputRanges[:len(newOutputRanges)-1]
+		}
+	}
+	for rangeIdx := range inputRanges {
+		currentInputRange := inputRanges[rangeIdx]
+		currentOutputRange := outputRanges[rangeIdx]
+		if len(newInputRanges) > 0 {
+			topInputRange := newInputRanges[len(newInputRanges)-1]
+			topOutputRange := newOutputRanges[len(newOutputRanges)-1]
+			if topInputRange.differences(currentInputRange) <= 1 && topOutputRange.differences(currentOutputRange) <= 1 {
+				topInputRange.merge(currentInputRange)
+				topOutputRange.merge(currentOutputRange)
+				continue
+			}
+			collapse()
+		}
+		newInputRanges = append(newInputRanges, currentInputRange)
+		newOutputRanges = append(newOutputRanges, currentOutputRange)
+	}
+	collapse()
+	return newInputRanges, newOutputRanges
+}
+
+// consolidateMultiPass is a highly inefficient way of reducing the number of ranges down to the absolute minimum. This
+// loops repeatedly over newly created slices until no changes are made, similar to bubble sort. Although it's terrible,
+// it works, and computers are fast enough that this takes only milliseconds for most character sets (and only needs to
+// run once).
+func consolidateMultiPass(inputRanges []rangeBounds, outputRanges []rangeBounds) ([]rangeBounds, []rangeBounds) {
+	for merged := true; merged; {
+		inputRanges, outputRanges, merged = consolidatePass(inputRanges, outputRanges)
+	}
+	return inputRanges, outputRanges
+}
+
+// consolidatePass compares each range set with the previous range set (both input and output). If both sets of ranges
+// have only a single difference (or no differences), then we merge the current range set with the previous range set.
+// If there are multiple differences, then we add the new range set. Differences represent changes that may be merged.
+// Too many differences and the ranges are not mergeable. This ensures that there is a sequential mapping between the
+// input and the output. Returns whether any range sets were merged.
+func consolidatePass(inputRanges []rangeBounds, outputRanges []rangeBounds) ([]rangeBounds, []rangeBounds, bool) {
+	merged := false
+	var newInputRanges []rangeBounds
+	var newOutputRanges []rangeBounds
+	for rangeIdx := 0; rangeIdx < len(inputRanges); rangeIdx++ {
+		currentInputRange := inputRanges[rangeIdx]
+		currentOutputRange := outputRanges[rangeIdx]
+		if len(newInputRanges) == 0 {
+			newInputRanges = append(newInputRanges, currentInputRange)
+			newOutputRanges = append(newOutputRanges, currentOutputRange)
+			continue
+		}
+		lastInputRange := newInputRanges[len(newInputRanges)-1]
+		lastOutputRange := newOutputRanges[len(newOutputRanges)-1]
+		inputDifferences := lastInputRange.differences(currentInputRange)
+		outputDifferences := lastOutputRange.differences(currentOutputRange)
+		if inputDifferences <= 1 && outputDifferences <= 1 {
+			lastInputRange.merge(currentInputRange)
+			lastOutputRange.merge(currentOutputRange)
+			merged = true
+		} else {
+			newInputRanges = append(newInputRanges, currentInputRange)
+			newOutputRanges = append(newOutputRanges, currentOutputRange)
+		}
+	}
+	return newInputRanges, newOutputRanges, merged
+}
@@ -0,0 +1,110 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"testing"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// consolidationTestTrees returns trees with the structure of real character sets, which are used to compare the
+// consolidation strategies.
+func consolidationTestTrees() map[string]*CharacterSetEncodingTree {
+	add := func(tree *CharacterSetEncodingTree, encoding []byte, r rune) {
+		node := tree
+		for _, val := range encoding {
+			node = node.AddChild(val)
+		}
+		node.SetData([]byte(string(r)))
+	}
+	// The Basic Multilingual Plane in UTF16, which spans many planes of both its own encoding and UTF8
+	bmp := NewCharacterSetEncodingTree()
+	for r := rune(0); r < 0xD800; r++ {
+		word := utf16.Encode([]rune{r})[0]
+		add(bmp, []byte{byte(word >> 8), byte(word)}, r)
+	}
+	// A double-byte character set in the layout of EUC, where every row of trail bytes has gaps
+	euc := NewCharacterSetEncodingTree()
+	for b := 0; b < 0x80; b++ {
+		add(euc, []byte{byte(b)}, rune(b))
+	}
+	r := rune(0x3000)
+	for lead := 0xA1; lead <= 0xFE; lead++ {
+		for trail := 0xA1; trail <= 0xFE; trail++ {
+			if trail%7 != 0 {
+				add(euc, []byte{byte(lead), byte(trail)}, r)
+			}
+			r++
+		}
+	}
+	return map[string]*CharacterSetEncodingTree{"bmp": bmp, "euc": euc}
+}
+
+func TestConsolidationStrategies(t *testing.T) {
+	for name, tree := range consolidationTestTrees() {
+		entries := make(map[ConsolidationStrategy]int)
+		for _, strategy := range ConsolidationStrategies() {
+			// Every RangeMap is verified against the tree during construction
+			rangeMap, err := RangeMapFromTreeWithOptions(tree, RangeMapOptions{Consolidation: strategy})
+			require.NoError(t, err, "%s: %s", name, strategy)
+			for _, entryLength := range rangeMap.inputEntries {
+				entries[strategy] += len(entryLength)
+			}
+		}
+		assert.Equal(t, entries[ConsolidationStrategyMultiPass], entries[ConsolidationStrategyLinear], name)
+		assert.LessOrEqual(t, entries[ConsolidationStrategyLinear], entries[ConsolidationStrategyGreedy], name)
+		assert.LessOrEqual(t, entries[ConsolidationStrategyLinear], entries[ConsolidationStrategyPlanes], name)
+	}
+
+	strategy, err := ParseConsolidationStrategy("")
+	require.NoError(t, err)
+	assert.Equal(t, ConsolidationStrategyLinear, strategy)
+	strategy, err = ParseConsolidationStrategy("planes")
+	require.NoError(t, err)
+	assert.Equal(t, ConsolidationStrategyPlanes, strategy)
+	_, err = ParseConsolidationStrategy("bubble")
+	assert.Error(t, err)
+}
+
+func BenchmarkConsolidationStrategies(b *testing.B) {
+	for name, tree := range consolidationTestTrees() {
+		// The ranges are collected once, as only the consolidation is measured
+		var inputRanges, outputRanges []rangeBounds
+		iter := tree.Iterator()
+		for inputEncoding, outputEncoding, ok := iter.Next(); ok; inputEncoding, outputEncoding, ok = iter.Next() {
+			rc := NewRangeMapConstructor()
+			rc.AddValidEncoding(inputEncoding, outputEncoding)
+			inputRanges = append(inputRanges, rc.inputEnc[0])
+			outputRanges = append(outputRanges, rc.outputEnc[0])
+		}
+		for _, strategy := range ConsolidationStrategies() {
+			b.Run(name+"/"+string(strategy), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					// Merging modifies the ranges, so each iteration consolidates a copy
+					inputCopy := make([]rangeBounds, len(inputRanges))
+					outputCopy := make([]rangeBounds, len(outputRanges))
+					for j := range inputRanges {
+						inputCopy[j] = append(rangeBounds(nil), inputRanges[j]...)
+						outputCopy[j] = append(rangeBounds(nil), outputRanges[j]...)
+					}
+					strategy.consolidate(inputCopy, outputCopy)
+				}
+			})
+		}
+	}
+}
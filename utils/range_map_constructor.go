@@ -15,7 +15,9 @@
 package utils
 
 import (
+	"bytes"
 	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -24,6 +26,16 @@ import (
 type RangeMapConstructor struct {
 	inputEnc  []rangeBounds
 	outputEnc []rangeBounds
+	// These track whether the codepoints were given in the order that AddValidEncoding requires
+	lastInput       []byte
+	direction       int
+	finishedLengths map[int]struct{}
+	orderErr        error
+	// inputWordSize and linearEntries are given to the constructed RangeMap
+	inputWordSize int
+	linearEntries []linearRangeMapEntry
+	// consolidation is the strategy that merges the ranges, which defaults to ConsolidationStrategyLinear
+	consolidation ConsolidationStrategy
 }
 
 // rangeBounds represents the minimum and maximum values for each section of this specific range. The byte at index 0
@@ -32,7 +44,112 @@ type rangeBounds [][2]byte
 
 // NewRangeMapConstructor returns a new RangeMapConstructor.
 func NewRangeMapConstructor() *RangeMapConstructor {
-	return &RangeMapConstructor{}
+	return &RangeMapConstructor{finishedLengths: make(map[int]struct{})}
+}
+
+// RangeMapOptions control how a RangeMap is constructed from a tree, which depend on the structure of the character
+// set's encoding. The zero value is correct for every character set, but may produce far more entries for some.
+type RangeMapOptions struct {
+	// InputWordSize is the size of each little-endian word of the tree's input encodings (such as 2 for utf16le).
+	// Consecutive little-endian codepoints do not differ in their last byte, so they cannot be consolidated, and the
+	// constructor is therefore given each word in big-endian order.
+	InputWordSize int
+	// LinearRunLength is the minimum number of consecutive multi-byte codepoints that map to consecutive runes, which
+	// are represented as a single linear entry rather than consolidated ranges. Zero disables linear entries.
+	LinearRunLength int
+	// Consolidation is the strategy that merges the ranges, which defaults to ConsolidationStrategyLinear when empty.
+	Consolidation ConsolidationStrategy
+	// Replacement is the rune that DecodeModeReplace substitutes for invalid byte sequences, which defaults to '?' when
+	// zero, matching the server.
+	Replacement rune
+}
+
+// RangeMapFromTree constructs a RangeMap from the given tree, where the tree's input encodings are the RangeMap's input
+// encodings, and the tree's data are the output encodings. The RangeMap is verified against every encoding in the
+// tree before returning, so no further validation is necessary.
+func RangeMapFromTree(tree *CharacterSetEncodingTree) (*RangeMap, error) {
+	return RangeMapFromTreeWithOptions(tree, RangeMapOptions{})
+}
+
+// IdentityRangeMap returns a RangeMap whose input encoding is UTF8, such that every valid rune decodes to itself. This
+// is the RangeMap of the `binary` character set, whose bytes are never converted.
+func IdentityRangeMap() (*RangeMap, error) {
+	tree := NewCharacterSetEncodingTree()
+	iter := NewUTF8Iter()
+	for r, ok := iter.Next(); ok; r, ok = iter.Next() {
+		encoding := []byte(string(r))
+		node := tree
+		for _, val := range encoding {
+			node = node.AddChild(val)
+		}
+		node.SetData(encoding)
+	}
+	return RangeMapFromTree(tree)
+}
+
+// RangeMapFromTreeWithOptions is the same as RangeMapFromTree, using the given options.
+func RangeMapFromTreeWithOptions(tree *CharacterSetEncodingTree, options RangeMapOptions) (*RangeMap, error) {
+	// The iterator returns the encodings in the order that the constructor requires
+	var pairs []encodingPair
+	iter := tree.Iterator()
+	for inputEncoding, outputEncoding, ok := iter.Next(); ok; inputEncoding, outputEncoding, ok = iter.Next() {
+		if options.InputWordSize > 1 && len(inputEncoding)%options.InputWordSize != 0 {
+			return nil, fmt.Errorf("encoding %v is not made of %d byte words", inputEncoding, options.InputWordSize)
+		}
+		pairs = append(pairs, encodingPair{input: reverseWords(inputEncoding, options.InputWordSize), output: outputEncoding})
+	}
+	if options.InputWordSize > 1 {
+		// Reversing the words changes the order of the encodings, so they're sorted again
+		sort.SliceStable(pairs, func(i, j int) bool {
+			if len(pairs[i].input) != len(pairs[j].input) {
+				return len(pairs[i].input) < len(pairs[j].input)
+			}
+			return bytes.Compare(pairs[i].input, pairs[j].input) < 0
+		})
+	}
+	rangeMapConstructor := NewRangeMapConstructor()
+	rangeMapConstructor.SetInputWordSize(options.InputWordSize)
+	rangeMapConstructor.SetConsolidationStrategy(options.Consolidation)
+	if options.LinearRunLength > 0 {
+		pairs, rangeMapConstructor.linearEntries = extractLinearRuns(pairs, options.LinearRunLength)
+	}
+	for _, pair := range pairs {
+		rangeMapConstructor.AddValidEncoding(pair.input, pair.output)
+	}
+	if err := rangeMapConstructor.OrderingError(); err != nil {
+		return nil, err
+	}
+	rangeMap := rangeMapConstructor.Map()
+	rangeMap.replacement = options.Replacement
+
+	// Verify that the range map returns the correct results for all valid inputs
+	iter = tree.Iterator()
+	for inputEncoding, outputEncoding, ok := iter.Next(); ok; inputEncoding, outputEncoding, ok = iter.Next() {
+		generatedOutputEncoding, ok := rangeMap.Decode(inputEncoding)
+		if !ok || !bytes.Equal(outputEncoding, generatedOutputEncoding) {
+			return nil, fmt.Errorf("decoding %v returned %v instead of %v",
+				inputEncoding, generatedOutputEncoding, outputEncoding)
+		}
+		generatedInputEncoding, ok := rangeMap.Encode(outputEncoding)
+		if !ok || !bytes.Equal(inputEncoding, generatedInputEncoding) {
+			return nil, fmt.Errorf("encoding %v returned %v instead of %v",
+				outputEncoding, generatedInputEncoding, inputEncoding)
+		}
+	}
+	return rangeMap, nil
+}
+
+// SetInputWordSize sets the size of each little-endian word of the input encoding, which the constructed RangeMap
+// reverses before searching its entries. The input codepoints given to AddValidEncoding must already have the bytes of
+// each word reversed, which is handled by RangeMapFromTreeWithOptions.
+func (rc *RangeMapConstructor) SetInputWordSize(size int) {
+	rc.inputWordSize = size
+}
+
+// SetConsolidationStrategy sets the strategy that merges the ranges when Map is called. An empty strategy uses
+// ConsolidationStrategyLinear.
+func (rc *RangeMapConstructor) SetConsolidationStrategy(strategy ConsolidationStrategy) {
+	rc.consolidation = strategy
 }
 
 // AddValidEncoding adds the given codepoints to the constructor. It is assumed that these two codepoints are equivalent
@@ -40,11 +157,15 @@ func NewRangeMapConstructor() *RangeMapConstructor {
 // ascending or descending. Lastly, it does not matter if the sorted codepoints start with the shortest of longest
 // slice lengths. It only matters that all codepoints of a specific length are given before any other lengths are seen.
 // All codepoints returned by CharacterSetEncodingIterator will be in the correct order, and is the recommended way to
-// populate the constructor.
+// populate the constructor. Codepoints from any other source should be given to an EncodingBuffer, which will reorder
+// them as needed. Violations of the ordering are reported by OrderingError.
 func (rc *RangeMapConstructor) AddValidEncoding(inputCodepoint []byte, outputCodepoint []byte) {
 	if len(inputCodepoint) == 0 {
 		return
 	}
+	if rc.orderErr == nil {
+		rc.orderErr = rc.checkOrder(inputCodepoint)
+	}
 	newInputRange := make(rangeBounds, len(inputCodepoint))
 	newOutputRange := make(rangeBounds, len(outputCodepoint))
 	for i, val := range inputCodepoint {
@@ -57,12 +178,56 @@ func (rc *RangeMapConstructor) AddValidEncoding(inputCodepoint []byte, outputCod
 	rc.outputEnc = append(rc.outputEnc, newOutputRange)
 }
 
+// OrderingError returns an error if the codepoints given to AddValidEncoding did not follow the required ordering. A
+// RangeMap created from an incorrectly ordered constructor may contain incorrect ranges, so this should be checked
+// before calling Map.
+func (rc *RangeMapConstructor) OrderingError() error {
+	return rc.orderErr
+}
+
+// checkOrder returns an error if the given codepoint does not follow the previously given codepoint in the order that
+// AddValidEncoding requires.
+func (rc *RangeMapConstructor) checkOrder(inputCodepoint []byte) error {
+	lastInput := rc.lastInput
+	rc.lastInput = append([]byte(nil), inputCodepoint...)
+	if lastInput == nil {
+		return nil
+	}
+	if len(lastInput) != len(inputCodepoint) {
+		rc.finishedLengths[len(lastInput)] = struct{}{}
+		rc.direction = 0
+		if _, ok := rc.finishedLengths[len(inputCodepoint)]; ok {
+			return fmt.Errorf("codepoint %v was given after codepoints of a different length", inputCodepoint)
+		}
+		return nil
+	}
+	comparison := bytes.Compare(lastInput, inputCodepoint)
+	if comparison == 0 {
+		return fmt.Errorf("codepoint %v was given multiple times", inputCodepoint)
+	}
+	if rc.direction == 0 {
+		rc.direction = comparison
+	} else if rc.direction != comparison {
+		return fmt.Errorf("codepoint %v was not given in sorted order", inputCodepoint)
+	}
+	return nil
+}
+
 // Map creates a RangeMap based on the codepoints given to this constructor.
 func (rc *RangeMapConstructor) Map() *RangeMap {
 	// We consolidate the ranges as we want to iterate through as few ranges as possible
 	rc.consolidateRanges()
-	// Largest encoding has a length of 4, so we set that here.
-	rm := &RangeMap{make([][]rangeMapEntry, 4), make([][]rangeMapEntry, 4)}
+	// Largest encoding of most character sets has a length of 4, so we set that here unless a longer encoding exists.
+	maxLength := 4
+	for rangeIdx, inputRange := range rc.inputEnc {
+		if len(inputRange) > maxLength {
+			maxLength = len(inputRange)
+		}
+		if len(rc.outputEnc[rangeIdx]) > maxLength {
+			maxLength = len(rc.outputEnc[rangeIdx])
+		}
+	}
+	rm := &RangeMap{inputEntries: make([][]rangeMapEntry, maxLength), outputEntries: make([][]rangeMapEntry, maxLength), inputWordSize: rc.inputWordSize, linearEntries: rc.linearEntries}
 	for rangeIdx, inputRange := range rc.inputEnc {
 		outputRange := rc.outputEnc[rangeIdx]
 		// Multipliers are equivalent to powers in a traditional number encoding. Let's use binary for example. The
@@ -96,50 +261,14 @@ func (rc *RangeMapConstructor) Map() *RangeMap {
 		rm.inputEntries[len(inputRange)-1] = append(rm.inputEntries[len(inputRange)-1], entry)
 		rm.outputEntries[len(outputRange)-1] = append(rm.outputEntries[len(outputRange)-1], entry)
 	}
+	rm.index()
+	rm.asciiCompatible = rm.IsASCIICompatible()
 	return rm
 }
 
-// consolidateRanges is a highly inefficient way of reducing the number of ranges down to the absolute minimum. This
-// loops repeatedly over newly created slices until no changes are made, similar to bubble sort. Although it's terrible,
-// it works, and computers are fast enough that this takes only milliseconds (and only needs to run once).
-//
-// On each loop, we compare the next range set with the previous range set (both input and output). If both sets of
-// ranges have only a single difference (or no differences), then we merge the current range set with the previous range
-// set. If there are multiple differences, then we add the new range set. Differences represent changes that may be
-// merged. Too many differences and the ranges are not mergeable. This ensures that there is a sequential mapping
-// between the input and the output.
+// consolidateRanges reduces the number of ranges using the constructor's ConsolidationStrategy.
 func (rc *RangeMapConstructor) consolidateRanges() {
-	loop := true
-	for loop {
-		loop = false
-		var newInputRanges []rangeBounds
-		var newOutputRanges []rangeBounds
-		for rangeIdx := 0; rangeIdx < len(rc.inputEnc); rangeIdx++ {
-			currentInputRange := rc.inputEnc[rangeIdx]
-			currentOutputRange := rc.outputEnc[rangeIdx]
-			if len(newInputRanges) == 0 {
-				newInputRanges = append(newInputRanges, currentInputRange)
-				newOutputRanges = append(newOutputRanges, currentOutputRange)
-				continue
-			}
-			lastInputRange := newInputRanges[len(newInputRanges)-1]
-			lastOutputRange := newOutputRanges[len(newOutputRanges)-1]
-			inputDifferences := lastInputRange.differences(currentInputRange)
-			outputDifferences := lastOutputRange.differences(currentOutputRange)
-			if inputDifferences <= 1 && outputDifferences <= 1 {
-				lastInputRange.merge(currentInputRange)
-				lastOutputRange.merge(currentOutputRange)
-				loop = true
-				continue
-			} else {
-				newInputRanges = append(newInputRanges, currentInputRange)
-				newOutputRanges = append(newOutputRanges, currentOutputRange)
-				continue
-			}
-		}
-		rc.inputEnc = newInputRanges
-		rc.outputEnc = newOutputRanges
-	}
+	rc.inputEnc, rc.outputEnc = rc.consolidation.consolidate(rc.inputEnc, rc.outputEnc)
 }
 
 // boundsContains returns whether the right bounds are contained within the left bounds.
@@ -175,6 +304,17 @@ func (r rangeBounds) contains(data []byte) bool {
 	return true
 }
 
+// prefixLength returns the number of leading bytes from the data that fall within the range bounds. Assumes that the
+// length of the data matches the length of the range bounds.
+func (r rangeBounds) prefixLength(data []byte) int {
+	for i := 0; i < len(r); i++ {
+		if r[i][0] > data[i] || r[i][1] < data[i] {
+			return i
+		}
+	}
+	return len(r)
+}
+
 // differences returns the number of allowable differences between the given range bounds and the calling range bounds.
 // As we only merge on a single difference (or no differences), if the two range bounds are completely incompatible
 // (such as not being adjacent or having different lengths) then we return a value of 2.
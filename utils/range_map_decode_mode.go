@@ -0,0 +1,90 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+// DecodeMode is how DecodeAll handles byte sequences that cannot be decoded.
+type DecodeMode uint8
+
+const (
+	// DecodeModeStrict stops at the first byte sequence that cannot be decoded, returning a TranscodeError.
+	DecodeModeStrict DecodeMode = iota
+	// DecodeModeReplace substitutes the RangeMap's replacement rune for every byte that cannot be decoded, and then
+	// continues with the next byte. This matches how the server converts malformed data.
+	DecodeModeReplace
+)
+
+// Replacement returns the rune that DecodeModeReplace substitutes for invalid byte sequences.
+func (rm *RangeMap) Replacement() rune {
+	if rm.replacement == 0 {
+		return '?'
+	}
+	return rm.replacement
+}
+
+// DecodeAll converts an entire string from the input encoding to the output encoding, whereas Decode only converts a
+// single codepoint. Each codepoint is the longest prefix of the remaining data that decodes. In DecodeModeStrict, the
+// TranscodeError's position is relative to the start of the string.
+func (rm *RangeMap) DecodeAll(data []byte, mode DecodeMode) ([]byte, error) {
+	maxLength := rm.maxInputLength()
+	outputData := make([]byte, 0, len(data))
+	for position := 0; position < len(data); {
+		length := maxLength
+		if remaining := len(data) - position; remaining < length {
+			length = remaining
+		}
+		var decoded []byte
+		ok := false
+		for ; length > 0; length-- {
+			if decoded, ok = rm.Decode(data[position : position+length]); ok {
+				break
+			}
+		}
+		if ok {
+			outputData = append(outputData, decoded...)
+			position += length
+			continue
+		}
+		if mode == DecodeModeStrict {
+			return nil, rm.decodeAllError(data, position, maxLength)
+		}
+		outputData = append(outputData, string(rm.Replacement())...)
+		position++
+	}
+	return outputData, nil
+}
+
+// decodeAllError returns the TranscodeError for the data at the given position, which could not be decoded. The error
+// is unmappable if any length of the data at the position is a valid byte sequence, and invalid otherwise.
+func (rm *RangeMap) decodeAllError(data []byte, position int, maxLength int) *TranscodeError {
+	end := position + maxLength
+	if end > len(data) {
+		end = len(data)
+	}
+	var transcodeErr *TranscodeError
+	for length := end - position; length > 0; length-- {
+		_, err := rm.DecodeWithError(data[position : position+length])
+		if lengthErr, ok := err.(*TranscodeError); ok && (transcodeErr == nil || lengthErr.Kind == TranscodeErrorUnmappable) {
+			transcodeErr = lengthErr
+			if lengthErr.Kind == TranscodeErrorUnmappable {
+				break
+			}
+		}
+	}
+	if transcodeErr == nil {
+		return &TranscodeError{Kind: TranscodeErrorInvalid, Position: position, Data: data[position:end]}
+	}
+	transcodeErr.Position += position
+	return transcodeErr
+}
@@ -0,0 +1,53 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeAll(t *testing.T) {
+	tree := consolidationTestTrees()["euc"]
+	rangeMap, err := RangeMapFromTree(tree)
+	require.NoError(t, err)
+	// The trail byte 0xA8 is missing from the EUC layout
+	data := []byte{'A', 0xA1, 0xA9, 0xA1, 0xA8, 'B'}
+
+	_, err = rangeMap.DecodeAll(data, DecodeModeStrict)
+	var transcodeErr *TranscodeError
+	require.ErrorAs(t, err, &transcodeErr)
+	assert.Equal(t, TranscodeErrorInvalid, transcodeErr.Kind)
+	assert.Equal(t, 4, transcodeErr.Position)
+	decoded, err := rangeMap.DecodeAll(data[:3], DecodeModeStrict)
+	require.NoError(t, err)
+	assert.Equal(t, "A〈", string(decoded))
+
+	// Each byte that cannot be decoded is replaced on its own
+	decoded, err = rangeMap.DecodeAll(data, DecodeModeReplace)
+	require.NoError(t, err)
+	assert.Equal(t, "A〈??B", string(decoded))
+
+	rangeMap, err = RangeMapFromTreeWithOptions(tree, RangeMapOptions{Replacement: '�'})
+	require.NoError(t, err)
+	parsed, _, err := ParseRangeMapGoFile(RangeMapToGoFile(rangeMap, CaseMappings{}, "euc"))
+	require.NoError(t, err)
+	assert.Equal(t, '�', parsed.Replacement())
+	decoded, err = parsed.DecodeAll(data, DecodeModeReplace)
+	require.NoError(t, err)
+	assert.Equal(t, "A〈��B", string(decoded))
+}
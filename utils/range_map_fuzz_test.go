@@ -0,0 +1,147 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+	"unicode/utf8"
+)
+
+// fuzzCharacterSet returns a random character set as a map from each input encoding to its rune, along with a tree of
+// the same encodings. The lead byte determines the length of each encoding (one byte below 0x80, two below 0xE0, and
+// three otherwise), so that no encoding is a prefix of another. Runs of sequential encodings are mapped to sequential
+// runes, as real character sets mostly consist of such runs, which the consolidation merges.
+func fuzzCharacterSet(rng *rand.Rand, size int) (map[string]rune, *CharacterSetEncodingTree) {
+	encodings := make(map[string]rune)
+	used := make(map[rune]struct{})
+	randomEncoding := func() []byte {
+		var encoding []byte
+		switch rng.Intn(3) {
+		case 0:
+			encoding = []byte{byte(rng.Intn(0x80))}
+		case 1:
+			encoding = []byte{byte(0x80 + rng.Intn(0x60)), byte(rng.Intn(256))}
+		default:
+			encoding = []byte{byte(0xE0 + rng.Intn(0x20)), byte(rng.Intn(256)), byte(rng.Intn(256))}
+		}
+		return encoding
+	}
+	randomRune := func() rune {
+		for {
+			r := rune(rng.Intn(utf8.MaxRune + 1))
+			if _, ok := used[r]; !ok && utf8.ValidRune(r) {
+				return r
+			}
+		}
+	}
+	for len(encodings) < size {
+		encoding := randomEncoding()
+		r := randomRune()
+		runLength := 1 + rng.Intn(32)
+		for i := 0; i < runLength && len(encodings) < size; i++ {
+			if _, ok := encodings[string(encoding)]; ok {
+				break
+			}
+			if _, ok := used[r]; ok || !utf8.ValidRune(r) {
+				break
+			}
+			encodings[string(encoding)] = r
+			used[r] = struct{}{}
+			// The last byte is incremented without carrying, so that the run stays within the lead byte's length
+			if encoding[len(encoding)-1] == 0xFF || (len(encoding) == 1 && encoding[0] == 0x7F) {
+				break
+			}
+			encoding = append([]byte(nil), encoding...)
+			encoding[len(encoding)-1]++
+			r++
+		}
+	}
+	tree := NewCharacterSetEncodingTree()
+	for encoding, r := range encodings {
+		node := tree
+		for _, val := range []byte(encoding) {
+			node = node.AddChild(val)
+		}
+		node.SetData([]byte(string(r)))
+	}
+	return encodings, tree
+}
+
+func FuzzRangeMapRoundTrip(f *testing.F) {
+	f.Add(int64(1), uint16(1), uint8(0), false)
+	f.Add(int64(2), uint16(200), uint8(1), false)
+	f.Add(int64(3), uint16(1000), uint8(2), true)
+	f.Add(int64(4), uint16(3000), uint8(3), true)
+	f.Fuzz(func(t *testing.T, seed int64, size uint16, strategy uint8, linear bool) {
+		rng := rand.New(rand.NewSource(seed))
+		encodings, tree := fuzzCharacterSet(rng, int(size%4096)+1)
+		options := RangeMapOptions{Consolidation: ConsolidationStrategies()[int(strategy)%len(ConsolidationStrategies())]}
+		if linear {
+			options.LinearRunLength = 8
+		}
+		rangeMap, err := RangeMapFromTreeWithOptions(tree, options)
+		if err != nil {
+			t.Fatalf("unable to construct the RangeMap: %v", err)
+		}
+		for encoding, r := range encodings {
+			decoded, ok := rangeMap.Decode([]byte(encoding))
+			if !ok || string(decoded) != string(r) {
+				t.Fatalf("decoding %v returned %v rather than `%s`", []byte(encoding), decoded, string(r))
+			}
+			encoded, ok := rangeMap.Encode([]byte(string(r)))
+			if !ok || !bytes.Equal(encoded, []byte(encoding)) {
+				t.Fatalf("encoding `%s` returned %v rather than %v", string(r), encoded, []byte(encoding))
+			}
+		}
+		// Encodings and runes outside of the character set must be rejected, rather than mapped by an overly broad range
+		for i := 0; i < 256; i++ {
+			encoding := make([]byte, 1+rng.Intn(3))
+			rng.Read(encoding)
+			if _, ok := encodings[string(encoding)]; !ok {
+				if decoded, ok := rangeMap.Decode(encoding); ok {
+					t.Fatalf("decoding %v returned %v rather than failing", encoding, decoded)
+				}
+			}
+			r := rune(rng.Intn(utf8.MaxRune + 1))
+			if !utf8.ValidRune(r) {
+				continue
+			}
+			if _, ok := rangeMap.Encode([]byte(string(r))); ok {
+				found := false
+				for _, encodedRune := range encodings {
+					if encodedRune == r {
+						found = true
+						break
+					}
+				}
+				if !found {
+					t.Fatalf("encoding `%s` succeeded rather than failing", string(r))
+				}
+			}
+		}
+		// The generated file must contain the same ranges
+		parsed, _, err := ParseRangeMapGoFile(RangeMapToGoFile(rangeMap, CaseMappings{}, "fuzz"))
+		if err != nil {
+			t.Fatalf("unable to parse the generated file: %v", err)
+		}
+		for encoding, r := range encodings {
+			if decoded, ok := parsed.Decode([]byte(encoding)); !ok || string(decoded) != string(r) {
+				t.Fatalf("the generated file decodes %v to %v rather than `%s`", []byte(encoding), decoded, string(r))
+			}
+		}
+	})
+}
@@ -0,0 +1,227 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"go/ast"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// linearRangeMapEntry maps a run of consecutive input codepoints to a run of consecutive runes. The input codepoints
+// are numbered by their position within inputRange (using inputMults, the same as a rangeMapEntry), and the codepoints
+// numbered from first to last map to the runes starting at firstRune. Character sets such as gb18030 number their
+// four-byte codepoints sequentially, yet the numbering does not align with the byte boundaries of UTF8, so the same
+// runs would otherwise require thousands of rangeMapEntry values.
+type linearRangeMapEntry struct {
+	inputRange rangeBounds
+	inputMults []int
+	first      int
+	last       int
+	firstRune  rune
+}
+
+// decode returns the rune of the given input codepoint, if it's within the entry.
+func (entry linearRangeMapEntry) decode(data []byte) (rune, bool) {
+	if len(data) != len(entry.inputRange) || !entry.inputRange.contains(data) {
+		return 0, false
+	}
+	idx := 0
+	for i := range data {
+		idx += int(data[i]-entry.inputRange[i][0]) * entry.inputMults[i]
+	}
+	if idx < entry.first || idx > entry.last {
+		return 0, false
+	}
+	return entry.firstRune + rune(idx-entry.first), true
+}
+
+// encode returns the input codepoint of the given rune, if it's within the entry.
+func (entry linearRangeMapEntry) encode(r rune) ([]byte, bool) {
+	if r < entry.firstRune || r > entry.firstRune+rune(entry.last-entry.first) {
+		return nil, false
+	}
+	return entry.input(entry.first + int(r-entry.firstRune)), true
+}
+
+// input returns the input codepoint with the given number.
+func (entry linearRangeMapEntry) input(idx int) []byte {
+	data := make([]byte, len(entry.inputRange))
+	for i := range data {
+		diff := idx / entry.inputMults[i]
+		data[i] = entry.inputRange[i][0] + byte(diff)
+		idx -= diff * entry.inputMults[i]
+	}
+	return data
+}
+
+// goString returns the entry as a string that would be valid in a Go application.
+func (entry linearRangeMapEntry) goString() string {
+	inputMults := make([]string, len(entry.inputMults))
+	for i, mult := range entry.inputMults {
+		inputMults[i] = strconv.FormatInt(int64(mult), 10)
+	}
+	return fmt.Sprintf(`		{
+			inputRange: %s,
+			inputMults: []int{%s},
+			first:      %d,
+			last:       %d,
+			firstRune:  %d,
+		},
+`, entry.inputRange.goString(), strings.Join(inputMults, ", "), entry.first, entry.last, entry.firstRune)
+}
+
+// encodingPair is an input codepoint along with its output codepoint.
+type encodingPair struct {
+	input  []byte
+	output []byte
+}
+
+// extractLinearRuns removes every run of at least minLength codepoints that map to consecutive runes, returning the
+// remaining codepoints (in their original order) along with a linearRangeMapEntry for each run. The codepoints must be
+// sorted by their length and then by their value, which is also the order of their numbering. Each length is numbered
+// within the smallest bounds that contain every codepoint of that length, and single-byte codepoints are never removed.
+func extractLinearRuns(pairs []encodingPair, minLength int) ([]encodingPair, []linearRangeMapEntry) {
+	bounds := make(map[int]rangeBounds)
+	for _, pair := range pairs {
+		if len(pair.input) < 2 {
+			continue
+		}
+		lengthBounds, ok := bounds[len(pair.input)]
+		if !ok {
+			lengthBounds = make(rangeBounds, len(pair.input))
+			for i, val := range pair.input {
+				lengthBounds[i] = [2]byte{val, val}
+			}
+			bounds[len(pair.input)] = lengthBounds
+		}
+		for i, val := range pair.input {
+			lengthBounds[i] = lengthBounds.boundsMinMax(lengthBounds[i], [2]byte{val, val})
+		}
+	}
+	mults := make(map[int][]int)
+	for length, lengthBounds := range bounds {
+		lengthMults := make([]int, length)
+		mult := 1
+		for i := length - 1; i >= 0; i-- {
+			lengthMults[i] = mult
+			mult *= int(lengthBounds[i][1]-lengthBounds[i][0]) + 1
+		}
+		mults[length] = lengthMults
+	}
+
+	var entries []linearRangeMapEntry
+	removed := make([]bool, len(pairs))
+	// Returns the number and rune of the codepoint, or false if it cannot be part of a run
+	numbering := func(pair encodingPair) (int, rune, bool) {
+		lengthBounds, ok := bounds[len(pair.input)]
+		if !ok {
+			return 0, 0, false
+		}
+		r, size := utf8.DecodeRune(pair.output)
+		if r == utf8.RuneError || size != len(pair.output) {
+			return 0, 0, false
+		}
+		idx := 0
+		for i, val := range pair.input {
+			idx += int(val-lengthBounds[i][0]) * mults[len(pair.input)][i]
+		}
+		return idx, r, true
+	}
+	for start := 0; start < len(pairs); {
+		startIdx, startRune, ok := numbering(pairs[start])
+		if !ok {
+			start++
+			continue
+		}
+		end := start + 1
+		for ; end < len(pairs) && len(pairs[end].input) == len(pairs[start].input); end++ {
+			idx, r, ok := numbering(pairs[end])
+			if !ok || idx-startIdx != end-start || r-startRune != rune(end-start) {
+				break
+			}
+		}
+		if end-start >= minLength {
+			entries = append(entries, linearRangeMapEntry{
+				inputRange: bounds[len(pairs[start].input)],
+				inputMults: mults[len(pairs[start].input)],
+				first:      startIdx,
+				last:       startIdx + (end - start - 1),
+				firstRune:  startRune,
+			})
+			for i := start; i < end; i++ {
+				removed[i] = true
+			}
+		}
+		start = end
+	}
+	remaining := make([]encodingPair, 0, len(pairs))
+	for i, pair := range pairs {
+		if !removed[i] {
+			remaining = append(remaining, pair)
+		}
+	}
+	return remaining, entries
+}
+
+// parseLinearRangeMapEntries parses the linear entries of a RangeMap.
+func parseLinearRangeMapEntries(expr ast.Expr) ([]linearRangeMapEntry, error) {
+	lit, ok := expr.(*ast.CompositeLit)
+	if !ok {
+		return nil, fmt.Errorf("expected a composite literal for the linear RangeMap entries")
+	}
+	entries := make([]linearRangeMapEntry, len(lit.Elts))
+	for i, elt := range lit.Elts {
+		entryLit, ok := elt.(*ast.CompositeLit)
+		if !ok {
+			return nil, fmt.Errorf("expected a composite literal for a linear RangeMap entry")
+		}
+		for _, fieldElt := range entryLit.Elts {
+			kv, ok := fieldElt.(*ast.KeyValueExpr)
+			if !ok {
+				return nil, fmt.Errorf("linear RangeMap entry fields must be keyed")
+			}
+			key, ok := kv.Key.(*ast.Ident)
+			if !ok {
+				return nil, fmt.Errorf("linear RangeMap entry fields must be keyed by name")
+			}
+			var err error
+			var val int64
+			switch key.Name {
+			case "inputRange":
+				entries[i].inputRange, err = parseRangeBounds(kv.Value)
+			case "inputMults":
+				entries[i].inputMults, err = parseIntSlice(kv.Value)
+			case "first":
+				val, err = parseInt(kv.Value)
+				entries[i].first = int(val)
+			case "last":
+				val, err = parseInt(kv.Value)
+				entries[i].last = int(val)
+			case "firstRune":
+				val, err = parseInt(kv.Value)
+				entries[i].firstRune = rune(val)
+			default:
+				err = fmt.Errorf("unknown linear RangeMap entry field: %s", key.Name)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gb18030FourByte returns the four-byte gb18030 encoding of a supplementary rune, which are numbered sequentially from
+// 0x90308130. Each byte has a base of 126 or 10.
+func gb18030FourByte(r rune) []byte {
+	idx := 189000 + int(r-0x10000)
+	return []byte{byte(0x81 + idx/12600), byte(0x30 + idx/1260%10), byte(0x81 + idx/10%126), byte(0x30 + idx%10)}
+}
+
+func TestLinearRangeMapEntries(t *testing.T) {
+	quirks := CharacterSetQuirksFor("gb18030")
+	tree := NewCharacterSetEncodingTree()
+	add := func(encoding []byte, r rune) {
+		node := tree
+		for _, val := range encoding {
+			node = node.AddChild(val)
+		}
+		require.True(t, node.SetData([]byte(string(r))))
+	}
+	for r := rune(0); r < 0x80; r++ {
+		add([]byte{byte(r)}, r)
+	}
+	add(quirks.KnownEncodings['€'], '€')
+	add(quirks.KnownEncodings['\u0080'], '\u0080')
+	for r := rune(0x10000); r < 0x20000; r++ {
+		add(gb18030FourByte(r), r)
+	}
+	assert.Equal(t, quirks.KnownEncodings['😀'], gb18030FourByte('😀'))
+
+	rangeMap, err := RangeMapFromTreeWithOptions(tree, quirks.RangeMapOptions)
+	require.NoError(t, err)
+	assert.NoError(t, quirks.Verify(rangeMap))
+	require.Len(t, rangeMap.linearEntries, 1)
+	// Only the lone codepoint of U+0080 is too short to be a run
+	assert.Len(t, rangeMap.inputEntries[3], 1)
+	assert.True(t, rangeMap.IsASCIICompatible())
+	// The numbering of the run continues across every byte position
+	decoded, ok := rangeMap.Decode([]byte{0x90, 0x30, 0x81, 0x39})
+	require.True(t, ok)
+	assert.Equal(t, string(rune(0x10009)), string(decoded))
+	decoded, ok = rangeMap.Decode([]byte{0x90, 0x30, 0x82, 0x30})
+	require.True(t, ok)
+	assert.Equal(t, string(rune(0x1000A)), string(decoded))
+	// Codepoints outside of the run are not decoded, although they're valid for the bounds of the entry
+	_, err = rangeMap.DecodeWithError(gb18030FourByte(0x20000))
+	var transcodeErr *TranscodeError
+	require.ErrorAs(t, err, &transcodeErr)
+	assert.Equal(t, TranscodeErrorUnmappable, transcodeErr.Kind)
+	_, ok = rangeMap.Encode([]byte(string(rune(0x20000))))
+	assert.False(t, ok)
+
+	// The tree and the generated file both retain the linear entries
+	rebuilt, err := RangeMapFromTreeWithOptions(rangeMap.Tree(), quirks.RangeMapOptions)
+	require.NoError(t, err)
+	assert.Equal(t, rangeMap.linearEntries, rebuilt.linearEntries)
+	parsed, _, err := ParseRangeMapGoFile(RangeMapToGoFile(rangeMap, CaseMappings{}, "gb18030"))
+	require.NoError(t, err)
+	assert.Equal(t, rangeMap.linearEntries, parsed.linearEntries)
+	encoded, ok := parsed.Encode([]byte("😀"))
+	require.True(t, ok)
+	assert.Equal(t, quirks.KnownEncodings['😀'], encoded)
+}
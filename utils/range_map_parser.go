@@ -0,0 +1,297 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// ParseRangeMapGoFile parses a file that was previously generated by RangeMapToGoFile, returning the RangeMap along
+// with the case mappings. This allows a previous extraction to be loaded without querying the server again.
+func ParseRangeMapGoFile(src string) (rm *RangeMap, caseMappings CaseMappings, err error) {
+	file, err := parser.ParseFile(token.NewFileSet(), "", src, 0)
+	if err != nil {
+		return nil, CaseMappings{}, err
+	}
+	var toUpper, toLower [][2]rune
+	var rangeMapLit *ast.CompositeLit
+	ast.Inspect(file, func(node ast.Node) bool {
+		if rangeMapLit != nil {
+			return false
+		}
+		if unary, ok := node.(*ast.UnaryExpr); ok && unary.Op == token.AND {
+			if lit, ok := unary.X.(*ast.CompositeLit); ok {
+				if ident, ok := lit.Type.(*ast.Ident); ok && ident.Name == "RangeMap" {
+					rangeMapLit = lit
+					return false
+				}
+			}
+		}
+		return true
+	})
+	if rangeMapLit == nil {
+		return nil, CaseMappings{}, fmt.Errorf("unable to find a RangeMap declaration")
+	}
+
+	rm = &RangeMap{}
+	for _, elt := range rangeMapLit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			return nil, CaseMappings{}, fmt.Errorf("RangeMap fields must be keyed")
+		}
+		key, ok := kv.Key.(*ast.Ident)
+		if !ok {
+			return nil, CaseMappings{}, fmt.Errorf("RangeMap fields must be keyed by name")
+		}
+		switch key.Name {
+		case "inputEntries":
+			rm.inputEntries, err = parseRangeMapEntries(kv.Value)
+		case "outputEntries":
+			rm.outputEntries, err = parseRangeMapEntries(kv.Value)
+		case "inputUpperBounds", "outputUpperBounds":
+			// The upper bounds are derived from the entries, so they're recomputed once every field has been parsed
+		case "asciiCompatible":
+			// Whether the character set is ASCII compatible is derived from the entries, as it is only written for
+			// the RangeMap of this repository
+		case "inputWordSize":
+			var wordSize int64
+			wordSize, err = parseInt(kv.Value)
+			rm.inputWordSize = int(wordSize)
+		case "replacement":
+			var replacement int64
+			replacement, err = parseInt(kv.Value)
+			rm.replacement = rune(replacement)
+		case "linearEntries":
+			rm.linearEntries, err = parseLinearRangeMapEntries(kv.Value)
+		case "toUpper":
+			toUpper, err = parseRuneMap(kv.Value)
+		case "toLower":
+			toLower, err = parseRuneMap(kv.Value)
+		default:
+			err = fmt.Errorf("unknown RangeMap field: %s", key.Name)
+		}
+		if err != nil {
+			return nil, CaseMappings{}, err
+		}
+	}
+	rm.index()
+	rm.asciiCompatible = rm.IsASCIICompatible()
+
+	// The multi-rune conversions are declared outside of the RangeMap, and only exist when the character set has any
+	caseMappings = caseMappingsFromConversions(toUpper, toLower)
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.VAR {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			valueSpec := spec.(*ast.ValueSpec)
+			if len(valueSpec.Names) != 1 || len(valueSpec.Values) != 1 {
+				continue
+			}
+			var conversions map[rune]string
+			switch name := valueSpec.Names[0].Name; {
+			case strings.HasSuffix(name, "_MultiRuneToUpper"):
+				conversions = caseMappings.ToUpper
+			case strings.HasSuffix(name, "_MultiRuneToLower"):
+				conversions = caseMappings.ToLower
+			default:
+				continue
+			}
+			if err = parseRuneStringMap(valueSpec.Values[0], conversions); err != nil {
+				return nil, CaseMappings{}, err
+			}
+		}
+	}
+	return rm, caseMappings, nil
+}
+
+// parseRangeMapEntries parses the entries of a RangeMap, which are grouped by their encoding length.
+func parseRangeMapEntries(expr ast.Expr) ([][]rangeMapEntry, error) {
+	lit, ok := expr.(*ast.CompositeLit)
+	if !ok {
+		return nil, fmt.Errorf("expected a composite literal for the RangeMap entries")
+	}
+	entries := make([][]rangeMapEntry, len(lit.Elts))
+	for i, elt := range lit.Elts {
+		if ident, ok := elt.(*ast.Ident); ok && ident.Name == "nil" {
+			continue
+		}
+		lengthLit, ok := elt.(*ast.CompositeLit)
+		if !ok {
+			return nil, fmt.Errorf("expected a composite literal for the RangeMap entries of length %d", i+1)
+		}
+		for _, entryElt := range lengthLit.Elts {
+			entry, err := parseRangeMapEntry(entryElt)
+			if err != nil {
+				return nil, err
+			}
+			entries[i] = append(entries[i], entry)
+		}
+	}
+	return entries, nil
+}
+
+// parseRangeMapEntry parses a single rangeMapEntry.
+func parseRangeMapEntry(expr ast.Expr) (entry rangeMapEntry, err error) {
+	lit, ok := expr.(*ast.CompositeLit)
+	if !ok {
+		return entry, fmt.Errorf("expected a composite literal for a RangeMap entry")
+	}
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			return entry, fmt.Errorf("RangeMap entry fields must be keyed")
+		}
+		key, ok := kv.Key.(*ast.Ident)
+		if !ok {
+			return entry, fmt.Errorf("RangeMap entry fields must be keyed by name")
+		}
+		switch key.Name {
+		case "inputRange":
+			entry.inputRange, err = parseRangeBounds(kv.Value)
+		case "outputRange":
+			entry.outputRange, err = parseRangeBounds(kv.Value)
+		case "inputMults":
+			entry.inputMults, err = parseIntSlice(kv.Value)
+		case "outputMults":
+			entry.outputMults, err = parseIntSlice(kv.Value)
+		default:
+			err = fmt.Errorf("unknown RangeMap entry field: %s", key.Name)
+		}
+		if err != nil {
+			return entry, err
+		}
+	}
+	return entry, nil
+}
+
+// parseRangeBounds parses a rangeBounds literal, such as `rangeBounds{{0, 127}}`.
+func parseRangeBounds(expr ast.Expr) (rangeBounds, error) {
+	lit, ok := expr.(*ast.CompositeLit)
+	if !ok {
+		return nil, fmt.Errorf("expected a composite literal for the range bounds")
+	}
+	bounds := make(rangeBounds, len(lit.Elts))
+	for i, elt := range lit.Elts {
+		vals, err := parseIntSlice(elt)
+		if err != nil {
+			return nil, err
+		}
+		if len(vals) != 2 {
+			return nil, fmt.Errorf("range bounds must contain a minimum and maximum")
+		}
+		for j, val := range vals {
+			if val < 0 || val > 255 {
+				return nil, fmt.Errorf("range bound `%d` does not fit in a byte", val)
+			}
+			bounds[i][j] = byte(val)
+		}
+	}
+	return bounds, nil
+}
+
+// parseRuneMap parses a `map[rune]rune` literal, returning the pairs in the order that they were declared.
+func parseRuneMap(expr ast.Expr) ([][2]rune, error) {
+	lit, ok := expr.(*ast.CompositeLit)
+	if !ok {
+		return nil, fmt.Errorf("expected a composite literal for the rune map")
+	}
+	var runes [][2]rune
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			return nil, fmt.Errorf("rune map entries must be keyed")
+		}
+		key, err := parseInt(kv.Key)
+		if err != nil {
+			return nil, err
+		}
+		val, err := parseInt(kv.Value)
+		if err != nil {
+			return nil, err
+		}
+		runes = append(runes, [2]rune{rune(key), rune(val)})
+	}
+	return runes, nil
+}
+
+// parseRuneStringMap parses a composite literal of a map from rune to string, adding each entry to the given map.
+func parseRuneStringMap(expr ast.Expr, conversions map[rune]string) error {
+	lit, ok := expr.(*ast.CompositeLit)
+	if !ok {
+		return fmt.Errorf("expected a composite literal for the rune map")
+	}
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			return fmt.Errorf("rune map entries must be keyed")
+		}
+		key, err := parseInt(kv.Key)
+		if err != nil {
+			return err
+		}
+		val, ok := kv.Value.(*ast.BasicLit)
+		if !ok || val.Kind != token.STRING {
+			return fmt.Errorf("expected a string literal for rune %d", key)
+		}
+		converted, err := strconv.Unquote(val.Value)
+		if err != nil {
+			return err
+		}
+		conversions[rune(key)] = converted
+	}
+	return nil
+}
+
+// parseIntSlice parses a composite literal that only contains integers, such as `[]int{1, 2}` or `{1, 2}`.
+func parseIntSlice(expr ast.Expr) ([]int, error) {
+	lit, ok := expr.(*ast.CompositeLit)
+	if !ok {
+		return nil, fmt.Errorf("expected a composite literal of integers")
+	}
+	vals := make([]int, len(lit.Elts))
+	for i, elt := range lit.Elts {
+		val, err := parseInt(elt)
+		if err != nil {
+			return nil, err
+		}
+		vals[i] = int(val)
+	}
+	return vals, nil
+}
+
+// parseBool parses a boolean literal.
+func parseBool(expr ast.Expr) (bool, error) {
+	ident, ok := expr.(*ast.Ident)
+	if !ok || (ident.Name != "true" && ident.Name != "false") {
+		return false, fmt.Errorf("expected a boolean literal")
+	}
+	return ident.Name == "true", nil
+}
+
+// parseInt parses an integer literal.
+func parseInt(expr ast.Expr) (int64, error) {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.INT {
+		return 0, fmt.Errorf("expected an integer literal")
+	}
+	return strconv.ParseInt(lit.Value, 0, 64)
+}
@@ -0,0 +1,165 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// RangeMapReport contains the statistics of a RangeMap, along with any problems that were found by checking every
+// codepoint that the RangeMap covers. A RangeMap that was constructed by RangeMapFromTree should never have problems,
+// however a RangeMap that was parsed from a file (which may have been edited) or constructed directly might.
+type RangeMapReport struct {
+	// InputEntries is the number of input entries for each encoding length, where the first element is the number of
+	// single-byte entries.
+	InputEntries []int
+	// OutputEntries is the number of output entries for each encoding length.
+	OutputEntries []int
+	// LinearEntries is the number of linear entries.
+	LinearEntries int
+	// Codepoints is the number of input codepoints that the RangeMap is able to decode.
+	Codepoints int
+	// Overlaps contains every pair of entries that share a codepoint, which decode or encode that codepoint using
+	// whichever entry is found first.
+	Overlaps []RangeMapOverlap
+	// RoundTripFailures contains every input codepoint that does not encode back to itself after being decoded.
+	RoundTripFailures []RoundTripFailure
+}
+
+// RangeMapOverlap is a pair of entries of the same length whose bounds overlap.
+type RangeMapOverlap struct {
+	// Output is true when the output ranges overlap, and false when the input ranges overlap.
+	Output bool
+	Left   [][2]byte
+	Right  [][2]byte
+}
+
+// RoundTripFailure is an input codepoint that does not encode back to itself. Encoded is nil when the decoded output
+// could not be encoded at all.
+type RoundTripFailure struct {
+	Input   []byte
+	Decoded []byte
+	Encoded []byte
+}
+
+// String returns the overlap as a human-readable string.
+func (overlap RangeMapOverlap) String() string {
+	side := "input"
+	if overlap.Output {
+		side = "output"
+	}
+	return fmt.Sprintf("%s ranges %v and %v overlap", side, overlap.Left, overlap.Right)
+}
+
+// String returns the failure as a human-readable string.
+func (failure RoundTripFailure) String() string {
+	if failure.Encoded == nil {
+		return fmt.Sprintf("%v decodes to %v, which cannot be encoded", failure.Input, failure.Decoded)
+	}
+	return fmt.Sprintf("%v decodes to %v, which encodes to %v", failure.Input, failure.Decoded, failure.Encoded)
+}
+
+// String returns the statistics of the report as a human-readable string. The problems are not included, as there may
+// be an enormous number of them.
+func (report RangeMapReport) String() string {
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("%d codepoints", report.Codepoints))
+	for length, count := range report.InputEntries {
+		if count > 0 {
+			sb.WriteString(fmt.Sprintf(", %d input entries of length %d", count, length+1))
+		}
+	}
+	for length, count := range report.OutputEntries {
+		if count > 0 {
+			sb.WriteString(fmt.Sprintf(", %d output entries of length %d", count, length+1))
+		}
+	}
+	if report.LinearEntries > 0 {
+		sb.WriteString(fmt.Sprintf(", %d linear entries", report.LinearEntries))
+	}
+	return sb.String()
+}
+
+// Err returns an error describing the number of problems in the report, or nil if there are none.
+func (report RangeMapReport) Err() error {
+	if len(report.Overlaps) == 0 && len(report.RoundTripFailures) == 0 {
+		return nil
+	}
+	return fmt.Errorf("the RangeMap has %d overlapping entries and %d codepoints that do not round trip",
+		len(report.Overlaps), len(report.RoundTripFailures))
+}
+
+// Report returns the statistics of the RangeMap, checking every entry for overlaps and every codepoint for a round
+// trip. Every codepoint is decoded, so this takes as long as Tree.
+func (rm *RangeMap) Report() RangeMapReport {
+	report := RangeMapReport{
+		InputEntries:  make([]int, len(rm.inputEntries)),
+		OutputEntries: make([]int, len(rm.outputEntries)),
+		LinearEntries: len(rm.linearEntries),
+	}
+	for length, entryLength := range rm.inputEntries {
+		report.InputEntries[length] = len(entryLength)
+		report.Overlaps = append(report.Overlaps, findOverlaps(entryLength, inputBounds, false)...)
+	}
+	for length, entryLength := range rm.outputEntries {
+		report.OutputEntries[length] = len(entryLength)
+		report.Overlaps = append(report.Overlaps, findOverlaps(entryLength, outputBounds, true)...)
+	}
+	iter := rm.Tree().Iterator()
+	for input, decoded, ok := iter.Next(); ok; input, decoded, ok = iter.Next() {
+		report.Codepoints++
+		encoded, ok := rm.Encode(decoded)
+		if !ok {
+			report.RoundTripFailures = append(report.RoundTripFailures, RoundTripFailure{Input: input, Decoded: decoded})
+		} else if !bytes.Equal(input, encoded) {
+			report.RoundTripFailures = append(report.RoundTripFailures, RoundTripFailure{Input: input, Decoded: decoded, Encoded: encoded})
+		}
+	}
+	return report
+}
+
+// Validate returns an error if the RangeMap has any overlapping entries, or if any codepoint does not round trip. Use
+// Report to find the problems.
+func (rm *RangeMap) Validate() error {
+	return rm.Report().Err()
+}
+
+// findOverlaps returns every pair of entries whose bounds overlap. The entries must be sorted by RangeMap.index, so that
+// only the entries that start before the first byte of another entry ends need to be compared.
+func findOverlaps(entries []rangeMapEntry, bounds func(entry rangeMapEntry) rangeBounds, output bool) []RangeMapOverlap {
+	var overlaps []RangeMapOverlap
+	for i := range entries {
+		left := bounds(entries[i])
+		for j := i + 1; j < len(entries) && bounds(entries[j])[0][0] <= left[0][1]; j++ {
+			if right := bounds(entries[j]); left.overlaps(right) {
+				overlaps = append(overlaps, RangeMapOverlap{Output: output, Left: left, Right: right})
+			}
+		}
+	}
+	return overlaps
+}
+
+// overlaps returns whether every section of the given range bounds overlaps the same section of the calling range
+// bounds. Assumes that both range bounds have the same length.
+func (r rangeBounds) overlaps(other rangeBounds) bool {
+	for i := range r {
+		if r[i][1] < other[i][0] || other[i][1] < r[i][0] {
+			return false
+		}
+	}
+	return true
+}
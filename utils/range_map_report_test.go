@@ -0,0 +1,52 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRangeMapReport(t *testing.T) {
+	rangeMap, err := RangeMapFromTree(consolidationTestTrees()["euc"])
+	require.NoError(t, err)
+	report := rangeMap.Report()
+	require.NoError(t, report.Err())
+	// Fourteen of the 94 trail bytes are multiples of seven, which are missing from every row
+	assert.Equal(t, 128+94*(94-14), report.Codepoints)
+	assert.Equal(t, len(rangeMap.inputEntries[1]), report.InputEntries[1])
+	assert.Equal(t, 0, report.LinearEntries)
+	assert.NoError(t, rangeMap.Validate())
+
+	// An entry that shifts the first row by one codepoint overlaps the original entries, and the codepoints that it
+	// finds first no longer round trip
+	shifted := rangeMapEntry{
+		inputRange:  rangeBounds{{0xA1, 0xA1}, {0xA1, 0xA6}},
+		outputRange: rangeBounds{{0xE3, 0xE3}, {0x80, 0x80}, {0x81, 0x86}},
+		inputMults:  []int{6, 1},
+		outputMults: []int{6, 6, 1},
+	}
+	rangeMap.inputEntries[1] = append([]rangeMapEntry{shifted}, rangeMap.inputEntries[1]...)
+	rangeMap.index()
+	report = rangeMap.Report()
+	assert.Error(t, report.Err())
+	require.NotEmpty(t, report.Overlaps)
+	assert.False(t, report.Overlaps[0].Output)
+	require.NotEmpty(t, report.RoundTripFailures)
+	assert.Equal(t, []byte{0xA1, 0xA1}, report.RoundTripFailures[0].Input)
+	assert.Equal(t, []byte{0xA1, 0xA2}, report.RoundTripFailures[0].Encoded)
+}
@@ -0,0 +1,112 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// index sorts the entries of each length by the lower bound of their first byte, and records the running maximum of
+// the upper bound of their first byte. Together these allow an entry to be found using a binary search rather than
+// checking every entry. This must be called whenever the entries change.
+func (rm *RangeMap) index() {
+	rm.inputUpperBounds = indexEntries(rm.inputEntries, inputBounds)
+	rm.outputUpperBounds = indexEntries(rm.outputEntries, outputBounds)
+}
+
+// inputBounds returns the input range of the entry.
+func inputBounds(entry rangeMapEntry) rangeBounds {
+	return entry.inputRange
+}
+
+// outputBounds returns the output range of the entry.
+func outputBounds(entry rangeMapEntry) rangeBounds {
+	return entry.outputRange
+}
+
+// indexEntries sorts the entries of each length in place, returning the running maximum of the upper bound of each
+// entry's first byte. Entries are sorted by every lower bound, so that the order does not depend on how the entries
+// were constructed.
+func indexEntries(entries [][]rangeMapEntry, bounds func(entry rangeMapEntry) rangeBounds) [][]byte {
+	upperBounds := make([][]byte, len(entries))
+	for length, entryLength := range entries {
+		if len(entryLength) == 0 {
+			continue
+		}
+		sort.SliceStable(entryLength, func(i, j int) bool {
+			return bytes.Compare(bounds(entryLength[i]).lowerBounds(), bounds(entryLength[j]).lowerBounds()) < 0
+		})
+		upperBounds[length] = make([]byte, len(entryLength))
+		maxUpperBound := byte(0)
+		for i, entry := range entryLength {
+			if upper := bounds(entry)[0][1]; upper > maxUpperBound {
+				maxUpperBound = upper
+			}
+			upperBounds[length][i] = maxUpperBound
+		}
+	}
+	return upperBounds
+}
+
+// searchEntries returns the entry that contains the data, using the upper bounds returned by indexEntries. Entries
+// before the first running maximum that reaches the first byte end before the data, and entries from the first lower
+// bound that exceeds the first byte start after the data, so only the entries between both are checked.
+func searchEntries(entries []rangeMapEntry, upperBounds []byte, data []byte, bounds func(entry rangeMapEntry) rangeBounds) (rangeMapEntry, bool) {
+	first := sort.Search(len(upperBounds), func(i int) bool {
+		return upperBounds[i] >= data[0]
+	})
+	last := sort.Search(len(entries), func(i int) bool {
+		return bounds(entries[i])[0][0] > data[0]
+	})
+	for i := first; i < last; i++ {
+		if bounds(entries[i]).contains(data) {
+			return entries[i], true
+		}
+	}
+	return rangeMapEntry{}, false
+}
+
+// lowerBounds returns the minimum of each section of the range bounds.
+func (r rangeBounds) lowerBounds() []byte {
+	lower := make([]byte, len(r))
+	for i, bound := range r {
+		lower[i] = bound[0]
+	}
+	return lower
+}
+
+// upperBoundsToGoFile returns the upper bounds returned by indexEntries as a string that would be valid in a Go
+// application.
+func upperBoundsToGoFile(upperBounds [][]byte) string {
+	sb := strings.Builder{}
+	sb.WriteString("[][]byte{\n")
+	for _, upperBoundLength := range upperBounds {
+		if len(upperBoundLength) == 0 {
+			sb.WriteString("\t\tnil,\n")
+			continue
+		}
+		vals := make([]string, len(upperBoundLength))
+		for i, val := range upperBoundLength {
+			vals[i] = strconv.Itoa(int(val))
+		}
+		sb.WriteString(fmt.Sprintf("\t\t{%s},\n", strings.Join(vals, ", ")))
+	}
+	sb.WriteString("\t}")
+	return sb.String()
+}
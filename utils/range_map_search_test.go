@@ -0,0 +1,71 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchEntries(t *testing.T) {
+	for name, tree := range consolidationTestTrees() {
+		rangeMap, err := RangeMapFromTree(tree)
+		require.NoError(t, err, name)
+		// Every encoding is found within the same entry that checking every entry would find
+		iter := tree.Iterator()
+		for inputEncoding, outputEncoding, ok := iter.Next(); ok; inputEncoding, outputEncoding, ok = iter.Next() {
+			entries := rangeMap.inputEntries[len(inputEncoding)-1]
+			entry, ok := searchEntries(entries, rangeMap.inputUpperBounds[len(inputEncoding)-1], inputEncoding, inputBounds)
+			require.True(t, ok, "%s: %v", name, inputEncoding)
+			for _, scannedEntry := range entries {
+				if scannedEntry.inputRange.contains(inputEncoding) {
+					assert.Equal(t, scannedEntry, entry)
+					break
+				}
+			}
+			entries = rangeMap.outputEntries[len(outputEncoding)-1]
+			_, ok = searchEntries(entries, rangeMap.outputUpperBounds[len(outputEncoding)-1], outputEncoding, outputBounds)
+			require.True(t, ok, "%s: %v", name, outputEncoding)
+		}
+		parsed, _, err := ParseRangeMapGoFile(RangeMapToGoFile(rangeMap, CaseMappings{}, name))
+		require.NoError(t, err)
+		assert.Equal(t, rangeMap.inputEntries, parsed.inputEntries)
+		assert.Equal(t, rangeMap.inputUpperBounds, parsed.inputUpperBounds)
+		assert.Equal(t, rangeMap.outputUpperBounds, parsed.outputUpperBounds)
+	}
+}
+
+func TestSearchEntriesGaps(t *testing.T) {
+	rangeMap, err := RangeMapFromTree(consolidationTestTrees()["euc"])
+	require.NoError(t, err)
+	// Every seventh trail byte is missing from the EUC layout, so these fall between entries
+	for _, encoding := range [][]byte{{0xA1, 0xA8}, {0xFE, 0xFC}} {
+		_, ok := rangeMap.Decode(encoding)
+		assert.False(t, ok, "%v", encoding)
+	}
+	decoded, ok := rangeMap.Decode([]byte{0xA1, 0xA9})
+	require.True(t, ok)
+	assert.Equal(t, string(rune(0x3008)), string(decoded))
+}
+
+func BenchmarkRangeMapDecode(b *testing.B) {
+	rangeMap, err := RangeMapFromTree(consolidationTestTrees()["euc"])
+	require.NoError(b, err)
+	for i := 0; i < b.N; i++ {
+		rangeMap.Decode([]byte{0xFE, 0xFD})
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// DecodeString converts an entire string from the input encoding to the output encoding, where each codepoint is the
+// longest prefix of the remaining data that decodes. Returns a TranscodeError at the first byte sequence that cannot be
+// decoded, whose position is relative to the start of the string. This is the same as DecodeAll using
+// DecodeModeStrict.
+func (rm *RangeMap) DecodeString(data []byte) ([]byte, error) {
+	return rm.DecodeAll(data, DecodeModeStrict)
+}
+
+// EncodeString converts an entire string from the output encoding (which is UTF8 for extracted character sets) to the
+// input encoding. Returns a TranscodeError at the first rune that is invalid or cannot be encoded, whose position is
+// relative to the start of the string.
+func (rm *RangeMap) EncodeString(data []byte) ([]byte, error) {
+	inputData := make([]byte, 0, len(data))
+	for position := 0; position < len(data); {
+		_, size := utf8.DecodeRune(data[position:])
+		encoded, err := rm.EncodeWithError(data[position : position+size])
+		if err != nil {
+			transcodeErr := err.(*TranscodeError)
+			transcodeErr.Position += position
+			return nil, transcodeErr
+		}
+		inputData = append(inputData, encoded...)
+		position += size
+	}
+	return inputData, nil
+}
+
+// validInputBytes returns the ranges of bytes that are valid at each position of a codepoint of each length, which are
+// the bytes that DecodeWithError considers valid when determining whether data is invalid or unmappable. The positions
+// are in the order of the data rather than of the entries, so little-endian character sets are not reversed. Lengths
+// without any entries are nil.
+func (rm *RangeMap) validInputBytes() [][][][2]byte {
+	validBytes := make([][][][2]byte, rm.maxInputLength())
+	addBounds := func(bounds rangeBounds) {
+		length := len(bounds)
+		if validBytes[length-1] == nil {
+			validBytes[length-1] = make([][][2]byte, length)
+		}
+		// Reversing the indices of the data gives the index of the bounds that each byte is compared against
+		indices := make([]byte, length)
+		for i := range indices {
+			indices[i] = byte(i)
+		}
+		indices = reverseWords(indices, rm.inputWordSize)
+		for i := range indices {
+			validBytes[length-1][i] = append(validBytes[length-1][i], bounds[indices[i]])
+		}
+	}
+	for _, entryLength := range rm.inputEntries {
+		for _, entry := range entryLength {
+			addBounds(entry.inputRange)
+		}
+	}
+	for _, entry := range rm.linearEntries {
+		addBounds(entry.inputRange)
+	}
+	// Overlapping and adjacent ranges are merged, so that each position lists as few ranges as possible
+	for _, positions := range validBytes {
+		for i, ranges := range positions {
+			sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+			merged := ranges[:1]
+			for _, r := range ranges[1:] {
+				last := &merged[len(merged)-1]
+				if int(r[0]) <= int(last[1])+1 {
+					if r[1] > last[1] {
+						last[1] = r[1]
+					}
+				} else {
+					merged = append(merged, r)
+				}
+			}
+			positions[i] = merged
+		}
+	}
+	return validBytes
+}
+
+// validInputBytesToGoFile returns the given valid bytes as a Go literal.
+func validInputBytesToGoFile(validBytes [][][][2]byte) string {
+	sb := strings.Builder{}
+	sb.WriteString("[][][][2]byte{\n")
+	for _, positions := range validBytes {
+		if positions == nil {
+			sb.WriteString("\tnil,\n")
+			continue
+		}
+		sb.WriteString("\t{\n")
+		for _, ranges := range positions {
+			vals := make([]string, len(ranges))
+			for i, r := range ranges {
+				vals[i] = fmt.Sprintf("{%d, %d}", r[0], r[1])
+			}
+			sb.WriteString(fmt.Sprintf("\t\t{%s},\n", strings.Join(vals, ", ")))
+		}
+		sb.WriteString("\t},\n")
+	}
+	sb.WriteString("}")
+	return sb.String()
+}
+
+// stringHelpersToGoFile returns the declarations of DecodeString and EncodeString for the file that RangeMapToGoFile
+// generates, which only depend on the Decode and Encode functions of the Encoder interface. The errors distinguish
+// invalid data from valid data that cannot be mapped in the same way as TranscodeError, using the valid bytes of each
+// position rather than the RangeMap's entries, as the Encoder interface does not expose them.
+func (rm *RangeMap) stringHelpersToGoFile(titleName string, lowerName string) string {
+	return fmt.Sprintf(`
+// %[2]s_MaxCodepointLength is the length of the longest codepoint of the %[3]s character set.
+const %[2]s_MaxCodepointLength = %[4]d
+
+// %[2]s_ValidBytes contains the ranges of bytes that are valid at each position of a codepoint of each length within
+// the %[3]s character set, which distinguishes invalid byte sequences from those that are valid but unmappable.
+var %[2]s_ValidBytes = %[5]s
+
+// %[1]s_ErrInvalid is returned when data is not valid for its encoding.
+var %[1]s_ErrInvalid = errors.New("%[2]s: invalid byte sequence")
+
+// %[1]s_ErrUnmappable is returned when data is valid, but does not have an equivalent in the target encoding.
+var %[1]s_ErrUnmappable = errors.New("%[2]s: unmappable codepoint")
+
+// %[1]s_DecodeString decodes an entire string from the %[3]s character set to UTF8, where each codepoint is the
+// longest prefix of the remaining data that decodes. Returns an error wrapping %[1]s_ErrInvalid or
+// %[1]s_ErrUnmappable at the first byte sequence that cannot be decoded.
+func %[1]s_DecodeString(data []byte) ([]byte, error) {
+	output := make([]byte, 0, len(data))
+	for position := 0; position < len(data); {
+		length := %[2]s_MaxCodepointLength
+		if remaining := len(data) - position; remaining < length {
+			length = remaining
+		}
+		for ; length > 0; length-- {
+			if decoded, ok := %[1]s.Decode(data[position : position+length]); ok {
+				output = append(output, decoded...)
+				break
+			}
+		}
+		if length == 0 {
+			return nil, %[2]s_DecodeError(data, position)
+		}
+		position += length
+	}
+	return output, nil
+}
+
+// %[2]s_DecodeError returns the error for the codepoint at the given position, which could not be decoded. The error
+// wraps %[1]s_ErrUnmappable if the bytes of any codepoint length are valid at their positions, and %[1]s_ErrInvalid
+// otherwise.
+func %[2]s_DecodeError(data []byte, position int) error {
+	for length := 1; length <= len(%[2]s_ValidBytes) && position+length <= len(data); length++ {
+		valid := len(%[2]s_ValidBytes[length-1]) > 0
+		for i := 0; valid && i < length; i++ {
+			valid = false
+			for _, bounds := range %[2]s_ValidBytes[length-1][i] {
+				if data[position+i] >= bounds[0] && data[position+i] <= bounds[1] {
+					valid = true
+					break
+				}
+			}
+		}
+		if valid {
+			return fmt.Errorf("%%w at position %%d", %[1]s_ErrUnmappable, position)
+		}
+	}
+	return fmt.Errorf("%%w at position %%d", %[1]s_ErrInvalid, position)
+}
+
+// %[1]s_EncodeString encodes an entire UTF8 string to the %[3]s character set. Returns an error wrapping
+// %[1]s_ErrInvalid at the first rune that is invalid, or %[1]s_ErrUnmappable at the first rune that the character set
+// does not contain.
+func %[1]s_EncodeString(data []byte) ([]byte, error) {
+	output := make([]byte, 0, len(data))
+	for position := 0; position < len(data); {
+		r, size := utf8.DecodeRune(data[position:])
+		if r == utf8.RuneError && size <= 1 {
+			return nil, fmt.Errorf("%%w: invalid UTF8 at position %%d", %[1]s_ErrInvalid, position)
+		}
+		encoded, ok := %[1]s.Encode(data[position : position+size])
+		if !ok {
+			return nil, fmt.Errorf("%%w: rune %%q at position %%d", %[1]s_ErrUnmappable, r, position)
+		}
+		output = append(output, encoded...)
+		position += size
+	}
+	return output, nil
+}
+`, titleName, lowerName, "`"+lowerName+"`", rm.maxInputLength(), validInputBytesToGoFile(rm.validInputBytes()))
+}
@@ -0,0 +1,218 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStringTranscoding(t *testing.T) {
+	rangeMap, err := RangeMapFromTree(consolidationTestTrees()["euc"])
+	require.NoError(t, err)
+	encoded, err := rangeMap.EncodeString([]byte("A〈B"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte{'A', 0xA1, 0xA9, 'B'}, encoded)
+	decoded, err := rangeMap.DecodeString(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, "A〈B", string(decoded))
+
+	// Positions are relative to the start of the string
+	var transcodeErr *TranscodeError
+	_, err = rangeMap.DecodeString([]byte{'A', 0xA1, 0xA8})
+	require.ErrorAs(t, err, &transcodeErr)
+	assert.Equal(t, 2, transcodeErr.Position)
+	_, err = rangeMap.EncodeString([]byte("AB😀"))
+	require.ErrorAs(t, err, &transcodeErr)
+	assert.Equal(t, TranscodeErrorUnmappable, transcodeErr.Kind)
+	assert.Equal(t, 2, transcodeErr.Position)
+	_, err = rangeMap.EncodeString([]byte{'A', 0xFF})
+	require.ErrorAs(t, err, &transcodeErr)
+	assert.Equal(t, TranscodeErrorInvalid, transcodeErr.Kind)
+	assert.Equal(t, 1, transcodeErr.Position)
+
+	// The generated file declares the same helpers
+	file, err := parser.ParseFile(token.NewFileSet(), "", RangeMapToGoFile(rangeMap, CaseMappings{}, "euc"), 0)
+	require.NoError(t, err)
+	for _, name := range []string{"Euc", "euc_MaxCodepointLength", "euc_ValidBytes", "Euc_ErrInvalid", "Euc_ErrUnmappable",
+		"Euc_DecodeString", "euc_DecodeError", "Euc_EncodeString", "Euc_Name", "Euc_Lookup"} {
+		assert.NotNil(t, file.Scope.Lookup(name), name)
+	}
+}
+
+func TestTranscodeErrors(t *testing.T) {
+	// A double-byte character set whose second row is shorter than its first, so that every byte of a codepoint from
+	// the end of the second row is valid even though the codepoint is not
+	tree := NewCharacterSetEncodingTree()
+	for b := 0; b < 0x80; b++ {
+		tree.AddChild(byte(b)).SetData([]byte{byte(b)})
+	}
+	r := rune(0x3000)
+	for trail := 0xA1; trail <= 0xFE; trail++ {
+		tree.AddChild(0xA1).AddChild(byte(trail)).SetData([]byte(string(r)))
+		if trail <= 0xB0 {
+			tree.AddChild(0xA2).AddChild(byte(trail)).SetData([]byte(string(r + 0x100)))
+		}
+		r++
+	}
+	rangeMap, err := RangeMapFromTree(tree)
+	require.NoError(t, err)
+	var transcodeErr *TranscodeError
+	_, err = rangeMap.DecodeWithError([]byte{0xA2, 0xC0})
+	require.ErrorAs(t, err, &transcodeErr)
+	assert.Equal(t, TranscodeErrorUnmappable, transcodeErr.Kind)
+	assert.Equal(t, 1, transcodeErr.Position)
+	// A valid lead byte followed by a trail byte that no codepoint uses
+	_, err = rangeMap.DecodeWithError([]byte{0xA1, 0x41})
+	require.ErrorAs(t, err, &transcodeErr)
+	assert.Equal(t, TranscodeErrorInvalid, transcodeErr.Kind)
+	assert.Equal(t, 1, transcodeErr.Position)
+	_, err = rangeMap.DecodeWithError([]byte{0xFF, 0xA1})
+	require.ErrorAs(t, err, &transcodeErr)
+	assert.Equal(t, TranscodeErrorInvalid, transcodeErr.Kind)
+	assert.Equal(t, 0, transcodeErr.Position)
+	_, err = rangeMap.DecodeWithError([]byte{0xA1, 0xA1, 0xA1})
+	require.ErrorAs(t, err, &transcodeErr)
+	assert.Equal(t, TranscodeErrorInvalid, transcodeErr.Kind)
+	_, err = rangeMap.EncodeWithError([]byte("😀"))
+	require.ErrorAs(t, err, &transcodeErr)
+	assert.Equal(t, TranscodeErrorUnmappable, transcodeErr.Kind)
+	_, err = rangeMap.EncodeWithError([]byte{0xE3, 0x80})
+	require.ErrorAs(t, err, &transcodeErr)
+	assert.Equal(t, TranscodeErrorInvalid, transcodeErr.Kind)
+
+	// The generated file distinguishes the kinds using the valid bytes of each position, which must agree with the
+	// RangeMap for every string that fails to decode. The generated error is positioned at the start of the codepoint
+	// rather than at the byte that caused it.
+	validBytes := rangeMap.validInputBytes()
+	assert.Equal(t, [][][2]byte{{{0, 0x7F}}}, validBytes[0])
+	assert.Equal(t, [][][2]byte{{{0xA1, 0xA2}}, {{0xA1, 0xFE}}}, validBytes[1])
+	var inputs []generatedDecodeErrorInput
+	var expected []TranscodeErrorKind
+	for lead := 0; lead < 0x100; lead++ {
+		for trail := 0; trail < 0x100; trail++ {
+			data := []byte{'A', byte(lead), byte(trail)}
+			if _, err = rangeMap.DecodeString(data); err == nil {
+				continue
+			}
+			require.ErrorAs(t, err, &transcodeErr)
+			position := 1
+			if lead < 0x80 {
+				position = 2
+			}
+			inputs = append(inputs, generatedDecodeErrorInput{data: data, position: position})
+			expected = append(expected, transcodeErr.Kind)
+		}
+	}
+	assert.Contains(t, expected, TranscodeErrorInvalid)
+	assert.Contains(t, expected, TranscodeErrorUnmappable)
+	actual := runGeneratedDecodeError(t, RangeMapToGoFile(rangeMap, CaseMappings{}, "dbcs"), "Dbcs", "dbcs", inputs)
+	require.Len(t, actual, len(expected))
+	for i := range expected {
+		assert.Equal(t, expected[i], actual[i], "0x%X", inputs[i].data)
+	}
+}
+
+// generatedDecodeErrorInput is the data and position given to the generated DecodeError function.
+type generatedDecodeErrorInput struct {
+	data     []byte
+	position int
+}
+
+// runGeneratedDecodeError compiles the valid bytes, errors, and DecodeError function of a file generated by
+// RangeMapToGoFile into a program using the `go` command, and returns the kind of error that the generated function
+// returns for each input. This checks the generated code itself rather than a copy of it. The test is skipped when the
+// generated code cannot be compiled.
+func runGeneratedDecodeError(t *testing.T, contents string, titleName string, lowerName string, inputs []generatedDecodeErrorInput) []TranscodeErrorKind {
+	if testing.Short() {
+		t.Skip("compiles the generated file")
+	}
+	goCommand, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("the go command is not available")
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", contents, parser.ParseComments)
+	require.NoError(t, err)
+	// Only the declarations that DecodeError depends on are kept, as the rest of the file depends on GMS's RangeMap
+	wanted := map[string]bool{
+		lowerName + "_ValidBytes":    true,
+		lowerName + "_DecodeError":   true,
+		titleName + "_ErrInvalid":    true,
+		titleName + "_ErrUnmappable": true,
+	}
+	program := bytes.Buffer{}
+	program.WriteString("package main\n\nimport (\n\t\"errors\"\n\t\"fmt\"\n)\n\n")
+	found := 0
+	for _, decl := range file.Decls {
+		var name string
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			name = decl.Name.Name
+		case *ast.GenDecl:
+			if valueSpec, ok := decl.Specs[0].(*ast.ValueSpec); ok && len(decl.Specs) == 1 {
+				name = valueSpec.Names[0].Name
+			}
+		}
+		if !wanted[name] {
+			continue
+		}
+		found++
+		require.NoError(t, printer.Fprint(&program, fset, decl))
+		program.WriteString("\n\n")
+	}
+	require.Equal(t, len(wanted), found)
+	program.WriteString("var inputs = []struct {\n\tdata     []byte\n\tposition int\n}{\n")
+	for _, input := range inputs {
+		program.WriteString(fmt.Sprintf("\t{%#v, %d},\n", input.data, input.position))
+	}
+	program.WriteString(fmt.Sprintf(`}
+
+func main() {
+	for _, input := range inputs {
+		fmt.Println(errors.Is(%s_DecodeError(input.data, input.position), %s_ErrUnmappable))
+	}
+}
+`, lowerName, titleName))
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module generateddecodeerror\n\ngo 1.18\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), program.Bytes(), 0644))
+	run := exec.Command(goCommand, "run", ".")
+	run.Dir = dir
+	output, err := run.CombinedOutput()
+	require.NoError(t, err, string(output))
+	var kinds []TranscodeErrorKind
+	for _, line := range strings.Fields(string(output)) {
+		if line == "true" {
+			kinds = append(kinds, TranscodeErrorUnmappable)
+		} else {
+			kinds = append(kinds, TranscodeErrorInvalid)
+		}
+	}
+	return kinds
+}
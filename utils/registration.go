@@ -0,0 +1,84 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RegistrationToGoFile returns a Go file that registers every character set and collation within the manifest into
+// lookup tables keyed by name, so that a batch of generated files may be copied into GMS without wiring each file by
+// hand. The file references the identifiers that RangeMapToGoFile, RuneComparatorToGoFile, and
+// RuneComparatorAliasToGoFile generate, so every registered file must be copied alongside it, and must have been
+// generated with the same prefix and encoder type as the options. As the prefix replaces the name of every entry, a
+// manifest generated with a prefix may only contain a single character set and a single collation. Collations without
+// metadata use the character set from the start of their name, an ID of zero, and the MySQL flavor.
+func RegistrationToGoFile(manifest *Manifest, options CodegenOptions) string {
+	charsetsSb := strings.Builder{}
+	collationsSb := strings.Builder{}
+	// The entries are sorted the same as a saved manifest, so that the file does not depend on the order of extraction
+	entries := append([]ManifestEntry(nil), manifest.Entries...)
+	sortManifestEntries(entries)
+	for _, entry := range entries {
+		// The lookup tables are keyed by the name, while the identifiers may use the prefix instead
+		titleName, _ := options.names(entry.Name)
+		lowerName := strings.ToLower(entry.Name)
+		switch entry.Kind {
+		case ManifestKindCharset:
+			charsetsSb.WriteString(fmt.Sprintf("\t%q: %s,\n", lowerName, titleName))
+		case ManifestKindCollation:
+			metadata := CollationMetadata{Name: lowerName, Charset: strings.Split(lowerName, "_")[0]}
+			if entry.Metadata != nil {
+				metadata = *entry.Metadata
+			}
+			if metadata.Flavor == "" {
+				metadata.Flavor = ServerFlavorMySQL
+			}
+			collationsSb.WriteString(fmt.Sprintf(`	%q: {
+		CharacterSet: %q,
+		ID:           %d,
+		IsDefault:    %t,
+		RuneWeight:   %s_RuneWeight,
+		Compare:      %s_Compare,
+		PadSpace:     %s_PadSpace,
+		Flavor:       %q,
+	},
+`, lowerName, metadata.Charset, metadata.ID, metadata.IsDefault, titleName, titleName, titleName, string(metadata.Flavor)))
+		}
+	}
+	return fmt.Sprintf(`%[1]s
+
+// ExtractedCollation contains the implementation of a generated collation.
+type ExtractedCollation struct {
+	CharacterSet string
+	ID           int
+	IsDefault    bool
+	RuneWeight   func(r rune) int32
+	Compare      func(l string, r string) int
+	PadSpace     bool
+	// Flavor is the server that the collation was extracted from, which is either "mysql" or "mariadb".
+	Flavor       string
+}
+
+// ExtractedCharacterSets maps the name of each generated character set to its %[2]s.
+var ExtractedCharacterSets = map[string]%[2]s{
+%[3]s}
+
+// ExtractedCollations maps the name of each generated collation to its implementation.
+var ExtractedCollations = map[string]ExtractedCollation{
+%[4]s}
+`, options.fileHeader(), options.encoderType(), charsetsSb.String(), collationsSb.String())
+}
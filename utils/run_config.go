@@ -0,0 +1,156 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RunConfig describes a batch of extractions, so that a whole set of generated files may be reproduced from a single
+// file. Every setting uses the name of a command line flag (without the leading dash), and is passed to the command
+// of each extraction as that flag.
+type RunConfig struct {
+	// Connection contains the connection flags (such as host and port) that are passed to every extraction.
+	Connection map[string]string `yaml:"connection"`
+	// Flags are passed to every extraction, such as the output directory and package.
+	Flags map[string]string `yaml:"flags"`
+	// Extract contains each extraction, which run in order.
+	Extract []RunConfigItem `yaml:"extract"`
+}
+
+// RunConfigItem is a single extraction within a RunConfig. Exactly one of Charset, Collation, Collations, and All must
+// be set, which select the `extract charset`, `extract collation`, `extract collations`, and `extract all` commands.
+type RunConfigItem struct {
+	Charset   string `yaml:"charset"`
+	Collation string `yaml:"collation"`
+	// Collations is the character set whose every collation is extracted.
+	Collations string `yaml:"collations"`
+	All        bool   `yaml:"all"`
+	// Out, Package, and Codegen are shorthands for the flags of the same name, as they are the most common to vary.
+	Out     string `yaml:"out"`
+	Package string `yaml:"package"`
+	Codegen string `yaml:"codegen"`
+	// Flags are passed to this extraction only, overriding the flags of the config.
+	Flags map[string]string `yaml:"flags"`
+}
+
+// runConfigPathFlags are the flags whose values are paths, which are relative to the directory of the config file
+// rather than to the working directory.
+var runConfigPathFlags = map[string]struct{}{
+	"out":                 {},
+	"cache":               {},
+	"record":              {},
+	"replay":              {},
+	"socket":              {},
+	"tls-ca":              {},
+	"tls-cert":            {},
+	"tls-key":             {},
+	"header-file":         {},
+	"gms-root":            {},
+	"derived-age":         {},
+	"base":                {},
+	"weight-cache-import": {},
+}
+
+// LoadRunConfig reads the RunConfig at the given path, which is written in YAML.
+func LoadRunConfig(path string) (*RunConfig, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := &RunConfig{}
+	decoder := yaml.NewDecoder(bytes.NewReader(contents))
+	// A misspelled setting would otherwise be silently ignored
+	decoder.KnownFields(true)
+	if err = decoder.Decode(config); err != nil {
+		return nil, fmt.Errorf("`%s`: %w", path, err)
+	}
+	return config, nil
+}
+
+// Commands returns the arguments of the command that runs each extraction, in order. Relative paths are resolved
+// against the given directory, which should be the directory of the config file.
+func (config *RunConfig) Commands(dir string) ([][]string, error) {
+	var commands [][]string
+	for i, item := range config.Extract {
+		command, err := item.command()
+		if err != nil {
+			return nil, fmt.Errorf("extraction %d: %w", i+1, err)
+		}
+		flags := make(map[string]string)
+		for _, source := range []map[string]string{config.Connection, config.Flags, item.shorthandFlags(), item.Flags} {
+			for name, value := range source {
+				flags[name] = value
+			}
+		}
+		names := make([]string, 0, len(flags))
+		for name := range flags {
+			names = append(names, name)
+		}
+		// The flags are sorted so that the same config always produces the same commands
+		sort.Strings(names)
+		for _, name := range names {
+			value := flags[name]
+			if _, ok := runConfigPathFlags[name]; ok && value != "" && !filepath.IsAbs(value) {
+				value = filepath.Join(dir, value)
+			}
+			command = append(command, fmt.Sprintf("-%s=%s", name, value))
+		}
+		commands = append(commands, command)
+	}
+	return commands, nil
+}
+
+// command returns the command that the item runs, without any flags.
+func (item RunConfigItem) command() ([]string, error) {
+	var commands [][]string
+	if item.Charset != "" {
+		commands = append(commands, []string{"extract", "charset", item.Charset})
+	}
+	if item.Collation != "" {
+		commands = append(commands, []string{"extract", "collation", item.Collation})
+	}
+	if item.Collations != "" {
+		commands = append(commands, []string{"extract", "collations", "-charset=" + item.Collations})
+	}
+	if item.All {
+		commands = append(commands, []string{"extract", "all"})
+	}
+	if len(commands) != 1 {
+		return nil, fmt.Errorf("exactly one of `charset`, `collation`, `collations`, or `all` must be set")
+	}
+	return commands[0], nil
+}
+
+// shorthandFlags returns the flags of the item's shorthands that are set.
+func (item RunConfigItem) shorthandFlags() map[string]string {
+	flags := make(map[string]string)
+	if item.Out != "" {
+		flags["out"] = item.Out
+	}
+	if item.Package != "" {
+		flags["package"] = item.Package
+	}
+	if item.Codegen != "" {
+		flags["codegen"] = item.Codegen
+	}
+	return flags
+}
@@ -0,0 +1,68 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "extract.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+connection:
+  host: 127.0.0.1
+  port: 3306
+flags:
+  out: generated
+  workers: 4
+extract:
+  - charset: latin1
+  - collation: utf8mb4_0900_ai_ci
+    codegen: table
+    out: /tmp/ucs
+  - collations: latin1
+    package: latin
+    flags:
+      workers: 1
+  - all: true
+`), 0644))
+	config, err := LoadRunConfig(path)
+	require.NoError(t, err)
+	commands, err := config.Commands(dir)
+	require.NoError(t, err)
+	out := "-out=" + filepath.Join(dir, "generated")
+	assert.Equal(t, [][]string{
+		{"extract", "charset", "latin1", "-host=127.0.0.1", out, "-port=3306", "-workers=4"},
+		{"extract", "collation", "utf8mb4_0900_ai_ci", "-codegen=table", "-host=127.0.0.1", "-out=/tmp/ucs", "-port=3306", "-workers=4"},
+		{"extract", "collations", "-charset=latin1", "-host=127.0.0.1", out, "-package=latin", "-port=3306", "-workers=1"},
+		{"extract", "all", "-host=127.0.0.1", out, "-port=3306", "-workers=4"},
+	}, commands)
+
+	// Each extraction must select exactly one command
+	config.Extract = append(config.Extract, RunConfigItem{Charset: "latin1", Collation: "latin1_bin"})
+	_, err = config.Commands(dir)
+	assert.Error(t, err)
+
+	// Misspelled settings are errors rather than being ignored
+	require.NoError(t, os.WriteFile(path, []byte("extract:\n  - charst: latin1\n"), 0644))
+	_, err = LoadRunConfig(path)
+	assert.Error(t, err)
+}
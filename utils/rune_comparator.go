@@ -15,22 +15,37 @@
 package utils
 
 import (
+	"bytes"
 	"fmt"
+	"sort"
 	"strings"
-	"time"
 )
 
 // RuneComparator stores runes by their relative weights, such that any rune may be compared to any other rune. This is
 // useful for generating code that collations will depend on.
 type RuneComparator struct {
-	// The index of values is used as the weight. All runes on the same index (belonging to the same rune slice) have
-	// the same weight. A greater weight (higher index) sorts after a lower weight. Rune slices are shuffled around to
-	// preserve this relational ordering. This results in fairly expensive operations, which are a non-issue for our
-	// use case.
-	values     [][]rune
+	// The rows are held in order across the blocks, and the index of a row across all blocks is used as the weight. All
+	// runes on the same row (belonging to the same rune slice) have the same weight. A greater weight (higher index)
+	// sorts after a lower weight. A new row only shifts the rows within its own block, and a block is split in half
+	// once it grows beyond twice the block size, so that insertion does not shift every row of a large collation.
+	blocks     [][][]rune
+	rowCount   int
 	comparator func(l rune, r rune) int
 }
 
+// runeComparatorBlockSize is the number of rows that each block of a RuneComparator holds after being split. A block
+// holds at most twice this number of rows.
+const runeComparatorBlockSize = 512
+
+// staticWeightRangeCutoff is the default cutoff point that determines whether a static range is written as a range
+// comparison or as map entries, which is compared against the difference between the upper and lower bounds. See
+// TuneCutoffs for choosing a cutoff based on the weights.
+const staticWeightRangeCutoff = 100
+
+// dynamicWeightRangeCutoff is the default minimum number of runes within a dynamic range, as shorter runs of sequential
+// weights are written as map entries.
+const dynamicWeightRangeCutoff = 100
+
 // staticWeightRange is a sequential range of runes that all have the same weight.
 type staticWeightRange struct {
 	Weight int
@@ -47,41 +62,179 @@ type dynamicWeightRange struct {
 
 // NewRuneComparator returns a new RuneComparator.
 func NewRuneComparator() *RuneComparator {
-	return &RuneComparator{make([][]rune, 0, 1200000), nil}
+	return &RuneComparator{}
+}
+
+// NewRuneComparatorFromOrder returns a RuneComparator containing the given order, where each rune slice contains the
+// runes of a single weight, and the slices are sorted from the lowest weight to the highest. Runes within each slice
+// must be in sequential order. The comparator is not set, so SetComparator must be called before Insert is called.
+func NewRuneComparatorFromOrder(order [][]rune) *RuneComparator {
+	rc := &RuneComparator{}
+	rc.setRows(order)
+	return rc
 }
 
 // Insert adds the given rune, calling the comparator to determine where to place it. SetComparator must be called
-// before Insert is called, else a panic will occur. This assumes that runes are given in sequential order, which is
-// necessary for file generation.
+// before Insert is called, else a panic will occur. Runes are expected in sequential order, as they're appended to the
+// end of their weight's row, while an earlier rune (such as a new codepoint inserted into a loaded RuneComparator) is
+// placed in order within the row, which is necessary for file generation.
 func (rc *RuneComparator) Insert(r rune) {
-	if len(rc.values) == 0 {
-		rc.values = append(rc.values, []rune{r})
+	if rc.rowCount == 0 {
+		rc.setRows([][]rune{{r}})
 		return
 	}
 
+	// The block is the last one whose first row does not sort after the rune, or the first block when every block does
 	low := 0
-	high := len(rc.values) - 1
+	high := len(rc.blocks) - 1
+	for high-low > 0 {
+		mid := (high + low + 1) / 2
+		switch rc.comparator(r, rc.blocks[mid][0][0]) {
+		case 1:
+			low = mid
+		case -1:
+			high = mid - 1
+		case 0:
+			rc.insertIntoRow(r, mid, 0)
+			return
+		}
+	}
+	blockIdx := low
+	block := rc.blocks[blockIdx]
+
+	low = 0
+	high = len(block) - 1
 	for high-low > 0 {
 		mid := (high + low) / 2
-		comp := rc.comparator(r, rc.values[mid][0])
+		comp := rc.comparator(r, block[mid][0])
 		switch comp {
 		case 1:
 			low = mid + 1
 		case -1:
 			high = mid
 		case 0:
-			rc.values[mid] = append(rc.values[mid], r)
+			rc.insertIntoRow(r, blockIdx, mid)
 			return
 		}
 	}
-	switch rc.comparator(r, rc.values[low][0]) {
+	switch rc.comparator(r, block[low][0]) {
 	case 1:
-		rc.insertNewRow(r, low+1)
+		rc.insertNewRow(r, blockIdx, low+1)
 	case -1:
-		rc.insertNewRow(r, low)
+		rc.insertNewRow(r, blockIdx, low)
 	case 0:
-		rc.values[low] = append(rc.values[low], r)
+		rc.insertIntoRow(r, blockIdx, low)
+	}
+}
+
+// InsertWeighted adds the given runes, whose weights are all contained within the map. The runes are sorted using their
+// weights rather than the comparator, such that runes with equal weights share a row, so the comparator is never called
+// when the RuneComparator is empty. Otherwise, the runes are inserted in sequential order using Insert. Runes without a
+// weight (such as those that WEIGHT_STRING does not return a weight for) should be inserted afterward using Insert.
+func (rc *RuneComparator) InsertWeighted(runes []rune, weights map[rune][]byte) {
+	sorted := make([]rune, len(runes))
+	copy(sorted, runes)
+	if rc.rowCount > 0 {
+		sort.Slice(sorted, func(i, j int) bool {
+			return sorted[i] < sorted[j]
+		})
+		for _, r := range sorted {
+			rc.Insert(r)
+		}
+		return
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if comp := bytes.Compare(weights[sorted[i]], weights[sorted[j]]); comp != 0 {
+			return comp < 0
+		}
+		return sorted[i] < sorted[j]
+	})
+	var rows [][]rune
+	for i, r := range sorted {
+		if i > 0 && bytes.Equal(weights[sorted[i-1]], weights[r]) {
+			rows[len(rows)-1] = append(rows[len(rows)-1], r)
+		} else {
+			rows = append(rows, []rune{r})
+		}
+	}
+	rc.setRows(rows)
+}
+
+// RemoveMisordered removes every rune whose position the comparator disagrees with, and returns the removed runes in
+// sequential order. This allows the order of one collation to be reused for a similar collation (such as a language
+// tailoring of the collation that the order came from), by setting the similar collation's comparator, removing the
+// runes that it orders differently, and then inserting them again. Each rune is compared to the first rune of its row,
+// and the first rune of each row to the first rune of the next row, so the comparator is called about once per rune.
+// Either row of a pair that is out of order may be the one that moved, so both are removed, and the rows that become
+// adjacent are compared in turn, which leaves the remaining rows in order.
+func (rc *RuneComparator) RemoveMisordered() []rune {
+	var removed []rune
+	rows := rc.rows()
+	for i, row := range rows {
+		kept := []rune{row[0]}
+		for _, r := range row[1:] {
+			if rc.comparator(row[0], r) == 0 {
+				kept = append(kept, r)
+			} else {
+				removed = append(removed, r)
+			}
+		}
+		rows[i] = kept
+	}
+
+	// The rows are linked to their neighbors, so that removing a row makes its neighbors adjacent
+	prev := make([]int, len(rows))
+	next := make([]int, len(rows))
+	for i := range rows {
+		prev[i], next[i] = i-1, i+1
+	}
+	isRemoved := make([]bool, len(rows))
+	// Each pending row is compared to the row that follows it
+	pending := make([]int, 0, len(rows))
+	for i := 0; i+1 < len(rows); i++ {
+		pending = append(pending, i)
+	}
+	for len(pending) > 0 {
+		i := pending[0]
+		pending = pending[1:]
+		if isRemoved[i] || next[i] == len(rows) {
+			continue
+		}
+		j := next[i]
+		if rc.comparator(rows[i][0], rows[j][0]) == -1 {
+			continue
+		}
+		isRemoved[i], isRemoved[j] = true, true
+		before, after := prev[i], next[j]
+		if before >= 0 {
+			next[before] = after
+		}
+		if after < len(rows) {
+			prev[after] = before
+		}
+		if before >= 0 && after < len(rows) {
+			pending = append(pending, before)
+		}
+	}
+
+	kept := make([][]rune, 0, len(rows))
+	for i, row := range rows {
+		if isRemoved[i] {
+			removed = append(removed, row...)
+		} else {
+			kept = append(kept, row)
+		}
 	}
+	rc.setRows(kept)
+	sort.Slice(removed, func(i, j int) bool {
+		return removed[i] < removed[j]
+	})
+	return removed
+}
+
+// Len returns the number of distinct weights, which is the number of rows.
+func (rc *RuneComparator) Len() int {
+	return rc.rowCount
 }
 
 // SetComparator sets the comparator that will be used during insertion. This must be set before Insert is called, else
@@ -90,32 +243,59 @@ func (rc *RuneComparator) SetComparator(comparator func(l rune, r rune) int) {
 	rc.comparator = comparator
 }
 
-// RuneComparatorToGoFile returns the given RuneComparator as a Go file for inclusion in an application.
-func RuneComparatorToGoFile(rc *RuneComparator, name string) string {
-	titleName := name
-	lowerName := strings.ToLower(name)
-	{
-		nameRunes := []rune(lowerName)
-		nameRunes[0] = []rune(strings.ToUpper(string(nameRunes[0])))[0]
-		titleName = string(nameRunes)
+// RuneComparatorGoFileOptions controls the file that RuneComparatorToGoFileWithOptions generates.
+type RuneComparatorGoFileOptions struct {
+	// MapChunkSize is the maximum number of entries within each map literal. When positive, the weight map is filled
+	// by one init function per chunk, as a single literal with hundreds of thousands of entries slows compilation and
+	// may exceed the compiler's limits. Zero writes the map as a single literal.
+	MapChunkSize int
+	// Codegen controls the package, header, and identifiers of the file.
+	Codegen CodegenOptions
+	// ImplicitWeights are the regions whose weights are computed rather than listed, which must have been found using
+	// the same RuneComparator (see FindImplicitWeightRegions). The regions are checked after every other weight.
+	ImplicitWeights []ImplicitWeightRegion
+	// SharedWeights are the tables that the file references rather than listing their runes within its own weight
+	// map, which must have been found for the same collation (see FindSharedWeightTables). The file depends on the
+	// file that SharedWeightsToGoFile generates.
+	SharedWeights []SharedWeightReference
+	// DynamicRangeCutoff is the minimum number of runes within a range whose weights are computed from the rune. Zero
+	// uses the default of 100.
+	DynamicRangeCutoff int
+	// StaticRangeCutoff is the minimum difference between the upper and lower bounds of a range whose runes share a
+	// weight for the range to be written as a comparison rather than as map entries. Zero uses the default of 100.
+	StaticRangeCutoff int
+	// Ignorables are the runes that the collation ignores entirely (see extractor.CollationIgnorables), which the
+	// comparison function removes from both strings before comparing them. The extracted RuneComparator already gives
+	// them the weight of zero, as they sort before every other rune.
+	Ignorables []rune
+}
+
+// cutoffs returns the dynamic and static range cutoffs of the options, replacing zeros with the defaults.
+func (options RuneComparatorGoFileOptions) cutoffs() (dynamicCutoff int, staticCutoff int) {
+	dynamicCutoff, staticCutoff = options.DynamicRangeCutoff, options.StaticRangeCutoff
+	if dynamicCutoff <= 0 {
+		dynamicCutoff = dynamicWeightRangeCutoff
+	}
+	if staticCutoff <= 0 {
+		staticCutoff = staticWeightRangeCutoff
 	}
+	return dynamicCutoff, staticCutoff
+}
 
-	fileSb := strings.Builder{}
-	fileSb.WriteString(fmt.Sprintf(`// Copyright %d Dolthub, Inc.
-//
-// Licensed under the Apache License, Version 2.0 (the "License");
-// you may not use this file except in compliance with the License.
-// You may obtain a copy of the License at
-//
-//     http://www.apache.org/licenses/LICENSE-2.0
-//
-// Unless required by applicable law or agreed to in writing, software
-// distributed under the License is distributed on an "AS IS" BASIS,
-// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
-// See the License for the specific language governing permissions and
-// limitations under the License.
+// RuneComparatorToGoFile returns the given RuneComparator as a Go file for inclusion in an application. The padding
+// determines whether the generated comparison function treats trailing spaces as insignificant (PAD SPACE) or
+// significant (NO PAD).
+func RuneComparatorToGoFile(rc *RuneComparator, name string, padSpace bool) string {
+	return RuneComparatorToGoFileWithOptions(rc, name, padSpace, RuneComparatorGoFileOptions{})
+}
 
-package encodings
+// RuneComparatorToGoFileWithOptions returns the same file as RuneComparatorToGoFile, modified by the given options.
+func RuneComparatorToGoFileWithOptions(rc *RuneComparator, name string, padSpace bool, options RuneComparatorGoFileOptions) string {
+	titleName, lowerName := options.Codegen.names(name)
+
+	fileSb := strings.Builder{}
+	fileSb.WriteString(options.Codegen.fileHeader())
+	fileSb.WriteString(fmt.Sprintf(`
 
 // %s_RuneWeight returns the weight of a given rune based on its relational sort order from
 // the %s collation.
@@ -123,13 +303,270 @@ func %s_RuneWeight(r rune) int32 {
 	weight, ok := %s_Weights[r]
 	if ok {
 		return weight
-	}`, time.Now().Year(), titleName, "`"+lowerName+"`", titleName, lowerName))
-	mapSb := strings.Builder{}
-	mapSb.WriteString(fmt.Sprintf("var %s_Weights = map[rune]int32{\n", lowerName))
+	}`, titleName, "`"+strings.ToLower(name)+"`", titleName, lowerName))
+	var mapRunes []rune
+	mapWeights := make(map[rune]int)
+
+	dynamicCutoff, staticCutoff := options.cutoffs()
+	dynamicWeightRanges, staticWeightRanges := rc.weightRangesWithCutoff(dynamicCutoff)
+	dynamicWeightRanges, staticWeightRanges = withoutImplicitWeights(dynamicWeightRanges, staticWeightRanges, options.ImplicitWeights)
+
+	// All offset entries are listed first as they should be accessed more frequently than the static range entries
+	for _, rowWeightRange := range dynamicWeightRanges {
+		sign := "+"
+		if rowWeightRange.Offset < 0 {
+			sign = "-"
+			rowWeightRange.Offset *= -1
+		}
+		fileSb.WriteString(fmt.Sprintf(" else if r >= %d && r <= %d {\n\t\treturn r%s%d\n\t}",
+			rowWeightRange.Lower, rowWeightRange.Upper, sign, rowWeightRange.Offset))
+	}
+
+	// We either make map entries or a range entry depending on the range size
+	for _, rowWeightRange := range staticWeightRanges {
+		if rowWeightRange.Upper-rowWeightRange.Lower >= rune(staticCutoff) {
+			fileSb.WriteString(fmt.Sprintf(" else if r >= %d && r <= %d {\n\t\treturn %d\n\t}",
+				rowWeightRange.Lower, rowWeightRange.Upper, rowWeightRange.Weight))
+		} else {
+			for i := rowWeightRange.Lower; i <= rowWeightRange.Upper; i++ {
+				if sharedWeightsContain(options.SharedWeights, i, int32(rowWeightRange.Weight)) {
+					continue
+				}
+				mapRunes = append(mapRunes, i)
+				mapWeights[i] = rowWeightRange.Weight
+			}
+		}
+	}
+	// The entries are listed by rune, so that regenerating the file after the weights change only touches the changed
+	// entries
+	sort.Slice(mapRunes, func(i, j int) bool {
+		return mapRunes[i] < mapRunes[j]
+	})
+	mapEntries := make([]string, len(mapRunes))
+	for i, r := range mapRunes {
+		mapEntries[i] = fmt.Sprintf("%d: %d,", r, mapWeights[r])
+	}
+
+	// The shared tables only contain runes that would otherwise be within the weight map, so they may be checked last
+	for _, ref := range options.SharedWeights {
+		fileSb.WriteString(fmt.Sprintf(" else if weight, ok := %s_Weights[r]; ok {\n\t\treturn weight + %d\n\t}",
+			ref.Table.Name, ref.Offset))
+	}
+
+	// The regions contain runes with other weights, so they are only checked once every other weight has been checked
+	for _, region := range options.ImplicitWeights {
+		sign := "+"
+		if region.Offset < 0 {
+			sign = "-"
+			region.Offset *= -1
+		}
+		fileSb.WriteString(fmt.Sprintf(" else if r >= %d && r <= %d {\n\t\t// Implicit weights\n\t\treturn r%s%d\n\t}",
+			region.Lower, region.Upper, sign, region.Offset))
+	}
 
+	fileSb.WriteString(` else {
+		return 2147483647
+	}
+}
+
+`)
+	fileSb.WriteString(runeComparatorCompareFunc(titleName, lowerName, padSpace, len(options.Ignorables) > 0))
+	if len(options.Ignorables) > 0 {
+		fileSb.WriteString(ignorablesToGoFile(titleName, lowerName, options.Ignorables))
+	}
+	fileSb.WriteString(fmt.Sprintf(`
+// %s_Weights contain a map from rune to weight for the %s collation. The
+// map primarily contains mappings that have a random order. Mappings that fit into a sequential range (and are long
+// enough) are defined in the calling function to save space.
+`, lowerName, "`"+strings.ToLower(name)+"`"))
+	if options.MapChunkSize <= 0 {
+		fileSb.WriteString(fmt.Sprintf("var %s_Weights = map[rune]int32{\n", lowerName))
+		for _, entry := range mapEntries {
+			fileSb.WriteString("\t" + entry + "\n")
+		}
+		fileSb.WriteString("}\n")
+		return fileSb.String()
+	}
+	// Each chunk is a separate literal within its own init function, so that no single function grows too large
+	fileSb.WriteString(fmt.Sprintf("var %s_Weights = make(map[rune]int32, %d)\n", lowerName, len(mapEntries)))
+	for start := 0; start < len(mapEntries); start += options.MapChunkSize {
+		end := start + options.MapChunkSize
+		if end > len(mapEntries) {
+			end = len(mapEntries)
+		}
+		fileSb.WriteString("\nfunc init() {\n\tfor r, weight := range map[rune]int32{\n")
+		for _, entry := range mapEntries[start:end] {
+			fileSb.WriteString("\t\t" + entry + "\n")
+		}
+		fileSb.WriteString(fmt.Sprintf("\t} {\n\t\t%s_Weights[r] = weight\n\t}\n}\n", lowerName))
+	}
+	return fileSb.String()
+}
+
+// RuneComparatorAliasToGoFile returns a Go file for a collation whose weights are identical to the weights of a
+// collation that was generated by RuneComparatorToGoFile. Rather than duplicating the weights, the weight function calls
+// the shared collation's weight function. The padding may differ from the shared collation.
+func RuneComparatorAliasToGoFile(name string, sharedName string, padSpace bool, options CodegenOptions) string {
+	titleName, lowerName := options.names(name)
+	// The shared collation's file was generated separately, so the prefix of this file does not apply to it
+	sharedTitleName, _ := CodegenOptions{}.names(sharedName)
+
+	return fmt.Sprintf(`%s
+
+// %s_RuneWeight returns the weight of a given rune based on its relational sort order from
+// the %s collation. The weights are identical to the %s collation.
+func %s_RuneWeight(r rune) int32 {
+	return %s_RuneWeight(r)
+}
+
+%s`, options.fileHeader(), titleName, "`"+lowerName+"`", "`"+strings.ToLower(sharedName)+"`", titleName, sharedTitleName,
+		runeComparatorCompareFunc(titleName, lowerName, padSpace, false))
+}
+
+// runeComparatorCompareFunc returns the padding constant and comparison function for a generated collation file. The
+// comparison is made rune by rune using the weight function. Under PAD SPACE, the shorter string is compared as though
+// it were padded with spaces to the length of the longer string, which is not the same as trimming trailing spaces, as
+// some runes (such as tabs) may sort before the space.
+func runeComparatorCompareFunc(titleName string, lowerName string, padSpace bool, ignorables bool) string {
+	padding := "NO PAD, so trailing spaces are significant"
+	toRunes := "[]rune(%s)"
+	if ignorables {
+		padding += ", and ignorable runes are skipped"
+		toRunes = lowerName + "_withoutIgnorables(%s)"
+	}
+	tail := `	if len(lRunes) < len(rRunes) {
+		return -1
+	} else if len(lRunes) > len(rRunes) {
+		return 1
+	}
+	return 0`
+	if padSpace {
+		padding = "PAD SPACE, so trailing spaces are insignificant"
+		tail = fmt.Sprintf(`	spaceWeight := %[1]s_RuneWeight(' ')
+	for i := len(rRunes); i < len(lRunes); i++ {
+		if weight := %[1]s_RuneWeight(lRunes[i]); weight < spaceWeight {
+			return -1
+		} else if weight > spaceWeight {
+			return 1
+		}
+	}
+	for i := len(lRunes); i < len(rRunes); i++ {
+		if weight := %[1]s_RuneWeight(rRunes[i]); weight < spaceWeight {
+			return 1
+		} else if weight > spaceWeight {
+			return -1
+		}
+	}
+	return 0`, titleName)
+	}
+	return fmt.Sprintf(`// %[1]s_PadSpace is whether the %[2]s collation is PAD SPACE. When false, the
+// collation is NO PAD.
+const %[1]s_PadSpace = %[3]t
+
+// %[1]s_Compare returns the relative sorting order of the given strings for the %[2]s
+// collation. Returns -1 if the left string sorts first, 1 if the right string sorts first, and 0 if they are
+// equivalent. The collation is %[4]s.
+func %[1]s_Compare(l string, r string) int {
+	lRunes := %[6]s
+	rRunes := %[7]s
+	for i := 0; i < len(lRunes) && i < len(rRunes); i++ {
+		if lWeight, rWeight := %[1]s_RuneWeight(lRunes[i]), %[1]s_RuneWeight(rRunes[i]); lWeight < rWeight {
+			return -1
+		} else if lWeight > rWeight {
+			return 1
+		}
+	}
+%[5]s
+}
+`, titleName, "`"+lowerName+"`", padSpace, padding, tail, fmt.Sprintf(toRunes, "l"), fmt.Sprintf(toRunes, "r"))
+}
+
+// ignorablesToGoFile returns the declarations that the comparison function uses to skip the given ignorable runes.
+func ignorablesToGoFile(titleName string, lowerName string, ignorables []rune) string {
+	sorted := append([]rune(nil), ignorables...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf(`
+// %[1]s_IsIgnorable returns whether the given rune is ignored entirely by the %[3]s collation, such
+// that its presence never changes the result of a comparison. Ignorable runes have a weight of zero.
+func %[1]s_IsIgnorable(r rune) bool {
+	return %[2]s_Ignorables[r]
+}
+
+// %[2]s_withoutIgnorables returns the runes of the given string that are not ignorable.
+func %[2]s_withoutIgnorables(str string) []rune {
+	runes := make([]rune, 0, len(str))
+	for _, r := range str {
+		if !%[2]s_Ignorables[r] {
+			runes = append(runes, r)
+		}
+	}
+	return runes
+}
+
+// %[2]s_Ignorables contains the runes that the %[3]s collation ignores entirely.
+var %[2]s_Ignorables = map[rune]bool{
+`, titleName, lowerName, "`"+lowerName+"`"))
+	for _, r := range sorted {
+		sb.WriteString(fmt.Sprintf("\t%d: true,\n", r))
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// sharedWeightsContain returns whether any of the references contain the given rune with the given weight.
+func sharedWeightsContain(refs []SharedWeightReference, r rune, weight int32) bool {
+	for _, ref := range refs {
+		if ref.contains(r, weight) {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutImplicitWeights returns the given ranges without the runes whose weights are computed by any of the regions.
+func withoutImplicitWeights(dynamicWeightRanges []dynamicWeightRange, staticWeightRanges []staticWeightRange, regions []ImplicitWeightRegion) ([]dynamicWeightRange, []staticWeightRange) {
+	if len(regions) == 0 {
+		return dynamicWeightRanges, staticWeightRanges
+	}
+	inRegion := func(r rune, weight int) bool {
+		for _, region := range regions {
+			if region.contains(r, weight) {
+				return true
+			}
+		}
+		return false
+	}
+	var dynamics []dynamicWeightRange
+	for _, dynamic := range dynamicWeightRanges {
+		if !inRegion(dynamic.Lower, int(dynamic.Lower)+dynamic.Offset) || !inRegion(dynamic.Upper, int(dynamic.Upper)+dynamic.Offset) {
+			dynamics = append(dynamics, dynamic)
+		}
+	}
+	var statics []staticWeightRange
+	for _, static := range staticWeightRanges {
+		if static.Count() > 1 || !inRegion(static.Lower, static.Weight) {
+			statics = append(statics, static)
+		}
+	}
+	return dynamics, statics
+}
+
+// weightRanges returns the weights of all runes as ranges. Sequential runes that have sequential weights are returned
+// as dynamic ranges (when the range is long enough), while all remaining runes are returned as static ranges, even if
+// they contain a single rune.
+func (rc *RuneComparator) weightRanges() ([]dynamicWeightRange, []staticWeightRange) {
+	return rc.weightRangesWithCutoff(dynamicWeightRangeCutoff)
+}
+
+// weightRangesWithCutoff is the same as weightRanges, where dynamic ranges must contain at least the given number of
+// runes.
+func (rc *RuneComparator) weightRangesWithCutoff(dynamicCutoff int) ([]dynamicWeightRange, []staticWeightRange) {
 	// Calculate all of the static ranges, even if they contain a single rune
 	var staticWeightRanges []staticWeightRange
-	for weight, row := range rc.values {
+	for weight, row := range rc.rows() {
 		for _, r := range row {
 			if len(staticWeightRanges) == 0 {
 				staticWeightRanges = append(staticWeightRanges, staticWeightRange{
@@ -175,8 +612,8 @@ func %s_RuneWeight(r rune) int32 {
 				break
 			}
 		}
-		// Cutoff point that determines whether we make this a range comparison. Decision is arbitrary.
-		if dynamic.Count() >= 100 {
+		// Cutoff point that determines whether we make this a range comparison
+		if dynamic.Count() >= int32(dynamicCutoff) {
 			dynamicWeightRanges = append(dynamicWeightRanges, dynamic)
 			copy(staticWeightRanges[lowerIdx:], staticWeightRanges[upperIdx:])
 			staticWeightRanges = staticWeightRanges[:len(staticWeightRanges)-(upperIdx-lowerIdx)]
@@ -184,58 +621,72 @@ func %s_RuneWeight(r rune) int32 {
 			lowerIdx = upperIdx - 1
 		}
 	}
+	return dynamicWeightRanges, staticWeightRanges
+}
 
-	// All offset entries are listed first as they should be accessed more frequently than the static range entries
-	for _, rowWeightRange := range dynamicWeightRanges {
-		sign := "+"
-		if rowWeightRange.Offset < 0 {
-			sign = "-"
-			rowWeightRange.Offset *= -1
-		}
-		fileSb.WriteString(fmt.Sprintf(" else if r >= %d && r <= %d {\n\t\treturn r%s%d\n\t}",
-			rowWeightRange.Lower, rowWeightRange.Upper, sign, rowWeightRange.Offset))
+// insertIntoRow adds the given rune to the row at the given index of the given block, keeping the row in sequential
+// order.
+func (rc *RuneComparator) insertIntoRow(r rune, blockIdx int, idx int) {
+	row := rc.blocks[blockIdx][idx]
+	if len(row) == 0 || row[len(row)-1] < r {
+		rc.blocks[blockIdx][idx] = append(row, r)
+		return
 	}
-
-	// We either make map entries or a range entry depending on the range size
-	for _, rowWeightRange := range staticWeightRanges {
-		// Cutoff point that determines whether we do a range comparison or a map comparison. Decision is arbitrary.
-		if rowWeightRange.Upper-rowWeightRange.Lower >= 100 {
-			fileSb.WriteString(fmt.Sprintf(" else if r >= %d && r <= %d {\n\t\treturn %d\n\t}",
-				rowWeightRange.Lower, rowWeightRange.Upper, rowWeightRange.Weight))
-		} else {
-			for i := rowWeightRange.Lower; i <= rowWeightRange.Upper; i++ {
-				mapSb.WriteString(fmt.Sprintf("\t%d: %d,\n", i, rowWeightRange.Weight))
-			}
-		}
+	pos := sort.Search(len(row), func(i int) bool {
+		return row[i] >= r
+	})
+	if row[pos] == r {
+		return
 	}
+	row = append(row, 0)
+	copy(row[pos+1:], row[pos:])
+	row[pos] = r
+	rc.blocks[blockIdx][idx] = row
+}
 
-	mapSb.WriteString("}\n")
-	fileSb.WriteString(fmt.Sprintf(` else {
-		return 2147483647
+// insertNewRow inserts a new row at the given index of the given block (containing the given rune as its only element)
+// while pushing back the rows already at and after that index (if any exist). The block is split in half once it holds
+// more than twice the block size.
+func (rc *RuneComparator) insertNewRow(r rune, blockIdx int, idx int) {
+	block := append(rc.blocks[blockIdx], nil)
+	copy(block[idx+1:], block[idx:])
+	block[idx] = []rune{r}
+	rc.rowCount++
+	if len(block) <= 2*runeComparatorBlockSize {
+		rc.blocks[blockIdx] = block
+		return
 	}
+	// The halves are copied so that appending to the first half does not overwrite the second
+	half := len(block) / 2
+	lower := append(make([][]rune, 0, 2*runeComparatorBlockSize+1), block[:half]...)
+	upper := append(make([][]rune, 0, 2*runeComparatorBlockSize+1), block[half:]...)
+	rc.blocks = append(rc.blocks, nil)
+	copy(rc.blocks[blockIdx+2:], rc.blocks[blockIdx+1:])
+	rc.blocks[blockIdx] = lower
+	rc.blocks[blockIdx+1] = upper
 }
 
-// %s_Weights contain a map from rune to weight for the %s collation. The
-// map primarily contains mappings that have a random order. Mappings that fit into a sequential range (and are long
-// enough) are defined in the calling function to save space.
-%s`, lowerName, "`"+lowerName+"`", mapSb.String()))
-	return fileSb.String()
+// setRows replaces the contents of the RuneComparator with the given rows, which are split into blocks.
+func (rc *RuneComparator) setRows(rows [][]rune) {
+	rc.blocks = nil
+	rc.rowCount = len(rows)
+	for start := 0; start < len(rows); start += runeComparatorBlockSize {
+		end := start + runeComparatorBlockSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		rc.blocks = append(rc.blocks, append(make([][]rune, 0, 2*runeComparatorBlockSize+1), rows[start:end]...))
+	}
 }
 
-// insertNewRow inserts a new row at the given index (containing the given rune as its only element) while pushing back
-// the row already at that index (if one exists).
-func (rc *RuneComparator) insertNewRow(r rune, idx int) {
-	// If we're inserting after the last element then we may append
-	if idx == len(rc.values) {
-		rc.values = append(rc.values, []rune{r})
-		return
+// rows returns every row in order, such that the index of each row is its weight. The rows are shared with the
+// RuneComparator, so they must not be modified.
+func (rc *RuneComparator) rows() [][]rune {
+	rows := make([][]rune, 0, rc.rowCount)
+	for _, block := range rc.blocks {
+		rows = append(rows, block...)
 	}
-
-	// To insert at the given index, we shift the existing data up by 1, and then replace the existing row with the new
-	// row.
-	rc.values = append(rc.values, nil)
-	copy(rc.values[idx+1:], rc.values[idx:])
-	rc.values[idx] = []rune{r}
+	return rows
 }
 
 // Count returns the number of runes that are contained within this range.
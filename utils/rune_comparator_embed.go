@@ -0,0 +1,134 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"strings"
+)
+
+// runeComparatorEmbeddedTableSuffix ends the name of every table returned by RuneComparatorEmbeddedTableName.
+const runeComparatorEmbeddedTableSuffix = "_weights.bin"
+
+// RuneComparatorEmbeddedTableName returns the name of the binary table that the file generated by
+// RuneComparatorToEmbeddedGoFile embeds, which must be written to the same directory as the file.
+func RuneComparatorEmbeddedTableName(name string) string {
+	return strings.ToLower(name) + runeComparatorEmbeddedTableSuffix
+}
+
+// RuneComparatorToEmbeddedGoFile returns the given RuneComparator as a Go file for inclusion in an application, along
+// with the binary table that it embeds, which is named by RuneComparatorEmbeddedTableName. The table is the gzip
+// compressed output of RuneComparatorToDoltFile, which is decoded the first time that a weight is requested. The file
+// contains the same functions as the file from RuneComparatorToGoFile.
+func RuneComparatorToEmbeddedGoFile(rc *RuneComparator, name string, padSpace bool, options CodegenOptions) (string, []byte, error) {
+	titleName, lowerName := options.names(name)
+
+	buf := bytes.Buffer{}
+	writer, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return "", nil, err
+	}
+	if _, err = writer.Write(RuneComparatorToDoltFile(rc, name)); err != nil {
+		return "", nil, err
+	}
+	if err = writer.Close(); err != nil {
+		return "", nil, err
+	}
+
+	file := fmt.Sprintf(`%[1]s
+
+import (
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"encoding/binary"
+	"io"
+	"sort"
+	"sync"
+)
+
+// %[3]s_weightTable is the compressed weight table of the %[4]s collation, which is decoded on first use.
+//
+//go:embed %[5]s
+var %[3]s_weightTable []byte
+
+var (
+	%[3]s_weightsOnce sync.Once
+	// %[3]s_offsetRanges contains the ranges whose runes have the weight of the rune plus the offset, as
+	// {lower, upper, offset}, sorted by their lower bound.
+	%[3]s_offsetRanges [][3]int32
+	// %[3]s_weightRanges contains the ranges whose runes all have the same weight, as {lower, upper, weight},
+	// sorted by their lower bound.
+	%[3]s_weightRanges [][3]int32
+)
+
+// %[2]s_RuneWeight returns the weight of a given rune based on its relational sort order from
+// the %[4]s collation.
+func %[2]s_RuneWeight(r rune) int32 {
+	%[3]s_weightsOnce.Do(%[3]s_loadWeights)
+	if rng, ok := %[3]s_searchRanges(%[3]s_offsetRanges, r); ok {
+		return r + rng[2]
+	}
+	if rng, ok := %[3]s_searchRanges(%[3]s_weightRanges, r); ok {
+		return rng[2]
+	}
+	return 2147483647
+}
+
+// %[3]s_searchRanges returns the range that contains the given rune.
+func %[3]s_searchRanges(ranges [][3]int32, r rune) ([3]int32, bool) {
+	idx := sort.Search(len(ranges), func(i int) bool {
+		return ranges[i][1] >= r
+	})
+	if idx < len(ranges) && ranges[idx][0] <= r {
+		return ranges[idx], true
+	}
+	return [3]int32{}, false
+}
+
+// %[3]s_loadWeights decodes the embedded weight table. The table is generated alongside this file, so a table
+// that cannot be decoded is a build error, and panics.
+func %[3]s_loadWeights() {
+	reader, err := gzip.NewReader(bytes.NewReader(%[3]s_weightTable))
+	if err != nil {
+		panic(err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		panic(err)
+	}
+	// The header contains the magic, the version, and the length of the name, which is followed by the name
+	data = data[8+int(binary.LittleEndian.Uint16(data[6:8])):]
+	readRanges := func() [][3]int32 {
+		ranges := make([][3]int32, binary.LittleEndian.Uint32(data))
+		data = data[4:]
+		for i := range ranges {
+			for j := range ranges[i] {
+				ranges[i][j] = int32(binary.LittleEndian.Uint32(data))
+				data = data[4:]
+			}
+		}
+		return ranges
+	}
+	%[3]s_offsetRanges = readRanges()
+	%[3]s_weightRanges = readRanges()
+}
+
+%[6]s`, options.fileHeader(), titleName, lowerName, "`"+lowerName+"`", RuneComparatorEmbeddedTableName(name),
+		runeComparatorCompareFunc(titleName, lowerName, padSpace, false))
+	return file, buf.Bytes(), nil
+}
@@ -0,0 +1,60 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"go/parser"
+	"go/token"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuneComparatorToEmbeddedGoFile(t *testing.T) {
+	var order [][]rune
+	for r := rune(0x100); r < 0x300; r++ {
+		order = append(order, []rune{r})
+	}
+	order = append(order, []rune{'A', 'a'}, []rune{'B', 'b'})
+	rc := NewRuneComparatorFromOrder(order)
+
+	contents, table, err := RuneComparatorToEmbeddedGoFile(rc, "Test_ci", true, CodegenOptions{})
+	require.NoError(t, err)
+	_, err = parser.ParseFile(token.NewFileSet(), "", contents, 0)
+	require.NoError(t, err)
+	assert.True(t, strings.Contains(contents, "//go:embed test_ci_weights.bin\n"))
+	assert.True(t, strings.Contains(contents, "func Test_ci_RuneWeight(r rune) int32 {"))
+	assert.True(t, strings.Contains(contents, "func Test_ci_Compare(l string, r string) int {"))
+
+	// The table is the compressed Dolt weight table, so it decodes to the same weights
+	reader, err := gzip.NewReader(bytes.NewReader(table))
+	require.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, RuneComparatorToDoltFile(rc, "Test_ci"), data)
+	name, weights, err := ParseDoltFile(data)
+	require.NoError(t, err)
+	assert.Equal(t, "Test_ci", name)
+	for weight, row := range rc.rows() {
+		for _, r := range row {
+			assert.Equal(t, int32(weight), weights.Weight(r))
+		}
+	}
+}
@@ -0,0 +1,529 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// RuneWeights contains the weights from a file that was previously generated by RuneComparatorToGoFile. This mirrors
+// the logic of the generated weight function, so that a generated file may be checked without compiling it.
+type RuneWeights struct {
+	weights       map[rune]int32
+	dynamicRanges []dynamicWeightRange
+	staticRanges  []staticWeightRange
+	// implicitRanges are the offset ranges that follow the static ranges, which are the ImplicitWeightRegions.
+	implicitRanges []dynamicWeightRange
+	// sharedRanges are the SharedWeightTables that the file references, along with the offset of each.
+	sharedRanges []sharedWeightRange
+	// ignorables are the runes that the comparison skips, which is empty for files generated without Ignorables.
+	ignorables map[rune]bool
+	padSpace   bool
+}
+
+// sharedWeightRange is a reference to a SharedWeightTable from the weight function.
+type sharedWeightRange struct {
+	weights map[rune]int32
+	offset  int32
+}
+
+// ParseRuneComparatorGoFile parses a file that was previously generated by RuneComparatorToGoFile.
+func ParseRuneComparatorGoFile(src string) (*RuneWeights, error) {
+	return ParseRuneComparatorGoFileWithSharedWeights(src, "")
+}
+
+// ParseRuneComparatorGoFileWithSharedWeights parses a file that was previously generated by
+// RuneComparatorToGoFileWithOptions with SharedWeights, along with the file that SharedWeightsToGoFile generated. The
+// shared file may be empty when the file does not reference any tables.
+func ParseRuneComparatorGoFileWithSharedWeights(src string, sharedSrc string) (*RuneWeights, error) {
+	tables := make(map[string]map[rune]int32)
+	if sharedSrc != "" {
+		var err error
+		if tables, err = parseSharedWeightTables(sharedSrc); err != nil {
+			return nil, err
+		}
+	}
+	file, err := parser.ParseFile(token.NewFileSet(), "", src, 0)
+	if err != nil {
+		return nil, err
+	}
+	rw := &RuneWeights{weights: make(map[rune]int32)}
+	foundFunc := false
+	foundMap := false
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			// Files generated with a map chunk size fill the weight map from init functions
+			if decl.Name.Name == "init" && decl.Body != nil {
+				if err = rw.parseMapChunk(decl.Body); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if !strings.HasSuffix(decl.Name.Name, "_RuneWeight") || decl.Body == nil {
+				continue
+			}
+			// Files generated by RuneComparatorAliasToGoFile call the weight function of another collation
+			if len(decl.Body.List) == 1 {
+				if returnStmt, ok := decl.Body.List[0].(*ast.ReturnStmt); ok && len(returnStmt.Results) == 1 {
+					if call, ok := returnStmt.Results[0].(*ast.CallExpr); ok {
+						if ident, ok := call.Fun.(*ast.Ident); ok {
+							return nil, fmt.Errorf("the weights are shared with `%s`, which should be parsed instead",
+								strings.ToLower(strings.TrimSuffix(ident.Name, "_RuneWeight")))
+						}
+					}
+				}
+			}
+			// Files generated by CollationDeltaToGoFile call the weight function of the base collation
+			for _, stmt := range decl.Body.List {
+				if assign, ok := stmt.(*ast.AssignStmt); ok && len(assign.Rhs) == 1 {
+					if call, ok := assign.Rhs[0].(*ast.CallExpr); ok {
+						if ident, ok := call.Fun.(*ast.Ident); ok && strings.HasSuffix(ident.Name, "_RuneWeight") {
+							return nil, fmt.Errorf("the weights are a delta from `%s`, which cannot be parsed from a single file",
+								strings.ToLower(strings.TrimSuffix(ident.Name, "_RuneWeight")))
+						}
+					}
+				}
+			}
+			if len(decl.Body.List) < 2 {
+				continue
+			}
+			// Files generated by RuneComparatorToSortedSliceGoFile search the ranges, which are parsed from the slice
+			if assign, ok := decl.Body.List[0].(*ast.AssignStmt); ok && len(assign.Rhs) == 1 {
+				if _, ok := assign.Rhs[0].(*ast.CallExpr); ok {
+					foundFunc = true
+					continue
+				}
+			}
+			ifStmt, ok := decl.Body.List[1].(*ast.IfStmt)
+			if !ok {
+				return nil, fmt.Errorf("expected the weight function to check the map first")
+			}
+			if err = rw.parseRanges(ifStmt.Else, tables); err != nil {
+				return nil, err
+			}
+			foundFunc = true
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				valueSpec, ok := spec.(*ast.ValueSpec)
+				if !ok || len(valueSpec.Values) != 1 {
+					continue
+				}
+				// Files that were generated before the padding was extracted do not contain the constant, and were
+				// compared as NO PAD
+				if strings.HasSuffix(valueSpec.Names[0].Name, "_PadSpace") {
+					if rw.padSpace, err = parseBool(valueSpec.Values[0]); err != nil {
+						return nil, err
+					}
+					continue
+				}
+				if strings.HasSuffix(valueSpec.Names[0].Name, "_Ignorables") {
+					if rw.ignorables, err = parseIgnorables(valueSpec.Values[0]); err != nil {
+						return nil, err
+					}
+					continue
+				}
+				if strings.HasSuffix(valueSpec.Names[0].Name, "_weightRanges") {
+					if err = rw.parseSortedRanges(valueSpec.Values[0]); err != nil {
+						return nil, err
+					}
+					foundMap = true
+					continue
+				}
+				if !strings.HasSuffix(valueSpec.Names[0].Name, "_Weights") {
+					continue
+				}
+				if call, ok := valueSpec.Values[0].(*ast.CallExpr); ok {
+					if ident, ok := call.Fun.(*ast.Ident); ok && ident.Name == "make" {
+						foundMap = true
+						continue
+					}
+				}
+				mapLit, ok := valueSpec.Values[0].(*ast.CompositeLit)
+				if !ok {
+					return nil, fmt.Errorf("expected a composite literal for the weight map")
+				}
+				runes, err := parseRuneMap(mapLit)
+				if err != nil {
+					return nil, err
+				}
+				for _, pair := range runes {
+					rw.weights[pair[0]] = pair[1]
+				}
+				foundMap = true
+			}
+		}
+	}
+	if !foundFunc || !foundMap {
+		return nil, fmt.Errorf("unable to find the weight function and weight map")
+	}
+	return rw, nil
+}
+
+// Weight returns the weight of the given rune. This matches the generated weight function.
+func (rw *RuneWeights) Weight(r rune) int32 {
+	if weight, ok := rw.weights[r]; ok {
+		return weight
+	}
+	for _, dynamic := range rw.dynamicRanges {
+		if r >= dynamic.Lower && r <= dynamic.Upper {
+			return r + int32(dynamic.Offset)
+		}
+	}
+	for _, static := range rw.staticRanges {
+		if r >= static.Lower && r <= static.Upper {
+			return int32(static.Weight)
+		}
+	}
+	for _, shared := range rw.sharedRanges {
+		if weight, ok := shared.weights[r]; ok {
+			return weight + shared.offset
+		}
+	}
+	for _, implicit := range rw.implicitRanges {
+		if r >= implicit.Lower && r <= implicit.Upper {
+			return r + int32(implicit.Offset)
+		}
+	}
+	return 2147483647
+}
+
+// Order returns the given runes grouped into rows of equal weight, with the rows sorted by weight and the runes of each
+// row sorted by value. This matches the rows of the RuneComparator that the file was generated from, limited to the
+// given runes. Runes without a weight (which the weight function returns the maximum weight for) are omitted.
+func (rw *RuneWeights) Order(runes []rune) [][]rune {
+	rowsByWeight := make(map[int32][]rune)
+	for _, r := range runes {
+		if weight := rw.Weight(r); weight != 2147483647 {
+			rowsByWeight[weight] = append(rowsByWeight[weight], r)
+		}
+	}
+	weights := make([]int32, 0, len(rowsByWeight))
+	for weight := range rowsByWeight {
+		weights = append(weights, weight)
+	}
+	sort.Slice(weights, func(i, j int) bool {
+		return weights[i] < weights[j]
+	})
+	rows := make([][]rune, len(weights))
+	for i, weight := range weights {
+		rows[i] = rowsByWeight[weight]
+		sort.Slice(rows[i], func(a, b int) bool {
+			return rows[i][a] < rows[i][b]
+		})
+	}
+	return rows
+}
+
+// PadSpace returns whether the collation is PAD SPACE.
+func (rw *RuneWeights) PadSpace() bool {
+	return rw.padSpace
+}
+
+// Compare returns the relative sorting order of the given strings. This matches the generated comparison function.
+func (rw *RuneWeights) Compare(l string, r string) int {
+	lRunes := rw.withoutIgnorables(l)
+	rRunes := rw.withoutIgnorables(r)
+	for i := 0; i < len(lRunes) && i < len(rRunes); i++ {
+		if lWeight, rWeight := rw.Weight(lRunes[i]), rw.Weight(rRunes[i]); lWeight < rWeight {
+			return -1
+		} else if lWeight > rWeight {
+			return 1
+		}
+	}
+	if !rw.padSpace {
+		if len(lRunes) < len(rRunes) {
+			return -1
+		} else if len(lRunes) > len(rRunes) {
+			return 1
+		}
+		return 0
+	}
+	spaceWeight := rw.Weight(' ')
+	for i := len(rRunes); i < len(lRunes); i++ {
+		if weight := rw.Weight(lRunes[i]); weight < spaceWeight {
+			return -1
+		} else if weight > spaceWeight {
+			return 1
+		}
+	}
+	for i := len(lRunes); i < len(rRunes); i++ {
+		if weight := rw.Weight(rRunes[i]); weight < spaceWeight {
+			return 1
+		} else if weight > spaceWeight {
+			return -1
+		}
+	}
+	return 0
+}
+
+// IsIgnorable returns whether the given rune is skipped by the comparison. This matches the generated function.
+func (rw *RuneWeights) IsIgnorable(r rune) bool {
+	return rw.ignorables[r]
+}
+
+// withoutIgnorables returns the runes of the given string that are not ignorable.
+func (rw *RuneWeights) withoutIgnorables(str string) []rune {
+	runes := make([]rune, 0, len(str))
+	for _, r := range str {
+		if !rw.ignorables[r] {
+			runes = append(runes, r)
+		}
+	}
+	return runes
+}
+
+// parseIgnorables parses the set of ignorable runes, which has the form `map[rune]bool{r: true}`.
+func parseIgnorables(expr ast.Expr) (map[rune]bool, error) {
+	lit, ok := expr.(*ast.CompositeLit)
+	if !ok {
+		return nil, fmt.Errorf("expected a composite literal for the ignorable runes")
+	}
+	ignorables := make(map[rune]bool, len(lit.Elts))
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			return nil, fmt.Errorf("ignorable runes must be keyed")
+		}
+		r, err := parseInt(kv.Key)
+		if err != nil {
+			return nil, err
+		}
+		ignorable, err := parseBool(kv.Value)
+		if err != nil {
+			return nil, err
+		}
+		ignorables[rune(r)] = ignorable
+	}
+	return ignorables, nil
+}
+
+// parseRanges parses the chain of `else if` statements that make up the ranges of the weight function. Each statement
+// has the form `r >= lower && r <= upper`, and returns either an offset from the rune or a static weight.
+func (rw *RuneWeights) parseRanges(stmt ast.Stmt, tables map[string]map[rune]int32) error {
+	for stmt != nil {
+		ifStmt, ok := stmt.(*ast.IfStmt)
+		if !ok {
+			// The final `else` block returns the default weight
+			return nil
+		}
+		// References to a SharedWeightTable look up the rune within the table before the condition
+		if ifStmt.Init != nil {
+			if err := rw.parseSharedRange(ifStmt, tables); err != nil {
+				return err
+			}
+			stmt = ifStmt.Else
+			continue
+		}
+		cond, ok := ifStmt.Cond.(*ast.BinaryExpr)
+		if !ok || cond.Op != token.LAND {
+			return fmt.Errorf("expected a range condition in the weight function")
+		}
+		lower, err := parseRangeComparison(cond.X, token.GEQ)
+		if err != nil {
+			return err
+		}
+		upper, err := parseRangeComparison(cond.Y, token.LEQ)
+		if err != nil {
+			return err
+		}
+		if len(ifStmt.Body.List) != 1 {
+			return fmt.Errorf("expected a single return statement for the range %d to %d", lower, upper)
+		}
+		returnStmt, ok := ifStmt.Body.List[0].(*ast.ReturnStmt)
+		if !ok || len(returnStmt.Results) != 1 {
+			return fmt.Errorf("expected a single return statement for the range %d to %d", lower, upper)
+		}
+		switch result := returnStmt.Results[0].(type) {
+		case *ast.BasicLit:
+			weight, err := parseInt(result)
+			if err != nil {
+				return err
+			}
+			rw.staticRanges = append(rw.staticRanges, staticWeightRange{Weight: int(weight), Lower: rune(lower), Upper: rune(upper)})
+		case *ast.BinaryExpr:
+			offset, err := parseInt(result.Y)
+			if err != nil {
+				return err
+			}
+			if result.Op == token.SUB {
+				offset = -offset
+			} else if result.Op != token.ADD {
+				return fmt.Errorf("unexpected operator `%s` for the range %d to %d", result.Op, lower, upper)
+			}
+			// The implicit weights are the only offset ranges that are written after a static range
+			if len(rw.staticRanges) > 0 || len(rw.sharedRanges) > 0 {
+				rw.implicitRanges = append(rw.implicitRanges, dynamicWeightRange{Offset: int(offset), Lower: rune(lower), Upper: rune(upper)})
+			} else {
+				rw.dynamicRanges = append(rw.dynamicRanges, dynamicWeightRange{Offset: int(offset), Lower: rune(lower), Upper: rune(upper)})
+			}
+		default:
+			return fmt.Errorf("unexpected return value for the range %d to %d", lower, upper)
+		}
+		stmt = ifStmt.Else
+	}
+	return nil
+}
+
+// parseSharedRange parses a reference to a SharedWeightTable, which has the form
+// `weight, ok := table_Weights[r]; ok` and returns `weight + offset`.
+func (rw *RuneWeights) parseSharedRange(ifStmt *ast.IfStmt, tables map[string]map[rune]int32) error {
+	assign, ok := ifStmt.Init.(*ast.AssignStmt)
+	if !ok || len(assign.Rhs) != 1 {
+		return fmt.Errorf("expected a shared weight table lookup in the weight function")
+	}
+	index, ok := assign.Rhs[0].(*ast.IndexExpr)
+	if !ok {
+		return fmt.Errorf("expected a shared weight table lookup in the weight function")
+	}
+	ident, ok := index.X.(*ast.Ident)
+	if !ok {
+		return fmt.Errorf("expected a shared weight table lookup in the weight function")
+	}
+	weights, ok := tables[ident.Name]
+	if !ok {
+		return fmt.Errorf("the weights reference the shared table `%s`, which must be parsed alongside the file",
+			strings.TrimSuffix(ident.Name, "_Weights"))
+	}
+	if len(ifStmt.Body.List) != 1 {
+		return fmt.Errorf("expected a single return statement for the shared table `%s`", ident.Name)
+	}
+	returnStmt, ok := ifStmt.Body.List[0].(*ast.ReturnStmt)
+	if !ok || len(returnStmt.Results) != 1 {
+		return fmt.Errorf("expected a single return statement for the shared table `%s`", ident.Name)
+	}
+	result, ok := returnStmt.Results[0].(*ast.BinaryExpr)
+	if !ok || result.Op != token.ADD {
+		return fmt.Errorf("expected an offset to be added to the shared table `%s`", ident.Name)
+	}
+	offset, err := parseInt(result.Y)
+	if err != nil {
+		return err
+	}
+	rw.sharedRanges = append(rw.sharedRanges, sharedWeightRange{weights: weights, offset: int32(offset)})
+	return nil
+}
+
+// parseSharedWeightTables parses a file that was previously generated by SharedWeightsToGoFile, returning each table's
+// weights by the identifier of the table.
+func parseSharedWeightTables(src string) (map[string]map[rune]int32, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), "", src, 0)
+	if err != nil {
+		return nil, err
+	}
+	tables := make(map[string]map[rune]int32)
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			valueSpec, ok := spec.(*ast.ValueSpec)
+			if !ok || len(valueSpec.Values) != 1 || !strings.HasSuffix(valueSpec.Names[0].Name, "_Weights") {
+				continue
+			}
+			runes, err := parseRuneMap(valueSpec.Values[0])
+			if err != nil {
+				return nil, err
+			}
+			weights := make(map[rune]int32, len(runes))
+			for _, pair := range runes {
+				weights[pair[0]] = pair[1]
+			}
+			tables[valueSpec.Names[0].Name] = weights
+		}
+	}
+	return tables, nil
+}
+
+// parseSortedRanges parses the slice of ranges from a file generated by RuneComparatorToSortedSliceGoFile. Each element
+// has the form `{lower, upper, value, isOffset}`.
+func (rw *RuneWeights) parseSortedRanges(expr ast.Expr) error {
+	lit, ok := expr.(*ast.CompositeLit)
+	if !ok {
+		return fmt.Errorf("expected a composite literal for the weight ranges")
+	}
+	for _, elt := range lit.Elts {
+		rangeLit, ok := elt.(*ast.CompositeLit)
+		if !ok || len(rangeLit.Elts) != 4 {
+			return fmt.Errorf("expected each weight range to contain a lower bound, upper bound, value, and kind")
+		}
+		var vals [3]int64
+		for i := range vals {
+			val, err := parseSignedInt(rangeLit.Elts[i])
+			if err != nil {
+				return err
+			}
+			vals[i] = val
+		}
+		isOffset, err := parseBool(rangeLit.Elts[3])
+		if err != nil {
+			return err
+		}
+		if isOffset {
+			rw.dynamicRanges = append(rw.dynamicRanges, dynamicWeightRange{Offset: int(vals[2]), Lower: rune(vals[0]), Upper: rune(vals[1])})
+		} else {
+			rw.staticRanges = append(rw.staticRanges, staticWeightRange{Weight: int(vals[2]), Lower: rune(vals[0]), Upper: rune(vals[1])})
+		}
+	}
+	return nil
+}
+
+// parseSignedInt parses an integer literal that may be negated.
+func parseSignedInt(expr ast.Expr) (int64, error) {
+	if unary, ok := expr.(*ast.UnaryExpr); ok && unary.Op == token.SUB {
+		val, err := parseInt(unary.X)
+		return -val, err
+	}
+	return parseInt(expr)
+}
+
+// parseMapChunk parses the body of an init function that adds a chunk of the weight map, which ranges over a map
+// literal.
+func (rw *RuneWeights) parseMapChunk(body *ast.BlockStmt) error {
+	if len(body.List) != 1 {
+		return fmt.Errorf("expected a single loop within the weight map chunk")
+	}
+	rangeStmt, ok := body.List[0].(*ast.RangeStmt)
+	if !ok {
+		return fmt.Errorf("expected a single loop within the weight map chunk")
+	}
+	mapLit, ok := rangeStmt.X.(*ast.CompositeLit)
+	if !ok {
+		return fmt.Errorf("expected a composite literal for the weight map chunk")
+	}
+	runes, err := parseRuneMap(mapLit)
+	if err != nil {
+		return err
+	}
+	for _, pair := range runes {
+		rw.weights[pair[0]] = pair[1]
+	}
+	return nil
+}
+
+// parseRangeComparison parses a comparison of the form `r <op> value`, returning the value.
+func parseRangeComparison(expr ast.Expr, op token.Token) (int64, error) {
+	comparison, ok := expr.(*ast.BinaryExpr)
+	if !ok || comparison.Op != op {
+		return 0, fmt.Errorf("expected a `%s` comparison in the weight function", op)
+	}
+	return parseInt(comparison.Y)
+}
@@ -0,0 +1,63 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"os"
+)
+
+// runeComparatorFile is the saved state of a RuneComparator, which uses the same ordering format as Model.
+type runeComparatorFile struct {
+	Weights [][]rune
+}
+
+// Save writes the ordering of the RuneComparator to the given path, so that it may be loaded by LoadRuneComparator. The
+// comparator is not saved.
+func (rc *RuneComparator) Save(path string) error {
+	buf := bytes.Buffer{}
+	if err := gob.NewEncoder(&buf).Encode(runeComparatorFile{Weights: rc.rows()}); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// LoadRuneComparator reads the RuneComparator that was saved to the given path. The comparator is not set, so
+// SetComparator must be called before inserting new runes, such as the codepoints that a newer server added to a
+// previously extracted collation.
+func LoadRuneComparator(path string) (*RuneComparator, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file := runeComparatorFile{}
+	if err = gob.NewDecoder(bytes.NewReader(contents)).Decode(&file); err != nil {
+		return nil, fmt.Errorf("unable to decode the rune comparator at `%s`: %w", path, err)
+	}
+	return NewRuneComparatorFromOrder(file.Weights), nil
+}
+
+// Runes returns the set of every rune within the RuneComparator.
+func (rc *RuneComparator) Runes() map[rune]struct{} {
+	runes := make(map[rune]struct{})
+	for _, row := range rc.rows() {
+		for _, r := range row {
+			runes[r] = struct{}{}
+		}
+	}
+	return runes
+}
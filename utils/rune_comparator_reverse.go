@@ -0,0 +1,91 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RuneComparatorReverseToGoFile returns a Go file for inclusion in an application, alongside the file that
+// RuneComparatorToGoFile generated for the same RuneComparator, which maps a weight back to every rune that carries it.
+// This allows a rune to be expanded into every rune that compares as equal to it, such as for pattern matching. The
+// weights are written using the same ranges as the weight function, so that sequential runes do not require map
+// entries.
+func RuneComparatorReverseToGoFile(rc *RuneComparator, name string, options CodegenOptions) string {
+	titleName, lowerName := options.names(name)
+
+	fileSb := strings.Builder{}
+	fileSb.WriteString(fmt.Sprintf(`%s
+
+import "sort"
+
+// %s_WeightRunes returns every rune that has the given weight for the %s collation, in sequential
+// order. This is the reverse of %s_RuneWeight. Returns nil when no rune has the given weight.
+func %s_WeightRunes(weight int32) []rune {
+	runes := append([]rune(nil), %s_weightRunes[weight]...)
+`, options.fileHeader(), titleName, "`"+lowerName+"`", titleName, titleName, lowerName))
+	mapSb := strings.Builder{}
+	mapSb.WriteString(fmt.Sprintf("var %s_weightRunes = map[int32][]rune{\n", lowerName))
+
+	dynamicWeightRanges, staticWeightRanges := rc.weightRanges()
+	// Each rune of a dynamic range has its own weight, so the rune is found by removing the offset from the weight
+	for _, rowWeightRange := range dynamicWeightRanges {
+		sign := "-"
+		offset := rowWeightRange.Offset
+		if offset < 0 {
+			sign = "+"
+			offset *= -1
+		}
+		fileSb.WriteString(fmt.Sprintf("\tif weight >= %d && weight <= %d {\n\t\trunes = append(runes, weight%s%d)\n\t}\n",
+			int(rowWeightRange.Lower)+rowWeightRange.Offset, int(rowWeightRange.Upper)+rowWeightRange.Offset, sign, offset))
+	}
+	var mapWeights []int
+	mapRunes := make(map[int][]string)
+	for _, rowWeightRange := range staticWeightRanges {
+		if rowWeightRange.Upper-rowWeightRange.Lower >= staticWeightRangeCutoff {
+			fileSb.WriteString(fmt.Sprintf("\tif weight == %d {\n\t\tfor r := rune(%d); r <= %d; r++ {\n\t\t\trunes = append(runes, r)\n\t\t}\n\t}\n",
+				rowWeightRange.Weight, rowWeightRange.Lower, rowWeightRange.Upper))
+			continue
+		}
+		if _, ok := mapRunes[rowWeightRange.Weight]; !ok {
+			mapWeights = append(mapWeights, rowWeightRange.Weight)
+		}
+		for r := rowWeightRange.Lower; r <= rowWeightRange.Upper; r++ {
+			mapRunes[rowWeightRange.Weight] = append(mapRunes[rowWeightRange.Weight], fmt.Sprint(r))
+		}
+	}
+	sort.Ints(mapWeights)
+	for _, weight := range mapWeights {
+		mapSb.WriteString(fmt.Sprintf("\t%d: {%s},\n", weight, strings.Join(mapRunes[weight], ", ")))
+	}
+	mapSb.WriteString("}\n")
+
+	fileSb.WriteString(fmt.Sprintf(`	if len(runes) == 0 {
+		return nil
+	}
+	sort.Slice(runes, func(i, j int) bool {
+		return runes[i] < runes[j]
+	})
+	return runes
+}
+
+// %s_weightRunes contains a map from weight to runes for the %s collation, containing the runes
+// that the weight function finds within its map. Runes within sequential ranges (that are long enough) are found in the
+// calling function to save space.
+%s`, lowerName, "`"+lowerName+"`", mapSb.String()))
+	return fileSb.String()
+}
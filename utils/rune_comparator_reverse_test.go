@@ -0,0 +1,49 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuneComparatorReverseToGoFile(t *testing.T) {
+	var rows [][]rune
+	// Each of these runes has its own sequential weight, which becomes a dynamic range
+	for r := rune(0x100); r < 0x100+300; r++ {
+		rows = append(rows, []rune{r})
+	}
+	// The long range shares its weight with runes that are written as map entries
+	shared := []rune{5, 7}
+	for r := rune(0x1000); r < 0x1000+200; r++ {
+		shared = append(shared, r)
+	}
+	rows = append(rows, shared, []rune{'a', 'b'})
+	rc := NewRuneComparatorFromOrder(rows)
+
+	contents := RuneComparatorReverseToGoFile(rc, "test", CodegenOptions{})
+	_, err := parser.ParseFile(token.NewFileSet(), "", contents, 0)
+	require.NoError(t, err)
+	assert.True(t, strings.Contains(contents, "func Test_WeightRunes(weight int32) []rune {"))
+	assert.True(t, strings.Contains(contents, "\tif weight >= 0 && weight <= 299 {\n\t\trunes = append(runes, weight+256)\n\t}\n"))
+	assert.True(t, strings.Contains(contents, "\tif weight == 300 {\n\t\tfor r := rune(4096); r <= 4295; r++ {"))
+	assert.True(t, strings.Contains(contents, "\t300: {5, 7},\n"))
+	assert.True(t, strings.Contains(contents, "\t301: {97, 98},\n"))
+}
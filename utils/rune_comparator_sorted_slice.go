@@ -0,0 +1,78 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RuneComparatorToSortedSliceGoFile returns the given RuneComparator as a Go file for inclusion in an application,
+// which contains the same functions as the file from RuneComparatorToGoFile. Rather than a map, every range of runes is
+// written to a slice sorted by the lower bound of each range, which the weight function binary searches. Each range
+// either adds an offset to the rune or has a single weight. This is often smaller and faster than a map, and a slice
+// literal does not need to be built when the program starts.
+func RuneComparatorToSortedSliceGoFile(rc *RuneComparator, name string, padSpace bool, options CodegenOptions) string {
+	titleName, lowerName := options.names(name)
+
+	dynamicWeightRanges, staticWeightRanges := rc.weightRanges()
+	ranges := make([]sortedWeightRange, 0, len(dynamicWeightRanges)+len(staticWeightRanges))
+	for _, dynamic := range dynamicWeightRanges {
+		ranges = append(ranges, sortedWeightRange{Lower: dynamic.Lower, Upper: dynamic.Upper, Value: int32(dynamic.Offset), IsOffset: true})
+	}
+	for _, static := range staticWeightRanges {
+		ranges = append(ranges, sortedWeightRange{Lower: static.Lower, Upper: static.Upper, Value: int32(static.Weight)})
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].Lower < ranges[j].Lower
+	})
+
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf(`%[1]s
+
+import "sort"
+
+// %[2]s_RuneWeight returns the weight of a given rune based on its relational sort order from
+// the %[4]s collation.
+func %[2]s_RuneWeight(r rune) int32 {
+	idx := sort.Search(len(%[3]s_weightRanges), func(i int) bool {
+		return %[3]s_weightRanges[i].upper >= r
+	})
+	if idx < len(%[3]s_weightRanges) && %[3]s_weightRanges[idx].lower <= r {
+		if %[3]s_weightRanges[idx].isOffset {
+			return r + %[3]s_weightRanges[idx].value
+		}
+		return %[3]s_weightRanges[idx].value
+	}
+	return 2147483647
+}
+
+%[5]s
+// %[3]s_weightRanges contains every range of runes for the %[4]s collation, sorted by their lower
+// bound. The value of a range is either an offset that is added to the rune, or the weight of every rune within it.
+var %[3]s_weightRanges = []struct {
+	lower    rune
+	upper    rune
+	value    int32
+	isOffset bool
+}{
+`, options.fileHeader(), titleName, lowerName, "`"+lowerName+"`", runeComparatorCompareFunc(titleName, lowerName, padSpace, false)))
+	for _, rng := range ranges {
+		sb.WriteString(fmt.Sprintf("\t{%d, %d, %d, %t},\n", rng.Lower, rng.Upper, rng.Value, rng.IsOffset))
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
@@ -0,0 +1,58 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuneComparatorToSortedSliceGoFile(t *testing.T) {
+	const runeCount = 2000
+	weights := shuffledRuneWeights(runeCount, 2)
+	runes := make([]rune, runeCount)
+	for r := range runes {
+		runes[r] = rune(r)
+	}
+	rc := NewRuneComparator()
+	rc.InsertWeighted(runes, weights)
+	// A long run of sequential weights becomes an offset range, and a long run of runes with a single weight becomes a
+	// static range
+	order := rc.rows()
+	for r := rune(0x3000); r < 0x3200; r++ {
+		order = append(order, []rune{r})
+	}
+	var shared []rune
+	for r := rune(0x4000); r < 0x4200; r++ {
+		shared = append(shared, r)
+	}
+	rc = NewRuneComparatorFromOrder(append(order, shared))
+
+	contents := RuneComparatorToSortedSliceGoFile(rc, "test_ci", true, CodegenOptions{})
+	_, err := parser.ParseFile(token.NewFileSet(), "", contents, 0)
+	require.NoError(t, err)
+	expected, err := ParseRuneComparatorGoFile(RuneComparatorToGoFile(rc, "test_ci", true))
+	require.NoError(t, err)
+	sorted, err := ParseRuneComparatorGoFile(contents)
+	require.NoError(t, err)
+	require.True(t, sorted.PadSpace())
+	require.NotEmpty(t, sorted.dynamicRanges)
+	for r := rune(0); r < 0x5000; r++ {
+		require.Equal(t, expected.Weight(r), sorted.Weight(r), "rune %d", r)
+	}
+}
@@ -0,0 +1,279 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestRuneComparatorInsertWeighted verifies that inserting a batch of weighted runes matches inserting each rune using a
+// weight comparator, without calling the comparator, and that weightless runes may be inserted afterward.
+func TestRuneComparatorInsertWeighted(t *testing.T) {
+	weights := make(map[rune][]byte)
+	var runes []rune
+	for r := rune(0x20); r < 0x3000; r++ {
+		// Several runes share each weight, and the weights do not follow the order of the runes
+		weights[r] = []byte{byte((r * 7) % 61), byte(r % 3)}
+		runes = append(runes, r)
+	}
+	comparisons := 0
+	comparator := func(l rune, r rune) int {
+		comparisons++
+		return bytes.Compare(weights[l], weights[r])
+	}
+
+	expected := NewRuneComparator()
+	expected.SetComparator(comparator)
+	for _, r := range runes {
+		expected.Insert(r)
+	}
+	comparisons = 0
+
+	batched := NewRuneComparator()
+	batched.SetComparator(comparator)
+	batched.InsertWeighted(runes, weights)
+	require.Zero(t, comparisons)
+	require.Equal(t, expected.rows(), batched.rows())
+
+	// A weightless rune is placed using the comparator, which treats it as equal to the weight of 'a'
+	weightless := rune(0x3000)
+	batched.SetComparator(func(l rune, r rune) int {
+		if l == weightless {
+			l = 'a'
+		}
+		return comparator(l, r)
+	})
+	batched.Insert(weightless)
+	require.NotZero(t, comparisons)
+	for _, row := range batched.rows() {
+		if row[0] == weightless || bytes.Equal(weights[row[0]], weights['a']) {
+			require.Contains(t, row, rune('a'))
+			require.Equal(t, weightless, row[len(row)-1])
+		}
+	}
+}
+
+// TestRuneComparatorIncremental verifies that a saved RuneComparator may be loaded and extended with new runes, which
+// matches inserting every rune from the start.
+func TestRuneComparatorIncremental(t *testing.T) {
+	weights := make(map[rune][]byte)
+	for r := rune(0x20); r < 0x1000; r++ {
+		weights[r] = []byte{byte((r * 7) % 61), byte(r % 3)}
+	}
+	comparator := func(l rune, r rune) int {
+		return bytes.Compare(weights[l], weights[r])
+	}
+	expected := NewRuneComparator()
+	expected.SetComparator(comparator)
+	for r := rune(0x20); r < 0x1000; r++ {
+		expected.Insert(r)
+	}
+
+	// The base is missing every fifth rune, which are inserted after loading
+	base := NewRuneComparator()
+	base.SetComparator(comparator)
+	for r := rune(0x20); r < 0x1000; r++ {
+		if r%5 != 0 {
+			base.Insert(r)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "base.order.bin")
+	require.NoError(t, base.Save(path))
+	loaded, err := LoadRuneComparator(path)
+	require.NoError(t, err)
+	require.Equal(t, base.rows(), loaded.rows())
+
+	loaded.SetComparator(comparator)
+	existing := loaded.Runes()
+	for r := rune(0x20); r < 0x1000; r++ {
+		if _, ok := existing[r]; !ok {
+			loaded.Insert(r)
+		}
+	}
+	require.Equal(t, expected.rows(), loaded.rows())
+}
+
+// TestRuneComparatorRemoveMisordered verifies that reusing the order of a base collation for a tailoring of it, by
+// removing the runes that the tailoring orders differently and inserting them again, matches inserting every rune using
+// the tailoring, while comparing far fewer runes.
+func TestRuneComparatorRemoveMisordered(t *testing.T) {
+	baseWeights := make(map[rune][]byte)
+	for r := rune(0x20); r < 0x1000; r++ {
+		baseWeights[r] = []byte{byte(r >> 8), byte(r & 0xF0)}
+	}
+	tailoredWeights := make(map[rune][]byte)
+	for r, weight := range baseWeights {
+		tailoredWeights[r] = weight
+	}
+	// Runes move later and earlier, split from their row, and join another row, along with a run of adjacent runes
+	// that all move later
+	tailoredWeights[0x123] = []byte{0x0F, 0xFF}
+	tailoredWeights[0xE42] = []byte{0x00, 0x01}
+	tailoredWeights[0x456] = []byte{0x04, 0x55}
+	tailoredWeights[0x789] = baseWeights[0x321]
+	for r := rune(0x500); r < 0x540; r++ {
+		tailoredWeights[r] = []byte{0x0F, 0xF0}
+	}
+	comparisons := 0
+	comparatorOf := func(weights map[rune][]byte) func(l rune, r rune) int {
+		return func(l rune, r rune) int {
+			comparisons++
+			return bytes.Compare(weights[l], weights[r])
+		}
+	}
+
+	expected := NewRuneComparator()
+	expected.SetComparator(comparatorOf(tailoredWeights))
+	for r := rune(0x20); r < 0x1000; r++ {
+		expected.Insert(r)
+	}
+	fullComparisons := comparisons
+
+	tailored := NewRuneComparator()
+	tailored.SetComparator(comparatorOf(baseWeights))
+	for r := rune(0x20); r < 0x1000; r++ {
+		tailored.Insert(r)
+	}
+	comparisons = 0
+	tailored.SetComparator(comparatorOf(tailoredWeights))
+	removed := tailored.RemoveMisordered()
+	for _, r := range []rune{0x123, 0xE42, 0x456, 0x789, 0x500, 0x53F} {
+		require.Contains(t, removed, r)
+	}
+	for _, r := range removed {
+		tailored.Insert(r)
+	}
+	require.Equal(t, expected.rows(), tailored.rows())
+	require.Less(t, comparisons, fullComparisons/2)
+	require.Less(t, len(removed), 0x100)
+
+	// The order of the collation itself has nothing to remove
+	require.Empty(t, tailored.RemoveMisordered())
+	require.Equal(t, expected.rows(), tailored.rows())
+}
+
+// TestRuneComparatorBlocks verifies that insertion across many blocks places every rune by its weight, where the
+// weights are shuffled so that new rows are inserted throughout the existing rows.
+func TestRuneComparatorBlocks(t *testing.T) {
+	const runeCount = 20 * runeComparatorBlockSize
+	weights := shuffledRuneWeights(runeCount, 3)
+	rc := NewRuneComparator()
+	rc.SetComparator(func(l rune, r rune) int {
+		return bytes.Compare(weights[l], weights[r])
+	})
+	for r := rune(0); r < runeCount; r++ {
+		rc.Insert(r)
+	}
+	require.Greater(t, len(rc.blocks), 1)
+
+	expected := NewRuneComparator()
+	runes := make([]rune, runeCount)
+	for r := range runes {
+		runes[r] = rune(r)
+	}
+	expected.InsertWeighted(runes, weights)
+	require.Equal(t, expected.rows(), rc.rows())
+	require.Equal(t, expected.Len(), rc.Len())
+	for _, block := range rc.blocks {
+		require.LessOrEqual(t, len(block), 2*runeComparatorBlockSize)
+	}
+}
+
+// BenchmarkRuneComparatorInsert measures inserting a million runes, where nearly every rune has its own weight and the
+// weights are shuffled, which is the worst case for adding new rows.
+func BenchmarkRuneComparatorInsert(b *testing.B) {
+	const runeCount = 1000000
+	weights := shuffledRuneWeights(runeCount, 1)
+	comparator := func(l rune, r rune) int {
+		return bytes.Compare(weights[l], weights[r])
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rc := NewRuneComparator()
+		rc.SetComparator(comparator)
+		for r := rune(0); r < runeCount; r++ {
+			rc.Insert(r)
+		}
+	}
+}
+
+// BenchmarkRuneComparatorInsertWeighted measures inserting a million weighted runes as a single batch.
+func BenchmarkRuneComparatorInsertWeighted(b *testing.B) {
+	const runeCount = 1000000
+	weights := shuffledRuneWeights(runeCount, 1)
+	runes := make([]rune, runeCount)
+	for r := range runes {
+		runes[r] = rune(r)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewRuneComparator().InsertWeighted(runes, weights)
+	}
+}
+
+// shuffledRuneWeights returns a weight for every rune below the given count, where each weight is shared by the given
+// number of runes, and the order of the weights is shuffled relative to the runes.
+func shuffledRuneWeights(runeCount int, shared int) map[rune][]byte {
+	random := rand.New(rand.NewSource(1))
+	order := random.Perm(runeCount)
+	weights := make(map[rune][]byte, runeCount)
+	for r, position := range order {
+		weight := uint32(position / shared)
+		weights[rune(r)] = []byte{byte(weight >> 24), byte(weight >> 16), byte(weight >> 8), byte(weight)}
+	}
+	return weights
+}
+
+// TestRuneComparatorToGoFileChunks verifies that a weight map written in chunks contains the same weights as a single
+// map literal.
+func TestRuneComparatorToGoFileChunks(t *testing.T) {
+	const runeCount = 1000
+	weights := shuffledRuneWeights(runeCount, 2)
+	runes := make([]rune, runeCount)
+	for r := range runes {
+		runes[r] = rune(r)
+	}
+	rc := NewRuneComparator()
+	rc.InsertWeighted(runes, weights)
+
+	single, err := ParseRuneComparatorGoFile(RuneComparatorToGoFile(rc, "test_ci", false))
+	require.NoError(t, err)
+	contents := RuneComparatorToGoFileWithOptions(rc, "test_ci", false, RuneComparatorGoFileOptions{MapChunkSize: 128})
+	require.Equal(t, (len(single.weights)+127)/128, strings.Count(contents, "\nfunc init() {\n"))
+	chunked, err := ParseRuneComparatorGoFile(contents)
+	require.NoError(t, err)
+	require.Equal(t, single.weights, chunked.weights)
+	for r := rune(0); r < runeCount; r++ {
+		require.Equal(t, single.Weight(r), chunked.Weight(r))
+	}
+}
+
+func TestRuneWeightsOrder(t *testing.T) {
+	order := [][]rune{{'a', 'A'}, {'b'}, {'c', 'C'}, {'d'}}
+	runeWeights, err := ParseRuneComparatorGoFile(RuneComparatorToGoFile(NewRuneComparatorFromOrder(order), "test_ci", true))
+	require.NoError(t, err)
+	require.True(t, runeWeights.PadSpace())
+	// Runes are sorted within their rows, and runes without a weight are omitted
+	require.Equal(t, [][]rune{{'A', 'a'}, {'b'}, {'C', 'c'}, {'d'}}, runeWeights.Order([]rune("dcbaCAz")))
+	require.Equal(t, [][]rune{{'a'}, {'d'}}, runeWeights.Order([]rune("da")))
+}
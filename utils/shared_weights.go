@@ -0,0 +1,225 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SharedWeightTable is a block of weights that is identical (other than a constant offset) across several collations,
+// such as the Latin runes of most `_ci` collations. The table is generated once, and each collation adds its own offset
+// to the table's weights, rather than listing the block within its own weight map.
+type SharedWeightTable struct {
+	// Name is the identifier that the table is generated with, which is followed by `_Weights`.
+	Name string
+	// Lower and Upper are the bounds of the block that the table was found within.
+	Lower rune
+	Upper rune
+	// Weights are the weights of the runes within the block, with the smallest weight being zero.
+	Weights map[rune]int32
+	// Collations are the names of the collations that reference the table, sorted by name.
+	Collations []string
+}
+
+// SharedWeightReference is a single collation's use of a SharedWeightTable. The weight of a rune within the table is the
+// table's weight plus the offset.
+type SharedWeightReference struct {
+	Table  *SharedWeightTable
+	Offset int32
+}
+
+// SharedWeights are the SharedWeightTables that were found across a set of collations.
+type SharedWeights struct {
+	// Tables are sorted by their lower bound, followed by their name.
+	Tables []*SharedWeightTable
+	// References maps the lower-cased name of each collation to the tables that it references, in the same order as
+	// Tables. Collations that do not reference any table are omitted.
+	References map[string][]SharedWeightReference
+}
+
+// SharedWeightBlockSize is the default number of runes within each block that FindSharedWeightTables compares. Smaller
+// blocks are more likely to be identical across collations, as a single tailored rune prevents its entire block from
+// being shared, but each reference adds a map lookup to the generated weight function.
+const SharedWeightBlockSize = 256
+
+// sharedWeightTableCutoff is the minimum number of weights within a SharedWeightTable, as smaller tables cost more in
+// lookups than they save in size.
+const sharedWeightTableCutoff = 32
+
+// FindSharedWeightTables splits the weight maps of the given collation Models into blocks of the given size (see
+// SharedWeightBlockSize), and returns the blocks that are identical across two or more Models. Two blocks are identical
+// when they contain the same runes, and every weight differs by the same amount. Only the runes that the generated file
+// lists within its weight map are considered, as ranges are already compact. Models with identical weights should be
+// grouped beforehand (see GroupCollationModels), as aliases would otherwise share every block.
+func FindSharedWeightTables(models []*Model, blockSize int) (*SharedWeights, error) {
+	if blockSize <= 0 {
+		return nil, fmt.Errorf("the block size must be positive")
+	}
+	type blockUse struct {
+		collation string
+		offset    int32
+	}
+	type candidate struct {
+		lower   rune
+		weights map[rune]int32
+		uses    []blockUse
+	}
+	candidates := make(map[string]*candidate)
+	for _, model := range models {
+		if model.Kind != ManifestKindCollation {
+			return nil, fmt.Errorf("model `%s` is a %s rather than a %s", model.Name, model.Kind, ManifestKindCollation)
+		}
+		rc, err := model.RuneComparator()
+		if err != nil {
+			return nil, err
+		}
+		blocks := make(map[rune]map[rune]int32)
+		for r, weight := range rc.mapWeights() {
+			lower := r - r%rune(blockSize)
+			if blocks[lower] == nil {
+				blocks[lower] = make(map[rune]int32)
+			}
+			blocks[lower][r] = weight
+		}
+		for lower, block := range blocks {
+			if len(block) < sharedWeightTableCutoff {
+				continue
+			}
+			offset := int32(2147483647)
+			for _, weight := range block {
+				if weight < offset {
+					offset = weight
+				}
+			}
+			for r := range block {
+				block[r] -= offset
+			}
+			key := sharedWeightTableKey(lower, block)
+			if candidates[key] == nil {
+				candidates[key] = &candidate{lower: lower, weights: block}
+			}
+			candidates[key].uses = append(candidates[key].uses, blockUse{collation: strings.ToLower(model.Name), offset: offset})
+		}
+	}
+
+	shared := &SharedWeights{References: make(map[string][]SharedWeightReference)}
+	var sharedCandidates []*candidate
+	for _, c := range candidates {
+		if len(c.uses) < 2 {
+			continue
+		}
+		sort.Slice(c.uses, func(i, j int) bool {
+			return c.uses[i].collation < c.uses[j].collation
+		})
+		sharedCandidates = append(sharedCandidates, c)
+	}
+	// Blocks are named by their position, so that the names do not change between runs
+	sort.Slice(sharedCandidates, func(i, j int) bool {
+		if sharedCandidates[i].lower != sharedCandidates[j].lower {
+			return sharedCandidates[i].lower < sharedCandidates[j].lower
+		}
+		return sharedCandidates[i].uses[0].collation < sharedCandidates[j].uses[0].collation
+	})
+	index := 0
+	for i, c := range sharedCandidates {
+		// Different groups of collations may share different tables within the same block
+		if i > 0 && sharedCandidates[i-1].lower == c.lower {
+			index++
+		} else {
+			index = 0
+		}
+		table := &SharedWeightTable{
+			Name:    fmt.Sprintf("shared_%04X_%d", c.lower, index),
+			Lower:   c.lower,
+			Upper:   c.lower + rune(blockSize) - 1,
+			Weights: c.weights,
+		}
+		shared.Tables = append(shared.Tables, table)
+		for _, use := range c.uses {
+			table.Collations = append(table.Collations, use.collation)
+			shared.References[use.collation] = append(shared.References[use.collation], SharedWeightReference{Table: table, Offset: use.offset})
+		}
+	}
+	return shared, nil
+}
+
+// SharedWeightsToGoFile returns the given SharedWeights as a Go file, which the files that RuneComparatorToGoFileWithOptions
+// generates with references to the tables depend on.
+func SharedWeightsToGoFile(shared *SharedWeights, options CodegenOptions) string {
+	fileSb := strings.Builder{}
+	fileSb.WriteString(options.fileHeader())
+	fileSb.WriteString("\n")
+	for _, table := range shared.Tables {
+		fileSb.WriteString(fmt.Sprintf(`
+// %s_Weights contain the weights of the runes from %d to %d that are shared by the following collations, each of
+// which adds its own offset: %s.
+var %s_Weights = map[rune]int32{
+`, table.Name, table.Lower, table.Upper, "`"+strings.Join(table.Collations, "`, `")+"`", table.Name))
+		for _, r := range table.runes() {
+			fileSb.WriteString(fmt.Sprintf("\t%d: %d,\n", r, table.Weights[r]))
+		}
+		fileSb.WriteString("}\n")
+	}
+	return fileSb.String()
+}
+
+// contains returns whether the table contains the given rune with the given weight, once the reference's offset has
+// been applied.
+func (ref SharedWeightReference) contains(r rune, weight int32) bool {
+	tableWeight, ok := ref.Table.Weights[r]
+	return ok && tableWeight+ref.Offset == weight
+}
+
+// runes returns the runes of the table in ascending order.
+func (table *SharedWeightTable) runes() []rune {
+	runes := make([]rune, 0, len(table.Weights))
+	for r := range table.Weights {
+		runes = append(runes, r)
+	}
+	sort.Slice(runes, func(i, j int) bool {
+		return runes[i] < runes[j]
+	})
+	return runes
+}
+
+// mapWeights returns the weights of the runes that the generated file lists within its weight map, which are the runes
+// of the static ranges that are too short to be written as a range comparison.
+func (rc *RuneComparator) mapWeights() map[rune]int32 {
+	weights := make(map[rune]int32)
+	_, staticWeightRanges := rc.weightRanges()
+	for _, rowWeightRange := range staticWeightRanges {
+		if rowWeightRange.Upper-rowWeightRange.Lower >= staticWeightRangeCutoff {
+			continue
+		}
+		for r := rowWeightRange.Lower; r <= rowWeightRange.Upper; r++ {
+			weights[r] = int32(rowWeightRange.Weight)
+		}
+	}
+	return weights
+}
+
+// sharedWeightTableKey returns a key that is identical for two blocks only when they start at the same rune and contain
+// the same weights.
+func sharedWeightTableKey(lower rune, weights map[rune]int32) string {
+	table := SharedWeightTable{Weights: weights}
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("%d:", lower))
+	for _, r := range table.runes() {
+		sb.WriteString(fmt.Sprintf("%d=%d,", r, weights[r]))
+	}
+	return sb.String()
+}
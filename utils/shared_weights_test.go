@@ -0,0 +1,77 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// latinExtendedOrder returns the runes from U+0100 to U+013F in descending order, so that none of them may be written
+// as a range.
+func latinExtendedOrder() [][]rune {
+	var order [][]rune
+	for r := rune(0x013F); r >= 0x0100; r-- {
+		order = append(order, []rune{r})
+	}
+	return order
+}
+
+func TestFindSharedWeightTables(t *testing.T) {
+	// Both collations order the block identically, but `b` sorts before the block in the second, shifting its weights
+	first := NewCollationModel("first_ci", NewRuneComparatorFromOrder(append([][]rune{{'a'}}, latinExtendedOrder()...)), true)
+	second := NewCollationModel("second_ci", NewRuneComparatorFromOrder(append([][]rune{{'a'}, {'b'}}, latinExtendedOrder()...)), true)
+	// The third collation orders a single rune of the block differently, so it does not share the block
+	thirdOrder := latinExtendedOrder()
+	thirdOrder[0], thirdOrder[1] = thirdOrder[1], thirdOrder[0]
+	third := NewCollationModel("third_ci", NewRuneComparatorFromOrder(thirdOrder), true)
+
+	shared, err := FindSharedWeightTables([]*Model{third, second, first}, SharedWeightBlockSize)
+	require.NoError(t, err)
+	require.Len(t, shared.Tables, 1)
+	table := shared.Tables[0]
+	assert.Equal(t, "shared_0100_0", table.Name)
+	assert.Equal(t, rune(0x0100), table.Lower)
+	assert.Equal(t, rune(0x01FF), table.Upper)
+	assert.Equal(t, []string{"first_ci", "second_ci"}, table.Collations)
+	assert.Equal(t, int32(0), table.Weights[0x013F])
+	assert.Equal(t, []SharedWeightReference{{Table: table, Offset: 1}}, shared.References["first_ci"])
+	assert.Equal(t, []SharedWeightReference{{Table: table, Offset: 2}}, shared.References["second_ci"])
+	assert.Empty(t, shared.References["third_ci"])
+
+	sharedContents := SharedWeightsToGoFile(shared, CodegenOptions{})
+	for _, model := range []*Model{first, second} {
+		rc, err := model.RuneComparator()
+		require.NoError(t, err)
+		contents := RuneComparatorToGoFileWithOptions(rc, model.Name, true, RuneComparatorGoFileOptions{
+			SharedWeights: shared.References[model.Name],
+		})
+		assert.NotContains(t, contents, "256: ")
+		_, err = ParseRuneComparatorGoFile(contents)
+		assert.Error(t, err)
+		runeWeights, err := ParseRuneComparatorGoFileWithSharedWeights(contents, sharedContents)
+		require.NoError(t, err)
+		var runes []rune
+		for _, row := range model.Weights {
+			runes = append(runes, row...)
+		}
+		assert.Equal(t, model.Weights, runeWeights.Order(runes))
+	}
+
+	_, err = FindSharedWeightTables([]*Model{first}, 0)
+	assert.Error(t, err)
+}
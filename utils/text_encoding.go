@@ -0,0 +1,130 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+)
+
+// TextEncodingToGoFile returns a Go file that implements golang.org/x/text/encoding.Encoding for the character set,
+// alongside the file that RangeMapToGoFile generated for the same character set, which declares the RangeMap and the
+// length of its longest codepoint. This allows Go programs outside of GMS to transcode streams using the extracted
+// tables. The transformers find each codepoint by decoding the shortest prefix that the RangeMap accepts, so a
+// codepoint that is split across buffers returns transform.ErrShortSrc until the rest of it arrives. Invalid byte
+// sequences decode to U+FFFD, which matches the decoders of golang.org/x/text, while runes that the character set does
+// not contain fail to encode.
+func TextEncodingToGoFile(name string, options CodegenOptions) string {
+	titleName, lowerName := options.names(name)
+	return fmt.Sprintf(`%[1]s
+
+import (
+	"errors"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/transform"
+)
+
+// %[2]s_TextEncoding implements encoding.Encoding for the %[4]s character set using %[2]s, so that streams may be
+// transcoded outside of GMS. Invalid byte sequences decode to U+FFFD, while runes that are not in the character set
+// fail to encode.
+var %[2]s_TextEncoding encoding.Encoding = %[3]sTextEncoding{}
+
+// err%[2]sUnsupported is returned when encoding a rune that is not in the %[4]s character set.
+var err%[2]sUnsupported = errors.New("encoding: rune not supported by the %[3]s character set")
+
+type %[3]sTextEncoding struct{}
+
+// NewDecoder implements the interface encoding.Encoding.
+func (%[3]sTextEncoding) NewDecoder() *encoding.Decoder {
+	return &encoding.Decoder{Transformer: %[3]sDecoder{}}
+}
+
+// NewEncoder implements the interface encoding.Encoding.
+func (%[3]sTextEncoding) NewEncoder() *encoding.Encoder {
+	return &encoding.Encoder{Transformer: %[3]sEncoder{}}
+}
+
+// %[3]sDecoder transforms the %[4]s character set to UTF8.
+type %[3]sDecoder struct{ transform.NopResetter }
+
+// Transform implements the interface transform.Transformer.
+func (%[3]sDecoder) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	for nSrc < len(src) {
+		decoded, size := []byte("\uFFFD"), 1
+		for length := 1; length <= %[3]s_MaxCodepointLength; length++ {
+			if nSrc+length > len(src) {
+				if !atEOF {
+					return nDst, nSrc, transform.ErrShortSrc
+				}
+				break
+			}
+			if output, ok := %[2]s.Decode(src[nSrc : nSrc+length]); ok {
+				decoded, size = output, length
+				break
+			}
+		}
+		if nDst+len(decoded) > len(dst) {
+			return nDst, nSrc, transform.ErrShortDst
+		}
+		nDst += copy(dst[nDst:], decoded)
+		nSrc += size
+	}
+	return nDst, nSrc, nil
+}
+
+// %[3]sEncoder transforms UTF8 to the %[4]s character set.
+type %[3]sEncoder struct{ transform.NopResetter }
+
+// Transform implements the interface transform.Transformer.
+func (%[3]sEncoder) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	for nSrc < len(src) {
+		if !atEOF && !utf8.FullRune(src[nSrc:]) {
+			return nDst, nSrc, transform.ErrShortSrc
+		}
+		r, size := utf8.DecodeRune(src[nSrc:])
+		if r == utf8.RuneError && size == 1 {
+			return nDst, nSrc, encoding.ErrInvalidUTF8
+		}
+		encoded, ok := %[2]s.Encode(src[nSrc : nSrc+size])
+		if !ok {
+			return nDst, nSrc, err%[2]sUnsupported
+		}
+		if nDst+len(encoded) > len(dst) {
+			return nDst, nSrc, transform.ErrShortDst
+		}
+		nDst += copy(dst[nDst:], encoded)
+		nSrc += size
+	}
+	return nDst, nSrc, nil
+}
+`, options.fileHeader(), titleName, lowerName, "`"+lowerName+"`")
+}
+
+// maxInputLength returns the length of the longest input codepoint that the RangeMap is able to decode.
+func (rm *RangeMap) maxInputLength() int {
+	maxLength := 0
+	for length, entryLength := range rm.inputEntries {
+		if len(entryLength) > 0 && length+1 > maxLength {
+			maxLength = length + 1
+		}
+	}
+	for _, entry := range rm.linearEntries {
+		if len(entry.inputRange) > maxLength {
+			maxLength = len(entry.inputRange)
+		}
+	}
+	return maxLength
+}
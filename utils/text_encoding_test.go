@@ -0,0 +1,54 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTextEncodingToGoFile(t *testing.T) {
+	trees := consolidationTestTrees()
+	rangeMap, err := RangeMapFromTree(trees["euc"])
+	require.NoError(t, err)
+	assert.Equal(t, 2, rangeMap.maxInputLength())
+	linearRangeMap, err := RangeMapFromTreeWithOptions(trees["bmp"], RangeMapOptions{LinearRunLength: 128})
+	require.NoError(t, err)
+	assert.Equal(t, 2, linearRangeMap.maxInputLength())
+
+	// The file depends on golang.org/x/text, so it is only parsed rather than compiled
+	file, err := parser.ParseFile(token.NewFileSet(), "", TextEncodingToGoFile("EUC", CodegenOptions{}), 0)
+	require.NoError(t, err)
+	declared := make(map[string]bool)
+	ast.Inspect(file, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.ValueSpec:
+			for _, ident := range node.Names {
+				declared[ident.Name] = true
+			}
+		case *ast.TypeSpec:
+			declared[node.Name.Name] = true
+		}
+		return true
+	})
+	for _, name := range []string{"Euc_TextEncoding", "errEucUnsupported", "eucTextEncoding", "eucDecoder", "eucEncoder"} {
+		assert.True(t, declared[name], name)
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// UnicodeAges contains the Unicode version that assigned each range of runes, as read from the DerivedAge.txt file of
+// the Unicode Character Database. The file is distributed with every Unicode version, and is a superset of the files of
+// all previous versions, so the latest file may be used to pin an extraction to any version. This is independent of the
+// Unicode tables of the Go toolchain, so that extractions are comparable between machines.
+type UnicodeAges struct {
+	ranges []unicodeAgeRange
+}
+
+// unicodeAgeRange is a single range within UnicodeAges.
+type unicodeAgeRange struct {
+	Lower   rune
+	Upper   rune
+	Version [2]int
+}
+
+// LoadDerivedAge reads the DerivedAge.txt file at the given path.
+func LoadDerivedAge(path string) (*UnicodeAges, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDerivedAge(contents)
+}
+
+// ParseDerivedAge parses the contents of a DerivedAge.txt file. Each non-comment line contains either a single rune or
+// a range of runes, followed by the version that assigned them, such as "0000..001F ; 1.1 # ...".
+func ParseDerivedAge(data []byte) (*UnicodeAges, error) {
+	ages := &UnicodeAges{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		if idx := strings.IndexByte(line, '#'); idx >= 0 {
+			line = line[:idx]
+		}
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		fields := strings.Split(line, ";")
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d of DerivedAge is malformed: `%s`", lineNumber, line)
+		}
+		runeRange := strings.Split(strings.TrimSpace(fields[0]), "..")
+		lower, err := strconv.ParseUint(runeRange[0], 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("line %d of DerivedAge is malformed: %w", lineNumber, err)
+		}
+		upper := lower
+		if len(runeRange) == 2 {
+			upper, err = strconv.ParseUint(runeRange[1], 16, 32)
+			if err != nil {
+				return nil, fmt.Errorf("line %d of DerivedAge is malformed: %w", lineNumber, err)
+			}
+		}
+		version, err := parseUnicodeVersion(strings.TrimSpace(fields[1]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d of DerivedAge is malformed: %w", lineNumber, err)
+		}
+		ages.ranges = append(ages.ranges, unicodeAgeRange{Lower: rune(lower), Upper: rune(upper), Version: version})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(ages.ranges) == 0 {
+		return nil, fmt.Errorf("DerivedAge does not contain any runes")
+	}
+	sort.Slice(ages.ranges, func(i, j int) bool {
+		return ages.ranges[i].Lower < ages.ranges[j].Lower
+	})
+	return ages, nil
+}
+
+// LatestVersion returns the most recent Unicode version within the file, which is the version of the file itself.
+func (ages *UnicodeAges) LatestVersion() string {
+	var latest [2]int
+	for _, ageRange := range ages.ranges {
+		if compareUnicodeVersions(ageRange.Version, latest) > 0 {
+			latest = ageRange.Version
+		}
+	}
+	return fmt.Sprintf("%d.%d", latest[0], latest[1])
+}
+
+// AssignedRanges returns the sorted and merged ranges of every rune that was assigned in or before the given Unicode
+// version (such as "9.0"). The ranges may be given to UTF8Iter.SetRanges.
+func (ages *UnicodeAges) AssignedRanges(version string) ([][2]rune, error) {
+	pinned, err := parseUnicodeVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	if latest, _ := parseUnicodeVersion(ages.LatestVersion()); compareUnicodeVersions(pinned, latest) > 0 {
+		return nil, fmt.Errorf("Unicode version `%s` is newer than the DerivedAge file, which is version `%s`", version, ages.LatestVersion())
+	}
+	var ranges [][2]rune
+	for _, ageRange := range ages.ranges {
+		if compareUnicodeVersions(ageRange.Version, pinned) > 0 {
+			continue
+		}
+		if len(ranges) > 0 && ranges[len(ranges)-1][1]+1 >= ageRange.Lower {
+			if ageRange.Upper > ranges[len(ranges)-1][1] {
+				ranges[len(ranges)-1][1] = ageRange.Upper
+			}
+			continue
+		}
+		ranges = append(ranges, [2]rune{ageRange.Lower, ageRange.Upper})
+	}
+	return ranges, nil
+}
+
+// assignedRanges caches the result of AssignedRanges, as it checks every rune against the unicode package's tables.
+var assignedRanges struct {
+	once   sync.Once
+	ranges [][2]rune
+}
+
+// AssignedRanges returns the sorted and merged ranges of every rune that is assigned according to the tables of the
+// unicode package, which are the tables of unicode.Version. Private use runes are assigned, as many character sets map
+// vendor-specific characters to them. Unlike UnicodeAges, the result depends on the Go toolchain, so it is intended for
+// skipping the runes that are unlikely to be in a character set, rather than for pinning an extraction.
+func AssignedRanges() [][2]rune {
+	assignedRanges.once.Do(func() {
+		// The table of every "other" rune includes the unassigned runes, so only its assigned categories are used
+		tables := []*unicode.RangeTable{unicode.L, unicode.M, unicode.N, unicode.P, unicode.S, unicode.Z,
+			unicode.Cc, unicode.Cf, unicode.Co, unicode.Cs}
+		for r := rune(0); r <= utf8.MaxRune; r++ {
+			if !unicode.In(r, tables...) {
+				continue
+			}
+			ranges := assignedRanges.ranges
+			if len(ranges) > 0 && ranges[len(ranges)-1][1]+1 == r {
+				ranges[len(ranges)-1][1] = r
+			} else {
+				assignedRanges.ranges = append(ranges, [2]rune{r, r})
+			}
+		}
+	})
+	return assignedRanges.ranges
+}
+
+// UnassignedRanges returns the sorted ranges of every rune that AssignedRanges does not contain, which are the runes that
+// UTF8Iter.SkipUnassigned skips. The surrogates are included, as the iterator skips them regardless.
+func UnassignedRanges() [][2]rune {
+	var ranges [][2]rune
+	next := rune(0)
+	for _, assigned := range AssignedRanges() {
+		if assigned[0] > next {
+			ranges = append(ranges, [2]rune{next, assigned[0] - 1})
+		}
+		next = assigned[1] + 1
+	}
+	if next <= utf8.MaxRune {
+		ranges = append(ranges, [2]rune{next, utf8.MaxRune})
+	}
+	return ranges
+}
+
+// parseUnicodeVersion parses a version such as "15.0" into its major and minor components.
+func parseUnicodeVersion(version string) ([2]int, error) {
+	parts := strings.Split(version, ".")
+	if len(parts) != 2 {
+		return [2]int{}, fmt.Errorf("invalid Unicode version `%s`", version)
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return [2]int{}, fmt.Errorf("invalid Unicode version `%s`", version)
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return [2]int{}, fmt.Errorf("invalid Unicode version `%s`", version)
+	}
+	return [2]int{major, minor}, nil
+}
+
+// compareUnicodeVersions returns -1 if the left version is older, 1 if it is newer, and 0 if they're the same.
+func compareUnicodeVersions(l [2]int, r [2]int) int {
+	for i := range l {
+		if l[i] < r[i] {
+			return -1
+		} else if l[i] > r[i] {
+			return 1
+		}
+	}
+	return 0
+}
+
+// NewPinnedUTF8Iter returns a UTF8Iter over the runes that were assigned in or before the given Unicode version, using
+// the DerivedAge.txt file at the given path. An empty version uses the version of the file. An empty path returns an
+// iterator over every rune, which is not pinned to any version. Also returns the pinned version, which is empty when
+// the iterator is not pinned.
+func NewPinnedUTF8Iter(derivedAgePath string, version string) (*UTF8Iter, string, error) {
+	iter := NewUTF8Iter()
+	if derivedAgePath == "" {
+		return iter, "", nil
+	}
+	ages, err := LoadDerivedAge(derivedAgePath)
+	if err != nil {
+		return nil, "", err
+	}
+	if version == "" {
+		version = ages.LatestVersion()
+	}
+	ranges, err := ages.AssignedRanges(version)
+	if err != nil {
+		return nil, "", err
+	}
+	iter.SetRanges(ranges)
+	return iter, version, nil
+}
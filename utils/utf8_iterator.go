@@ -19,17 +19,35 @@ import (
 	"unicode/utf8"
 )
 
+// SupplementaryPlaneStart is the first rune that is not within the Basic Multilingual Plane.
+const SupplementaryPlaneStart rune = 0x10000
+
+// PlaneCount is the number of Unicode planes, each of which contains 0x10000 runes. The Basic Multilingual Plane is
+// plane 0, and the supplementary planes are 1 through 16.
+const PlaneCount = 17
+
 // UTF8Iter iterates over the entire valid range of unicode characters that Go supports.
 type UTF8Iter struct {
+	start rune
 	r     rune
 	count int
 	limit int
+	// ranges restricts iteration to the given inclusive ranges when non-nil. Ranges are sorted and do not overlap.
+	ranges   [][2]rune
+	rangeIdx int
 }
 
 // NewUTF8Iter returns a new UTF8Iter.
 func NewUTF8Iter() *UTF8Iter {
 	// Negative numbers do not represent any valid runes so we start at 0.
-	return &UTF8Iter{0, 0, math.MaxInt32}
+	return &UTF8Iter{start: 0, r: 0, count: 0, limit: math.MaxInt32}
+}
+
+// NewSupplementaryUTF8Iter returns a new UTF8Iter that only iterates over the supplementary planes (U+10000 and above).
+// New Unicode versions primarily add characters to these planes, so this allows an existing extraction to be extended
+// without iterating over the Basic Multilingual Plane again.
+func NewSupplementaryUTF8Iter() *UTF8Iter {
+	return &UTF8Iter{start: SupplementaryPlaneStart, r: SupplementaryPlaneStart, count: 0, limit: math.MaxInt32}
 }
 
 // Next returns the next sequential rune. Returns false if there are no more runes to iterate through.
@@ -41,12 +59,29 @@ func (iter *UTF8Iter) Next() (rune, bool) {
 	if iter.count >= iter.limit {
 		return 0, false
 	}
-	// Negative numbers do not represent any valid runes
-	if iter.r > utf8.MaxRune {
-		return 0, false
-	}
-	if utf8SurrogateMin <= iter.r && iter.r <= utf8SurrogateMax {
-		iter.r = utf8SurrogateMax + 1
+	for {
+		// Skip to the next range when the current rune is not within one
+		if iter.ranges != nil {
+			for iter.rangeIdx < len(iter.ranges) && iter.r > iter.ranges[iter.rangeIdx][1] {
+				iter.rangeIdx++
+			}
+			if iter.rangeIdx >= len(iter.ranges) {
+				return 0, false
+			}
+			if iter.r < iter.ranges[iter.rangeIdx][0] {
+				iter.r = iter.ranges[iter.rangeIdx][0]
+			}
+		}
+		// Negative numbers do not represent any valid runes
+		if iter.r > utf8.MaxRune {
+			return 0, false
+		}
+		// Skipping the surrogates may leave the current range, so we check the ranges again
+		if utf8SurrogateMin <= iter.r && iter.r <= utf8SurrogateMax {
+			iter.r = utf8SurrogateMax + 1
+			continue
+		}
+		break
 	}
 	iter.r++
 	iter.count++
@@ -64,8 +99,185 @@ func (iter *UTF8Iter) MaxRune() rune {
 	return utf8.MaxRune
 }
 
+// SetRanges restricts the iterator to the given inclusive ranges, which must be sorted and must not overlap. Runes
+// outside of the ranges are skipped, as are the surrogates within the ranges. This resets the iterator.
+func (iter *UTF8Iter) SetRanges(ranges [][2]rune) {
+	iter.ranges = ranges
+	iter.Reset()
+}
+
+// SetRange restricts the iterator to the runes from lo to hi inclusive, such as to re-extract a region that failed. When
+// the iterator is already restricted (such as by SetRanges or SetPlanes), only the runes within both are returned.
+// This resets the iterator.
+func (iter *UTF8Iter) SetRange(lo rune, hi rune) {
+	iter.restrict([][2]rune{{lo, hi}})
+}
+
+// SetPlanes restricts the iterator to the given Unicode planes, such as plane 0 for character sets that only encode the
+// Basic Multilingual Plane. When the iterator is already restricted, only the runes within both are returned. This
+// resets the iterator.
+func (iter *UTF8Iter) SetPlanes(planes ...int) {
+	included := make([]bool, PlaneCount)
+	for _, plane := range planes {
+		if plane >= 0 && plane < PlaneCount {
+			included[plane] = true
+		}
+	}
+	var ranges [][2]rune
+	for plane := range included {
+		if !included[plane] {
+			continue
+		}
+		lo, hi := rune(plane)*SupplementaryPlaneStart, rune(plane+1)*SupplementaryPlaneStart-1
+		// Adjacent planes are merged, as the ranges must not overlap
+		if len(ranges) > 0 && ranges[len(ranges)-1][1]+1 == lo {
+			ranges[len(ranges)-1][1] = hi
+		} else {
+			ranges = append(ranges, [2]rune{lo, hi})
+		}
+	}
+	iter.restrict(ranges)
+}
+
+// SkipUnassigned restricts the iterator to the runes that are assigned according to the unicode package's tables, as
+// returned by AssignedRanges. Most character sets only contain assigned runes, so this skips the bulk of the runes that
+// would otherwise be converted for nothing. When the iterator is already restricted, only the runes within both are
+// returned. This resets the iterator.
+func (iter *UTF8Iter) SkipUnassigned() {
+	iter.restrict(AssignedRanges())
+}
+
+// SkipAssigned restricts the iterator to the runes that SkipUnassigned skips, so that an extraction that skipped them
+// may be verified. When the iterator is already restricted, only the runes within both are returned. This resets the
+// iterator.
+func (iter *UTF8Iter) SkipAssigned() {
+	iter.restrict(UnassignedRanges())
+}
+
+// restrict sets the ranges of the iterator to the intersection of its current ranges and the given ranges, which must be
+// sorted and must not overlap. This resets the iterator.
+func (iter *UTF8Iter) restrict(ranges [][2]rune) {
+	if iter.ranges == nil {
+		iter.SetRanges(ranges)
+		return
+	}
+	// Both sets of ranges are sorted, so they are intersected in a single pass
+	intersection := [][2]rune{}
+	for i, j := 0, 0; i < len(iter.ranges) && j < len(ranges); {
+		lo, hi := iter.ranges[i][0], iter.ranges[i][1]
+		if ranges[j][0] > lo {
+			lo = ranges[j][0]
+		}
+		if ranges[j][1] < hi {
+			hi = ranges[j][1]
+		}
+		if lo <= hi {
+			intersection = append(intersection, [2]rune{lo, hi})
+		}
+		if iter.ranges[i][1] < ranges[j][1] {
+			i++
+		} else {
+			j++
+		}
+	}
+	iter.SetRanges(intersection)
+}
+
+// SetStart sets the first rune that the iterator returns, such as when resuming an extraction from a checkpoint. This
+// resets the iterator.
+func (iter *UTF8Iter) SetStart(start rune) {
+	iter.start = start
+	iter.Reset()
+}
+
+// Total returns the number of runes that the iterator returns from its initial state, taking the limit into account.
+func (iter *UTF8Iter) Total() int {
+	total := 0
+	for _, rng := range iter.effectiveRanges() {
+		total += int(rng[1]-rng[0]) + 1
+	}
+	return total
+}
+
+// Split divides the runes that the iterator returns from its initial state into at most n iterators, each over a
+// contiguous block of runes, where the number of runes of any two blocks differs by at most one. The blocks are
+// disjoint and in order, so the runes of each iterator in turn are the runes of this iterator, which allows concurrent
+// workers to each own a block while their results are merged in sequential order. Fewer than n iterators are returned
+// when there are fewer than n runes.
+func (iter *UTF8Iter) Split(n int) []*UTF8Iter {
+	if n < 1 {
+		n = 1
+	}
+	total := iter.Total()
+	var shards []*UTF8Iter
+	var shardRanges [][2]rune
+	shardCount := 0
+	for _, rng := range iter.effectiveRanges() {
+		for lo := rng[0]; lo <= rng[1]; {
+			// The first blocks each take one of the remaining runes, so that every block is within one of the others
+			shardSize := total / n
+			if len(shards) < total%n {
+				shardSize++
+			}
+			hi := rng[1]
+			if int(hi-lo)+1 > shardSize-shardCount {
+				hi = lo + rune(shardSize-shardCount) - 1
+			}
+			shardRanges = append(shardRanges, [2]rune{lo, hi})
+			shardCount += int(hi-lo) + 1
+			if shardCount == shardSize {
+				shard := NewUTF8Iter()
+				shard.SetRanges(shardRanges)
+				shards = append(shards, shard)
+				shardRanges, shardCount = nil, 0
+			}
+			lo = hi + 1
+		}
+	}
+	return shards
+}
+
+// effectiveRanges returns the ranges of the runes that the iterator returns from its initial state, which accounts for
+// the start, the surrogates, and the limit.
+func (iter *UTF8Iter) effectiveRanges() [][2]rune {
+	const utf8SurrogateMin = 0xD800
+	const utf8SurrogateMax = 0xDFFF
+	ranges := iter.ranges
+	if ranges == nil {
+		ranges = [][2]rune{{0, utf8.MaxRune}}
+	}
+	var effective [][2]rune
+	remaining := iter.limit
+	for _, rng := range ranges {
+		lower, upper := rng[0], rng[1]
+		if lower < iter.start {
+			lower = iter.start
+		}
+		if upper > utf8.MaxRune {
+			upper = utf8.MaxRune
+		}
+		// The surrogates are skipped by the iterator, so the range is split around them
+		parts := [][2]rune{{lower, upper}}
+		if lower <= utf8SurrogateMax && upper >= utf8SurrogateMin {
+			parts = [][2]rune{{lower, utf8SurrogateMin - 1}, {utf8SurrogateMax + 1, upper}}
+		}
+		for _, part := range parts {
+			if part[0] > part[1] || remaining <= 0 {
+				continue
+			}
+			if count := int(part[1]-part[0]) + 1; count > remaining {
+				part[1] = part[0] + rune(remaining) - 1
+			}
+			remaining -= int(part[1]-part[0]) + 1
+			effective = append(effective, part)
+		}
+	}
+	return effective
+}
+
 // Reset returns the iterator to its initial state.
 func (iter *UTF8Iter) Reset() {
-	iter.r = 0
+	iter.r = iter.start
 	iter.count = 0
+	iter.rangeIdx = 0
 }
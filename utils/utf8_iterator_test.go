@@ -0,0 +1,141 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// collectRunes returns every rune that the iterator returns, checking the count against Total.
+func collectRunes(t *testing.T, iter *UTF8Iter) []rune {
+	var runes []rune
+	for r, ok := iter.Next(); ok; r, ok = iter.Next() {
+		runes = append(runes, r)
+	}
+	assert.Equal(t, iter.Total(), len(runes))
+	return runes
+}
+
+func TestUTF8IterSetRange(t *testing.T) {
+	iter := NewUTF8Iter()
+	iter.SetRange(0x41, 0x45)
+	assert.Equal(t, []rune{'A', 'B', 'C', 'D', 'E'}, collectRunes(t, iter))
+
+	// The surrogates are skipped within a range
+	iter = NewUTF8Iter()
+	iter.SetRange(0xD7FE, 0xE001)
+	assert.Equal(t, []rune{0xD7FE, 0xD7FF, 0xE000, 0xE001}, collectRunes(t, iter))
+
+	// An existing restriction is intersected rather than replaced
+	iter = NewUTF8Iter()
+	iter.SetRanges([][2]rune{{0x10, 0x20}, {0x30, 0x40}, {0x50, 0x60}})
+	iter.SetRange(0x1F, 0x31)
+	assert.Equal(t, []rune{0x1F, 0x20, 0x30, 0x31}, collectRunes(t, iter))
+	iter.SetRange(0x21, 0x2F)
+	assert.Empty(t, collectRunes(t, iter))
+
+	// The start still applies after restricting
+	iter = NewUTF8Iter()
+	iter.SetRange(0x41, 0x45)
+	iter.SetStart(0x44)
+	assert.Equal(t, []rune{'D', 'E'}, collectRunes(t, iter))
+}
+
+func TestUTF8IterSetPlanes(t *testing.T) {
+	iter := NewUTF8Iter()
+	iter.SetPlanes(0)
+	assert.Equal(t, 0x10000-0x800, iter.Total())
+	runes := collectRunes(t, iter)
+	assert.Equal(t, rune(0xFFFF), runes[len(runes)-1])
+
+	// Adjacent planes are merged, and invalid planes are ignored
+	iter = NewUTF8Iter()
+	iter.SetPlanes(2, 1, 16, 17, -1)
+	assert.Equal(t, [][2]rune{{0x10000, 0x2FFFF}, {0x100000, 0x10FFFF}}, iter.ranges)
+	assert.Equal(t, 3*0x10000, iter.Total())
+
+	// Planes and ranges combine
+	iter = NewUTF8Iter()
+	iter.SetRange(0xFFFE, 0x10001)
+	iter.SetPlanes(1)
+	assert.Equal(t, []rune{0x10000, 0x10001}, collectRunes(t, iter))
+}
+
+func TestUTF8IterSkipUnassigned(t *testing.T) {
+	iter := NewUTF8Iter()
+	iter.SetRange(0x370, 0x37F)
+	iter.SkipUnassigned()
+	// U+0378 and U+0379 are unassigned within the Greek block
+	assert.Equal(t, []rune{0x370, 0x371, 0x372, 0x373, 0x374, 0x375, 0x376, 0x377, 0x37A, 0x37B, 0x37C, 0x37D, 0x37E, 0x37F}, collectRunes(t, iter))
+	iter = NewUTF8Iter()
+	iter.SetRange(0x370, 0x37F)
+	iter.SkipAssigned()
+	assert.Equal(t, []rune{0x378, 0x379}, collectRunes(t, iter))
+
+	// Private use runes are assigned, as character sets map vendor-specific characters to them
+	iter = NewUTF8Iter()
+	iter.SetRange(0xE000, 0xE002)
+	iter.SkipUnassigned()
+	assert.Equal(t, []rune{0xE000, 0xE001, 0xE002}, collectRunes(t, iter))
+
+	// Every rune is either assigned or unassigned
+	assigned, unassigned := NewUTF8Iter(), NewUTF8Iter()
+	assigned.SkipUnassigned()
+	unassigned.SkipAssigned()
+	assert.Equal(t, NewUTF8Iter().Total(), assigned.Total()+unassigned.Total())
+}
+
+func TestUTF8IterSplit(t *testing.T) {
+	// The shards are in order, and together return the same runes as the iterator
+	iter := NewUTF8Iter()
+	iter.SetRanges([][2]rune{{0x10, 0x1A}, {0x30, 0x31}, {0xD7FD, 0xE002}})
+	expected := collectRunes(t, iter)
+	shards := iter.Split(4)
+	require.Len(t, shards, 4)
+	var actual []rune
+	for _, shard := range shards {
+		runes := collectRunes(t, shard)
+		// Every shard is within one rune of the others, with the first shards taking the remainder
+		assert.Contains(t, []int{len(expected) / 4, len(expected)/4 + 1}, len(runes))
+		actual = append(actual, runes...)
+	}
+	assert.Equal(t, expected, actual)
+
+	// The start and limit are taken into account
+	iter = NewUTF8Iter()
+	iter.SetStart(0x100)
+	iter.SetIteratorLimit(10)
+	shards = iter.Split(3)
+	require.Len(t, shards, 3)
+	assert.Equal(t, []rune{0x100, 0x101, 0x102, 0x103}, collectRunes(t, shards[0]))
+	assert.Equal(t, []rune{0x104, 0x105, 0x106}, collectRunes(t, shards[1]))
+	assert.Equal(t, []rune{0x107, 0x108, 0x109}, collectRunes(t, shards[2]))
+
+	// There are never more shards than runes
+	iter = NewUTF8Iter()
+	iter.SetRange('a', 'b')
+	assert.Len(t, iter.Split(8), 2)
+	assert.Len(t, iter.Split(0), 1)
+
+	// Every rune is split
+	total := 0
+	for _, shard := range NewUTF8Iter().Split(7) {
+		total += shard.Total()
+	}
+	assert.Equal(t, NewUTF8Iter().Total(), total)
+}
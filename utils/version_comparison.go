@@ -0,0 +1,116 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"unicode/utf8"
+)
+
+// VersionComparison contains the changes of a character set or collation across several server versions (such as the
+// releases of MySQL), which documents how its encodings, case conversions, and weights have changed between releases.
+// Each version is compared against the version before it.
+type VersionComparison struct {
+	Name     string   `json:"name"`
+	Kind     string   `json:"kind"`
+	Versions []string `json:"versions"`
+	// Missing lists the versions that do not offer the character set or collation, which the changes skip over.
+	Missing []string        `json:"missing,omitempty"`
+	Changes []VersionChange `json:"changes"`
+	// Codepoints lists every change by the codepoint that it affects, sorted by codepoint.
+	Codepoints []CodepointHistory `json:"codepoints"`
+}
+
+// VersionChange is every difference between a version and the version before it.
+type VersionChange struct {
+	From string    `json:"from"`
+	To   string    `json:"to"`
+	Diff ModelDiff `json:"diff"`
+}
+
+// CodepointHistory is every change that affects a single codepoint, in the order of the versions.
+type CodepointHistory struct {
+	Rune    rune     `json:"rune"`
+	Changes []string `json:"changes"`
+}
+
+// CompareVersions returns the changes between each of the given Models, which were extracted from the matching server
+// versions in order from the oldest. A nil Model marks a version that does not offer the character set or collation.
+func CompareVersions(name string, kind string, versions []string, models []*Model) (*VersionComparison, error) {
+	if len(versions) != len(models) {
+		return nil, fmt.Errorf("%d versions were given for %d models", len(versions), len(models))
+	}
+	comparison := &VersionComparison{Name: name, Kind: kind, Versions: versions}
+	histories := make(map[rune]*CodepointHistory)
+	addHistory := func(change VersionChange, r rune, str string) {
+		history, ok := histories[r]
+		if !ok {
+			history = &CodepointHistory{Rune: r}
+			histories[r] = history
+		}
+		history.Changes = append(history.Changes, fmt.Sprintf("%s to %s: %s", change.From, change.To, str))
+	}
+	previous := -1
+	for i, model := range models {
+		if model == nil {
+			comparison.Missing = append(comparison.Missing, versions[i])
+			continue
+		}
+		if previous >= 0 {
+			diff, err := DiffModels(models[previous], model)
+			if err != nil {
+				return nil, err
+			}
+			change := VersionChange{From: versions[previous], To: versions[i], Diff: diff}
+			comparison.Changes = append(comparison.Changes, change)
+			for _, encodingDiff := range diff.Encodings {
+				data := encodingDiff.Left
+				if data == nil {
+					data = encodingDiff.Right
+				}
+				if r, _ := utf8.DecodeRune(data); r != utf8.RuneError {
+					addHistory(change, r, "encoding: "+encodingDiff.String())
+				}
+			}
+			for _, caseDiff := range diff.ToUpper {
+				addHistory(change, caseDiff.Rune, "uppercase: "+caseDiff.String())
+			}
+			for _, caseDiff := range diff.ToLower {
+				addHistory(change, caseDiff.Rune, "lowercase: "+caseDiff.String())
+			}
+			for _, weightDiff := range diff.Weights {
+				addHistory(change, weightDiff.Rune, "weight: "+weightDiff.String())
+			}
+		}
+		previous = i
+	}
+	for _, history := range histories {
+		comparison.Codepoints = append(comparison.Codepoints, *history)
+	}
+	sort.Slice(comparison.Codepoints, func(i, j int) bool {
+		return comparison.Codepoints[i].Rune < comparison.Codepoints[j].Rune
+	})
+	return comparison, nil
+}
+
+// Len returns the number of differences across every change.
+func (comparison *VersionComparison) Len() int {
+	length := 0
+	for _, change := range comparison.Changes {
+		length += change.Diff.Len()
+	}
+	return length
+}
@@ -0,0 +1,52 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareVersions(t *testing.T) {
+	// Each version moves a different rune, and the middle version does not offer the collation
+	first := &Model{Name: "test_ci", Kind: ManifestKindCollation, Weights: [][]rune{{'a'}, {'b'}, {'c'}}}
+	second := &Model{Name: "test_ci", Kind: ManifestKindCollation, Weights: [][]rune{{'a', 'b'}, {'c'}}}
+	third := &Model{Name: "test_ci", Kind: ManifestKindCollation, Weights: [][]rune{{'a', 'b'}, {'c'}, {'d'}}}
+	comparison, err := CompareVersions("test_ci", ManifestKindCollation, []string{"5.7.40", "8.0.0", "8.0.31", "8.4.0"},
+		[]*Model{first, nil, second, third})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"8.0.0"}, comparison.Missing)
+	require.Len(t, comparison.Changes, 2)
+	assert.Equal(t, "5.7.40", comparison.Changes[0].From)
+	assert.Equal(t, "8.0.31", comparison.Changes[0].To)
+	assert.Equal(t, "8.0.31", comparison.Changes[1].From)
+	assert.Equal(t, "8.4.0", comparison.Changes[1].To)
+	assert.Equal(t, comparison.Changes[0].Diff.Len()+comparison.Changes[1].Diff.Len(), comparison.Len())
+
+	// The codepoints are sorted, with each one listing the versions that changed it. Merging `a` and `b` also moves `c`,
+	// as the row before it changed
+	var runes []rune
+	for _, history := range comparison.Codepoints {
+		runes = append(runes, history.Rune)
+	}
+	assert.Equal(t, []rune{'a', 'b', 'c', 'd'}, runes)
+	require.Len(t, comparison.Codepoints[3].Changes, 1)
+	assert.Contains(t, comparison.Codepoints[3].Changes[0], "8.0.31 to 8.4.0: weight:")
+
+	_, err = CompareVersions("test_ci", ManifestKindCollation, []string{"8.0.31"}, []*Model{first, second})
+	assert.Error(t, err)
+}
@@ -0,0 +1,70 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SaveWeightCache writes the given weights to a file, so that they may be loaded by LoadWeightCache to seed the
+// extraction of a related collation. Each line contains a rune and its weight (hex encoded), separated by a tab. Lines
+// are sorted by rune.
+func SaveWeightCache(path string, weights map[rune][]byte) error {
+	sortedRunes := make([]rune, 0, len(weights))
+	for r := range weights {
+		sortedRunes = append(sortedRunes, r)
+	}
+	sort.Slice(sortedRunes, func(i, j int) bool {
+		return sortedRunes[i] < sortedRunes[j]
+	})
+	sb := strings.Builder{}
+	for _, r := range sortedRunes {
+		sb.WriteString(fmt.Sprintf("%d\t%s\n", r, hex.EncodeToString(weights[r])))
+	}
+	return os.WriteFile(path, []byte(sb.String()), 0644)
+}
+
+// LoadWeightCache reads the weights from a file that was written by SaveWeightCache.
+func LoadWeightCache(path string) (map[rune][]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	weights := make(map[rune][]byte)
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d of `%s` does not contain a rune and a weight", lineNumber, path)
+		}
+		r, err := strconv.ParseInt(fields[0], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("line %d of `%s`: %w", lineNumber, path, err)
+		}
+		weight, err := hex.DecodeString(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d of `%s`: %w", lineNumber, path, err)
+		}
+		weights[rune(r)] = weight
+	}
+	return weights, scanner.Err()
+}
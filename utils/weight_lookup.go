@@ -0,0 +1,176 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"sort"
+	"unicode/utf8"
+)
+
+// WeightLookup is a representation of a collation's weights, which returns the weight of any rune. Each implementation
+// mirrors a representation that generated code could use, so that the representations may be compared by benchmarking
+// them against each other. Representations that only exist as compiled code (such as a switch statement) cannot be
+// constructed at runtime, and must be benchmarked within GMS.
+type WeightLookup interface {
+	// Weight returns the weight of the given rune.
+	Weight(r rune) int32
+	// Size returns the approximate number of bytes that the representation occupies in memory.
+	Size() int
+}
+
+var _ WeightLookup = (*RuneWeights)(nil)
+var _ WeightLookup = (*MapWeightLookup)(nil)
+var _ WeightLookup = (*SortedSliceWeightLookup)(nil)
+var _ WeightLookup = (*PageTableWeightLookup)(nil)
+
+// Size implements the WeightLookup interface. RuneWeights matches the representation of the generated files.
+func (rw *RuneWeights) Size() int {
+	// Go maps use roughly 8 bytes per entry for overhead in addition to the key and value
+	return len(rw.weights)*(4+4+8) + len(rw.dynamicRanges)*24 + len(rw.staticRanges)*24
+}
+
+// MapWeightLookup stores the weight of every rune within a single map.
+type MapWeightLookup struct {
+	weights map[rune]int32
+}
+
+// NewMapWeightLookup returns a MapWeightLookup containing the weights of the given lookup. Runes with the maximum weight
+// are not stored, as it is the default.
+func NewMapWeightLookup(source WeightLookup) *MapWeightLookup {
+	lookup := &MapWeightLookup{weights: make(map[rune]int32)}
+	for r := rune(0); r <= utf8.MaxRune; r++ {
+		if weight := source.Weight(r); weight != 2147483647 {
+			lookup.weights[r] = weight
+		}
+	}
+	return lookup
+}
+
+// Weight implements the WeightLookup interface.
+func (lookup *MapWeightLookup) Weight(r rune) int32 {
+	if weight, ok := lookup.weights[r]; ok {
+		return weight
+	}
+	return 2147483647
+}
+
+// Size implements the WeightLookup interface.
+func (lookup *MapWeightLookup) Size() int {
+	return len(lookup.weights) * (4 + 4 + 8)
+}
+
+// SortedSliceWeightLookup stores ranges of runes within a slice sorted by their lower bound, which is binary searched.
+// Each range either has an offset that is added to the rune, or a static weight.
+type SortedSliceWeightLookup struct {
+	ranges []sortedWeightRange
+}
+
+// sortedWeightRange is a range within a SortedSliceWeightLookup.
+type sortedWeightRange struct {
+	Lower rune
+	Upper rune
+	// Value is either the offset or the weight, depending on IsOffset.
+	Value    int32
+	IsOffset bool
+}
+
+// NewSortedSliceWeightLookup returns a SortedSliceWeightLookup containing the weights of the given lookup.
+func NewSortedSliceWeightLookup(source WeightLookup) *SortedSliceWeightLookup {
+	lookup := &SortedSliceWeightLookup{}
+	for r := rune(0); r <= utf8.MaxRune; r++ {
+		weight := source.Weight(r)
+		if weight == 2147483647 {
+			continue
+		}
+		if len(lookup.ranges) > 0 {
+			last := &lookup.ranges[len(lookup.ranges)-1]
+			if last.Upper+1 == r {
+				// A range of a single rune may become either kind of range
+				if last.Lower == last.Upper && last.Value+1 == weight && !last.IsOffset {
+					last.Value = last.Value - last.Lower
+					last.IsOffset = true
+				}
+				if (last.IsOffset && r+last.Value == weight) || (!last.IsOffset && last.Value == weight) {
+					last.Upper = r
+					continue
+				}
+			}
+		}
+		lookup.ranges = append(lookup.ranges, sortedWeightRange{Lower: r, Upper: r, Value: weight})
+	}
+	return lookup
+}
+
+// Weight implements the WeightLookup interface.
+func (lookup *SortedSliceWeightLookup) Weight(r rune) int32 {
+	idx := sort.Search(len(lookup.ranges), func(i int) bool {
+		return lookup.ranges[i].Upper >= r
+	})
+	if idx < len(lookup.ranges) && lookup.ranges[idx].Lower <= r {
+		if lookup.ranges[idx].IsOffset {
+			return r + lookup.ranges[idx].Value
+		}
+		return lookup.ranges[idx].Value
+	}
+	return 2147483647
+}
+
+// Size implements the WeightLookup interface.
+func (lookup *SortedSliceWeightLookup) Size() int {
+	return len(lookup.ranges) * 16
+}
+
+// pageTableSize is the number of runes within each page of a PageTableWeightLookup.
+const pageTableSize = 256
+
+// PageTableWeightLookup stores the weights within fixed-size pages, which are indexed by the upper bits of the rune.
+// Identical pages (such as pages of unassigned runes) are only stored once.
+type PageTableWeightLookup struct {
+	index []uint16
+	pages [][pageTableSize]int32
+}
+
+// NewPageTableWeightLookup returns a PageTableWeightLookup containing the weights of the given lookup.
+func NewPageTableWeightLookup(source WeightLookup) *PageTableWeightLookup {
+	lookup := &PageTableWeightLookup{index: make([]uint16, (utf8.MaxRune+1)/pageTableSize)}
+	pageIndexes := make(map[[pageTableSize]int32]uint16)
+	for pageIdx := range lookup.index {
+		var page [pageTableSize]int32
+		for i := range page {
+			page[i] = source.Weight(rune(pageIdx*pageTableSize + i))
+		}
+		idx, ok := pageIndexes[page]
+		if !ok {
+			idx = uint16(len(lookup.pages))
+			pageIndexes[page] = idx
+			lookup.pages = append(lookup.pages, page)
+		}
+		lookup.index[pageIdx] = idx
+	}
+	return lookup
+}
+
+// Weight implements the WeightLookup interface.
+func (lookup *PageTableWeightLookup) Weight(r rune) int32 {
+	if r < 0 || r > utf8.MaxRune {
+		return 2147483647
+	}
+	return lookup.pages[lookup.index[r/pageTableSize]][r%pageTableSize]
+}
+
+// Size implements the WeightLookup interface.
+func (lookup *PageTableWeightLookup) Size() int {
+	return len(lookup.index)*2 + len(lookup.pages)*pageTableSize*4
+}
@@ -0,0 +1,139 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// A file previously generated by TestExtractCollation, the benchmark is skipped when the file does not exist
+	BenchmarkWeightLookup_file = "../utf16_unicode_ci.go.txt"
+	// A UTF-8 text file containing the corpus, the built-in corpus is used when empty
+	BenchmarkWeightLookup_corpus = ""
+)
+
+// weightLookupCorpus is a small corpus containing text from a variety of scripts, which approximates the distribution of
+// runes that a database would store.
+const weightLookupCorpus = `The quick brown fox jumps over the lazy dog. 1234567890 !@#$%^&*()
+Größere Äpfel schmecken süß, während Ölbäume über Straßen wachsen.
+Le cœur déçu mais l'âme plutôt naïve, Louÿs rêva de crapaüter en canoë.
+Съешь же ещё этих мягких французских булок, да выпей чаю.
+Ξεσκεπάζω την ψυχοφθόρα βδελυγμία.
+いろはにほへと ちりぬるを わかよたれそ つねならむ
+色は匂へど散りぬるを我が世誰ぞ常ならむ有為の奥山今日越えて
+다람쥐 헌 쳇바퀴에 타고파
+نص حكيم له سر قاطع وذو شأن عظيم مكتوب على ثوب أخضر ومغلف بجلد أزرق
+עטלף אבק נס דרך מזגן שהתפוצץ כי חם
+เป็นมนุษย์สุดประเสริฐเลิศคุณค่า
+😀 🎉 👍🏽 🇺🇸 ∑ ∫ √ ∞ ≠ ≤ ≥ € £ ¥ © ® ™`
+
+// weightLookupRepresentations returns every representation of the given lookup, sorted by name.
+func weightLookupRepresentations(source *RuneWeights) ([]string, map[string]WeightLookup) {
+	lookups := map[string]WeightLookup{
+		"generated":    source,
+		"map":          NewMapWeightLookup(source),
+		"sorted_slice": NewSortedSliceWeightLookup(source),
+		"page_table":   NewPageTableWeightLookup(source),
+	}
+	names := make([]string, 0, len(lookups))
+	for name := range lookups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, lookups
+}
+
+func TestWeightLookupRepresentations(t *testing.T) {
+	// Weights that contain dynamic ranges, static ranges, and map entries
+	rc := NewRuneComparator()
+	rc.SetComparator(func(l rune, r rune) int {
+		lWeight, rWeight := l, r
+		if l >= 0x1000 && l < 0x1200 {
+			lWeight = 0x1000
+		}
+		if r >= 0x1000 && r < 0x1200 {
+			rWeight = 0x1000
+		}
+		if l%7 == 0 && l < 0x800 {
+			lWeight = -l
+		}
+		if r%7 == 0 && r < 0x800 {
+			rWeight = -r
+		}
+		if lWeight < rWeight {
+			return -1
+		} else if lWeight > rWeight {
+			return 1
+		}
+		return 0
+	})
+	for r := rune(0); r < 0x2000; r++ {
+		rc.Insert(r)
+	}
+	source, err := ParseRuneComparatorGoFile(RuneComparatorToGoFile(rc, "utf8mb4_test_ci", false))
+	require.NoError(t, err)
+	names, lookups := weightLookupRepresentations(source)
+	for _, name := range names {
+		for r := rune(0); r < 0x3000; r++ {
+			require.Equal(t, source.Weight(r), lookups[name].Weight(r), "%s returned the wrong weight for %d", name, r)
+		}
+	}
+}
+
+func BenchmarkWeightLookup(b *testing.B) {
+	contents, err := ReadArtifact(BenchmarkWeightLookup_file)
+	if errors.Is(err, fs.ErrNotExist) {
+		b.Skipf("`%s` does not exist", BenchmarkWeightLookup_file)
+	}
+	require.NoError(b, err)
+	source, err := ParseRuneComparatorGoFile(string(contents))
+	require.NoError(b, err)
+	corpus := []rune(weightLookupCorpus)
+	if BenchmarkWeightLookup_corpus != "" {
+		corpusContents, err := os.ReadFile(BenchmarkWeightLookup_corpus)
+		require.NoError(b, err)
+		corpus = []rune(string(corpusContents))
+	}
+
+	names, lookups := weightLookupRepresentations(source)
+	for _, name := range names {
+		lookup := lookups[name]
+		// Every representation must return the same weights, otherwise the comparison is meaningless
+		for _, r := range corpus {
+			require.Equal(b, source.Weight(r), lookup.Weight(r), "%s returned the wrong weight for %d", name, r)
+		}
+		b.Run(name, func(b *testing.B) {
+			b.ReportMetric(float64(lookup.Size()), "table-bytes")
+			b.ResetTimer()
+			start := time.Now()
+			var sum int32
+			for i := 0; i < b.N; i++ {
+				for _, r := range corpus {
+					sum += lookup.Weight(r)
+				}
+			}
+			b.ReportMetric(float64(b.N*len(corpus))/time.Since(start).Seconds(), "runes/s")
+			_ = sum
+		})
+	}
+}
@@ -0,0 +1,332 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// WeightString contains the data necessary to reproduce the output of MySQL's WEIGHT_STRING function for a collation.
+type WeightString struct {
+	// Weights contains the WEIGHT_STRING output of each individual rune.
+	Weights map[rune][]byte
+	// Levels is the number of weight levels that are contained in each weight. Levels are separated by two zero bytes,
+	// which is only done for the UCA 9.0.0 (_0900_) collations.
+	Levels int
+	// Pads is whether casting to a CHAR of a longer length pads the string with spaces before computing the weight.
+	Pads bool
+	// Contractions contains the WEIGHT_STRING output of multi-rune sequences that the collation weighs as a single
+	// collation element (such as "ch" in traditional Spanish), which differs from the concatenated weights of its runes.
+	Contractions map[string][]byte
+}
+
+// DefaultContractionRunes are the runes that contraction candidates are built from when probing a collation. Known
+// contractions (such as those in Czech, Hungarian, Slovak, and traditional Spanish) are made of Latin letters, while
+// the middle dot is part of the Catalan "l·l".
+const DefaultContractionRunes = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz·"
+
+// DefaultContractionLength is the maximum number of runes within a contraction candidate, as the longest known
+// contractions are trigraphs (such as "dzs" in Hungarian).
+const DefaultContractionLength = 3
+
+// NewWeightString returns a new WeightString.
+func NewWeightString(levels int, pads bool) *WeightString {
+	return &WeightString{
+		Weights:      make(map[rune][]byte),
+		Levels:       levels,
+		Pads:         pads,
+		Contractions: make(map[string][]byte),
+	}
+}
+
+// ContractionCandidates returns every sequence of the given runes that contains from 2 to maxLength runes, ordered by
+// length. Shorter sequences come first, so that the contractions within a longer sequence are already known.
+func ContractionCandidates(runes []rune, maxLength int) [][]rune {
+	var candidates [][]rune
+	previous := make([][]rune, len(runes))
+	for i, r := range runes {
+		previous[i] = []rune{r}
+	}
+	for length := 2; length <= maxLength; length++ {
+		var current [][]rune
+		for _, prefix := range previous {
+			for _, r := range runes {
+				current = append(current, append(append(make([]rune, 0, length), prefix...), r))
+			}
+		}
+		candidates = append(candidates, current...)
+		previous = current
+	}
+	return candidates
+}
+
+// SplitWeightLevels splits the given weight into the given number of levels. Levels are separated by two zero bytes,
+// which are aligned to two-byte boundaries. If fewer separators are found, then the trailing levels will be empty.
+func SplitWeightLevels(weight []byte, levels int) [][]byte {
+	split := make([][]byte, levels)
+	level := 0
+	start := 0
+	for i := 0; i+1 < len(weight) && level < levels-1; i += 2 {
+		if weight[i] == 0 && weight[i+1] == 0 {
+			split[level] = weight[start:i]
+			level++
+			start = i + 2
+		}
+	}
+	split[level] = weight[start:]
+	return split
+}
+
+// Compute returns the weight string of the given string, which matches `WEIGHT_STRING(str AS CHAR(charLength))`. A
+// charLength of zero matches `WEIGHT_STRING(str)`. Returns false if a rune in the string does not have a weight. The
+// longest contraction that starts at each rune is used in place of the runes' individual weights.
+func (ws *WeightString) Compute(str string, charLength int) ([]byte, bool) {
+	runes := []rune(str)
+	if charLength > 0 {
+		if len(runes) > charLength {
+			runes = runes[:charLength]
+		} else if ws.Pads {
+			for len(runes) < charLength {
+				runes = append(runes, ' ')
+			}
+		}
+	}
+	maxContraction := 0
+	for contraction := range ws.Contractions {
+		if length := len([]rune(contraction)); length > maxContraction {
+			maxContraction = length
+		}
+	}
+	levels := make([][]byte, ws.Levels)
+	for idx := 0; idx < len(runes); {
+		var weight []byte
+		var ok bool
+		for length := maxContraction; length >= 2 && !ok; length-- {
+			if idx+length <= len(runes) {
+				if weight, ok = ws.Contractions[string(runes[idx:idx+length])]; ok {
+					idx += length
+				}
+			}
+		}
+		if !ok {
+			if weight, ok = ws.Weights[runes[idx]]; !ok {
+				return nil, false
+			}
+			idx++
+		}
+		for i, levelWeight := range SplitWeightLevels(weight, ws.Levels) {
+			levels[i] = append(levels[i], levelWeight...)
+		}
+	}
+	var output []byte
+	for i, level := range levels {
+		if i > 0 {
+			output = append(output, 0, 0)
+		}
+		output = append(output, level...)
+	}
+	return output, true
+}
+
+// MaxLevelLengths returns the maximum number of weight bytes that a single rune contributes to each level.
+func (ws *WeightString) MaxLevelLengths() []int {
+	maxLengths := make([]int, ws.Levels)
+	for _, weight := range ws.Weights {
+		for i, levelWeight := range SplitWeightLevels(weight, ws.Levels) {
+			if len(levelWeight) > maxLengths[i] {
+				maxLengths[i] = len(levelWeight)
+			}
+		}
+	}
+	return maxLengths
+}
+
+// MaxWeightLength returns the maximum number of weight bytes that a single rune contributes across all levels. This
+// is comparable to the SORTLEN that MySQL reports for a collation.
+func (ws *WeightString) MaxWeightLength() int {
+	total := 0
+	for _, maxLength := range ws.MaxLevelLengths() {
+		total += maxLength
+	}
+	return total
+}
+
+// MaxSortKeyLength returns the maximum length of the weight string for a string with the given number of characters.
+// This includes the separators between levels.
+func (ws *WeightString) MaxSortKeyLength(charLength int) int {
+	return charLength*ws.MaxWeightLength() + 2*(ws.Levels-1)
+}
+
+// WeightStringToGoFile returns the given WeightString as a Go file for inclusion in an application.
+func WeightStringToGoFile(ws *WeightString, name string, options CodegenOptions) string {
+	titleName, lowerName := options.names(name)
+
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf(`%s
+
+// %s_WeightStringLevels is the number of weight levels returned by WEIGHT_STRING for the %s
+// collation. Levels are separated by two zero bytes.
+const %s_WeightStringLevels = %d
+
+// %s_WeightStringPads is whether WEIGHT_STRING pads the string with spaces when casting to a longer CHAR
+// for the %s collation.
+const %s_WeightStringPads = %t
+
+// %s_MaxWeightLength is the maximum number of weight bytes that a single character contributes to the
+// WEIGHT_STRING output (across all levels) for the %s collation.
+const %s_MaxWeightLength = %d
+
+// %s_SortKeyLength returns the maximum length of the WEIGHT_STRING output for a string containing the given number of
+// characters for the %s collation. This is used to size index keys.
+func %s_SortKeyLength(charLength int) int {
+	return charLength*%s_MaxWeightLength + %d
+}
+
+// %s_WeightStrings contains the WEIGHT_STRING output of each rune for the %s collation.
+var %s_WeightStrings = map[rune][]byte{
+`, options.fileHeader(), titleName, "`"+lowerName+"`", titleName, ws.Levels,
+		titleName, "`"+lowerName+"`", titleName, ws.Pads,
+		titleName, "`"+lowerName+"`", titleName, ws.MaxWeightLength(),
+		titleName, "`"+lowerName+"`", titleName, titleName, 2*(ws.Levels-1),
+		lowerName, "`"+lowerName+"`", lowerName))
+
+	sortedRunes := make([]rune, 0, len(ws.Weights))
+	for r := range ws.Weights {
+		sortedRunes = append(sortedRunes, r)
+	}
+	sort.Slice(sortedRunes, func(i, j int) bool {
+		return sortedRunes[i] < sortedRunes[j]
+	})
+	for _, r := range sortedRunes {
+		weight := ws.Weights[r]
+		weightBytes := make([]string, len(weight))
+		for i, b := range weight {
+			weightBytes[i] = fmt.Sprintf("0x%02X", b)
+		}
+		sb.WriteString(fmt.Sprintf("\t%d: {%s},\n", r, strings.Join(weightBytes, ", ")))
+	}
+	sb.WriteString("}\n")
+
+	if len(ws.Contractions) > 0 {
+		sb.WriteString(fmt.Sprintf(`
+// %s_Contractions contains the WEIGHT_STRING output of each sequence of runes that is weighed as a
+// single collation element for the %s collation. These take precedence over the weights of the
+// individual runes, matching the longest sequence first.
+var %s_Contractions = map[string][]byte{
+`, lowerName, "`"+lowerName+"`", lowerName))
+		sortedContractions := make([]string, 0, len(ws.Contractions))
+		for contraction := range ws.Contractions {
+			sortedContractions = append(sortedContractions, contraction)
+		}
+		sort.Strings(sortedContractions)
+		for _, contraction := range sortedContractions {
+			weight := ws.Contractions[contraction]
+			weightBytes := make([]string, len(weight))
+			for i, b := range weight {
+				weightBytes[i] = fmt.Sprintf("0x%02X", b)
+			}
+			sb.WriteString(fmt.Sprintf("\t%q: {%s},\n", contraction, strings.Join(weightBytes, ", ")))
+		}
+		sb.WriteString("}\n")
+	}
+	sb.WriteString(weightStringSortKeyFuncs(titleName, lowerName, ws))
+	return sb.String()
+}
+
+// weightStringSortKeyFuncs returns the sort key functions for a generated WeightString file, which reproduce Compute
+// using the file's weights and contractions. Runes without a weight (for which WEIGHT_STRING returns nothing) contribute
+// nothing to the sort key.
+func weightStringSortKeyFuncs(titleName string, lowerName string, ws *WeightString) string {
+	maxContraction := 0
+	for contraction := range ws.Contractions {
+		if length := len([]rune(contraction)); length > maxContraction {
+			maxContraction = length
+		}
+	}
+	contractions := ""
+	if maxContraction > 0 {
+		contractions = fmt.Sprintf(`	for length := %[1]s_maxContractionLength; length >= 2; length-- {
+		if length <= len(runes) {
+			if weight, ok := %[1]s_Contractions[string(runes[:length])]; ok {
+				return weight, length
+			}
+		}
+	}
+`, lowerName)
+	}
+	sb := strings.Builder{}
+	if maxContraction > 0 {
+		sb.WriteString(fmt.Sprintf(`
+// %[1]s_maxContractionLength is the number of runes within the longest contraction of the %[2]s collation.
+const %[1]s_maxContractionLength = %[3]d
+`, lowerName, "`"+lowerName+"`", maxContraction))
+	}
+	sb.WriteString(fmt.Sprintf(`
+// %[1]s_SortKey returns the WEIGHT_STRING output of the given string for the %[3]s collation, so that
+// sort keys (such as those of an index) byte-compare identically to the server. Runes without a weight contribute
+// nothing to the sort key, matching the server.
+func %[1]s_SortKey(str string) []byte {
+	return %[1]s_SortKeyAsChar(str, 0)
+}
+
+// %[1]s_SortKeyAsChar returns the WEIGHT_STRING output of the given string when cast to a CHAR of the given
+// length for the %[3]s collation, matching `+"`WEIGHT_STRING(str AS CHAR(charLength))`"+`. A charLength of zero
+// matches %[1]s_SortKey.
+func %[1]s_SortKeyAsChar(str string, charLength int) []byte {
+	runes := []rune(str)
+	if charLength > 0 {
+		if len(runes) > charLength {
+			runes = runes[:charLength]
+		} else if %[1]s_WeightStringPads {
+			for len(runes) < charLength {
+				runes = append(runes, ' ')
+			}
+		}
+	}
+	levels := make([][]byte, %[1]s_WeightStringLevels)
+	for idx := 0; idx < len(runes); {
+		weight, length := %[2]s_elementWeight(runes[idx:])
+		idx += length
+		// Levels are separated by two zero bytes, which are aligned to two-byte boundaries
+		level, start := 0, 0
+		for i := 0; i+1 < len(weight) && level < len(levels)-1; i += 2 {
+			if weight[i] == 0 && weight[i+1] == 0 {
+				levels[level] = append(levels[level], weight[start:i]...)
+				level++
+				start = i + 2
+			}
+		}
+		levels[level] = append(levels[level], weight[start:]...)
+	}
+	var key []byte
+	for i, level := range levels {
+		if i > 0 {
+			key = append(key, 0, 0)
+		}
+		key = append(key, level...)
+	}
+	return key
+}
+
+// %[2]s_elementWeight returns the weight of the collation element at the start of the given runes, along with
+// the number of runes that the element contains.
+func %[2]s_elementWeight(runes []rune) ([]byte, int) {
+%[4]s	return %[2]s_WeightStrings[runes[0]], 1
+}
+`, titleName, lowerName, "`"+lowerName+"`", contractions))
+	return sb.String()
+}
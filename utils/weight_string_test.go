@@ -0,0 +1,50 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWeightStringToGoFileSortKey(t *testing.T) {
+	ws := NewWeightString(2, true)
+	ws.Weights['a'] = []byte{0x10, 0x61, 0x00, 0x00, 0x00, 0x20}
+	ws.Weights['c'] = []byte{0x10, 0x63, 0x00, 0x00, 0x00, 0x20}
+	ws.Weights['h'] = []byte{0x10, 0x68, 0x00, 0x00, 0x00, 0x20}
+	expected, ok := ws.Compute("cha", 0)
+	require.True(t, ok)
+	assert.Equal(t, []byte{0x10, 0x63, 0x10, 0x68, 0x10, 0x61, 0x00, 0x00, 0x00, 0x20, 0x00, 0x20, 0x00, 0x20}, expected)
+
+	// Without contractions, the element weight is always the weight of a single rune
+	contents := WeightStringToGoFile(ws, "test", CodegenOptions{})
+	_, err := parser.ParseFile(token.NewFileSet(), "", contents, 0)
+	require.NoError(t, err)
+	assert.True(t, strings.Contains(contents, "func Test_SortKey(str string) []byte {"))
+	assert.True(t, strings.Contains(contents, "func Test_SortKeyAsChar(str string, charLength int) []byte {"))
+	assert.False(t, strings.Contains(contents, "test_maxContractionLength"))
+
+	ws.Contractions["ch"] = []byte{0x10, 0x64, 0x00, 0x00, 0x00, 0x20}
+	contents = WeightStringToGoFile(ws, "test", CodegenOptions{})
+	_, err = parser.ParseFile(token.NewFileSet(), "", contents, 0)
+	require.NoError(t, err)
+	assert.True(t, strings.Contains(contents, "const test_maxContractionLength = 2\n"))
+	assert.True(t, strings.Contains(contents, "test_Contractions[string(runes[:length])]"))
+}
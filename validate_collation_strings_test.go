@@ -0,0 +1,56 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/collation-extractor/extractor"
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+const (
+	TestValidateCollationStrings_user      = "root"
+	TestValidateCollationStrings_password  = "password"
+	TestValidateCollationStrings_host      = "localhost"
+	TestValidateCollationStrings_port      = 3306
+	TestValidateCollationStrings_collation = "utf8mb4_0900_ai_ci"
+	TestValidateCollationStrings_charset   = "utf8mb4"
+	TestValidateCollationStrings_file      = "./" + TestValidateCollationStrings_collation + ".go.txt"
+	TestValidateCollationStrings_samples   = 10000
+)
+
+// TestValidateCollationStrings compares random strings of multiple runes using the weight function from a file that was
+// previously generated by TestExtractCollation, and compares each result against MySQL comparing the same strings. The
+// extraction orders each rune individually, which cannot catch contractions and expansions that only change the order
+// of strings containing several runes.
+func TestValidateCollationStrings(t *testing.T) {
+	contents, err := utils.ReadArtifact(TestValidateCollationStrings_file)
+	require.NoError(t, err)
+	runeWeights, err := utils.ParseRuneComparatorGoFile(string(contents))
+	require.NoError(t, err)
+	conn, err := utils.NewConnection(TestValidateCollationStrings_user, TestValidateCollationStrings_password, TestValidateCollationStrings_host, TestValidateCollationStrings_port)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	mismatches, err := extractor.ValidateCollationStrings(NewContext(t, conn), conn, TestValidateCollationStrings_collation,
+		TestValidateCollationStrings_charset, runeWeights, TestValidateCollationStrings_samples, 0)
+	require.NoError(t, err)
+	for _, mismatch := range mismatches {
+		t.Error(mismatch.String())
+	}
+}
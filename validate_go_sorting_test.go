@@ -15,8 +15,6 @@
 package main
 
 import (
-	"encoding/hex"
-	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -54,16 +52,16 @@ func TestValidateGoSorting(t *testing.T) {
 	conn, err := utils.NewConnection(TestValidateGoSorting_user, TestValidateGoSorting_password, TestValidateGoSorting_host, TestValidateGoSorting_port)
 	require.NoError(t, err)
 	defer conn.Close()
+	qb := conn.Builder()
 	prevR, _ = iter.Next()
 	for r, ok := iter.Next(); ok; r, ok = iter.Next() {
 		rAsBytes := []byte(string(r))
 		prevRAsBytes := []byte(string(prevR))
 		// We convert the string to a hexadecimal to ensure that Go's exact byte representation is being given to MySQL.
 		// This also allows us to bypass escape rules.
-		sqlOutput, err := conn.Query(fmt.Sprintf(
-			"SELECT STRCMP(CONVERT(_utf8mb4 0x%s USING utf8mb4) COLLATE utf8mb4_0900_bin,"+
-				"CONVERT(_utf8mb4 0x%s USING utf8mb4) COLLATE utf8mb4_0900_bin);",
-			hex.EncodeToString(prevRAsBytes), hex.EncodeToString(rAsBytes)))
+		sqlOutput, err := conn.Query(qb.Select(qb.Call("STRCMP",
+			qb.InCollation(prevRAsBytes, "utf8mb4", qb.BinaryCollation()),
+			qb.InCollation(rAsBytes, "utf8mb4", qb.BinaryCollation()))))
 		if assert.NoError(t, err) {
 			assert.Equal(t, "-1", string(sqlOutput),
 				"Previous Rune: %d, Bytes: %v\nCurrent Rune: %d, Bytes: %v", prevR, prevRAsBytes, r, rAsBytes)
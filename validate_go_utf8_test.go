@@ -15,8 +15,6 @@
 package main
 
 import (
-	"encoding/hex"
-	"fmt"
 	"testing"
 	"unicode/utf8"
 
@@ -63,6 +61,7 @@ func TestValidateGoUTF8(t *testing.T) {
 	conn, err := utils.NewConnection(TestValidateGoUTF8_user, TestValidateGoUTF8_password, TestValidateGoUTF8_host, TestValidateGoUTF8_port)
 	require.NoError(t, err)
 	defer conn.Close()
+	qb := conn.Builder()
 	for r, ok := iter.Next(); ok; r, ok = iter.Next() {
 		// Converting a rune to a string will encode the rune (which is an int32) as a sequence of valid UTF8 bytes.
 		// It is important to note that this byte sequence may have NO RELATION to the initial rune, and it is best
@@ -70,8 +69,7 @@ func TestValidateGoUTF8(t *testing.T) {
 		rAsStr := string(r)
 		// We convert the string to a hexadecimal to ensure that Go's exact byte representation is being given to MySQL.
 		// This also allows us to bypass escape rules.
-		sqlOutput, err := conn.Query(fmt.Sprintf(`SELECT CAST(CONVERT(_utf8mb4 0x%s USING utf8mb4) AS BINARY);`,
-			hex.EncodeToString([]byte(rAsStr))))
+		sqlOutput, err := conn.Query(qb.Select(qb.AsBinary(qb.InCharset([]byte(rAsStr), "utf8mb4"))))
 		if assert.NoError(t, err) {
 			assert.Equal(t, []byte(rAsStr), sqlOutput)
 		}
@@ -0,0 +1,57 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/collation-extractor/extractor"
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+const (
+	TestValidateReverseMappings_user     = "root"
+	TestValidateReverseMappings_password = "password"
+	TestValidateReverseMappings_host     = "localhost"
+	TestValidateReverseMappings_port     = 3306
+	TestValidateReverseMappings_charset  = "utf16"
+	TestValidateReverseMappings_file     = "./" + TestValidateReverseMappings_charset + ".go.txt"
+	// Every sequence of up to this many bytes that cannot be decoded on its own is probed, which grows quickly with the
+	// number of multi-byte lead bytes of the character set
+	TestValidateReverseMappings_maxLength = 2
+)
+
+// TestValidateReverseMappings decodes byte sequences of a character set using MySQL, and compares them against a
+// RangeMap from a file that was previously generated by TestExtractCharacterSet. The extraction only encodes runes, so
+// it cannot find codepoints that MySQL decodes but never produces when encoding, or that encode to a different sequence.
+func TestValidateReverseMappings(t *testing.T) {
+	contents, err := utils.ReadArtifact(TestValidateReverseMappings_file)
+	require.NoError(t, err)
+	rangeMap, _, err := utils.ParseRangeMapGoFile(string(contents))
+	require.NoError(t, err)
+	conn, err := utils.NewConnection(TestValidateReverseMappings_user, TestValidateReverseMappings_password, TestValidateReverseMappings_host, TestValidateReverseMappings_port)
+	require.NoError(t, err)
+	defer conn.Close()
+	EnableQueryCache(t, conn)
+
+	mappings, err := extractor.CharacterSetReverseMappings(NewContext(t, conn), conn, TestValidateReverseMappings_charset, rangeMap,
+		TestValidateReverseMappings_maxLength, t.Logf)
+	require.NoError(t, err)
+	for _, mapping := range mappings {
+		t.Error(mapping.String())
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/collation-extractor/extractor"
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+const (
+	TestValidateRoundTrip_user     = "root"
+	TestValidateRoundTrip_password = "password"
+	TestValidateRoundTrip_host     = "localhost"
+	TestValidateRoundTrip_port     = 3306
+	TestValidateRoundTrip_charset  = "utf16"
+	TestValidateRoundTrip_file     = "./" + TestValidateRoundTrip_charset + ".go.txt"
+	TestValidateRoundTrip_samples  = 10000
+)
+
+// TestValidateRoundTrip converts random strings from a character set to utf8mb4 and back again, using a RangeMap from a
+// file that was previously generated by TestExtractCharacterSet, and compares each step against MySQL performing the
+// same conversions. The extraction validates each codepoint of the Decode and Encode tables individually, which cannot
+// catch asymmetries where both tables are internally consistent yet disagree with each other for some codepoints.
+func TestValidateRoundTrip(t *testing.T) {
+	contents, err := utils.ReadArtifact(TestValidateRoundTrip_file)
+	require.NoError(t, err)
+	rangeMap, _, err := utils.ParseRangeMapGoFile(string(contents))
+	require.NoError(t, err)
+	conn, err := utils.NewConnection(TestValidateRoundTrip_user, TestValidateRoundTrip_password, TestValidateRoundTrip_host, TestValidateRoundTrip_port)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	mismatches, err := extractor.ValidateRoundTrip(NewContext(t, conn), conn, TestValidateRoundTrip_charset, rangeMap, TestValidateRoundTrip_samples, 0)
+	require.NoError(t, err)
+	for _, mismatch := range mismatches {
+		t.Error(mismatch.String())
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2022 Dolthub, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dolthub/collation-extractor/utils"
+)
+
+const (
+	WriteArtifact_gzip      = false // Compresses every artifact, which is useful when attaching a full regeneration
+	WriteArtifact_txtSuffix = true  // Prevents generated Go files from being compiled when placed within a package
+	WriteArtifact_index     = "./artifacts.txt"
+	// A go-mysql-server checkout whose encodings package receives the generated Go files directly, without compression
+	// or the txt suffix. Other artifacts (such as models) are still written to their own paths.
+	WriteArtifact_gmsRoot = ""
+)
+
+// WriteArtifact_codegen sets the package, header, and build constraint of every generated Go file, which may be changed
+// to place the files somewhere other than the encodings package of GMS.
+var WriteArtifact_codegen = utils.CodegenOptions{}
+
+// WriteArtifact writes a generated artifact for all tests that create files, using the options above. The artifact is
+// added to the index, and the written path (which may have additional suffixes) is returned.
+func WriteArtifact(t *testing.T, path string, contents []byte) string {
+	if strings.HasSuffix(path, ".go") {
+		var err error
+		contents, err = WriteArtifact_codegen.ApplyToGoFile(contents)
+		require.NoError(t, err)
+	}
+	artifact, err := utils.WriteArtifact(path, contents, utils.ArtifactOptions{
+		Gzip:      WriteArtifact_gzip,
+		TxtSuffix: WriteArtifact_txtSuffix,
+		GMSRoot:   WriteArtifact_gmsRoot,
+	})
+	require.NoError(t, err)
+	if WriteArtifact_index != "" {
+		require.NoError(t, utils.UpdateArtifactIndex(WriteArtifact_index, artifact))
+	}
+	return artifact.Path
+}
+
+// StampProvenance records the given Provenance within the header comment of every Go file that the test writes from now
+// on, until the test finishes.
+func StampProvenance(t *testing.T, provenance *utils.Provenance) {
+	previous := WriteArtifact_codegen.Provenance
+	WriteArtifact_codegen.Provenance = provenance
+	t.Cleanup(func() {
+		WriteArtifact_codegen.Provenance = previous
+	})
+}
+
+// ModelCodegen returns the codegen options of every generated Go file, along with the Provenance of the given model
+// when it was recorded, for a test that regenerates the files of several models.
+func ModelCodegen(model *utils.Model) utils.CodegenOptions {
+	options := WriteArtifact_codegen
+	if model.Provenance != nil {
+		options.Provenance = model.Provenance
+	}
+	return options
+}
+
+// ArtifactBasePath returns the path that was given to WriteArtifact for a written artifact's path, so that an artifact
+// may be rewritten in place.
+func ArtifactBasePath(path string) string {
+	path = strings.TrimSuffix(path, ".gz")
+	if strings.HasSuffix(path, ".go.txt") {
+		path = strings.TrimSuffix(path, ".txt")
+	}
+	return path
+}